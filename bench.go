@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aldehir/gpt-oss-adapter/internal/bench"
+)
+
+var benchOptions bench.Options
+
+// benchCompare is a second URL, usually the target's, to run the same
+// benchmark against, so that the adapter's overhead shows.
+var benchCompare string
+
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Benchmark chat completions through the adapter",
+	Long: "Send synthetic chat completions through a running adapter, or any OpenAI-compatible server, " +
+		"and report latency percentiles, time to first token and tokens per second.",
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		urls := []string{benchOptions.URL}
+		if benchCompare != "" {
+			urls = append(urls, benchCompare)
+		}
+
+		fmt.Printf("Running %d %s runs with concurrency %d (streaming: %t)\n\n",
+			benchOptions.Runs, benchOptions.Scenario, benchOptions.Concurrency, benchOptions.Stream)
+		for i, url := range urls {
+			opts := benchOptions
+			opts.URL = url
+			report, err := bench.Run(ctx, opts)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			if i > 0 {
+				fmt.Println()
+			}
+			report.Write(os.Stdout)
+			if ctx.Err() != nil {
+				break
+			}
+		}
+	},
+}
+
+func init() {
+	flags := benchCmd.Flags()
+	flags.StringVar(&benchOptions.URL, "url", "http://localhost:8005", "Base URL of the adapter to benchmark")
+	flags.StringVar(&benchCompare, "compare", "", "Base URL, such as the target's, to run the same benchmark against afterwards")
+	flags.StringVar(&benchOptions.APIKey, "api-key", "", "API key to send as a bearer token")
+	flags.StringVarP(&benchOptions.Model, "model", "m", "", "Model to request")
+	flags.IntVarP(&benchOptions.Runs, "runs", "n", 100, "Number of times to run the scenario")
+	flags.IntVarP(&benchOptions.Concurrency, "concurrency", "C", 4, "Number of runs in flight at once")
+	flags.StringVar(&benchOptions.Scenario, "scenario", bench.ScenarioChat, "Scenario to run ("+strings.Join(bench.Scenarios(), ", ")+")")
+	flags.BoolVar(&benchOptions.Stream, "stream", true, "Stream responses, which measures time to first token")
+	flags.StringVar(&benchOptions.Prompt, "prompt", "", "User message to send instead of the scenario's")
+	flags.IntVar(&benchOptions.MaxTokens, "max-tokens", 256, "Maximum tokens to generate per request (0 leaves it to the server)")
+	flags.DurationVar(&benchOptions.Timeout, "timeout", 2*time.Minute, "Timeout for each request")
+
+	rootCmd.AddCommand(benchCmd)
+}
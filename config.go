@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+
+	"github.com/aldehir/gpt-oss-adapter/pkg/adapter"
+	"github.com/aldehir/gpt-oss-adapter/providers/types"
+)
+
+// Config holds the sections of the config file that do not correspond to a
+// command line flag. Every other top-level key is treated as the name of a
+// flag, e.g. "listen", "target" or "model-concurrency".
+type Config struct {
+	Providers    map[string]types.Provider `yaml:"providers"`
+	RequestRules []adapter.RequestRule     `yaml:"request-rules"`
+}
+
+var configSections = map[string]bool{
+	"providers":     true,
+	"request-rules": true,
+}
+
+// LoadConfig reads a YAML config file and applies it to flags that were not
+// set explicitly on the command line, so that flags always take precedence
+// over the file.
+func LoadConfig(path string, flags *pflag.FlagSet) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	var values map[string]any
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if configSections[key] {
+			continue
+		}
+
+		flag := flags.Lookup(key)
+		if flag == nil {
+			return nil, fmt.Errorf("%s: unknown option %q", path, key)
+		}
+		if flag.Changed {
+			continue
+		}
+
+		if err := setFlagValue(flags, key, values[key]); err != nil {
+			return nil, fmt.Errorf("%s: option %q: %w", path, key, err)
+		}
+	}
+
+	return &config, nil
+}
+
+// envPrefix starts the name of the environment variable for each flag, e.g.
+// GPT_OSS_ADAPTER_CACHE_SIZE for --cache-size.
+const envPrefix = "GPT_OSS_ADAPTER_"
+
+// envName returns the environment variable that sets the named flag.
+func envName(flag string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+// LoadEnv applies the environment variables named after flags to the flags
+// that were not set explicitly on the command line. It runs before
+// LoadConfig, so the command line takes precedence over the environment,
+// and the environment over the config file. Values are parsed as on the
+// command line: lists are separated by commas and maps written as
+// key=value pairs. Repeatable flags, such as --replica, take one value per
+// element, and elements that contain commas are quoted as in CSV.
+func LoadEnv(flags *pflag.FlagSet) error {
+	var err error
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if err != nil || flag.Changed || flag.Name == "help" || flag.Name == "version" {
+			return
+		}
+		value, ok := os.LookupEnv(envName(flag.Name))
+		if !ok {
+			return
+		}
+		values := []string{value}
+		if flag.Value.Type() == "stringArray" {
+			var splitErr error
+			if values, splitErr = splitEnvList(value); splitErr != nil {
+				err = fmt.Errorf("%s: %w", envName(flag.Name), splitErr)
+				return
+			}
+		}
+		for _, value := range values {
+			if setErr := flags.Set(flag.Name, value); setErr != nil {
+				err = fmt.Errorf("%s: %w", envName(flag.Name), setErr)
+				return
+			}
+		}
+	})
+	return err
+}
+
+// splitEnvList splits the value of a repeatable flag on commas. Elements
+// that contain commas themselves, such as an effort rule with several
+// conditions, are quoted as in CSV.
+func splitEnvList(value string) ([]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+	reader := csv.NewReader(strings.NewReader(value))
+	values, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	for i := range values {
+		values[i] = strings.TrimSpace(values[i])
+	}
+	return values, nil
+}
+
+// setFlagValue sets a flag from a decoded YAML value. Lists are applied one
+// element at a time, which appends for array flags, and maps are encoded as
+// key=value pairs.
+func setFlagValue(flags *pflag.FlagSet, name string, value any) error {
+	switch v := value.(type) {
+	case []any:
+		for _, item := range v {
+			if err := flags.Set(name, fmt.Sprint(item)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]any:
+		pairs := make([]string, 0, len(v))
+		for key, item := range v {
+			pairs = append(pairs, fmt.Sprintf("%s=%v", key, item))
+		}
+		sort.Strings(pairs)
+		return flags.Set(name, strings.Join(pairs, ","))
+	case nil:
+		return nil
+	default:
+		return flags.Set(name, fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	var (
+		listen      string
+		target      string
+		cacheSize   int
+		concurrency map[string]int
+		rules       []string
+	)
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.StringVar(&listen, "listen", ":8005", "")
+	flags.StringVar(&target, "target", "", "")
+	flags.IntVar(&cacheSize, "cache-size", 1000, "")
+	flags.StringToIntVar(&concurrency, "model-concurrency", nil, "")
+	flags.StringArrayVar(&rules, "effort-rule", nil, "")
+
+	require.NoError(t, flags.Parse([]string{"--listen", ":9000"}))
+
+	path := writeConfig(t, `
+listen: ":8080"
+target: http://localhost:8000
+cache-size: 50
+model-concurrency:
+  gpt-oss-120b: 2
+  gpt-oss-20b: 8
+effort-rule:
+  - "inflight>4:low"
+  - "*:medium"
+providers:
+  llama-cpp:
+    reasoning: thinking
+request-rules:
+  - match:
+      model: gpt-oss-*
+    delete: [parallel_tool_calls]
+    max:
+      max_tokens: 4096
+`)
+
+	config, err := LoadConfig(path, flags)
+	require.NoError(t, err)
+
+	assert.Equal(t, ":9000", listen, "command line flags take precedence")
+	assert.Equal(t, "http://localhost:8000", target)
+	assert.Equal(t, 50, cacheSize)
+	assert.Equal(t, map[string]int{"gpt-oss-120b": 2, "gpt-oss-20b": 8}, concurrency)
+	assert.Equal(t, []string{"inflight>4:low", "*:medium"}, rules)
+
+	assert.Equal(t, "thinking", config.Providers["llama-cpp"].Reasoning)
+	require.Len(t, config.RequestRules, 1)
+	assert.Equal(t, "gpt-oss-*", config.RequestRules[0].Match.Model)
+	assert.Equal(t, []string{"parallel_tool_calls"}, config.RequestRules[0].Delete)
+	assert.Equal(t, map[string]float64{"max_tokens": 4096}, config.RequestRules[0].Max)
+}
+
+func TestLoadConfig_UnknownOption(t *testing.T) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.String("listen", "", "")
+
+	_, err := LoadConfig(writeConfig(t, "bogus: true\n"), flags)
+	assert.ErrorContains(t, err, "bogus")
+}
+
+func TestLoadEnv(t *testing.T) {
+	var (
+		listen      string
+		target      string
+		cacheSize   int
+		concurrency map[string]int
+		statuses    []int
+	)
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.StringVar(&listen, "listen", ":8005", "")
+	flags.StringVar(&target, "target", "", "")
+	flags.IntVar(&cacheSize, "cache-size", 1000, "")
+	flags.StringToIntVar(&concurrency, "model-concurrency", nil, "")
+	flags.IntSliceVar(&statuses, "retry-on-status", nil, "")
+
+	require.NoError(t, flags.Parse([]string{"--listen", ":9000"}))
+
+	t.Setenv("GPT_OSS_ADAPTER_LISTEN", ":8080")
+	t.Setenv("GPT_OSS_ADAPTER_TARGET", "http://localhost:8000")
+	t.Setenv("GPT_OSS_ADAPTER_CACHE_SIZE", "50")
+	t.Setenv("GPT_OSS_ADAPTER_MODEL_CONCURRENCY", "gpt-oss-120b=2,gpt-oss-20b=8")
+	t.Setenv("GPT_OSS_ADAPTER_RETRY_ON_STATUS", "502,503")
+	require.NoError(t, LoadEnv(flags))
+
+	assert.Equal(t, ":9000", listen, "command line flags take precedence")
+	assert.Equal(t, "http://localhost:8000", target)
+	assert.Equal(t, 50, cacheSize)
+	assert.Equal(t, map[string]int{"gpt-oss-120b": 2, "gpt-oss-20b": 8}, concurrency)
+	assert.Equal(t, []int{502, 503}, statuses)
+
+	// The environment takes precedence over the config file.
+	_, err := LoadConfig(writeConfig(t, "cache-size: 10\n"), flags)
+	require.NoError(t, err)
+	assert.Equal(t, 50, cacheSize)
+}
+
+func TestLoadEnv_Lists(t *testing.T) {
+	var replicas, rules, empty []string
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.StringArrayVar(&replicas, "replica", nil, "")
+	flags.StringArrayVar(&rules, "effort-rule", nil, "")
+	flags.StringArrayVar(&empty, "transformer", nil, "")
+
+	t.Setenv("GPT_OSS_ADAPTER_REPLICA", "http://a:8080, http://b:8080")
+	t.Setenv("GPT_OSS_ADAPTER_EFFORT_RULE", `"tools,model=gpt-oss-120b:high",*:low`)
+	t.Setenv("GPT_OSS_ADAPTER_TRANSFORMER", "")
+	require.NoError(t, LoadEnv(flags))
+
+	assert.Equal(t, []string{"http://a:8080", "http://b:8080"}, replicas)
+	assert.Equal(t, []string{"tools,model=gpt-oss-120b:high", "*:low"}, rules)
+	assert.Empty(t, empty)
+}
+
+func TestLoadEnv_Invalid(t *testing.T) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.Int("cache-size", 1000, "")
+
+	t.Setenv("GPT_OSS_ADAPTER_CACHE_SIZE", "many")
+	assert.ErrorContains(t, LoadEnv(flags), "GPT_OSS_ADAPTER_CACHE_SIZE")
+}
@@ -0,0 +1,534 @@
+// Package bench benchmarks chat completions through the adapter, or any
+// OpenAI-compatible server, for the bench command.
+package bench
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Benchmark scenarios.
+const (
+	// ScenarioChat sends a single user message.
+	ScenarioChat = "chat"
+
+	// ScenarioTools offers a tool and, when the model calls it, sends
+	// the tool result back without the reasoning, as clients do, so that
+	// the reasoning is restored from the cache on the second turn.
+	ScenarioTools = "tools"
+)
+
+// Scenarios lists the benchmark scenarios.
+func Scenarios() []string {
+	return []string{ScenarioChat, ScenarioTools}
+}
+
+const (
+	chatPrompt  = "Explain in a few paragraphs how a hash map handles collisions."
+	toolsPrompt = "What is the weather in Paris right now? Use the get_weather tool."
+	toolResult  = `{"temperature": 18, "conditions": "sunny"}`
+)
+
+var tools = []any{map[string]any{
+	"type": "function",
+	"function": map[string]any{
+		"name":        "get_weather",
+		"description": "Get the current weather for a location.",
+		"parameters": map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"location": map[string]any{"type": "string"}},
+			"required":   []any{"location"},
+		},
+	},
+}}
+
+// Options configures Run.
+type Options struct {
+	// URL is the base URL to send chat completions to, such as the
+	// adapter's, or the target's to compare against.
+	URL    string
+	APIKey string
+	Model  string
+
+	// Runs is how many times the scenario is run, by Concurrency workers.
+	// A tools scenario run sends two requests when the model calls the
+	// tool.
+	Runs        int
+	Concurrency int
+	Scenario    string
+	Stream      bool
+
+	// Prompt replaces the scenario's user message.
+	Prompt    string
+	MaxTokens int
+	Timeout   time.Duration
+
+	// Client sends the requests. Nil uses a client without a timeout.
+	Client *http.Client
+}
+
+// Percentiles summarizes a distribution of durations.
+type Percentiles struct {
+	P50 time.Duration
+	P90 time.Duration
+	P99 time.Duration
+	Max time.Duration
+}
+
+// Report is the result of Run.
+type Report struct {
+	URL      string
+	Duration time.Duration
+	Requests int
+	Errors   int
+
+	// FirstError is the error of the first failed request, if any.
+	FirstError string
+
+	// Latency is the time until a response was complete, and TimeToFirstToken
+	// the time until the first content, reasoning or tool call delta of a
+	// streamed response.
+	Latency          Percentiles
+	TimeToFirstToken Percentiles
+
+	CompletionTokens int
+
+	// TokensPerSecond is the mean generation rate of a request, after its
+	// first token when streamed. Throughput is the completion tokens of all
+	// requests per second of the benchmark.
+	TokensPerSecond float64
+	Throughput      float64
+
+	// ToolCalls counts the responses that called a tool.
+	ToolCalls int
+}
+
+// result is the measurement of one request.
+type result struct {
+	latency          time.Duration
+	ttft             time.Duration
+	completionTokens int
+	toolCalls        bool
+	err              error
+}
+
+// Run runs a benchmark scenario against opts.URL and reports latency,
+// time to first token and token rates.
+func Run(ctx context.Context, opts Options) (*Report, error) {
+	if opts.URL == "" {
+		return nil, errors.New("a URL is required")
+	}
+	if opts.Scenario == "" {
+		opts.Scenario = ScenarioChat
+	}
+	if !slices.Contains(Scenarios(), opts.Scenario) {
+		return nil, fmt.Errorf("unknown scenario %q", opts.Scenario)
+	}
+	if opts.Runs < 1 || opts.Concurrency < 1 {
+		return nil, errors.New("runs and concurrency must be at least 1")
+	}
+	if opts.Client == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.MaxIdleConnsPerHost = opts.Concurrency
+		opts.Client = &http.Client{Transport: transport}
+	}
+
+	runs := make(chan struct{})
+	var mu sync.Mutex
+	var samples []result
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for range min(opts.Concurrency, opts.Runs) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range runs {
+				results := runScenario(ctx, opts)
+				mu.Lock()
+				samples = append(samples, results...)
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := 0; i < opts.Runs && ctx.Err() == nil; i++ {
+		select {
+		case runs <- struct{}{}:
+		case <-ctx.Done():
+		}
+	}
+	close(runs)
+	wg.Wait()
+
+	return summarize(opts.URL, samples, time.Since(start)), nil
+}
+
+// runScenario runs a scenario once and returns the samples of its
+// requests.
+func runScenario(ctx context.Context, opts Options) []result {
+	prompt := opts.Prompt
+	if prompt == "" {
+		prompt = chatPrompt
+		if opts.Scenario == ScenarioTools {
+			prompt = toolsPrompt
+		}
+	}
+	messages := []any{map[string]any{"role": "user", "content": prompt}}
+
+	sample, message := sendRequest(ctx, opts, messages)
+	if opts.Scenario != ScenarioTools || sample.err != nil || !sample.toolCalls {
+		return []result{sample}
+	}
+
+	// The reasoning of the first turn is left out, for the adapter to
+	// restore.
+	assistant := map[string]any{"role": "assistant", "tool_calls": message["tool_calls"]}
+	if content, ok := message["content"].(string); ok && content != "" {
+		assistant["content"] = content
+	}
+	messages = append(messages, assistant)
+	for _, c := range message["tool_calls"].([]any) {
+		call, _ := c.(map[string]any)
+		id, _ := call["id"].(string)
+		messages = append(messages, map[string]any{"role": "tool", "tool_call_id": id, "content": toolResult})
+	}
+
+	followUp, _ := sendRequest(ctx, opts, messages)
+	return []result{sample, followUp}
+}
+
+// sendRequest sends one chat completions request and measures it. It
+// returns the assistant message of the response, for the next turn.
+func sendRequest(ctx context.Context, opts Options, messages []any) (result, map[string]any) {
+	body := map[string]any{"messages": messages}
+	if opts.Model != "" {
+		body["model"] = opts.Model
+	}
+	if opts.MaxTokens > 0 {
+		body["max_tokens"] = opts.MaxTokens
+	}
+	if opts.Scenario == ScenarioTools {
+		body["tools"] = tools
+	}
+	if opts.Stream {
+		body["stream"] = true
+		body["stream_options"] = map[string]any{"include_usage": true}
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return result{err: err}, nil
+	}
+
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	url := strings.TrimSuffix(opts.URL, "/") + "/v1/chat/completions"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return result{err: err}, nil
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if opts.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+opts.APIKey)
+	}
+
+	start := time.Now()
+	resp, err := opts.Client.Do(req)
+	if err != nil {
+		return result{err: err}, nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return result{err: fmt.Errorf("status %d: %s", resp.StatusCode, bytes.TrimSpace(message))}, nil
+	}
+
+	var sample result
+	var message, usage map[string]any
+	if opts.Stream {
+		message, usage, sample = readStream(resp.Body, start)
+	} else {
+		var completion map[string]any
+		err = json.NewDecoder(resp.Body).Decode(&completion)
+		sample.latency = time.Since(start)
+		sample.err = err
+		message = firstChoice(completion, "message")
+		usage, _ = completion["usage"].(map[string]any)
+	}
+	if sample.err != nil {
+		return sample, nil
+	}
+
+	if calls, _ := message["tool_calls"].([]any); len(calls) > 0 {
+		sample.toolCalls = true
+	}
+	if tokens, ok := usage["completion_tokens"].(float64); ok {
+		sample.completionTokens = int(tokens)
+	}
+	return sample, message
+}
+
+// readStream reads a streamed chat completion and returns the message of
+// its first choice and its usage. When the stream reports no usage, each
+// chunk with a delta counts as a token.
+func readStream(body io.Reader, start time.Time) (map[string]any, map[string]any, result) {
+	var sample result
+	var usage map[string]any
+	message := make(map[string]any)
+	toolCalls := make(map[int]map[string]any)
+	deltas := 0
+
+	err := readEvents(body, func(data string) error {
+		if data == "[DONE]" {
+			return nil
+		}
+		var chunk map[string]any
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil
+		}
+		if errorData, ok := chunk["error"]; ok {
+			return fmt.Errorf("stream error: %v", errorData)
+		}
+		if u, ok := chunk["usage"].(map[string]any); ok {
+			usage = u
+		}
+
+		if chunkHasDelta(chunk) {
+			deltas++
+			if sample.ttft == 0 {
+				sample.ttft = time.Since(start)
+			}
+		}
+		mergeDelta(message, toolCalls, firstChoice(chunk, "delta"))
+		return nil
+	})
+	if err != nil {
+		sample.err = err
+		return nil, nil, sample
+	}
+	sample.latency = time.Since(start)
+
+	if len(toolCalls) > 0 {
+		var calls []any
+		for _, index := range slices.Sorted(maps.Keys(toolCalls)) {
+			calls = append(calls, toolCalls[index])
+		}
+		message["tool_calls"] = calls
+	}
+	if usage == nil {
+		usage = map[string]any{"completion_tokens": float64(deltas)}
+	}
+	return message, usage, sample
+}
+
+// readEvents calls fn with the data of each server-sent event in body.
+func readEvents(body io.Reader, fn func(data string) error) error {
+	reader := bufio.NewReader(body)
+	var data []string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		eof := err != nil
+		line = strings.TrimRight(line, "\r\n")
+
+		if line == "" {
+			if len(data) > 0 {
+				if err := fn(strings.Join(data, "\n")); err != nil {
+					return err
+				}
+				data = data[:0]
+			}
+		} else if value, ok := strings.CutPrefix(line, "data:"); ok {
+			data = append(data, strings.TrimPrefix(value, " "))
+		}
+
+		if eof {
+			if len(data) > 0 {
+				return fn(strings.Join(data, "\n"))
+			}
+			return nil
+		}
+	}
+}
+
+// firstChoice returns the field, "message" or "delta", of the first choice
+// of a completion or chunk.
+func firstChoice(data map[string]any, field string) map[string]any {
+	choices, _ := data["choices"].([]any)
+	for _, c := range choices {
+		choice, _ := c.(map[string]any)
+		if index, _ := choice["index"].(float64); index == 0 {
+			value, _ := choice[field].(map[string]any)
+			return value
+		}
+	}
+	return nil
+}
+
+// mergeDelta adds a streamed delta to the message built so far. Strings,
+// such as the content and the reasoning, are appended, and tool calls are
+// merged by index into toolCalls.
+func mergeDelta(message map[string]any, toolCalls map[int]map[string]any, delta map[string]any) {
+	for key, value := range delta {
+		switch v := value.(type) {
+		case string:
+			if key == "role" {
+				message[key] = v
+				continue
+			}
+			existing, _ := message[key].(string)
+			message[key] = existing + v
+		case []any:
+			if key != "tool_calls" {
+				continue
+			}
+			for i, d := range v {
+				callDelta, ok := d.(map[string]any)
+				if !ok {
+					continue
+				}
+				index := i
+				if n, ok := callDelta["index"].(float64); ok {
+					index = int(n)
+				}
+				call, ok := toolCalls[index]
+				if !ok {
+					call = map[string]any{"type": "function", "function": map[string]any{"name": "", "arguments": ""}}
+					toolCalls[index] = call
+				}
+				if id, ok := callDelta["id"].(string); ok && id != "" {
+					call["id"] = id
+				}
+				function, _ := callDelta["function"].(map[string]any)
+				merged := call["function"].(map[string]any)
+				for _, field := range []string{"name", "arguments"} {
+					if value, ok := function[field].(string); ok {
+						merged[field] = merged[field].(string) + value
+					}
+				}
+			}
+		}
+	}
+}
+
+// chunkHasDelta reports whether a chunk carries generated content,
+// reasoning or tool calls, whatever the reasoning field is called.
+func chunkHasDelta(chunk map[string]any) bool {
+	choices, _ := chunk["choices"].([]any)
+	for _, c := range choices {
+		choice, _ := c.(map[string]any)
+		delta, _ := choice["delta"].(map[string]any)
+		for key, value := range delta {
+			switch v := value.(type) {
+			case string:
+				if key != "role" && v != "" {
+					return true
+				}
+			case []any:
+				if len(v) > 0 {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func summarize(url string, samples []result, elapsed time.Duration) *Report {
+	report := &Report{URL: url, Duration: elapsed, Requests: len(samples)}
+
+	var latencies, ttfts []time.Duration
+	var rateSum float64
+	rates := 0
+	for _, s := range samples {
+		if s.err != nil {
+			if report.Errors == 0 {
+				report.FirstError = s.err.Error()
+			}
+			report.Errors++
+			continue
+		}
+		latencies = append(latencies, s.latency)
+		if s.ttft > 0 {
+			ttfts = append(ttfts, s.ttft)
+		}
+		if s.toolCalls {
+			report.ToolCalls++
+		}
+		report.CompletionTokens += s.completionTokens
+
+		generation := s.latency - s.ttft
+		if s.completionTokens > 0 && generation > 0 {
+			rateSum += float64(s.completionTokens) / generation.Seconds()
+			rates++
+		}
+	}
+
+	report.Latency = percentiles(latencies)
+	report.TimeToFirstToken = percentiles(ttfts)
+	if rates > 0 {
+		report.TokensPerSecond = rateSum / float64(rates)
+	}
+	if elapsed > 0 {
+		report.Throughput = float64(report.CompletionTokens) / elapsed.Seconds()
+	}
+	return report
+}
+
+// percentiles computes nearest-rank percentiles.
+func percentiles(durations []time.Duration) Percentiles {
+	if len(durations) == 0 {
+		return Percentiles{}
+	}
+	slices.Sort(durations)
+	rank := func(p float64) time.Duration {
+		i := int(math.Ceil(p*float64(len(durations)))) - 1
+		return durations[max(0, min(i, len(durations)-1))]
+	}
+	return Percentiles{
+		P50: rank(0.50),
+		P90: rank(0.90),
+		P99: rank(0.99),
+		Max: durations[len(durations)-1],
+	}
+}
+
+func (p Percentiles) String() string {
+	round := func(d time.Duration) time.Duration { return d.Round(time.Millisecond) }
+	return fmt.Sprintf("p50 %v  p90 %v  p99 %v  max %v", round(p.P50), round(p.P90), round(p.P99), round(p.Max))
+}
+
+// Write prints the report for people to read.
+func (r *Report) Write(w io.Writer) {
+	fmt.Fprintf(w, "%s\n", r.URL)
+	fmt.Fprintf(w, "  Requests:    %d (%d errors) in %v, %.1f req/s\n",
+		r.Requests, r.Errors, r.Duration.Round(time.Millisecond), float64(r.Requests)/max(r.Duration.Seconds(), 1e-9))
+	if r.FirstError != "" {
+		fmt.Fprintf(w, "  First error: %s\n", r.FirstError)
+	}
+	fmt.Fprintf(w, "  Latency:     %v\n", r.Latency)
+	if r.TimeToFirstToken.Max > 0 {
+		fmt.Fprintf(w, "  TTFT:        %v\n", r.TimeToFirstToken)
+	}
+	fmt.Fprintf(w, "  Tokens:      %d, %.1f/s per request, %.1f/s total\n", r.CompletionTokens, r.TokensPerSecond, r.Throughput)
+	if r.ToolCalls > 0 {
+		fmt.Fprintf(w, "  Tool calls:  %d\n", r.ToolCalls)
+	}
+}
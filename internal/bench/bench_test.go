@@ -0,0 +1,104 @@
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/pkg/adapter"
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+)
+
+func TestRun_Tools(t *testing.T) {
+	var calls, restored atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		messages := request["messages"].([]any)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		if len(messages) == 1 {
+			id := fmt.Sprintf("call_%d", calls.Add(1))
+			io.WriteString(w, `data: {"object":"chat.completion.chunk","choices":[{"index":0,"delta":{"reasoning_content":"Look it up."}}]}`+"\n\n")
+			io.WriteString(w, `data: {"object":"chat.completion.chunk","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"`+id+`","type":"function","function":{"name":"get_weather","arguments":"{\"location\":\"Paris\"}"}}]},"finish_reason":"tool_calls"}]}`+"\n\n")
+		} else {
+			if messages[1].(map[string]any)["reasoning_content"] == "Look it up." {
+				restored.Add(1)
+			}
+			io.WriteString(w, `data: {"object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"It is "}}]}`+"\n\n")
+			io.WriteString(w, `data: {"object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"sunny."},"finish_reason":"stop"}]}`+"\n\n")
+		}
+		io.WriteString(w, `data: {"object":"chat.completion.chunk","choices":[],"usage":{"completion_tokens":4}}`+"\n\n")
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	defer backend.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	server := httptest.NewServer(adapter.NewAdapter(backend.URL, adapter.NewLRUCache(100), logger, llamacpp.NewProvider()))
+	defer server.Close()
+
+	report, err := Run(context.Background(), Options{
+		URL:         server.URL,
+		Model:       "gpt-oss-20b",
+		Runs:        6,
+		Concurrency: 3,
+		Scenario:    ScenarioTools,
+		Stream:      true,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 12, report.Requests)
+	assert.Zero(t, report.Errors, report.FirstError)
+	assert.Equal(t, 6, report.ToolCalls)
+	assert.Equal(t, int32(6), restored.Load())
+	assert.Equal(t, 48, report.CompletionTokens)
+	assert.Positive(t, report.Latency.P50)
+	assert.Positive(t, report.TimeToFirstToken.Max)
+	assert.LessOrEqual(t, report.TimeToFirstToken.P50, report.Latency.Max)
+
+	var out strings.Builder
+	report.Write(&out)
+	assert.Contains(t, out.String(), "Requests:    12 (0 errors)")
+	assert.Contains(t, out.String(), "Tool calls:  6")
+}
+
+func TestRun_Errors(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"message":"overloaded"}}`, http.StatusServiceUnavailable)
+	}))
+	defer backend.Close()
+
+	report, err := Run(context.Background(), Options{URL: backend.URL, Runs: 3, Concurrency: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Requests)
+	assert.Equal(t, 3, report.Errors)
+	assert.Contains(t, report.FirstError, "status 503")
+
+	_, err = Run(context.Background(), Options{URL: backend.URL, Runs: 1, Concurrency: 1, Scenario: "soak"})
+	assert.Error(t, err)
+}
+
+func TestPercentiles(t *testing.T) {
+	var durations []time.Duration
+	for i := 100; i >= 1; i-- {
+		durations = append(durations, time.Duration(i)*time.Millisecond)
+	}
+
+	p := percentiles(durations)
+	assert.Equal(t, 50*time.Millisecond, p.P50)
+	assert.Equal(t, 90*time.Millisecond, p.P90)
+	assert.Equal(t, 99*time.Millisecond, p.P99)
+	assert.Equal(t, 100*time.Millisecond, p.Max)
+	assert.Equal(t, Percentiles{}, percentiles(nil))
+}
@@ -0,0 +1,505 @@
+// Package mockbackend is a fake OpenAI-compatible backend for the
+// mock-backend command and for tests.
+package mockbackend
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	mathrand "math/rand/v2"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Reasoning formats of the backend besides a field name.
+const (
+	ReasoningNone      = "none"
+	ReasoningThinkTags = "think-tags"
+)
+
+// When the backend answers with a tool call.
+const (
+	// ToolCallsAuto calls the first tool offered, unless the last
+	// message is a tool result, which is answered with content.
+	ToolCallsAuto   = "auto"
+	ToolCallsAlways = "always"
+	ToolCallsNever  = "never"
+)
+
+// Faults the backend can inject into its responses.
+const (
+	// FaultServerError answers with a 500 and an OpenAI-style error.
+	FaultServerError = "server-error"
+	// FaultInvalidJSON sends an event, or a body, that is not JSON.
+	FaultInvalidJSON = "invalid-json"
+	// FaultSplitLine breaks a data line of the stream in two.
+	FaultSplitLine = "split-line"
+	// FaultErrorEvent sends an error object in the middle of the stream.
+	FaultErrorEvent = "error-event"
+	// FaultNoDone ends the stream without [DONE].
+	FaultNoDone = "no-done"
+	// FaultTruncate drops the connection halfway through the response.
+	FaultTruncate = "truncate"
+)
+
+// faultHeader selects the faults of a single request, overriding the
+// configured ones. The adapter forwards it like any other header.
+const faultHeader = "X-Mock-Fault"
+
+// Faults lists the faults the backend can inject.
+func Faults() []string {
+	return []string{FaultServerError, FaultInvalidJSON, FaultSplitLine, FaultErrorEvent, FaultNoDone, FaultTruncate}
+}
+
+// Options configures New.
+type Options struct {
+	// ReasoningField is the message and delta field the reasoning is sent
+	// in, such as reasoning_content or reasoning, or ReasoningNone or
+	// ReasoningThinkTags.
+	ReasoningField string
+	Reasoning      string
+	Content        string
+
+	// ToolCalls is ToolCallsAuto, ToolCallsAlways or
+	// ToolCallsNever.
+	ToolCalls string
+
+	// ChunkSize is the number of characters of each streamed delta.
+	ChunkSize int
+
+	// FirstTokenDelay is waited before the first delta of a stream, or
+	// before a blocking response, and ChunkDelay between deltas.
+	FirstTokenDelay time.Duration
+	ChunkDelay      time.Duration
+
+	// Faults are injected into a FaultRate fraction of the responses.
+	Faults    []string
+	FaultRate float64
+
+	// Models are listed at /v1/models.
+	Models []string
+}
+
+// Backend is a fake OpenAI-compatible backend for integration tests of
+// the adapter and its clients, without a GPU. It answers chat completions
+// with fixed reasoning, content and tool calls, streamed or not.
+type Backend struct {
+	opts     Options
+	mux      *http.ServeMux
+	requests atomic.Uint64
+}
+
+// New validates opts and returns the backend.
+func New(opts Options) (*Backend, error) {
+	if opts.ReasoningField == "" {
+		opts.ReasoningField = "reasoning_content"
+	}
+	switch opts.ToolCalls {
+	case "":
+		opts.ToolCalls = ToolCallsAuto
+	case ToolCallsAuto, ToolCallsAlways, ToolCallsNever:
+	default:
+		return nil, fmt.Errorf("unknown tool call mode %q", opts.ToolCalls)
+	}
+	if opts.ChunkSize < 1 {
+		opts.ChunkSize = 8
+	}
+	for _, fault := range opts.Faults {
+		if !slices.Contains(Faults(), fault) {
+			return nil, fmt.Errorf("unknown fault %q", fault)
+		}
+	}
+	if opts.FaultRate < 0 || opts.FaultRate > 1 {
+		return nil, fmt.Errorf("fault rate must be between 0 and 1")
+	}
+	if len(opts.Models) == 0 {
+		opts.Models = []string{"gpt-oss-20b", "gpt-oss-120b"}
+	}
+
+	m := &Backend{opts: opts, mux: http.NewServeMux()}
+	m.mux.HandleFunc("/v1/chat/completions", m.handleChatCompletions)
+	m.mux.HandleFunc("/chat/completions", m.handleChatCompletions)
+	m.mux.HandleFunc("/v1/models", m.handleModels)
+	m.mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"status": "ok"})
+	})
+	return m, nil
+}
+
+func (m *Backend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mux.ServeHTTP(w, r)
+}
+
+func (m *Backend) handleModels(w http.ResponseWriter, r *http.Request) {
+	models := make([]any, 0, len(m.opts.Models))
+	for _, model := range m.opts.Models {
+		models = append(models, map[string]any{"id": model, "object": "model", "owned_by": "mock"})
+	}
+	writeJSON(w, map[string]any{"object": "list", "data": models})
+}
+
+// faults returns the faults to inject into the response to r.
+func (m *Backend) faults(r *http.Request) ([]string, error) {
+	if header := r.Header.Get(faultHeader); header != "" {
+		var faults []string
+		for _, fault := range strings.Split(header, ",") {
+			fault = strings.TrimSpace(fault)
+			if !slices.Contains(Faults(), fault) {
+				return nil, fmt.Errorf("unknown fault %q", fault)
+			}
+			faults = append(faults, fault)
+		}
+		return faults, nil
+	}
+	if len(m.opts.Faults) > 0 && mathrand.Float64() < m.opts.FaultRate {
+		return m.opts.Faults, nil
+	}
+	return nil, nil
+}
+
+// reply is what the backend answers a request with.
+type reply struct {
+	reasoning string
+	content   string
+	toolCall  map[string]any
+}
+
+func (m *Backend) turn(requestData map[string]any) reply {
+	turn := reply{reasoning: m.opts.Reasoning, content: m.opts.Content}
+
+	messages, _ := requestData["messages"].([]any)
+	var last map[string]any
+	if len(messages) > 0 {
+		last, _ = messages[len(messages)-1].(map[string]any)
+	}
+
+	tools, _ := requestData["tools"].([]any)
+	answered := last["role"] == "tool"
+	if len(tools) == 0 || m.opts.ToolCalls == ToolCallsNever || (answered && m.opts.ToolCalls == ToolCallsAuto) {
+		if answered {
+			result, _ := last["content"].(string)
+			turn.content = "The tool returned: " + result
+		}
+		return turn
+	}
+
+	tool, _ := tools[0].(map[string]any)
+	function, _ := tool["function"].(map[string]any)
+	name, _ := function["name"].(string)
+	parameters, _ := function["parameters"].(map[string]any)
+	arguments, _ := json.Marshal(placeholderArguments(parameters))
+
+	id := make([]byte, 8)
+	rand.Read(id)
+	turn.content = ""
+	turn.toolCall = map[string]any{
+		"id":   "call_" + hex.EncodeToString(id),
+		"type": "function",
+		"function": map[string]any{
+			"name":      name,
+			"arguments": string(arguments),
+		},
+	}
+	return turn
+}
+
+// placeholderArguments fills in the required properties of a tool's parameters
+// with placeholder values of their types.
+func placeholderArguments(parameters map[string]any) map[string]any {
+	arguments := make(map[string]any)
+	properties, _ := parameters["properties"].(map[string]any)
+	required, _ := parameters["required"].([]any)
+	for _, r := range required {
+		name, _ := r.(string)
+		property, _ := properties[name].(map[string]any)
+		switch property["type"] {
+		case "number", "integer":
+			arguments[name] = 1
+		case "boolean":
+			arguments[name] = true
+		case "array":
+			arguments[name] = []any{}
+		case "object":
+			arguments[name] = map[string]any{}
+		default:
+			arguments[name] = "example"
+		}
+	}
+	return arguments
+}
+
+func (m *Backend) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed", "invalid_request_error")
+		return
+	}
+
+	var requestData map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		writeError(w, http.StatusBadRequest, "Request body is not valid JSON", "invalid_request_error")
+		return
+	}
+
+	faults, err := m.faults(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), "invalid_request_error")
+		return
+	}
+	if slices.Contains(faults, FaultServerError) {
+		writeError(w, http.StatusInternalServerError, "The mock backend failed as asked", "server_error")
+		return
+	}
+
+	model, _ := requestData["model"].(string)
+	if model == "" {
+		model = m.opts.Models[0]
+	}
+	response := pending{
+		id:      fmt.Sprintf("chatcmpl-mock-%d", m.requests.Add(1)),
+		created: time.Now().Unix(),
+		model:   model,
+		turn:    m.turn(requestData),
+		faults:  faults,
+		prompt:  promptTokens(requestData),
+	}
+
+	if stream, _ := requestData["stream"].(bool); stream {
+		options, _ := requestData["stream_options"].(map[string]any)
+		includeUsage, _ := options["include_usage"].(bool)
+		m.stream(w, r, &response, includeUsage)
+		return
+	}
+
+	time.Sleep(m.opts.FirstTokenDelay)
+	body, err := json.Marshal(m.completion(&response))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to marshal response", "server_error")
+		return
+	}
+	switch {
+	case slices.Contains(faults, FaultInvalidJSON):
+		body = body[:len(body)/2]
+	case slices.Contains(faults, FaultTruncate):
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+		w.Write(body[:len(body)/2])
+		panic(http.ErrAbortHandler)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// pending is a response being written by the backend.
+type pending struct {
+	id      string
+	created int64
+	model   string
+	turn    reply
+	faults  []string
+	prompt  int
+}
+
+func (resp *pending) has(fault string) bool {
+	return slices.Contains(resp.faults, fault)
+}
+
+// split returns the reasoning field of the turn's message, with its value,
+// and the content, which holds the reasoning with think tags.
+func (m *Backend) split(turn reply) (field, reasoning, content string) {
+	content = turn.content
+	if turn.reasoning == "" {
+		return "", "", content
+	}
+	switch m.opts.ReasoningField {
+	case ReasoningNone:
+		return "", "", content
+	case ReasoningThinkTags:
+		return "", "", "<think>" + turn.reasoning + "</think>" + content
+	}
+	return m.opts.ReasoningField, turn.reasoning, content
+}
+
+func (m *Backend) completion(resp *pending) map[string]any {
+	message := map[string]any{"role": "assistant"}
+	field, reasoning, content := m.split(resp.turn)
+	if field != "" {
+		message[field] = reasoning
+	}
+	if content != "" || resp.turn.toolCall == nil {
+		message["content"] = content
+	}
+	finishReason := "stop"
+	if resp.turn.toolCall != nil {
+		message["tool_calls"] = []any{resp.turn.toolCall}
+		finishReason = "tool_calls"
+	}
+	return map[string]any{
+		"id":      resp.id,
+		"object":  "chat.completion",
+		"created": resp.created,
+		"model":   resp.model,
+		"choices": []any{map[string]any{"index": 0, "message": message, "finish_reason": finishReason}},
+		"usage":   m.usage(resp),
+	}
+}
+
+func (m *Backend) usage(resp *pending) map[string]any {
+	completion := countTokens(resp.turn.reasoning) + countTokens(resp.turn.content)
+	if resp.turn.toolCall != nil {
+		arguments, _ := resp.turn.toolCall["function"].(map[string]any)["arguments"].(string)
+		completion += countTokens(arguments) + 1
+	}
+	return map[string]any{
+		"prompt_tokens":     resp.prompt,
+		"completion_tokens": completion,
+		"total_tokens":      resp.prompt + completion,
+	}
+}
+
+// stream writes the response as chat completion chunks of ChunkSize
+// characters, injecting the response's faults.
+func (m *Backend) stream(w http.ResponseWriter, r *http.Request, resp *pending, includeUsage bool) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+
+	var deltas []map[string]any
+	field, reasoning, content := m.split(resp.turn)
+	for _, piece := range chunks(reasoning, m.opts.ChunkSize) {
+		deltas = append(deltas, map[string]any{field: piece})
+	}
+	for _, piece := range chunks(content, m.opts.ChunkSize) {
+		deltas = append(deltas, map[string]any{"content": piece})
+	}
+	finishReason := "stop"
+	if call := resp.turn.toolCall; call != nil {
+		function := call["function"].(map[string]any)
+		deltas = append(deltas, map[string]any{"tool_calls": []any{map[string]any{
+			"index": 0, "id": call["id"], "type": "function",
+			"function": map[string]any{"name": function["name"], "arguments": ""},
+		}}})
+		for _, piece := range chunks(function["arguments"].(string), m.opts.ChunkSize) {
+			deltas = append(deltas, map[string]any{"tool_calls": []any{map[string]any{
+				"index": 0, "function": map[string]any{"arguments": piece},
+			}}})
+		}
+		finishReason = "tool_calls"
+	}
+	if len(deltas) == 0 {
+		deltas = append(deltas, map[string]any{"content": ""})
+	}
+	deltas[0]["role"] = "assistant"
+
+	chunk := func(delta map[string]any, finishReason any) map[string]any {
+		return map[string]any{
+			"id":      resp.id,
+			"object":  "chat.completion.chunk",
+			"created": resp.created,
+			"model":   resp.model,
+			"choices": []any{map[string]any{"index": 0, "delta": delta, "finish_reason": finishReason}},
+		}
+	}
+	send := func(data string) bool {
+		if r.Context().Err() != nil {
+			return false
+		}
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return true
+	}
+	sendJSON := func(value any) bool {
+		data, _ := json.Marshal(value)
+		return send(string(data))
+	}
+
+	middle := len(deltas) / 2
+	time.Sleep(m.opts.FirstTokenDelay)
+	for i, delta := range deltas {
+		if i > 0 {
+			time.Sleep(m.opts.ChunkDelay)
+		}
+		if i == middle {
+			switch {
+			case resp.has(FaultTruncate):
+				panic(http.ErrAbortHandler)
+			case resp.has(FaultErrorEvent):
+				sendJSON(map[string]any{"error": map[string]any{"message": "The mock backend failed as asked", "type": "server_error"}})
+				return
+			case resp.has(FaultInvalidJSON):
+				send(`{"choices":[{"index":0,"delta":{"content":`)
+			}
+		}
+
+		if i == middle && resp.has(FaultSplitLine) {
+			data, _ := json.Marshal(chunk(delta, nil))
+			half := len(data) / 2
+			fmt.Fprintf(w, "data: %s\n%s\n\n", data[:half], data[half:])
+			continue
+		}
+		if !sendJSON(chunk(delta, nil)) {
+			return
+		}
+	}
+
+	sendJSON(chunk(map[string]any{}, finishReason))
+	if includeUsage {
+		final := chunk(nil, nil)
+		final["choices"] = []any{}
+		final["usage"] = m.usage(resp)
+		sendJSON(final)
+	}
+	if !resp.has(FaultNoDone) {
+		send("[DONE]")
+	}
+}
+
+// chunks splits text into pieces of size characters.
+func chunks(text string, size int) []string {
+	runes := []rune(text)
+	var pieces []string
+	for len(runes) > 0 {
+		n := min(size, len(runes))
+		pieces = append(pieces, string(runes[:n]))
+		runes = runes[n:]
+	}
+	return pieces
+}
+
+// countTokens estimates the tokens of text as one per word.
+func countTokens(text string) int {
+	return len(strings.Fields(text))
+}
+
+func promptTokens(requestData map[string]any) int {
+	tokens := 0
+	messages, _ := requestData["messages"].([]any)
+	for _, m := range messages {
+		message, _ := m.(map[string]any)
+		if content, ok := message["content"].(string); ok {
+			tokens += countTokens(content)
+		}
+	}
+	return tokens
+}
+
+func writeJSON(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeError writes an error body in the format of the OpenAI API.
+func writeError(w http.ResponseWriter, status int, message string, errType string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{"message": message, "type": errType},
+	})
+}
@@ -0,0 +1,154 @@
+package mockbackend
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/pkg/adapter"
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+)
+
+func postMock(t *testing.T, url, body string, header http.Header) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url+"/v1/chat/completions", strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestBackend_ThroughAdapter(t *testing.T) {
+	backend, err := New(Options{Reasoning: "Call the tool.", Content: "Done."})
+	require.NoError(t, err)
+	target := httptest.NewServer(backend)
+	defer target.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	server := httptest.NewServer(adapter.NewAdapter(target.URL, adapter.NewLRUCache(100), logger, llamacpp.NewProvider()))
+	defer server.Close()
+
+	tools := `"tools":[{"type":"function","function":{"name":"get_weather","parameters":{"type":"object","properties":{"location":{"type":"string"},"days":{"type":"integer"}},"required":["location","days"]}}}]`
+	resp := postMock(t, server.URL, `{"model":"gpt-oss-20b","stream":true,"messages":[{"role":"user","content":"Weather?"}],`+tools+`}`, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	events := strings.Split(strings.TrimSuffix(string(body), "\n\n"), "\n\n")
+	require.Equal(t, "data: [DONE]", events[len(events)-1])
+
+	var reasoning strings.Builder
+	var call map[string]any
+	var arguments strings.Builder
+	for _, event := range events[:len(events)-1] {
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Reasoning string           `json:"reasoning"`
+					ToolCalls []map[string]any `json:"tool_calls"`
+				} `json:"delta"`
+			} `json:"choices"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(event, "data: ")), &chunk))
+		for _, choice := range chunk.Choices {
+			reasoning.WriteString(choice.Delta.Reasoning)
+			for _, delta := range choice.Delta.ToolCalls {
+				if call == nil {
+					call = delta
+				}
+				arguments.WriteString(delta["function"].(map[string]any)["arguments"].(string))
+			}
+		}
+	}
+	assert.Equal(t, "Call the tool.", reasoning.String())
+	require.NotNil(t, call)
+	assert.Equal(t, "get_weather", call["function"].(map[string]any)["name"])
+	assert.JSONEq(t, `{"location":"example","days":1}`, arguments.String())
+
+	followUp := `{"model":"gpt-oss-20b","messages":[{"role":"user","content":"Weather?"},` +
+		`{"role":"assistant","tool_calls":[{"id":"` + call["id"].(string) + `","type":"function","function":{"name":"get_weather","arguments":"{}"}}]},` +
+		`{"role":"tool","tool_call_id":"` + call["id"].(string) + `","content":"Sunny"}],` + tools + `}`
+	resp = postMock(t, server.URL, followUp, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var completion map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&completion))
+	message := completion["choices"].([]any)[0].(map[string]any)["message"].(map[string]any)
+	assert.Equal(t, "The tool returned: Sunny", message["content"])
+	assert.Nil(t, message["tool_calls"])
+	assert.NotZero(t, completion["usage"].(map[string]any)["prompt_tokens"])
+}
+
+func TestBackend_ThinkTags(t *testing.T) {
+	backend, err := New(Options{ReasoningField: ReasoningThinkTags, Reasoning: "Hmm.", Content: "Hi."})
+	require.NoError(t, err)
+	server := httptest.NewServer(backend)
+	defer server.Close()
+
+	resp := postMock(t, server.URL, `{"messages":[{"role":"user","content":"Hello"}]}`, nil)
+	var completion map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&completion))
+	message := completion["choices"].([]any)[0].(map[string]any)["message"].(map[string]any)
+	assert.Equal(t, "<think>Hmm.</think>Hi.", message["content"])
+	assert.Equal(t, "gpt-oss-20b", completion["model"])
+}
+
+func TestBackend_Faults(t *testing.T) {
+	backend, err := New(Options{Reasoning: "Thinking it over.", Content: "The answer is four.", ChunkSize: 4})
+	require.NoError(t, err)
+	server := httptest.NewServer(backend)
+	defer server.Close()
+
+	stream := `{"stream":true,"messages":[{"role":"user","content":"2+2?"}]}`
+	read := func(fault string) string {
+		resp := postMock(t, server.URL, stream, http.Header{"X-Mock-Fault": {fault}})
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	resp := postMock(t, server.URL, stream, http.Header{"X-Mock-Fault": {"server-error"}})
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+
+	resp = postMock(t, server.URL, stream, http.Header{"X-Mock-Fault": {"gremlins"}})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	assert.True(t, strings.HasSuffix(read(""), "data: [DONE]\n\n"))
+	assert.NotContains(t, read("no-done"), "[DONE]")
+	assert.Contains(t, read("error-event"), `"error":{"message":"The mock backend failed as asked"`)
+	assert.Contains(t, read("invalid-json"), `data: {"choices":[{"index":0,"delta":{"content":`+"\n\n")
+
+	split := read("split-line, no-done")
+	assert.NotContains(t, split, "[DONE]")
+	var broken int
+	for _, line := range strings.Split(split, "\n") {
+		if line != "" && !strings.HasPrefix(line, "data: ") {
+			broken++
+		}
+	}
+	assert.Equal(t, 1, broken)
+
+	resp = postMock(t, server.URL, stream, http.Header{"X-Mock-Fault": {"truncate"}})
+	_, err = io.ReadAll(resp.Body)
+	assert.Error(t, err)
+}
+
+func TestNew_Invalid(t *testing.T) {
+	_, err := New(Options{ToolCalls: "sometimes"})
+	assert.Error(t, err)
+	_, err = New(Options{Faults: []string{"gremlins"}})
+	assert.Error(t, err)
+	_, err = New(Options{FaultRate: 2})
+	assert.Error(t, err)
+}
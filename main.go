@@ -3,27 +3,203 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 
-	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
-	"github.com/aldehir/gpt-oss-adapter/providers/lmstudio"
+	"github.com/aldehir/gpt-oss-adapter/pkg/adapter"
+	"github.com/aldehir/gpt-oss-adapter/providers"
 	"github.com/aldehir/gpt-oss-adapter/providers/types"
 )
 
 var version = "dev"
 
 var (
-	listen   string
-	target   string
-	verbose  bool
-	provider string
+	configFile string
+	listen     string
+	reusePort  bool
+	target     string
+	verbose    bool
+	provider   string
+	cacheSize  int
+
+	cacheMaxBytes      int64
+	cacheStatsInterval time.Duration
+
+	logFormat      string
+	logRouteLevels map[string]string
+	logSampleRate  int
+
+	accessLogFile           string
+	accessLogMaxSize        int64
+	accessLogRotateInterval time.Duration
+	accessLogMaxBackups     int
+
+	cacheBackend      string
+	cacheTTL          time.Duration
+	cacheFile         string
+	cacheSaveInterval time.Duration
+	redisURL          string
+	sqlitePath        string
+	memcachedServers  []string
+	boltPath          string
+	cacheFallback     bool
+	cacheKey          string
+	cacheKeyFile      string
+
+	cacheNamespace       string
+	cacheNamespaceHeader string
+	cacheMode            string
+
+	providersFile string
+
+	reasoningField       string
+	reasoningEffortField string
+
+	modelConcurrency map[string]int
+	modelRate        map[string]int
+
+	moderationURL           string
+	moderationMode          string
+	moderationFailOpen      bool
+	moderationCheckResponse bool
+	moderationTimeout       time.Duration
+
+	reasoningSummary        bool
+	reasoningSummaryURL     string
+	reasoningSummaryModel   string
+	reasoningSummaryTimeout time.Duration
+
+	statelessKeyFile string
+
+	conversationBudget     int
+	conversationBudgetMode string
+
+	syntheticUsage  bool
+	tokenizeURL     string
+	usageAccounting bool
+
+	effortRules    []string
+	effortOverride bool
+	defaultEffort  string
+
+	effortDowngradeQueue   []int
+	effortDowngradeLatency []time.Duration
+
+	transformers    []string
+	transformScript string
+
+	streamFormat      string
+	streamMaxLineSize int
+	streamKeepAlive   time.Duration
+
+	streamWriteTimeout time.Duration
+	streamBufferSize   int
+	streamOverflow     string
+	streamStats        bool
+
+	fuzzyMatch          bool
+	plainTurns          string
+	reasoningInjection  string
+	reasoningBudget     int
+	reasoningChains     bool
+	reasoningChainDepth int
+
+	reasoningFormat string
+
+	stripReasoning bool
+
+	scrubRules    []string
+	scrubPatterns []string
+	scrubKeywords []string
+
+	providerRouting bool
+	modelRoutes     []string
+
+	fallbackTarget   string
+	fallbackProvider string
+	fallbackModels   map[string]string
+
+	replicas []string
+
+	maxConcurrent int
+	queueDepth    int
+	queueTimeout  time.Duration
+
+	coalesce bool
+
+	responseCacheTTL      time.Duration
+	responseCacheSize     int
+	responseCacheMaxBytes int64
+
+	retryMax        int
+	retryBackoff    time.Duration
+	retryMaxBackoff time.Duration
+	retryStatuses   []int
+
+	circuitThreshold   float64
+	circuitMinRequests int
+	circuitWindow      time.Duration
+	circuitCooldown    time.Duration
+
+	recordDir string
+
+	adminToken string
+
+	debugTransform bool
+	debugRecent    int
+	debugListen    string
+
+	embeddingsBatchSize int
+	toolChoiceRetries   int
+	toolCallRetries     int
+	structuredOutputs   string
+
+	apiKeysFile string
+
+	rateLimit        int
+	rateLimitBurst   int
+	rateLimitStreams int
+	rateLimitKey     string
+
+	maxRequestSize int64
+
+	tlsCert string
+	tlsKey  string
+
+	shutdownTimeout time.Duration
+
+	upstreamCA                 string
+	upstreamClientCert         string
+	upstreamClientKey          string
+	upstreamInsecureSkipVerify bool
+
+	upstreamConnectTimeout        time.Duration
+	upstreamResponseHeaderTimeout time.Duration
+	upstreamTimeout               time.Duration
+
+	upstreamMaxIdleConns int
+	upstreamIdleTimeout  time.Duration
+	upstreamHTTP2        string
+	upstreamSocket       string
+
+	upstreamCompression bool
+	compressResponses   bool
+
+	upstreamHeaderStrip []string
+	upstreamHeaderSet   map[string]string
+
+	healthCheckInterval time.Duration
+	healthCheckPath     string
+	healthCheckTimeout  time.Duration
 )
 
 var rootCmd = &cobra.Command{
@@ -32,11 +208,26 @@ var rootCmd = &cobra.Command{
 	Long:    "gpt-oss adapter to inject reasoning from tool calls",
 	Version: version,
 	Run: func(cmd *cobra.Command, args []string) {
+		if err := LoadEnv(cmd.Flags()); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid environment variable: %v\n", err)
+			os.Exit(1)
+		}
+
+		config := &Config{}
+		if configFile != "" {
+			var err error
+			config, err = LoadConfig(configFile, cmd.Flags())
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: failed to load config: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
 		if target == "" {
 			fmt.Fprintf(os.Stderr, "Error: target argument is required\n")
 			os.Exit(1)
 		}
-		startServer()
+		startServer(config)
 	},
 }
 
@@ -47,11 +238,36 @@ func Execute() {
 	}
 }
 
-func startServer() {
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
-	defer stop()
+// encryptCache wraps cache so that reasoning is encrypted under the key from
+// --cache-key or --cache-key-file before it is stored.
+func encryptCache(cache adapter.Cache, logger *slog.Logger) (adapter.Cache, error) {
+	if cacheKey != "" && cacheKeyFile != "" {
+		return nil, fmt.Errorf("--cache-key and --cache-key-file cannot both be set")
+	}
+
+	var key []byte
+	var err error
+	if cacheKeyFile != "" {
+		key, err = adapter.LoadReasoningKey(cacheKeyFile)
+	} else {
+		key, err = adapter.ParseReasoningKey(cacheKey)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	cache := NewLRUCache(1000)
+	cipher, err := adapter.NewReasoningCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return adapter.NewEncryptedCache(cache, cipher, logger), nil
+}
+
+func startServer(config *Config) {
+	adapter.Version = version
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	var logLevel slog.Level
 	if verbose {
@@ -60,32 +276,204 @@ func startServer() {
 		logLevel = slog.LevelInfo
 	}
 
-	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-		Level: logLevel,
-	}))
-	providerConfig := getProviderConfig(provider)
-	adapter := NewAdapter(target, cache, logger, providerConfig)
+	routeLevels, err := adapter.ParseRouteLevels(logRouteLevels)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid --log-route-level: %v\n", err)
+		os.Exit(1)
+	}
+
+	logHandler, err := adapter.NewLogHandler(os.Stdout, adapter.LogOptions{
+		Format:      logFormat,
+		Level:       logLevel,
+		RouteLevels: routeLevels,
+		SampleRate:  logSampleRate,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	logger := slog.New(logHandler)
+
+	cache, err := adapter.OpenCache(ctx, adapter.CacheOptions{
+		Backend:          cacheBackend,
+		Size:             cacheSize,
+		MaxBytes:         cacheMaxBytes,
+		TTL:              cacheTTL,
+		RedisURL:         redisURL,
+		SQLitePath:       sqlitePath,
+		MemcachedServers: memcachedServers,
+		BoltPath:         boltPath,
+		Fallback:         cacheFallback,
+	}, logger)
+	if err != nil {
+		logger.Error("failed to open cache", "backend", cacheBackend, "error", err)
+		os.Exit(1)
+	}
+	if closer, ok := cache.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	// The cache file snapshots the in-memory cache, including one that
+	// replaced an unreachable backend.
+	lru, _ := cache.(*adapter.LRUCache)
+	if cacheFile != "" && lru != nil {
+		n, err := lru.LoadFile(cacheFile)
+		if err != nil {
+			logger.Error("failed to load cache", "path", cacheFile, "error", err)
+			os.Exit(1)
+		}
+		logger.Info("Loaded reasoning cache", "path", cacheFile, "entries", n)
+		if cacheSaveInterval > 0 {
+			go adapter.PersistCache(ctx, lru, cacheFile, cacheSaveInterval, logger)
+		}
+	}
+
+	if cacheKey != "" || cacheKeyFile != "" {
+		cache, err = encryptCache(cache, logger)
+		if err != nil {
+			logger.Error("invalid cache key", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	if cacheStatsInterval > 0 {
+		go adapter.LogCacheStats(ctx, cache, cacheStatsInterval, logger)
+	}
+
+	a, err := buildAdapter(config, cache, logger)
+	if err != nil {
+		logger.Error("invalid configuration", "error", err)
+		os.Exit(1)
+	}
+	reloadable := adapter.NewReloadable(a)
+
+	runHealth := func(health *adapter.HealthChecker) context.CancelFunc {
+		healthCtx, cancel := context.WithCancel(ctx)
+		go health.Run(healthCtx)
+		return cancel
+	}
+	stopHealth := func() {}
+	if a.Health != nil {
+		stopHealth = runHealth(a.Health)
+	}
+
+	var recorder *adapter.Recorder
+	if recordDir != "" {
+		recorder, err = adapter.NewRecorder(recordDir)
+		if err != nil {
+			logger.Error("failed to create record directory", "error", err)
+			os.Exit(1)
+		}
+		defer recorder.Close()
+		recorder.Scrubber = a.Scrubber
+		logger.Warn("recording requests and responses", "dir", recordDir)
+	}
 
-	// Wrap adapter with logging middleware
-	handler := NewLoggingMiddleware(adapter, logger)
+	shutdownTracing, err := adapter.SetupTracing(ctx)
+	if err != nil {
+		logger.Error("failed to set up tracing", "error", err)
+		os.Exit(1)
+	}
+	if shutdownTracing != nil {
+		logger.Info("exporting traces over OTLP")
+	}
+
+	var accessLog *adapter.AccessLogFile
+	if accessLogFile != "" {
+		accessLog, err = adapter.NewAccessLogFile(accessLogFile, accessLogMaxSize, accessLogRotateInterval, accessLogMaxBackups)
+		if err != nil {
+			logger.Error("failed to open access log", "path", accessLogFile, "error", err)
+			os.Exit(1)
+		}
+		defer accessLog.Close()
+	}
+
+	handler, err := adapter.NewHandler(adapter.Options{
+		Handler:           reloadable,
+		Logger:            logger,
+		Recorder:          recorder,
+		CompressResponses: compressResponses,
+		Tracing:           shutdownTracing != nil,
+		AccessLog:         true,
+		AccessLogFile:     accessLog,
+	})
+	if err != nil {
+		logger.Error("failed to create handler", "error", err)
+		os.Exit(1)
+	}
 
 	server := &http.Server{
 		Addr:    listen,
 		Handler: handler,
 	}
 
+	listener, err := adapter.ActivationListener()
+	if err != nil {
+		logger.Error("failed to use the socket passed by systemd", "error", err)
+		os.Exit(1)
+	}
+	if listener != nil {
+		server.Addr = listener.Addr().String()
+		logger.Info("using socket passed by systemd", "addr", server.Addr)
+	} else if listener, err = adapter.Listen(listen, reusePort); err != nil {
+		logger.Error("failed to listen", "addr", listen, "error", err)
+		os.Exit(1)
+	}
+
+	if (tlsCert == "") != (tlsKey == "") {
+		logger.Error("--tls-cert and --tls-key must be set together")
+		os.Exit(1)
+	}
+
+	// The flag variables change when the configuration is reloaded, so the
+	// settings that only apply at startup are copied first.
+	certFile, keyFile, savePath := tlsCert, tlsKey, cacheFile
+	drainTimeout := shutdownTimeout
+
+	if debugListen != "" {
+		debugServer := &http.Server{Addr: debugListen, Handler: adapter.NewDebugHandler()}
+		defer debugServer.Close()
+		go func() {
+			logger.Warn("serving pprof and runtime metrics without authentication", "addr", debugServer.Addr)
+			if err := debugServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Error("debug server failed", "error", err)
+			}
+		}()
+	}
+
 	go func() {
-		logger.Info("Starting server", "addr", listen)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logger.Info("Starting server", "addr", server.Addr, "tls", certFile != "")
+		var err error
+		if certFile != "" {
+			err = server.ServeTLS(listener, certFile, keyFile)
+		} else {
+			err = server.Serve(listener)
+		}
+		if err != nil && err != http.ErrServerClosed {
 			logger.Error("Server failed to start", "error", err)
 			os.Exit(1)
 		}
 	}()
 
+	if configFile != "" {
+		go watchConfig(ctx, reloadable, cache, logger, func(next, prev *adapter.Adapter) {
+			// The health checker is carried over unless its settings changed.
+			if next.Health != prev.Health {
+				stopHealth()
+				stopHealth = func() {}
+				if next.Health != nil {
+					stopHealth = runHealth(next.Health)
+				}
+			}
+		})
+	}
+
 	<-ctx.Done()
-	logger.Info("Shutting down server")
+	// A second signal stops the server without waiting for requests.
+	stop()
+	logger.Info("Shutting down server", "timeout", drainTimeout)
 
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), drainTimeout)
 	defer cancel()
 
 	if err := server.Shutdown(shutdownCtx); err != nil {
@@ -93,133 +481,550 @@ func startServer() {
 		os.Exit(1)
 	}
 
+	if shutdownTracing != nil {
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			logger.Error("failed to flush traces", "error", err)
+		}
+	}
+
+	if savePath != "" && lru != nil {
+		if err := lru.SaveFile(savePath); err != nil {
+			logger.Error("failed to save cache", "path", savePath, "error", err)
+		}
+	}
+	logger.Info("cache statistics", "cache", cache.Stats())
+
 	logger.Info("Server exited")
 }
 
 func init() {
-	rootCmd.Flags().StringVarP(&listen, "listen", "l", ":8005", "Address to listen on")
-	rootCmd.Flags().StringVarP(&target, "target", "t", "", "Target URL to proxy requests to (required)")
-	rootCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable debug output")
-	rootCmd.Flags().StringVarP(&provider, "provider", "p", "llama-cpp", "Backend provider (lmstudio, llama-cpp)")
+	addFlags(rootCmd.Flags())
 }
 
-// LoggingMiddleware wraps an http.Handler and logs HTTP requests in Apache/nginx format
-type LoggingMiddleware struct {
-	handler http.Handler
-	logger  *slog.Logger
+// addFlags defines the command line flags on flags, binding them to the
+// package variables and resetting those to their defaults.
+func addFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&configFile, "config", "c", "", "Path to a YAML config file")
+	flags.StringVarP(&listen, "listen", "l", ":8005", "Address to listen on")
+	flags.BoolVar(&reusePort, "reuse-port", false, "Listen with SO_REUSEPORT, so that a new instance can take over the address while this one drains")
+	flags.DurationVar(&shutdownTimeout, "shutdown-timeout", 5*time.Second, "Maximum time to wait for requests in flight, including streams, on shutdown")
+	flags.StringVar(&debugListen, "debug-listen", "", "Separate address to serve pprof profiles and Go runtime metrics on, e.g. localhost:6060 (disabled when empty)")
+	flags.StringVarP(&target, "target", "t", "", "Target URL to proxy requests to (required)")
+	flags.BoolVarP(&verbose, "verbose", "v", false, "Enable debug output")
+	flags.StringVar(&logFormat, "log-format", adapter.LogFormatText, "Log output format (text, json)")
+	flags.StringToStringVar(&logRouteLevels, "log-route-level", nil, "Log level for requests to a path, e.g. /healthz=warn or /v1/=debug (repeatable)")
+	flags.IntVar(&logSampleRate, "log-sample-rate", 0, "Log only every Nth repeated debug message per second, such as per stream event logs (0 logs all)")
+	flags.StringVar(&accessLogFile, "access-log-file", "", "File to also write a combined-format access log to")
+	flags.Int64Var(&accessLogMaxSize, "access-log-max-size", 100<<20, "Rotate the access log file once it reaches this many bytes (0 disables)")
+	flags.DurationVar(&accessLogRotateInterval, "access-log-rotate-interval", 24*time.Hour, "Rotate the access log file once it has been written to for this long (0 disables)")
+	flags.IntVar(&accessLogMaxBackups, "access-log-max-backups", 7, "Rotated access log files to keep (0 keeps all)")
+	flags.StringVarP(&provider, "provider", "p", "llama-cpp", "Backend provider (lmstudio, llama-cpp, vllm, ollama, openrouter, harmony, responses, or one from --providers-file)")
+	flags.StringVar(&providersFile, "providers-file", "", "YAML or JSON file with additional provider definitions")
+	flags.BoolVar(&providerRouting, "provider-routing", false, "Let clients select a provider per request with the X-GPT-OSS-Provider header or a \"provider/\" model name prefix")
+	flags.StringArrayVar(&modelRoutes, "model-route", nil, "Send requests for models matching a glob to a provider, e.g. \"gpt-oss-120b=openrouter,model=openai/gpt-oss-120b\" (repeatable, first match wins)")
+	flags.StringVar(&fallbackTarget, "fallback-target", "", "Backend URL to send chat requests to when the target fails or times out (e.g. https://openrouter.ai/api)")
+	flags.StringVar(&fallbackProvider, "fallback-provider", "", "Provider of --fallback-target (defaults to --provider)")
+	flags.StringToStringVar(&fallbackModels, "fallback-model", nil, "Model name to use with --fallback-target, e.g. gpt-oss-120b=openai/gpt-oss-120b (repeatable)")
+	flags.StringArrayVar(&replicas, "replica", nil, "Another backend URL serving the same models as --target; requests of one conversation stick to one backend (repeatable)")
+	flags.IntVar(&cacheSize, "cache-size", adapter.DefaultCacheSize, "Maximum number of cached reasoning entries")
+	flags.Int64Var(&cacheMaxBytes, "cache-max-bytes", 0, "Maximum total size in bytes of the in-memory reasoning cache (0 disables)")
+	flags.DurationVar(&cacheStatsInterval, "cache-stats-interval", 0, "How often to log cache hit, miss, insert and eviction counts (0 logs them only on shutdown)")
+	flags.StringVar(&cacheBackend, "cache-backend", adapter.CacheBackendMemory, "Where to store cached reasoning (memory, redis, sqlite, memcached, bolt)")
+	flags.StringVar(&redisURL, "redis-url", "", "Redis URL for --cache-backend=redis (e.g. redis://localhost:6379/0)")
+	flags.StringVar(&cacheNamespace, "cache-namespace", adapter.CacheNamespaceNone, "Keep cached reasoning apart per API key or per header value (none, auth, header)")
+	flags.StringVar(&cacheNamespaceHeader, "cache-namespace-header", adapter.DefaultCacheNamespaceHeader, "Header that selects the namespace for --cache-namespace=header")
+	flags.StringVar(&cacheMode, "cache-mode", adapter.CacheModeStore, "Default cache mode for requests without an X-GPT-OSS-Cache header (store, no-store, bypass)")
+	flags.StringVar(&sqlitePath, "sqlite-path", "gpt-oss-adapter.db", "Database file for --cache-backend=sqlite")
+	flags.StringSliceVar(&memcachedServers, "memcached-servers", nil, "Comma-separated host:port addresses for --cache-backend=memcached")
+	flags.StringVar(&boltPath, "bolt-path", "gpt-oss-adapter.bolt", "Database file for --cache-backend=bolt")
+	flags.BoolVar(&cacheFallback, "cache-fallback", false, "Fall back to the in-memory cache when the cache backend cannot be reached")
+	flags.DurationVar(&cacheTTL, "cache-ttl", 0, "Evict cached reasoning unused for this long (0 disables)")
+	flags.StringVar(&cacheKey, "cache-key", "", "32 byte hex or base64 key to encrypt cached reasoning with, best set through GPT_OSS_ADAPTER_CACHE_KEY")
+	flags.StringVar(&cacheKeyFile, "cache-key-file", "", "File with a 32 byte hex or base64 key to encrypt cached reasoning with")
+	flags.StringVar(&cacheFile, "cache-file", "", "File to persist the in-memory reasoning cache to across restarts")
+	flags.DurationVar(&cacheSaveInterval, "cache-save-interval", time.Minute, "How often to snapshot the cache to --cache-file (0 saves only on shutdown)")
+	flags.StringVar(&reasoningField, "reasoning-field", "", "Override the provider's reasoning field name")
+	flags.StringVar(&reasoningEffortField, "reasoning-effort-field", "", "Override the provider's reasoning effort field path")
+	flags.StringToIntVar(&modelConcurrency, "model-concurrency", nil, "Maximum concurrent requests per model (e.g. gpt-oss-120b=2)")
+	flags.StringToIntVar(&modelRate, "model-rate", nil, "Maximum requests per minute per model (e.g. gpt-oss-120b=30)")
+	flags.IntVar(&rateLimit, "rate-limit", 0, "Maximum requests per minute per client (0 disables)")
+	flags.IntVar(&rateLimitBurst, "rate-limit-burst", 0, "Requests a client may make at once before --rate-limit applies (default: the per-minute rate)")
+	flags.IntVar(&rateLimitStreams, "rate-limit-streams", 0, "Maximum concurrent streamed requests per client (0 disables)")
+	flags.StringVar(&rateLimitKey, "rate-limit-key", adapter.RateLimitKeyAuto, "What identifies a client for rate limits (auto, ip, key)")
+	flags.Int64Var(&maxRequestSize, "max-request-size", 32<<20, "Maximum request body size in bytes (0 disables)")
+	flags.StringVar(&moderationURL, "moderation-url", "", "External moderation endpoint to check user messages against")
+	flags.StringVar(&moderationMode, "moderation-mode", adapter.ModerationModeBlock, "Action on flagged content (block, annotate)")
+	flags.BoolVar(&moderationFailOpen, "moderation-fail-open", false, "Allow requests when the moderation endpoint is unavailable")
+	flags.BoolVar(&moderationCheckResponse, "moderation-check-response", false, "Also check non-streaming responses")
+	flags.DurationVar(&moderationTimeout, "moderation-timeout", 5*time.Second, "Timeout for moderation requests")
+	flags.BoolVar(&reasoningSummary, "reasoning-summary", false, "Summarize reasoning and add the summary to responses")
+	flags.StringVar(&reasoningSummaryURL, "reasoning-summary-url", "", "Chat completions endpoint that writes reasoning summaries (default: the target's)")
+	flags.StringVar(&reasoningSummaryModel, "reasoning-summary-model", "", "Model that writes reasoning summaries (default: the model of the response)")
+	flags.DurationVar(&reasoningSummaryTimeout, "reasoning-summary-timeout", 30*time.Second, "Timeout for reasoning summary requests")
+	flags.StringVar(&statelessKeyFile, "stateless-key-file", "", "File with a 32 byte hex or base64 key to encrypt reasoning into tool calls with, instead of relying on the cache")
+	flags.IntVar(&conversationBudget, "conversation-token-budget", 0, "Maximum cumulative tokens per conversation (0 disables)")
+	flags.StringVar(&conversationBudgetMode, "conversation-budget-mode", adapter.BudgetModeReject, "Action when a conversation exceeds its budget (reject, warn)")
+	flags.BoolVar(&syntheticUsage, "synthetic-usage", false, "Estimate token usage when the backend does not report it")
+	flags.BoolVar(&usageAccounting, "usage-accounting", false, "Count the tokens used per API key and model, served at /v1/usage and /metrics")
+	flags.StringVar(&tokenizeURL, "tokenize-url", "", "llama.cpp-compatible /tokenize endpoint used for synthetic usage")
+	flags.StringArrayVar(&effortRules, "effort-rule", nil, "Reasoning effort policy rule, e.g. \"inflight>4:low\" (repeatable, first match wins)")
+	flags.BoolVar(&effortOverride, "effort-override", false, "Apply effort rules even when the client requested an effort")
+	flags.IntSliceVar(&effortDowngradeQueue, "effort-downgrade-queue", nil, "Lower the reasoning effort by a step at each of these numbers of queued requests, e.g. 4,8")
+	flags.DurationSliceVar(&effortDowngradeLatency, "effort-downgrade-latency", nil, "Lower the reasoning effort by a step at each of these average times to first token, e.g. 5s,15s")
+	flags.StringVar(&defaultEffort, "default-reasoning-effort", "", "Reasoning effort for requests that do not request one (low, medium, high)")
+	flags.StringArrayVar(&transformers, "transformer", nil, "Optional built-in transformer to apply to chat requests and responses (repeatable: reasoning-content, max-tokens)")
+	flags.StringVar(&transformScript, "transform-script", "", "Starlark script that transforms chat requests and responses, after any --transformer")
+	flags.StringVar(&streamFormat, "stream-format", adapter.StreamFormatSSE, "Output format for streamed responses (sse, ndjson)")
+	flags.BoolVar(&fuzzyMatch, "fuzzy-match", false, "Also match cached reasoning by tool call name and arguments when IDs do not match")
+	flags.StringVar(&reasoningFormat, "reasoning-format", adapter.ReasoningFormatField, "Where the backend puts reasoning (field, think-tags)")
+	flags.StringVar(&reasoningInjection, "reasoning-injection", adapter.ReasoningInjectionAll, "Which assistant messages get their reasoning restored (all, since-last-user, latest-only)")
+	flags.BoolVar(&reasoningChains, "reasoning-chains", false, "Keep the ordered reasoning history of each conversation and restore it by turn")
+	flags.IntVar(&reasoningChainDepth, "reasoning-chain-depth", 0, "Number of latest turns kept in a conversation's reasoning chain (0 for all)")
+	flags.IntVar(&reasoningBudget, "reasoning-token-budget", 0, "Maximum estimated tokens of reasoning injected into a request, dropping the oldest first (0 for no limit)")
+	flags.StringVar(&plainTurns, "plain-turns", adapter.PlainTurnsOff, "Also cache reasoning for assistant turns without tool calls, tagging them with an ID (off, field, marker)")
+	flags.StringVar(&apiKeysFile, "api-keys-file", "", "YAML or JSON file with the API keys clients must present, and optional keys to send upstream instead")
+	flags.StringVar(&adminToken, "admin-token", "", "Bearer token for the /admin API (disabled when empty)")
+	flags.BoolVar(&debugTransform, "debug-transform", false, "Serve POST /debug/transform, which returns a chat request as it would be sent to the target")
+	flags.IntVar(&debugRecent, "debug-recent", 0, "Keep the last N chat exchanges, before and after transformation, for GET /debug/recent (0 disables, requires --admin-token)")
+	flags.IntVar(&embeddingsBatchSize, "embeddings-batch-size", 0, "Split embeddings requests with more inputs than this into batches and merge the results (0 disables)")
+	flags.StringVar(&structuredOutputs, "structured-outputs", "", "Validate chat completions against the schema of their response format, and annotate or reject those that do not match (annotate, reject)")
+	flags.IntVar(&toolCallRetries, "tool-call-retries", 0, "Send blocking chat requests again up to this many times when the model calls a tool with truncated, malformed or schema-invalid arguments")
+	flags.IntVar(&toolChoiceRetries, "tool-choice-retries", 0, "Send blocking chat requests whose tool_choice requires a tool call again up to this many times while the model does not call it")
+	flags.BoolVar(&stripReasoning, "strip-reasoning", false, "Cache reasoning for later turns but remove it from responses sent to clients")
+	flags.StringSliceVar(&scrubRules, "scrub", nil, "Comma-separated built-in rules redacting reasoning before it is cached or recorded (api-keys, emails, cards)")
+	flags.StringArrayVar(&scrubPatterns, "scrub-pattern", nil, "Regular expression redacted from reasoning before it is cached or recorded (repeatable)")
+	flags.StringArrayVar(&scrubKeywords, "scrub-keyword", nil, "Case-insensitive keyword redacted from reasoning before it is cached or recorded (repeatable)")
+	flags.StringVar(&recordDir, "record-dir", "", "Directory to record request/response pairs to as JSONL, for debugging")
+	flags.IntVar(&streamMaxLineSize, "stream-max-line-size", adapter.DefaultStreamMaxLineSize, "Maximum size in bytes of a single line in a streamed backend response")
+	flags.DurationVar(&streamKeepAlive, "stream-keep-alive", 0, "Send an SSE comment to streaming clients at this interval until the backend responds (0 disables)")
+	flags.DurationVar(&streamWriteTimeout, "stream-write-timeout", 0, "Cut off streaming clients that take longer than this to accept a chunk (0 disables)")
+	flags.IntVar(&streamBufferSize, "stream-buffer-size", 0, "Cut off streaming clients that fall this many chunks behind (0 disables)")
+	flags.StringVar(&streamOverflow, "stream-overflow", adapter.StreamOverflowDisconnect, "What happens to the backend stream of a client that was cut off (disconnect, drop-through)")
+	flags.BoolVar(&streamStats, "stream-stats", false, "Add the time to first token and tokens per second to the usage chunk of streamed chat completions")
+	flags.IntVar(&maxConcurrent, "max-concurrent", 0, "Maximum chat requests in flight to the target; excess requests are queued (0 disables)")
+	flags.IntVar(&queueDepth, "queue-depth", 100, "Maximum requests waiting for a slot under --max-concurrent")
+	flags.DurationVar(&queueTimeout, "queue-timeout", 30*time.Second, "Maximum time a request waits for a slot (0 waits until the client disconnects)")
+	flags.BoolVar(&coalesce, "coalesce", false, "Serve identical non-streaming chat requests in flight at the same time from one upstream call")
+	flags.DurationVar(&responseCacheTTL, "response-cache-ttl", 0, "Cache responses to non-streaming chat requests with temperature 0 for this long (0 disables)")
+	flags.IntVar(&responseCacheSize, "response-cache-size", 1000, "Maximum number of responses in the response cache")
+	flags.Int64Var(&responseCacheMaxBytes, "response-cache-max-bytes", 0, "Maximum total size in bytes of the response bodies in the response cache (0 disables)")
+	flags.IntVar(&retryMax, "retry-max", 0, "Times to retry chat requests that fail to connect or return a retryable status (0 disables)")
+	flags.DurationVar(&retryBackoff, "retry-backoff", 500*time.Millisecond, "Delay before the first retry, doubled for each further retry")
+	flags.DurationVar(&retryMaxBackoff, "retry-max-backoff", 10*time.Second, "Maximum delay between retries")
+	flags.IntSliceVar(&retryStatuses, "retry-on-status", adapter.DefaultRetryStatuses, "Upstream statuses to retry")
+	flags.Float64Var(&circuitThreshold, "circuit-breaker-threshold", 0, "Share of failed chat requests, from 0 to 1, that opens the circuit to the target (0 disables)")
+	flags.IntVar(&circuitMinRequests, "circuit-breaker-min-requests", 5, "Requests within the window before the circuit breaker judges the failure rate")
+	flags.DurationVar(&circuitWindow, "circuit-breaker-window", time.Minute, "Period over which the circuit breaker counts failures")
+	flags.DurationVar(&circuitCooldown, "circuit-breaker-cooldown", 30*time.Second, "How long an open circuit rejects requests before letting one through to probe the target")
+	flags.StringVar(&tlsCert, "tls-cert", "", "Certificate file to serve HTTPS with")
+	flags.StringVar(&tlsKey, "tls-key", "", "Private key file for --tls-cert")
+	flags.StringVar(&upstreamCA, "upstream-ca", "", "CA bundle to verify the target's certificate with")
+	flags.StringVar(&upstreamClientCert, "upstream-client-cert", "", "Client certificate file for mutual TLS with the target")
+	flags.StringVar(&upstreamClientKey, "upstream-client-key", "", "Private key file for --upstream-client-cert")
+	flags.BoolVar(&upstreamInsecureSkipVerify, "upstream-insecure-skip-verify", false, "Do not verify the target's TLS certificate")
+	flags.DurationVar(&upstreamConnectTimeout, "upstream-connect-timeout", 0, "Maximum time to connect to the target (0 uses the default of 30s)")
+	flags.IntVar(&upstreamMaxIdleConns, "upstream-max-idle-conns", adapter.DefaultUpstreamMaxIdleConns, "Idle connections to the target kept open for reuse")
+	flags.DurationVar(&upstreamIdleTimeout, "upstream-idle-timeout", adapter.DefaultUpstreamIdleTimeout, "How long an idle connection to the target is kept open")
+	flags.StringVar(&upstreamHTTP2, "upstream-http2", adapter.UpstreamHTTP2Auto, "When to use HTTP/2 with the target (auto, off, always)")
+	flags.StringVar(&upstreamSocket, "upstream-socket", "", "Connect to the target through this unix socket")
+	flags.DurationVar(&upstreamResponseHeaderTimeout, "upstream-response-header-timeout", 0, "Maximum time to wait for the target's response headers (0 disables)")
+	flags.DurationVar(&upstreamTimeout, "upstream-timeout", 0, "Maximum time for a non-streaming chat request to the target (0 disables)")
+	flags.StringArrayVar(&upstreamHeaderStrip, "upstream-header-strip", nil, "Client header not to forward to the target, or a prefix ending in * (repeatable)")
+	flags.StringToStringVar(&upstreamHeaderSet, "upstream-header", nil, "Header to set on requests to the target, e.g. X-Title=My App (repeatable, values may reference ${VAR})")
+	flags.BoolVar(&upstreamCompression, "upstream-compression", false, "Request brotli, gzip or deflate compressed responses from the target")
+	flags.BoolVar(&compressResponses, "compress-responses", false, "Gzip responses for clients that accept it")
+	flags.DurationVar(&healthCheckInterval, "health-check-interval", 10*time.Second, "How often to probe the target for /readyz (0 disables)")
+	flags.StringVar(&healthCheckPath, "health-check-path", "", "Target path to probe (default: /health, falling back to /v1/models)")
+	flags.DurationVar(&healthCheckTimeout, "health-check-timeout", 5*time.Second, "Timeout for each health probe")
 }
 
-// NewLoggingMiddleware creates a new HTTP logging middleware
-func NewLoggingMiddleware(handler http.Handler, logger *slog.Logger) *LoggingMiddleware {
-	return &LoggingMiddleware{
-		handler: handler,
-		logger:  logger,
+func getModelLimits() map[string]adapter.ModelLimit {
+	limits := make(map[string]adapter.ModelLimit)
+	for model, n := range modelConcurrency {
+		limit := limits[model]
+		limit.Concurrency = n
+		limits[model] = limit
 	}
+	for model, n := range modelRate {
+		limit := limits[model]
+		limit.RatePerMinute = n
+		limits[model] = limit
+	}
+	return limits
 }
 
-// responseWriter wraps http.ResponseWriter to capture status code and response size
-type responseWriter struct {
-	http.ResponseWriter
-	statusCode int
-	size       int
-}
-
-func (rw *responseWriter) WriteHeader(code int) {
-	rw.statusCode = code
-	rw.ResponseWriter.WriteHeader(code)
-}
+// watchConfig rebuilds the adapter from the command line and config file on
+// SIGHUP and swaps it in for new requests. Requests in flight, including
+// streams, finish on the adapter they started on. A configuration that fails
+// to load leaves the running one in place.
+func watchConfig(ctx context.Context, reloadable *adapter.Reloadable, cache adapter.Cache, logger *slog.Logger, replaced func(next, prev *adapter.Adapter)) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
 
-func (rw *responseWriter) Write(b []byte) (int, error) {
-	size, err := rw.ResponseWriter.Write(b)
-	rw.size += size
-	return size, err
-}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+		}
 
-func (rw *responseWriter) Flush() {
-	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
-		flusher.Flush()
+		logger.Info("reloading configuration", "path", configFile)
+		next, err := reloadConfig(cache, logger)
+		if err != nil {
+			logger.Error("failed to reload configuration, keeping the current one", "error", err)
+			continue
+		}
+		prev := reloadable.Replace(next)
+		replaced(next, prev)
+		logger.Info("reloaded configuration", "target", next.Target, "provider", next.Provider.Name)
 	}
 }
 
-func (m *LoggingMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	start := time.Now()
-
-	// Wrap the response writer to capture status and size
-	rw := &responseWriter{
-		ResponseWriter: w,
-		statusCode:     200, // default status code
-		size:           0,
+// reloadConfig parses the command line again into a fresh flag set, so that
+// options removed from the config file return to their defaults, applies the
+// environment and the config file on top and builds a new adapter. Settings
+// that are not part of the adapter, such as the listen address, TLS and the
+// cache backend, keep the values the server was started with.
+func reloadConfig(cache adapter.Cache, logger *slog.Logger) (*adapter.Adapter, error) {
+	flags := pflag.NewFlagSet("gpt-oss-adapter", pflag.ContinueOnError)
+	flags.ParseErrorsWhitelist.UnknownFlags = true
+	addFlags(flags)
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		return nil, err
+	}
+	if err := LoadEnv(flags); err != nil {
+		return nil, err
 	}
 
-	// Call the wrapped handler
-	m.handler.ServeHTTP(rw, r)
-
-	// Calculate request duration
-	duration := time.Since(start)
-
-	// Get client IP, preferring X-Forwarded-For or X-Real-IP headers
-	clientIP := getClientIP(r)
+	config, err := LoadConfig(configFile, flags)
+	if err != nil {
+		return nil, err
+	}
+	if target == "" {
+		return nil, fmt.Errorf("target is required")
+	}
+	return buildAdapter(config, cache, logger)
+}
 
-	// Get user agent
-	userAgent := r.Header.Get("User-Agent")
-	if userAgent == "" {
-		userAgent = "-"
+// buildAdapter creates an adapter from the flags and the config file. It is
+// called again with the same cache whenever the configuration is reloaded.
+func buildAdapter(config *Config, cache adapter.Cache, logger *slog.Logger) (*adapter.Adapter, error) {
+	registry := providers.NewRegistry()
+	if providersFile != "" {
+		if err := registry.LoadFile(providersFile); err != nil {
+			return nil, fmt.Errorf("loading provider definitions: %w", err)
+		}
+	}
+	if err := registry.MergeAll(config.Providers); err != nil {
+		return nil, fmt.Errorf("invalid provider definition in config: %w", err)
 	}
 
-	// Get referer
-	referer := r.Header.Get("Referer")
-	if referer == "" {
-		referer = "-"
+	providerConfig := getProviderConfig(registry, provider)
+	if reasoningField != "" {
+		providerConfig.Reasoning = reasoningField
+	}
+	if reasoningEffortField != "" {
+		providerConfig.ReasoningEffort = reasoningEffortField
+	}
+	a := adapter.NewAdapter(target, cache, logger, providerConfig)
+	switch streamFormat {
+	case adapter.StreamFormatSSE, adapter.StreamFormatNDJSON:
+		a.StreamFormat = streamFormat
+	default:
+		return nil, fmt.Errorf("unknown stream format %q", streamFormat)
+	}
+	a.StreamMaxLineSize = streamMaxLineSize
+	a.StreamKeepAlive = streamKeepAlive
+	if streamWriteTimeout < 0 || streamBufferSize < 0 {
+		return nil, fmt.Errorf("invalid stream write timeout or buffer size")
+	}
+	a.StreamWriteTimeout = streamWriteTimeout
+	a.StreamBufferSize = streamBufferSize
+	switch streamOverflow {
+	case adapter.StreamOverflowDisconnect, adapter.StreamOverflowDropThrough:
+		a.StreamOverflow = streamOverflow
+	default:
+		return nil, fmt.Errorf("unknown stream overflow policy %q", streamOverflow)
+	}
+	a.StreamStats = streamStats
+	a.FuzzyMatch = fuzzyMatch
+	a.AdminToken = adminToken
+	a.DebugTransform = debugTransform
+	a.DebugRecent = debugRecent
+	if embeddingsBatchSize < 0 {
+		return nil, fmt.Errorf("invalid embeddings batch size %d", embeddingsBatchSize)
+	}
+	a.EmbeddingsBatchSize = embeddingsBatchSize
+	if toolChoiceRetries < 0 {
+		return nil, fmt.Errorf("invalid tool choice retries %d", toolChoiceRetries)
+	}
+	a.ToolChoiceRetries = toolChoiceRetries
+	if toolCallRetries < 0 {
+		return nil, fmt.Errorf("invalid tool call retries %d", toolCallRetries)
+	}
+	a.ToolCallRetries = toolCallRetries
+	switch structuredOutputs {
+	case "", adapter.StructuredOutputAnnotate, adapter.StructuredOutputReject:
+		a.StructuredOutputs = structuredOutputs
+	default:
+		return nil, fmt.Errorf("unknown structured outputs mode %q", structuredOutputs)
+	}
+	a.StripReasoning = stripReasoning
+	a.Coalesce = coalesce
+	if responseCacheTTL > 0 {
+		a.ResponseCache = adapter.NewResponseCache(responseCacheTTL, responseCacheSize, responseCacheMaxBytes)
+	}
+	a.UpstreamCompression = upstreamCompression
+	if len(upstreamHeaderStrip) > 0 || len(upstreamHeaderSet) > 0 {
+		a.HeaderRules = adapter.NewHeaderRules(upstreamHeaderStrip, upstreamHeaderSet)
+	}
+	a.UpstreamTimeout = upstreamTimeout
+	switch cacheNamespace {
+	case adapter.CacheNamespaceNone:
+	case adapter.CacheNamespaceAuth, adapter.CacheNamespaceHeader:
+		a.CacheNamespace = cacheNamespace
+		a.CacheNamespaceHeader = cacheNamespaceHeader
+	default:
+		return nil, fmt.Errorf("unknown cache namespace mode %q", cacheNamespace)
+	}
+	switch cacheMode {
+	case adapter.CacheModeStore, adapter.CacheModeNoStore, adapter.CacheModeBypass:
+		a.CacheMode = cacheMode
+	default:
+		return nil, fmt.Errorf("unknown cache mode %q", cacheMode)
+	}
+	switch reasoningInjection {
+	case adapter.ReasoningInjectionAll, adapter.ReasoningInjectionSinceLastUser, adapter.ReasoningInjectionLatestOnly:
+		a.ReasoningInjection = reasoningInjection
+	default:
+		return nil, fmt.Errorf("unknown reasoning injection policy %q", reasoningInjection)
+	}
+	if reasoningChainDepth < 0 {
+		return nil, fmt.Errorf("invalid reasoning chain depth %d", reasoningChainDepth)
+	}
+	a.ReasoningChains = reasoningChains
+	a.ReasoningChainDepth = reasoningChainDepth
+	if reasoningBudget < 0 {
+		return nil, fmt.Errorf("invalid reasoning token budget %d", reasoningBudget)
+	}
+	a.ReasoningTokenBudget = reasoningBudget
+	switch plainTurns {
+	case adapter.PlainTurnsOff, adapter.PlainTurnsField, adapter.PlainTurnsMarker:
+		a.PlainTurns = plainTurns
+	default:
+		return nil, fmt.Errorf("unknown plain turns mode %q", plainTurns)
+	}
+	switch reasoningFormat {
+	case adapter.ReasoningFormatField, adapter.ReasoningFormatThinkTags:
+		a.ReasoningFormat = reasoningFormat
+	default:
+		return nil, fmt.Errorf("unknown reasoning format %q", reasoningFormat)
+	}
+	if limits := getModelLimits(); len(limits) > 0 {
+		a.Throttle = adapter.NewModelThrottle(limits)
+	}
+	switch rateLimitKey {
+	case adapter.RateLimitKeyAuto, adapter.RateLimitKeyIP, adapter.RateLimitKeyAPIKey:
+	default:
+		return nil, fmt.Errorf("unknown rate limit key %q", rateLimitKey)
+	}
+	if rateLimit > 0 || rateLimitStreams > 0 {
+		a.RateLimit = adapter.NewRateLimiter(rateLimit, rateLimitBurst, rateLimitStreams, rateLimitKey)
+	}
+	a.MaxRequestSize = maxRequestSize
+	switch moderationMode {
+	case adapter.ModerationModeBlock, adapter.ModerationModeAnnotate:
+	default:
+		return nil, fmt.Errorf("unknown moderation mode %q", moderationMode)
+	}
+	if moderationURL != "" {
+		a.Moderator = adapter.NewModerator(moderationURL, moderationMode, moderationFailOpen, moderationCheckResponse, moderationTimeout)
+	}
+	if reasoningSummary {
+		// Summaries are written by the target unless another endpoint is
+		// given, in which case the provider's headers are not sent to it.
+		summaryURL, headers := reasoningSummaryURL, map[string]string(nil)
+		if summaryURL == "" {
+			summaryURL = strings.TrimSuffix(target, "/") + "/v1/chat/completions"
+			headers = providerHeaders(providerConfig)
+		}
+		a.Summarizer = adapter.NewSummarizer(summaryURL, reasoningSummaryModel, headers, reasoningSummaryTimeout)
+	}
+	if statelessKeyFile != "" {
+		key, err := adapter.LoadReasoningKey(statelessKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading stateless key: %w", err)
+		}
+		cipher, err := adapter.NewReasoningCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid stateless key: %w", err)
+		}
+		a.Cipher = cipher
+	}
+	if len(scrubRules) > 0 || len(scrubPatterns) > 0 || len(scrubKeywords) > 0 {
+		scrubber, err := adapter.NewScrubber(scrubRules, scrubPatterns, scrubKeywords)
+		if err != nil {
+			return nil, err
+		}
+		a.Scrubber = scrubber
+	}
+	if conversationBudget > 0 {
+		a.Budget = adapter.NewTokenBudget(conversationBudget, conversationBudgetMode)
+	}
+	if syntheticUsage {
+		a.Usage = adapter.NewUsageEstimator(tokenizeURL)
+	}
+	if usageAccounting {
+		a.Ledger = adapter.NewUsageLedger()
+	}
+	if apiKeysFile != "" {
+		keys, err := adapter.LoadAPIKeys(apiKeysFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading API keys: %w", err)
+		}
+		a.APIKeys = keys
+		logger.Info("requiring client API keys", "keys", keys.Len())
+	}
+	if len(effortRules) > 0 {
+		policy, err := adapter.NewEffortPolicy(effortRules, effortOverride)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reasoning effort policy: %w", err)
+		}
+		a.Policy = policy
+	}
+	for _, name := range transformers {
+		transformer, err := adapter.NewTransformer(name)
+		if err != nil {
+			return nil, err
+		}
+		a.Transformers = append(a.Transformers, transformer)
+	}
+	rules, err := adapter.CompileRequestRules(config.RequestRules)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request rule in config: %w", err)
+	}
+	a.RequestRules = rules
+	if transformScript != "" {
+		script, err := adapter.LoadScriptTransformer(transformScript, logger)
+		if err != nil {
+			return nil, fmt.Errorf("loading transform script: %w", err)
+		}
+		a.Transformers = append(a.Transformers, script)
+	}
+	if defaultEffort != "" {
+		if !adapter.ValidReasoningEffort(defaultEffort) {
+			return nil, fmt.Errorf("invalid default reasoning effort %q", defaultEffort)
+		}
+		a.DefaultReasoningEffort = defaultEffort
+	}
+	if len(effortDowngradeQueue) > 0 || len(effortDowngradeLatency) > 0 {
+		a.EffortDowngrade = adapter.NewEffortDowngrade(effortDowngradeQueue, effortDowngradeLatency)
+	}
+	if maxConcurrent > 0 {
+		a.Queue = adapter.NewRequestQueue(maxConcurrent, queueDepth, queueTimeout)
+	}
+	if retryMax > 0 {
+		a.Retry = adapter.NewRetryPolicy(retryMax, retryBackoff, retryMaxBackoff, retryStatuses)
+	}
+	if circuitThreshold < 0 || circuitThreshold > 1 {
+		return nil, fmt.Errorf("circuit breaker threshold must be between 0 and 1, got %v", circuitThreshold)
+	}
+	if circuitThreshold > 0 {
+		a.Breaker = adapter.NewCircuitBreaker(circuitThreshold, circuitMinRequests, circuitWindow, circuitCooldown)
+	}
+	if providerRouting {
+		for _, name := range registry.Names() {
+			route, _ := registry.Get(name)
+			if name == providerConfig.Name {
+				route = providerConfig
+			}
+			a.AddRoute(route)
+		}
+	}
+	for _, source := range modelRoutes {
+		route, err := adapter.ParseModelRoute(source)
+		if err != nil {
+			return nil, err
+		}
+		routeProvider, ok := registry.Get(route.Provider)
+		if !ok {
+			return nil, fmt.Errorf("unknown provider %q in model route %q", route.Provider, source)
+		}
+		if route.Provider == providerConfig.Name {
+			routeProvider = providerConfig
+		}
+		if err := a.AddModelRoute(route, routeProvider); err != nil {
+			return nil, err
+		}
+	}
+	if fallbackTarget != "" {
+		fallbackConfig := providerConfig
+		if fallbackProvider != "" {
+			var ok bool
+			if fallbackConfig, ok = registry.Get(fallbackProvider); !ok {
+				return nil, fmt.Errorf("unknown fallback provider %q", fallbackProvider)
+			}
+		}
+		a.SetFallback(fallbackTarget, fallbackConfig)
+		a.FallbackModels = fallbackModels
+	}
+	for _, replica := range replicas {
+		a.AddReplica(replica)
 	}
 
-	// Log using structured logging fields
-	m.logger.Info("HTTP request",
-		"client_ip", clientIP,
-		"method", r.Method,
-		"path", r.RequestURI,
-		"protocol", r.Proto,
-		"status", rw.statusCode,
-		"size", rw.size,
-		"referer", referer,
-		"user_agent", userAgent,
-		"duration_ms", duration.Milliseconds(),
-	)
-}
+	if healthCheckInterval > 0 {
+		a.Health = adapter.NewHealthChecker(target, healthCheckPath, providerHeaders(providerConfig), healthCheckInterval, healthCheckTimeout)
+	}
 
-// getClientIP extracts the client IP from the request, checking proxy headers first
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		// X-Forwarded-For can contain multiple IPs, take the first one
-		if idx := strings.Index(xff, ","); idx != -1 {
-			return strings.TrimSpace(xff[:idx])
+	upstreamTLS, err := adapter.LoadUpstreamTLSConfig(upstreamCA, upstreamClientCert, upstreamClientKey, upstreamInsecureSkipVerify)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upstream TLS options: %w", err)
+	}
+	if upstreamTLS != nil {
+		a.SetUpstreamTLS(upstreamTLS)
+		if upstreamInsecureSkipVerify {
+			logger.Warn("upstream TLS certificate verification is disabled")
 		}
-		return strings.TrimSpace(xff)
 	}
-
-	// Check X-Real-IP header
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return strings.TrimSpace(xri)
+	if upstreamConnectTimeout > 0 || upstreamResponseHeaderTimeout > 0 {
+		a.SetUpstreamTimeouts(upstreamConnectTimeout, upstreamResponseHeaderTimeout)
 	}
+	a.SetUpstreamPool(upstreamMaxIdleConns, upstreamIdleTimeout)
+	switch upstreamHTTP2 {
+	case adapter.UpstreamHTTP2Auto, adapter.UpstreamHTTP2Off, adapter.UpstreamHTTP2Always:
+		a.SetUpstreamHTTP2(upstreamHTTP2)
+	default:
+		return nil, fmt.Errorf("unknown upstream HTTP/2 mode %q", upstreamHTTP2)
+	}
+	if upstreamSocket != "" {
+		a.SetUpstreamSocket(upstreamSocket)
+	}
+
+	return a, nil
+}
 
-	// Fall back to RemoteAddr
-	if idx := strings.LastIndex(r.RemoteAddr, ":"); idx != -1 {
-		return r.RemoteAddr[:idx]
+func getProviderConfig(registry *providers.Registry, name string) types.Provider {
+	if provider, ok := registry.Get(name); ok {
+		return provider
 	}
 
-	return r.RemoteAddr
+	fmt.Fprintf(os.Stderr, "Error: unknown provider %s, defaulting to lmstudio\n", name)
+	provider, _ := registry.Get("lmstudio")
+	return provider
 }
 
-func getProviderConfig(provider string) types.Provider {
-	switch provider {
-	case "lmstudio":
-		return lmstudio.NewProvider()
-	case "llama-cpp":
-		return llamacpp.NewProvider()
-	default:
-		fmt.Fprintf(os.Stderr, "Error: unknown provider %s, defaulting to lmstudio\n", provider)
-		return lmstudio.NewProvider()
+// providerHeaders returns the headers the provider sends to its backend,
+// including its API key, for requests the adapter makes on its own.
+func providerHeaders(provider types.Provider) map[string]string {
+	if provider.APIKey == "" {
+		return provider.Headers
+	}
+	headers := map[string]string{"Authorization": "Bearer " + provider.APIKey}
+	for name, value := range provider.Headers {
+		headers[name] = value
 	}
+	return headers
 }
 
 func main() {
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aldehir/gpt-oss-adapter/internal/mockbackend"
+)
+
+var (
+	mockListen  string
+	mockOptions mockbackend.Options
+)
+
+var mockBackendCmd = &cobra.Command{
+	Use:   "mock-backend",
+	Short: "Serve a fake OpenAI-compatible backend for integration tests",
+	Long: "Serve a fake OpenAI-compatible chat completions API with configurable reasoning fields, tool calls " +
+		"and streaming patterns, including malformed ones, to test the adapter and its clients without a GPU.",
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+		backend, err := mockbackend.New(mockOptions)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		server := &http.Server{Addr: mockListen, Handler: backend}
+		go func() {
+			logger.Info("Starting mock backend", "addr", mockListen, "reasoning_field", mockOptions.ReasoningField, "faults", mockOptions.Faults)
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Error("Mock backend failed to start", "error", err)
+				os.Exit(1)
+			}
+		}()
+
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	},
+}
+
+func init() {
+	flags := mockBackendCmd.Flags()
+	flags.StringVarP(&mockListen, "listen", "l", ":8080", "Address to listen on")
+	flags.StringVar(&mockOptions.ReasoningField, "reasoning-field", "reasoning_content", "Field to send reasoning in, e.g. reasoning, or "+mockbackend.ReasoningNone+" or "+mockbackend.ReasoningThinkTags)
+	flags.StringVar(&mockOptions.Reasoning, "reasoning", "The user wants an answer. I will give a short one.", "Reasoning to send with every response")
+	flags.StringVar(&mockOptions.Content, "content", "Hello from the mock backend.", "Content to answer with")
+	flags.StringVar(&mockOptions.ToolCalls, "tool-calls", mockbackend.ToolCallsAuto, "When to call the first tool offered (auto, always, never)")
+	flags.IntVar(&mockOptions.ChunkSize, "chunk-size", 8, "Characters per streamed delta")
+	flags.DurationVar(&mockOptions.FirstTokenDelay, "first-token-delay", 0, "Delay before the first delta or a blocking response")
+	flags.DurationVar(&mockOptions.ChunkDelay, "chunk-delay", 0, "Delay between streamed deltas")
+	flags.StringSliceVar(&mockOptions.Faults, "fault", nil, "Faults to inject ("+strings.Join(mockbackend.Faults(), ", ")+")")
+	flags.Float64Var(&mockOptions.FaultRate, "fault-rate", 1, "Fraction of responses to inject --fault into")
+	flags.StringSliceVar(&mockOptions.Models, "models", []string{"gpt-oss-20b", "gpt-oss-120b"}, "Models to list at /v1/models")
+
+	rootCmd.AddCommand(mockBackendCmd)
+}
@@ -0,0 +1,229 @@
+package adapter
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// combinedLogTime is the timestamp layout of the combined log format.
+const combinedLogTime = "02/Jan/2006:15:04:05 -0700"
+
+// rotatedLogTime is the layout of the time appended to rotated access logs.
+const rotatedLogTime = "20060102-150405"
+
+// AccessLogFile writes an access log in the combined log format used by
+// Apache and nginx to a file, separately from the adapter's own logs, and
+// rotates it by size and age. Rotated files are renamed to the path followed
+// by the time of the rotation, e.g. access.log.20250102-150405.
+type AccessLogFile struct {
+	Path string
+
+	// MaxSize rotates the file before a write would take it past this many
+	// bytes. Zero disables size-based rotation.
+	MaxSize int64
+
+	// RotateInterval rotates the file once it has been written to for this
+	// long. Zero disables time-based rotation.
+	RotateInterval time.Duration
+
+	// MaxBackups is the number of rotated files to keep, removing the
+	// oldest. Zero keeps them all.
+	MaxBackups int
+
+	mutex  sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+}
+
+// NewAccessLogFile opens, or creates, the access log at path.
+func NewAccessLogFile(path string, maxSize int64, rotateInterval time.Duration, maxBackups int) (*AccessLogFile, error) {
+	f := &AccessLogFile{
+		Path:           path,
+		MaxSize:        maxSize,
+		RotateInterval: rotateInterval,
+		MaxBackups:     maxBackups,
+	}
+	if err := f.open(time.Now()); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *AccessLogFile) open(now time.Time) error {
+	file, err := os.OpenFile(f.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	f.opened = now
+	return nil
+}
+
+// Write appends a line to the log, rotating it first if it is due.
+func (f *AccessLogFile) Write(line []byte) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	now := time.Now()
+	if f.file == nil {
+		if err := f.open(now); err != nil {
+			return 0, err
+		}
+	}
+	if f.due(now, int64(len(line))) {
+		if err := f.rotate(now); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(line)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *AccessLogFile) due(now time.Time, size int64) bool {
+	if f.size == 0 {
+		return false
+	}
+	if f.MaxSize > 0 && f.size+size > f.MaxSize {
+		return true
+	}
+	return f.RotateInterval > 0 && now.Sub(f.opened) >= f.RotateInterval
+}
+
+// rotate renames the current file aside, opens a new one and removes the
+// backups beyond MaxBackups.
+func (f *AccessLogFile) rotate(now time.Time) error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+
+	base := f.Path + "." + now.Format(rotatedLogTime)
+	backup := base
+	for i := 1; ; i++ {
+		if _, err := os.Stat(backup); os.IsNotExist(err) {
+			break
+		}
+		backup = base + "." + strconv.Itoa(i)
+	}
+	if err := os.Rename(f.Path, backup); err != nil {
+		return fmt.Errorf("rotating access log: %w", err)
+	}
+	if err := f.open(now); err != nil {
+		return err
+	}
+	return f.removeBackups()
+}
+
+func (f *AccessLogFile) removeBackups() error {
+	if f.MaxBackups <= 0 {
+		return nil
+	}
+	matches, err := filepath.Glob(f.Path + ".*")
+	if err != nil {
+		return err
+	}
+	var backups []string
+	for _, match := range matches {
+		suffix := strings.TrimPrefix(match, f.Path+".")
+		if len(suffix) < len(rotatedLogTime) {
+			continue
+		}
+		if _, err := time.Parse(rotatedLogTime, suffix[:len(rotatedLogTime)]); err == nil {
+			backups = append(backups, match)
+		}
+	}
+	if len(backups) <= f.MaxBackups {
+		return nil
+	}
+
+	// The timestamps in the names sort in the order of the rotations.
+	sort.Strings(backups)
+	for _, backup := range backups[:len(backups)-f.MaxBackups] {
+		if err := os.Remove(backup); err != nil {
+			return fmt.Errorf("removing rotated access log: %w", err)
+		}
+	}
+	return nil
+}
+
+func (f *AccessLogFile) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// combinedLogLine formats a request in the combined log format:
+//
+//	client - user [time] "request line" status size "referer" "user agent"
+func combinedLogLine(r *http.Request, clientIP string, start time.Time, status, size int) []byte {
+	user := "-"
+	if username, _, ok := r.BasicAuth(); ok && username != "" {
+		user = username
+	}
+
+	var b strings.Builder
+	b.WriteString(clientIP)
+	b.WriteString(" - ")
+	b.WriteString(user)
+	b.WriteString(" [")
+	b.WriteString(start.Format(combinedLogTime))
+	b.WriteString("] ")
+	b.WriteString(quoteLogField(r.Method + " " + r.RequestURI + " " + r.Proto))
+	b.WriteString(" ")
+	b.WriteString(strconv.Itoa(status))
+	b.WriteString(" ")
+	b.WriteString(strconv.Itoa(size))
+	b.WriteString(" ")
+	b.WriteString(quoteLogField(r.Header.Get("Referer")))
+	b.WriteString(" ")
+	b.WriteString(quoteLogField(r.Header.Get("User-Agent")))
+	b.WriteString("\n")
+	return []byte(b.String())
+}
+
+// quoteLogField quotes a field of a log line, escaping quotes, backslashes
+// and control characters as nginx does, or returns "-" if it is empty.
+func quoteLogField(value string) string {
+	if value == "" {
+		return `"-"`
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&b, `\x%02X`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,107 @@
+package adapter
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCombinedLogLine(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions?stream=true", nil)
+	r.Header.Set("User-Agent", `curl/8.0 "quoted"`)
+	start := time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	line := combinedLogLine(r, "203.0.113.7", start, http.StatusOK, 1532)
+	assert.Equal(t, `203.0.113.7 - - [02/Jan/2025:15:04:05 +0000] "POST /v1/chat/completions?stream=true HTTP/1.1" 200 1532 "-" "curl/8.0 \"quoted\""`+"\n", string(line))
+
+	r.SetBasicAuth("alice", "secret")
+	r.Header.Set("Referer", "https://example.com/\n")
+	line = combinedLogLine(r, "203.0.113.7", start, http.StatusNotFound, 0)
+	assert.Contains(t, string(line), ` - alice [`)
+	assert.Contains(t, string(line), ` 404 0 "https://example.com/\x0A" `)
+}
+
+func TestAccessLogFile_Rotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+
+	f, err := NewAccessLogFile(path, 20, 0, 2)
+	require.NoError(t, err)
+	defer f.Close()
+
+	for _, line := range []string{"first line\n", "second line\n", "third line\n", "fourth line\n"} {
+		_, err := f.Write([]byte(line))
+		require.NoError(t, err)
+	}
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "fourth line\n", string(data))
+
+	// Only the newest two of the three rotated files are kept.
+	backups, err := filepath.Glob(path + ".*")
+	require.NoError(t, err)
+	require.Len(t, backups, 2)
+	var rotated []string
+	for _, backup := range backups {
+		data, err := os.ReadFile(backup)
+		require.NoError(t, err)
+		rotated = append(rotated, string(data))
+	}
+	assert.Equal(t, []string{"second line\n", "third line\n"}, rotated)
+}
+
+func TestAccessLogFile_Interval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	require.NoError(t, os.WriteFile(path, []byte("earlier\n"), 0o644))
+
+	f, err := NewAccessLogFile(path, 0, time.Hour, 0)
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, err = f.Write([]byte("appended\n"))
+	require.NoError(t, err)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "earlier\nappended\n", string(data))
+
+	f.opened = f.opened.Add(-time.Hour)
+	_, err = f.Write([]byte("rotated\n"))
+	require.NoError(t, err)
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "rotated\n", string(data))
+}
+
+func TestLoggingMiddleware_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	f, err := NewAccessLogFile(path, 0, 0, 0)
+	require.NoError(t, err)
+	defer f.Close()
+
+	handler, err := NewHandler(Options{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+			io.WriteString(w, "short and stout")
+		}),
+		AccessLogFile: f,
+	})
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodGet, "/teapot", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), "192.0.2.1 - - ["))
+	assert.Contains(t, string(data), `"GET /teapot HTTP/1.1" 418 15 "-" "-"`)
+}
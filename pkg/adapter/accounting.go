@@ -0,0 +1,210 @@
+package adapter
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// anonymousClient is the usage client of requests without credentials.
+const anonymousClient = "anonymous"
+
+// UsageTotals are the tokens reported by the backend for one client and
+// model.
+type UsageTotals struct {
+	Client           string `json:"client"`
+	Model            string `json:"model"`
+	Requests         int64  `json:"requests"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	ReasoningTokens  int64  `json:"reasoning_tokens"`
+	TotalTokens      int64  `json:"total_tokens"`
+}
+
+type usageKey struct {
+	client string
+	model  string
+}
+
+// UsageLedger aggregates the usage blocks of upstream responses per client
+// and model. Counters live in memory and start from zero on restart.
+type UsageLedger struct {
+	mutex  sync.Mutex
+	totals map[usageKey]*UsageTotals
+}
+
+func NewUsageLedger() *UsageLedger {
+	return &UsageLedger{totals: make(map[usageKey]*UsageTotals)}
+}
+
+func (l *UsageLedger) entry(client, model string) *UsageTotals {
+	key := usageKey{client: client, model: model}
+	totals, ok := l.totals[key]
+	if !ok {
+		totals = &UsageTotals{Client: client, Model: model}
+		l.totals[key] = totals
+	}
+	return totals
+}
+
+// AddRequest counts a request that was answered by the backend.
+func (l *UsageLedger) AddRequest(client, model string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.entry(client, model).Requests++
+}
+
+// AddUsage adds an OpenAI usage object to the totals. It reports whether the
+// object held any token counts.
+func (l *UsageLedger) AddUsage(client, model string, usage map[string]any) bool {
+	prompt := usageCount(usage, "prompt_tokens")
+	completion := usageCount(usage, "completion_tokens")
+	total := usageCount(usage, "total_tokens")
+	var reasoning int64
+	if details, ok := usage["completion_tokens_details"].(map[string]any); ok {
+		reasoning = usageCount(details, "reasoning_tokens")
+	}
+	if total == 0 {
+		total = prompt + completion
+	}
+	if total == 0 && reasoning == 0 {
+		return false
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	totals := l.entry(client, model)
+	totals.PromptTokens += prompt
+	totals.CompletionTokens += completion
+	totals.ReasoningTokens += reasoning
+	totals.TotalTokens += total
+	return true
+}
+
+// Totals returns a copy of the counters, sorted by client and model. An
+// empty client returns every client.
+func (l *UsageLedger) Totals(client string) []UsageTotals {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	out := make([]UsageTotals, 0, len(l.totals))
+	for key, totals := range l.totals {
+		if client == "" || key.client == client {
+			out = append(out, *totals)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Client != out[j].Client {
+			return out[i].Client < out[j].Client
+		}
+		return out[i].Model < out[j].Model
+	})
+	return out
+}
+
+func usageCount(usage map[string]any, field string) int64 {
+	n, _ := usage[field].(float64)
+	return int64(n)
+}
+
+// usageClient identifies the client a request's usage is accounted to: the
+// name of its key with --api-keys-file, and a hash of its API key otherwise.
+func usageClient(r *http.Request) string {
+	if key, ok := authenticatedKey(r.Context()); ok {
+		return key.Name
+	}
+	if credential := requestCredential(r); credential != "" {
+		return hashIdentity(credential)
+	}
+	return anonymousClient
+}
+
+// recordLedgerUsage accounts the usage block of a response or stream event,
+// if it has one.
+func (a *Adapter) recordLedgerUsage(chat *chatRequest, data map[string]any) {
+	if a.Ledger == nil {
+		return
+	}
+	usage, ok := data["usage"].(map[string]any)
+	if !ok {
+		return
+	}
+	if a.Ledger.AddUsage(chat.client, chat.model, usage) {
+		a.logger.DebugContext(chat.ctx, "recorded client usage", "client", chat.client, "model", chat.model, "usage", usage)
+	}
+}
+
+// requestStreamUsage asks the backend to end a stream with a usage chunk, so
+// that streamed requests can be accounted. It reports whether the client did
+// not ask for the chunk itself, in which case it must not be passed on.
+func requestStreamUsage(requestData map[string]any) bool {
+	if stream, _ := requestData["stream"].(bool); !stream {
+		return false
+	}
+
+	options, _ := requestData["stream_options"].(map[string]any)
+	if include, _ := options["include_usage"].(bool); include {
+		return false
+	}
+	if options == nil {
+		options = make(map[string]any)
+		requestData["stream_options"] = options
+	}
+	options["include_usage"] = true
+	return true
+}
+
+// hideStreamUsage removes usage the client did not ask for from a stream
+// event. It reports whether the event was modified, and whether it carries
+// nothing else and should be dropped.
+func hideStreamUsage(eventData map[string]any) (modified, drop bool) {
+	if _, ok := eventData["usage"]; !ok {
+		return false, false
+	}
+	if choices, _ := eventData["choices"].([]any); len(choices) == 0 {
+		return false, true
+	}
+	delete(eventData, "usage")
+	return true, false
+}
+
+// handleUsage serves the token usage counters. The admin token sees every
+// client; other callers see the usage of their own API key.
+func (a *Adapter) handleUsage(w http.ResponseWriter, r *http.Request) {
+	admin := a.isAdmin(r)
+	if !admin && a.APIKeys != nil {
+		var ok bool
+		if r, ok = a.authenticate(w, r); !ok {
+			return
+		}
+	}
+
+	if a.Ledger == nil {
+		a.handleDefault(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "Method not allowed", "invalid_request_error", "")
+		return
+	}
+
+	client := ""
+	if !admin {
+		client = usageClient(r)
+	}
+
+	data := a.Ledger.Totals(client)
+	if model := r.URL.Query().Get("model"); model != "" {
+		filtered := data[:0]
+		for _, totals := range data {
+			if totals.Model == model {
+				filtered = append(filtered, totals)
+			}
+		}
+		data = filtered
+	}
+
+	writeAdminJSON(w, map[string]any{"object": "list", "data": data})
+}
@@ -0,0 +1,117 @@
+package adapter
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+)
+
+func TestUsageLedger(t *testing.T) {
+	ledger := NewUsageLedger()
+
+	ledger.AddRequest("alice", "gpt-oss-20b")
+	assert.True(t, ledger.AddUsage("alice", "gpt-oss-20b", map[string]any{
+		"prompt_tokens": float64(10), "completion_tokens": float64(5), "total_tokens": float64(15),
+		"completion_tokens_details": map[string]any{"reasoning_tokens": float64(3)},
+	}))
+	assert.True(t, ledger.AddUsage("alice", "gpt-oss-20b", map[string]any{"prompt_tokens": float64(1), "completion_tokens": float64(1)}))
+	assert.False(t, ledger.AddUsage("alice", "gpt-oss-20b", map[string]any{}))
+	ledger.AddRequest("bob", "gpt-oss-120b")
+
+	assert.Equal(t, []UsageTotals{
+		{Client: "alice", Model: "gpt-oss-20b", Requests: 1, PromptTokens: 11, CompletionTokens: 6, ReasoningTokens: 3, TotalTokens: 17},
+		{Client: "bob", Model: "gpt-oss-120b", Requests: 1},
+	}, ledger.Totals(""))
+	assert.Len(t, ledger.Totals("bob"), 1)
+	assert.Empty(t, ledger.Totals("carol"))
+}
+
+func newUsageTestAdapter(t *testing.T, handler http.HandlerFunc) *Adapter {
+	upstream := httptest.NewServer(handler)
+	t.Cleanup(upstream.Close)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter(upstream.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+	adapter.Ledger = NewUsageLedger()
+	adapter.AdminToken = "secret"
+	return adapter
+}
+
+func getUsage(t *testing.T, adapter *Adapter, authorization string) []UsageTotals {
+	r := httptest.NewRequest(http.MethodGet, "/v1/usage", nil)
+	r.Header.Set("Authorization", authorization)
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var body struct {
+		Data []UsageTotals `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return body.Data
+}
+
+func TestUsageAccounting_Stream(t *testing.T) {
+	adapter := newUsageTestAdapter(t, func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		assert.Equal(t, map[string]any{"include_usage": true}, request["stream_options"])
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hi\"}}]}\n\n")
+		io.WriteString(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":7,\"completion_tokens\":2,\"total_tokens\":9}}\n\n")
+		io.WriteString(w, "data: [DONE]\n\n")
+	})
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"gpt-oss-20b","messages":[],"stream":true}`))
+	r.Header.Set("Authorization", "Bearer alice")
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, r)
+
+	// The usage chunk was only requested for accounting.
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"content":"Hi"`)
+	assert.NotContains(t, w.Body.String(), "usage")
+	assert.Contains(t, w.Body.String(), "data: [DONE]")
+
+	usage := getUsage(t, adapter, "Bearer alice")
+	require.Len(t, usage, 1)
+	assert.Equal(t, UsageTotals{Client: hashIdentity("Bearer alice"), Model: "gpt-oss-20b", Requests: 1, PromptTokens: 7, CompletionTokens: 2, TotalTokens: 9}, usage[0])
+
+	assert.Empty(t, getUsage(t, adapter, "Bearer bob"))
+	assert.Len(t, getUsage(t, adapter, "Bearer secret"), 1)
+}
+
+func TestUsageAccounting_Blocking(t *testing.T) {
+	adapter := newUsageTestAdapter(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"Hi"}}],"usage":{"prompt_tokens":7,"completion_tokens":2,"total_tokens":9}}`)
+	})
+
+	for range 2 {
+		r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"gpt-oss-20b","messages":[]}`))
+		w := httptest.NewRecorder()
+		adapter.ServeHTTP(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"usage"`)
+	}
+
+	usage := getUsage(t, adapter, "")
+	require.Len(t, usage, 1)
+	assert.Equal(t, anonymousClient, usage[0].Client)
+	assert.Equal(t, int64(2), usage[0].Requests)
+	assert.Equal(t, int64(18), usage[0].TotalTokens)
+
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, w.Body.String(), `gpt_oss_adapter_usage_tokens_total{client="anonymous",model="gpt-oss-20b"} 18`)
+}
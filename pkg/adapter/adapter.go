@@ -0,0 +1,1883 @@
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/types"
+)
+
+// Version is reported in the OpenAPI document. The command sets it to its
+// own version.
+var Version = "dev"
+
+// Cache stores reasoning by key within a namespace, which keeps the entries
+// of different tenants apart. The empty namespace is shared. Stats counts
+// lookups, inserts and evictions, to help size the cache.
+type Cache interface {
+	Put(namespace, key string, item ReasoningItem)
+	Get(namespace, key string) (ReasoningItem, bool)
+	Stats() CacheStats
+}
+
+type Adapter struct {
+	Target       string
+	Provider     types.Provider
+	Throttle     *ModelThrottle
+	Moderator    *Moderator
+	Budget       *TokenBudget
+	Usage        *UsageEstimator
+	Policy       *EffortPolicy
+	Summarizer   *Summarizer
+	Cipher       *ReasoningCipher
+	Scrubber     *Scrubber
+	Retry        *RetryPolicy
+	Queue        *RequestQueue
+	Health       *HealthChecker
+	Breaker      *CircuitBreaker
+	StreamFormat string
+
+	// FuzzyMatch also caches reasoning under a fingerprint of the tool
+	// calls' names and arguments, and falls back to it when no tool call ID
+	// matches.
+	FuzzyMatch bool
+
+	// UpstreamTimeout bounds a non-streaming chat request to the target,
+	// including reading the response. Zero disables it.
+	UpstreamTimeout time.Duration
+
+	// StreamKeepAlive is how often to send an SSE comment to a streaming
+	// client until the target starts responding. Zero disables it.
+	StreamKeepAlive time.Duration
+
+	// StreamMaxLineSize bounds a single line of an upstream event stream.
+	// Zero uses DefaultStreamMaxLineSize.
+	StreamMaxLineSize int
+
+	// StreamWriteTimeout bounds writing each chunk of a streamed response to
+	// the client, and StreamBufferSize is how many chunks may wait to be
+	// written. A client that falls behind either is cut off, and
+	// StreamOverflow selects what happens to the upstream stream:
+	// StreamOverflowDropThrough reads it to the end, and empty or
+	// StreamOverflowDisconnect cancels it. Zero disables each limit.
+	StreamWriteTimeout time.Duration
+	StreamBufferSize   int
+	StreamOverflow     string
+
+	// ReasoningFormat selects where the backend puts reasoning:
+	// ReasoningFormatThinkTags, or empty or ReasoningFormatField for the
+	// provider's reasoning field.
+	ReasoningFormat string
+
+	// DefaultReasoningEffort is requested for clients that do not request
+	// an effort themselves, after the header and the policy are applied.
+	DefaultReasoningEffort string
+
+	// EffortDowngrade lowers the reasoning effort of requests while the
+	// target is under load.
+	EffortDowngrade *EffortDowngrade
+
+	// ReasoningInjection selects which assistant messages get their
+	// reasoning restored: ReasoningInjectionSinceLastUser,
+	// ReasoningInjectionLatestOnly, or empty or ReasoningInjectionAll for
+	// every message.
+	ReasoningInjection string
+
+	// ReasoningChains keeps the ordered reasoning history of each
+	// conversation, so that reasoning is restored by the position of an
+	// assistant turn even when it has no tool calls or the client rewrote
+	// their IDs. Conversations are identified as for the token budget.
+	ReasoningChains bool
+
+	// ReasoningChainDepth limits reasoning chains to the latest turns of a
+	// conversation. Zero keeps every turn.
+	ReasoningChainDepth int
+
+	// ReasoningTokenBudget bounds the estimated tokens of the reasoning
+	// injected into a request. The oldest reasoning is dropped first to
+	// fit. Zero means no limit.
+	ReasoningTokenBudget int
+
+	// PlainTurns selects how reasoning for assistant turns without tool
+	// calls is tagged for caching. Empty or PlainTurnsOff disables it.
+	PlainTurns string
+
+	// CacheNamespace selects how requests are assigned cache namespaces:
+	// CacheNamespaceAuth, CacheNamespaceHeader, or empty for a single shared
+	// namespace. CacheNamespaceHeader names the header used in header mode.
+	CacheNamespace       string
+	CacheNamespaceHeader string
+
+	// CacheMode is the cache mode of requests without an X-GPT-OSS-Cache
+	// header: CacheModeNoStore, CacheModeBypass, or empty or CacheModeStore
+	// to look up and store reasoning.
+	CacheMode string
+
+	// HeaderRules strips and adds headers on requests to the target.
+	HeaderRules *HeaderRules
+
+	// UpstreamCompression asks the target for brotli, gzip or deflate
+	// compressed responses and decodes them.
+	UpstreamCompression bool
+
+	// StripReasoning withholds reasoning from clients. It is still cached
+	// and restored on later turns, so the backend sees it as usual.
+	StripReasoning bool
+
+	// AdminToken is the bearer token for the /admin API, which is disabled
+	// when it is empty.
+	AdminToken string
+
+	// APIKeys, if set, are required of clients. Health, metrics and admin
+	// endpoints are exempt.
+	APIKeys *APIKeys
+
+	// RateLimit limits the request rate and concurrent streams of each
+	// client.
+	RateLimit *RateLimiter
+
+	// MaxRequestSize is the largest request body accepted, in bytes. Zero
+	// means no limit.
+	MaxRequestSize int64
+
+	// Ledger accounts the token usage reported by the backend per client and
+	// model, and serves it at /v1/usage.
+	Ledger *UsageLedger
+
+	// FallbackModels renames models for the fallback target set with
+	// SetFallback, e.g. gpt-oss-120b to openai/gpt-oss-120b.
+	FallbackModels map[string]string
+
+	// Coalesce serves identical non-streaming chat requests that are in
+	// flight at the same time from a single upstream call.
+	Coalesce bool
+
+	// ResponseCache answers repeated deterministic chat requests without
+	// calling the target.
+	ResponseCache *ResponseCache
+
+	// RequestRules change the chat requests they match, after the
+	// transformers. Use CompileRequestRules to prepare them.
+	RequestRules []RequestRule
+
+	// Transformers change requests, responses and stream chunks after the
+	// built-in reasoning effort and reasoning field handling.
+	Transformers []Transformer
+
+	// EmbeddingsBatchSize splits embeddings requests with more inputs into
+	// batches of this size and merges the responses. Zero disables it.
+	EmbeddingsBatchSize int
+
+	// StructuredOutputs validates the content of chat completions against
+	// the schema of their response format, either StructuredOutputAnnotate
+	// or StructuredOutputReject. Empty disables validation.
+	StructuredOutputs string
+
+	// ToolChoiceRetries is how many times a blocking chat request whose
+	// tool_choice requires a tool call is sent again when the model
+	// answers without calling it.
+	ToolChoiceRetries int
+
+	// ToolCallRetries is how many times a blocking chat request is sent
+	// again, with a system message describing the problem, when the model
+	// calls a tool with arguments that are truncated, not JSON or do not
+	// match the tool's parameters.
+	ToolCallRetries int
+
+	// StreamStats adds the time to first token and the tokens per second of
+	// each streamed chat completion to its usage chunk.
+	StreamStats bool
+
+	// DebugTransform serves /debug/transform, which returns a chat request
+	// as it would be sent to the target without sending it.
+	DebugTransform bool
+
+	// DebugRecent keeps the last DebugRecent chat completions exchanges,
+	// before and after transformation, and serves them to admins at
+	// /debug/recent. Zero disables it.
+	DebugRecent int
+
+	inflight    *atomic.Int64
+	toolRetries *toolRetryCounts
+	tap         *tap
+	recent      *recentExchanges
+	rates       *streamRates
+	coalescer   *coalescer
+	routes      map[string]*Adapter
+	modelRoutes []modelRoute
+	fallback    *Adapter
+	replicas    []*Adapter
+	mux         *http.ServeMux
+	client      *http.Client
+	dialer      upstreamDialer
+	cache       Cache
+	logger      *slog.Logger
+}
+
+func NewAdapter(target string, cache Cache, logger *slog.Logger, provider types.Provider) *Adapter {
+	adapter := &Adapter{
+		Target:      target,
+		Provider:    provider,
+		inflight:    new(atomic.Int64),
+		toolRetries: new(toolRetryCounts),
+		tap:         newTap(),
+		recent:      newRecentExchanges(),
+		rates:       newStreamRates(),
+		coalescer:   newCoalescer(),
+		client:      &http.Client{Transport: newUpstreamTransport()},
+		cache:       cache,
+		logger:      logger,
+	}
+	adapter.mux = adapter.newMux()
+	return adapter
+}
+
+// newMux returns the mux serving a's endpoints.
+func (a *Adapter) newMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/chat/completions", a.handleChatCompletions)
+	mux.HandleFunc("/chat/completions", a.handleChatCompletions)
+	mux.HandleFunc("/v1/completions", a.handleCompletions)
+	mux.HandleFunc("/completions", a.handleCompletions)
+	mux.HandleFunc("/v1/embeddings", a.handleEmbeddings)
+	mux.HandleFunc("/embeddings", a.handleEmbeddings)
+	mux.HandleFunc("/v1/responses", a.handleResponses)
+	mux.HandleFunc("/responses", a.handleResponses)
+	mux.HandleFunc("/v1/messages", a.handleMessages)
+	mux.HandleFunc("/v1/messages/count_tokens", a.handleCountTokens)
+	mux.HandleFunc("/openapi.json", a.handleOpenAPI)
+	mux.HandleFunc("/healthz", a.handleHealthz)
+	mux.HandleFunc("/readyz", a.handleReadyz)
+	mux.HandleFunc("/metrics", a.handleMetrics)
+	mux.HandleFunc("/v1/usage", a.handleUsage)
+	mux.HandleFunc("/admin/cache", a.handleAdminCache)
+	mux.HandleFunc("/admin/cache/stats", a.handleAdminCacheStats)
+	mux.HandleFunc("/admin/cache/import", a.handleAdminCacheImport)
+	mux.HandleFunc("/admin/cache/export", a.handleAdminCacheExport)
+	mux.HandleFunc("/admin/tap", a.handleAdminTap)
+	mux.HandleFunc("/debug/transform", a.handleDebugTransform)
+	mux.HandleFunc("/debug/recent", a.handleDebugRecent)
+	mux.HandleFunc("/", a.handleDefault)
+	return mux
+}
+
+func (a *Adapter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if a.MaxRequestSize > 0 && !a.limitRequestBody(w, r) {
+		return
+	}
+
+	if a.APIKeys != nil && !isLocalPath(r.URL.Path) {
+		var ok bool
+		if r, ok = a.authenticate(w, r); !ok {
+			return
+		}
+	}
+
+	if a.RateLimit != nil && !isLocalPath(r.URL.Path) && !a.checkRateLimit(w, r) {
+		return
+	}
+
+	if (len(a.routes) > 0 || len(a.modelRoutes) > 0) && !isLocalPath(r.URL.Path) {
+		route, ok := a.route(w, r)
+		if !ok {
+			return
+		}
+		route.mux.ServeHTTP(w, r)
+		return
+	}
+	a.mux.ServeHTTP(w, r)
+}
+
+// limitRequestBody rejects requests that declare a body over MaxRequestSize
+// and caps the rest, so handlers reading the body fail once it is exceeded.
+// It returns false if the request was rejected.
+func (a *Adapter) limitRequestBody(w http.ResponseWriter, r *http.Request) bool {
+	if r.ContentLength > a.MaxRequestSize {
+		a.logger.Warn("request body too large", "path", r.URL.Path, "content_length", r.ContentLength, "limit", a.MaxRequestSize)
+		writeRequestTooLarge(w, r, a.MaxRequestSize)
+		return false
+	}
+	if r.Body != nil && r.Body != http.NoBody {
+		r.Body = http.MaxBytesReader(w, r.Body, a.MaxRequestSize)
+	}
+	return true
+}
+
+// isLocalPath reports whether path is served by the adapter itself rather
+// than a provider route.
+func isLocalPath(path string) bool {
+	return path == "/healthz" || path == "/readyz" || path == "/metrics" || path == "/v1/usage" || path == "/debug/recent" || path == "/debug/transform" || strings.HasPrefix(path, "/admin/")
+}
+
+func (a *Adapter) handleDefault(w http.ResponseWriter, r *http.Request) {
+	targetURL, err := url.Parse(a.Target)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Invalid target URL", "server_error", "")
+		return
+	}
+
+	// A body of unknown length would be streamed to the target before the
+	// limit trips, so it is read first. The limit bounds the memory used.
+	if a.MaxRequestSize > 0 && r.ContentLength < 0 && r.Body != nil && r.Body != http.NoBody {
+		requestBody, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeRequestBodyError(w, r, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(requestBody))
+		r.ContentLength = int64(len(requestBody))
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(targetURL)
+			pr.Out.URL.Path = strings.TrimSuffix(targetURL.Path, "/") + r.URL.Path
+			pr.Out.URL.RawPath = ""
+			pr.Out.URL.RawQuery = r.URL.RawQuery
+			if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+				pr.Out.Header.Set("X-Forwarded-For", xff)
+			}
+			a.rewritePassthrough(pr.Out, r)
+		},
+		Transport: a.client.Transport,
+		// Flush every write, so that streamed and chunked responses from
+		// endpoints the adapter does not know reach the client as they come.
+		FlushInterval: -1,
+		ErrorHandler:  a.handleProxyError,
+		ErrorLog:      slog.NewLogLogger(a.logger.Handler(), slog.LevelError),
+	}
+	if isUpgradeRequest(r) {
+		w = upgradeWriter{w}
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+// rewritePassthrough applies the header rules and provider headers to a
+// request passed through to the target.
+func (a *Adapter) rewritePassthrough(req *http.Request, r *http.Request) {
+	a.HeaderRules.strip(req.Header)
+
+	// Passthrough bodies are copied unchanged, so with UpstreamCompression
+	// the client's own encoding preference can be passed on as is.
+	if !a.UpstreamCompression {
+		req.Header.Del("Accept-Encoding")
+	}
+
+	a.setProviderHeaders(req)
+
+	if req.Header.Get("X-Forwarded-For") == "" {
+		if clientIP := getClientIP(r); clientIP != "" {
+			req.Header.Set("X-Forwarded-For", clientIP)
+		}
+	}
+	a.HeaderRules.set(req.Header)
+}
+
+// handleProxyError reports a passthrough request that failed before the
+// target's response could be relayed.
+func (a *Adapter) handleProxyError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		writeRequestTooLarge(w, r, tooLarge.Limit)
+	case r.Context().Err() != nil:
+		a.logger.InfoContext(r.Context(), "client disconnected before the target responded", "error", err)
+	default:
+		a.logger.ErrorContext(r.Context(), "failed to proxy request", "error", err)
+		writeError(w, r, http.StatusBadGateway, "Failed to proxy request", "server_error", "upstream_error")
+	}
+}
+
+// chatRequest carries per-request state through the chat completions pipeline.
+type chatRequest struct {
+	// ctx is the client request's context, which carries its log route.
+	ctx context.Context
+
+	data           map[string]any
+	conversationID string
+	ndjson         bool
+
+	// recent captures the exchange for /debug/recent, if it is kept.
+	recent *recentCapture
+
+	// chainTurn is the position of the assistant turn the request asks for
+	// in its conversation's reasoning chain.
+	chainTurn int
+
+	// cacheScope scopes every cache access made for the request.
+	cacheScope
+
+	// plainTurns tags assistant messages without tool calls so that their
+	// reasoning can be restored. Only chat completions clients see the tags.
+	plainTurns bool
+
+	// stripReasoning removes reasoning from chat completions sent to the
+	// client. The Responses and Messages APIs redact it in their own way.
+	stripReasoning bool
+
+	// summarize adds a reasoning_summary to chat completions, or to the
+	// messages the Responses API translates.
+	summarize bool
+
+	// client and model are what usage is accounted to. hideUsage is set
+	// when the stream usage chunk was requested for accounting only.
+	client    string
+	model     string
+	hideUsage bool
+
+	// legacyFunctions is set when the client sent the legacy functions
+	// field, so its tool calls are returned as function_call.
+	legacyFunctions bool
+
+	// grammarToolCall is set when a required tool call was forced with a
+	// response format, so the content of the response is the call.
+	grammarToolCall bool
+
+	// outputSchema is the schema of the requested response format, set when
+	// structured outputs are validated.
+	outputSchema map[string]any
+
+	// replicas are the replicas left to fail over to.
+	replicas []*Adapter
+
+	// retried is set once the request is sent again because of its tool
+	// calls, so that it is moderated and counted in usage only once.
+	retried bool
+
+	// sent is when the request was sent to the target that responded.
+	sent time.Time
+}
+
+func (a *Adapter) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	a.logger.InfoContext(r.Context(), "handling chat completions request", "method", r.Method, "path", r.URL.Path)
+
+	a.inflight.Add(1)
+	defer a.inflight.Add(-1)
+
+	_, parseSpan := tracer().Start(r.Context(), "parse request")
+	requestBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		endSpan(parseSpan, err)
+		a.logger.ErrorContext(r.Context(), "failed to read request body", "error", err)
+		writeRequestBodyError(w, r, err)
+		return
+	}
+
+	var requestData map[string]any
+	err = json.Unmarshal(requestBody, &requestData)
+	endSpan(parseSpan, err)
+	if err != nil {
+		a.logger.ErrorContext(r.Context(), "failed to unmarshal request", "error", err)
+		writeOpenAIError(w, http.StatusBadRequest, "Request body is not valid JSON", "invalid_request_error", "")
+		return
+	}
+	if requestData == nil {
+		writeOpenAIError(w, http.StatusBadRequest, "Request body must be a JSON object", "invalid_request_error", "")
+		return
+	}
+
+	chat, err := a.newChatRequest(r)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, err.Error(), "invalid_request_error", "invalid_cache_mode")
+		return
+	}
+	chat.data = requestData
+
+	w, finishRecent := a.captureRecent(w, r, chat, requestBody)
+	defer finishRecent()
+
+	serve := func(w http.ResponseWriter) {
+		a.serveChatCompletions(w, r, chat)
+	}
+	if stream, _ := requestData["stream"].(bool); !stream {
+		key := a.requestKey(r, requestBody)
+		if a.Coalesce {
+			coalesced := serve
+			serve = func(w http.ResponseWriter) { a.coalesce(w, r, key, coalesced) }
+		}
+		if a.ResponseCache != nil && !chat.noStore && deterministic(requestData) {
+			cached := serve
+			serve = func(w http.ResponseWriter) { a.cacheResponse(w, r, key, cached) }
+		}
+	}
+	serve(w)
+}
+
+// serveChatCompletions forwards a parsed chat completions request and relays
+// the response.
+func (a *Adapter) serveChatCompletions(w http.ResponseWriter, r *http.Request, chat *chatRequest) {
+	requestData := chat.data
+	release, ok := a.acquireModelSlot(w, r, requestData)
+	if !ok {
+		return
+	}
+	defer release()
+
+	releaseStream, ok := a.acquireStreamSlot(w, r, requestData)
+	if !ok {
+		return
+	}
+	defer releaseStream()
+
+	releaseQueue, ok := a.acquireQueueSlot(w, r)
+	if !ok {
+		return
+	}
+	defer releaseQueue()
+
+	chat.ndjson = a.StreamFormat == StreamFormatNDJSON || acceptsNDJSON(r)
+	chat.plainTurns = a.plainTurnsEnabled() && !chat.noStore
+	chat.stripReasoning = a.StripReasoning
+	chat.summarize = a.Summarizer != nil
+
+	w, stopKeepAlive := a.startKeepAlive(w, chat)
+	defer stopKeepAlive()
+
+	resp, upstream, ok := a.forwardWithToolRetries(w, r, chat, r.URL.Path)
+	if !ok {
+		return
+	}
+	defer resp.Body.Close()
+
+	contentType := resp.Header.Get("Content-Type")
+	a.logger.DebugContext(r.Context(), "received response", "status", resp.StatusCode, "content-type", contentType)
+
+	if strings.Contains(contentType, "text/event-stream") {
+		a.logger.DebugContext(r.Context(), "handling streaming response")
+		_, span := tracer().Start(r.Context(), "stream relay")
+		upstream.handleChatCompletionsStreaming(w, resp, chat)
+		span.End()
+	} else {
+		a.logger.DebugContext(r.Context(), "handling blocking response")
+		upstream.handleChatCompletionsBlocking(w, resp, chat)
+	}
+}
+
+// acquireModelSlot applies per-model throttling. It returns false when the
+// request was rejected; otherwise release must be called once the request
+// completes.
+func (a *Adapter) acquireModelSlot(w http.ResponseWriter, r *http.Request, requestData map[string]any) (func(), bool) {
+	if a.Throttle == nil {
+		return func() {}, true
+	}
+
+	model, _ := requestData["model"].(string)
+	release, retryAfter, ok := a.Throttle.Acquire(model)
+	if !ok {
+		a.logger.Warn("model limit exceeded", "model", model, "retry_after", retryAfter)
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(retryAfter)))
+		writeError(w, r, http.StatusTooManyRequests, fmt.Sprintf("Request limit of model %s exceeded", model), "requests", "model_limit_exceeded")
+		return nil, false
+	}
+	return release, true
+}
+
+// forwardChatRequest checks a chat completions request against moderation
+// and budgets, prepares it with prepareChatRequest and sends it to the target
+// at the given path. It
+// returns the adapter whose target responded, which is the fallback's if the
+// request failed over, and false if a response has already been written to w.
+func (a *Adapter) forwardChatRequest(w http.ResponseWriter, r *http.Request, chat *chatRequest, path string) (*http.Response, *Adapter, bool) {
+	if len(a.replicas) > 0 {
+		return a.forwardToReplica(w, r, chat, path)
+	}
+
+	requestData := chat.data
+
+	var original map[string]any
+	if a.fallback != nil || len(chat.replicas) > 0 {
+		original = deepCopyJSON(requestData).(map[string]any)
+	}
+
+	if a.Moderator != nil && !chat.retried && !a.moderateRequest(w, r, requestData) {
+		return nil, nil, false
+	}
+
+	if a.Budget != nil || a.ReasoningChains {
+		chat.conversationID = conversationID(r, requestData)
+	}
+	if a.Budget != nil && chat.conversationID != "" && !a.checkBudget(w, chat.conversationID) {
+		return nil, nil, false
+	}
+
+	requestData, path, ok := a.prepareChatRequest(w, r, chat, path)
+	if !ok {
+		return nil, nil, false
+	}
+
+	modifiedRequestBody, err := json.Marshal(requestData)
+	if err != nil {
+		a.logger.ErrorContext(r.Context(), "failed to marshal modified request", "error", err)
+		writeError(w, r, http.StatusInternalServerError, "Failed to marshal modified request", "server_error", "")
+		return nil, nil, false
+	}
+
+	targetURL, err := url.Parse(a.Target)
+	if err != nil {
+		a.logger.ErrorContext(r.Context(), "invalid target URL", "target", a.Target, "error", err)
+		writeError(w, r, http.StatusInternalServerError, "Invalid target URL", "server_error", "")
+		return nil, nil, false
+	}
+
+	targetURL.Path = strings.TrimSuffix(targetURL.Path, "/") + path
+	targetURL.RawQuery = r.URL.RawQuery
+
+	a.logger.DebugContext(r.Context(), "proxying request to target", "target", targetURL.String())
+	recordUpstreamRequest(r, targetURL.String(), modifiedRequestBody)
+	chat.recent.observeUpstream(targetURL.String(), modifiedRequestBody)
+
+	// The request is bound to the client's context, so that the target
+	// stops generating when the client goes away.
+	upstreamCtx, cancel := a.upstreamContext(r, chat)
+	req, err := http.NewRequestWithContext(upstreamCtx, r.Method, targetURL.String(), bytes.NewReader(modifiedRequestBody))
+	if err != nil {
+		cancel()
+		a.logger.ErrorContext(r.Context(), "failed to create request", "error", err)
+		writeError(w, r, http.StatusInternalServerError, "Failed to create request", "server_error", "")
+		return nil, nil, false
+	}
+
+	for name, values := range r.Header {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	a.HeaderRules.strip(req.Header)
+
+	a.setUpstreamEncoding(req)
+
+	a.setProviderHeaders(req)
+
+	if req.Header.Get("X-Forwarded-For") == "" {
+		if clientIP := getClientIP(r); clientIP != "" {
+			req.Header.Set("X-Forwarded-For", clientIP)
+		}
+	}
+	a.HeaderRules.set(req.Header)
+
+	if a.Breaker != nil {
+		if allowed, retryAfter := a.Breaker.allow(); !allowed {
+			cancel()
+			if len(chat.replicas) > 0 {
+				a.logger.WarnContext(r.Context(), "circuit open, failing over to replica", "replica", chat.replicas[0].Target)
+				return a.failOverReplica(w, r, chat, path, original)
+			}
+			if a.fallback != nil {
+				a.logger.WarnContext(r.Context(), "circuit open, failing over", "fallback", a.fallback.Target)
+				return a.failOver(w, r, chat, path, original)
+			}
+			a.writeCircuitOpen(w, r, retryAfter)
+			return nil, nil, false
+		}
+	}
+
+	ctx, upstreamSpan := tracer().Start(r.Context(), "upstream request",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(upstreamSpanAttributes(req)...),
+	)
+	injectTraceContext(ctx, req.Header)
+
+	chat.sent = time.Now()
+	resp, err := a.doWithRetry(upstreamCtx, req)
+	if err == nil {
+		upstreamSpan.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	}
+	endSpan(upstreamSpan, err)
+	if a.Breaker != nil {
+		if r.Context().Err() != nil {
+			a.Breaker.abandon()
+		} else {
+			a.Breaker.record(err == nil && resp.StatusCode < 500)
+		}
+	}
+	if err != nil {
+		cancel()
+		if r.Context().Err() == nil && len(chat.replicas) > 0 {
+			a.logger.WarnContext(r.Context(), "target failed, failing over to replica", "error", err, "replica", chat.replicas[0].Target)
+			return a.failOverReplica(w, r, chat, path, original)
+		}
+		if r.Context().Err() == nil && a.fallback != nil {
+			a.logger.WarnContext(r.Context(), "target failed, failing over", "error", err, "fallback", a.fallback.Target)
+			return a.failOver(w, r, chat, path, original)
+		}
+		switch {
+		case r.Context().Err() != nil:
+			a.logger.InfoContext(r.Context(), "client disconnected before the target responded", "error", err)
+		case isTimeout(err):
+			a.logger.ErrorContext(r.Context(), "timed out waiting for the target", "error", err)
+			writeError(w, r, http.StatusGatewayTimeout, "Timed out waiting for the target", "server_error", "upstream_timeout")
+		default:
+			a.logger.ErrorContext(r.Context(), "failed to proxy request", "error", err)
+			writeError(w, r, http.StatusBadGateway, "Failed to proxy request", "server_error", "upstream_error")
+		}
+		return nil, nil, false
+	}
+	resp.Body = cancelOnClose{resp.Body, cancel}
+
+	if resp.StatusCode >= 500 && len(chat.replicas) > 0 {
+		resp.Body.Close()
+		a.logger.WarnContext(r.Context(), "target returned server error, failing over to replica", "status", resp.StatusCode, "replica", chat.replicas[0].Target)
+		return a.failOverReplica(w, r, chat, path, original)
+	}
+
+	if resp.StatusCode >= 500 && a.fallback != nil {
+		resp.Body.Close()
+		a.logger.WarnContext(r.Context(), "target returned server error, failing over", "status", resp.StatusCode, "fallback", a.fallback.Target)
+		return a.failOver(w, r, chat, path, original)
+	}
+
+	if err := decodeResponseBody(resp); err != nil {
+		resp.Body.Close()
+		a.logger.ErrorContext(r.Context(), "failed to decode upstream response", "error", err)
+		writeError(w, r, http.StatusBadGateway, "Failed to decode upstream response", "server_error", "upstream_error")
+		return nil, nil, false
+	}
+
+	switch a.Provider.API {
+	case types.APIOllama:
+		resp = a.ollamaResponseToChat(resp)
+	case types.APIHarmony:
+		resp = a.harmonyResponseToChat(resp)
+	case types.APIResponses:
+		resp = a.responsesBackendToChat(resp)
+	}
+
+	if a.Ledger != nil && !chat.retried {
+		a.Ledger.AddRequest(chat.client, chat.model)
+	}
+
+	if a.fallback != nil {
+		w.Header().Set(backendHeader, "primary")
+	}
+
+	return resp, a, true
+}
+
+// prepareChatRequest applies the request-side transformations to a chat
+// completions request, such as restoring cached reasoning and mapping the
+// reasoning effort, and returns the body and path to send to the target. It
+// returns false if a response has already been written to w.
+func (a *Adapter) prepareChatRequest(w http.ResponseWriter, r *http.Request, chat *chatRequest, path string) (map[string]any, string, bool) {
+	requestData := chat.data
+
+	if err := a.normalizeTools(chat, requestData); err != nil {
+		a.writeTransformError(w, r, err)
+		return nil, "", false
+	}
+	if !a.transformRequest(w, r, requestData) {
+		return nil, "", false
+	}
+	a.applyRequestRules(r, requestData)
+	if a.EffortDowngrade != nil {
+		if effort, ok := a.requestedEffort(requestData).(string); ok {
+			w.Header().Set(effortResponseHeader, effort)
+		}
+	}
+
+	var snapshot []map[string]any
+	if a.ReasoningTokenBudget > 0 {
+		snapshot = snapshotAssistantMessages(requestData)
+	}
+
+	if a.Cipher != nil {
+		a.openSealedReasoning(requestData)
+	}
+
+	_, cacheSpan := tracer().Start(r.Context(), "cache lookup")
+	restored := 0
+	if chat.skipLookup {
+		stripPlainTurnTags(requestData)
+	} else {
+		restored = a.injectReasoningFromCache(chat.namespace, requestData)
+	}
+	if a.ReasoningChains && chat.conversationID != "" {
+		chat.chainTurn = countAssistantTurns(requestData)
+		if !chat.skipLookup {
+			restored += a.injectReasoningChain(chat, requestData)
+		}
+	}
+	cacheSpan.SetAttributes(attribute.Int("gpt_oss_adapter.cache.restored", restored))
+	cacheSpan.End()
+
+	if a.ReasoningTokenBudget > 0 {
+		a.enforceReasoningBudget(requestData, snapshot)
+	}
+
+	if a.thinkTagsEnabled() {
+		a.wrapThinkTags(requestData)
+	}
+
+	if len(a.Provider.Body) > 0 {
+		mergeDefaults(requestData, a.Provider.Body)
+	}
+
+	if a.StructuredOutputs != "" {
+		chat.outputSchema = outputSchema(requestData)
+	}
+	if a.Provider.ToolChoice == types.ToolChoiceGrammar {
+		chat.grammarToolCall = a.forceToolCallGrammar(requestData)
+	}
+	if a.Provider.ResponseFormat != "" {
+		a.translateResponseFormat(requestData)
+	}
+
+	if len(a.Provider.Unsupported) > 0 || len(a.Provider.Rename) > 0 {
+		a.adaptUnsupportedFields(r.Context(), requestData)
+	}
+
+	if a.Ledger != nil {
+		chat.client = usageClient(r)
+		chat.model, _ = requestData["model"].(string)
+		chat.hideUsage = requestStreamUsage(requestData)
+	}
+
+	switch a.Provider.API {
+	case types.APIOllama:
+		requestData = chatToOllamaRequest(requestData)
+		path = ollamaChatPath
+	case types.APIHarmony:
+		requestData = chatToHarmonyRequest(requestData, a.Provider.Reasoning)
+		path = harmonyCompletionsPath
+	case types.APIResponses:
+		requestData = chatToResponsesRequest(requestData, a.Provider.Reasoning)
+		path = responsesBackendPath
+	}
+
+	return requestData, path, true
+}
+
+// checkBudget enforces the conversation token budget. It returns false when
+// the request was rejected.
+func (a *Adapter) checkBudget(w http.ResponseWriter, convID string) bool {
+	if !a.Budget.Exceeded(convID) {
+		return true
+	}
+
+	used := a.Budget.Used(convID)
+	if a.Budget.Mode == BudgetModeWarn {
+		a.logger.Warn("conversation token budget exceeded", "conversation_id", convID, "used", used, "limit", a.Budget.Limit)
+		w.Header().Set("X-Conversation-Budget-Exceeded", "true")
+		return true
+	}
+
+	a.logger.Warn("rejecting request over conversation token budget", "conversation_id", convID, "used", used, "limit", a.Budget.Limit)
+	message := fmt.Sprintf("Conversation token budget of %d tokens exceeded (%d used)", a.Budget.Limit, used)
+	writeOpenAIError(w, http.StatusTooManyRequests, message, "insufficient_quota", "conversation_budget_exceeded")
+	return false
+}
+
+// recordUsage accounts the usage block of a response or stream event to the
+// client and to the conversation's budget.
+func (a *Adapter) recordUsage(chat *chatRequest, data map[string]any) {
+	a.recordLedgerUsage(chat, data)
+
+	convID := chat.conversationID
+	if a.Budget == nil || convID == "" {
+		return
+	}
+
+	if tokens := usageTotalTokens(data); tokens > 0 {
+		total := a.Budget.Add(convID, tokens)
+		a.logger.Debug("recorded conversation usage", "conversation_id", convID, "tokens", tokens, "total", total)
+	}
+}
+
+func (a *Adapter) handleChatCompletionsBlocking(w http.ResponseWriter, resp *http.Response, chat *chatRequest) {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil && isTimeout(err) {
+		a.logger.ErrorContext(chat.ctx, "timed out reading response body", "error", err)
+		writeOpenAIError(w, http.StatusGatewayTimeout, "Timed out waiting for the target", "server_error", "upstream_timeout")
+		return
+	}
+	if err != nil {
+		a.logger.ErrorContext(chat.ctx, "failed to read response body", "error", err)
+		writeOpenAIError(w, http.StatusBadGateway, "Failed to read response body", "server_error", "upstream_error")
+		return
+	}
+
+	chat.recent.observeUpstreamResponse(body)
+
+	var responseData map[string]any
+	if err := json.Unmarshal(body, &responseData); err != nil {
+		a.logger.ErrorContext(chat.ctx, "failed to unmarshal response", "error", err)
+		writeOpenAIError(w, http.StatusBadGateway, "Upstream response is not valid JSON", "server_error", "upstream_error")
+		return
+	}
+
+	if !a.processChatResponse(w, resp, chat, responseData) {
+		return
+	}
+
+	modifiedBody, err := json.Marshal(responseData)
+	if err != nil {
+		a.logger.ErrorContext(chat.ctx, "failed to marshal modified response", "error", err)
+		writeOpenAIError(w, http.StatusInternalServerError, "Failed to marshal modified response", "server_error", "")
+		return
+	}
+
+	for name, values := range resp.Header {
+		if name == "Content-Length" {
+			continue
+		}
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	w.Write(modifiedBody)
+}
+
+// processChatResponse applies the response-side transformations to a
+// blocking chat completions response. It returns false if a response has
+// already been written to w.
+func (a *Adapter) processChatResponse(w http.ResponseWriter, resp *http.Response, chat *chatRequest, responseData map[string]any) bool {
+	if a.Moderator != nil && a.Moderator.CheckResponse && !a.moderateResponse(w, resp, responseData) {
+		return false
+	}
+
+	if a.thinkTagsEnabled() {
+		a.splitThinkTags(responseData)
+	}
+
+	if chat.grammarToolCall {
+		a.grammarToolCalls(responseData)
+	}
+
+	if chat.outputSchema != nil && !a.checkStructuredOutput(w, chat, responseData) {
+		return false
+	}
+
+	if a.Usage != nil {
+		a.addSyntheticUsage(resp, chat, responseData)
+	}
+
+	a.recordUsage(chat, responseData)
+	if !chat.noStore {
+		a.extractAndCacheReasoning(chat.namespace, responseData)
+		if a.ReasoningChains && chat.conversationID != "" {
+			a.recordChainResponse(chat, responseData)
+		}
+	}
+	if chat.summarize {
+		a.addReasoningSummaries(chat, responseData)
+	}
+	if a.Cipher != nil {
+		a.sealReasoning(responseData)
+	}
+	if chat.plainTurns {
+		a.cachePlainTurns(chat.namespace, responseData)
+	}
+	a.transformResponse(chat.ctx, responseData)
+	if chat.legacyFunctions {
+		toLegacyFunctionCalls(responseData, "message")
+	}
+	if chat.stripReasoning {
+		forEachChoice(responseData, "message", func(_ int, message map[string]any) {
+			stripReasoningFields(message)
+		})
+	}
+	return true
+}
+
+// stripReasoningFields removes reasoning from a message or stream delta that
+// has been through the transformers. It reports whether anything was
+// removed.
+func stripReasoningFields(message map[string]any) bool {
+	stripped := false
+	for _, field := range []string{"reasoning", "reasoning_content", "reasoning_details"} {
+		if _, ok := message[field]; ok {
+			delete(message, field)
+			stripped = true
+		}
+	}
+	return stripped
+}
+
+func (a *Adapter) addSyntheticUsage(resp *http.Response, chat *chatRequest, responseData map[string]any) {
+	if _, ok := responseData["usage"]; ok {
+		return
+	}
+
+	choices, ok := responseData["choices"].([]any)
+	if !ok {
+		return
+	}
+
+	var completion strings.Builder
+	for _, c := range choices {
+		choice, ok := c.(map[string]any)
+		if !ok {
+			continue
+		}
+		if message, ok := choice["message"].(map[string]any); ok {
+			completion.WriteString(completionText(message, a.Provider.Reasoning))
+		}
+	}
+
+	responseData["usage"] = a.Usage.Usage(resp.Request.Context(), chat.data, completion.String())
+	a.logger.Debug("added synthetic usage", "usage", responseData["usage"])
+}
+
+func (a *Adapter) moderateRequest(w http.ResponseWriter, r *http.Request, requestData map[string]any) bool {
+	input := lastUserMessage(requestData)
+	if input == "" {
+		return true
+	}
+
+	flagged, err := a.Moderator.Check(r.Context(), input)
+	if err != nil && !a.moderationFailed(w, err) {
+		return false
+	}
+
+	return a.applyModerationVerdict(w, flagged, "Request blocked by moderation")
+}
+
+func (a *Adapter) moderateResponse(w http.ResponseWriter, resp *http.Response, responseData map[string]any) bool {
+	var texts []string
+	forEachChoice(responseData, "message", func(_ int, message map[string]any) {
+		if text := messageText(message); text != "" {
+			texts = append(texts, text)
+		}
+	})
+
+	output := strings.Join(texts, "\n")
+	if output == "" {
+		return true
+	}
+
+	flagged, err := a.Moderator.Check(resp.Request.Context(), output)
+	if err != nil && !a.moderationFailed(w, err) {
+		return false
+	}
+
+	return a.applyModerationVerdict(w, flagged, "Response blocked by moderation")
+}
+
+// moderationFailed logs a failed moderation check and, unless the moderator
+// fails open, rejects the exchange as unavailable: the outage is the
+// server's, not the client's. It returns false when the exchange was
+// rejected.
+func (a *Adapter) moderationFailed(w http.ResponseWriter, err error) bool {
+	a.logger.Error("moderation check failed", "error", err, "fail_open", a.Moderator.FailOpen)
+	if a.Moderator.FailOpen {
+		return true
+	}
+	writeOpenAIError(w, http.StatusServiceUnavailable, "Moderation service unavailable", "server_error", "moderation_unavailable")
+	return false
+}
+
+// applyModerationVerdict either rejects a flagged exchange or annotates it
+// with a header, depending on the moderation mode. It returns false when the
+// exchange was rejected.
+func (a *Adapter) applyModerationVerdict(w http.ResponseWriter, flagged bool, message string) bool {
+	if !flagged {
+		return true
+	}
+
+	if a.Moderator.Blocks() {
+		a.logger.Warn("blocked by moderation", "reason", message)
+		writeOpenAIError(w, http.StatusBadRequest, message, "invalid_request_error", "content_filter")
+		return false
+	}
+
+	a.logger.Info("flagged by moderation")
+	w.Header().Set("X-Moderation-Flagged", "true")
+	return true
+}
+
+func (a *Adapter) transformReasoningContentToReasoning(responseData map[string]any) {
+	forEachChoice(responseData, "message", func(index int, message map[string]any) {
+		if a.renameReasoningField(message) {
+			a.logger.Debug("transformed reasoning field", "choice", index, "from", a.Provider.Reasoning, "to", "reasoning")
+		}
+	})
+}
+
+// forEachChoice calls fn with the index and the given field, "message" or
+// "delta", of every choice in a chat completion or stream chunk.
+func forEachChoice(data map[string]any, field string, fn func(index int, message map[string]any)) {
+	choices, _ := data["choices"].([]any)
+	for i, c := range choices {
+		choice, ok := c.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		message, ok := choice[field].(map[string]any)
+		if !ok {
+			continue
+		}
+
+		index := i
+		if n, ok := choice["index"].(float64); ok {
+			index = int(n)
+		}
+		fn(index, message)
+	}
+}
+
+// renameReasoningField moves the provider's reasoning field in a message or
+// stream delta to "reasoning". It reports whether the message was modified.
+func (a *Adapter) renameReasoningField(message map[string]any) bool {
+	reasoningContent, ok := message[a.Provider.Reasoning].(string)
+	if !ok {
+		return false
+	}
+
+	if reasoningContent == "" && a.Provider.DropEmptyReasoning {
+		delete(message, a.Provider.Reasoning)
+		return true
+	}
+
+	if a.Provider.Reasoning == "reasoning" {
+		return false
+	}
+
+	message["reasoning"] = reasoningContent
+	delete(message, a.Provider.Reasoning)
+	return true
+}
+
+// injectReasoningFromCache restores cached reasoning to the assistant
+// messages of a request selected by the ReasoningInjection policy that carry
+// no reasoning of their own, and returns how many messages it restored.
+func (a *Adapter) injectReasoningFromCache(namespace string, requestData map[string]any) int {
+	messages, ok := requestData["messages"].([]any)
+	if !ok {
+		return 0
+	}
+
+	start := a.injectionStart(messages)
+	injectedCount := 0
+	skipped := 0
+	for i, msg := range messages {
+		message, ok := msg.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		role, ok := message["role"].(string)
+		if !ok || role != "assistant" {
+			continue
+		}
+
+		if i < start {
+			// Plain turn tags must not reach the backend either way.
+			takePlainTurnID(message)
+			skipped++
+			continue
+		}
+
+		if reasoning, _ := a.extractReasoning(message); reasoning != "" {
+			// Reasoning the client sent back takes precedence.
+			takePlainTurnID(message)
+			continue
+		}
+
+		toolCalls, ok := message["tool_calls"].([]any)
+		if !ok || len(toolCalls) == 0 {
+			if a.plainTurnsEnabled() && a.restorePlainTurn(namespace, message) {
+				injectedCount++
+			}
+			continue
+		}
+
+		injected := false
+		for _, tc := range toolCalls {
+			toolCall, ok := tc.(map[string]any)
+			if !ok {
+				continue
+			}
+
+			id, ok := toolCall["id"].(string)
+			if !ok {
+				continue
+			}
+
+			if item, found := a.cache.Get(namespace, id); found {
+				a.restoreReasoning(message, item.Content)
+				injected = true
+				injectedCount++
+				a.logger.Debug("injected reasoning content from cache", "tool_call_id", id, "field", a.Provider.Reasoning)
+				break
+			}
+		}
+
+		if injected || !a.FuzzyMatch {
+			continue
+		}
+
+		if item, found := a.cache.Get(namespace, toolCallFingerprint(toolCalls)); found {
+			a.restoreReasoning(message, item.Content)
+			injectedCount++
+			a.logger.Debug("injected reasoning content from cache by tool call content", "field", a.Provider.Reasoning)
+		}
+	}
+
+	if skipped > 0 {
+		a.logger.Debug("skipped reasoning injection for earlier assistant messages", "count", skipped, "policy", a.ReasoningInjection)
+	}
+	if injectedCount > 0 {
+		a.logger.Info("injected reasoning content", "count", injectedCount)
+	}
+	return injectedCount
+}
+
+func (a *Adapter) extractAndCacheReasoning(namespace string, responseData map[string]any) {
+	forEachChoice(responseData, "message", func(_ int, message map[string]any) {
+		toolCalls, ok := message["tool_calls"].([]any)
+		if !ok || len(toolCalls) == 0 {
+			return
+		}
+
+		reasoningContent, ok := a.extractReasoning(message)
+		if !ok {
+			return
+		}
+
+		a.cacheReasoning(namespace, toolCalls, reasoningContent)
+	})
+}
+
+// extractReasoning returns the reasoning to cache for an assistant message,
+// in whatever form the provider needs to have it injected back.
+func (a *Adapter) extractReasoning(message map[string]any) (string, bool) {
+	if a.Provider.ExtractReasoning != nil {
+		return a.Provider.ExtractReasoning(message)
+	}
+	reasoningContent, ok := message[a.Provider.Reasoning].(string)
+	return reasoningContent, ok
+}
+
+// restoreReasoning injects cached reasoning into an assistant message.
+func (a *Adapter) restoreReasoning(message map[string]any, reasoningContent string) {
+	if a.Provider.InjectReasoning != nil {
+		a.Provider.InjectReasoning(message, reasoningContent)
+		return
+	}
+	message[a.Provider.Reasoning] = reasoningContent
+}
+
+// cacheReasoning stores reasoning under the ID of every tool call it
+// produced, so it can be restored from whichever call a client echoes back.
+// With fuzzy matching it is also stored under a fingerprint of the calls'
+// names and arguments, for clients that rewrite tool call IDs.
+func (a *Adapter) cacheReasoning(namespace string, toolCalls []any, reasoningContent string) {
+	for _, id := range toolCallIDs(toolCalls) {
+		a.storeReasoning(namespace, id, ReasoningItem{
+			ID:      id,
+			Content: reasoningContent,
+		})
+		a.logger.Info("cached reasoning content", "tool_call_id", id, "content_length", len(reasoningContent))
+	}
+
+	if a.FuzzyMatch && len(toolCalls) > 0 {
+		fingerprint := toolCallFingerprint(toolCalls)
+		a.storeReasoning(namespace, fingerprint, ReasoningItem{
+			ID:      fingerprint,
+			Content: reasoningContent,
+		})
+	}
+}
+
+// toolCallFingerprint hashes the names and arguments of a message's tool
+// calls. Arguments are re-encoded first so that differences in whitespace or
+// key order do not change the fingerprint.
+func toolCallFingerprint(toolCalls []any) string {
+	hash := sha256.New()
+	for _, tc := range toolCalls {
+		toolCall, _ := tc.(map[string]any)
+		function, _ := toolCall["function"].(map[string]any)
+		name, _ := function["name"].(string)
+		arguments, _ := function["arguments"].(string)
+
+		var decoded any
+		if err := json.Unmarshal([]byte(arguments), &decoded); err == nil {
+			if canonical, err := json.Marshal(decoded); err == nil {
+				arguments = string(canonical)
+			}
+		}
+
+		fmt.Fprintf(hash, "%s\x00%s\x00", name, arguments)
+	}
+	return "toolcalls:" + hex.EncodeToString(hash.Sum(nil))
+}
+
+// toolCallIDs returns the IDs of the given tool calls, skipping any without
+// one, such as stream deltas that continue an earlier call.
+func toolCallIDs(toolCalls []any) []string {
+	var ids []string
+	for _, tc := range toolCalls {
+		toolCall, ok := tc.(map[string]any)
+		if !ok {
+			continue
+		}
+		if id, ok := toolCall["id"].(string); ok && id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (a *Adapter) handleChatCompletionsStreaming(w http.ResponseWriter, resp *http.Response, chat *chatRequest) {
+	a.logger.DebugContext(chat.ctx, "starting streaming response processing")
+
+	for name, values := range resp.Header {
+		if name == "Content-Length" {
+			continue
+		}
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+	if chat.ndjson {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	w.WriteHeader(resp.StatusCode)
+
+	w, stopWriter := a.startStreamWriter(w, resp, chat)
+	defer stopWriter()
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		a.logger.WarnContext(chat.ctx, "response writer does not support flushing, falling back to simple copy")
+		io.Copy(w, resp.Body)
+		return
+	}
+
+	a.relayChatStream(resp, chat, func(line string) {
+		if chat.ndjson {
+			writeNDJSONLine(w, line)
+		} else {
+			w.Write([]byte(line + "\n"))
+		}
+		flusher.Flush()
+	})
+
+	a.logger.DebugContext(chat.ctx, "completed streaming response processing")
+}
+
+// relayChatStream reads a chat completions event stream from the target,
+// caching reasoning and recording usage as it goes, and passes each
+// transformed SSE line to emit.
+func (a *Adapter) relayChatStream(resp *http.Response, chat *chatRequest, emit func(line string)) {
+	reader := newSSEReader(resp.Body, a.StreamMaxLineSize)
+	reasoning := make(map[int]*choiceReasoning)
+	parsers := make(map[int]types.StreamParser)
+	var usage streamUsage
+	var header map[string]any
+	timing := streamTiming{sent: chat.sent}
+	done := false
+	var grammarContent map[int]*strings.Builder
+	if chat.grammarToolCall {
+		grammarContent = make(map[int]*strings.Builder)
+	}
+	var output streamedOutput
+	if chat.outputSchema != nil {
+		output = make(streamedOutput)
+	}
+	var turn *streamedTurn
+	if a.ReasoningChains && chat.conversationID != "" && !chat.noStore {
+		turn = new(streamedTurn)
+	}
+
+	tapped := a.tap.open(chat)
+	if tapped != nil {
+		defer tapped.close()
+		emit = tapped.wrap(emit)
+	}
+
+	for {
+		event, err := reader.Next()
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				a.logger.InfoContext(chat.ctx, "client disconnected, cancelled upstream stream")
+			} else if !errors.Is(err, io.EOF) {
+				a.logger.ErrorContext(chat.ctx, "upstream stream interrupted", "error", err)
+				if !done {
+					emitStreamError(emit, err)
+				}
+			}
+			break
+		}
+
+		if tapped != nil && event.HasData {
+			tapped.publish(tapEvent{Kind: "upstream", Data: event.Data})
+		}
+		if event.HasData {
+			chat.recent.observeUpstreamEvent(event.Data)
+		}
+
+		if event.HasData && event.Data == "[DONE]" {
+			done = true
+			if output != nil {
+				a.checkStreamedOutput(chat, output, emit)
+			}
+			if chat.summarize {
+				a.emitReasoningSummaries(chat, header, reasoning, emit)
+			}
+			if a.Usage != nil && !usage.seen {
+				a.emitSyntheticUsage(resp, chat, &usage, &timing, emit)
+			}
+			if a.StreamStats {
+				a.emitStreamStats(&timing, &usage, emit)
+			}
+			a.logger.DebugContext(chat.ctx, "received [DONE] event, finalizing stream")
+			a.cacheStreamReasoning(chat, reasoning)
+		} else if event.HasData {
+			var eventData map[string]any
+			if err := json.Unmarshal([]byte(event.Data), &eventData); err == nil {
+				modified := a.parseStreamDeltas(eventData, parsers)
+				if grammarContent != nil && grammarToolCallChunk(eventData, grammarContent) {
+					modified = true
+				}
+				if output != nil {
+					output.observe(eventData)
+				}
+				if chat.summarize {
+					header = streamHeader(eventData)
+				}
+
+				if a.Usage != nil || a.StreamStats {
+					usage.observe(eventData, a.Provider.Reasoning)
+				}
+				timing.observe(eventData, a.Provider.Reasoning)
+
+				a.recordUsage(chat, eventData)
+				a.processStreamingDelta(eventData, reasoning)
+				if turn != nil {
+					a.observeChainChunk(chat, eventData, reasoning, turn)
+				}
+				if a.Cipher != nil && a.sealStreamedReasoning(eventData, reasoning) {
+					modified = true
+				}
+
+				if chat.hideUsage {
+					hidden, drop := hideStreamUsage(eventData)
+					if drop {
+						continue
+					}
+					if hidden {
+						modified = true
+					}
+				}
+
+				if chat.plainTurns && a.tagStreamedPlainTurns(chat.namespace, eventData, reasoning) {
+					modified = true
+				}
+				if a.transformChunk(chat.ctx, eventData) {
+					modified = true
+				}
+				if chat.legacyFunctions && toLegacyFunctionCalls(eventData, "delta") {
+					modified = true
+				}
+				if a.StreamStats && timing.attach(eventData) {
+					modified = true
+				}
+				if chat.stripReasoning {
+					forEachChoice(eventData, "delta", func(_ int, delta map[string]any) {
+						if stripReasoningFields(delta) {
+							modified = true
+						}
+					})
+				}
+				if modified {
+					if modifiedData, err := json.Marshal(eventData); err == nil {
+						event.Data = string(modifiedData)
+					}
+				}
+			}
+		}
+
+		a.logger.DebugContext(chat.ctx, "relaying stream event", "event", event.Event, "size", len(event.Data))
+		for _, line := range event.lines() {
+			emit(line)
+		}
+	}
+
+	if reader.repaired > 0 {
+		a.logger.WarnContext(chat.ctx, "repaired stream events split by the target", "count", reader.repaired)
+	}
+	a.rates.record(a.Target, &timing)
+	if a.EffortDowngrade != nil && !timing.firstToken.IsZero() {
+		a.EffortDowngrade.Observe(timing.timeToFirstToken())
+	}
+	a.cacheStreamReasoning(chat, reasoning)
+}
+
+// choiceReasoning accumulates the reasoning and tool calls streamed for a
+// single choice. Reasoning is collected in content, or in message for
+// providers that merge deltas themselves.
+type choiceReasoning struct {
+	content   strings.Builder
+	message   map[string]any
+	toolCalls []*streamToolCall
+}
+
+type streamToolCall struct {
+	index     int
+	id        string
+	name      string
+	arguments strings.Builder
+}
+
+// addToolCallDeltas merges tool call deltas into the calls seen so far.
+// Deltas after the first for a call carry only its index and a fragment of
+// the arguments.
+func (c *choiceReasoning) addToolCallDeltas(deltas []any) {
+	for i, d := range deltas {
+		delta, ok := d.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		index := i
+		if n, ok := delta["index"].(float64); ok {
+			index = int(n)
+		}
+
+		var call *streamToolCall
+		for _, existing := range c.toolCalls {
+			if existing.index == index {
+				call = existing
+				break
+			}
+		}
+		if call == nil {
+			call = &streamToolCall{index: index}
+			c.toolCalls = append(c.toolCalls, call)
+		}
+
+		if id, ok := delta["id"].(string); ok && id != "" {
+			call.id = id
+		}
+		if function, ok := delta["function"].(map[string]any); ok {
+			if name, ok := function["name"].(string); ok {
+				call.name += name
+			}
+			if arguments, ok := function["arguments"].(string); ok {
+				call.arguments.WriteString(arguments)
+			}
+		}
+	}
+}
+
+// toolCallList returns the accumulated tool calls in chat message format.
+func (c *choiceReasoning) toolCallList() []any {
+	toolCalls := make([]any, 0, len(c.toolCalls))
+	for _, call := range c.toolCalls {
+		toolCall := map[string]any{
+			"function": map[string]any{
+				"name":      call.name,
+				"arguments": call.arguments.String(),
+			},
+		}
+		if call.id != "" {
+			toolCall["id"] = call.id
+		}
+		toolCalls = append(toolCalls, toolCall)
+	}
+	return toolCalls
+}
+
+// cacheStreamReasoning caches the reasoning accumulated for each choice,
+// unless the client asked for it not to be stored, and resets the
+// accumulators so a stream is not cached twice.
+func (a *Adapter) cacheStreamReasoning(chat *chatRequest, reasoning map[int]*choiceReasoning) {
+	for index, choice := range reasoning {
+		if reasoningContent, ok := a.streamedReasoning(choice); ok && reasoningContent != "" && !chat.noStore {
+			a.cacheReasoning(chat.namespace, choice.toolCallList(), reasoningContent)
+		}
+		delete(reasoning, index)
+	}
+}
+
+// streamedReasoning returns the reasoning to cache for a streamed choice.
+func (a *Adapter) streamedReasoning(choice *choiceReasoning) (string, bool) {
+	if choice.message != nil {
+		return a.extractReasoning(choice.message)
+	}
+	if choice.content.Len() == 0 {
+		return "", false
+	}
+	return a.extractReasoning(map[string]any{a.Provider.Reasoning: choice.content.String()})
+}
+
+func (a *Adapter) emitSyntheticUsage(resp *http.Response, chat *chatRequest, usage *streamUsage, timing *streamTiming, emit func(line string)) {
+	chunk := usage.chunk(a.Usage.Usage(resp.Request.Context(), chat.data, usage.completion.String()))
+	if a.StreamStats {
+		timing.attach(chunk)
+	}
+
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		a.logger.Error("failed to marshal synthetic usage chunk", "error", err)
+		return
+	}
+
+	emit("data: " + string(data))
+	emit("")
+	a.recordUsage(chat, chunk)
+	a.logger.Debug("emitted synthetic usage chunk", "usage", chunk["usage"])
+}
+
+// parseStreamDeltas runs a stream parser, one per choice, over the deltas of
+// a stream chunk. It reports whether any delta was parsed.
+func (a *Adapter) parseStreamDeltas(eventData map[string]any, parsers map[int]types.StreamParser) bool {
+	parsed := false
+	choices, _ := eventData["choices"].([]any)
+	for i, c := range choices {
+		choice, ok := c.(map[string]any)
+		if !ok {
+			continue
+		}
+		delta, ok := choice["delta"].(map[string]any)
+		if !ok {
+			continue
+		}
+
+		index := i
+		if n, ok := choice["index"].(float64); ok {
+			index = int(n)
+		}
+		parser, ok := parsers[index]
+		if !ok {
+			if parser = a.newStreamParser(); parser == nil {
+				return false
+			}
+			parsers[index] = parser
+		}
+
+		parser.Parse(delta)
+		if finishReason, _ := choice["finish_reason"].(string); finishReason != "" {
+			parser.Flush(delta)
+		}
+		parsed = true
+	}
+	return parsed
+}
+
+// transformStreamingEvent renames the reasoning field in every delta of a
+// stream chunk. It reports whether the chunk was modified.
+func (a *Adapter) transformStreamingEvent(eventData map[string]any) bool {
+	modified := false
+	forEachChoice(eventData, "delta", func(_ int, delta map[string]any) {
+		if a.renameReasoningField(delta) {
+			modified = true
+		}
+	})
+	return modified
+}
+
+func (a *Adapter) processStreamingDelta(eventData map[string]any, reasoning map[int]*choiceReasoning) {
+	forEachChoice(eventData, "delta", func(index int, delta map[string]any) {
+		choice, ok := reasoning[index]
+		if !ok {
+			choice = &choiceReasoning{}
+			reasoning[index] = choice
+		}
+
+		if a.Provider.MergeReasoningDelta != nil {
+			if choice.message == nil {
+				choice.message = make(map[string]any)
+			}
+			a.Provider.MergeReasoningDelta(choice.message, delta)
+		} else if reasoningDelta, ok := delta[a.Provider.Reasoning].(string); ok {
+			choice.content.WriteString(reasoningDelta)
+		}
+
+		if toolCalls, ok := delta["tool_calls"].([]any); ok {
+			choice.addToolCallDeltas(toolCalls)
+		}
+	})
+}
+
+func (a *Adapter) injectReasoningEffort(requestData map[string]any) {
+	if a.Provider.ReasoningEffort == "" {
+		return
+	}
+
+	if a.Provider.ReasoningEffort == "reasoning.effort" {
+		return
+	}
+
+	reasoningEffort := a.getNestedField(requestData, "reasoning.effort")
+	if reasoningEffort == nil {
+		return
+	}
+
+	a.setNestedField(requestData, a.Provider.ReasoningEffort, reasoningEffort)
+	a.deleteNestedField(requestData, "reasoning.effort")
+	a.logger.Debug("injected reasoning effort", "field", a.Provider.ReasoningEffort, "value", reasoningEffort)
+}
+
+// mergeDefaults sets the fields of defaults that data does not have,
+// merging objects present in both key by key.
+func mergeDefaults(data, defaults map[string]any) {
+	for key, value := range defaults {
+		existing, ok := data[key]
+		if !ok {
+			data[key] = deepCopyJSON(value)
+			continue
+		}
+		existingMap, ok := existing.(map[string]any)
+		if valueMap, isMap := value.(map[string]any); ok && isMap {
+			mergeDefaults(existingMap, valueMap)
+		}
+	}
+}
+
+// adaptUnsupportedFields renames the request fields the provider knows by
+// another name and removes those it rejects, so that clients sending them
+// do not get a 400 from a strict backend.
+func (a *Adapter) adaptUnsupportedFields(ctx context.Context, requestData map[string]any) {
+	for _, from := range slices.Sorted(maps.Keys(a.Provider.Rename)) {
+		value := a.getNestedField(requestData, from)
+		if value == nil {
+			continue
+		}
+		a.deleteNestedField(requestData, from)
+		to := a.Provider.Rename[from]
+		if a.getNestedField(requestData, to) == nil {
+			a.setNestedField(requestData, to, value)
+		}
+		a.logger.DebugContext(ctx, "renamed unsupported request field", "field", from, "to", to)
+	}
+
+	for _, field := range a.Provider.Unsupported {
+		if a.getNestedField(requestData, field) != nil {
+			a.logger.DebugContext(ctx, "dropped unsupported request field", "field", field)
+		}
+		a.deleteNestedField(requestData, field)
+	}
+}
+
+// deepCopyJSON copies the objects and arrays of a decoded JSON or YAML
+// value, so that a request can modify it without affecting the original.
+func deepCopyJSON(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		copied := make(map[string]any, len(v))
+		for key, item := range v {
+			copied[key] = deepCopyJSON(item)
+		}
+		return copied
+	case []any:
+		copied := make([]any, len(v))
+		for i, item := range v {
+			copied[i] = deepCopyJSON(item)
+		}
+		return copied
+	default:
+		return v
+	}
+}
+
+func (a *Adapter) applyEffortPolicy(r *http.Request, requestData map[string]any) {
+	requested := a.requestedEffort(requestData)
+	if requested != nil && !a.Policy.Override {
+		return
+	}
+
+	model, _ := requestData["model"].(string)
+	tools, _ := requestData["tools"].([]any)
+	signals := EffortSignals{
+		Model:        model,
+		HasTools:     len(tools) > 0,
+		PromptTokens: estimateTokens(promptText(requestData)),
+		APIKey:       bearerToken(r.Header.Get("Authorization")),
+		Time:         time.Now(),
+		InFlight:     int(a.inflight.Load()),
+	}
+
+	effort, rule := a.Policy.Choose(signals)
+	if effort == "" {
+		return
+	}
+
+	if a.Provider.ReasoningEffort != "" {
+		a.deleteNestedField(requestData, a.Provider.ReasoningEffort)
+	}
+	a.setNestedField(requestData, "reasoning.effort", effort)
+	a.logger.Debug("applied reasoning effort policy", "effort", effort, "rule", rule.Source, "requested", requested)
+}
+
+func (a *Adapter) getNestedField(data map[string]any, path string) any {
+	parts := strings.Split(path, ".")
+	current := data
+
+	for i, part := range parts {
+		if i == len(parts)-1 {
+			return current[part]
+		}
+
+		if next, ok := current[part].(map[string]any); ok {
+			current = next
+		} else {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (a *Adapter) setNestedField(data map[string]any, path string, value any) {
+	parts := strings.Split(path, ".")
+	current := data
+
+	for i, part := range parts {
+		if i == len(parts)-1 {
+			current[part] = value
+			return
+		}
+
+		if _, exists := current[part]; !exists {
+			current[part] = make(map[string]any)
+		}
+
+		if next, ok := current[part].(map[string]any); ok {
+			current = next
+		} else {
+			return
+		}
+	}
+}
+
+func (a *Adapter) deleteNestedField(data map[string]any, path string) {
+	parts := strings.Split(path, ".")
+	if len(parts) == 1 {
+		delete(data, parts[0])
+		return
+	}
+
+	current := data
+	for _, part := range parts[:len(parts)-1] {
+		if next, ok := current[part].(map[string]any); ok {
+			current = next
+		} else {
+			return
+		}
+	}
+
+	delete(current, parts[len(parts)-1])
+}
@@ -0,0 +1,552 @@
+package adapter
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+	"github.com/aldehir/gpt-oss-adapter/providers/lmstudio"
+	"github.com/aldehir/gpt-oss-adapter/providers/openrouter"
+)
+
+func newTestAdapter() *Adapter {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewAdapter("http://localhost:8080", NewLRUCache(10), logger, llamacpp.NewProvider())
+}
+
+func TestExtractAndCacheReasoning_ParallelToolCalls(t *testing.T) {
+	adapter := newTestAdapter()
+
+	adapter.extractAndCacheReasoning("", map[string]any{
+		"choices": []any{map[string]any{
+			"message": map[string]any{
+				"role":              "assistant",
+				"reasoning_content": "Check both cities.",
+				"tool_calls": []any{
+					map[string]any{"id": "call_1", "function": map[string]any{"name": "get_weather"}},
+					map[string]any{"id": "call_2", "function": map[string]any{"name": "get_weather"}},
+				},
+			},
+		}},
+	})
+
+	for _, id := range []string{"call_1", "call_2"} {
+		item, found := adapter.cache.Get("", id)
+		assert.True(t, found, id)
+		assert.Equal(t, "Check both cities.", item.Content)
+	}
+}
+
+func TestInjectReasoningFromCache_AnyToolCall(t *testing.T) {
+	adapter := newTestAdapter()
+	adapter.cache.Put("", "call_2", ReasoningItem{ID: "call_2", Content: "Check both cities."})
+
+	request := map[string]any{
+		"messages": []any{
+			map[string]any{"role": "user", "content": "Weather in Paris and Rome?"},
+			map[string]any{
+				"role": "assistant",
+				"tool_calls": []any{
+					map[string]any{"id": "call_1"},
+					map[string]any{"id": "call_2"},
+				},
+			},
+		},
+	}
+	adapter.injectReasoningFromCache("", request)
+
+	message := request["messages"].([]any)[1].(map[string]any)
+	assert.Equal(t, "Check both cities.", message["reasoning_content"])
+}
+
+func TestRelayChatStream_ParallelToolCalls(t *testing.T) {
+	adapter := newTestAdapter()
+
+	stream := strings.Join([]string{
+		`data: {"choices":[{"index":0,"delta":{"reasoning_content":"Check "}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"reasoning_content":"both."}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"get_weather","arguments":""}}]}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{}"}}]}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_2","function":{"name":"get_weather","arguments":"{}"}}]}}]}`,
+		`data: [DONE]`,
+		``,
+	}, "\n\n")
+
+	req, _ := http.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	resp := &http.Response{Body: io.NopCloser(strings.NewReader(stream)), Request: req}
+	adapter.relayChatStream(resp, &chatRequest{data: map[string]any{}}, func(string) {})
+
+	for _, id := range []string{"call_1", "call_2"} {
+		item, found := adapter.cache.Get("", id)
+		assert.True(t, found, id)
+		assert.Equal(t, "Check both.", item.Content)
+	}
+}
+
+func TestTransformReasoningContentToReasoning_AllChoices(t *testing.T) {
+	adapter := newTestAdapter()
+
+	response := map[string]any{
+		"choices": []any{
+			map[string]any{"index": float64(0), "message": map[string]any{"reasoning_content": "first"}},
+			map[string]any{"index": float64(1), "message": map[string]any{"reasoning_content": "second"}},
+		},
+	}
+	adapter.transformReasoningContentToReasoning(response)
+
+	choices := response["choices"].([]any)
+	assert.Equal(t, map[string]any{"reasoning": "first"}, choices[0].(map[string]any)["message"])
+	assert.Equal(t, map[string]any{"reasoning": "second"}, choices[1].(map[string]any)["message"])
+}
+
+func TestRelayChatStream_MultipleChoices(t *testing.T) {
+	adapter := newTestAdapter()
+
+	stream := strings.Join([]string{
+		`data: {"choices":[{"index":0,"delta":{"reasoning_content":"First "}},{"index":1,"delta":{"reasoning_content":"Second "}}]}`,
+		`data: {"choices":[{"index":1,"delta":{"reasoning_content":"choice."}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"reasoning_content":"choice."}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_a","function":{"name":"f","arguments":"{}"}}]}},{"index":1,"delta":{"tool_calls":[{"index":0,"id":"call_b","function":{"name":"f","arguments":"{}"}}]}}]}`,
+		`data: [DONE]`,
+		``,
+	}, "\n\n")
+
+	req, _ := http.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	resp := &http.Response{Body: io.NopCloser(strings.NewReader(stream)), Request: req}
+
+	var lines []string
+	adapter.relayChatStream(resp, &chatRequest{data: map[string]any{}}, func(line string) {
+		lines = append(lines, line)
+	})
+
+	assert.Contains(t, lines[0], `{"delta":{"reasoning":"First "},"index":0}`)
+	assert.Contains(t, lines[0], `{"delta":{"reasoning":"Second "},"index":1}`)
+
+	item, _ := adapter.cache.Get("", "call_a")
+	assert.Equal(t, "First choice.", item.Content)
+	item, _ = adapter.cache.Get("", "call_b")
+	assert.Equal(t, "Second choice.", item.Content)
+}
+
+func TestRelayChatStream_LargeLine(t *testing.T) {
+	adapter := newTestAdapter()
+	arguments := strings.Repeat("x", 256*1024)
+
+	stream := strings.Join([]string{
+		`data: {"choices":[{"index":0,"delta":{"reasoning_content":"Write it."}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"write","arguments":"` + arguments + `"}}]}}]}`,
+		`data: [DONE]`,
+		``,
+	}, "\n\n")
+
+	req, _ := http.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	resp := &http.Response{Body: io.NopCloser(strings.NewReader(stream)), Request: req}
+
+	var lines []string
+	adapter.relayChatStream(resp, &chatRequest{data: map[string]any{}}, func(line string) {
+		lines = append(lines, line)
+	})
+
+	assert.Contains(t, lines, "data: [DONE]")
+	item, found := adapter.cache.Get("", "call_1")
+	assert.True(t, found)
+	assert.Equal(t, "Write it.", item.Content)
+}
+
+func TestFuzzyMatch(t *testing.T) {
+	adapter := newTestAdapter()
+	adapter.FuzzyMatch = true
+
+	adapter.extractAndCacheReasoning("", map[string]any{
+		"choices": []any{map[string]any{
+			"message": map[string]any{
+				"role":              "assistant",
+				"reasoning_content": "Look up Paris.",
+				"tool_calls": []any{
+					map[string]any{"id": "call_1", "function": map[string]any{"name": "get_weather", "arguments": `{"city":"Paris","units":"c"}`}},
+				},
+			},
+		}},
+	})
+
+	request := map[string]any{
+		"messages": []any{
+			map[string]any{
+				"role": "assistant",
+				"tool_calls": []any{
+					map[string]any{"id": "rewritten", "function": map[string]any{"name": "get_weather", "arguments": `{"units": "c", "city": "Paris"}`}},
+				},
+			},
+			map[string]any{
+				"role": "assistant",
+				"tool_calls": []any{
+					map[string]any{"id": "other", "function": map[string]any{"name": "get_weather", "arguments": `{"city":"Rome"}`}},
+				},
+			},
+		},
+	}
+	adapter.injectReasoningFromCache("", request)
+
+	messages := request["messages"].([]any)
+	assert.Equal(t, "Look up Paris.", messages[0].(map[string]any)["reasoning_content"])
+	assert.NotContains(t, messages[1], "reasoning_content")
+}
+
+func TestFuzzyMatch_Stream(t *testing.T) {
+	adapter := newTestAdapter()
+	adapter.FuzzyMatch = true
+
+	stream := strings.Join([]string{
+		`data: {"choices":[{"index":0,"delta":{"reasoning_content":"Look up Paris."}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"get_weather","arguments":"{\"city\":"}}]}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}"}}]}}]}`,
+		`data: [DONE]`,
+		``,
+	}, "\n\n")
+
+	req, _ := http.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	resp := &http.Response{Body: io.NopCloser(strings.NewReader(stream)), Request: req}
+	adapter.relayChatStream(resp, &chatRequest{data: map[string]any{}}, func(string) {})
+
+	item, found := adapter.cache.Get("", toolCallFingerprint([]any{
+		map[string]any{"function": map[string]any{"name": "get_weather", "arguments": `{"city": "Paris"}`}},
+	}))
+	assert.True(t, found)
+	assert.Equal(t, "Look up Paris.", item.Content)
+}
+
+func TestFuzzyMatch_Disabled(t *testing.T) {
+	adapter := newTestAdapter()
+
+	toolCalls := []any{map[string]any{"id": "call_1", "function": map[string]any{"name": "f", "arguments": "{}"}}}
+	adapter.cacheReasoning("", toolCalls, "thoughts")
+
+	_, found := adapter.cache.Get("", toolCallFingerprint(toolCalls))
+	assert.False(t, found)
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, io.ErrUnexpectedEOF
+}
+
+func TestRelayChatStream_Interrupted(t *testing.T) {
+	adapter := newTestAdapter()
+
+	stream := `data: {"choices":[{"index":0,"delta":{"content":"Hel"}}]}` + "\n\n"
+	req, _ := http.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	resp := &http.Response{Body: io.NopCloser(io.MultiReader(strings.NewReader(stream), failingReader{})), Request: req}
+
+	var lines []string
+	adapter.relayChatStream(resp, &chatRequest{data: map[string]any{}}, func(line string) {
+		lines = append(lines, line)
+	})
+
+	require.Len(t, lines, 6)
+	assert.Contains(t, lines[2], `"code":"stream_interrupted"`)
+	assert.Equal(t, []string{"", "data: [DONE]", ""}, lines[3:])
+}
+
+func TestOpenRouter_ReasoningDetails(t *testing.T) {
+	adapter := newTestAdapter()
+	adapter.Provider = openrouter.NewProvider()
+
+	details := []any{
+		map[string]any{"type": "reasoning.text", "text": "Look up Paris.", "signature": "sig", "format": "anthropic-claude-v1", "index": float64(0)},
+		map[string]any{"type": "reasoning.encrypted", "data": "opaque", "format": "anthropic-claude-v1", "index": float64(1)},
+	}
+	adapter.extractAndCacheReasoning("", map[string]any{
+		"choices": []any{map[string]any{
+			"message": map[string]any{
+				"role":              "assistant",
+				"reasoning":         "Look up Paris.",
+				"reasoning_details": details,
+				"tool_calls":        []any{map[string]any{"id": "call_1", "function": map[string]any{"name": "get_weather", "arguments": "{}"}}},
+			},
+		}},
+	})
+
+	message := map[string]any{
+		"role":       "assistant",
+		"tool_calls": []any{map[string]any{"id": "call_1", "function": map[string]any{"name": "get_weather", "arguments": "{}"}}},
+	}
+	adapter.injectReasoningFromCache("", map[string]any{"messages": []any{message}})
+
+	assert.Equal(t, "Look up Paris.", message["reasoning"])
+	assert.Equal(t, details, message["reasoning_details"])
+}
+
+func TestOpenRouter_PlainReasoning(t *testing.T) {
+	adapter := newTestAdapter()
+	adapter.Provider = openrouter.NewProvider()
+
+	toolCalls := []any{map[string]any{"id": "call_1", "function": map[string]any{"name": "f", "arguments": "{}"}}}
+	adapter.extractAndCacheReasoning("", map[string]any{
+		"choices": []any{map[string]any{
+			"message": map[string]any{"role": "assistant", "reasoning": "thoughts", "tool_calls": toolCalls},
+		}},
+	})
+
+	message := map[string]any{"role": "assistant", "tool_calls": toolCalls}
+	adapter.injectReasoningFromCache("", map[string]any{"messages": []any{message}})
+
+	assert.Equal(t, "thoughts", message["reasoning"])
+	assert.NotContains(t, message, "reasoning_details")
+}
+
+func TestOpenRouter_StreamReasoningDetails(t *testing.T) {
+	adapter := newTestAdapter()
+	adapter.Provider = openrouter.NewProvider()
+
+	stream := strings.Join([]string{
+		`data: {"choices":[{"index":0,"delta":{"reasoning":"Look ","reasoning_details":[{"type":"reasoning.text","text":"Look ","format":"unknown","index":0}]}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"reasoning":"up Paris.","reasoning_details":[{"type":"reasoning.text","text":"up Paris.","format":"unknown","index":0}]}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"reasoning_details":[{"type":"reasoning.encrypted","data":"opaque","format":"unknown","index":1}]}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"get_weather","arguments":"{}"}}]}}]}`,
+		`data: [DONE]`,
+		``,
+	}, "\n\n")
+
+	req, _ := http.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	resp := &http.Response{Body: io.NopCloser(strings.NewReader(stream)), Request: req}
+	adapter.relayChatStream(resp, &chatRequest{data: map[string]any{}}, func(string) {})
+
+	message := map[string]any{
+		"role":       "assistant",
+		"tool_calls": []any{map[string]any{"id": "call_1", "function": map[string]any{"name": "get_weather", "arguments": "{}"}}},
+	}
+	adapter.injectReasoningFromCache("", map[string]any{"messages": []any{message}})
+
+	assert.Equal(t, "Look up Paris.", message["reasoning"])
+	assert.Equal(t, []any{
+		map[string]any{"type": "reasoning.text", "text": "Look up Paris.", "format": "unknown", "index": float64(0)},
+		map[string]any{"type": "reasoning.encrypted", "data": "opaque", "format": "unknown", "index": float64(1)},
+	}, message["reasoning_details"])
+}
+
+func TestLMStudio_StreamThinkTags(t *testing.T) {
+	adapter := newTestAdapter()
+	adapter.Provider = lmstudio.NewProvider()
+
+	stream := strings.Join([]string{
+		`data: {"choices":[{"index":0,"delta":{"role":"assistant","content":"<thi"}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"content":"nk>Check the "}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"content":"weather.</th"}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"content":"ink>\n\n"}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"content":"Calling a tool <"}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"get_weather","arguments":"{}"}}]},"finish_reason":"tool_calls"}]}`,
+		`data: [DONE]`,
+		``,
+	}, "\n\n")
+
+	req, _ := http.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	resp := &http.Response{Body: io.NopCloser(strings.NewReader(stream)), Request: req}
+
+	var lines []string
+	adapter.relayChatStream(resp, &chatRequest{data: map[string]any{}}, func(line string) {
+		if strings.HasPrefix(line, "data: ") {
+			lines = append(lines, strings.TrimPrefix(line, "data: "))
+		}
+	})
+
+	require.Len(t, lines, 7)
+	assert.Contains(t, lines[0], `"delta":{"content":"","role":"assistant"}`)
+	assert.Contains(t, lines[1], `"delta":{"reasoning":"Check the "}`)
+	assert.Contains(t, lines[2], `"delta":{"reasoning":"weather."}`)
+	assert.Contains(t, lines[3], `"delta":{"content":""}`)
+	assert.Contains(t, lines[4], `"delta":{"content":"Calling a tool "}`)
+	assert.Contains(t, lines[5], `"content":"\u003c"`)
+
+	item, found := adapter.cache.Get("", "call_1")
+	require.True(t, found)
+	assert.Equal(t, "Check the weather.", item.Content)
+}
+
+func TestStripReasoning_Blocking(t *testing.T) {
+	adapter := newTestAdapter()
+
+	response := map[string]any{
+		"choices": []any{map[string]any{
+			"message": map[string]any{
+				"role":              "assistant",
+				"reasoning_content": "Look up Paris.",
+				"tool_calls": []any{
+					map[string]any{"id": "call_1", "function": map[string]any{"name": "get_weather", "arguments": "{}"}},
+				},
+			},
+		}},
+	}
+	ok := adapter.processChatResponse(nil, &http.Response{}, &chatRequest{data: map[string]any{}, stripReasoning: true}, response)
+	require.True(t, ok)
+
+	message := response["choices"].([]any)[0].(map[string]any)["message"].(map[string]any)
+	assert.NotContains(t, message, "reasoning")
+	assert.NotContains(t, message, "reasoning_content")
+
+	item, found := adapter.cache.Get("", "call_1")
+	assert.True(t, found)
+	assert.Equal(t, "Look up Paris.", item.Content)
+}
+
+func TestStripReasoning_Stream(t *testing.T) {
+	adapter := newTestAdapter()
+
+	stream := strings.Join([]string{
+		`data: {"choices":[{"index":0,"delta":{"reasoning_content":"Look up Paris."}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"get_weather","arguments":"{}"}}]}}]}`,
+		`data: [DONE]`,
+		``,
+	}, "\n\n")
+
+	req, _ := http.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	resp := &http.Response{Body: io.NopCloser(strings.NewReader(stream)), Request: req}
+
+	var out strings.Builder
+	adapter.relayChatStream(resp, &chatRequest{data: map[string]any{}, stripReasoning: true}, func(line string) {
+		out.WriteString(line + "\n")
+	})
+
+	assert.NotContains(t, out.String(), "Look up Paris.")
+	assert.Contains(t, out.String(), "call_1")
+
+	item, found := adapter.cache.Get("", "call_1")
+	assert.True(t, found)
+	assert.Equal(t, "Look up Paris.", item.Content)
+}
+
+func TestClientDisconnect_CancelsUpstream(t *testing.T) {
+	cancelled := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		deadline := time.After(5 * time.Second)
+		for {
+			io.WriteString(w, `data: {"choices":[{"index":0,"delta":{"content":"token"}}]}`+"\n\n")
+			w.(http.Flusher).Flush()
+			select {
+			case <-r.Context().Done():
+				close(cancelled)
+				return
+			case <-deadline:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}))
+	defer backend.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	server := httptest.NewServer(NewAdapter(backend.URL, NewLRUCache(10), logger, llamacpp.NewProvider()))
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json", strings.NewReader(`{"messages":[],"stream":true}`))
+	require.NoError(t, err)
+	_, err = resp.Body.Read(make([]byte, 16))
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("upstream request was not cancelled after the client disconnected")
+	}
+}
+
+func newPassthroughTestServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	backend := httptest.NewServer(handler)
+	t.Cleanup(backend.Close)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	server := httptest.NewServer(NewLoggingMiddleware(NewAdapter(backend.URL, NewLRUCache(10), logger, llamacpp.NewProvider()), logger))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHandleDefault_Trailers(t *testing.T) {
+	server := newPassthroughTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/embeddings", r.URL.Path)
+		assert.Equal(t, "encoding_format=float", r.URL.RawQuery)
+		w.Header().Set("Trailer", "X-Checksum")
+		io.WriteString(w, `{"data":[]}`)
+		w.Header().Set("X-Checksum", "abc")
+	})
+
+	resp, err := http.Post(server.URL+"/v1/embeddings?encoding_format=float", "application/json", strings.NewReader(`{"input":"hi"}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, `{"data":[]}`, string(body))
+	assert.Equal(t, "abc", resp.Trailer.Get("X-Checksum"))
+}
+
+func TestHandleDefault_InformationalResponses(t *testing.T) {
+	server := newPassthroughTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", "</style.css>; rel=preload")
+		w.WriteHeader(http.StatusEarlyHints)
+		w.Header().Del("Link")
+		io.WriteString(w, "ok")
+	})
+
+	var informational []int
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			informational = append(informational, code)
+			return nil
+		},
+	}
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), http.MethodGet, server.URL+"/slots", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []int{http.StatusEarlyHints}, informational)
+}
+
+func TestHandleDefault_Streams(t *testing.T) {
+	release := make(chan struct{})
+	server := newPassthroughTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "first\n")
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-time.After(5 * time.Second):
+		}
+		io.WriteString(w, "second\n")
+	})
+
+	resp, err := http.Get(server.URL + "/slots")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	// The first chunk arrives while the target is still writing.
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "first\n", line)
+	close(release)
+}
+
+func TestHandleDefault_UpstreamError(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter("http://127.0.0.1:1", NewLRUCache(10), logger, llamacpp.NewProvider())
+
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slots", nil))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"upstream_error"`)
+}
@@ -0,0 +1,423 @@
+package adapter
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AdminCache is implemented by caches that can be inspected and invalidated
+// through the admin API. Keys are as stored, including any namespace prefix.
+type AdminCache interface {
+	Entries() ([]CacheEntryInfo, error)
+	Peek(key string) (ReasoningItem, bool, error)
+	Delete(key string) (bool, error)
+	Flush() (int, error)
+}
+
+// CacheEntryInfo describes a cached reasoning entry. Size is the length of
+// the reasoning content in bytes, and Created is when the entry was last
+// written.
+type CacheEntryInfo struct {
+	Key      string    `json:"key"`
+	ID       string    `json:"id"`
+	Size     int       `json:"size"`
+	Created  time.Time `json:"created,omitzero"`
+	LastUsed time.Time `json:"last_used,omitzero"`
+}
+
+type adminCacheEntry struct {
+	CacheEntryInfo
+	AgeSeconds float64 `json:"age_seconds,omitempty"`
+}
+
+// authorizeAdmin checks the admin bearer token. The admin API does not exist
+// unless a token is configured. A client API key is refused with 403, any
+// other credential with 401.
+func (a *Adapter) authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if a.AdminToken == "" {
+		http.NotFound(w, r)
+		return false
+	}
+
+	if !a.isAdmin(r) {
+		if a.APIKeys != nil {
+			if _, ok := a.APIKeys.Lookup(presentedKey(r)); ok {
+				a.logger.Warn("rejected admin request with a client API key", "path", r.URL.Path, "client_ip", getClientIP(r))
+				writeOpenAIError(w, http.StatusForbidden, "This endpoint requires the admin token", "invalid_request_error", "permission_denied")
+				return false
+			}
+		}
+		a.logger.Warn("rejected admin request", "path", r.URL.Path, "client_ip", getClientIP(r))
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		writeOpenAIError(w, http.StatusUnauthorized, "Invalid admin token", "invalid_request_error", "invalid_api_key")
+		return false
+	}
+	return true
+}
+
+// isAdmin reports whether r carries the admin token.
+func (a *Adapter) isAdmin(r *http.Request) bool {
+	token := bearerToken(r.Header.Get("Authorization"))
+	return a.AdminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.AdminToken)) == 1
+}
+
+// adminCache returns the cache if it supports the admin API.
+func (a *Adapter) adminCache(w http.ResponseWriter) (AdminCache, bool) {
+	cache, ok := a.cache.(AdminCache)
+	if !ok {
+		writeOpenAIError(w, http.StatusNotImplemented, "The cache backend does not support inspection", "invalid_request_error", "not_implemented")
+		return nil, false
+	}
+	return cache, true
+}
+
+// handleAdminCache lists cache entries on GET and invalidates them on DELETE.
+// Both accept a prefix query parameter, e.g. a namespace followed by ":", to
+// select entries; DELETE also accepts a single key. A DELETE without either
+// flushes the whole cache.
+func (a *Adapter) handleAdminCache(w http.ResponseWriter, r *http.Request) {
+	if !a.authorizeAdmin(w, r) {
+		return
+	}
+	cache, ok := a.adminCache(w)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	prefix := query.Get("prefix")
+
+	switch r.Method {
+	case http.MethodGet:
+		limit := 0
+		if value := query.Get("limit"); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				writeOpenAIError(w, http.StatusBadRequest, "Invalid limit: "+value, "invalid_request_error", "invalid_limit")
+				return
+			}
+			limit = n
+		}
+
+		entries, err := matchingEntries(cache, prefix)
+		if err != nil {
+			a.logger.Error("failed to list cache entries", "error", err)
+			writeOpenAIError(w, http.StatusInternalServerError, "Failed to list cache entries: "+err.Error(), "server_error", "")
+			return
+		}
+
+		total := len(entries)
+		if limit > 0 && limit < total {
+			entries = entries[:limit]
+		}
+
+		now := time.Now()
+		out := make([]adminCacheEntry, len(entries))
+		for i, entry := range entries {
+			out[i] = adminCacheEntry{CacheEntryInfo: entry}
+			if !entry.LastUsed.IsZero() {
+				out[i].AgeSeconds = now.Sub(entry.LastUsed).Seconds()
+			}
+		}
+		writeAdminJSON(w, map[string]any{"entries": out, "total": total})
+
+	case http.MethodDelete:
+		var removed int
+		var err error
+		switch key := query.Get("key"); {
+		case key != "":
+			var found bool
+			found, err = cache.Delete(key)
+			if found {
+				removed = 1
+			}
+		case prefix != "":
+			removed, err = deleteEntries(cache, prefix)
+		default:
+			removed, err = cache.Flush()
+		}
+		if err != nil {
+			a.logger.Error("failed to invalidate cache entries", "error", err)
+			writeOpenAIError(w, http.StatusInternalServerError, "Failed to invalidate cache entries: "+err.Error(), "server_error", "")
+			return
+		}
+
+		a.logger.Info("invalidated cached reasoning", "key", query.Get("key"), "prefix", prefix, "removed", removed)
+		writeAdminJSON(w, map[string]any{"removed": removed})
+
+	default:
+		w.Header().Set("Allow", "GET, DELETE")
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "Method not allowed", "invalid_request_error", "")
+	}
+}
+
+// handleAdminCacheStats reports the number of cache entries, the total size
+// of their reasoning content and the cache's hit, miss, insert and eviction
+// counts.
+func (a *Adapter) handleAdminCacheStats(w http.ResponseWriter, r *http.Request) {
+	if !a.authorizeAdmin(w, r) {
+		return
+	}
+	cache, ok := a.adminCache(w)
+	if !ok {
+		return
+	}
+
+	entries, err := cache.Entries()
+	if err != nil {
+		a.logger.Error("failed to list cache entries", "error", err)
+		writeOpenAIError(w, http.StatusInternalServerError, "Failed to list cache entries: "+err.Error(), "server_error", "")
+		return
+	}
+
+	bytes := 0
+	var oldest time.Time
+	for _, entry := range entries {
+		bytes += entry.Size
+		if !entry.LastUsed.IsZero() && (oldest.IsZero() || entry.LastUsed.Before(oldest)) {
+			oldest = entry.LastUsed
+		}
+	}
+
+	counters := a.cache.Stats()
+	stats := map[string]any{
+		"entries":   len(entries),
+		"bytes":     bytes,
+		"hits":      counters.Hits,
+		"misses":    counters.Misses,
+		"inserts":   counters.Inserts,
+		"evictions": counters.Evictions,
+		"hit_rate":  counters.HitRate(),
+	}
+	if !oldest.IsZero() {
+		stats["oldest_age_seconds"] = time.Since(oldest).Seconds()
+	}
+	writeAdminJSON(w, stats)
+}
+
+// cacheImport is the body of a cache import: the messages of a chat
+// conversation, as a client would send them back, and the namespace to
+// cache their reasoning in.
+type cacheImport struct {
+	Namespace string           `json:"namespace"`
+	Messages  []map[string]any `json:"messages"`
+}
+
+// cacheExportEntry is a line of a cache export.
+type cacheExportEntry struct {
+	Key      string    `json:"key"`
+	ID       string    `json:"id"`
+	Content  string    `json:"content"`
+	Created  time.Time `json:"created,omitzero"`
+	LastUsed time.Time `json:"last_used,omitzero"`
+}
+
+// exportContentType is the media type of cache exports, one JSON entry per
+// line.
+const exportContentType = "application/x-ndjson"
+
+// exportFlushInterval is how many entries of an export are written between
+// flushes.
+const exportFlushInterval = 100
+
+// handleAdminCacheImport caches the reasoning of the assistant messages of a
+// conversation, so that a conversation resumed after a restart or moved from
+// another instance gets its reasoning back. Reasoning is taken from the
+// provider's field or from reasoning, and cached under the message's tool
+// call IDs, or under its plain turn tag for messages without tool calls. A
+// body sent as exportContentType is instead an export of another cache,
+// whose entries are stored under their keys.
+func (a *Adapter) handleAdminCacheImport(w http.ResponseWriter, r *http.Request) {
+	if !a.authorizeAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "Method not allowed", "invalid_request_error", "")
+		return
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == exportContentType {
+		a.importCacheExport(w, r)
+		return
+	}
+
+	var body cacheImport
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "Invalid conversation: "+err.Error(), "invalid_request_error", "invalid_json")
+		return
+	}
+
+	imported, skipped := 0, 0
+	for _, message := range body.Messages {
+		if role, _ := message["role"].(string); role != "assistant" {
+			continue
+		}
+		reasoningContent, ok := a.extractReasoning(message)
+		if !ok || reasoningContent == "" {
+			reasoningContent, _ = message["reasoning"].(string)
+		}
+		if reasoningContent == "" {
+			skipped++
+			continue
+		}
+
+		toolCalls, _ := message["tool_calls"].([]any)
+		if len(toolCallIDs(toolCalls)) > 0 {
+			a.cacheReasoning(body.Namespace, toolCalls, reasoningContent)
+			imported++
+			continue
+		}
+		if id := takePlainTurnID(message); id != "" {
+			a.storeReasoning(body.Namespace, id, ReasoningItem{ID: id, Content: reasoningContent})
+			imported++
+			continue
+		}
+		skipped++
+	}
+
+	a.logger.Info("imported cached reasoning", "namespace", body.Namespace, "imported", imported, "skipped", skipped)
+	writeAdminJSON(w, map[string]any{"imported": imported, "skipped": skipped})
+}
+
+// importCacheExport stores the entries of a cache export. Entries are stored
+// as they are read, so an invalid entry stops the import with the entries
+// before it already stored, and the error says how many there were.
+func (a *Adapter) importCacheExport(w http.ResponseWriter, r *http.Request) {
+	imported := 0
+	invalid := func(reason string) {
+		a.logger.Warn("stopped importing cache export at an invalid entry", "entry", imported+1, "imported", imported, "error", reason)
+		message := fmt.Sprintf("Invalid export entry %d: %s (%d imported before it)", imported+1, reason, imported)
+		writeOpenAIError(w, http.StatusBadRequest, message, "invalid_request_error", "invalid_json")
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	for {
+		var entry cacheExportEntry
+		err := decoder.Decode(&entry)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			invalid(err.Error())
+			return
+		}
+		if entry.Key == "" {
+			invalid("missing key")
+			return
+		}
+		// Exported keys already include their namespace.
+		a.storeReasoning("", entry.Key, ReasoningItem{ID: entry.ID, Content: entry.Content})
+		imported++
+	}
+
+	a.logger.Info("imported cache export", "imported", imported)
+	writeAdminJSON(w, map[string]any{"imported": imported, "skipped": 0})
+}
+
+// handleAdminCacheExport streams cache entries with their reasoning as JSON
+// lines, in the order Entries lists them, to be inspected or imported into
+// another adapter. A prefix query parameter selects entries as for
+// handleAdminCache.
+func (a *Adapter) handleAdminCacheExport(w http.ResponseWriter, r *http.Request) {
+	if !a.authorizeAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "Method not allowed", "invalid_request_error", "")
+		return
+	}
+	cache, ok := a.adminCache(w)
+	if !ok {
+		return
+	}
+
+	prefix := r.URL.Query().Get("prefix")
+	entries, err := matchingEntries(cache, prefix)
+	if err != nil {
+		a.logger.Error("failed to list cache entries", "error", err)
+		writeOpenAIError(w, http.StatusInternalServerError, "Failed to list cache entries: "+err.Error(), "server_error", "")
+		return
+	}
+
+	w.Header().Set("Content-Type", exportContentType)
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	exported := 0
+	for _, entry := range entries {
+		if r.Context().Err() != nil {
+			return
+		}
+		item, found, err := cache.Peek(entry.Key)
+		if err != nil {
+			// The status has been sent; end the export early and let the
+			// client notice the missing entries.
+			a.logger.Error("failed to export cache entry", "key", entry.Key, "error", err)
+			return
+		}
+		if !found {
+			// Expired or removed since it was listed.
+			continue
+		}
+
+		encoder.Encode(cacheExportEntry{
+			Key:      entry.Key,
+			ID:       item.ID,
+			Content:  item.Content,
+			Created:  entry.Created,
+			LastUsed: entry.LastUsed,
+		})
+		exported++
+		if flusher != nil && exported%exportFlushInterval == 0 {
+			flusher.Flush()
+		}
+	}
+	a.logger.Info("exported cached reasoning", "prefix", prefix, "exported", exported)
+}
+
+func matchingEntries(cache AdminCache, prefix string) ([]CacheEntryInfo, error) {
+	entries, err := cache.Entries()
+	if err != nil || prefix == "" {
+		return entries, err
+	}
+
+	matched := entries[:0]
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Key, prefix) {
+			matched = append(matched, entry)
+		}
+	}
+	return matched, nil
+}
+
+func deleteEntries(cache AdminCache, prefix string) (int, error) {
+	entries, err := matchingEntries(cache, prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		found, err := cache.Delete(entry.Key)
+		if err != nil {
+			return removed, err
+		}
+		if found {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func writeAdminJSON(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
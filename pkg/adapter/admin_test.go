@@ -0,0 +1,267 @@
+package adapter
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+)
+
+type opaqueCache struct{ Cache }
+
+func newAdminTestAdapter(cache Cache) *Adapter {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter("http://localhost:1", cache, logger, llamacpp.NewProvider())
+	adapter.AdminToken = "secret"
+	return adapter
+}
+
+func adminRequest(t *testing.T, adapter *Adapter, method, target, token string) (*httptest.ResponseRecorder, map[string]any) {
+	r := httptest.NewRequest(method, target, nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, r)
+
+	var body map[string]any
+	if w.Code != http.StatusNotFound {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	}
+	return w, body
+}
+
+func TestAdminCache_Auth(t *testing.T) {
+	adapter := newAdminTestAdapter(NewLRUCache(10))
+
+	w, _ := adminRequest(t, adapter, http.MethodGet, "/admin/cache", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w, _ = adminRequest(t, adapter, http.MethodDelete, "/admin/cache", "wrong")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w, _ = adminRequest(t, adapter, http.MethodGet, "/admin/cache", "secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Without a token the admin API does not exist.
+	adapter.AdminToken = ""
+	w, _ = adminRequest(t, adapter, http.MethodGet, "/admin/cache", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAdminCache_List(t *testing.T) {
+	cache := NewLRUCache(10)
+	cache.Put("", "call_1", ReasoningItem{ID: "call_1", Content: "first"})
+	cache.Put("tenant", "call_2", ReasoningItem{ID: "call_2", Content: "second!"})
+	cache.Put("tenant", "call_3", ReasoningItem{ID: "call_3", Content: "third"})
+	adapter := newAdminTestAdapter(cache)
+
+	w, body := adminRequest(t, adapter, http.MethodGet, "/admin/cache", "secret")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, float64(3), body["total"])
+	entries := body["entries"].([]any)
+	require.Len(t, entries, 3)
+	latest := entries[0].(map[string]any)
+	assert.Equal(t, "tenant:call_3", latest["key"])
+	assert.Equal(t, "call_3", latest["id"])
+	assert.Equal(t, float64(5), latest["size"])
+	assert.Contains(t, latest, "created")
+	assert.Contains(t, latest, "last_used")
+
+	w, body = adminRequest(t, adapter, http.MethodGet, "/admin/cache?prefix=tenant:&limit=1", "secret")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, float64(2), body["total"])
+	assert.Len(t, body["entries"], 1)
+
+	w, _ = adminRequest(t, adapter, http.MethodGet, "/admin/cache?limit=x", "secret")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w, _ = adminRequest(t, adapter, http.MethodPost, "/admin/cache", "secret")
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestAdminCache_Delete(t *testing.T) {
+	cache := NewLRUCache(10)
+	cache.Put("", "call_1", ReasoningItem{ID: "call_1", Content: "first"})
+	cache.Put("tenant", "call_2", ReasoningItem{ID: "call_2", Content: "second"})
+	cache.Put("tenant", "call_3", ReasoningItem{ID: "call_3", Content: "third"})
+	cache.Put("other", "call_4", ReasoningItem{ID: "call_4", Content: "fourth"})
+	adapter := newAdminTestAdapter(cache)
+
+	_, body := adminRequest(t, adapter, http.MethodDelete, "/admin/cache?key=call_1", "secret")
+	assert.Equal(t, float64(1), body["removed"])
+	_, found := cache.Get("", "call_1")
+	assert.False(t, found)
+
+	_, body = adminRequest(t, adapter, http.MethodDelete, "/admin/cache?key=call_1", "secret")
+	assert.Equal(t, float64(0), body["removed"])
+
+	_, body = adminRequest(t, adapter, http.MethodDelete, "/admin/cache?prefix=tenant:", "secret")
+	assert.Equal(t, float64(2), body["removed"])
+	assert.Equal(t, 1, cache.Size())
+
+	_, body = adminRequest(t, adapter, http.MethodDelete, "/admin/cache", "secret")
+	assert.Equal(t, float64(1), body["removed"])
+	assert.Equal(t, 0, cache.Size())
+}
+
+func TestAdminCache_Stats(t *testing.T) {
+	cache := NewLRUCache(10)
+	cache.Put("", "call_1", ReasoningItem{ID: "call_1", Content: "first"})
+	cache.Put("", "call_2", ReasoningItem{ID: "call_2", Content: "second"})
+	cache.Get("", "call_1")
+	cache.Get("", "call_3")
+	adapter := newAdminTestAdapter(cache)
+
+	w, body := adminRequest(t, adapter, http.MethodGet, "/admin/cache/stats", "secret")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, float64(2), body["entries"])
+	assert.Equal(t, float64(11), body["bytes"])
+	assert.Contains(t, body, "oldest_age_seconds")
+	assert.Equal(t, float64(1), body["hits"])
+	assert.Equal(t, float64(1), body["misses"])
+	assert.Equal(t, float64(2), body["inserts"])
+	assert.Equal(t, float64(0), body["evictions"])
+	assert.Equal(t, 0.5, body["hit_rate"])
+}
+
+func TestAdminCache_Unsupported(t *testing.T) {
+	adapter := newAdminTestAdapter(opaqueCache{NewLRUCache(10)})
+
+	w, _ := adminRequest(t, adapter, http.MethodGet, "/admin/cache", "secret")
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+}
+
+func TestAdminCache_NotRouted(t *testing.T) {
+	adapter := newAdminTestAdapter(NewLRUCache(10))
+	adapter.AddRoute(llamacpp.NewProvider())
+
+	r := httptest.NewRequest(http.MethodGet, "/admin/cache", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	r.Header.Set(providerHeader, "unknown")
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestAdminCache_Import(t *testing.T) {
+	cache := NewLRUCache(10)
+	adapter := newAdminTestAdapter(cache)
+
+	conversation := `{"namespace":"tenant","messages":[
+		{"role":"user","content":"Weather in Paris?"},
+		{"role":"assistant","reasoning":"Look it up.","tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{}"}}]},
+		{"role":"tool","tool_call_id":"call_1","content":"Sunny"},
+		{"role":"assistant","reasoning_content":"Summarize.","content":"It is sunny.\n\n<!-- reasoning:rsn_0123abcd -->"},
+		{"role":"assistant","content":"No reasoning."}
+	]}`
+	r := httptest.NewRequest(http.MethodPost, "/admin/cache/import", strings.NewReader(conversation))
+	r.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, float64(2), body["imported"])
+	assert.Equal(t, float64(1), body["skipped"])
+
+	item, found := cache.Get("tenant", "call_1")
+	require.True(t, found)
+	assert.Equal(t, "Look it up.", item.Content)
+	item, found = cache.Get("tenant", "rsn_0123abcd")
+	require.True(t, found)
+	assert.Equal(t, "Summarize.", item.Content)
+	_, found = cache.Get("", "call_1")
+	assert.False(t, found)
+
+	r = httptest.NewRequest(http.MethodPost, "/admin/cache/import", strings.NewReader("{"))
+	r.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	adapter.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w, _ = adminRequest(t, adapter, http.MethodGet, "/admin/cache/import", "secret")
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestAdminCache_Export(t *testing.T) {
+	cache := NewLRUCache(10)
+	cache.Put("", "call_1", ReasoningItem{ID: "call_1", Content: "first"})
+	cache.Put("tenant", "call_2", ReasoningItem{ID: "call_2", Content: "second"})
+	cache.Put("tenant", "call_3", ReasoningItem{ID: "call_3", Content: "third"})
+	adapter := newAdminTestAdapter(cache)
+
+	export := func(target string) []cacheExportEntry {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		adapter.ServeHTTP(w, r)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+
+		var entries []cacheExportEntry
+		for _, line := range strings.Split(strings.TrimSpace(w.Body.String()), "\n") {
+			var entry cacheExportEntry
+			require.NoError(t, json.Unmarshal([]byte(line), &entry))
+			entries = append(entries, entry)
+		}
+		return entries
+	}
+
+	entries := export("/admin/cache/export")
+	require.Len(t, entries, 3)
+	assert.Equal(t, "tenant:call_3", entries[0].Key)
+	assert.Equal(t, "call_3", entries[0].ID)
+	assert.Equal(t, "third", entries[0].Content)
+	assert.False(t, entries[0].Created.IsZero())
+	// Exporting does not count as using the entries.
+	assert.Equal(t, int64(0), cache.Stats().Hits)
+
+	entries = export("/admin/cache/export?prefix=tenant:")
+	require.Len(t, entries, 2)
+
+	// The export imports into another adapter with its namespaces intact.
+	r := httptest.NewRequest(http.MethodGet, "/admin/cache/export", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, r)
+
+	other := NewLRUCache(10)
+	r = httptest.NewRequest(http.MethodPost, "/admin/cache/import", w.Body)
+	r.Header.Set("Authorization", "Bearer secret")
+	r.Header.Set("Content-Type", "application/x-ndjson")
+	w = httptest.NewRecorder()
+	newAdminTestAdapter(other).ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"imported":3`)
+	item, found := other.Get("tenant", "call_2")
+	require.True(t, found)
+	assert.Equal(t, "second", item.Content)
+
+	// An invalid line stops the import, keeping the entries before it.
+	partial := NewLRUCache(10)
+	body := `{"key":"call_4","id":"call_4","content":"fourth"}` + "\n" + `{"id":"call_5"}` + "\n" + `{"key":"call_6","id":"call_6","content":"sixth"}` + "\n"
+	r = httptest.NewRequest(http.MethodPost, "/admin/cache/import", strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer secret")
+	r.Header.Set("Content-Type", "application/x-ndjson")
+	w = httptest.NewRecorder()
+	newAdminTestAdapter(partial).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Invalid export entry 2: missing key (1 imported before it)")
+	_, found = partial.Get("", "call_4")
+	assert.True(t, found)
+	_, found = partial.Get("", "call_6")
+	assert.False(t, found)
+
+	w, _ = adminRequest(t, adapter, http.MethodDelete, "/admin/cache/export", "secret")
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
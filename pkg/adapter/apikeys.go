@@ -0,0 +1,138 @@
+package adapter
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// APIKey is a key that clients authenticate to the adapter with.
+// UpstreamKey, if set, is sent to the target in its place. Priority is the
+// highest queue priority the key's requests get, and the default for them.
+type APIKey struct {
+	Name        string `yaml:"name"`
+	Key         string `yaml:"key"`
+	UpstreamKey string `yaml:"upstream_key"`
+	Priority    string `yaml:"priority"`
+}
+
+// APIKeys validates client credentials. Keys are looked up by their hash, so
+// that the lookup does not depend on how much of a guessed key is right.
+type APIKeys struct {
+	keys map[[sha256.Size]byte]APIKey
+}
+
+type apiKeysDocument struct {
+	UpstreamKey string   `yaml:"upstream_key"`
+	Keys        []APIKey `yaml:"keys"`
+}
+
+// LoadAPIKeys reads a YAML or JSON file with a list of keys and an optional
+// upstream_key used for keys without their own. Values may reference
+// environment variables, e.g. ${OPENROUTER_API_KEY}.
+func LoadAPIKeys(path string) (*APIKeys, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file apiKeysDocument
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	keys := &APIKeys{keys: make(map[[sha256.Size]byte]APIKey)}
+	names := make(map[string]bool)
+	for i, key := range file.Keys {
+		key.Key = os.ExpandEnv(key.Key)
+		key.UpstreamKey = os.ExpandEnv(key.UpstreamKey)
+		if key.UpstreamKey == "" {
+			key.UpstreamKey = os.ExpandEnv(file.UpstreamKey)
+		}
+
+		switch {
+		case key.Name == "":
+			return nil, fmt.Errorf("%s: key %d has no name", path, i+1)
+		case key.Key == "":
+			return nil, fmt.Errorf("%s: key %q is empty", path, key.Name)
+		case names[key.Name]:
+			return nil, fmt.Errorf("%s: duplicate key name %q", path, key.Name)
+		}
+		if _, err := ParsePriority(key.Priority); err != nil {
+			return nil, fmt.Errorf("%s: key %q: %w", path, key.Name, err)
+		}
+		names[key.Name] = true
+
+		hash := sha256.Sum256([]byte(key.Key))
+		if _, ok := keys.keys[hash]; ok {
+			return nil, fmt.Errorf("%s: key %q is also used by another entry", path, key.Name)
+		}
+		keys.keys[hash] = key
+	}
+
+	if len(keys.keys) == 0 {
+		return nil, fmt.Errorf("%s: no keys defined", path)
+	}
+	return keys, nil
+}
+
+// Lookup returns the key matching a presented credential.
+func (k *APIKeys) Lookup(credential string) (APIKey, bool) {
+	key, ok := k.keys[sha256.Sum256([]byte(credential))]
+	return key, ok
+}
+
+// Len returns the number of keys.
+func (k *APIKeys) Len() int {
+	return len(k.keys)
+}
+
+type apiKeyContextKey struct{}
+
+// authenticatedKey returns the key a request was authenticated with.
+func authenticatedKey(ctx context.Context) (APIKey, bool) {
+	key, ok := ctx.Value(apiKeyContextKey{}).(APIKey)
+	return key, ok
+}
+
+// presentedKey returns the API key sent by a client, as a bearer token or in
+// the X-Api-Key header used by Anthropic clients.
+func presentedKey(r *http.Request) string {
+	if token := bearerToken(r.Header.Get("Authorization")); token != "" {
+		return token
+	}
+	return strings.TrimSpace(r.Header.Get("X-Api-Key"))
+}
+
+// authenticate checks the client's API key. The key is not passed on to the
+// target: its credentials are replaced by the key's upstream key, if any. It
+// returns false if the request was rejected.
+func (a *Adapter) authenticate(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	credential := presentedKey(r)
+	if credential == "" {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeOpenAIError(w, http.StatusUnauthorized, "You didn't provide an API key. Pass it as a bearer token in the Authorization header.", "invalid_request_error", "missing_api_key")
+		return nil, false
+	}
+
+	key, ok := a.APIKeys.Lookup(credential)
+	if !ok {
+		a.logger.Warn("rejected request with invalid API key", "path", r.URL.Path, "client_ip", getClientIP(r))
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeOpenAIError(w, http.StatusUnauthorized, "Incorrect API key provided.", "invalid_request_error", "invalid_api_key")
+		return nil, false
+	}
+
+	r = r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, key))
+	r.Header.Del("Authorization")
+	r.Header.Del("X-Api-Key")
+	if key.UpstreamKey != "" {
+		r.Header.Set("Authorization", "Bearer "+key.UpstreamKey)
+	}
+	return r, true
+}
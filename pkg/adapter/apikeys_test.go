@@ -0,0 +1,150 @@
+package adapter
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+)
+
+func writeAPIKeys(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadAPIKeys(t *testing.T) {
+	t.Setenv("TEST_UPSTREAM_KEY", "sk-or-shared")
+	keys, err := LoadAPIKeys(writeAPIKeys(t, `
+upstream_key: ${TEST_UPSTREAM_KEY}
+keys:
+  - name: alice
+    key: sk-alice
+  - name: ci
+    key: sk-ci
+    upstream_key: sk-or-ci
+`))
+	require.NoError(t, err)
+	assert.Equal(t, 2, keys.Len())
+
+	key, ok := keys.Lookup("sk-alice")
+	require.True(t, ok)
+	assert.Equal(t, APIKey{Name: "alice", Key: "sk-alice", UpstreamKey: "sk-or-shared"}, key)
+
+	key, ok = keys.Lookup("sk-ci")
+	require.True(t, ok)
+	assert.Equal(t, "sk-or-ci", key.UpstreamKey)
+
+	_, ok = keys.Lookup("sk-bob")
+	assert.False(t, ok)
+
+	invalid := map[string]string{
+		"no keys":        "keys: []",
+		"missing name":   "keys: [{key: sk-a}]",
+		"empty key":      "keys: [{name: a}]",
+		"duplicate name": "keys: [{name: a, key: sk-a}, {name: a, key: sk-b}]",
+		"duplicate key":  "keys: [{name: a, key: sk-a}, {name: b, key: sk-a}]",
+		"bad priority":   "keys: [{name: a, key: sk-a, priority: urgent}]",
+	}
+	for name, content := range invalid {
+		_, err := LoadAPIKeys(writeAPIKeys(t, content))
+		assert.Error(t, err, name)
+	}
+}
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	var upstreamAuth []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamAuth = append(upstreamAuth, r.Header.Get("Authorization")+"|"+r.Header.Get("X-Api-Key"))
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"Hi"}}]}`)
+	}))
+	defer upstream.Close()
+
+	keys, err := LoadAPIKeys(writeAPIKeys(t, `
+keys:
+  - name: alice
+    key: sk-alice
+  - name: ci
+    key: sk-ci
+    upstream_key: sk-or-ci
+`))
+	require.NoError(t, err)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter(upstream.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+	adapter.APIKeys = keys
+
+	tests := []struct {
+		name    string
+		header  string
+		value   string
+		status  int
+		code    string
+		forward string
+	}{
+		{"missing key", "", "", http.StatusUnauthorized, "missing_api_key", ""},
+		{"invalid key", "Authorization", "Bearer sk-bob", http.StatusUnauthorized, "invalid_api_key", ""},
+		{"valid key", "Authorization", "Bearer sk-alice", http.StatusOK, "", "|"},
+		{"x-api-key", "X-Api-Key", "sk-alice", http.StatusOK, "", "|"},
+		{"upstream key", "Authorization", "Bearer sk-ci", http.StatusOK, "", "Bearer sk-or-ci|"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstreamAuth = nil
+			r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"messages":[]}`))
+			if tt.header != "" {
+				r.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+			adapter.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.status, w.Code)
+			if tt.code != "" {
+				assert.Contains(t, w.Body.String(), `"code":"`+tt.code+`"`)
+				assert.Empty(t, upstreamAuth)
+				return
+			}
+			assert.Equal(t, []string{tt.forward}, upstreamAuth)
+		})
+	}
+
+	// Health checks do not need a key.
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestAPIKeyAuthentication_Usage(t *testing.T) {
+	adapter := newUsageTestAdapter(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[],"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`)
+	})
+	keys, err := LoadAPIKeys(writeAPIKeys(t, "keys: [{name: alice, key: sk-alice}]"))
+	require.NoError(t, err)
+	adapter.APIKeys = keys
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"m","messages":[]}`))
+	r.Header.Set("Authorization", "Bearer sk-alice")
+	adapter.ServeHTTP(httptest.NewRecorder(), r)
+
+	usage := getUsage(t, adapter, "Bearer sk-alice")
+	require.Len(t, usage, 1)
+	assert.Equal(t, "alice", usage[0].Client)
+	assert.Len(t, getUsage(t, adapter, "Bearer secret"), 1)
+
+	r = httptest.NewRequest(http.MethodGet, "/v1/usage", nil)
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
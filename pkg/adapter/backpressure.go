@@ -0,0 +1,199 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	StreamOverflowDisconnect  = "disconnect"
+	StreamOverflowDropThrough = "drop-through"
+)
+
+// errSlowClient ends the upstream read of a stream whose client was
+// disconnected for not keeping up.
+var errSlowClient = fmt.Errorf("client could not keep up with the stream: %w", context.Canceled)
+
+// startStreamWriter wraps w so that a streamed response is written to the
+// client by a separate goroutine, each flushed chunk within
+// StreamWriteTimeout, with up to StreamBufferSize chunks waiting. A client
+// that stalls a write past the timeout or lets the buffer fill up is cut off,
+// and StreamOverflow decides what happens to the upstream stream: it is
+// cancelled, or with StreamOverflowDropThrough read to the end without
+// writing to the client, so that its reasoning is still cached and its usage
+// recorded. It may replace resp.Body, and the returned stop function must be
+// called before the handler returns.
+func (a *Adapter) startStreamWriter(w http.ResponseWriter, resp *http.Response, chat *chatRequest) (http.ResponseWriter, func()) {
+	if a.StreamWriteTimeout <= 0 && a.StreamBufferSize <= 0 {
+		return w, func() {}
+	}
+
+	sw := &streamWriter{
+		ResponseWriter: w,
+		rc:             http.NewResponseController(w),
+		timeout:        a.StreamWriteTimeout,
+		buffered:       a.StreamBufferSize > 0,
+		chunks:         make(chan []byte, max(a.StreamBufferSize, 0)),
+		done:           make(chan struct{}),
+		failed:         make(chan struct{}),
+	}
+	body := resp.Body
+	sw.onFail = func(reason string) {
+		if a.StreamOverflow == StreamOverflowDropThrough {
+			a.logger.WarnContext(chat.ctx, "client is not keeping up with the stream, dropping the rest of it", "reason", reason)
+			return
+		}
+		a.logger.WarnContext(chat.ctx, "client is not keeping up with the stream, disconnecting it", "reason", reason)
+		body.Close()
+	}
+	if a.StreamOverflow != StreamOverflowDropThrough {
+		resp.Body = &slowClientBody{ReadCloser: body, failed: sw.failed}
+	}
+
+	go sw.run()
+	return sw, sw.stop
+}
+
+// streamWriter queues the chunks a streaming handler flushes and writes them
+// to the client in the background, so that a slow client does not hold up
+// the relay for longer than a write timeout.
+type streamWriter struct {
+	http.ResponseWriter
+	rc       *http.ResponseController
+	timeout  time.Duration
+	buffered bool
+	onFail   func(reason string)
+
+	// pending collects writes until the handler flushes them as a chunk.
+	pending []byte
+	chunks  chan []byte
+	done    chan struct{}
+
+	failed   chan struct{}
+	failOnce sync.Once
+	stopOnce sync.Once
+}
+
+func (sw *streamWriter) run() {
+	defer close(sw.done)
+
+	for chunk := range sw.chunks {
+		if sw.isFailed() {
+			continue
+		}
+		if sw.timeout > 0 {
+			sw.rc.SetWriteDeadline(time.Now().Add(sw.timeout))
+		}
+		_, err := sw.ResponseWriter.Write(chunk)
+		if err == nil {
+			err = sw.rc.Flush()
+		}
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			sw.fail(fmt.Sprintf("write took longer than %s", sw.timeout))
+		} else if err != nil && !errors.Is(err, http.ErrNotSupported) {
+			sw.fail(err.Error())
+		}
+	}
+}
+
+// fail stops writing to the client. The connection's write deadline is set
+// to the past to abort a write in progress.
+func (sw *streamWriter) fail(reason string) {
+	sw.failOnce.Do(func() {
+		close(sw.failed)
+		sw.rc.SetWriteDeadline(time.Now())
+		sw.onFail(reason)
+	})
+}
+
+func (sw *streamWriter) isFailed() bool {
+	select {
+	case <-sw.failed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (sw *streamWriter) Write(b []byte) (int, error) {
+	if !sw.isFailed() {
+		sw.pending = append(sw.pending, b...)
+	}
+	return len(b), nil
+}
+
+func (sw *streamWriter) Flush() {
+	sw.enqueue(false)
+}
+
+// enqueue hands the pending chunk to the write goroutine. Without a buffer,
+// or when block is set, it waits for room; otherwise a full buffer fails the
+// stream.
+func (sw *streamWriter) enqueue(block bool) {
+	if len(sw.pending) == 0 {
+		return
+	}
+	chunk := sw.pending
+	sw.pending = nil
+	if sw.isFailed() {
+		return
+	}
+
+	if sw.buffered && !block {
+		select {
+		case sw.chunks <- chunk:
+		default:
+			sw.fail(fmt.Sprintf("more than %d chunks waiting to be written", cap(sw.chunks)))
+		}
+		return
+	}
+	select {
+	case sw.chunks <- chunk:
+	case <-sw.failed:
+	}
+}
+
+func (sw *streamWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// stop writes what is left and waits for the write goroutine to finish. The
+// write deadline is cleared unless the client was cut off.
+func (sw *streamWriter) stop() {
+	sw.stopOnce.Do(func() {
+		sw.enqueue(true)
+		close(sw.chunks)
+		<-sw.done
+		if sw.timeout > 0 && !sw.isFailed() {
+			sw.rc.SetWriteDeadline(time.Time{})
+		}
+	})
+}
+
+// slowClientBody fails reads of an upstream stream once its client was
+// disconnected.
+type slowClientBody struct {
+	io.ReadCloser
+	failed chan struct{}
+}
+
+func (b *slowClientBody) Read(p []byte) (int, error) {
+	select {
+	case <-b.failed:
+		return 0, errSlowClient
+	default:
+	}
+	n, err := b.ReadCloser.Read(p)
+	select {
+	case <-b.failed:
+		return 0, errSlowClient
+	default:
+	}
+	return n, err
+}
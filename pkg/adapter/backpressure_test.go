@@ -0,0 +1,164 @@
+package adapter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+)
+
+// stalledWriter is a client that stops reading after the first write. Later
+// writes block until the write deadline passes.
+type stalledWriter struct {
+	header http.Header
+
+	mu       sync.Mutex
+	deadline time.Time
+	body     bytes.Buffer
+}
+
+func (s *stalledWriter) Header() http.Header {
+	return s.header
+}
+
+func (s *stalledWriter) WriteHeader(int) {}
+
+func (s *stalledWriter) Write(b []byte) (int, error) {
+	s.mu.Lock()
+	if s.body.Len() == 0 {
+		defer s.mu.Unlock()
+		return s.body.Write(b)
+	}
+	s.mu.Unlock()
+
+	giveUp := time.Now().Add(5 * time.Second)
+	for time.Now().Before(giveUp) {
+		s.mu.Lock()
+		deadline := s.deadline
+		s.mu.Unlock()
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return 0, os.ErrDeadlineExceeded
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return 0, io.ErrClosedPipe
+}
+
+func (s *stalledWriter) Flush() {}
+
+func (s *stalledWriter) SetWriteDeadline(deadline time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deadline = deadline
+	return nil
+}
+
+// newBackpressureTestAdapter returns an adapter for a backend that streams
+// events chunks and then waits for the request to be cancelled, which it
+// reports on the returned channel.
+func newBackpressureTestAdapter(t *testing.T, events int, wait bool) (*Adapter, chan struct{}) {
+	cancelled := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := range events {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"reasoning_content\":\"step %d \"}}]}\n\n", i)
+			w.(http.Flusher).Flush()
+		}
+		if !wait {
+			io.WriteString(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"tool_calls\":[{\"index\":0,\"id\":\"call_1\",\"function\":{\"name\":\"get_weather\",\"arguments\":\"{}\"}}]},\"finish_reason\":\"tool_calls\"}]}\n\n")
+			io.WriteString(w, "data: [DONE]\n\n")
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			close(cancelled)
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	t.Cleanup(backend.Close)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewAdapter(backend.URL, NewLRUCache(10), logger, llamacpp.NewProvider()), cancelled
+}
+
+func serveStalled(t *testing.T, adapter *Adapter) *stalledWriter {
+	w := &stalledWriter{header: make(http.Header)}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		body := `{"model":"gpt-oss-20b","stream":true,"messages":[{"role":"user","content":"Hello"}]}`
+		adapter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+	}()
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("stalled client held up the stream")
+	}
+	return w
+}
+
+func TestStreamWriter_BufferOverflowDisconnects(t *testing.T) {
+	adapter, cancelled := newBackpressureTestAdapter(t, 50, true)
+	adapter.StreamBufferSize = 2
+
+	w := serveStalled(t, adapter)
+	select {
+	case <-cancelled:
+	case <-time.After(3 * time.Second):
+		t.Fatal("upstream stream was not cancelled")
+	}
+	assert.NotContains(t, w.body.String(), "step 49")
+}
+
+func TestStreamWriter_WriteTimeout(t *testing.T) {
+	adapter, cancelled := newBackpressureTestAdapter(t, 3, true)
+	adapter.StreamWriteTimeout = 20 * time.Millisecond
+
+	serveStalled(t, adapter)
+	select {
+	case <-cancelled:
+	case <-time.After(3 * time.Second):
+		t.Fatal("upstream stream was not cancelled")
+	}
+}
+
+func TestStreamWriter_DropThrough(t *testing.T) {
+	adapter, _ := newBackpressureTestAdapter(t, 50, false)
+	adapter.StreamBufferSize = 2
+	adapter.StreamOverflow = StreamOverflowDropThrough
+
+	w := serveStalled(t, adapter)
+	assert.NotContains(t, w.body.String(), "[DONE]")
+
+	// The stream was read to the end, so its reasoning is cached.
+	item, found := adapter.cache.Get("", "call_1")
+	require.True(t, found)
+	assert.True(t, strings.HasPrefix(item.Content, "step 0 step 1 "), item.Content)
+	assert.Contains(t, item.Content, "step 49")
+}
+
+func TestStreamWriter_KeepsUp(t *testing.T) {
+	adapter, _ := newBackpressureTestAdapter(t, 50, false)
+	adapter.StreamBufferSize = 1000
+	adapter.StreamWriteTimeout = time.Second
+
+	body := `{"model":"gpt-oss-20b","stream":true,"messages":[{"role":"user","content":"Hello"}]}`
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "step 49")
+	assert.True(t, strings.HasSuffix(w.Body.String(), "data: [DONE]\n\n"), w.Body.String())
+}
@@ -0,0 +1,131 @@
+package adapter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	BudgetModeReject = "reject"
+	BudgetModeWarn   = "warn"
+
+	conversationIDHeader = "X-Conversation-ID"
+	budgetIdleTimeout    = 24 * time.Hour
+)
+
+type budgetEntry struct {
+	tokens  int
+	updated time.Time
+}
+
+// TokenBudget tracks cumulative token usage per conversation. Conversations
+// idle for longer than a day are forgotten.
+type TokenBudget struct {
+	Limit int
+	Mode  string
+	mutex sync.Mutex
+	usage map[string]*budgetEntry
+}
+
+func NewTokenBudget(limit int, mode string) *TokenBudget {
+	return &TokenBudget{
+		Limit: limit,
+		Mode:  mode,
+		usage: make(map[string]*budgetEntry),
+	}
+}
+
+// Add records tokens spent by a conversation and returns the new total.
+func (b *TokenBudget) Add(conversationID string, tokens int) int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := time.Now()
+	b.prune(now)
+
+	entry, exists := b.usage[conversationID]
+	if !exists {
+		entry = &budgetEntry{}
+		b.usage[conversationID] = entry
+	}
+	entry.tokens += tokens
+	entry.updated = now
+	return entry.tokens
+}
+
+// Used returns the tokens spent so far by a conversation.
+func (b *TokenBudget) Used(conversationID string) int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if entry, exists := b.usage[conversationID]; exists {
+		return entry.tokens
+	}
+	return 0
+}
+
+// Exceeded reports whether a conversation has spent its budget.
+func (b *TokenBudget) Exceeded(conversationID string) bool {
+	return b.Used(conversationID) >= b.Limit
+}
+
+func (b *TokenBudget) prune(now time.Time) {
+	for id, entry := range b.usage {
+		if now.Sub(entry.updated) > budgetIdleTimeout {
+			delete(b.usage, id)
+		}
+	}
+}
+
+// conversationID identifies the conversation a request belongs to, using the
+// X-Conversation-ID header when present and otherwise a fingerprint of the
+// system prompt and first user message.
+func conversationID(r *http.Request, requestData map[string]any) string {
+	if id := r.Header.Get(conversationIDHeader); id != "" {
+		return id
+	}
+
+	messages, ok := requestData["messages"].([]any)
+	if !ok {
+		return ""
+	}
+
+	hash := sha256.New()
+	for _, msg := range messages {
+		message, ok := msg.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		role, _ := message["role"].(string)
+		if role == "system" || role == "developer" || role == "user" {
+			hash.Write([]byte(role))
+			hash.Write([]byte{0})
+			hash.Write([]byte(messageText(message)))
+			hash.Write([]byte{0})
+		}
+
+		if role == "user" {
+			return hex.EncodeToString(hash.Sum(nil))[:32]
+		}
+	}
+
+	return ""
+}
+
+// usageTotalTokens returns usage.total_tokens from a response or stream event.
+func usageTotalTokens(data map[string]any) int {
+	usage, ok := data["usage"].(map[string]any)
+	if !ok {
+		return 0
+	}
+
+	total, ok := usage["total_tokens"].(float64)
+	if !ok {
+		return 0
+	}
+	return int(total)
+}
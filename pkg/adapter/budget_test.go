@@ -0,0 +1,44 @@
+package adapter
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBudget(t *testing.T) {
+	budget := NewTokenBudget(100, BudgetModeReject)
+
+	assert.False(t, budget.Exceeded("conv1"))
+	assert.Equal(t, 60, budget.Add("conv1", 60))
+	assert.False(t, budget.Exceeded("conv1"))
+	assert.Equal(t, 120, budget.Add("conv1", 60))
+	assert.True(t, budget.Exceeded("conv1"))
+	assert.False(t, budget.Exceeded("conv2"))
+}
+
+func TestConversationID(t *testing.T) {
+	request := func(first string, extra ...any) map[string]any {
+		messages := []any{
+			map[string]any{"role": "system", "content": "You are helpful."},
+			map[string]any{"role": "user", "content": first},
+		}
+		return map[string]any{"messages": append(messages, extra...)}
+	}
+
+	r := httptest.NewRequest("POST", "/v1/chat/completions", nil)
+
+	id := conversationID(r, request("hello"))
+	assert.NotEmpty(t, id)
+	assert.Equal(t, id, conversationID(r, request("hello", map[string]any{"role": "assistant", "content": "hi"})))
+	assert.NotEqual(t, id, conversationID(r, request("goodbye")))
+
+	r.Header.Set(conversationIDHeader, "explicit")
+	assert.Equal(t, "explicit", conversationID(r, request("hello")))
+}
+
+func TestUsageTotalTokens(t *testing.T) {
+	assert.Equal(t, 42, usageTotalTokens(map[string]any{"usage": map[string]any{"total_tokens": float64(42)}}))
+	assert.Equal(t, 0, usageTotalTokens(map[string]any{}))
+}
@@ -0,0 +1,358 @@
+package adapter
+
+import (
+	"container/list"
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type ReasoningItem struct {
+	ID      string
+	Content string
+}
+
+// CacheStats counts the activity of a cache since it was created. Evictions
+// are entries removed to make room or because they expired, not those
+// deleted through the admin API.
+type CacheStats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Inserts   int64 `json:"inserts"`
+	Evictions int64 `json:"evictions"`
+}
+
+// HitRate returns the share of lookups that were hits, or zero before the
+// first lookup.
+func (s CacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// LogValue logs the statistics as a group.
+func (s CacheStats) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int64("hits", s.Hits),
+		slog.Int64("misses", s.Misses),
+		slog.Int64("inserts", s.Inserts),
+		slog.Int64("evictions", s.Evictions),
+		slog.Float64("hit_rate", s.HitRate()),
+	)
+}
+
+// LogCacheStats logs the statistics of cache every interval until ctx is
+// done.
+func LogCacheStats(ctx context.Context, cache Cache, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			logger.Info("cache statistics", "cache", cache.Stats())
+		}
+	}
+}
+
+// cacheCounters implements the Stats method of the caches.
+type cacheCounters struct {
+	hits      atomic.Int64
+	misses    atomic.Int64
+	inserts   atomic.Int64
+	evictions atomic.Int64
+}
+
+// lookup counts a hit or a miss.
+func (c *cacheCounters) lookup(found bool) {
+	if found {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+}
+
+func (c *cacheCounters) Stats() CacheStats {
+	return CacheStats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Inserts:   c.inserts.Load(),
+		Evictions: c.evictions.Load(),
+	}
+}
+
+type LRUCache struct {
+	capacity int
+	maxBytes int64
+	bytes    int64
+	ttl      time.Duration
+	cache    map[string]*list.Element
+	list     *list.List
+	mutex    sync.RWMutex
+
+	cacheCounters
+}
+
+type cacheEntry struct {
+	key      string
+	item     ReasoningItem
+	expires  time.Time
+	created  time.Time
+	accessed time.Time
+}
+
+// size is what the entry counts toward the cache's byte limit.
+func (e *cacheEntry) size() int64 {
+	return int64(len(e.key) + len(e.item.ID) + len(e.item.Content))
+}
+
+func NewLRUCache(capacity int) *LRUCache {
+	return NewLRUCacheWithTTL(capacity, 0)
+}
+
+// NewLRUCacheWithTTL creates a cache whose entries also expire once they have
+// not been read or written for ttl. A ttl of zero disables expiry.
+func NewLRUCacheWithTTL(capacity int, ttl time.Duration) *LRUCache {
+	return &LRUCache{
+		capacity: capacity,
+		ttl:      ttl,
+		cache:    make(map[string]*list.Element),
+		list:     list.New(),
+	}
+}
+
+// SetMaxBytes limits the total size of the cached keys and reasoning, in
+// addition to the number of entries, evicting the least recently used
+// entries to stay under it. Entries larger than the limit are not cached. A
+// limit of zero disables it.
+func (c *LRUCache) SetMaxBytes(maxBytes int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.maxBytes = maxBytes
+	for c.maxBytes > 0 && c.bytes > c.maxBytes {
+		c.evictLRU()
+	}
+}
+
+func (c *LRUCache) Get(namespace, key string) (ReasoningItem, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if elem, exists := c.cache[namespacedKey(namespace, key)]; exists {
+		entry := elem.Value.(*cacheEntry)
+		now := time.Now()
+		if c.expired(entry, now) {
+			c.remove(elem)
+			c.evictions.Add(1)
+			c.lookup(false)
+			return ReasoningItem{}, false
+		}
+		c.touch(elem, now)
+		c.lookup(true)
+		return entry.item, true
+	}
+	c.lookup(false)
+	return ReasoningItem{}, false
+}
+
+func (c *LRUCache) Put(namespace, key string, item ReasoningItem) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.put(namespacedKey(namespace, key), item, c.expiry(time.Now()))
+}
+
+// namespacedKey scopes key to a namespace. Namespaces are hex digests, so
+// they never contain the separator and cannot collide with one another.
+func namespacedKey(namespace, key string) string {
+	if namespace == "" {
+		return key
+	}
+	return namespace + ":" + key
+}
+
+func (c *LRUCache) put(key string, item ReasoningItem, expires time.Time) {
+	if c.capacity <= 0 {
+		return
+	}
+
+	if elem, exists := c.cache[key]; exists {
+		c.remove(elem)
+	}
+
+	now := time.Now()
+	entry := &cacheEntry{key: key, item: item, expires: expires, created: now, accessed: now}
+	size := entry.size()
+	if c.maxBytes > 0 && size > c.maxBytes {
+		return
+	}
+
+	for c.list.Len() >= c.capacity || (c.maxBytes > 0 && c.bytes+size > c.maxBytes) {
+		c.evictLRU()
+	}
+
+	elem := c.list.PushFront(entry)
+	c.cache[key] = elem
+	c.bytes += size
+	c.inserts.Add(1)
+}
+
+func (c *LRUCache) expiry(now time.Time) time.Time {
+	if c.ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(c.ttl)
+}
+
+func (c *LRUCache) expired(entry *cacheEntry, now time.Time) bool {
+	return !entry.expires.IsZero() && !now.Before(entry.expires)
+}
+
+func (c *LRUCache) touch(elem *list.Element, now time.Time) {
+	c.list.MoveToFront(elem)
+	entry := elem.Value.(*cacheEntry)
+	entry.expires = c.expiry(now)
+	entry.accessed = now
+}
+
+func (c *LRUCache) remove(elem *list.Element) {
+	entry := elem.Value.(*cacheEntry)
+	c.list.Remove(elem)
+	delete(c.cache, entry.key)
+	c.bytes -= entry.size()
+}
+
+// sweep removes expired entries and returns how many were removed. Every
+// access moves an entry to the front and extends its expiry, so expired
+// entries are always at the back of the list.
+func (c *LRUCache) sweep(now time.Time) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	removed := 0
+	for elem := c.list.Back(); elem != nil; elem = c.list.Back() {
+		if !c.expired(elem.Value.(*cacheEntry), now) {
+			break
+		}
+		c.remove(elem)
+		removed++
+	}
+	c.evictions.Add(int64(removed))
+	return removed
+}
+
+// RunSweeper removes expired entries every interval until ctx is done, so
+// that abandoned conversations do not hold capacity until they are evicted.
+func (c *LRUCache) RunSweeper(ctx context.Context, interval time.Duration) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			c.sweep(now)
+		}
+	}
+}
+
+func (c *LRUCache) evictLRU() {
+	if elem := c.list.Back(); elem != nil {
+		c.remove(elem)
+		c.evictions.Add(1)
+	}
+}
+
+func (c *LRUCache) Size() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.list.Len()
+}
+
+// Bytes returns the total size of the cached keys and reasoning.
+func (c *LRUCache) Bytes() int64 {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.bytes
+}
+
+func (c *LRUCache) Clear() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.cache = make(map[string]*list.Element)
+	c.list = list.New()
+	c.bytes = 0
+}
+
+// Entries lists the unexpired entries, most recently used first.
+func (c *LRUCache) Entries() ([]CacheEntryInfo, error) {
+	now := time.Now()
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	entries := make([]CacheEntryInfo, 0, c.list.Len())
+	for elem := c.list.Front(); elem != nil; elem = elem.Next() {
+		entry := elem.Value.(*cacheEntry)
+		if c.expired(entry, now) {
+			continue
+		}
+		entries = append(entries, CacheEntryInfo{
+			Key:      entry.key,
+			ID:       entry.item.ID,
+			Size:     len(entry.item.Content),
+			Created:  entry.created,
+			LastUsed: entry.accessed,
+		})
+	}
+	return entries, nil
+}
+
+// Peek returns the entry stored under key, as listed by Entries, without
+// counting a lookup or marking it used.
+func (c *LRUCache) Peek(key string) (ReasoningItem, bool, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	elem, exists := c.cache[key]
+	if !exists || c.expired(elem.Value.(*cacheEntry), time.Now()) {
+		return ReasoningItem{}, false, nil
+	}
+	return elem.Value.(*cacheEntry).item, true, nil
+}
+
+// Delete removes the entry stored under key, as listed by Entries.
+func (c *LRUCache) Delete(key string) (bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	elem, exists := c.cache[key]
+	if !exists {
+		return false, nil
+	}
+	c.remove(elem)
+	return true, nil
+}
+
+// Flush removes every entry and returns how many were removed.
+func (c *LRUCache) Flush() (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	n := c.list.Len()
+	c.cache = make(map[string]*list.Element)
+	c.list = list.New()
+	c.bytes = 0
+	return n, nil
+}
@@ -0,0 +1,364 @@
+package adapter
+
+import (
+	"context"
+	"encoding/binary"
+	"log/slog"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const CacheBackendBolt = "bolt"
+
+var (
+	boltEntries = []byte("reasoning")
+	// boltAccessed indexes the entries by when they were last used, with
+	// keys of the access time in big-endian Unix nanoseconds followed by
+	// the entry's key, so that a cursor finds the least recently used first.
+	boltAccessed = []byte("accessed")
+)
+
+// boltOpenTimeout bounds the wait for another process to release the
+// database file.
+const boltOpenTimeout = 5 * time.Second
+
+// BoltCache implements Cache on top of a bbolt database file, for durable
+// reasoning storage on a single node without cgo or a server. Like
+// SQLiteCache, it holds at most capacity entries, evicting the least
+// recently used, and entries expire once they have not been read or written
+// for ttl. Database errors are logged and treated as cache misses.
+type BoltCache struct {
+	db       *bolt.DB
+	capacity int
+	ttl      time.Duration
+	logger   *slog.Logger
+
+	// count is the number of entries. The database is locked by this
+	// process, so nothing else changes it.
+	mutex sync.Mutex
+	count int
+
+	cacheCounters
+}
+
+// NewBoltCache opens or creates the database at path.
+func NewBoltCache(path string, capacity int, ttl time.Duration, logger *slog.Logger) (*BoltCache, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: boltOpenTimeout})
+	if err != nil {
+		return nil, err
+	}
+
+	count := 0
+	err = db.Update(func(tx *bolt.Tx) error {
+		entries, err := tx.CreateBucketIfNotExists(boltEntries)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists(boltAccessed); err != nil {
+			return err
+		}
+		count = entries.Stats().KeyN
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltCache{db: db, capacity: capacity, ttl: ttl, logger: logger, count: count}, nil
+}
+
+func boltAccessedKey(accessed int64, key string) []byte {
+	index := make([]byte, 8, 8+len(key))
+	binary.BigEndian.PutUint64(index, uint64(accessed))
+	return append(index, key...)
+}
+
+func (c *BoltCache) expired(entry storedEntry, now time.Time) bool {
+	return c.ttl > 0 && now.Sub(time.Unix(0, entry.Accessed)) > c.ttl
+}
+
+// load reads the entry stored under key.
+func (c *BoltCache) load(tx *bolt.Tx, key string) (storedEntry, bool, error) {
+	data := tx.Bucket(boltEntries).Get([]byte(key))
+	if data == nil {
+		return storedEntry{}, false, nil
+	}
+	entry, err := decodeEntry(data)
+	return entry, err == nil, err
+}
+
+// store writes an entry under key and indexes it by its access time,
+// replacing the index of the entry it overwrites, if any.
+func (c *BoltCache) store(tx *bolt.Tx, key string, entry storedEntry, previous *storedEntry) error {
+	data, err := encodeEntry(entry)
+	if err != nil {
+		return err
+	}
+	if previous != nil {
+		if err := tx.Bucket(boltAccessed).Delete(boltAccessedKey(previous.Accessed, key)); err != nil {
+			return err
+		}
+	}
+	if err := tx.Bucket(boltEntries).Put([]byte(key), data); err != nil {
+		return err
+	}
+	return tx.Bucket(boltAccessed).Put(boltAccessedKey(entry.Accessed, key), nil)
+}
+
+// remove deletes an entry and its index.
+func (c *BoltCache) remove(tx *bolt.Tx, key string, entry storedEntry) error {
+	if err := tx.Bucket(boltAccessed).Delete(boltAccessedKey(entry.Accessed, key)); err != nil {
+		return err
+	}
+	return tx.Bucket(boltEntries).Delete([]byte(key))
+}
+
+func (c *BoltCache) Get(namespace, key string) (ReasoningItem, bool) {
+	key = namespacedKey(namespace, key)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var item ReasoningItem
+	found, expired := false, false
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		entry, ok, err := c.load(tx, key)
+		if err != nil || !ok {
+			return err
+		}
+
+		now := time.Now()
+		if c.expired(entry, now) {
+			expired = true
+			return c.remove(tx, key, entry)
+		}
+
+		touched := entry
+		touched.Accessed = now.UnixNano()
+		if err := c.store(tx, key, touched, &entry); err != nil {
+			return err
+		}
+		item, found = entry.ReasoningItem, true
+		return nil
+	})
+	if err != nil {
+		c.logger.Error("failed to read reasoning from bolt", "key", key, "error", err)
+		c.lookup(false)
+		return ReasoningItem{}, false
+	}
+	if expired {
+		c.count--
+		c.evictions.Add(1)
+	}
+	c.lookup(found)
+	return item, found
+}
+
+func (c *BoltCache) Put(namespace, key string, item ReasoningItem) {
+	key = namespacedKey(namespace, key)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	count, evicted := c.count, 0
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		previous, exists, err := c.load(tx, key)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UnixNano()
+		entry := storedEntry{ReasoningItem: item, Created: now, Accessed: now}
+		if exists {
+			err = c.store(tx, key, entry, &previous)
+		} else {
+			err = c.store(tx, key, entry, nil)
+			count++
+		}
+		if err != nil {
+			return err
+		}
+
+		for c.capacity > 0 && count > c.capacity {
+			oldest, _ := tx.Bucket(boltAccessed).Cursor().First()
+			if oldest == nil {
+				break
+			}
+			oldest = append([]byte(nil), oldest...)
+			victim := string(oldest[8:])
+			if err := tx.Bucket(boltAccessed).Delete(oldest); err != nil {
+				return err
+			}
+			if err := tx.Bucket(boltEntries).Delete([]byte(victim)); err != nil {
+				return err
+			}
+			count--
+			evicted++
+		}
+		return nil
+	})
+	if err != nil {
+		c.logger.Error("failed to write reasoning to bolt", "key", key, "error", err)
+		return
+	}
+	c.count = count
+	c.inserts.Add(1)
+	c.evictions.Add(int64(evicted))
+}
+
+// Entries lists the unexpired entries, most recently used first.
+func (c *BoltCache) Entries() ([]CacheEntryInfo, error) {
+	now := time.Now()
+
+	var entries []CacheEntryInfo
+	err := c.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(boltAccessed).Cursor()
+		for index, _ := cursor.Last(); index != nil; index, _ = cursor.Prev() {
+			key := string(index[8:])
+			entry, ok, err := c.load(tx, key)
+			if err != nil {
+				return err
+			}
+			if !ok || c.expired(entry, now) {
+				continue
+			}
+
+			info := CacheEntryInfo{
+				Key:      key,
+				ID:       entry.ID,
+				Size:     len(entry.Content),
+				LastUsed: time.Unix(0, entry.Accessed),
+			}
+			if entry.Created != 0 {
+				info.Created = time.Unix(0, entry.Created)
+			}
+			entries = append(entries, info)
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// Peek returns the entry stored under key, as listed by Entries, without
+// counting a lookup or marking it used.
+func (c *BoltCache) Peek(key string) (ReasoningItem, bool, error) {
+	var entry storedEntry
+	var found bool
+	err := c.db.View(func(tx *bolt.Tx) error {
+		var err error
+		entry, found, err = c.load(tx, key)
+		return err
+	})
+	if err != nil || !found || c.expired(entry, time.Now()) {
+		return ReasoningItem{}, false, err
+	}
+	return entry.ReasoningItem, true, nil
+}
+
+// Delete removes the entry stored under key, as listed by Entries.
+func (c *BoltCache) Delete(key string) (bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	found := false
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		entry, ok, err := c.load(tx, key)
+		if err != nil || !ok {
+			return err
+		}
+		found = true
+		return c.remove(tx, key, entry)
+	})
+	if found && err == nil {
+		c.count--
+	}
+	return found && err == nil, err
+}
+
+// Flush removes every entry and returns how many were removed.
+func (c *BoltCache) Flush() (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltEntries, boltAccessed} {
+			if err := tx.DeleteBucket(name); err != nil {
+				return err
+			}
+			if _, err := tx.CreateBucket(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	n := c.count
+	c.count = 0
+	return n, nil
+}
+
+// sweep deletes every expired entry and returns how many were removed.
+func (c *BoltCache) sweep(now time.Time) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	cutoff := now.Add(-c.ttl).UnixNano()
+	removed := 0
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		var expired [][]byte
+		cursor := tx.Bucket(boltAccessed).Cursor()
+		for index, _ := cursor.First(); index != nil && int64(binary.BigEndian.Uint64(index)) < cutoff; index, _ = cursor.Next() {
+			// Keys are only valid until the transaction changes.
+			expired = append(expired, append([]byte(nil), index...))
+		}
+		for _, index := range expired {
+			if err := tx.Bucket(boltAccessed).Delete(index); err != nil {
+				return err
+			}
+			if err := tx.Bucket(boltEntries).Delete(index[8:]); err != nil {
+				return err
+			}
+		}
+		removed = len(expired)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	c.count -= removed
+	c.evictions.Add(int64(removed))
+	return removed, nil
+}
+
+// RunSweeper periodically deletes expired entries until ctx is done. It
+// returns immediately when the cache has no TTL.
+func (c *BoltCache) RunSweeper(ctx context.Context, interval time.Duration) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if n, err := c.sweep(now); err != nil {
+				c.logger.Error("failed to sweep bolt cache", "error", err)
+			} else if n > 0 {
+				c.logger.Debug("swept expired reasoning", "count", n)
+			}
+		}
+	}
+}
+
+func (c *BoltCache) Close() error {
+	return c.db.Close()
+}
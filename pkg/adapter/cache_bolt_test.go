@@ -0,0 +1,131 @@
+package adapter
+
+import (
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBoltCache(t *testing.T, path string, capacity int, ttl time.Duration) *BoltCache {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cache, err := NewBoltCache(path, capacity, ttl, logger)
+	require.NoError(t, err)
+	t.Cleanup(func() { cache.Close() })
+	return cache
+}
+
+func TestBoltCache(t *testing.T) {
+	cache := newTestBoltCache(t, filepath.Join(t.TempDir(), "reasoning.bolt"), 10, 0)
+
+	_, found := cache.Get("", "call_1")
+	assert.False(t, found)
+
+	cache.Put("", "call_1", ReasoningItem{ID: "call_1", Content: "reasoning"})
+	cache.Put("", "call_1", ReasoningItem{ID: "call_1", Content: "updated"})
+	cache.Put("tenant", "call_1", ReasoningItem{ID: "call_1", Content: "tenant"})
+
+	item, found := cache.Get("", "call_1")
+	assert.True(t, found)
+	assert.Equal(t, ReasoningItem{ID: "call_1", Content: "updated"}, item)
+	item, found = cache.Get("tenant", "call_1")
+	assert.True(t, found)
+	assert.Equal(t, "tenant", item.Content)
+	assert.Equal(t, CacheStats{Hits: 2, Misses: 1, Inserts: 3}, cache.Stats())
+}
+
+func TestBoltCache_Durable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reasoning.bolt")
+
+	cache := newTestBoltCache(t, path, 2, 0)
+	cache.Put("", "call_1", ReasoningItem{ID: "call_1", Content: "first"})
+	cache.Put("", "call_2", ReasoningItem{ID: "call_2", Content: "second"})
+	require.NoError(t, cache.Close())
+
+	cache = newTestBoltCache(t, path, 2, 0)
+	item, found := cache.Get("", "call_1")
+	require.True(t, found)
+	assert.Equal(t, "first", item.Content)
+
+	// The entry count survives the restart, so capacity still applies.
+	cache.Put("", "call_3", ReasoningItem{ID: "call_3", Content: "third"})
+	_, found = cache.Get("", "call_2")
+	assert.False(t, found)
+}
+
+func TestBoltCache_Capacity(t *testing.T) {
+	cache := newTestBoltCache(t, filepath.Join(t.TempDir(), "reasoning.bolt"), 2, 0)
+
+	cache.Put("", "call_1", ReasoningItem{ID: "call_1", Content: "first"})
+	cache.Put("", "call_2", ReasoningItem{ID: "call_2", Content: "second"})
+	cache.Get("", "call_1")
+	cache.Put("", "call_3", ReasoningItem{ID: "call_3", Content: "third"})
+
+	_, found := cache.Get("", "call_2")
+	assert.False(t, found, "least recently used entry is evicted")
+	_, found = cache.Get("", "call_1")
+	assert.True(t, found)
+	_, found = cache.Get("", "call_3")
+	assert.True(t, found)
+	assert.Equal(t, int64(1), cache.Stats().Evictions)
+}
+
+func TestBoltCache_TTL(t *testing.T) {
+	cache := newTestBoltCache(t, filepath.Join(t.TempDir(), "reasoning.bolt"), 10, 200*time.Millisecond)
+
+	cache.Put("", "old", ReasoningItem{ID: "old", Content: "old"})
+	n, err := cache.sweep(time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	time.Sleep(150 * time.Millisecond)
+	cache.Put("", "new", ReasoningItem{ID: "new", Content: "new"})
+	time.Sleep(100 * time.Millisecond)
+	n, err = cache.sweep(time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	_, found := cache.Get("", "old")
+	assert.False(t, found)
+	_, found = cache.Get("", "new")
+	assert.True(t, found)
+}
+
+func TestBoltCache_Admin(t *testing.T) {
+	cache := newTestBoltCache(t, filepath.Join(t.TempDir(), "reasoning.bolt"), 10, 0)
+
+	cache.Put("", "call_1", ReasoningItem{ID: "call_1", Content: "reasoning"})
+	cache.Put("tenant", "call_2", ReasoningItem{ID: "call_2", Content: "日本"})
+
+	entries, err := cache.Entries()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "tenant:call_2", entries[0].Key)
+	assert.Equal(t, 6, entries[0].Size)
+	assert.False(t, entries[0].Created.IsZero())
+	assert.Equal(t, "call_1", entries[1].Key)
+
+	item, found, err := cache.Peek("tenant:call_2")
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, "日本", item.Content)
+	assert.Equal(t, int64(0), cache.Stats().Hits)
+
+	found, err = cache.Delete("call_1")
+	require.NoError(t, err)
+	assert.True(t, found)
+	found, err = cache.Delete("call_1")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	removed, err := cache.Flush()
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	entries, err = cache.Entries()
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
@@ -0,0 +1,131 @@
+package adapter
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// encryptedContentPrefix marks reasoning encrypted by EncryptedCache. The
+// version allows the format to change without misreading old entries.
+const encryptedContentPrefix = "enc1:"
+
+// EncryptedCache encrypts the reasoning of every entry with AES-256-GCM
+// before it reaches the cache it wraps, so that chain-of-thought, which can
+// repeat sensitive user data, is not stored in plaintext in a cache file,
+// Redis, SQLite or any other backend. Keys and IDs are stored as they are.
+// Each entry is authenticated along with its key, so that it cannot be
+// moved to another key. Entries written before encryption was enabled are
+// read unchanged; entries that fail to decrypt, e.g. after the key changed,
+// are treated as cache misses.
+type EncryptedCache struct {
+	cache  Cache
+	cipher *ReasoningCipher
+	logger *slog.Logger
+}
+
+// encryptedAdminCache is an EncryptedCache over a cache that supports the
+// admin API.
+type encryptedAdminCache struct {
+	*EncryptedCache
+	admin AdminCache
+}
+
+// NewEncryptedCache wraps cache with encryption under cipher's key. The
+// result supports the admin API if cache does.
+func NewEncryptedCache(cache Cache, cipher *ReasoningCipher, logger *slog.Logger) Cache {
+	encrypted := &EncryptedCache{cache: cache, cipher: cipher, logger: logger}
+	if admin, ok := cache.(AdminCache); ok {
+		return &encryptedAdminCache{EncryptedCache: encrypted, admin: admin}
+	}
+	return encrypted
+}
+
+// Unwrap returns the wrapped cache.
+func (c *EncryptedCache) Unwrap() Cache {
+	return c.cache
+}
+
+func (c *EncryptedCache) encrypt(key string, item ReasoningItem) ReasoningItem {
+	sealed := c.cipher.seal([]byte(item.Content), []byte(key))
+	item.Content = encryptedContentPrefix + base64.RawStdEncoding.EncodeToString(sealed)
+	return item
+}
+
+func (c *EncryptedCache) decrypt(key string, item ReasoningItem) (ReasoningItem, error) {
+	encoded, ok := strings.CutPrefix(item.Content, encryptedContentPrefix)
+	if !ok {
+		return item, nil
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return ReasoningItem{}, fmt.Errorf("decoding encrypted reasoning: %w", err)
+	}
+	plaintext, err := c.cipher.open(sealed, []byte(key))
+	if err != nil {
+		return ReasoningItem{}, fmt.Errorf("decrypting reasoning: %w", err)
+	}
+	item.Content = string(plaintext)
+	return item, nil
+}
+
+func (c *EncryptedCache) Put(namespace, key string, item ReasoningItem) {
+	c.cache.Put(namespace, key, c.encrypt(namespacedKey(namespace, key), item))
+}
+
+func (c *EncryptedCache) Get(namespace, key string) (ReasoningItem, bool) {
+	item, found := c.cache.Get(namespace, key)
+	if !found {
+		return ReasoningItem{}, false
+	}
+	item, err := c.decrypt(namespacedKey(namespace, key), item)
+	if err != nil {
+		c.logger.Error("failed to decrypt cached reasoning", "key", key, "error", err)
+		return ReasoningItem{}, false
+	}
+	return item, true
+}
+
+// Stats returns the counters of the wrapped cache.
+func (c *EncryptedCache) Stats() CacheStats {
+	return c.cache.Stats()
+}
+
+// Entries lists the entries of the wrapped cache, with the sizes of their
+// encrypted reasoning.
+func (c *encryptedAdminCache) Entries() ([]CacheEntryInfo, error) {
+	return c.admin.Entries()
+}
+
+// Peek returns the decrypted entry stored under key, as listed by Entries.
+func (c *encryptedAdminCache) Peek(key string) (ReasoningItem, bool, error) {
+	item, found, err := c.admin.Peek(key)
+	if err != nil || !found {
+		return item, found, err
+	}
+	item, err = c.decrypt(key, item)
+	if err != nil {
+		return ReasoningItem{}, false, err
+	}
+	return item, true, nil
+}
+
+func (c *encryptedAdminCache) Delete(key string) (bool, error) {
+	return c.admin.Delete(key)
+}
+
+func (c *encryptedAdminCache) Flush() (int, error) {
+	return c.admin.Flush()
+}
+
+// unwrapCache returns the innermost cache under any wrappers.
+func unwrapCache(cache Cache) Cache {
+	for {
+		wrapper, ok := cache.(interface{ Unwrap() Cache })
+		if !ok {
+			return cache
+		}
+		cache = wrapper.Unwrap()
+	}
+}
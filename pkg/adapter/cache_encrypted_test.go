@@ -0,0 +1,94 @@
+package adapter
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestEncryptedCache(t *testing.T, inner Cache, key []byte) Cache {
+	c, err := NewReasoningCipher(key)
+	require.NoError(t, err)
+	return NewEncryptedCache(inner, c, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestEncryptedCache(t *testing.T) {
+	inner := NewLRUCache(10)
+	cache := newTestEncryptedCache(t, inner, testReasoningKey)
+
+	cache.Put("tenant", "call_1", ReasoningItem{ID: "call_1", Content: "Look up Paris."})
+
+	stored, found := inner.Get("tenant", "call_1")
+	require.True(t, found)
+	assert.Equal(t, "call_1", stored.ID)
+	assert.True(t, strings.HasPrefix(stored.Content, encryptedContentPrefix))
+	assert.NotContains(t, stored.Content, "Paris")
+
+	item, found := cache.Get("tenant", "call_1")
+	require.True(t, found)
+	assert.Equal(t, ReasoningItem{ID: "call_1", Content: "Look up Paris."}, item)
+
+	// Entries are bound to their key.
+	inner.Put("tenant", "call_2", stored)
+	_, found = cache.Get("tenant", "call_2")
+	assert.False(t, found)
+	inner.Put("", "call_1", stored)
+	_, found = cache.Get("", "call_1")
+	assert.False(t, found)
+
+	// Entries from before encryption was enabled are read as they are.
+	inner.Put("", "call_3", ReasoningItem{ID: "call_3", Content: "Plain."})
+	item, found = cache.Get("", "call_3")
+	require.True(t, found)
+	assert.Equal(t, "Plain.", item.Content)
+
+	// Entries under another key are misses.
+	other := newTestEncryptedCache(t, inner, bytes.Repeat([]byte{8}, 32))
+	_, found = other.Get("tenant", "call_1")
+	assert.False(t, found)
+
+	assert.Equal(t, inner.Stats(), cache.Stats())
+	assert.Same(t, inner, unwrapCache(cache))
+}
+
+func TestEncryptedCache_Admin(t *testing.T) {
+	inner := NewLRUCache(10)
+	cache := newTestEncryptedCache(t, inner, testReasoningKey)
+
+	admin, ok := cache.(AdminCache)
+	require.True(t, ok)
+
+	cache.Put("tenant", "call_1", ReasoningItem{ID: "call_1", Content: "first"})
+	cache.Put("", "call_2", ReasoningItem{ID: "call_2", Content: "second"})
+
+	entries, err := admin.Entries()
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	item, found, err := admin.Peek("tenant:call_1")
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, "first", item.Content)
+
+	removed, err := admin.Delete("call_2")
+	require.NoError(t, err)
+	assert.True(t, removed)
+
+	n, err := admin.Flush()
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+}
+
+func TestEncryptedCache_NoAdmin(t *testing.T) {
+	// Embedding the interface hides the admin methods of the LRU cache.
+	inner := struct{ Cache }{NewLRUCache(10)}
+	cache := newTestEncryptedCache(t, inner, testReasoningKey)
+
+	_, ok := cache.(AdminCache)
+	assert.False(t, ok)
+}
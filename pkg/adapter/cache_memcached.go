@@ -0,0 +1,132 @@
+package adapter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+const (
+	CacheBackendMemcached = "memcached"
+
+	memcachedKeyPrefix = "gpt-oss-adapter:reasoning:"
+	memcachedTimeout   = 500 * time.Millisecond
+
+	// memcachedMaxKey is the longest key memcached accepts.
+	memcachedMaxKey = 250
+	// memcachedMaxRelative is the longest expiry memcached takes as relative
+	// to now; longer ones are read as Unix timestamps.
+	memcachedMaxRelative = 30 * 24 * time.Hour
+)
+
+// MemcachedCache implements Cache on top of one or more memcached servers,
+// so that several adapter replicas can share reasoning state. Memcached
+// cannot list its keys, so the cache does not support the admin API.
+// Errors are logged and treated as cache misses, as with RedisCache.
+type MemcachedCache struct {
+	client *memcache.Client
+	ttl    time.Duration
+	logger *slog.Logger
+
+	// Memcached evicts and expires keys itself, so evictions are not
+	// counted.
+	cacheCounters
+}
+
+// NewMemcachedCache connects to the memcached servers at the given
+// host:port addresses, spreading keys between them, and verifies that they
+// are reachable. Entries expire once they have not been read or written for
+// ttl; zero leaves them to memcached's own eviction.
+func NewMemcachedCache(servers []string, ttl time.Duration, logger *slog.Logger) (*MemcachedCache, error) {
+	selector := new(memcache.ServerList)
+	if err := selector.SetServers(servers...); err != nil {
+		return nil, err
+	}
+
+	client := memcache.NewFromSelector(selector)
+	client.Timeout = memcachedTimeout
+	if err := client.Ping(); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &MemcachedCache{client: client, ttl: ttl, logger: logger}, nil
+}
+
+// memcachedKey returns the memcached key for a cache key. Keys that are too
+// long or contain whitespace or control characters, which memcached
+// rejects, are replaced by their hash.
+func memcachedKey(key string) string {
+	if len(memcachedKeyPrefix)+len(key) <= memcachedMaxKey && !strings.ContainsFunc(key, func(r rune) bool { return r <= ' ' || r == 0x7f }) {
+		return memcachedKeyPrefix + key
+	}
+	sum := sha256.Sum256([]byte(key))
+	return memcachedKeyPrefix + "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// expiration returns the expiry of an entry written or read now.
+func (c *MemcachedCache) expiration() int32 {
+	switch {
+	case c.ttl <= 0:
+		return 0
+	case c.ttl > memcachedMaxRelative:
+		return int32(time.Now().Add(c.ttl).Unix())
+	default:
+		return int32(max(c.ttl/time.Second, 1))
+	}
+}
+
+func (c *MemcachedCache) Get(namespace, key string) (ReasoningItem, bool) {
+	key = namespacedKey(namespace, key)
+
+	stored, err := c.client.Get(memcachedKey(key))
+	if errors.Is(err, memcache.ErrCacheMiss) {
+		c.lookup(false)
+		return ReasoningItem{}, false
+	}
+	if err != nil {
+		c.logger.Error("failed to read reasoning from memcached", "key", key, "error", err)
+		c.lookup(false)
+		return ReasoningItem{}, false
+	}
+
+	entry, err := decodeEntry(stored.Value)
+	if err != nil {
+		c.logger.Error("invalid reasoning item in memcached", "key", key, "error", err)
+		c.lookup(false)
+		return ReasoningItem{}, false
+	}
+
+	if c.ttl > 0 {
+		if err := c.client.Touch(memcachedKey(key), c.expiration()); err != nil && !errors.Is(err, memcache.ErrCacheMiss) {
+			c.logger.Error("failed to touch reasoning in memcached", "key", key, "error", err)
+		}
+	}
+	c.lookup(true)
+	return entry.ReasoningItem, true
+}
+
+func (c *MemcachedCache) Put(namespace, key string, item ReasoningItem) {
+	key = namespacedKey(namespace, key)
+
+	data, err := encodeEntry(storedEntry{ReasoningItem: item, Created: time.Now().UnixNano()})
+	if err != nil {
+		return
+	}
+
+	err = c.client.Set(&memcache.Item{Key: memcachedKey(key), Value: data, Expiration: c.expiration()})
+	if err != nil {
+		c.logger.Error("failed to write reasoning to memcached", "key", key, "error", err)
+		return
+	}
+	c.inserts.Add(1)
+}
+
+func (c *MemcachedCache) Close() error {
+	return c.client.Close()
+}
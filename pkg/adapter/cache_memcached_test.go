@@ -0,0 +1,150 @@
+package adapter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMemcached speaks enough of the memcached text protocol for
+// MemcachedCache: version, set, gets and touch.
+type fakeMemcached struct {
+	listener net.Listener
+
+	mutex       sync.Mutex
+	values      map[string][]byte
+	expirations map[string]int
+}
+
+func newFakeMemcached(t *testing.T) *fakeMemcached {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &fakeMemcached{listener: listener, values: make(map[string][]byte), expirations: make(map[string]int)}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (s *fakeMemcached) serve(conn net.Conn) {
+	defer conn.Close()
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	for {
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			return
+		}
+
+		s.mutex.Lock()
+		switch fields[0] {
+		case "version":
+			rw.WriteString("VERSION 1.6.0\r\n")
+		case "set":
+			size, _ := strconv.Atoi(fields[4])
+			value := make([]byte, size+2)
+			io.ReadFull(rw, value)
+			s.values[fields[1]] = value[:size]
+			s.expirations[fields[1]], _ = strconv.Atoi(fields[3])
+			rw.WriteString("STORED\r\n")
+		case "gets":
+			for _, key := range fields[1:] {
+				if value, ok := s.values[key]; ok {
+					fmt.Fprintf(rw, "VALUE %s 0 %d 1\r\n%s\r\n", key, len(value), value)
+				}
+			}
+			rw.WriteString("END\r\n")
+		case "touch":
+			if _, ok := s.values[fields[1]]; ok {
+				s.expirations[fields[1]], _ = strconv.Atoi(fields[2])
+				rw.WriteString("TOUCHED\r\n")
+			} else {
+				rw.WriteString("NOT_FOUND\r\n")
+			}
+		default:
+			rw.WriteString("ERROR\r\n")
+		}
+		s.mutex.Unlock()
+		rw.Flush()
+	}
+}
+
+func (s *fakeMemcached) expiration(key string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.expirations[key]
+}
+
+func (s *fakeMemcached) keys() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var keys []string
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func TestMemcachedCache(t *testing.T) {
+	server := newFakeMemcached(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	cache, err := NewMemcachedCache([]string{server.listener.Addr().String()}, time.Hour, logger)
+	require.NoError(t, err)
+	defer cache.Close()
+
+	_, found := cache.Get("", "call_1")
+	assert.False(t, found)
+
+	cache.Put("", "call_1", ReasoningItem{ID: "call_1", Content: "reasoning"})
+	cache.Put("tenant", "call_1", ReasoningItem{ID: "call_1", Content: "tenant"})
+
+	item, found := cache.Get("", "call_1")
+	require.True(t, found)
+	assert.Equal(t, ReasoningItem{ID: "call_1", Content: "reasoning"}, item)
+	item, found = cache.Get("tenant", "call_1")
+	require.True(t, found)
+	assert.Equal(t, "tenant", item.Content)
+	assert.Equal(t, CacheStats{Hits: 2, Misses: 1, Inserts: 2}, cache.Stats())
+	assert.Equal(t, 3600, server.expiration("gpt-oss-adapter:reasoning:call_1"))
+
+	// IDs memcached would reject as keys are stored under their hash.
+	cache.Put("", "call with spaces", ReasoningItem{ID: "call with spaces", Content: "spaced"})
+	item, found = cache.Get("", "call with spaces")
+	require.True(t, found)
+	assert.Equal(t, "spaced", item.Content)
+	assert.Contains(t, server.keys(), memcachedKey("call with spaces"))
+	assert.True(t, strings.HasPrefix(memcachedKey("call with spaces"), "gpt-oss-adapter:reasoning:sha256:"))
+}
+
+func TestNewMemcachedCache_Unreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	listener.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	_, err = NewMemcachedCache([]string{addr}, 0, logger)
+	assert.Error(t, err)
+}
@@ -0,0 +1,227 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	CacheBackendMemory = "memory"
+	CacheBackendRedis  = "redis"
+
+	redisKeyPrefix = "gpt-oss-adapter:reasoning:"
+	redisTimeout   = 2 * time.Second
+
+	// redisAdminTimeout bounds admin operations, which scan every key.
+	redisAdminTimeout = 30 * time.Second
+	redisScanCount    = 100
+)
+
+// RedisCache implements Cache on top of Redis so that several adapter
+// replicas can share reasoning state. Redis errors are logged and treated as
+// cache misses, since a missing reasoning item only degrades the next turn.
+type RedisCache struct {
+	client *redis.Client
+	ttl    time.Duration
+	logger *slog.Logger
+
+	// Redis expires keys itself, so evictions are not counted.
+	cacheCounters
+}
+
+// NewRedisCache connects to the Redis server at url, e.g.
+// redis://localhost:6379/0, and verifies the connection. Entries expire once
+// they have not been read or written for ttl; zero disables expiry.
+func NewRedisCache(url string, ttl time.Duration, logger *slog.Logger) (*RedisCache, error) {
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	client := redis.NewClient(options)
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &RedisCache{client: client, ttl: ttl, logger: logger}, nil
+}
+
+func (c *RedisCache) Get(namespace, key string) (ReasoningItem, bool) {
+	key = namespacedKey(namespace, key)
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	var data []byte
+	var err error
+	if c.ttl > 0 {
+		data, err = c.client.GetEx(ctx, redisKeyPrefix+key, c.ttl).Bytes()
+	} else {
+		data, err = c.client.Get(ctx, redisKeyPrefix+key).Bytes()
+	}
+	if errors.Is(err, redis.Nil) {
+		c.lookup(false)
+		return ReasoningItem{}, false
+	}
+	if err != nil {
+		c.logger.Error("failed to read reasoning from redis", "key", key, "error", err)
+		c.lookup(false)
+		return ReasoningItem{}, false
+	}
+
+	entry, err := decodeEntry(data)
+	if err != nil {
+		c.logger.Error("invalid reasoning item in redis", "key", key, "error", err)
+		c.lookup(false)
+		return ReasoningItem{}, false
+	}
+	c.lookup(true)
+	return entry.ReasoningItem, true
+}
+
+func (c *RedisCache) Put(namespace, key string, item ReasoningItem) {
+	key = namespacedKey(namespace, key)
+
+	data, err := encodeEntry(storedEntry{ReasoningItem: item, Created: time.Now().UnixNano()})
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	if err := c.client.Set(ctx, redisKeyPrefix+key, data, c.ttl).Err(); err != nil {
+		c.logger.Error("failed to write reasoning to redis", "key", key, "error", err)
+		return
+	}
+	c.inserts.Add(1)
+}
+
+// scan returns the keys of all reasoning entries, with the key prefix.
+func (c *RedisCache) scan(ctx context.Context) ([]string, error) {
+	var keys []string
+	iter := c.client.Scan(ctx, 0, redisKeyPrefix+"*", redisScanCount).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	return keys, iter.Err()
+}
+
+// Entries lists the stored entries. Redis does not track access times, so
+// LastUsed is derived from the remaining TTL and left zero without one.
+func (c *RedisCache) Entries() ([]CacheEntryInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisAdminTimeout)
+	defer cancel()
+
+	keys, err := c.scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := c.client.Pipeline()
+	values := make([]*redis.StringCmd, len(keys))
+	ttls := make([]*redis.DurationCmd, len(keys))
+	for i, key := range keys {
+		values[i] = pipe.Get(ctx, key)
+		ttls[i] = pipe.PTTL(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	now := time.Now()
+	entries := make([]CacheEntryInfo, 0, len(keys))
+	for i, key := range keys {
+		data, err := values[i].Bytes()
+		if err != nil {
+			// Expired between the scan and the read.
+			continue
+		}
+
+		stored, err := decodeEntry(data)
+		if err != nil {
+			c.logger.Error("invalid reasoning item in redis", "key", key, "error", err)
+			continue
+		}
+
+		entry := CacheEntryInfo{
+			Key:  strings.TrimPrefix(key, redisKeyPrefix),
+			ID:   stored.ID,
+			Size: len(stored.Content),
+		}
+		if stored.Created != 0 {
+			entry.Created = time.Unix(0, stored.Created)
+		}
+		if remaining := ttls[i].Val(); c.ttl > 0 && remaining > 0 {
+			entry.LastUsed = now.Add(remaining - c.ttl)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Peek returns the entry stored under key, as listed by Entries, without
+// counting a lookup or extending its expiry.
+func (c *RedisCache) Peek(key string) (ReasoningItem, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	data, err := c.client.Get(ctx, redisKeyPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return ReasoningItem{}, false, nil
+	}
+	if err != nil {
+		return ReasoningItem{}, false, err
+	}
+
+	entry, err := decodeEntry(data)
+	if err != nil {
+		return ReasoningItem{}, false, err
+	}
+	return entry.ReasoningItem, true, nil
+}
+
+// Delete removes the entry stored under key, as listed by Entries.
+func (c *RedisCache) Delete(key string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	n, err := c.client.Del(ctx, redisKeyPrefix+key).Result()
+	return n > 0, err
+}
+
+// Flush removes every reasoning entry, leaving other keys in the database
+// alone, and returns how many were removed.
+func (c *RedisCache) Flush() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisAdminTimeout)
+	defer cancel()
+
+	keys, err := c.scan(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for start := 0; start < len(keys); start += redisScanCount {
+		end := min(start+redisScanCount, len(keys))
+		n, err := c.client.Del(ctx, keys[start:end]...).Result()
+		removed += int(n)
+		if err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+func (c *RedisCache) Close() error {
+	return c.client.Close()
+}
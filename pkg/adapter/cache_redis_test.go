@@ -0,0 +1,112 @@
+package adapter
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisCache(t *testing.T) {
+	server := miniredis.RunT(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	cache, err := NewRedisCache("redis://"+server.Addr(), 0, logger)
+	require.NoError(t, err)
+	defer cache.Close()
+
+	_, found := cache.Get("", "call_1")
+	assert.False(t, found)
+
+	cache.Put("", "call_1", ReasoningItem{ID: "call_1", Content: "reasoning"})
+
+	item, found := cache.Get("", "call_1")
+	assert.True(t, found)
+	assert.Equal(t, ReasoningItem{ID: "call_1", Content: "reasoning"}, item)
+
+	// A second replica sees the same entries.
+	other, err := NewRedisCache("redis://"+server.Addr(), 0, logger)
+	require.NoError(t, err)
+	defer other.Close()
+
+	item, found = other.Get("", "call_1")
+	assert.True(t, found)
+	assert.Equal(t, "reasoning", item.Content)
+
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 1, Inserts: 1}, cache.Stats())
+}
+
+func TestNewRedisCache_Unreachable(t *testing.T) {
+	server := miniredis.RunT(t)
+	addr := server.Addr()
+	server.Close()
+
+	_, err := NewRedisCache("redis://"+addr, 0, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	assert.Error(t, err)
+}
+
+func TestRedisCache_TTL(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	cache, err := NewRedisCache("redis://"+server.Addr(), time.Hour, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+	defer cache.Close()
+
+	cache.Put("", "call_1", ReasoningItem{ID: "call_1", Content: "reasoning"})
+
+	server.FastForward(45 * time.Minute)
+	_, found := cache.Get("", "call_1")
+	assert.True(t, found, "reads extend the expiry")
+
+	server.FastForward(45 * time.Minute)
+	_, found = cache.Get("", "call_1")
+	assert.True(t, found)
+
+	server.FastForward(61 * time.Minute)
+	_, found = cache.Get("", "call_1")
+	assert.False(t, found)
+}
+
+func TestRedisCache_Admin(t *testing.T) {
+	server := miniredis.RunT(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	cache, err := NewRedisCache("redis://"+server.Addr(), time.Hour, logger)
+	require.NoError(t, err)
+	defer cache.Close()
+
+	cache.Put("", "call_1", ReasoningItem{ID: "call_1", Content: "reasoning"})
+	cache.Put("tenant", "call_2", ReasoningItem{ID: "call_2", Content: "more"})
+	server.Set("unrelated", "value")
+
+	entries, err := cache.Entries()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	keys := []string{entries[0].Key, entries[1].Key}
+	assert.ElementsMatch(t, []string{"call_1", "tenant:call_2"}, keys)
+	for _, entry := range entries {
+		assert.False(t, entry.LastUsed.IsZero())
+		assert.False(t, entry.Created.IsZero())
+	}
+
+	item, found, err := cache.Peek("tenant:call_2")
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, "more", item.Content)
+	assert.Equal(t, int64(0), cache.Stats().Hits)
+
+	found, err = cache.Delete("tenant:call_2")
+	require.NoError(t, err)
+	assert.True(t, found)
+	_, ok := cache.Get("tenant", "call_2")
+	assert.False(t, ok)
+
+	removed, err := cache.Flush()
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	assert.True(t, server.Exists("unrelated"))
+}
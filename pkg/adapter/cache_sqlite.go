@@ -0,0 +1,238 @@
+package adapter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/url"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const CacheBackendSQLite = "sqlite"
+
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS reasoning (
+	key      TEXT PRIMARY KEY,
+	id       TEXT NOT NULL,
+	content  TEXT NOT NULL,
+	created  INTEGER NOT NULL DEFAULT 0,
+	accessed INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS reasoning_accessed ON reasoning (accessed);`
+
+// SQLiteCache implements Cache on top of a single-file SQLite database, for
+// durable reasoning storage on a single node. Like LRUCache, it holds at most
+// capacity entries, evicting the least recently used, and entries expire once
+// they have not been read or written for ttl. Database errors are logged and
+// treated as cache misses.
+type SQLiteCache struct {
+	db       *sql.DB
+	capacity int
+	ttl      time.Duration
+	logger   *slog.Logger
+
+	cacheCounters
+}
+
+// NewSQLiteCache opens or creates the database at path.
+func NewSQLiteCache(path string, capacity int, ttl time.Duration, logger *slog.Logger) (*SQLiteCache, error) {
+	dsn := "file:" + url.PathEscape(path) + "?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows a single writer; serializing access in the pool avoids
+	// SQLITE_BUSY errors under concurrent requests.
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := migrateSQLiteCache(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteCache{db: db, capacity: capacity, ttl: ttl, logger: logger}, nil
+}
+
+// migrateSQLiteCache adds the created column to databases made before it
+// existed.
+func migrateSQLiteCache(db *sql.DB) error {
+	var n int
+	err := db.QueryRow(`SELECT count(*) FROM pragma_table_info('reasoning') WHERE name = 'created'`).Scan(&n)
+	if err != nil || n > 0 {
+		return err
+	}
+	_, err = db.Exec(`ALTER TABLE reasoning ADD COLUMN created INTEGER NOT NULL DEFAULT 0`)
+	return err
+}
+
+func (c *SQLiteCache) Get(namespace, key string) (ReasoningItem, bool) {
+	key = namespacedKey(namespace, key)
+
+	var item ReasoningItem
+	var accessed int64
+	err := c.db.QueryRow(`SELECT id, content, accessed FROM reasoning WHERE key = ?`, key).Scan(&item.ID, &item.Content, &accessed)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.lookup(false)
+		return ReasoningItem{}, false
+	}
+	if err != nil {
+		c.logger.Error("failed to read reasoning from sqlite", "key", key, "error", err)
+		c.lookup(false)
+		return ReasoningItem{}, false
+	}
+
+	now := time.Now()
+	if c.ttl > 0 && now.Sub(time.Unix(0, accessed)) > c.ttl {
+		if _, err := c.db.Exec(`DELETE FROM reasoning WHERE key = ?`, key); err != nil {
+			c.logger.Error("failed to delete expired reasoning from sqlite", "key", key, "error", err)
+		} else {
+			c.evictions.Add(1)
+		}
+		c.lookup(false)
+		return ReasoningItem{}, false
+	}
+
+	if _, err := c.db.Exec(`UPDATE reasoning SET accessed = ? WHERE key = ?`, now.UnixNano(), key); err != nil {
+		c.logger.Error("failed to touch reasoning in sqlite", "key", key, "error", err)
+	}
+	c.lookup(true)
+	return item, true
+}
+
+func (c *SQLiteCache) Put(namespace, key string, item ReasoningItem) {
+	key = namespacedKey(namespace, key)
+
+	now := time.Now().UnixNano()
+	_, err := c.db.Exec(`INSERT INTO reasoning (key, id, content, created, accessed) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET id = excluded.id, content = excluded.content, created = excluded.created, accessed = excluded.accessed`,
+		key, item.ID, item.Content, now, now)
+	if err != nil {
+		c.logger.Error("failed to write reasoning to sqlite", "key", key, "error", err)
+		return
+	}
+	c.inserts.Add(1)
+
+	if c.capacity > 0 {
+		result, err := c.db.Exec(`DELETE FROM reasoning WHERE key IN (
+			SELECT key FROM reasoning ORDER BY accessed DESC LIMIT -1 OFFSET ?)`, c.capacity)
+		if err != nil {
+			c.logger.Error("failed to evict reasoning from sqlite", "error", err)
+			return
+		}
+		if n, err := result.RowsAffected(); err == nil {
+			c.evictions.Add(n)
+		}
+	}
+}
+
+// Entries lists the unexpired entries, most recently used first.
+func (c *SQLiteCache) Entries() ([]CacheEntryInfo, error) {
+	rows, err := c.db.Query(`SELECT key, id, length(CAST(content AS BLOB)), created, accessed FROM reasoning ORDER BY accessed DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	var entries []CacheEntryInfo
+	for rows.Next() {
+		var entry CacheEntryInfo
+		var created, accessed int64
+		if err := rows.Scan(&entry.Key, &entry.ID, &entry.Size, &created, &accessed); err != nil {
+			return nil, err
+		}
+		if created != 0 {
+			entry.Created = time.Unix(0, created)
+		}
+		entry.LastUsed = time.Unix(0, accessed)
+		if c.ttl > 0 && now.Sub(entry.LastUsed) > c.ttl {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// Peek returns the entry stored under key, as listed by Entries, without
+// counting a lookup or marking it used.
+func (c *SQLiteCache) Peek(key string) (ReasoningItem, bool, error) {
+	var item ReasoningItem
+	var accessed int64
+	err := c.db.QueryRow(`SELECT id, content, accessed FROM reasoning WHERE key = ?`, key).Scan(&item.ID, &item.Content, &accessed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ReasoningItem{}, false, nil
+	}
+	if err != nil {
+		return ReasoningItem{}, false, err
+	}
+	if c.ttl > 0 && time.Since(time.Unix(0, accessed)) > c.ttl {
+		return ReasoningItem{}, false, nil
+	}
+	return item, true, nil
+}
+
+// Delete removes the entry stored under key, as listed by Entries.
+func (c *SQLiteCache) Delete(key string) (bool, error) {
+	result, err := c.db.Exec(`DELETE FROM reasoning WHERE key = ?`, key)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// Flush removes every entry and returns how many were removed.
+func (c *SQLiteCache) Flush() (int, error) {
+	result, err := c.db.Exec(`DELETE FROM reasoning`)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// sweep deletes every expired entry and returns how many were removed.
+func (c *SQLiteCache) sweep(now time.Time) (int, error) {
+	result, err := c.db.Exec(`DELETE FROM reasoning WHERE accessed < ?`, now.Add(-c.ttl).UnixNano())
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	c.evictions.Add(n)
+	return int(n), err
+}
+
+// RunSweeper periodically deletes expired entries until ctx is done. It
+// returns immediately when the cache has no TTL.
+func (c *SQLiteCache) RunSweeper(ctx context.Context, interval time.Duration) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if n, err := c.sweep(now); err != nil {
+				c.logger.Error("failed to sweep sqlite cache", "error", err)
+			} else if n > 0 {
+				c.logger.Debug("swept expired reasoning", "count", n)
+			}
+		}
+	}
+}
+
+func (c *SQLiteCache) Close() error {
+	return c.db.Close()
+}
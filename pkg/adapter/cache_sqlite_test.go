@@ -0,0 +1,134 @@
+package adapter
+
+import (
+	"database/sql"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSQLiteCache(t *testing.T, path string, capacity int, ttl time.Duration) *SQLiteCache {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cache, err := NewSQLiteCache(path, capacity, ttl, logger)
+	require.NoError(t, err)
+	t.Cleanup(func() { cache.Close() })
+	return cache
+}
+
+func TestSQLiteCache(t *testing.T) {
+	cache := newTestSQLiteCache(t, filepath.Join(t.TempDir(), "reasoning.db"), 10, 0)
+
+	_, found := cache.Get("", "call_1")
+	assert.False(t, found)
+
+	cache.Put("", "call_1", ReasoningItem{ID: "call_1", Content: "reasoning"})
+	cache.Put("", "call_1", ReasoningItem{ID: "call_1", Content: "updated"})
+
+	item, found := cache.Get("", "call_1")
+	assert.True(t, found)
+	assert.Equal(t, ReasoningItem{ID: "call_1", Content: "updated"}, item)
+}
+
+func TestSQLiteCache_Durable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reasoning.db")
+
+	cache := newTestSQLiteCache(t, path, 10, 0)
+	cache.Put("", "call_1", ReasoningItem{ID: "call_1", Content: "reasoning"})
+	require.NoError(t, cache.Close())
+
+	reopened := newTestSQLiteCache(t, path, 10, 0)
+	item, found := reopened.Get("", "call_1")
+	assert.True(t, found)
+	assert.Equal(t, "reasoning", item.Content)
+}
+
+func TestSQLiteCache_Capacity(t *testing.T) {
+	cache := newTestSQLiteCache(t, filepath.Join(t.TempDir(), "reasoning.db"), 2, 0)
+
+	cache.Put("", "a", ReasoningItem{ID: "a", Content: "a"})
+	cache.Put("", "b", ReasoningItem{ID: "b", Content: "b"})
+	cache.Get("", "a")
+	cache.Put("", "c", ReasoningItem{ID: "c", Content: "c"})
+
+	_, found := cache.Get("", "b")
+	assert.False(t, found, "least recently used entry should be evicted")
+	_, found = cache.Get("", "a")
+	assert.True(t, found)
+	_, found = cache.Get("", "c")
+	assert.True(t, found)
+
+	assert.Equal(t, CacheStats{Hits: 3, Misses: 1, Inserts: 3, Evictions: 1}, cache.Stats())
+}
+
+func TestSQLiteCache_Migrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reasoning.db")
+	db, err := sql.Open("sqlite", "file:"+path)
+	require.NoError(t, err)
+	_, err = db.Exec(`CREATE TABLE reasoning (key TEXT PRIMARY KEY, id TEXT NOT NULL, content TEXT NOT NULL, accessed INTEGER NOT NULL);
+		INSERT INTO reasoning VALUES ('call_1', 'call_1', 'reasoning', 1)`)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	cache := newTestSQLiteCache(t, path, 10, 0)
+	cache.Put("", "call_2", ReasoningItem{ID: "call_2", Content: "more"})
+
+	entries, err := cache.Entries()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.False(t, entries[0].Created.IsZero())
+	assert.True(t, entries[1].Created.IsZero(), "written before the column existed")
+}
+
+func TestSQLiteCache_TTL(t *testing.T) {
+	cache := newTestSQLiteCache(t, filepath.Join(t.TempDir(), "reasoning.db"), 10, time.Hour)
+
+	cache.Put("", "old", ReasoningItem{ID: "old", Content: "old"})
+	cache.Put("", "new", ReasoningItem{ID: "new", Content: "new"})
+	_, err := cache.db.Exec(`UPDATE reasoning SET accessed = ? WHERE key = 'old'`, time.Now().Add(-2*time.Hour).UnixNano())
+	require.NoError(t, err)
+
+	n, err := cache.sweep(time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	_, found := cache.Get("", "old")
+	assert.False(t, found)
+	_, found = cache.Get("", "new")
+	assert.True(t, found)
+}
+
+func TestSQLiteCache_Admin(t *testing.T) {
+	cache := newTestSQLiteCache(t, filepath.Join(t.TempDir(), "reasoning.db"), 10, 0)
+
+	cache.Put("", "call_1", ReasoningItem{ID: "call_1", Content: "reasoning"})
+	cache.Put("tenant", "call_2", ReasoningItem{ID: "call_2", Content: "日本"})
+
+	entries, err := cache.Entries()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "tenant:call_2", entries[0].Key)
+	assert.Equal(t, 6, entries[0].Size)
+	assert.Equal(t, "call_1", entries[1].Key)
+
+	item, found, err := cache.Peek("tenant:call_2")
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, "日本", item.Content)
+	assert.Equal(t, int64(0), cache.Stats().Hits)
+
+	found, err = cache.Delete("call_1")
+	require.NoError(t, err)
+	assert.True(t, found)
+	found, err = cache.Delete("call_1")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	removed, err := cache.Flush()
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+}
@@ -0,0 +1,204 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// storedEntry is the serialized form of an entry in the backends that store
+// bytes rather than rows. Created and Accessed are Unix nanoseconds; entries
+// written before Created was added have it zero, and only backends that track
+// access times set Accessed.
+type storedEntry struct {
+	ReasoningItem
+	Created  int64 `json:",omitempty"`
+	Accessed int64 `json:",omitempty"`
+}
+
+func encodeEntry(entry storedEntry) ([]byte, error) {
+	return json.Marshal(entry)
+}
+
+func decodeEntry(data []byte) (storedEntry, error) {
+	var entry storedEntry
+	err := json.Unmarshal(data, &entry)
+	return entry, err
+}
+
+// CacheOptions selects and configures the backend returned by OpenCache.
+// Size, MaxBytes and TTL apply to the backends that support them, including
+// the in-memory fallback.
+type CacheOptions struct {
+	Backend  string
+	Size     int
+	MaxBytes int64
+	TTL      time.Duration
+
+	RedisURL         string
+	SQLitePath       string
+	MemcachedServers []string
+	BoltPath         string
+
+	// Fallback uses the in-memory cache when the backend cannot be opened,
+	// and otherwise wraps the backend in a FallbackCache.
+	Fallback bool
+}
+
+// OpenCache opens the cache backend selected by options and starts its
+// sweeper, which runs until ctx is done. Backends that hold connections or
+// files implement io.Closer.
+func OpenCache(ctx context.Context, options CacheOptions, logger *slog.Logger) (Cache, error) {
+	if options.Backend == CacheBackendMemory {
+		return openMemoryCache(ctx, options), nil
+	}
+
+	store, err := openCacheStore(ctx, options, logger)
+	if err != nil {
+		if !options.Fallback || errors.Is(err, errUnknownCacheBackend) {
+			return nil, err
+		}
+		logger.Warn("cache backend unavailable, falling back to memory", "backend", options.Backend, "error", err)
+		return openMemoryCache(ctx, options), nil
+	}
+	if options.Fallback {
+		return NewFallbackCache(store, openMemoryCache(ctx, options), logger), nil
+	}
+	return store, nil
+}
+
+var errUnknownCacheBackend = errors.New("unknown cache backend")
+
+func openMemoryCache(ctx context.Context, options CacheOptions) *LRUCache {
+	lru := NewLRUCacheWithTTL(options.Size, options.TTL)
+	lru.SetMaxBytes(options.MaxBytes)
+	go lru.RunSweeper(ctx, min(options.TTL, time.Minute))
+	return lru
+}
+
+func openCacheStore(ctx context.Context, options CacheOptions, logger *slog.Logger) (Cache, error) {
+	switch options.Backend {
+	case CacheBackendRedis:
+		if options.RedisURL == "" {
+			return nil, errors.New("a Redis URL is required for the redis cache backend")
+		}
+		cache, err := NewRedisCache(options.RedisURL, options.TTL, logger)
+		if err != nil {
+			return nil, fmt.Errorf("connecting to redis: %w", err)
+		}
+		return cache, nil
+	case CacheBackendSQLite:
+		cache, err := NewSQLiteCache(options.SQLitePath, options.Size, options.TTL, logger)
+		if err != nil {
+			return nil, fmt.Errorf("opening sqlite cache %s: %w", options.SQLitePath, err)
+		}
+		go cache.RunSweeper(ctx, min(options.TTL, time.Minute))
+		return cache, nil
+	case CacheBackendMemcached:
+		if len(options.MemcachedServers) == 0 {
+			return nil, errors.New("at least one server is required for the memcached cache backend")
+		}
+		cache, err := NewMemcachedCache(options.MemcachedServers, options.TTL, logger)
+		if err != nil {
+			return nil, fmt.Errorf("connecting to memcached: %w", err)
+		}
+		return cache, nil
+	case CacheBackendBolt:
+		cache, err := NewBoltCache(options.BoltPath, options.Size, options.TTL, logger)
+		if err != nil {
+			return nil, fmt.Errorf("opening bolt cache %s: %w", options.BoltPath, err)
+		}
+		go cache.RunSweeper(ctx, min(options.TTL, time.Minute))
+		return cache, nil
+	}
+	return nil, fmt.Errorf("%w %q", errUnknownCacheBackend, options.Backend)
+}
+
+// FallbackCache keeps a copy of every entry written to a shared or durable
+// backend in memory, and looks entries up there when the backend misses, so
+// that conversations served by this instance keep their reasoning while the
+// backend cannot be reached.
+type FallbackCache struct {
+	primary Cache
+	memory  *LRUCache
+	logger  *slog.Logger
+
+	cacheCounters
+}
+
+// NewFallbackCache wraps primary with an in-memory fallback.
+func NewFallbackCache(primary Cache, memory *LRUCache, logger *slog.Logger) *FallbackCache {
+	return &FallbackCache{primary: primary, memory: memory, logger: logger}
+}
+
+func (c *FallbackCache) Put(namespace, key string, item ReasoningItem) {
+	c.memory.Put(namespace, key, item)
+	c.primary.Put(namespace, key, item)
+	c.inserts.Add(1)
+}
+
+func (c *FallbackCache) Get(namespace, key string) (ReasoningItem, bool) {
+	if item, found := c.primary.Get(namespace, key); found {
+		c.lookup(true)
+		return item, true
+	}
+	item, found := c.memory.Get(namespace, key)
+	if found {
+		c.logger.Debug("reasoning found in the fallback cache", "key", key)
+	}
+	c.lookup(found)
+	return item, found
+}
+
+// Entries lists the entries of the backend, or of the in-memory copy when
+// the backend cannot be listed.
+func (c *FallbackCache) Entries() ([]CacheEntryInfo, error) {
+	if admin, ok := c.primary.(AdminCache); ok {
+		return admin.Entries()
+	}
+	return c.memory.Entries()
+}
+
+// Peek returns the entry stored under key in the backend, or in memory if
+// the backend does not have it.
+func (c *FallbackCache) Peek(key string) (ReasoningItem, bool, error) {
+	if admin, ok := c.primary.(AdminCache); ok {
+		if item, found, err := admin.Peek(key); err != nil || found {
+			return item, found, err
+		}
+	}
+	return c.memory.Peek(key)
+}
+
+// Delete removes the entry stored under key from both the backend and
+// memory.
+func (c *FallbackCache) Delete(key string) (bool, error) {
+	found, _ := c.memory.Delete(key)
+	if admin, ok := c.primary.(AdminCache); ok {
+		inPrimary, err := admin.Delete(key)
+		return found || inPrimary, err
+	}
+	return found, nil
+}
+
+// Flush removes every entry from both the backend and memory, and returns
+// how many the backend held, or memory if the backend cannot be listed.
+func (c *FallbackCache) Flush() (int, error) {
+	n, _ := c.memory.Flush()
+	if admin, ok := c.primary.(AdminCache); ok {
+		return admin.Flush()
+	}
+	return n, nil
+}
+
+// Close closes the backend.
+func (c *FallbackCache) Close() error {
+	if closer, ok := c.primary.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
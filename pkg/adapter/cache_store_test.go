@@ -0,0 +1,78 @@
+package adapter
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenCache(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	cache, err := OpenCache(ctx, CacheOptions{Backend: CacheBackendMemory, Size: 10}, logger)
+	require.NoError(t, err)
+	assert.IsType(t, &LRUCache{}, cache)
+
+	cache, err = OpenCache(ctx, CacheOptions{Backend: CacheBackendBolt, Size: 10, BoltPath: filepath.Join(t.TempDir(), "reasoning.bolt")}, logger)
+	require.NoError(t, err)
+	assert.IsType(t, &BoltCache{}, cache)
+	cache.(io.Closer).Close()
+
+	_, err = OpenCache(ctx, CacheOptions{Backend: "etcd"}, logger)
+	assert.ErrorContains(t, err, `unknown cache backend "etcd"`)
+	_, err = OpenCache(ctx, CacheOptions{Backend: "etcd", Fallback: true}, logger)
+	assert.Error(t, err, "a misspelled backend is not silently replaced")
+
+	_, err = OpenCache(ctx, CacheOptions{Backend: CacheBackendMemcached}, logger)
+	assert.ErrorContains(t, err, "at least one server is required")
+}
+
+func TestOpenCache_Fallback(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	unreachable := listener.Addr().String()
+	listener.Close()
+
+	options := CacheOptions{Backend: CacheBackendMemcached, Size: 10, MemcachedServers: []string{unreachable}}
+	_, err = OpenCache(ctx, options, logger)
+	assert.ErrorContains(t, err, "connecting to memcached")
+
+	// A backend that cannot be reached at startup is replaced by memory.
+	options.Fallback = true
+	cache, err := OpenCache(ctx, options, logger)
+	require.NoError(t, err)
+	assert.IsType(t, &LRUCache{}, cache)
+
+	// One that goes away later is backed by the copy kept in memory.
+	server := miniredis.RunT(t)
+	cache, err = OpenCache(ctx, CacheOptions{Backend: CacheBackendRedis, Size: 10, RedisURL: "redis://" + server.Addr() + "?max_retries=-1&dial_timeout=100ms", Fallback: true}, logger)
+	require.NoError(t, err)
+	require.IsType(t, &FallbackCache{}, cache)
+	defer cache.(io.Closer).Close()
+
+	cache.Put("tenant", "call_1", ReasoningItem{ID: "call_1", Content: "reasoning"})
+	assert.True(t, server.Exists(redisKeyPrefix+"tenant:call_1"))
+
+	server.Close()
+	item, found := cache.Get("tenant", "call_1")
+	require.True(t, found)
+	assert.Equal(t, "reasoning", item.Content)
+	assert.Equal(t, CacheStats{Hits: 1, Inserts: 1}, cache.Stats())
+
+	entries, err := cache.(AdminCache).Entries()
+	assert.Error(t, err, "the backend is listed, not the copy")
+	assert.Empty(t, entries)
+}
@@ -1,8 +1,12 @@
-package main
+package adapter
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -97,9 +101,9 @@ func TestLRUCache_PutAndGet(t *testing.T) {
 			for _, op := range tt.ops {
 				switch op.action {
 				case "put":
-					cache.Put(op.key, op.item)
+					cache.Put("", op.key, op.item)
 				case "get":
-					item, found := cache.Get(op.key)
+					item, found := cache.Get("", op.key)
 					assert.Equal(t, op.found, found)
 					if found {
 						assert.Equal(t, op.expected.ID, item.ID)
@@ -177,14 +181,14 @@ func TestLRUCache_EvictionBehavior(t *testing.T) {
 			for _, op := range tt.sequence {
 				switch op.action {
 				case "put":
-					cache.Put(op.key, op.item)
+					cache.Put("", op.key, op.item)
 				case "get":
-					cache.Get(op.key)
+					cache.Get("", op.key)
 				}
 			}
 
 			for _, check := range tt.finalChecks {
-				_, found := cache.Get(check.key)
+				_, found := cache.Get("", check.key)
 				assert.Equal(t, check.found, found, "key %s should have found=%v", check.key, check.found)
 			}
 
@@ -210,7 +214,7 @@ func TestLRUCache_ConcurrentAccess(t *testing.T) {
 					ID:      "id" + string(rune(id*numOperations+j)),
 					Content: "content" + string(rune(id*numOperations+j)),
 				}
-				cache.Put(key, item)
+				cache.Put("", key, item)
 			}
 		}(i)
 
@@ -218,7 +222,7 @@ func TestLRUCache_ConcurrentAccess(t *testing.T) {
 			defer wg.Done()
 			for j := 0; j < numOperations; j++ {
 				key := "key" + string(rune(id*numOperations+j))
-				cache.Get(key)
+				cache.Get("", key)
 			}
 		}(i)
 	}
@@ -237,9 +241,9 @@ func TestLRUCache_EdgeCases(t *testing.T) {
 			name: "zero capacity cache",
 			test: func(t *testing.T) {
 				cache := NewLRUCache(0)
-				cache.Put("key1", ReasoningItem{ID: "id1", Content: "content1"})
+				cache.Put("", "key1", ReasoningItem{ID: "id1", Content: "content1"})
 				assert.Equal(t, 0, cache.Size())
-				_, found := cache.Get("key1")
+				_, found := cache.Get("", "key1")
 				assert.False(t, found)
 			},
 		},
@@ -247,16 +251,16 @@ func TestLRUCache_EdgeCases(t *testing.T) {
 			name: "single capacity cache",
 			test: func(t *testing.T) {
 				cache := NewLRUCache(1)
-				cache.Put("key1", ReasoningItem{ID: "id1", Content: "content1"})
+				cache.Put("", "key1", ReasoningItem{ID: "id1", Content: "content1"})
 				assert.Equal(t, 1, cache.Size())
 
-				cache.Put("key2", ReasoningItem{ID: "id2", Content: "content2"})
+				cache.Put("", "key2", ReasoningItem{ID: "id2", Content: "content2"})
 				assert.Equal(t, 1, cache.Size())
 
-				_, found := cache.Get("key1")
+				_, found := cache.Get("", "key1")
 				assert.False(t, found)
 
-				_, found = cache.Get("key2")
+				_, found = cache.Get("", "key2")
 				assert.True(t, found)
 			},
 		},
@@ -279,7 +283,7 @@ func TestLRUCache_UtilityMethods(t *testing.T) {
 			capacity: 5,
 			setup: func(c *LRUCache) {
 				for i := 0; i < 3; i++ {
-					c.Put("key"+string(rune(i)), ReasoningItem{ID: "id" + string(rune(i))})
+					c.Put("", "key"+string(rune(i)), ReasoningItem{ID: "id" + string(rune(i))})
 				}
 			},
 			test: func(t *testing.T, c *LRUCache) {
@@ -291,7 +295,7 @@ func TestLRUCache_UtilityMethods(t *testing.T) {
 			capacity: 3,
 			setup: func(c *LRUCache) {
 				for i := 0; i < 3; i++ {
-					c.Put("key"+string(rune(i)), ReasoningItem{ID: "id" + string(rune(i))})
+					c.Put("", "key"+string(rune(i)), ReasoningItem{ID: "id" + string(rune(i))})
 				}
 			},
 			test: func(t *testing.T, c *LRUCache) {
@@ -299,7 +303,7 @@ func TestLRUCache_UtilityMethods(t *testing.T) {
 				c.Clear()
 				assert.Equal(t, 0, c.Size())
 				for i := 0; i < 3; i++ {
-					_, found := c.Get("key" + string(rune(i)))
+					_, found := c.Get("", "key"+string(rune(i)))
 					assert.False(t, found)
 				}
 			},
@@ -314,3 +318,157 @@ func TestLRUCache_UtilityMethods(t *testing.T) {
 		})
 	}
 }
+
+func TestLRUCache_SaveAndLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+
+	cache := NewLRUCache(3)
+	cache.Put("", "key1", ReasoningItem{ID: "id1", Content: "content1"})
+	cache.Put("", "key2", ReasoningItem{ID: "id2", Content: "content2"})
+	cache.Put("", "key3", ReasoningItem{ID: "id3", Content: "content3"})
+	cache.Get("", "key1")
+	require.NoError(t, cache.SaveFile(path))
+
+	restored := NewLRUCache(3)
+	n, err := restored.LoadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	item, found := restored.Get("", "key3")
+	assert.True(t, found)
+	assert.Equal(t, ReasoningItem{ID: "id3", Content: "content3"}, item)
+
+	// key2 was least recently used before the snapshot and is evicted first.
+	restored.Put("", "key4", ReasoningItem{ID: "id4", Content: "content4"})
+	_, found = restored.Get("", "key2")
+	assert.False(t, found)
+	_, found = restored.Get("", "key1")
+	assert.True(t, found)
+}
+
+func TestLRUCache_LoadFileMissing(t *testing.T) {
+	cache := NewLRUCache(3)
+	n, err := cache.LoadFile(filepath.Join(t.TempDir(), "missing.json"))
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestLRUCache_TTL(t *testing.T) {
+	cache := NewLRUCacheWithTTL(10, time.Millisecond)
+	cache.Put("", "key1", ReasoningItem{ID: "id1", Content: "content1"})
+
+	time.Sleep(5 * time.Millisecond)
+
+	_, found := cache.Get("", "key1")
+	assert.False(t, found)
+	assert.Equal(t, 0, cache.Size())
+}
+
+func TestLRUCache_Sweep(t *testing.T) {
+	cache := NewLRUCacheWithTTL(10, time.Hour)
+	cache.Put("", "key1", ReasoningItem{ID: "id1", Content: "content1"})
+	cache.Put("", "key2", ReasoningItem{ID: "id2", Content: "content2"})
+
+	now := time.Now()
+	assert.Equal(t, 0, cache.sweep(now.Add(30*time.Minute)))
+	assert.Equal(t, 2, cache.sweep(now.Add(2*time.Hour)))
+	assert.Equal(t, 0, cache.Size())
+}
+
+func TestLRUCache_SweepKeepsRecentlyUsed(t *testing.T) {
+	cache := NewLRUCacheWithTTL(10, time.Hour)
+	cache.Put("", "key1", ReasoningItem{ID: "id1", Content: "content1"})
+	cache.Put("", "key2", ReasoningItem{ID: "id2", Content: "content2"})
+
+	// Backdate key1 so only it has expired.
+	cache.cache["key1"].Value.(*cacheEntry).expires = time.Now().Add(-time.Second)
+
+	assert.Equal(t, 1, cache.sweep(time.Now()))
+	_, found := cache.Get("", "key2")
+	assert.True(t, found)
+}
+
+func TestLRUCache_Namespaces(t *testing.T) {
+	cache := NewLRUCache(10)
+
+	cache.Put("tenant-a", "call_1", ReasoningItem{ID: "call_1", Content: "a"})
+	cache.Put("tenant-b", "call_1", ReasoningItem{ID: "call_1", Content: "b"})
+
+	item, found := cache.Get("tenant-a", "call_1")
+	assert.True(t, found)
+	assert.Equal(t, "a", item.Content)
+
+	item, found = cache.Get("tenant-b", "call_1")
+	assert.True(t, found)
+	assert.Equal(t, "b", item.Content)
+
+	_, found = cache.Get("", "call_1")
+	assert.False(t, found)
+}
+
+func TestLRUCache_MaxBytes(t *testing.T) {
+	cache := NewLRUCache(10)
+	cache.SetMaxBytes(30)
+
+	// Each entry is 2 bytes of key, 3 of ID and 5 of content.
+	cache.Put("", "k1", ReasoningItem{ID: "id1", Content: "aaaaa"})
+	cache.Put("", "k2", ReasoningItem{ID: "id2", Content: "bbbbb"})
+	cache.Put("", "k3", ReasoningItem{ID: "id3", Content: "ccccc"})
+	assert.Equal(t, int64(30), cache.Bytes())
+
+	cache.Get("", "k1")
+	cache.Put("", "k4", ReasoningItem{ID: "id4", Content: "ddddd"})
+	assert.Equal(t, 3, cache.Size())
+	assert.Equal(t, int64(30), cache.Bytes())
+	_, found := cache.Get("", "k2")
+	assert.False(t, found, "least recently used entry evicted")
+
+	// Growing an entry evicts others to make room.
+	cache.Put("", "k1", ReasoningItem{ID: "id1", Content: "aaaaaaaaaaaaaaa"})
+	assert.Equal(t, int64(30), cache.Bytes())
+	assert.Equal(t, 2, cache.Size())
+
+	// Entries larger than the limit are not cached.
+	cache.Put("", "k5", ReasoningItem{ID: "id5", Content: "this reasoning does not fit at all"})
+	_, found = cache.Get("", "k5")
+	assert.False(t, found)
+	assert.Equal(t, int64(30), cache.Bytes())
+
+	cache.SetMaxBytes(20)
+	assert.Equal(t, 1, cache.Size())
+	assert.Equal(t, int64(20), cache.Bytes())
+
+	_, err := cache.Flush()
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), cache.Bytes())
+}
+
+func TestLRUCache_Metrics(t *testing.T) {
+	cache := NewLRUCache(10)
+	cache.Put("", "k1", ReasoningItem{ID: "id1", Content: "aaaaa"})
+	adapter := newAdminTestAdapter(cache)
+
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, w.Body.String(), "gpt_oss_adapter_cache_entries 1\n")
+	assert.Contains(t, w.Body.String(), "gpt_oss_adapter_cache_bytes 10\n")
+	assert.Contains(t, w.Body.String(), "gpt_oss_adapter_cache_inserts_total 1\n")
+}
+
+func TestLRUCache_Stats(t *testing.T) {
+	cache := NewLRUCacheWithTTL(2, time.Hour)
+
+	cache.Put("", "k1", ReasoningItem{ID: "id1", Content: "a"})
+	cache.Put("", "k2", ReasoningItem{ID: "id2", Content: "b"})
+	cache.Put("", "k3", ReasoningItem{ID: "id3", Content: "c"})
+	cache.Get("", "k1")
+	cache.Get("", "k3")
+
+	cache.cache["k3"].Value.(*cacheEntry).expires = time.Now().Add(-time.Second)
+	cache.Get("", "k3")
+
+	stats := cache.Stats()
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 2, Inserts: 3, Evictions: 2}, stats)
+	assert.InDelta(t, 1.0/3, stats.HitRate(), 0.001)
+	assert.Zero(t, CacheStats{}.HitRate())
+}
@@ -0,0 +1,73 @@
+package adapter
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	// CacheModeStore looks reasoning up in the cache and stores the
+	// reasoning of responses in it.
+	CacheModeStore = "store"
+	// CacheModeNoStore looks reasoning up but stores nothing from the
+	// request or its response.
+	CacheModeNoStore = "no-store"
+	// CacheModeBypass neither looks reasoning up nor stores it, as if the
+	// adapter had no cache.
+	CacheModeBypass = "bypass"
+)
+
+// cacheModeHeader lets a client choose the cache mode of a request,
+// overriding Adapter.CacheMode.
+const cacheModeHeader = "X-GPT-OSS-Cache"
+
+// cacheMode returns the cache mode of a request, from its X-GPT-OSS-Cache
+// header or the adapter's default. An unknown value is an error rather than
+// the default, so that a typo never stores reasoning a client meant to keep
+// out of the cache.
+func (a *Adapter) cacheMode(r *http.Request) (string, error) {
+	mode := strings.ToLower(strings.TrimSpace(r.Header.Get(cacheModeHeader)))
+	switch mode {
+	case "":
+		if a.CacheMode == "" {
+			return CacheModeStore, nil
+		}
+		return a.CacheMode, nil
+	case CacheModeStore, CacheModeNoStore, CacheModeBypass:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid %s header %q, expected %s, %s or %s", cacheModeHeader, mode, CacheModeStore, CacheModeNoStore, CacheModeBypass)
+}
+
+// cacheScope is the part of the reasoning cache a request may use: its
+// namespace, and whether the client lets the adapter look reasoning up there
+// and store the reasoning of the response.
+type cacheScope struct {
+	namespace  string
+	skipLookup bool
+	noStore    bool
+}
+
+// requestCacheScope returns the cache scope of a request.
+func (a *Adapter) requestCacheScope(r *http.Request) (cacheScope, error) {
+	mode, err := a.cacheMode(r)
+	if err != nil {
+		return cacheScope{}, err
+	}
+	return cacheScope{
+		namespace:  a.cacheNamespace(r),
+		skipLookup: mode == CacheModeBypass,
+		noStore:    mode != CacheModeStore,
+	}, nil
+}
+
+// newChatRequest starts the state of a chat request from the client request,
+// with its cache scope.
+func (a *Adapter) newChatRequest(r *http.Request) (*chatRequest, error) {
+	scope, err := a.requestCacheScope(r)
+	if err != nil {
+		return nil, err
+	}
+	return &chatRequest{ctx: r.Context(), cacheScope: scope}, nil
+}
@@ -0,0 +1,135 @@
+package adapter
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+)
+
+func TestCacheMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		fallback string
+		header   string
+		want     string
+		err      bool
+	}{
+		{"default", "", "", CacheModeStore, false},
+		{"configured default", CacheModeNoStore, "", CacheModeNoStore, false},
+		{"header", "", "bypass", CacheModeBypass, false},
+		{"header overrides default", CacheModeNoStore, " Store ", CacheModeStore, false},
+		{"unknown", "", "off", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adapter := newTestAdapter()
+			adapter.CacheMode = tt.fallback
+
+			r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+			if tt.header != "" {
+				r.Header.Set(cacheModeHeader, tt.header)
+			}
+
+			mode, err := adapter.cacheMode(r)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, mode)
+		})
+	}
+}
+
+func TestCacheMode_Requests(t *testing.T) {
+	var forwarded map[string]any
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = nil
+		json.NewDecoder(r.Body).Decode(&forwarded)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[{"index":0,"message":{"role":"assistant","reasoning_content":"Call the tool.","tool_calls":[{"id":"call_1","type":"function","function":{"name":"f","arguments":"{}"}}]},"finish_reason":"tool_calls"}]}`)
+	}))
+	defer backend.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cache := NewLRUCache(10)
+	adapter := NewAdapter(backend.URL, cache, logger, llamacpp.NewProvider())
+
+	send := func(mode string) *httptest.ResponseRecorder {
+		body := `{"model":"gpt-oss-20b","messages":[
+			{"role":"user","content":"Go"},
+			{"role":"assistant","tool_calls":[{"id":"call_0","type":"function","function":{"name":"f","arguments":"{}"}}]},
+			{"role":"tool","tool_call_id":"call_0","content":"Done"}]}`
+		r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+		if mode != "" {
+			r.Header.Set(cacheModeHeader, mode)
+		}
+		w := httptest.NewRecorder()
+		adapter.ServeHTTP(w, r)
+		return w
+	}
+	injected := func() bool {
+		message := forwarded["messages"].([]any)[1].(map[string]any)
+		_, ok := message["reasoning_content"]
+		return ok
+	}
+	cache.Put("", "call_0", ReasoningItem{ID: "call_0", Content: "Earlier reasoning."})
+
+	w := send(CacheModeNoStore)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, injected())
+	assert.Contains(t, w.Body.String(), "Call the tool.")
+	_, found := cache.Get("", "call_1")
+	assert.False(t, found)
+
+	w = send(CacheModeBypass)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, injected())
+	_, found = cache.Get("", "call_1")
+	assert.False(t, found)
+
+	w = send("forget")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid_cache_mode")
+
+	adapter.CacheMode = CacheModeNoStore
+	send("")
+	_, found = cache.Get("", "call_1")
+	assert.False(t, found)
+
+	send(CacheModeStore)
+	item, found := cache.Get("", "call_1")
+	require.True(t, found)
+	assert.Equal(t, "Call the tool.", item.Content)
+}
+
+func TestCacheMode_Responses(t *testing.T) {
+	adapter := newTestAdapter()
+
+	chatResponse := map[string]any{
+		"choices": []any{map[string]any{
+			"message": map[string]any{"role": "assistant", "reasoning": "Think.", "content": "Hi"},
+		}},
+	}
+	response := adapter.chatToResponse(cacheScope{noStore: true}, chatResponse, map[string]any{})
+	item := response["output"].([]any)[0].(map[string]any)
+	id := item["id"].(string)
+
+	_, found := adapter.cache.Get("", id)
+	assert.False(t, found)
+
+	adapter.cache.Put("", "rs_1", ReasoningItem{ID: "rs_1", Content: "Cached."})
+	reasoning := map[string]any{"type": "reasoning", "id": "rs_1"}
+	assert.Equal(t, "Cached.", adapter.responsesReasoningText(cacheScope{noStore: true}, reasoning))
+	assert.Empty(t, adapter.responsesReasoningText(cacheScope{skipLookup: true, noStore: true}, reasoning))
+}
@@ -0,0 +1,206 @@
+package adapter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+)
+
+// A reasoning chain is the ordered reasoning history of a conversation,
+// stored under chainKeyPrefix and the conversation's ID. Unlike entries keyed
+// by tool call ID, it also covers turns without tool calls and clients that
+// rewrite IDs, and restores reasoning by the position of the turn in the
+// conversation, as the Harmony format expects for agentic loops.
+const chainKeyPrefix = "chain:"
+
+// chainLink is the reasoning of one assistant turn. Turn counts the
+// assistant messages before it, and Fingerprint identifies the message so
+// that a client that edited its history is not given reasoning for a
+// different turn.
+type chainLink struct {
+	Turn        int    `json:"turn"`
+	Fingerprint string `json:"fingerprint"`
+	Reasoning   string `json:"reasoning"`
+}
+
+// turnFingerprint identifies an assistant message by its tool calls, or by
+// its content for a message without any.
+func turnFingerprint(content string, toolCalls []any) string {
+	if len(toolCalls) > 0 {
+		return toolCallFingerprint(toolCalls)
+	}
+	sum := sha256.Sum256([]byte(strings.TrimSpace(content)))
+	return "content:" + hex.EncodeToString(sum[:])
+}
+
+func (a *Adapter) loadChain(namespace, conversationID string) []chainLink {
+	item, found := a.cache.Get(namespace, chainKeyPrefix+conversationID)
+	if !found {
+		return nil
+	}
+	var chain []chainLink
+	if err := json.Unmarshal([]byte(item.Content), &chain); err != nil {
+		a.logger.Warn("invalid reasoning chain in cache", "conversation_id", conversationID, "error", err)
+		return nil
+	}
+	return chain
+}
+
+// countAssistantTurns returns the number of assistant messages of a
+// request, which is the turn of the assistant message it asks for.
+func countAssistantTurns(requestData map[string]any) int {
+	messages, _ := requestData["messages"].([]any)
+	turns := 0
+	for _, msg := range messages {
+		if message, ok := msg.(map[string]any); ok && message["role"] == "assistant" {
+			turns++
+		}
+	}
+	return turns
+}
+
+// injectReasoningChain restores reasoning from the conversation's chain to
+// the assistant messages selected by the ReasoningInjection policy that
+// still carry none, and returns how many messages it restored. With a
+// ReasoningChainDepth, only that many of the latest turns are restored.
+func (a *Adapter) injectReasoningChain(chat *chatRequest, requestData map[string]any) int {
+	chain := a.loadChain(chat.namespace, chat.conversationID)
+	if len(chain) == 0 {
+		return 0
+	}
+	links := make(map[int]chainLink, len(chain))
+	for _, link := range chain {
+		links[link.Turn] = link
+	}
+
+	messages, _ := requestData["messages"].([]any)
+	start := a.injectionStart(messages)
+	oldest := 0
+	if a.ReasoningChainDepth > 0 {
+		oldest = countAssistantTurns(requestData) - a.ReasoningChainDepth
+	}
+
+	restored := 0
+	turn := -1
+	for i, msg := range messages {
+		message, ok := msg.(map[string]any)
+		if !ok || message["role"] != "assistant" {
+			continue
+		}
+		turn++
+		if i < start || turn < oldest {
+			continue
+		}
+		if reasoning, _ := a.extractReasoning(message); reasoning != "" {
+			continue
+		}
+
+		link, ok := links[turn]
+		if !ok {
+			continue
+		}
+		toolCalls, _ := message["tool_calls"].([]any)
+		if link.Fingerprint != turnFingerprint(messageText(message), toolCalls) {
+			a.logger.Debug("assistant message differs from the reasoning chain", "conversation_id", chat.conversationID, "turn", turn)
+			continue
+		}
+		a.restoreReasoning(message, link.Reasoning)
+		restored++
+	}
+
+	if restored > 0 {
+		a.logger.Info("injected reasoning from conversation chain", "conversation_id", chat.conversationID, "count", restored)
+	}
+	return restored
+}
+
+// recordChainTurn appends the reasoning of the assistant turn a request
+// produced to its conversation's chain. Links at or after the turn, left by
+// a response the client regenerated or a branch it abandoned, are replaced.
+func (a *Adapter) recordChainTurn(chat *chatRequest, reasoning, content string, toolCalls []any) {
+	if reasoning == "" {
+		return
+	}
+
+	chain := a.loadChain(chat.namespace, chat.conversationID)
+	kept := chain[:0]
+	for _, link := range chain {
+		if link.Turn < chat.chainTurn && (a.ReasoningChainDepth <= 0 || link.Turn > chat.chainTurn-a.ReasoningChainDepth) {
+			kept = append(kept, link)
+		}
+	}
+	chain = append(kept, chainLink{
+		Turn:        chat.chainTurn,
+		Fingerprint: turnFingerprint(content, toolCalls),
+		Reasoning:   reasoning,
+	})
+
+	data, err := json.Marshal(chain)
+	if err != nil {
+		return
+	}
+	key := chainKeyPrefix + chat.conversationID
+	a.storeReasoning(chat.namespace, key, ReasoningItem{ID: key, Content: string(data)})
+	a.logger.Debug("recorded reasoning chain turn", "conversation_id", chat.conversationID, "turn", chat.chainTurn, "length", len(chain))
+}
+
+// recordChainResponse records the first choice of a chat completion in the
+// conversation's chain.
+func (a *Adapter) recordChainResponse(chat *chatRequest, responseData map[string]any) {
+	choices, _ := responseData["choices"].([]any)
+	if len(choices) == 0 {
+		return
+	}
+	choice, _ := choices[0].(map[string]any)
+	message, _ := choice["message"].(map[string]any)
+	if message == nil {
+		return
+	}
+	reasoning, _ := a.extractReasoning(message)
+	toolCalls, _ := message["tool_calls"].([]any)
+	a.recordChainTurn(chat, reasoning, messageText(message), toolCalls)
+}
+
+// streamedTurn accumulates the content streamed for the first choice, to
+// record the turn in the conversation's chain when it finishes.
+type streamedTurn struct {
+	content  strings.Builder
+	recorded bool
+}
+
+// observeChainChunk records the first choice of a stream in the
+// conversation's chain once a chunk finishes it. It must see the chunk after
+// processStreamingDelta and before the accumulated reasoning is consumed.
+func (a *Adapter) observeChainChunk(chat *chatRequest, eventData map[string]any, reasoning map[int]*choiceReasoning, turn *streamedTurn) {
+	if turn.recorded {
+		return
+	}
+	choices, _ := eventData["choices"].([]any)
+	for i, c := range choices {
+		choice, _ := c.(map[string]any)
+		index := i
+		if n, ok := choice["index"].(float64); ok {
+			index = int(n)
+		}
+		if index != 0 {
+			continue
+		}
+		if delta, ok := choice["delta"].(map[string]any); ok {
+			if content, ok := delta["content"].(string); ok {
+				turn.content.WriteString(content)
+			}
+		}
+		if reason, _ := choice["finish_reason"].(string); reason == "" {
+			continue
+		}
+
+		turn.recorded = true
+		accumulated, ok := reasoning[0]
+		if !ok {
+			return
+		}
+		reasoningContent, _ := a.streamedReasoning(accumulated)
+		a.recordChainTurn(chat, reasoningContent, turn.content.String(), accumulated.toolCallList())
+	}
+}
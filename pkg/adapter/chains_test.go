@@ -0,0 +1,137 @@
+package adapter
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func chainResponse(message map[string]any) map[string]any {
+	message["role"] = "assistant"
+	return map[string]any{"choices": []any{map[string]any{"message": message}}}
+}
+
+func TestReasoningChains(t *testing.T) {
+	adapter := newTestAdapter()
+	adapter.ReasoningChains = true
+
+	lookup := map[string]any{"id": "call_1", "function": map[string]any{"name": "lookup", "arguments": `{"q": "paris"}`}}
+
+	// Turn 0 calls a tool, turn 1 answers, and turn 2 follows a new question.
+	turns := []map[string]any{
+		{"tool_calls": []any{lookup}, "reasoning_content": "Look it up."},
+		{"content": "Paris is sunny.", "reasoning_content": "Summarize the result."},
+		{"content": "Rome is rainy.", "reasoning_content": "Answer from memory."},
+	}
+	for i, turn := range turns {
+		chat := &chatRequest{conversationID: "conv", chainTurn: i}
+		adapter.recordChainResponse(chat, chainResponse(turn))
+	}
+
+	// The client rewrote the tool call ID and dropped all reasoning.
+	request := map[string]any{"messages": []any{
+		map[string]any{"role": "user", "content": "Weather in Paris?"},
+		map[string]any{"role": "assistant", "tool_calls": []any{
+			map[string]any{"id": "renamed", "function": map[string]any{"name": "lookup", "arguments": `{"q":"paris"}`}},
+		}},
+		map[string]any{"role": "tool", "tool_call_id": "renamed", "content": "Sunny"},
+		map[string]any{"role": "assistant", "content": "Paris is sunny."},
+		map[string]any{"role": "user", "content": "And Rome?"},
+		map[string]any{"role": "assistant", "content": "Rome is rainy."},
+		map[string]any{"role": "user", "content": "Thanks."},
+	}}
+	chat := &chatRequest{conversationID: "conv", chainTurn: countAssistantTurns(request)}
+	assert.Equal(t, 3, chat.chainTurn)
+	assert.Equal(t, 3, adapter.injectReasoningChain(chat, request))
+
+	messages := request["messages"].([]any)
+	assert.Equal(t, "Look it up.", messages[1].(map[string]any)["reasoning_content"])
+	assert.Equal(t, "Summarize the result.", messages[3].(map[string]any)["reasoning_content"])
+	assert.Equal(t, "Answer from memory.", messages[5].(map[string]any)["reasoning_content"])
+
+	// Other conversations get nothing.
+	other := map[string]any{"messages": []any{
+		map[string]any{"role": "assistant", "content": "Paris is sunny."},
+	}}
+	assert.Zero(t, adapter.injectReasoningChain(&chatRequest{conversationID: "other"}, other))
+}
+
+func TestReasoningChains_EditedHistory(t *testing.T) {
+	adapter := newTestAdapter()
+	adapter.ReasoningChains = true
+
+	for i, text := range []string{"First answer.", "Second answer."} {
+		chat := &chatRequest{conversationID: "conv", chainTurn: i}
+		adapter.recordChainResponse(chat, chainResponse(map[string]any{"content": text, "reasoning_content": "Reasoning for " + text}))
+	}
+
+	// The client dropped the first answer, so the second is now turn 0 and
+	// matches neither link.
+	request := map[string]any{"messages": []any{
+		map[string]any{"role": "assistant", "content": "Second answer."},
+		map[string]any{"role": "assistant", "content": "Edited answer."},
+	}}
+	assert.Zero(t, adapter.injectReasoningChain(&chatRequest{conversationID: "conv"}, request))
+
+	// Regenerating turn 1 replaces it.
+	regenerated := &chatRequest{conversationID: "conv", chainTurn: 1}
+	adapter.recordChainResponse(regenerated, chainResponse(map[string]any{"content": "Edited answer.", "reasoning_content": "Try again."}))
+
+	request = map[string]any{"messages": []any{
+		map[string]any{"role": "assistant", "content": "First answer."},
+		map[string]any{"role": "assistant", "content": "Edited answer."},
+	}}
+	assert.Equal(t, 2, adapter.injectReasoningChain(&chatRequest{conversationID: "conv"}, request))
+	assert.Equal(t, "Try again.", request["messages"].([]any)[1].(map[string]any)["reasoning_content"])
+}
+
+func TestReasoningChains_Depth(t *testing.T) {
+	adapter := newTestAdapter()
+	adapter.ReasoningChains = true
+	adapter.ReasoningChainDepth = 2
+
+	var messages []any
+	for i, text := range []string{"One.", "Two.", "Three."} {
+		chat := &chatRequest{conversationID: "conv", chainTurn: i}
+		adapter.recordChainResponse(chat, chainResponse(map[string]any{"content": text, "reasoning_content": "Reasoning for " + text}))
+		messages = append(messages, map[string]any{"role": "assistant", "content": text})
+	}
+
+	chain := adapter.loadChain("", "conv")
+	require.Len(t, chain, 2)
+	assert.Equal(t, 1, chain[0].Turn)
+	assert.Equal(t, 2, chain[1].Turn)
+
+	request := map[string]any{"messages": messages}
+	assert.Equal(t, 2, adapter.injectReasoningChain(&chatRequest{conversationID: "conv"}, request))
+	assert.NotContains(t, messages[0], "reasoning_content")
+	assert.Equal(t, "Reasoning for Three.", messages[2].(map[string]any)["reasoning_content"])
+}
+
+func TestReasoningChains_Stream(t *testing.T) {
+	adapter := newTestAdapter()
+	adapter.ReasoningChains = true
+
+	stream := strings.Join([]string{
+		`data: {"choices":[{"index":0,"delta":{"reasoning_content":"Greet "}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"reasoning_content":"the user."}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"content":"Hello"}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"content":"!"}}]}`,
+		`data: {"choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+		`data: [DONE]`,
+		``,
+	}, "\n\n")
+
+	req, _ := http.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	resp := &http.Response{Body: io.NopCloser(strings.NewReader(stream)), Request: req}
+	adapter.relayChatStream(resp, &chatRequest{data: map[string]any{}, conversationID: "conv"}, func(string) {})
+
+	echoed := map[string]any{"role": "assistant", "content": "Hello!"}
+	request := map[string]any{"messages": []any{echoed}}
+	assert.Equal(t, 1, adapter.injectReasoningChain(&chatRequest{conversationID: "conv"}, request))
+	assert.Equal(t, "Greet the user.", echoed["reasoning_content"])
+}
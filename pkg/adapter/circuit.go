@@ -0,0 +1,142 @@
+package adapter
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Circuit breaker states.
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+// CircuitBreaker stops sending chat requests to a target that keeps failing.
+// Once at least MinRequests requests were sent within Window and Threshold
+// of them failed to connect, timed out or got a server error, the circuit
+// opens and requests are rejected right away for Cooldown. Then one request
+// is let through as a probe: if it succeeds the circuit closes again,
+// otherwise it stays open for another Cooldown.
+type CircuitBreaker struct {
+	Threshold   float64
+	MinRequests int
+	Window      time.Duration
+	Cooldown    time.Duration
+
+	mu          sync.Mutex
+	state       string
+	openedAt    time.Time
+	windowStart time.Time
+	requests    int
+	failures    int
+	probing     bool
+
+	opened   atomic.Int64
+	rejected atomic.Int64
+}
+
+func NewCircuitBreaker(threshold float64, minRequests int, window, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		Threshold:   threshold,
+		MinRequests: minRequests,
+		Window:      window,
+		Cooldown:    cooldown,
+		state:       CircuitClosed,
+	}
+}
+
+// State returns the state of the circuit.
+func (b *CircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// allow reports whether a request may be sent to the target. If it may, the
+// outcome must be reported with record or abandon. Otherwise it returns how
+// long the circuit stays open.
+func (b *CircuitBreaker) allow() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if remaining := b.Cooldown - time.Since(b.openedAt); remaining > 0 {
+			b.rejected.Add(1)
+			return false, remaining
+		}
+		b.state = CircuitHalfOpen
+		b.probing = true
+		return true, 0
+	case CircuitHalfOpen:
+		if b.probing {
+			b.rejected.Add(1)
+			return false, 0
+		}
+		b.probing = true
+		return true, 0
+	}
+	return true, 0
+}
+
+// record reports the outcome of a request that allow let through.
+func (b *CircuitBreaker) record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitHalfOpen {
+		b.probing = false
+		if success {
+			b.reset(CircuitClosed)
+		} else {
+			b.trip()
+		}
+		return
+	}
+	if b.state != CircuitClosed {
+		return
+	}
+
+	if now := time.Now(); b.Window > 0 && now.Sub(b.windowStart) >= b.Window {
+		b.windowStart = now
+		b.requests, b.failures = 0, 0
+	}
+	b.requests++
+	if !success {
+		b.failures++
+	}
+	if b.requests >= b.MinRequests && float64(b.failures) >= b.Threshold*float64(b.requests) && b.failures > 0 {
+		b.trip()
+	}
+}
+
+// abandon reports that a request allow let through ended without telling
+// whether the target is healthy, e.g. because the client went away.
+func (b *CircuitBreaker) abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *CircuitBreaker) trip() {
+	b.reset(CircuitOpen)
+	b.openedAt = time.Now()
+	b.opened.Add(1)
+}
+
+func (b *CircuitBreaker) reset(state string) {
+	b.state = state
+	b.windowStart = time.Now()
+	b.requests, b.failures = 0, 0
+}
+
+// writeCircuitOpen rejects a request because the target's circuit is open.
+func (a *Adapter) writeCircuitOpen(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	a.logger.WarnContext(r.Context(), "circuit open, rejecting request", "target", a.Target)
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(retryAfter)))
+	writeError(w, r, http.StatusServiceUnavailable, "The target is unavailable", "server_error", "circuit_open")
+}
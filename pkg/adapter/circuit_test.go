@@ -0,0 +1,130 @@
+package adapter
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	b := NewCircuitBreaker(0.5, 4, time.Minute, 20*time.Millisecond)
+
+	for _, success := range []bool{true, false, true} {
+		allowed, _ := b.allow()
+		require.True(t, allowed)
+		b.record(success)
+	}
+	assert.Equal(t, CircuitClosed, b.State(), "too few requests to judge")
+
+	allowed, _ := b.allow()
+	require.True(t, allowed)
+	b.record(false)
+	assert.Equal(t, CircuitOpen, b.State())
+
+	allowed, retryAfter := b.allow()
+	assert.False(t, allowed)
+	assert.Positive(t, retryAfter)
+
+	// After the cooldown, a single probe is let through.
+	time.Sleep(25 * time.Millisecond)
+	allowed, _ = b.allow()
+	require.True(t, allowed)
+	assert.Equal(t, CircuitHalfOpen, b.State())
+	allowed, _ = b.allow()
+	assert.False(t, allowed, "probe in flight")
+
+	b.record(false)
+	assert.Equal(t, CircuitOpen, b.State(), "failed probe")
+
+	time.Sleep(25 * time.Millisecond)
+	allowed, _ = b.allow()
+	require.True(t, allowed)
+	b.abandon()
+	allowed, _ = b.allow()
+	require.True(t, allowed, "abandoned probe")
+	b.record(true)
+	assert.Equal(t, CircuitClosed, b.State())
+
+	assert.Equal(t, int64(2), b.opened.Load())
+	assert.Equal(t, int64(2), b.rejected.Load())
+}
+
+func TestCircuitBreaker_Window(t *testing.T) {
+	b := NewCircuitBreaker(0.5, 2, 20*time.Millisecond, time.Minute)
+
+	b.allow()
+	b.record(false)
+	time.Sleep(25 * time.Millisecond)
+	b.allow()
+	b.record(true)
+	assert.Equal(t, CircuitClosed, b.State(), "failure outside the window")
+}
+
+func TestCircuitBreaker_FailsFast(t *testing.T) {
+	var calls atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "model crashed", http.StatusInternalServerError)
+	}))
+	defer backend.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter(backend.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+	adapter.Breaker = NewCircuitBreaker(0.5, 2, time.Minute, time.Minute)
+
+	body := `{"model":"gpt-oss-20b","messages":[{"role":"user","content":"Hello"}]}`
+	for range 2 {
+		w := httptest.NewRecorder()
+		adapter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+		assert.Equal(t, http.StatusBadGateway, w.Code)
+	}
+
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), `"code":"circuit_open"`)
+	assert.Equal(t, int32(2), calls.Load())
+
+	w = httptest.NewRecorder()
+	adapter.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, w.Body.String(), `gpt_oss_adapter_circuit_state{target="`+backend.URL+`"} 2`)
+	assert.Contains(t, w.Body.String(), `gpt_oss_adapter_circuit_rejected_total{target="`+backend.URL+`"} 1`)
+}
+
+func TestCircuitBreaker_FailsOver(t *testing.T) {
+	var primaryCalls, fallbackCalls atomic.Int32
+	adapter := newFallbackTestAdapter(t,
+		func(w http.ResponseWriter, r *http.Request) {
+			primaryCalls.Add(1)
+			http.Error(w, "model crashed", http.StatusInternalServerError)
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			fallbackCalls.Add(1)
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}]}`)
+		})
+	adapter.Breaker = NewCircuitBreaker(1, 1, time.Minute, time.Minute)
+	adapter.fallback.Breaker = NewCircuitBreaker(1, 1, time.Minute, time.Minute)
+
+	body := `{"model":"gpt-oss-20b","messages":[{"role":"user","content":"Hello"}]}`
+	for range 3 {
+		w := httptest.NewRecorder()
+		adapter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "fallback", w.Header().Get(backendHeader))
+	}
+
+	assert.Equal(t, int32(1), primaryCalls.Load(), "circuit opened after the first failure")
+	assert.Equal(t, int32(3), fallbackCalls.Load())
+}
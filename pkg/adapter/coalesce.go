@@ -0,0 +1,142 @@
+package adapter
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// coalescer tracks the non-streaming chat requests in flight, so that
+// identical ones are served from a single upstream call.
+type coalescer struct {
+	mu        sync.Mutex
+	calls     map[string]*coalescedCall
+	coalesced atomic.Int64
+}
+
+// coalescedCall is the response of the request serving a set of identical
+// ones, which waiters others are waiting for. done is closed once it is
+// complete; ok is false if the serving request was abandoned before it
+// finished.
+type coalescedCall struct {
+	done    chan struct{}
+	waiters int
+	ok      bool
+	status  int
+	header  http.Header
+	body    []byte
+}
+
+func newCoalescer() *coalescer {
+	return &coalescer{calls: make(map[string]*coalescedCall)}
+}
+
+// coalesce calls serve for the first request with a given key and replays
+// its response to the identical requests that arrive while it is in flight.
+// If the first request is abandoned, the others are served on their own.
+func (a *Adapter) coalesce(w http.ResponseWriter, r *http.Request, key string, serve func(w http.ResponseWriter)) {
+	c := a.coalescer
+	c.mu.Lock()
+	if call, ok := c.calls[key]; ok {
+		call.waiters++
+		c.mu.Unlock()
+
+		select {
+		case <-call.done:
+		case <-r.Context().Done():
+			return
+		}
+		if !call.ok {
+			serve(w)
+			return
+		}
+
+		c.coalesced.Add(1)
+		a.logger.InfoContext(r.Context(), "served identical request from one in flight")
+		for name, values := range call.header {
+			if _, ok := w.Header()[name]; !ok {
+				w.Header()[name] = values
+			}
+		}
+		w.WriteHeader(call.status)
+		w.Write(call.body)
+		return
+	}
+
+	call := &coalescedCall{done: make(chan struct{})}
+	c.calls[key] = call
+	c.mu.Unlock()
+
+	tw := &teeWriter{ResponseWriter: w}
+	defer func() {
+		c.mu.Lock()
+		delete(c.calls, key)
+		c.mu.Unlock()
+
+		call.ok = tw.status != 0 && r.Context().Err() == nil
+		call.status = tw.status
+		call.header = tw.header
+		call.body = tw.body.Bytes()
+		close(call.done)
+	}()
+	serve(tw)
+}
+
+// requestKey identifies a chat request by its target, body, path and the
+// headers that change how it is served or whose reasoning it may restore.
+func (a *Adapter) requestKey(r *http.Request, body []byte) string {
+	h := sha256.New()
+	for _, part := range []string{
+		a.Target,
+		r.URL.Path,
+		r.URL.RawQuery,
+		r.Header.Get("Authorization"),
+		r.Header.Get("X-Api-Key"),
+		r.Header.Get(reasoningEffortHeader),
+		r.Header.Get(conversationIDHeader),
+		r.Header.Get(cacheModeHeader),
+		a.cacheNamespace(r),
+	} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// teeWriter passes a response through while keeping a copy of it.
+type teeWriter struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (t *teeWriter) WriteHeader(status int) {
+	if t.status == 0 {
+		t.status = status
+		t.header = t.ResponseWriter.Header().Clone()
+	}
+	t.ResponseWriter.WriteHeader(status)
+}
+
+func (t *teeWriter) Write(p []byte) (int, error) {
+	if t.status == 0 {
+		t.WriteHeader(http.StatusOK)
+	}
+	t.body.Write(p)
+	return t.ResponseWriter.Write(p)
+}
+
+func (t *teeWriter) Flush() {
+	if flusher, ok := t.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (t *teeWriter) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
@@ -0,0 +1,156 @@
+package adapter
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+)
+
+func newCoalesceTestAdapter(t *testing.T, handler http.HandlerFunc) *Adapter {
+	backend := httptest.NewServer(handler)
+	t.Cleanup(backend.Close)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter(backend.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+	adapter.Coalesce = true
+	return adapter
+}
+
+// coalesceWaiters returns how many requests wait for one in flight.
+func coalesceWaiters(adapter *Adapter) int {
+	adapter.coalescer.mu.Lock()
+	defer adapter.coalescer.mu.Unlock()
+
+	waiters := 0
+	for _, call := range adapter.coalescer.calls {
+		waiters += call.waiters
+	}
+	return waiters
+}
+
+func TestCoalesce(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	adapter := newCoalesceTestAdapter(t, func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		if n == 1 {
+			select {
+			case <-release:
+			case <-time.After(5 * time.Second):
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}]}`)
+	})
+
+	body := `{"model":"gpt-oss-20b","messages":[{"role":"user","content":"Hello"}]}`
+	recorders := make([]*httptest.ResponseRecorder, 3)
+	var wg sync.WaitGroup
+	for i := range recorders {
+		recorders[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+			adapter.ServeHTTP(recorders[i], r)
+		}()
+	}
+	require.Eventually(t, func() bool { return coalesceWaiters(adapter) == 2 }, 5*time.Second, 5*time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, int64(2), adapter.coalescer.coalesced.Load())
+	for _, w := range recorders {
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.Equal(t, recorders[0].Body.String(), w.Body.String())
+	}
+
+	// Once the request completed, the same request is sent again.
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int32(2), calls.Load())
+
+	w = httptest.NewRecorder()
+	adapter.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, w.Body.String(), "gpt_oss_adapter_coalesced_requests_total 2\n")
+}
+
+func TestCoalesce_Key(t *testing.T) {
+	adapter := newTestAdapter()
+	body := []byte(`{"model":"gpt-oss-20b"}`)
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	r.Header.Set("Authorization", "Bearer a")
+	r.Header.Set("X-Request-ID", "1")
+	key := adapter.requestKey(r, body)
+
+	same := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	same.Header.Set("Authorization", "Bearer a")
+	same.Header.Set("X-Request-ID", "2")
+	assert.Equal(t, key, adapter.requestKey(same, body))
+
+	assert.NotEqual(t, key, adapter.requestKey(same, []byte(`{"model":"gpt-oss-120b"}`)))
+	other := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	other.Header.Set("Authorization", "Bearer b")
+	assert.NotEqual(t, key, adapter.requestKey(other, body))
+	same.Header.Set(reasoningEffortHeader, "high")
+	assert.NotEqual(t, key, adapter.requestKey(same, body))
+}
+
+func TestCoalesce_LeaderAbandoned(t *testing.T) {
+	var calls atomic.Int32
+	stop := make(chan struct{})
+	adapter := newCoalesceTestAdapter(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-stop:
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}]}`)
+	})
+
+	body := `{"model":"gpt-oss-20b","messages":[{"role":"user","content":"Hello"}]}`
+	ctx, cancel := context.WithCancel(context.Background())
+	leaderDone := make(chan struct{})
+	go func() {
+		defer close(leaderDone)
+		r := httptest.NewRequestWithContext(ctx, http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+		adapter.ServeHTTP(httptest.NewRecorder(), r)
+	}()
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, 5*time.Second, 5*time.Millisecond)
+
+	followerDone := make(chan *httptest.ResponseRecorder)
+	go func() {
+		w := httptest.NewRecorder()
+		adapter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+		followerDone <- w
+	}()
+	require.Eventually(t, func() bool { return coalesceWaiters(adapter) == 1 }, 5*time.Second, 5*time.Millisecond)
+
+	cancel()
+	<-leaderDone
+	close(stop)
+	w := <-followerDone
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"content":"Hi"`)
+	assert.Equal(t, int32(2), calls.Load())
+	assert.Zero(t, adapter.coalescer.coalesced.Load())
+}
@@ -0,0 +1,130 @@
+package adapter
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// harmonyReasoningLine matches the reasoning effort line of a Harmony system
+// message.
+var harmonyReasoningLine = regexp.MustCompile(`(?m)^Reasoning: *\w* *$`)
+
+// handleCompletions serves the legacy text completions API. The request is
+// passed through to the target, after the reasoning effort is applied the
+// same way as for chat completions: rewritten in the system message of a
+// prompt already rendered in the Harmony format, or set in the provider's
+// field for the backend's template otherwise.
+func (a *Adapter) handleCompletions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		a.handleDefault(w, r)
+		return
+	}
+
+	requestBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		a.logger.ErrorContext(r.Context(), "failed to read request body", "error", err)
+		writeRequestBodyError(w, r, err)
+		return
+	}
+
+	var requestData map[string]any
+	if err := json.Unmarshal(requestBody, &requestData); err != nil {
+		a.logger.ErrorContext(r.Context(), "failed to unmarshal request", "error", err)
+		writeOpenAIError(w, http.StatusBadRequest, "Request body is not valid JSON", "invalid_request_error", "")
+		return
+	}
+	if requestData == nil {
+		writeOpenAIError(w, http.StatusBadRequest, "Request body must be a JSON object", "invalid_request_error", "")
+		return
+	}
+
+	// Text completions clients set the effort at the top level, as for
+	// chat completions in the OpenAI API.
+	if effort, ok := requestData["reasoning_effort"]; ok && a.getNestedField(requestData, "reasoning.effort") == nil {
+		delete(requestData, "reasoning_effort")
+		a.setNestedField(requestData, "reasoning.effort", effort)
+	}
+
+	if err := a.chooseEffort(r, requestData); err != nil {
+		a.writeTransformError(w, r, err)
+		return
+	}
+
+	if effort, ok := a.getNestedField(requestData, "reasoning.effort").(string); ok && setHarmonyPromptEffort(requestData, effort) {
+		a.deleteNestedField(requestData, "reasoning.effort")
+		if reasoning, ok := requestData["reasoning"].(map[string]any); ok && len(reasoning) == 0 {
+			delete(requestData, "reasoning")
+		}
+		a.logger.DebugContext(r.Context(), "set reasoning effort in harmony prompt", "effort", effort)
+	} else {
+		a.injectReasoningEffort(requestData)
+	}
+
+	modifiedRequestBody, err := json.Marshal(requestData)
+	if err != nil {
+		a.logger.ErrorContext(r.Context(), "failed to marshal modified request", "error", err)
+		writeError(w, r, http.StatusInternalServerError, "Failed to marshal modified request", "server_error", "")
+		return
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(modifiedRequestBody))
+	r.ContentLength = int64(len(modifiedRequestBody))
+	a.handleDefault(w, r)
+}
+
+// setHarmonyPromptEffort sets the reasoning effort in the system message of
+// every prompt of a completions request that is rendered in the Harmony
+// format. It reports whether any prompt was.
+func setHarmonyPromptEffort(requestData map[string]any, effort string) bool {
+	switch prompt := requestData["prompt"].(type) {
+	case string:
+		rewritten, ok := harmonyPromptWithEffort(prompt, effort)
+		requestData["prompt"] = rewritten
+		return ok
+	case []any:
+		found := false
+		for i, p := range prompt {
+			if text, ok := p.(string); ok {
+				rewritten, ok := harmonyPromptWithEffort(text, effort)
+				prompt[i] = rewritten
+				found = found || ok
+			}
+		}
+		return found
+	}
+	return false
+}
+
+// harmonyPromptWithEffort replaces the reasoning line of the Harmony system
+// message in prompt, or adds one if the message has none. It reports false
+// if the prompt has no system message.
+func harmonyPromptWithEffort(prompt, effort string) (string, bool) {
+	header := harmonyStart + "system" + harmonyMessage
+	start := strings.Index(prompt, header)
+	if start < 0 {
+		return prompt, false
+	}
+	start += len(header)
+
+	length := strings.Index(prompt[start:], harmonyEnd)
+	if length < 0 {
+		return prompt, false
+	}
+
+	system := prompt[start : start+length]
+	line := "Reasoning: " + effort
+	switch {
+	case harmonyReasoningLine.MatchString(system):
+		system = harmonyReasoningLine.ReplaceAllLiteralString(system, line)
+	case strings.Contains(system, "# Valid channels"):
+		system = strings.Replace(system, "# Valid channels", line+"\n\n# Valid channels", 1)
+	default:
+		system += "\n\n" + line
+	}
+
+	return prompt[:start] + system + prompt[start+length:], true
+}
@@ -0,0 +1,80 @@
+package adapter
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+)
+
+func TestHarmonyPromptWithEffort(t *testing.T) {
+	rendered := renderHarmonyPrompt(map[string]any{
+		"messages": []any{map[string]any{"role": "user", "content": "Hi"}},
+	}, "reasoning_content", "2025-01-01")
+
+	prompt, ok := harmonyPromptWithEffort(rendered, "high")
+	assert.True(t, ok)
+	assert.Equal(t, strings.Replace(rendered, "Reasoning: medium", "Reasoning: high", 1), prompt)
+
+	prompt, ok = harmonyPromptWithEffort("<|start|>system<|message|>Knowledge cutoff: 2024-06\n\n# Valid channels: analysis, final.<|end|><|start|>user<|message|>Hi<|end|>", "low")
+	assert.True(t, ok)
+	assert.Equal(t, "<|start|>system<|message|>Knowledge cutoff: 2024-06\n\nReasoning: low\n\n# Valid channels: analysis, final.<|end|><|start|>user<|message|>Hi<|end|>", prompt)
+
+	prompt, ok = harmonyPromptWithEffort("<|start|>system<|message|>Be brief.<|end|>", "low")
+	assert.True(t, ok)
+	assert.Equal(t, "<|start|>system<|message|>Be brief.\n\nReasoning: low<|end|>", prompt)
+
+	prompt, ok = harmonyPromptWithEffort("Once upon a time", "low")
+	assert.False(t, ok)
+	assert.Equal(t, "Once upon a time", prompt)
+}
+
+func TestHandleCompletions(t *testing.T) {
+	var forwarded map[string]any
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/completions", r.URL.Path)
+		forwarded = nil
+		json.NewDecoder(r.Body).Decode(&forwarded)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[{"text":"Hello"}]}`)
+	}))
+	defer backend.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter(backend.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+
+	send := func(body, effort string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/v1/completions", strings.NewReader(body))
+		if effort != "" {
+			r.Header.Set(reasoningEffortHeader, effort)
+		}
+		w := httptest.NewRecorder()
+		adapter.ServeHTTP(w, r)
+		return w
+	}
+
+	w := send(`{"prompt":"<|start|>system<|message|>Reasoning: medium\n\n# Valid channels: analysis, final.<|end|><|start|>user<|message|>Hi<|end|><|start|>assistant"}`, "high")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"choices":[{"text":"Hello"}]}`, w.Body.String())
+	assert.Equal(t, "<|start|>system<|message|>Reasoning: high\n\n# Valid channels: analysis, final.<|end|><|start|>user<|message|>Hi<|end|><|start|>assistant", forwarded["prompt"])
+	assert.NotContains(t, forwarded, "reasoning")
+	assert.NotContains(t, forwarded, "chat_template_kwargs")
+
+	// Prompts without a Harmony system message are left to the template.
+	w = send(`{"prompt":"Hi","reasoning_effort":"low"}`, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "Hi", forwarded["prompt"])
+	assert.NotContains(t, forwarded, "reasoning_effort")
+	assert.Equal(t, map[string]any{"reasoning_effort": "low"}, forwarded["chat_template_kwargs"])
+
+	w = send(`{"prompt":"Hi"}`, "extreme")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
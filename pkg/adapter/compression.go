@@ -0,0 +1,181 @@
+package adapter
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// upstreamAcceptEncoding is sent to the target with UpstreamCompression.
+const upstreamAcceptEncoding = "br, gzip, deflate"
+
+// setUpstreamEncoding sets the encodings accepted from the target for a
+// request whose response body the adapter reads. Without
+// UpstreamCompression, the header is left to the HTTP client, which asks for
+// gzip and decompresses it transparently.
+func (a *Adapter) setUpstreamEncoding(req *http.Request) {
+	if a.UpstreamCompression {
+		req.Header.Set("Accept-Encoding", upstreamAcceptEncoding)
+	} else {
+		req.Header.Del("Accept-Encoding")
+	}
+}
+
+// decodeResponseBody replaces a compressed response body with its decoded
+// form, so that the rest of the pipeline can parse it.
+func decodeResponseBody(resp *http.Response) error {
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+
+	var decoded io.Reader
+	switch encoding {
+	case "", "identity":
+		return nil
+	case "gzip", "x-gzip":
+		reader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return fmt.Errorf("decoding gzip response: %w", err)
+		}
+		decoded = reader
+	case "deflate":
+		decoded = newDeflateReader(resp.Body)
+	case "br":
+		decoded = brotli.NewReader(resp.Body)
+	default:
+		return fmt.Errorf("unsupported response encoding %q", encoding)
+	}
+
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{decoded, resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+// newDeflateReader decodes a "deflate" body, which is meant to be zlib
+// wrapped but is sent as raw deflate data by some servers.
+func newDeflateReader(body io.Reader) io.Reader {
+	buffered := bufio.NewReader(body)
+	header, err := buffered.Peek(2)
+	if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		if reader, err := zlib.NewReader(buffered); err == nil {
+			return reader
+		}
+	}
+	return flate.NewReader(buffered)
+}
+
+// CompressionMiddleware gzips responses for clients that accept it. Streamed
+// responses are flushed through the compressor, so events still reach the
+// client as they are written.
+type CompressionMiddleware struct {
+	handler http.Handler
+}
+
+func NewCompressionMiddleware(handler http.Handler) *CompressionMiddleware {
+	return &CompressionMiddleware{handler: handler}
+}
+
+func (m *CompressionMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !acceptsGzip(r) || isUpgradeRequest(r) {
+		m.handler.ServeHTTP(w, r)
+		return
+	}
+
+	cw := &compressingWriter{ResponseWriter: w}
+	defer cw.Close()
+	m.handler.ServeHTTP(cw, r)
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.EqualFold(strings.TrimSpace(coding), "gzip") && strings.ReplaceAll(params, " ", "") != "q=0" {
+			return true
+		}
+	}
+	return false
+}
+
+// compressibleTypes are the response media types worth compressing.
+var compressibleTypes = map[string]bool{
+	"application/json":     true,
+	"application/x-ndjson": true,
+	"text/event-stream":    true,
+	"text/plain":           true,
+	"text/html":            true,
+}
+
+type compressingWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	wroteHeader bool
+}
+
+func (cw *compressingWriter) WriteHeader(code int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+
+	header := cw.Header()
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	if header.Get("Content-Encoding") == "" && compressibleTypes[mediaType] &&
+		code != http.StatusNoContent && code != http.StatusNotModified && code >= http.StatusOK {
+		header.Set("Content-Encoding", "gzip")
+		header.Add("Vary", "Accept-Encoding")
+		header.Del("Content-Length")
+		cw.gz = gzip.NewWriter(cw.ResponseWriter)
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *compressingWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.gz != nil {
+		return cw.gz.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+func (cw *compressingWriter) Flush() {
+	if cw.gz != nil {
+		cw.gz.Flush()
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (cw *compressingWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+func (cw *compressingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := cw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	return hijacker.Hijack()
+}
+
+func (cw *compressingWriter) Close() error {
+	if cw.gz == nil {
+		return nil
+	}
+	return cw.gz.Close()
+}
@@ -0,0 +1,82 @@
+package adapter
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// handleDebugTransform applies the request-side transformations to a chat
+// completions request, as it would be sent to the target, and returns the
+// result instead of sending it. The result holds restored reasoning, so it
+// requires the admin token. It goes through the same provider and model
+// routing as a chat request, so that it shows what the target would get.
+func (a *Adapter) handleDebugTransform(w http.ResponseWriter, r *http.Request) {
+	if !a.DebugTransform {
+		http.NotFound(w, r)
+		return
+	}
+	if !a.authorizeAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "Method not allowed", "invalid_request_error", "")
+		return
+	}
+
+	route := a
+	if len(a.routes) > 0 || len(a.modelRoutes) > 0 {
+		var ok bool
+		if route, ok = a.route(w, r); !ok {
+			return
+		}
+	}
+	route.previewChatRequest(w, r)
+}
+
+// previewChatRequest writes the chat request of r as a would send it.
+func (a *Adapter) previewChatRequest(w http.ResponseWriter, r *http.Request) {
+
+	requestBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeRequestBodyError(w, r, err)
+		return
+	}
+
+	var requestData map[string]any
+	if err := json.Unmarshal(requestBody, &requestData); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "Request body is not valid JSON", "invalid_request_error", "")
+		return
+	}
+	if requestData == nil {
+		writeOpenAIError(w, http.StatusBadRequest, "Request body must be a JSON object", "invalid_request_error", "")
+		return
+	}
+
+	chat, err := a.newChatRequest(r)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, err.Error(), "invalid_request_error", "invalid_cache_mode")
+		return
+	}
+	chat.data = requestData
+	transformed, path, ok := a.prepareChatRequest(w, r, chat, "/v1/chat/completions")
+	if !ok {
+		return
+	}
+
+	targetURL, err := url.Parse(a.Target)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Invalid target URL", "server_error", "")
+		return
+	}
+	targetURL.Path = strings.TrimSuffix(targetURL.Path, "/") + path
+
+	a.logger.InfoContext(r.Context(), "previewed chat request transformation", "target", targetURL.String())
+	writeAdminJSON(w, map[string]any{
+		"target": targetURL.String(),
+		"body":   transformed,
+	})
+}
@@ -0,0 +1,76 @@
+package adapter
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugTransform(t *testing.T) {
+	adapter := newTestAdapter()
+	adapter.DebugTransform = true
+	adapter.AdminToken = "secret"
+	adapter.cache.Put("", "call_1", ReasoningItem{ID: "call_1", Content: "Look up Paris."})
+
+	body := `{"model":"gpt-oss-20b","reasoning":{"effort":"high"},"messages":[
+		{"role":"user","content":"Weather in Paris?"},
+		{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{}"}}]},
+		{"role":"tool","tool_call_id":"call_1","content":"Sunny"}
+	]}`
+	r := httptest.NewRequest(http.MethodPost, "/debug/transform", strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var preview struct {
+		Target string         `json:"target"`
+		Body   map[string]any `json:"body"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &preview))
+	assert.Equal(t, "http://localhost:8080/v1/chat/completions", preview.Target)
+	assert.Equal(t, map[string]any{"reasoning_effort": "high"}, preview.Body["chat_template_kwargs"])
+
+	messages := preview.Body["messages"].([]any)
+	assert.Equal(t, "Look up Paris.", messages[1].(map[string]any)["reasoning_content"])
+}
+
+func TestDebugTransform_Disabled(t *testing.T) {
+	adapter := newTestAdapter()
+
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/debug/transform", strings.NewReader(`{}`)))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestDebugTransform_Admin(t *testing.T) {
+	adapter := newTestAdapter()
+	adapter.DebugTransform = true
+	keys, err := LoadAPIKeys(writeAPIKeys(t, "keys: [{name: alice, key: sk-alice}]"))
+	require.NoError(t, err)
+	adapter.APIKeys = keys
+
+	status := func(token string) int {
+		r := httptest.NewRequest(http.MethodPost, "/debug/transform", strings.NewReader(`{"messages":[]}`))
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		adapter.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// Without an admin token the endpoint does not exist.
+	assert.Equal(t, http.StatusNotFound, status("sk-alice"))
+
+	adapter.AdminToken = "secret"
+	assert.Equal(t, http.StatusForbidden, status("sk-alice"))
+	assert.Equal(t, http.StatusUnauthorized, status("sk-bob"))
+	assert.Equal(t, http.StatusUnauthorized, status(""))
+	assert.Equal(t, http.StatusOK, status("secret"))
+}
@@ -0,0 +1,72 @@
+package adapter
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"strconv"
+)
+
+// NewDebugHandler returns the handler for the debug listener: the
+// net/http/pprof profiles under /debug/pprof/ and Go runtime statistics in
+// the Prometheus text exposition format at /metrics. It has no
+// authentication, so it should only be reachable by operators.
+func NewDebugHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/metrics", handleRuntimeMetrics)
+	return mux
+}
+
+// handleRuntimeMetrics serves goroutine, heap and garbage collector
+// statistics, named as by the Prometheus Go client.
+func handleRuntimeMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	writeMetric(w, "go_info", "gauge", "Information about the Go environment.",
+		sample{labels: map[string]string{"version": runtime.Version()}, value: 1})
+	writeMetric(w, "go_goroutines", "gauge", "Number of goroutines that currently exist.",
+		sample{value: int64(runtime.NumGoroutine())})
+	writeMetric(w, "go_memstats_alloc_bytes", "gauge", "Number of bytes allocated and still in use.",
+		sample{value: int64(stats.Alloc)})
+	writeMetric(w, "go_memstats_alloc_bytes_total", "counter", "Total number of bytes allocated, even if freed.",
+		sample{value: int64(stats.TotalAlloc)})
+	writeMetric(w, "go_memstats_sys_bytes", "gauge", "Number of bytes obtained from the system.",
+		sample{value: int64(stats.Sys)})
+	writeMetric(w, "go_memstats_heap_alloc_bytes", "gauge", "Number of heap bytes allocated and still in use.",
+		sample{value: int64(stats.HeapAlloc)})
+	writeMetric(w, "go_memstats_heap_inuse_bytes", "gauge", "Number of heap bytes that are in use.",
+		sample{value: int64(stats.HeapInuse)})
+	writeMetric(w, "go_memstats_heap_idle_bytes", "gauge", "Number of heap bytes waiting to be used.",
+		sample{value: int64(stats.HeapIdle)})
+	writeMetric(w, "go_memstats_heap_released_bytes", "gauge", "Number of heap bytes released to the OS.",
+		sample{value: int64(stats.HeapReleased)})
+	writeMetric(w, "go_memstats_heap_objects", "gauge", "Number of allocated objects.",
+		sample{value: int64(stats.HeapObjects)})
+	writeMetric(w, "go_memstats_stack_inuse_bytes", "gauge", "Number of bytes in use by the stack allocator.",
+		sample{value: int64(stats.StackInuse)})
+	writeMetric(w, "go_memstats_next_gc_bytes", "gauge", "Heap size at which the next garbage collection will take place.",
+		sample{value: int64(stats.NextGC)})
+	writeMetric(w, "go_gc_cycles_total", "counter", "Number of completed garbage collection cycles.",
+		sample{value: int64(stats.NumGC)})
+	writeFloatMetric(w, "go_gc_pause_seconds_total", "counter", "Total time the world was stopped for garbage collection.",
+		float64(stats.PauseTotalNs)/1e9)
+	writeFloatMetric(w, "go_memstats_last_gc_time_seconds", "gauge", "Time of the last garbage collection since the epoch.",
+		float64(stats.LastGC)/1e9)
+	writeFloatMetric(w, "go_memstats_gc_cpu_fraction", "gauge", "Fraction of the CPU time used by the garbage collector since the program started.",
+		stats.GCCPUFraction)
+}
+
+func writeFloatMetric(w io.Writer, name, kind, help string, value float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	fmt.Fprintf(w, "%s %s\n", name, strconv.FormatFloat(value, 'g', -1, 64))
+}
@@ -0,0 +1,144 @@
+package adapter
+
+import (
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// effortResponseHeader tells the client the reasoning effort its request
+// was sent with, when effort downgrades are enabled.
+const effortResponseHeader = "X-GPT-OSS-Reasoning-Effort"
+
+// downgradeLatencyWindow is how far back time to first token observations
+// count towards the latency signal.
+const downgradeLatencyWindow = time.Minute
+
+// EffortDowngrade lowers the reasoning effort of requests while the target
+// is under load, so that interactive latency stays acceptable during a
+// spike. Each threshold crossed lowers the effort by one step, high to
+// medium to low, taking the larger of the steps for the queue and for
+// latency.
+type EffortDowngrade struct {
+	// QueueThresholds are the numbers of requests waiting in the queue, or
+	// in flight without one, at which the effort is lowered by another step.
+	QueueThresholds []int
+
+	// LatencyThresholds are the average times to first token of the
+	// streams of the last minute at which the effort is lowered by another
+	// step.
+	LatencyThresholds []time.Duration
+
+	mutex      sync.Mutex
+	latencies  []latencyObservation
+	downgraded atomic.Int64
+}
+
+type latencyObservation struct {
+	at      time.Time
+	latency time.Duration
+}
+
+func NewEffortDowngrade(queueThresholds []int, latencyThresholds []time.Duration) *EffortDowngrade {
+	queueThresholds = slices.Clone(queueThresholds)
+	slices.Sort(queueThresholds)
+	latencyThresholds = slices.Clone(latencyThresholds)
+	slices.Sort(latencyThresholds)
+	return &EffortDowngrade{QueueThresholds: queueThresholds, LatencyThresholds: latencyThresholds}
+}
+
+// Observe records the time to first token of a stream.
+func (d *EffortDowngrade) Observe(latency time.Duration) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	now := time.Now()
+	d.prune(now)
+	d.latencies = append(d.latencies, latencyObservation{at: now, latency: latency})
+}
+
+// Latency returns the average time to first token of the streams of the
+// last minute, or zero if there were none.
+func (d *EffortDowngrade) Latency() time.Duration {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.prune(time.Now())
+	if len(d.latencies) == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, o := range d.latencies {
+		total += o.latency
+	}
+	return total / time.Duration(len(d.latencies))
+}
+
+func (d *EffortDowngrade) prune(now time.Time) {
+	i := 0
+	for i < len(d.latencies) && now.Sub(d.latencies[i].at) > downgradeLatencyWindow {
+		i++
+	}
+	d.latencies = d.latencies[i:]
+}
+
+// Steps returns how many steps to lower the effort by with queued requests
+// waiting.
+func (d *EffortDowngrade) Steps(queued int) int {
+	queueSteps := 0
+	for _, threshold := range d.QueueThresholds {
+		if queued >= threshold {
+			queueSteps++
+		}
+	}
+
+	latencySteps := 0
+	if len(d.LatencyThresholds) > 0 {
+		latency := d.Latency()
+		for _, threshold := range d.LatencyThresholds {
+			if latency >= threshold {
+				latencySteps++
+			}
+		}
+	}
+	return max(queueSteps, latencySteps)
+}
+
+// lowerEffort returns effort lowered by steps, down to low.
+func lowerEffort(effort string, steps int) string {
+	i := slices.Index(reasoningEfforts, effort)
+	if i < 0 {
+		return effort
+	}
+	return reasoningEfforts[max(i-steps, 0)]
+}
+
+// applyEffortDowngrade lowers the reasoning effort of a request by the
+// steps the current load calls for. Requests without an effort are taken
+// to ask for medium, gpt-oss's default.
+func (a *Adapter) applyEffortDowngrade(r *http.Request, requestData map[string]any) {
+	queued := int(a.inflight.Load())
+	if a.Queue != nil {
+		queued = a.Queue.Waiting()
+	}
+	steps := a.EffortDowngrade.Steps(queued)
+	if steps == 0 {
+		return
+	}
+
+	requested, ok := a.requestedEffort(requestData).(string)
+	if !ok {
+		requested = "medium"
+	}
+	effort := lowerEffort(requested, steps)
+	if effort == requested {
+		return
+	}
+
+	if a.Provider.ReasoningEffort != "" {
+		a.deleteNestedField(requestData, a.Provider.ReasoningEffort)
+	}
+	a.setNestedField(requestData, "reasoning.effort", effort)
+	a.EffortDowngrade.downgraded.Add(1)
+	a.logger.InfoContext(r.Context(), "lowered reasoning effort under load", "requested", requested, "effort", effort, "queued", queued)
+}
@@ -0,0 +1,71 @@
+package adapter
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEffortDowngrade(t *testing.T) {
+	var forwarded map[string]any
+	adapter := newEffortTestAdapter(t, &forwarded)
+	// Without a queue, the request itself is in flight, so the first
+	// threshold is always crossed.
+	adapter.EffortDowngrade = NewEffortDowngrade([]int{100, 1}, nil)
+
+	w := sendEffortRequest(adapter, `{"messages":[],"reasoning":{"effort":"high"}}`, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, map[string]any{"reasoning_effort": "medium"}, forwarded["chat_template_kwargs"])
+	assert.Equal(t, "medium", w.Header().Get(effortResponseHeader))
+
+	// Requests without an effort are lowered from gpt-oss's default.
+	w = sendEffortRequest(adapter, `{"messages":[]}`, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, map[string]any{"reasoning_effort": "low"}, forwarded["chat_template_kwargs"])
+	assert.Equal(t, "low", w.Header().Get(effortResponseHeader))
+
+	w = sendEffortRequest(adapter, `{"messages":[]}`, "low")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "low", w.Header().Get(effortResponseHeader))
+	assert.Equal(t, int64(2), adapter.EffortDowngrade.downgraded.Load())
+
+	// Without load, the effort is left alone but still reported.
+	adapter.EffortDowngrade = NewEffortDowngrade([]int{100}, nil)
+	w = sendEffortRequest(adapter, `{"messages":[]}`, "high")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, map[string]any{"reasoning_effort": "high"}, forwarded["chat_template_kwargs"])
+	assert.Equal(t, "high", w.Header().Get(effortResponseHeader))
+}
+
+func TestEffortDowngrade_Steps(t *testing.T) {
+	d := NewEffortDowngrade([]int{8, 4}, []time.Duration{2 * time.Second, 5 * time.Second})
+	assert.Equal(t, []int{4, 8}, d.QueueThresholds)
+	assert.Equal(t, 0, d.Steps(3))
+	assert.Equal(t, 1, d.Steps(4))
+	assert.Equal(t, 2, d.Steps(9))
+
+	d.Observe(time.Second)
+	d.Observe(4 * time.Second)
+	assert.Equal(t, 2500*time.Millisecond, d.Latency())
+	assert.Equal(t, 1, d.Steps(0))
+	assert.Equal(t, 2, d.Steps(8))
+
+	d.Observe(10 * time.Second)
+	assert.Equal(t, 2, d.Steps(0))
+
+	// Observations older than the window no longer count.
+	d.latencies[0].at = time.Now().Add(-2 * downgradeLatencyWindow)
+	d.latencies[1].at = time.Now().Add(-2 * downgradeLatencyWindow)
+	d.latencies[2].at = time.Now().Add(-2 * downgradeLatencyWindow)
+	assert.Equal(t, time.Duration(0), d.Latency())
+}
+
+func TestLowerEffort(t *testing.T) {
+	assert.Equal(t, "medium", lowerEffort("high", 1))
+	assert.Equal(t, "low", lowerEffort("high", 2))
+	assert.Equal(t, "low", lowerEffort("medium", 5))
+	assert.Equal(t, "minimal", lowerEffort("minimal", 1))
+}
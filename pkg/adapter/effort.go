@@ -0,0 +1,89 @@
+package adapter
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// reasoningEffortHeader requests a reasoning effort from clients that can
+// add headers but not body fields.
+const reasoningEffortHeader = "X-Reasoning-Effort"
+
+// reasoningEfforts are the effort levels gpt-oss understands.
+var reasoningEfforts = []string{"low", "medium", "high"}
+
+// requestedEffort returns the reasoning effort a request body asks for,
+// either as reasoning.effort or in the provider's field.
+func (a *Adapter) requestedEffort(requestData map[string]any) any {
+	requested := a.getNestedField(requestData, "reasoning.effort")
+	if requested == nil && a.Provider.ReasoningEffort != "" {
+		requested = a.getNestedField(requestData, a.Provider.ReasoningEffort)
+	}
+	return requested
+}
+
+// chooseEffort sets reasoning.effort from the X-Reasoning-Effort header, the
+// effort policy or DefaultReasoningEffort, in that order, when the body does
+// not request an effort itself, and then lowers it if the target is under
+// load. An invalid header is returned as an error.
+func (a *Adapter) chooseEffort(r *http.Request, requestData map[string]any) error {
+	if err := a.applyEffortHeader(r, requestData); err != nil {
+		return err
+	}
+	if a.Policy != nil {
+		a.applyEffortPolicy(r, requestData)
+	}
+	if a.DefaultReasoningEffort != "" {
+		a.applyDefaultEffort(requestData)
+	}
+	if a.EffortDowngrade != nil {
+		a.applyEffortDowngrade(r, requestData)
+	}
+	return nil
+}
+
+// applyEffortHeader sets reasoning.effort from the X-Reasoning-Effort header
+// when the body does not request an effort itself. It returns a
+// *TransformError if the header is invalid.
+func (a *Adapter) applyEffortHeader(r *http.Request, requestData map[string]any) error {
+	value := r.Header.Get(reasoningEffortHeader)
+	if value == "" {
+		return nil
+	}
+
+	effort := strings.ToLower(strings.TrimSpace(value))
+	if !ValidReasoningEffort(effort) {
+		return &TransformError{
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("Invalid %s header %q: must be one of %s", reasoningEffortHeader, value, strings.Join(reasoningEfforts, ", ")),
+			Type:    "invalid_request_error",
+			Code:    "invalid_reasoning_effort",
+		}
+	}
+
+	if a.requestedEffort(requestData) != nil {
+		return nil
+	}
+
+	a.setNestedField(requestData, "reasoning.effort", effort)
+	a.logger.DebugContext(r.Context(), "applied reasoning effort header", "effort", effort)
+	return nil
+}
+
+// applyDefaultEffort sets reasoning.effort to DefaultReasoningEffort when the
+// request does not ask for an effort.
+func (a *Adapter) applyDefaultEffort(requestData map[string]any) {
+	if a.requestedEffort(requestData) != nil {
+		return
+	}
+
+	a.setNestedField(requestData, "reasoning.effort", a.DefaultReasoningEffort)
+	a.logger.Debug("applied default reasoning effort", "effort", a.DefaultReasoningEffort)
+}
+
+// ValidReasoningEffort reports whether effort is a level gpt-oss understands.
+func ValidReasoningEffort(effort string) bool {
+	return slices.Contains(reasoningEfforts, effort)
+}
@@ -0,0 +1,126 @@
+package adapter
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+)
+
+// newEffortTestAdapter returns a llama.cpp adapter whose upstream stores the
+// last request body it received in forwarded.
+func newEffortTestAdapter(t *testing.T, forwarded *map[string]any) *Adapter {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*forwarded = nil
+		json.NewDecoder(r.Body).Decode(forwarded)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[]}`)
+	}))
+	t.Cleanup(upstream.Close)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewAdapter(upstream.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+}
+
+func sendEffortRequest(adapter *Adapter, body, effort string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	if effort != "" {
+		r.Header.Set(reasoningEffortHeader, effort)
+	}
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, r)
+	return w
+}
+
+func TestReasoningEffortHeader(t *testing.T) {
+	var forwarded map[string]any
+	adapter := newEffortTestAdapter(t, &forwarded)
+	send := func(body, effort string) *httptest.ResponseRecorder {
+		return sendEffortRequest(adapter, body, effort)
+	}
+
+	require.Equal(t, http.StatusOK, send(`{"messages":[]}`, " High").Code)
+	assert.Equal(t, map[string]any{"reasoning_effort": "high"}, forwarded["chat_template_kwargs"])
+
+	// An effort in the body takes precedence.
+	require.Equal(t, http.StatusOK, send(`{"messages":[],"reasoning":{"effort":"low"}}`, "high").Code)
+	assert.Equal(t, map[string]any{"reasoning_effort": "low"}, forwarded["chat_template_kwargs"])
+
+	require.Equal(t, http.StatusOK, send(`{"messages":[],"chat_template_kwargs":{"reasoning_effort":"medium"}}`, "high").Code)
+	assert.Equal(t, map[string]any{"reasoning_effort": "medium"}, forwarded["chat_template_kwargs"])
+
+	forwarded = nil
+	w := send(`{"messages":[]}`, "extreme")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"invalid_reasoning_effort"`)
+	assert.Nil(t, forwarded)
+}
+
+func TestDefaultReasoningEffort(t *testing.T) {
+	var forwarded map[string]any
+	adapter := newEffortTestAdapter(t, &forwarded)
+	adapter.DefaultReasoningEffort = "high"
+
+	require.Equal(t, http.StatusOK, sendEffortRequest(adapter, `{"messages":[]}`, "").Code)
+	assert.Equal(t, map[string]any{"reasoning_effort": "high"}, forwarded["chat_template_kwargs"])
+
+	require.Equal(t, http.StatusOK, sendEffortRequest(adapter, `{"messages":[],"reasoning":{"effort":"low"}}`, "").Code)
+	assert.Equal(t, map[string]any{"reasoning_effort": "low"}, forwarded["chat_template_kwargs"])
+
+	require.Equal(t, http.StatusOK, sendEffortRequest(adapter, `{"messages":[]}`, "medium").Code)
+	assert.Equal(t, map[string]any{"reasoning_effort": "medium"}, forwarded["chat_template_kwargs"])
+
+	// The policy is consulted before the default.
+	policy, err := NewEffortPolicy([]string{"tools:low"}, false)
+	require.NoError(t, err)
+	adapter.Policy = policy
+	require.Equal(t, http.StatusOK, sendEffortRequest(adapter, `{"messages":[],"tools":[{"type":"function","function":{"name":"f"}}]}`, "").Code)
+	assert.Equal(t, map[string]any{"reasoning_effort": "low"}, forwarded["chat_template_kwargs"])
+}
+
+// nullBodyPaths are the endpoints that apply the reasoning effort to the
+// request body.
+var nullBodyPaths = []string{"/v1/chat/completions", "/v1/completions", "/debug/transform"}
+
+// assertNullBodyRejected posts a body of null, which is valid JSON but not
+// an object, and checks that it is rejected without being forwarded.
+func assertNullBodyRejected(t *testing.T, adapter *Adapter, forwarded *map[string]any, header http.Header) {
+	t.Helper()
+	adapter.DebugTransform = true
+	adapter.AdminToken = "secret"
+	for _, path := range nullBodyPaths {
+		*forwarded = nil
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader("null"))
+		r.Header.Set("Authorization", "Bearer secret")
+		for name, values := range header {
+			r.Header[name] = values
+		}
+		w := httptest.NewRecorder()
+		adapter.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+		assert.Contains(t, w.Body.String(), `"type":"invalid_request_error"`, path)
+		assert.Nil(t, *forwarded, path)
+	}
+}
+
+func TestReasoningEffortHeader_NullBody(t *testing.T) {
+	var forwarded map[string]any
+	adapter := newEffortTestAdapter(t, &forwarded)
+	assertNullBodyRejected(t, adapter, &forwarded, http.Header{reasoningEffortHeader: {"high"}})
+}
+
+func TestDefaultReasoningEffort_NullBody(t *testing.T) {
+	var forwarded map[string]any
+	adapter := newEffortTestAdapter(t, &forwarded)
+	adapter.DefaultReasoningEffort = "high"
+	assertNullBodyRejected(t, adapter, &forwarded, nil)
+}
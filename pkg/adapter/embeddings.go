@@ -0,0 +1,160 @@
+package adapter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// handleEmbeddings passes embeddings requests through, splitting those with
+// more inputs than EmbeddingsBatchSize into batches the backend accepts.
+// llama.cpp, for one, rejects a request with more inputs than its batch
+// size.
+func (a *Adapter) handleEmbeddings(w http.ResponseWriter, r *http.Request) {
+	if a.EmbeddingsBatchSize <= 0 || r.Method != http.MethodPost {
+		a.handleDefault(w, r)
+		return
+	}
+
+	requestBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeRequestBodyError(w, r, err)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(requestBody))
+
+	var requestData map[string]any
+	if err := json.Unmarshal(requestBody, &requestData); err != nil {
+		a.handleDefault(w, r)
+		return
+	}
+	inputs, ok := requestData["input"].([]any)
+	if !ok || len(inputs) <= a.EmbeddingsBatchSize || isTokenArray(inputs) {
+		a.handleDefault(w, r)
+		return
+	}
+
+	a.logger.InfoContext(r.Context(), "splitting embeddings request into batches", "inputs", len(inputs), "batch_size", a.EmbeddingsBatchSize)
+
+	var merged map[string]any
+	var data []any
+	usage := make(map[string]float64)
+	for offset := 0; offset < len(inputs); offset += a.EmbeddingsBatchSize {
+		batch := inputs[offset:min(offset+a.EmbeddingsBatchSize, len(inputs))]
+		requestData["input"] = batch
+
+		resp, body, err := a.sendEmbeddingsBatch(r, requestData)
+		if err != nil {
+			if r.Context().Err() != nil {
+				a.logger.InfoContext(r.Context(), "client disconnected before the target responded", "error", err)
+				return
+			}
+			a.logger.ErrorContext(r.Context(), "failed to send embeddings batch", "offset", offset, "error", err)
+			writeError(w, r, http.StatusBadGateway, "Failed to proxy request", "server_error", "upstream_error")
+			return
+		}
+
+		var responseData map[string]any
+		if resp.StatusCode != http.StatusOK || json.Unmarshal(body, &responseData) != nil {
+			// Relay the first failure as the target sent it.
+			a.logger.WarnContext(r.Context(), "embeddings batch failed", "offset", offset, "status", resp.StatusCode)
+			for name, values := range resp.Header {
+				if name != "Content-Length" {
+					w.Header()[name] = values
+				}
+			}
+			w.WriteHeader(resp.StatusCode)
+			w.Write(body)
+			return
+		}
+
+		items, _ := responseData["data"].([]any)
+		for i, item := range items {
+			embedding, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			index := i
+			if n, ok := embedding["index"].(float64); ok {
+				index = int(n)
+			}
+			embedding["index"] = offset + index
+			data = append(data, embedding)
+		}
+		if u, ok := responseData["usage"].(map[string]any); ok {
+			for name, value := range u {
+				if n, ok := value.(float64); ok {
+					usage[name] += n
+				}
+			}
+		}
+		if merged == nil {
+			merged = responseData
+		}
+	}
+
+	sort.SliceStable(data, func(i, j int) bool {
+		return data[i].(map[string]any)["index"].(int) < data[j].(map[string]any)["index"].(int)
+	})
+	merged["data"] = data
+	if len(usage) > 0 {
+		merged["usage"] = usage
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(merged)
+}
+
+// sendEmbeddingsBatch sends an embeddings request for one batch to the
+// target and reads the response.
+func (a *Adapter) sendEmbeddingsBatch(r *http.Request, requestData map[string]any) (*http.Response, []byte, error) {
+	requestBody, err := json.Marshal(requestData)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	targetURL, err := url.Parse(a.Target)
+	if err != nil {
+		return nil, nil, err
+	}
+	targetURL.Path = strings.TrimSuffix(targetURL.Path, "/") + r.URL.Path
+	targetURL.RawQuery = r.URL.RawQuery
+
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, targetURL.String(), bytes.NewReader(requestBody))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header = r.Header.Clone()
+	req.Header.Del("Content-Length")
+	a.rewritePassthrough(req, r)
+	// Each batch is decoded to be merged, so let the transport negotiate
+	// compression.
+	req.Header.Del("Accept-Encoding")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading response: %w", err)
+	}
+	return resp, body, nil
+}
+
+// isTokenArray reports whether an embeddings input is a single input given
+// as token IDs rather than a batch.
+func isTokenArray(inputs []any) bool {
+	for _, input := range inputs {
+		if _, ok := input.(float64); !ok {
+			return false
+		}
+	}
+	return true
+}
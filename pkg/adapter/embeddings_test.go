@@ -0,0 +1,123 @@
+package adapter
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+)
+
+// newEmbeddingsTestAdapter returns an adapter for a backend that accepts at
+// most two inputs and embeds each as its length.
+func newEmbeddingsTestAdapter(t *testing.T, calls *atomic.Int32) *Adapter {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		assert.Equal(t, "/v1/embeddings", r.URL.Path)
+
+		var request struct {
+			Input any `json:"input"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		inputs, ok := request.Input.([]any)
+		if !ok || isTokenArray(inputs) {
+			inputs = []any{request.Input}
+		}
+		if len(inputs) > 2 {
+			http.Error(w, `{"error":{"message":"input is too large to process"}}`, http.StatusBadRequest)
+			return
+		}
+
+		data := make([]any, len(inputs))
+		for i, input := range inputs {
+			text, _ := input.(string)
+			data[i] = map[string]any{"object": "embedding", "index": i, "embedding": []any{len(text)}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"object": "list",
+			"model":  "embed",
+			"data":   data,
+			"usage":  map[string]any{"prompt_tokens": len(inputs), "total_tokens": len(inputs)},
+		})
+	}))
+	t.Cleanup(backend.Close)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewAdapter(backend.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+}
+
+func TestEmbeddings_Batching(t *testing.T) {
+	var calls atomic.Int32
+	adapter := newEmbeddingsTestAdapter(t, &calls)
+	adapter.EmbeddingsBatchSize = 2
+
+	body := `{"model":"embed","input":["a","bb","ccc","dddd","eeeee"]}`
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/embeddings", strings.NewReader(body)))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, int32(3), calls.Load())
+
+	var response struct {
+		Model string `json:"model"`
+		Data  []struct {
+			Index     int   `json:"index"`
+			Embedding []int `json:"embedding"`
+		} `json:"data"`
+		Usage map[string]int `json:"usage"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "embed", response.Model)
+	require.Len(t, response.Data, 5)
+	for i, item := range response.Data {
+		assert.Equal(t, i, item.Index)
+		assert.Equal(t, []int{i + 1}, item.Embedding)
+	}
+	assert.Equal(t, map[string]int{"prompt_tokens": 5, "total_tokens": 5}, response.Usage)
+}
+
+func TestEmbeddings_Passthrough(t *testing.T) {
+	var calls atomic.Int32
+	adapter := newEmbeddingsTestAdapter(t, &calls)
+
+	// Without a batch size, large batches reach the backend as they are.
+	body := `{"model":"embed","input":["a","bb","ccc"]}`
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/embeddings", strings.NewReader(body)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// A single input of token IDs is not a batch.
+	adapter.EmbeddingsBatchSize = 2
+	body = `{"model":"embed","input":[1,2,3]}`
+	w = httptest.NewRecorder()
+	adapter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/embeddings", strings.NewReader(body)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestEmbeddings_BatchError(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		io.WriteString(w, `{"error":{"message":"loading model"}}`)
+	}))
+	defer backend.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter(backend.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+	adapter.EmbeddingsBatchSize = 1
+
+	body := `{"model":"embed","input":["a","b"]}`
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/embeddings", strings.NewReader(body)))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, `{"error":{"message":"loading model"}}`, w.Body.String())
+}
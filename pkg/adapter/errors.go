@@ -0,0 +1,77 @@
+package adapter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type openAIError struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	Code    string `json:"code,omitempty"`
+}
+
+// writeOpenAIError writes an error body in the format returned by the OpenAI
+// API so that client SDKs and agent frameworks can handle it.
+func writeOpenAIError(w http.ResponseWriter, status int, message string, errType string, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": openAIError{
+			Message: message,
+			Type:    errType,
+			Code:    code,
+		},
+	})
+}
+
+// writeError writes an error in the format of the API a request was made
+// to: Anthropic's for the Messages API and OpenAI's otherwise.
+func writeError(w http.ResponseWriter, r *http.Request, status int, message string, errType string, code string) {
+	if isMessagesPath(r.URL.Path) {
+		writeAnthropicError(w, status, anthropicErrorType(status), message)
+		return
+	}
+	writeOpenAIError(w, status, message, errType, code)
+}
+
+func isMessagesPath(path string) bool {
+	return strings.HasSuffix(path, "/messages") || strings.HasSuffix(path, "/messages/count_tokens")
+}
+
+// writeRequestBodyError reports a request body that could not be read,
+// usually because it exceeds the size limit or the client went away.
+func writeRequestBodyError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeRequestTooLarge(w, r, tooLarge.Limit)
+		return
+	}
+	writeError(w, r, http.StatusBadRequest, "Failed to read request body: "+err.Error(), "invalid_request_error", "")
+}
+
+func writeRequestTooLarge(w http.ResponseWriter, r *http.Request, limit int64) {
+	message := fmt.Sprintf("Request body exceeds the maximum size of %d bytes", limit)
+	writeError(w, r, http.StatusRequestEntityTooLarge, message, "invalid_request_error", "request_too_large")
+}
+
+// emitStreamError terminates an event stream that failed part way through
+// with an error event and the [DONE] sentinel, so that clients stop waiting
+// for more tokens.
+func emitStreamError(emit func(line string), err error) {
+	data, _ := json.Marshal(map[string]any{
+		"error": openAIError{
+			Message: "Upstream stream interrupted: " + err.Error(),
+			Type:    "server_error",
+			Code:    "stream_interrupted",
+		},
+	})
+
+	emit("data: " + string(data))
+	emit("")
+	emit("data: [DONE]")
+	emit("")
+}
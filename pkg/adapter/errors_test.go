@@ -0,0 +1,102 @@
+package adapter
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+)
+
+func TestRequestErrors(t *testing.T) {
+	var forwarded atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded.Add(1)
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[]}`)
+	}))
+	defer upstream.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter(upstream.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+	adapter.MaxRequestSize = 64
+
+	large := `{"messages":[{"role":"user","content":"` + strings.Repeat("a", 100) + `"}]}`
+
+	tests := []struct {
+		name    string
+		path    string
+		body    io.Reader
+		status  int
+		errType string
+		code    string
+	}{
+		{"invalid json", "/v1/chat/completions", strings.NewReader(`{"messages":`), http.StatusBadRequest, "invalid_request_error", ""},
+		{"too large", "/v1/chat/completions", strings.NewReader(large), http.StatusRequestEntityTooLarge, "invalid_request_error", "request_too_large"},
+		// Without a Content-Length the limit applies while the body is read.
+		{"too large chunked", "/v1/chat/completions", io.MultiReader(strings.NewReader(large)), http.StatusRequestEntityTooLarge, "invalid_request_error", "request_too_large"},
+		{"too large responses", "/v1/responses", io.MultiReader(strings.NewReader(large)), http.StatusRequestEntityTooLarge, "invalid_request_error", "request_too_large"},
+		{"too large passthrough", "/v1/embeddings", io.MultiReader(strings.NewReader(large)), http.StatusRequestEntityTooLarge, "invalid_request_error", "request_too_large"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forwarded.Store(0)
+			r := httptest.NewRequest(http.MethodPost, tt.path, tt.body)
+			w := httptest.NewRecorder()
+			adapter.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+			var body struct {
+				Error openAIError `json:"error"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, tt.errType, body.Error.Type)
+			assert.Equal(t, tt.code, body.Error.Code)
+			assert.NotEmpty(t, body.Error.Message)
+			assert.Zero(t, forwarded.Load())
+		})
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/messages", io.MultiReader(strings.NewReader(large)))
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Contains(t, w.Body.String(), `"type":"request_too_large"`)
+
+	r = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"messages":[]}`))
+	w = httptest.NewRecorder()
+	adapter.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRequestErrors_Upstream(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	upstream.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter(upstream.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"messages":[]}`))
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"upstream_error"`)
+
+	r = httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"m","max_tokens":10,"messages":[{"role":"user","content":"Hi"}]}`))
+	w = httptest.NewRecorder()
+	adapter.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Contains(t, w.Body.String(), `"type":"api_error"`)
+}
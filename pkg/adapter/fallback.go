@@ -0,0 +1,36 @@
+package adapter
+
+import (
+	"net/http"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/types"
+)
+
+// backendHeader tells the client whether the adapter's target ("primary")
+// or the fallback target ("fallback") served a chat request, when a fallback
+// target is set.
+const backendHeader = "X-GPT-OSS-Backend"
+
+// SetFallback sends chat requests to target, with provider, when the
+// adapter's own target cannot be reached, times out before responding or
+// responds with a server error. The fallback shares the adapter's cache and
+// settings, so SetFallback must be called after the adapter is configured.
+func (a *Adapter) SetFallback(target string, provider types.Provider) {
+	a.fallback = a.newRoute(target, provider)
+}
+
+// failOver sends a chat request to the fallback target instead, starting
+// over from the request data as it was before it was prepared for the
+// adapter's own target.
+func (a *Adapter) failOver(w http.ResponseWriter, r *http.Request, chat *chatRequest, path string, data map[string]any) (*http.Response, *Adapter, bool) {
+	chat.data = data
+	model, _ := data["model"].(string)
+	if renamed, ok := a.FallbackModels[model]; ok {
+		data["model"] = renamed
+	}
+	resp, upstream, ok := a.fallback.forwardChatRequest(w, r, chat, path)
+	if ok {
+		w.Header().Set(backendHeader, "fallback")
+	}
+	return resp, upstream, ok
+}
@@ -0,0 +1,119 @@
+package adapter
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+	"github.com/aldehir/gpt-oss-adapter/providers/openrouter"
+)
+
+func newFallbackTestAdapter(t *testing.T, primary, fallback http.HandlerFunc) *Adapter {
+	primaryServer := httptest.NewServer(primary)
+	t.Cleanup(primaryServer.Close)
+	fallbackServer := httptest.NewServer(fallback)
+	t.Cleanup(fallbackServer.Close)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter(primaryServer.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+	adapter.SetFallback(fallbackServer.URL, openrouter.NewProvider())
+	return adapter
+}
+
+func TestFallback_ServerError(t *testing.T) {
+	var fallbackRequest map[string]any
+	adapter := newFallbackTestAdapter(t,
+		func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "model not loaded", http.StatusServiceUnavailable)
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&fallbackRequest))
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"Hi","reasoning":"Greet.","tool_calls":[{"id":"call_1","type":"function","function":{"name":"greet","arguments":"{}"}}]},"finish_reason":"tool_calls"}]}`)
+		})
+	adapter.FallbackModels = map[string]string{"gpt-oss-120b": "openai/gpt-oss-120b"}
+
+	body := `{"model":"gpt-oss-120b","reasoning":{"effort":"high"},"messages":[{"role":"user","content":"Hello"}]}`
+	r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, r)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "fallback", w.Header().Get(backendHeader))
+
+	// The request is prepared for the fallback's provider.
+	require.NotNil(t, fallbackRequest)
+	assert.Equal(t, "openai/gpt-oss-120b", fallbackRequest["model"])
+	assert.Equal(t, map[string]any{"effort": "high"}, fallbackRequest["reasoning"])
+	assert.NotContains(t, fallbackRequest, "chat_template_kwargs")
+
+	// The response is processed with the fallback's provider as well.
+	item, found := adapter.cache.Get("", "call_1")
+	require.True(t, found)
+	assert.Equal(t, "Greet.", item.Content)
+}
+
+func TestFallback_Unreachable(t *testing.T) {
+	adapter := newFallbackTestAdapter(t,
+		func(w http.ResponseWriter, r *http.Request) {},
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, `data: {"choices":[{"index":0,"delta":{"content":"Hi"},"finish_reason":"stop"}]}`+"\n\n")
+			io.WriteString(w, "data: [DONE]\n\n")
+		})
+	adapter.Target = "http://127.0.0.1:1"
+
+	body := `{"model":"gpt-oss-20b","stream":true,"messages":[{"role":"user","content":"Hello"}]}`
+	r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, r)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "fallback", w.Header().Get(backendHeader))
+	assert.Contains(t, w.Body.String(), `"content":"Hi"`)
+	assert.Contains(t, w.Body.String(), "data: [DONE]")
+}
+
+func TestFallback_PrimaryServes(t *testing.T) {
+	var fallbackCalls atomic.Int32
+	adapter := newFallbackTestAdapter(t,
+		func(w http.ResponseWriter, r *http.Request) {
+			var req map[string]any
+			json.NewDecoder(r.Body).Decode(&req)
+			if req["model"] == "bad" {
+				http.Error(w, `{"error":{"message":"bad request"}}`, http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}]}`)
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			fallbackCalls.Add(1)
+		})
+
+	body := `{"model":"gpt-oss-20b","messages":[{"role":"user","content":"Hello"}]}`
+	r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "primary", w.Header().Get(backendHeader))
+
+	// Client errors are the client's to fix, not the target's.
+	body = `{"model":"bad","messages":[{"role":"user","content":"Hello"}]}`
+	r = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	w = httptest.NewRecorder()
+	adapter.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "primary", w.Header().Get(backendHeader))
+
+	assert.Zero(t, fallbackCalls.Load())
+}
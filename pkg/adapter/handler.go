@@ -0,0 +1,115 @@
+// Package adapter is an HTTP proxy that sits in front of an OpenAI-compatible
+// backend serving gpt-oss models. It caches the reasoning the model produces
+// and injects it back into later turns that clients send without it, and
+// translates between the reasoning formats of different backends.
+package adapter
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+	"github.com/aldehir/gpt-oss-adapter/providers/types"
+)
+
+// DefaultCacheSize is the number of entries of the in-memory cache used when
+// Options has no Cache.
+const DefaultCacheSize = 1000
+
+// Options configures the handler returned by NewHandler.
+type Options struct {
+	// Target is the URL of the backend. It is required unless Adapter or
+	// Handler is set.
+	Target string
+
+	// Provider describes the backend. The zero value selects llama.cpp.
+	Provider types.Provider
+
+	// Cache stores reasoning between turns. Nil uses an in-memory cache of
+	// DefaultCacheSize entries.
+	Cache Cache
+
+	// Logger receives the adapter's logs. Nil discards them.
+	Logger *slog.Logger
+
+	// Adapter, if set, is served instead of one built from the fields
+	// above, so that any of its settings can be configured.
+	Adapter *Adapter
+
+	// Handler, if set, is served in place of an adapter, such as a
+	// Reloadable one. The middleware below is still applied.
+	Handler http.Handler
+
+	// Recorder, if set, records every exchange.
+	Recorder *Recorder
+
+	// CompressResponses gzips responses for clients that accept it.
+	CompressResponses bool
+
+	// Tracing starts a server span for every request with the global
+	// OpenTelemetry tracer provider, such as one installed by SetupTracing.
+	Tracing bool
+
+	// AccessLog logs every request to Logger.
+	AccessLog bool
+
+	// AccessLogFile, if set, receives every request in the combined log
+	// format, whether or not AccessLog is set.
+	AccessLogFile *AccessLogFile
+}
+
+// NewHandler returns the adapter and the middleware selected by opts as a
+// single handler, for embedding the adapter in another server.
+func NewHandler(opts Options) (http.Handler, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	var handler http.Handler
+	switch {
+	case opts.Handler != nil:
+		handler = opts.Handler
+	case opts.Adapter != nil:
+		handler = opts.Adapter
+		if opts.Logger == nil {
+			logger = opts.Adapter.logger
+		}
+	default:
+		if opts.Target == "" {
+			return nil, errors.New("adapter: a target is required")
+		}
+
+		provider := opts.Provider
+		if provider.Name == "" {
+			provider = llamacpp.NewProvider()
+		}
+		cache := opts.Cache
+		if cache == nil {
+			cache = NewLRUCache(DefaultCacheSize)
+		}
+		handler = NewAdapter(opts.Target, cache, logger, provider)
+	}
+
+	if opts.Recorder != nil {
+		handler = NewRecordingMiddleware(handler, opts.Recorder, logger)
+	}
+	if opts.CompressResponses {
+		handler = NewCompressionMiddleware(handler)
+	}
+	if opts.Tracing {
+		handler = NewTracingMiddleware(handler)
+	}
+	if opts.AccessLog || opts.AccessLogFile != nil {
+		var accessLogger *slog.Logger
+		if opts.AccessLog {
+			accessLogger = logger
+		}
+		logging := NewLoggingMiddleware(handler, accessLogger)
+		logging.File = opts.AccessLogFile
+		handler = logging
+	}
+	return handler, nil
+}
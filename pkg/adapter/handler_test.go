@@ -0,0 +1,55 @@
+package adapter
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHandler(t *testing.T) {
+	var forwarded map[string]any
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&forwarded)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[{"index":0,"message":{"role":"assistant","reasoning_content":"Look it up.","tool_calls":[{"id":"call_1","type":"function","function":{"name":"lookup","arguments":"{}"}}]}}]}`)
+	}))
+	defer upstream.Close()
+
+	handler, err := NewHandler(Options{Target: upstream.URL, CompressResponses: true})
+	require.NoError(t, err)
+
+	send := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+		return w
+	}
+
+	w := send(`{"messages":[{"role":"user","content":"Hi"}]}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"reasoning":"Look it up."`)
+
+	send(`{"messages":[{"role":"user","content":"Hi"},{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"lookup","arguments":"{}"}}]}]}`)
+	message := forwarded["messages"].([]any)[1].(map[string]any)
+	assert.Equal(t, "Look it up.", message["reasoning_content"])
+}
+
+func TestNewHandler_Adapter(t *testing.T) {
+	a := newTestAdapter()
+	a.AdminToken = "secret"
+
+	handler, err := NewHandler(Options{Adapter: a, AccessLog: true})
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/cache/stats", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	_, err = NewHandler(Options{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,616 @@
+package adapter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const harmonyCompletionsPath = "/v1/completions"
+
+// harmonyRequestFields are the chat completions request fields consumed when
+// rendering the prompt. Everything else, such as sampling parameters, is
+// passed on to the completions endpoint unchanged.
+var harmonyRequestFields = map[string]bool{
+	"messages":              true,
+	"tools":                 true,
+	"tool_choice":           true,
+	"parallel_tool_calls":   true,
+	"response_format":       true,
+	"reasoning_effort":      true,
+	"max_completion_tokens": true,
+}
+
+// chatToHarmonyRequest translates a chat completions request, after
+// reasoning injection, into a raw completions request whose prompt is the
+// conversation rendered in the gpt-oss Harmony format.
+func chatToHarmonyRequest(chat map[string]any, reasoningField string) map[string]any {
+	req := make(map[string]any)
+	for key, value := range chat {
+		if !harmonyRequestFields[key] {
+			req[key] = value
+		}
+	}
+
+	if maxTokens, ok := chat["max_completion_tokens"]; ok {
+		req["max_tokens"] = maxTokens
+	}
+
+	// Stop at the end of the assistant's turn, in case the backend does
+	// not treat these tokens as end of generation.
+	stop := []any{harmonyReturn, harmonyCall}
+	switch s := chat["stop"].(type) {
+	case string:
+		stop = append(stop, s)
+	case []any:
+		stop = append(stop, s...)
+	}
+	req["stop"] = stop
+
+	req["prompt"] = renderHarmonyPrompt(chat, reasoningField, time.Now().Format("2006-01-02"))
+	return req
+}
+
+// renderHarmonyPrompt renders the conversation up to the start of the next
+// assistant message. Reasoning is only rendered for assistant messages with
+// tool calls; the model expects reasoning of earlier final answers to be
+// dropped.
+func renderHarmonyPrompt(chat map[string]any, reasoningField, today string) string {
+	var prompt strings.Builder
+
+	tools, _ := chat["tools"].([]any)
+
+	effort, _ := chat["reasoning_effort"].(string)
+	if effort == "" {
+		effort = "medium"
+	}
+
+	prompt.WriteString(harmonyStart + "system" + harmonyMessage)
+	prompt.WriteString("You are ChatGPT, a large language model trained by OpenAI.\n")
+	prompt.WriteString("Knowledge cutoff: 2024-06\n")
+	prompt.WriteString("Current date: " + today + "\n\n")
+	prompt.WriteString("Reasoning: " + effort + "\n\n")
+	prompt.WriteString("# Valid channels: analysis, commentary, final. Channel must be included for every message.")
+	if len(tools) > 0 {
+		prompt.WriteString("\nCalls to these tools must go to the commentary channel: 'functions'.")
+	}
+	prompt.WriteString(harmonyEnd)
+
+	messages, _ := chat["messages"].([]any)
+
+	var instructions []string
+	for _, m := range messages {
+		message, ok := m.(map[string]any)
+		if !ok {
+			continue
+		}
+		if role, _ := message["role"].(string); role == "system" || role == "developer" {
+			if text, _ := ollamaContent(message["content"]); text != "" {
+				instructions = append(instructions, text)
+			}
+		}
+	}
+
+	var developer strings.Builder
+	if len(instructions) > 0 {
+		developer.WriteString("# Instructions\n\n" + strings.Join(instructions, "\n\n"))
+	}
+	if len(tools) > 0 {
+		if developer.Len() > 0 {
+			developer.WriteString("\n\n")
+		}
+		developer.WriteString("# Tools\n\n## functions\n\n" + harmonyToolNamespace(tools))
+	}
+	if format := harmonyResponseFormat(chat["response_format"]); format != "" {
+		if developer.Len() > 0 {
+			developer.WriteString("\n\n")
+		}
+		developer.WriteString(format)
+	}
+	if developer.Len() > 0 {
+		prompt.WriteString(harmonyStart + "developer" + harmonyMessage + developer.String() + harmonyEnd)
+	}
+
+	toolNames := make(map[string]string)
+	for _, m := range messages {
+		message, ok := m.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		text, _ := ollamaContent(message["content"])
+
+		switch role, _ := message["role"].(string); role {
+		case "user":
+			prompt.WriteString(harmonyStart + "user" + harmonyMessage + text + harmonyEnd)
+
+		case "assistant":
+			toolCalls, _ := message["tool_calls"].([]any)
+
+			if reasoning, ok := message[reasoningField].(string); ok && reasoning != "" && len(toolCalls) > 0 {
+				prompt.WriteString(harmonyStart + "assistant" + harmonyChannel + "analysis" + harmonyMessage + reasoning + harmonyEnd)
+			}
+			if text != "" {
+				channel := "final"
+				if len(toolCalls) > 0 {
+					channel = "commentary"
+				}
+				prompt.WriteString(harmonyStart + "assistant" + harmonyChannel + channel + harmonyMessage + text + harmonyEnd)
+			}
+
+			for _, tc := range toolCalls {
+				toolCall, ok := tc.(map[string]any)
+				if !ok {
+					continue
+				}
+				function, _ := toolCall["function"].(map[string]any)
+				name, _ := function["name"].(string)
+				arguments, _ := function["arguments"].(string)
+				if id, ok := toolCall["id"].(string); ok {
+					toolNames[id] = name
+				}
+
+				prompt.WriteString(harmonyStart + "assistant" + harmonyChannel + "commentary to=functions." + name +
+					" " + harmonyConstrain + "json" + harmonyMessage + arguments + harmonyCall)
+			}
+
+		case "tool":
+			id, _ := message["tool_call_id"].(string)
+			name := toolNames[id]
+			if name == "" {
+				name, _ = message["name"].(string)
+			}
+			prompt.WriteString(harmonyStart + "functions." + name + " to=assistant" + harmonyChannel + "commentary" + harmonyMessage + text + harmonyEnd)
+		}
+	}
+
+	prompt.WriteString(harmonyStart + "assistant")
+	return prompt.String()
+}
+
+// harmonyToolNamespace renders function tools as the TypeScript-like
+// namespace gpt-oss was trained on.
+func harmonyToolNamespace(tools []any) string {
+	var out strings.Builder
+	out.WriteString("namespace functions {\n\n")
+
+	for _, t := range tools {
+		tool, ok := t.(map[string]any)
+		if !ok {
+			continue
+		}
+		function, ok := tool["function"].(map[string]any)
+		if !ok {
+			continue
+		}
+		name, _ := function["name"].(string)
+
+		if description, ok := function["description"].(string); ok && description != "" {
+			writeHarmonyComment(&out, description, "")
+		}
+
+		parameters, _ := function["parameters"].(map[string]any)
+		if properties, ok := parameters["properties"].(map[string]any); ok && len(properties) > 0 {
+			out.WriteString("type " + name + " = (_: " + harmonyObjectType(parameters, "") + ") => any;\n\n")
+		} else {
+			out.WriteString("type " + name + " = () => any;\n\n")
+		}
+	}
+
+	out.WriteString("} // namespace functions")
+	return out.String()
+}
+
+func writeHarmonyComment(out *strings.Builder, text, indent string) {
+	for _, line := range strings.Split(text, "\n") {
+		out.WriteString(indent + "// " + line + "\n")
+	}
+}
+
+// harmonyObjectType renders an object schema's properties as a type literal.
+// Properties are sorted by name, since decoding loses the schema's order.
+func harmonyObjectType(schema map[string]any, indent string) string {
+	properties, _ := schema["properties"].(map[string]any)
+
+	required := make(map[string]bool)
+	if list, ok := schema["required"].([]any); ok {
+		for _, r := range list {
+			if name, ok := r.(string); ok {
+				required[name] = true
+			}
+		}
+	}
+
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var out strings.Builder
+	out.WriteString("{\n")
+	for _, name := range names {
+		property, _ := properties[name].(map[string]any)
+
+		if description, ok := property["description"].(string); ok && description != "" {
+			writeHarmonyComment(&out, description, indent)
+		}
+
+		out.WriteString(indent + name)
+		if !required[name] {
+			out.WriteString("?")
+		}
+		out.WriteString(": " + harmonyType(property, indent) + ",")
+		if def, ok := property["default"]; ok {
+			encoded, _ := json.Marshal(def)
+			out.WriteString(" // default: " + string(encoded))
+		}
+		out.WriteString("\n")
+	}
+	out.WriteString(indent + "}")
+	return out.String()
+}
+
+func harmonyType(schema map[string]any, indent string) string {
+	if enum, ok := schema["enum"].([]any); ok && len(enum) > 0 {
+		values := make([]string, len(enum))
+		for i, value := range enum {
+			encoded, _ := json.Marshal(value)
+			values[i] = string(encoded)
+		}
+		return strings.Join(values, " | ")
+	}
+
+	for _, key := range []string{"anyOf", "oneOf"} {
+		if variants, ok := schema[key].([]any); ok && len(variants) > 0 {
+			types := make([]string, 0, len(variants))
+			for _, v := range variants {
+				variant, _ := v.(map[string]any)
+				types = append(types, harmonyType(variant, indent))
+			}
+			return strings.Join(types, " | ")
+		}
+	}
+
+	switch t := schema["type"].(type) {
+	case []any:
+		types := make([]string, 0, len(t))
+		for _, variant := range t {
+			types = append(types, harmonyType(map[string]any{"type": variant}, indent))
+		}
+		return strings.Join(types, " | ")
+	case string:
+		switch t {
+		case "string", "boolean", "null":
+			return t
+		case "number", "integer":
+			return "number"
+		case "array":
+			items, ok := schema["items"].(map[string]any)
+			if !ok {
+				return "any[]"
+			}
+			return harmonyType(items, indent) + "[]"
+		case "object":
+			if properties, ok := schema["properties"].(map[string]any); ok && len(properties) > 0 {
+				return harmonyObjectType(schema, indent+"  ")
+			}
+			return "object"
+		}
+	}
+	return "any"
+}
+
+// harmonyResponseFormat renders a json_schema response format as the
+// developer message section gpt-oss expects.
+func harmonyResponseFormat(value any) string {
+	format, ok := value.(map[string]any)
+	if !ok || format["type"] != "json_schema" {
+		return ""
+	}
+	jsonSchema, ok := format["json_schema"].(map[string]any)
+	if !ok {
+		return ""
+	}
+
+	name, _ := jsonSchema["name"].(string)
+	if name == "" {
+		name = "response"
+	}
+	schema, err := json.Marshal(jsonSchema["schema"])
+	if err != nil {
+		return ""
+	}
+	return "# Response Formats\n\n## " + name + "\n\n" + string(schema)
+}
+
+// harmonyResponseToChat replaces the body of a raw completions response with
+// its chat completions equivalent, so the rest of the pipeline can treat it
+// like any other backend. Error responses are passed through unchanged.
+func (a *Adapter) harmonyResponseToChat(resp *http.Response) *http.Response {
+	if resp.StatusCode >= 400 {
+		return resp
+	}
+
+	if strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream") {
+		reader, writer := io.Pipe()
+		go a.harmonyStreamToChat(resp.Body, writer)
+		return replaceResponseBody(resp, "text/event-stream", reader)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		a.logger.Error("failed to read completions response", "error", err)
+		return replaceResponseBody(resp, "application/json", io.NopCloser(bytes.NewReader(body)))
+	}
+
+	var completion map[string]any
+	if err := json.Unmarshal(body, &completion); err != nil {
+		a.logger.Error("failed to unmarshal completions response", "error", err)
+		return replaceResponseBody(resp, "application/json", io.NopCloser(bytes.NewReader(body)))
+	}
+
+	encoded, _ := json.Marshal(a.harmonyToChatCompletion(completion))
+	return replaceResponseBody(resp, "application/json", io.NopCloser(bytes.NewReader(encoded)))
+}
+
+// harmonyToChatCompletion translates a blocking completions response into a
+// chat completion.
+func (a *Adapter) harmonyToChatCompletion(completion map[string]any) map[string]any {
+	var choices []any
+	completionChoices, _ := completion["choices"].([]any)
+	for i, c := range completionChoices {
+		choice, ok := c.(map[string]any)
+		if !ok {
+			continue
+		}
+		text, _ := choice["text"].(string)
+
+		var parser harmonyParser
+		events := append(parser.Feed(text), parser.Finish()...)
+
+		var content, reasoning strings.Builder
+		var toolCalls []any
+		for _, event := range events {
+			content.WriteString(event.content)
+			reasoning.WriteString(event.reasoning)
+			if event.toolCall != nil {
+				toolCalls = append(toolCalls, harmonyChatToolCall(event.toolCall))
+			}
+		}
+
+		message := map[string]any{"role": "assistant", "content": content.String()}
+		if reasoning.Len() > 0 {
+			message[a.Provider.Reasoning] = reasoning.String()
+		}
+		if len(toolCalls) > 0 {
+			message["tool_calls"] = toolCalls
+		}
+
+		index := any(i)
+		if n, ok := choice["index"]; ok {
+			index = n
+		}
+		choices = append(choices, map[string]any{
+			"index":         index,
+			"message":       message,
+			"finish_reason": harmonyFinishReason(choice["finish_reason"], len(toolCalls) > 0),
+		})
+	}
+
+	chat := map[string]any{
+		"id":      completion["id"],
+		"object":  "chat.completion",
+		"created": completion["created"],
+		"model":   completion["model"],
+		"choices": choices,
+	}
+	if usage, ok := completion["usage"]; ok {
+		chat["usage"] = usage
+	}
+	return chat
+}
+
+func harmonyChatToolCall(call *harmonyToolCall) map[string]any {
+	return map[string]any{
+		"id":   "call_" + randomHex(12),
+		"type": "function",
+		"function": map[string]any{
+			"name":      call.name,
+			"arguments": call.arguments,
+		},
+	}
+}
+
+func harmonyFinishReason(reason any, hasToolCalls bool) any {
+	if hasToolCalls {
+		return "tool_calls"
+	}
+	return reason
+}
+
+// harmonyStreamChoice tracks the parser and emitted tool calls of one choice
+// in a stream.
+type harmonyStreamChoice struct {
+	parser    harmonyParser
+	started   bool
+	toolCalls int
+	finished  bool
+}
+
+// harmonyStreamToChat converts a raw completions event stream into chat
+// completion chunks.
+func (a *Adapter) harmonyStreamToChat(body io.ReadCloser, writer *io.PipeWriter) {
+	defer body.Close()
+
+	choices := make(map[int]*harmonyStreamChoice)
+	var last map[string]any
+
+	write := func(chunk map[string]any) error {
+		encoded, err := json.Marshal(chunk)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(writer, "data: %s\n\n", encoded)
+		return err
+	}
+
+	reader := newSSEReader(body, a.StreamMaxLineSize)
+	for {
+		event, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			writer.CloseWithError(err)
+			return
+		}
+		if !event.HasData {
+			continue
+		}
+		if event.Data == "[DONE]" {
+			break
+		}
+
+		var completion map[string]any
+		if err := json.Unmarshal([]byte(event.Data), &completion); err != nil {
+			a.logger.Debug("skipping malformed completions stream event", "error", err)
+			continue
+		}
+		last = completion
+
+		if message, ok := completion["error"]; ok {
+			writer.CloseWithError(fmt.Errorf("completions stream error: %v", message))
+			return
+		}
+
+		var chunkChoices []any
+		completionChoices, _ := completion["choices"].([]any)
+		for i, c := range completionChoices {
+			choice, ok := c.(map[string]any)
+			if !ok {
+				continue
+			}
+			index := i
+			if n, ok := choice["index"].(float64); ok {
+				index = int(n)
+			}
+
+			state, ok := choices[index]
+			if !ok {
+				state = &harmonyStreamChoice{}
+				choices[index] = state
+			}
+
+			text, _ := choice["text"].(string)
+			events := state.parser.Feed(text)
+			finishReason := choice["finish_reason"]
+			if finishReason != nil {
+				events = append(events, state.parser.Finish()...)
+				state.finished = true
+			}
+
+			if chunkChoice := a.harmonyChunkChoice(index, state, events, finishReason); chunkChoice != nil {
+				chunkChoices = append(chunkChoices, chunkChoice)
+			}
+		}
+
+		chunk := harmonyChunk(completion, chunkChoices)
+		if usage, ok := completion["usage"]; ok && usage != nil {
+			chunk["usage"] = usage
+		}
+		if len(chunkChoices) == 0 && chunk["usage"] == nil {
+			continue
+		}
+		if err := write(chunk); err != nil {
+			return
+		}
+	}
+
+	// Finish choices the backend did not report a finish reason for.
+	var chunkChoices []any
+	indices := make([]int, 0, len(choices))
+	for index := range choices {
+		indices = append(indices, index)
+	}
+	sort.Ints(indices)
+	for _, index := range indices {
+		state := choices[index]
+		if state.finished {
+			continue
+		}
+		if chunkChoice := a.harmonyChunkChoice(index, state, state.parser.Finish(), "stop"); chunkChoice != nil {
+			chunkChoices = append(chunkChoices, chunkChoice)
+		}
+	}
+	if len(chunkChoices) > 0 && last != nil {
+		if err := write(harmonyChunk(last, chunkChoices)); err != nil {
+			return
+		}
+	}
+
+	fmt.Fprint(writer, "data: [DONE]\n\n")
+	writer.Close()
+}
+
+func harmonyChunk(completion map[string]any, choices []any) map[string]any {
+	return map[string]any{
+		"id":      completion["id"],
+		"object":  "chat.completion.chunk",
+		"created": completion["created"],
+		"model":   completion["model"],
+		"choices": choices,
+	}
+}
+
+// harmonyChunkChoice builds the chat chunk choice for parsed events, or
+// returns nil when there is nothing to send.
+func (a *Adapter) harmonyChunkChoice(index int, state *harmonyStreamChoice, events []harmonyEvent, finishReason any) map[string]any {
+	delta := make(map[string]any)
+	if !state.started {
+		delta["role"] = "assistant"
+	}
+
+	var content, reasoning strings.Builder
+	var toolCalls []any
+	for _, event := range events {
+		content.WriteString(event.content)
+		reasoning.WriteString(event.reasoning)
+		if event.toolCall != nil {
+			toolCall := harmonyChatToolCall(event.toolCall)
+			toolCall["index"] = state.toolCalls
+			state.toolCalls++
+			toolCalls = append(toolCalls, toolCall)
+		}
+	}
+	if content.Len() > 0 {
+		delta["content"] = content.String()
+	}
+	if reasoning.Len() > 0 {
+		delta[a.Provider.Reasoning] = reasoning.String()
+	}
+	if len(toolCalls) > 0 {
+		delta["tool_calls"] = toolCalls
+	}
+
+	if finishReason == nil && (len(delta) == 0 || len(delta) == 1 && !state.started) {
+		return nil
+	}
+	state.started = true
+
+	if finishReason != nil {
+		finishReason = harmonyFinishReason(finishReason, state.toolCalls > 0)
+	}
+	return map[string]any{
+		"index":         index,
+		"delta":         delta,
+		"finish_reason": finishReason,
+	}
+}
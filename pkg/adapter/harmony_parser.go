@@ -0,0 +1,173 @@
+package adapter
+
+import "strings"
+
+// Harmony special tokens, as they appear in raw completions when the backend
+// does not parse them itself.
+const (
+	harmonyStart     = "<|start|>"
+	harmonyChannel   = "<|channel|>"
+	harmonyMessage   = "<|message|>"
+	harmonyConstrain = "<|constrain|>"
+	harmonyEnd       = "<|end|>"
+	harmonyCall      = "<|call|>"
+	harmonyReturn    = "<|return|>"
+
+	// harmonyMaxTokenLen bounds how long the parser holds back a "<|" that
+	// may start a special token split across stream chunks.
+	harmonyMaxTokenLen = 16
+)
+
+// harmonyEvent is a piece of parsed Harmony output: reasoning or content
+// text, or a complete tool call.
+type harmonyEvent struct {
+	reasoning string
+	content   string
+	toolCall  *harmonyToolCall
+}
+
+type harmonyToolCall struct {
+	name      string
+	arguments string
+}
+
+// harmonyParser incrementally splits raw gpt-oss output into its channels.
+// The analysis channel is reasoning, messages addressed to a recipient such
+// as functions.get_weather are tool calls, and everything else, i.e. the
+// final channel and commentary preambles, is content. Text is passed on as
+// soon as it arrives, except for tool call arguments, which are reported once
+// the call is complete.
+type harmonyParser struct {
+	pending   string
+	inBody    bool
+	channel   string
+	recipient string
+	arguments strings.Builder
+
+	// parsed is set once a message header has been seen. Output without
+	// any markup is reported as content when the parser finishes.
+	parsed bool
+}
+
+// Feed parses the next chunk of output.
+func (p *harmonyParser) Feed(text string) []harmonyEvent {
+	p.pending += text
+
+	var events []harmonyEvent
+	for {
+		if !p.inBody {
+			idx := strings.Index(p.pending, harmonyMessage)
+			if idx < 0 {
+				return events
+			}
+			p.readHeader(p.pending[:idx])
+			p.pending = p.pending[idx+len(harmonyMessage):]
+			continue
+		}
+
+		idx := strings.Index(p.pending, "<|")
+		if idx < 0 {
+			// Hold back a trailing "<" that may be the start of a token.
+			text := strings.TrimSuffix(p.pending, "<")
+			events = p.appendBody(events, text)
+			p.pending = p.pending[len(text):]
+			return events
+		}
+
+		events = p.appendBody(events, p.pending[:idx])
+		p.pending = p.pending[idx:]
+
+		end := strings.Index(p.pending, "|>")
+		if end < 0 && len(p.pending) < harmonyMaxTokenLen {
+			return events
+		}
+		if end < 0 || end+2 > harmonyMaxTokenLen || strings.Contains(p.pending[2:end], "<|") {
+			// Not a special token after all.
+			events = p.appendBody(events, p.pending[:2])
+			p.pending = p.pending[2:]
+			continue
+		}
+
+		switch token := p.pending[:end+2]; token {
+		case harmonyEnd, harmonyCall, harmonyReturn:
+			events = p.closeMessage(events)
+			p.pending = p.pending[len(token):]
+		case harmonyStart:
+			// A new message without the previous one being terminated.
+			events = p.closeMessage(events)
+		default:
+			events = p.appendBody(events, token)
+			p.pending = p.pending[len(token):]
+		}
+	}
+}
+
+// Finish reports whatever remains once the output is complete. A message
+// cut off by a stop sequence is treated as if it had been terminated.
+func (p *harmonyParser) Finish() []harmonyEvent {
+	var events []harmonyEvent
+	if p.inBody {
+		events = p.appendBody(events, p.pending)
+		events = p.closeMessage(events)
+	} else if !p.parsed && p.pending != "" {
+		events = append(events, harmonyEvent{content: p.pending})
+	}
+	p.pending = ""
+	return events
+}
+
+// readHeader parses a message header such as
+// "<|start|>assistant<|channel|>commentary to=functions.get_weather <|constrain|>json".
+// The recipient may appear before or after the channel.
+func (p *harmonyParser) readHeader(header string) {
+	p.inBody = true
+	p.parsed = true
+	p.channel = ""
+	p.recipient = ""
+	p.arguments.Reset()
+
+	header = strings.ReplaceAll(header, harmonyStart, " ")
+	header = strings.ReplaceAll(header, harmonyConstrain, " ")
+	header = strings.ReplaceAll(header, harmonyChannel, " "+harmonyChannel+" ")
+
+	fields := strings.Fields(header)
+	for i, field := range fields {
+		switch {
+		case strings.HasPrefix(field, "to="):
+			p.recipient = strings.TrimPrefix(field, "to=")
+		case field == harmonyChannel && i+1 < len(fields):
+			p.channel = fields[i+1]
+		}
+	}
+}
+
+func (p *harmonyParser) appendBody(events []harmonyEvent, text string) []harmonyEvent {
+	if text == "" {
+		return events
+	}
+
+	switch {
+	case p.recipient != "":
+		p.arguments.WriteString(text)
+		return events
+	case p.channel == "analysis":
+		return append(events, harmonyEvent{reasoning: text})
+	default:
+		return append(events, harmonyEvent{content: text})
+	}
+}
+
+func (p *harmonyParser) closeMessage(events []harmonyEvent) []harmonyEvent {
+	if p.recipient != "" {
+		events = append(events, harmonyEvent{toolCall: &harmonyToolCall{
+			name:      strings.TrimPrefix(p.recipient, "functions."),
+			arguments: strings.TrimSpace(p.arguments.String()),
+		}})
+	}
+
+	p.inBody = false
+	p.channel = ""
+	p.recipient = ""
+	p.arguments.Reset()
+	return events
+}
@@ -0,0 +1,302 @@
+package adapter
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/harmony"
+)
+
+func parseHarmony(chunks ...string) (reasoning, content string, toolCalls []harmonyToolCall) {
+	var parser harmonyParser
+	var events []harmonyEvent
+	for _, chunk := range chunks {
+		events = append(events, parser.Feed(chunk)...)
+	}
+	events = append(events, parser.Finish()...)
+
+	for _, event := range events {
+		reasoning += event.reasoning
+		content += event.content
+		if event.toolCall != nil {
+			toolCalls = append(toolCalls, *event.toolCall)
+		}
+	}
+	return reasoning, content, toolCalls
+}
+
+func TestHarmonyParser(t *testing.T) {
+	tests := []struct {
+		name          string
+		output        string
+		wantReasoning string
+		wantContent   string
+		wantToolCalls []harmonyToolCall
+	}{
+		{
+			name:          "final answer",
+			output:        "<|channel|>analysis<|message|>User greets.<|end|><|start|>assistant<|channel|>final<|message|>Hello!<|return|>",
+			wantReasoning: "User greets.",
+			wantContent:   "Hello!",
+		},
+		{
+			name:          "tool call",
+			output:        `<|channel|>analysis<|message|>Need weather.<|end|><|start|>assistant<|channel|>commentary to=functions.get_weather <|constrain|>json<|message|>{"city":"Paris"}<|call|>`,
+			wantReasoning: "Need weather.",
+			wantToolCalls: []harmonyToolCall{{name: "get_weather", arguments: `{"city":"Paris"}`}},
+		},
+		{
+			name:          "recipient before channel",
+			output:        `<|channel|>analysis<|message|>Need weather.<|end|><|start|>assistant to=functions.get_weather<|channel|>commentary json<|message|>{}`,
+			wantReasoning: "Need weather.",
+			wantToolCalls: []harmonyToolCall{{name: "get_weather", arguments: `{}`}},
+		},
+		{
+			name:          "preamble and parallel calls",
+			output:        `<|channel|>commentary<|message|>Checking both.<|end|><|start|>assistant<|channel|>commentary to=functions.a<|message|>{}<|call|><|start|>assistant<|channel|>commentary to=functions.b<|message|>{}<|call|>`,
+			wantContent:   "Checking both.",
+			wantToolCalls: []harmonyToolCall{{name: "a", arguments: "{}"}, {name: "b", arguments: "{}"}},
+		},
+		{
+			name:        "unterminated final stopped by stop sequence",
+			output:      "<|channel|>final<|message|>Done",
+			wantContent: "Done",
+		},
+		{
+			name:        "literal markup in content",
+			output:      "<|channel|>final<|message|>Use a <|pipe|> or <| here<|return|>",
+			wantContent: "Use a <|pipe|> or <| here",
+		},
+		{
+			name:        "no markup",
+			output:      "Plain text",
+			wantContent: "Plain text",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reasoning, content, toolCalls := parseHarmony(tt.output)
+			assert.Equal(t, tt.wantReasoning, reasoning)
+			assert.Equal(t, tt.wantContent, content)
+			assert.Equal(t, tt.wantToolCalls, toolCalls)
+
+			// The result does not depend on how the output is split.
+			chunks := make([]string, 0, len(tt.output))
+			for _, r := range tt.output {
+				chunks = append(chunks, string(r))
+			}
+			reasoning, content, toolCalls = parseHarmony(chunks...)
+			assert.Equal(t, tt.wantReasoning, reasoning)
+			assert.Equal(t, tt.wantContent, content)
+			assert.Equal(t, tt.wantToolCalls, toolCalls)
+		})
+	}
+}
+
+func TestRenderHarmonyPrompt(t *testing.T) {
+	chat := map[string]any{
+		"reasoning_effort": "high",
+		"tools": []any{map[string]any{
+			"type": "function",
+			"function": map[string]any{
+				"name":        "get_weather",
+				"description": "Gets the weather.",
+				"parameters": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"city":  map[string]any{"type": "string", "description": "City name"},
+						"units": map[string]any{"type": "string", "enum": []any{"celsius", "fahrenheit"}, "default": "celsius"},
+					},
+					"required": []any{"city"},
+				},
+			},
+		}},
+		"messages": []any{
+			map[string]any{"role": "system", "content": "Be brief."},
+			map[string]any{"role": "user", "content": "Weather in Paris?"},
+			map[string]any{
+				"role":      "assistant",
+				"reasoning": "Need weather.",
+				"tool_calls": []any{map[string]any{
+					"id":       "call_1",
+					"function": map[string]any{"name": "get_weather", "arguments": `{"city":"Paris"}`},
+				}},
+			},
+			map[string]any{"role": "tool", "tool_call_id": "call_1", "content": "Sunny"},
+			map[string]any{"role": "assistant", "reasoning": "Dropped.", "content": "Sunny."},
+			map[string]any{"role": "user", "content": "Thanks"},
+		},
+	}
+
+	want := "<|start|>system<|message|>You are ChatGPT, a large language model trained by OpenAI.\n" +
+		"Knowledge cutoff: 2024-06\n" +
+		"Current date: 2025-08-05\n\n" +
+		"Reasoning: high\n\n" +
+		"# Valid channels: analysis, commentary, final. Channel must be included for every message.\n" +
+		"Calls to these tools must go to the commentary channel: 'functions'.<|end|>" +
+		"<|start|>developer<|message|># Instructions\n\nBe brief.\n\n" +
+		"# Tools\n\n## functions\n\nnamespace functions {\n\n" +
+		"// Gets the weather.\n" +
+		"type get_weather = (_: {\n" +
+		"// City name\n" +
+		"city: string,\n" +
+		"units?: \"celsius\" | \"fahrenheit\", // default: \"celsius\"\n" +
+		"}) => any;\n\n" +
+		"} // namespace functions<|end|>" +
+		"<|start|>user<|message|>Weather in Paris?<|end|>" +
+		"<|start|>assistant<|channel|>analysis<|message|>Need weather.<|end|>" +
+		"<|start|>assistant<|channel|>commentary to=functions.get_weather <|constrain|>json<|message|>{\"city\":\"Paris\"}<|call|>" +
+		"<|start|>functions.get_weather to=assistant<|channel|>commentary<|message|>Sunny<|end|>" +
+		"<|start|>assistant<|channel|>final<|message|>Sunny.<|end|>" +
+		"<|start|>user<|message|>Thanks<|end|>" +
+		"<|start|>assistant"
+
+	assert.Equal(t, want, renderHarmonyPrompt(chat, "reasoning", "2025-08-05"))
+}
+
+func TestChatToHarmonyRequest(t *testing.T) {
+	req := chatToHarmonyRequest(map[string]any{
+		"model":                 "gpt-oss-20b",
+		"stream":                true,
+		"temperature":           0.5,
+		"max_completion_tokens": 100,
+		"stop":                  "END",
+		"tools":                 []any{},
+		"messages":              []any{map[string]any{"role": "user", "content": "Hi"}},
+	}, "reasoning")
+
+	assert.Equal(t, "gpt-oss-20b", req["model"])
+	assert.Equal(t, true, req["stream"])
+	assert.Equal(t, 0.5, req["temperature"])
+	assert.Equal(t, 100, req["max_tokens"])
+	assert.Equal(t, []any{"<|return|>", "<|call|>", "END"}, req["stop"])
+	assert.NotContains(t, req, "messages")
+	assert.NotContains(t, req, "tools")
+	assert.Contains(t, req["prompt"], "<|start|>user<|message|>Hi<|end|><|start|>assistant")
+}
+
+func newHarmonyTestServer(t *testing.T, handler http.HandlerFunc) *Adapter {
+	upstream := httptest.NewServer(handler)
+	t.Cleanup(upstream.Close)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewAdapter(upstream.URL, NewLRUCache(10), logger, harmony.NewProvider())
+}
+
+func TestHarmony_Blocking(t *testing.T) {
+	adapter := newHarmonyTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/completions", r.URL.Path)
+
+		var req map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Contains(t, req["prompt"], "Reasoning: low")
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":     "cmpl-1",
+			"object": "text_completion",
+			"model":  "gpt-oss-20b",
+			"choices": []any{map[string]any{
+				"index":         0,
+				"text":          `<|channel|>analysis<|message|>Need weather.<|end|><|start|>assistant<|channel|>commentary to=functions.get_weather <|constrain|>json<|message|>{"city":"Paris"}<|call|>`,
+				"finish_reason": "stop",
+			}},
+			"usage": map[string]any{"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15},
+		})
+	})
+
+	body := `{"model":"gpt-oss-20b","reasoning":{"effort":"low"},"messages":[{"role":"user","content":"Weather in Paris?"}]}`
+	r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "chat.completion", resp["object"])
+	assert.Equal(t, float64(15), resp["usage"].(map[string]any)["total_tokens"])
+
+	choice := resp["choices"].([]any)[0].(map[string]any)
+	assert.Equal(t, "tool_calls", choice["finish_reason"])
+	message := choice["message"].(map[string]any)
+	assert.Equal(t, "Need weather.", message["reasoning"])
+
+	toolCall := message["tool_calls"].([]any)[0].(map[string]any)
+	function := toolCall["function"].(map[string]any)
+	assert.Equal(t, "get_weather", function["name"])
+	assert.Equal(t, `{"city":"Paris"}`, function["arguments"])
+
+	item, found := adapter.cache.Get("", toolCall["id"].(string))
+	assert.True(t, found)
+	assert.Equal(t, "Need weather.", item.Content)
+}
+
+func TestHarmony_Streaming(t *testing.T) {
+	pieces := []string{"<|channel|>", "analysis", "<|message|>", "Greet", " back.", "<|end|>", "<|start|>assistant<|channel|>final<|message|>", "Hi", "!"}
+
+	adapter := newHarmonyTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i, piece := range pieces {
+			choice := map[string]any{"index": 0, "text": piece, "finish_reason": nil}
+			if i == len(pieces)-1 {
+				choice["finish_reason"] = "stop"
+			}
+			data, _ := json.Marshal(map[string]any{"id": "cmpl-1", "model": "gpt-oss-20b", "choices": []any{choice}})
+			w.Write([]byte("data: " + string(data) + "\n\n"))
+		}
+		w.Write([]byte("data: [DONE]\n\n"))
+	})
+
+	body := `{"model":"gpt-oss-20b","stream":true,"messages":[{"role":"user","content":"Hello"}]}`
+	r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var reasoning, content strings.Builder
+	var finishReason any
+	done := false
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		if data == "[DONE]" {
+			done = true
+			continue
+		}
+
+		var chunk map[string]any
+		require.NoError(t, json.Unmarshal([]byte(data), &chunk))
+		assert.Equal(t, "chat.completion.chunk", chunk["object"])
+		for _, c := range chunk["choices"].([]any) {
+			choice := c.(map[string]any)
+			delta := choice["delta"].(map[string]any)
+			if text, ok := delta["reasoning"].(string); ok {
+				reasoning.WriteString(text)
+			}
+			if text, ok := delta["content"].(string); ok {
+				content.WriteString(text)
+			}
+			if choice["finish_reason"] != nil {
+				finishReason = choice["finish_reason"]
+			}
+		}
+	}
+
+	assert.True(t, done)
+	assert.Equal(t, "Greet back.", reasoning.String())
+	assert.Equal(t, "Hi!", content.String())
+	assert.Equal(t, "stop", finishReason)
+}
@@ -0,0 +1,72 @@
+package adapter
+
+import (
+	"net/http"
+	"os"
+	"strings"
+)
+
+// HeaderRules rewrites the headers sent to the target, which otherwise gets
+// every header of the client's request.
+type HeaderRules struct {
+	// Strip names client headers to remove, such as Cookie or an internal
+	// auth header. A trailing "*" matches every header with that prefix.
+	Strip []string
+	// Set adds or replaces headers after the provider's own, e.g. the
+	// HTTP-Referer and X-Title headers OpenRouter uses to attribute apps.
+	Set map[string]string
+}
+
+// NewHeaderRules returns rules with the values of set expanded against the
+// environment, so that secrets can be referenced as ${VAR}.
+func NewHeaderRules(strip []string, set map[string]string) *HeaderRules {
+	rules := &HeaderRules{Set: make(map[string]string, len(set))}
+	for _, name := range strip {
+		rules.Strip = append(rules.Strip, http.CanonicalHeaderKey(strings.TrimSpace(name)))
+	}
+	for name, value := range set {
+		rules.Set[http.CanonicalHeaderKey(strings.TrimSpace(name))] = os.ExpandEnv(value)
+	}
+	return rules
+}
+
+// strip removes the client headers matched by the rules.
+func (h *HeaderRules) strip(header http.Header) {
+	if h == nil {
+		return
+	}
+	for _, pattern := range h.Strip {
+		prefix, wildcard := strings.CutSuffix(pattern, "*")
+		if !wildcard {
+			header.Del(pattern)
+			continue
+		}
+		for name := range header {
+			if strings.HasPrefix(http.CanonicalHeaderKey(name), prefix) {
+				delete(header, name)
+			}
+		}
+	}
+}
+
+// set applies the headers the rules add.
+func (h *HeaderRules) set(header http.Header) {
+	if h == nil {
+		return
+	}
+	for name, value := range h.Set {
+		header.Set(name, value)
+	}
+}
+
+// setProviderHeaders adds the provider's API key and headers to a request to
+// the target. The API key replaces the client's credentials, except for an
+// upstream key set by APIKeys, whose client credentials are already gone.
+func (a *Adapter) setProviderHeaders(req *http.Request) {
+	if a.Provider.APIKey != "" && (a.APIKeys == nil || req.Header.Get("Authorization") == "") {
+		req.Header.Set("Authorization", "Bearer "+a.Provider.APIKey)
+	}
+	for name, value := range a.Provider.Headers {
+		req.Header.Set(name, value)
+	}
+}
@@ -0,0 +1,147 @@
+package adapter
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+	"github.com/aldehir/gpt-oss-adapter/providers/openrouter"
+)
+
+func TestHeaderRules(t *testing.T) {
+	t.Setenv("TEST_UPSTREAM_KEY", "sk-upstream")
+	rules := NewHeaderRules([]string{"cookie", "X-Internal-*"}, map[string]string{
+		"authorization": "Bearer ${TEST_UPSTREAM_KEY}",
+		"X-Title":       "Agent",
+	})
+
+	header := http.Header{}
+	header.Set("Cookie", "session=1")
+	header.Set("X-Internal-User", "alice")
+	header.Set("X-Internal-Token", "secret")
+	header.Set("X-Request-Id", "abc")
+	header.Set("Authorization", "Bearer client")
+
+	rules.strip(header)
+	rules.set(header)
+
+	assert.Equal(t, http.Header{
+		"X-Request-Id":  {"abc"},
+		"Authorization": {"Bearer sk-upstream"},
+		"X-Title":       {"Agent"},
+	}, header)
+}
+
+func TestHeaderRules_Upstream(t *testing.T) {
+	var received []http.Header
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Clone())
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"Hi"}}]}`)
+	}))
+	defer backend.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	provider := llamacpp.NewProvider()
+	provider.Headers = map[string]string{"X-Provider": "llama", "X-Title": "Provider"}
+	adapter := NewAdapter(backend.URL, NewLRUCache(10), logger, provider)
+	adapter.HeaderRules = NewHeaderRules([]string{"Cookie"}, map[string]string{"X-Title": "Agent"})
+
+	for _, path := range []string{"/v1/chat/completions", "/v1/models"} {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"messages":[]}`))
+		r.Header.Set("Cookie", "session=1")
+		r.Header.Set("X-Request-Id", "abc")
+		adapter.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	assert.Len(t, received, 2)
+	for _, header := range received {
+		assert.Empty(t, header.Get("Cookie"))
+		assert.Equal(t, "abc", header.Get("X-Request-Id"))
+		assert.Equal(t, "llama", header.Get("X-Provider"))
+		assert.Equal(t, "Agent", header.Get("X-Title"))
+	}
+}
+
+func TestProviderAPIKeyAndBody(t *testing.T) {
+	var header http.Header
+	var body map[string]any
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"Hi"}}]}`)
+	}))
+	defer backend.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	provider := openrouter.NewProvider()
+	provider.APIKey = "sk-or-adapter"
+	provider.Body = map[string]any{
+		"provider": map[string]any{"order": []any{"groq"}, "allow_fallbacks": false},
+	}
+	adapter := NewAdapter(backend.URL, NewLRUCache(10), logger, provider)
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions",
+		strings.NewReader(`{"model":"openai/gpt-oss-120b","provider":{"allow_fallbacks":true},"messages":[]}`))
+	r.Header.Set("Authorization", "Bearer sk-placeholder")
+	adapter.ServeHTTP(httptest.NewRecorder(), r)
+
+	assert.Equal(t, "Bearer sk-or-adapter", header.Get("Authorization"))
+	assert.Equal(t, map[string]any{"order": []any{"groq"}, "allow_fallbacks": true}, body["provider"])
+	assert.Equal(t, map[string]any{"order": []any{"groq"}, "allow_fallbacks": false}, provider.Body["provider"])
+
+	// Upstream keys of API keys take precedence.
+	adapter.APIKeys = &APIKeys{}
+	req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+	req.Header.Set("Authorization", "Bearer sk-per-client")
+	adapter.setProviderHeaders(req)
+	assert.Equal(t, "Bearer sk-per-client", req.Header.Get("Authorization"))
+}
+
+func TestProviderUnsupportedFields(t *testing.T) {
+	var body map[string]any
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"Hi"}}]}`)
+	}))
+	defer backend.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	provider := llamacpp.NewProvider()
+	provider.Unsupported = append(provider.Unsupported, "logit_bias", "stream_options.include_obfuscation")
+	provider.Rename = map[string]string{"max_completion_tokens": "max_tokens"}
+	adapter := NewAdapter(backend.URL, NewLRUCache(10), logger, provider)
+
+	send := func(request string) {
+		w := httptest.NewRecorder()
+		adapter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(request)))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+
+	send(`{"model":"gpt-oss-20b","store":true,"metadata":{"team":"a"},"logit_bias":{"50256":-100},"max_completion_tokens":64,"messages":[]}`)
+	assert.NotContains(t, body, "store")
+	assert.NotContains(t, body, "metadata")
+	assert.NotContains(t, body, "logit_bias")
+	assert.NotContains(t, body, "max_completion_tokens")
+	assert.Equal(t, float64(64), body["max_tokens"])
+	assert.Equal(t, "gpt-oss-20b", body["model"])
+
+	// A field already set under the new name is kept, and nested fields are
+	// dropped without their parents.
+	send(`{"model":"gpt-oss-20b","max_completion_tokens":64,"max_tokens":32,"stream_options":{"include_usage":true,"include_obfuscation":false},"messages":[]}`)
+	assert.Equal(t, float64(32), body["max_tokens"])
+	assert.NotContains(t, body, "max_completion_tokens")
+	assert.Equal(t, map[string]any{"include_usage": true}, body["stream_options"])
+}
@@ -0,0 +1,168 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// healthProbePaths are tried in order when no probe path is configured. A
+// 404 moves on to the next path; any other status is taken as the answer.
+var healthProbePaths = []string{"/health", "/v1/models"}
+
+// HealthChecker periodically probes the target so the adapter can report
+// readiness without forwarding a request.
+type HealthChecker struct {
+	Target   string
+	Path     string
+	Headers  map[string]string
+	Interval time.Duration
+	Timeout  time.Duration
+
+	client  *http.Client
+	mutex   sync.RWMutex
+	checked bool
+	err     error
+}
+
+func NewHealthChecker(target, path string, headers map[string]string, interval, timeout time.Duration) *HealthChecker {
+	return &HealthChecker{
+		Target:   target,
+		Path:     path,
+		Headers:  headers,
+		Interval: interval,
+		Timeout:  timeout,
+		client:   &http.Client{},
+	}
+}
+
+// Run probes the target immediately and then every Interval until ctx is
+// done.
+func (h *HealthChecker) Run(ctx context.Context) {
+	h.update(h.Probe(ctx))
+
+	ticker := time.NewTicker(h.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.update(h.Probe(ctx))
+		}
+	}
+}
+
+// Status returns nil when the most recent probe succeeded, or the reason the
+// target is considered unavailable.
+func (h *HealthChecker) Status() error {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	if !h.checked {
+		return errors.New("backend has not been checked yet")
+	}
+	return h.err
+}
+
+func (h *HealthChecker) update(err error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.checked = true
+	h.err = err
+}
+
+// Probe checks the target once and returns nil if it is healthy.
+func (h *HealthChecker) Probe(ctx context.Context) error {
+	paths := healthProbePaths
+	if h.Path != "" {
+		paths = []string{h.Path}
+	}
+
+	var err error
+	for _, path := range paths {
+		var status int
+		status, err = h.get(ctx, path)
+		if err != nil {
+			return err
+		}
+		if status == http.StatusNotFound && h.Path == "" {
+			err = fmt.Errorf("GET %s returned %d", path, status)
+			continue
+		}
+		if status < 200 || status >= 300 {
+			return fmt.Errorf("GET %s returned %d", path, status)
+		}
+		return nil
+	}
+	return err
+}
+
+func (h *HealthChecker) get(ctx context.Context, path string) (int, error) {
+	targetURL, err := url.Parse(h.Target)
+	if err != nil {
+		return 0, err
+	}
+	targetURL.Path = strings.TrimSuffix(targetURL.Path, "/") + path
+
+	ctx, cancel := context.WithTimeout(ctx, h.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, targetURL.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	for name, value := range h.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	return resp.StatusCode, nil
+}
+
+// handleHealthz reports that the adapter process is up, regardless of the
+// backend, for use as a liveness probe.
+func (a *Adapter) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeHealthStatus(w, http.StatusOK, map[string]any{"status": "ok"})
+}
+
+// handleReadyz reports whether the backend passed its most recent health
+// check, for use as a readiness probe. Without a health checker the adapter
+// is always ready.
+func (a *Adapter) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if a.Health == nil {
+		writeHealthStatus(w, http.StatusOK, map[string]any{"status": "ready"})
+		return
+	}
+
+	if err := a.Health.Status(); err != nil {
+		writeHealthStatus(w, http.StatusServiceUnavailable, map[string]any{
+			"status": "unavailable",
+			"error":  err.Error(),
+		})
+		return
+	}
+
+	writeHealthStatus(w, http.StatusOK, map[string]any{"status": "ready"})
+}
+
+func writeHealthStatus(w http.ResponseWriter, status int, body map[string]any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,71 @@
+package adapter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthChecker_Probe(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		statuses map[string]int
+		healthy  bool
+	}{
+		{"health endpoint ok", "", map[string]int{"/health": 200}, true},
+		{"health endpoint loading", "", map[string]int{"/health": 503, "/v1/models": 200}, false},
+		{"falls back to models", "", map[string]int{"/v1/models": 200}, true},
+		{"nothing found", "", map[string]int{}, false},
+		{"configured path", "/api/tags", map[string]int{"/api/tags": 200}, true},
+		{"configured path only", "/api/tags", map[string]int{"/health": 200}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+				if status, ok := tt.statuses[r.URL.Path]; ok {
+					w.WriteHeader(status)
+					return
+				}
+				http.NotFound(w, r)
+			}))
+			defer server.Close()
+
+			checker := NewHealthChecker(server.URL, tt.path, map[string]string{"Authorization": "Bearer key"}, time.Minute, time.Second)
+			err := checker.Probe(context.Background())
+			if tt.healthy {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestHandleReadyz(t *testing.T) {
+	adapter := newTestAdapter()
+
+	get := func(path string) int {
+		rec := httptest.NewRecorder()
+		adapter.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, get("/readyz"), "ready without a health checker")
+
+	adapter.Health = NewHealthChecker("http://localhost:1", "", nil, time.Minute, time.Second)
+	assert.Equal(t, http.StatusServiceUnavailable, get("/readyz"), "not ready before the first probe")
+	assert.Equal(t, http.StatusOK, get("/healthz"))
+
+	adapter.Health.update(nil)
+	assert.Equal(t, http.StatusOK, get("/readyz"))
+
+	adapter.Health.update(assert.AnError)
+	assert.Equal(t, http.StatusServiceUnavailable, get("/readyz"))
+}
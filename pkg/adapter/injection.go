@@ -0,0 +1,163 @@
+package adapter
+
+import "maps"
+
+// Reasoning injection policies select which assistant messages of a request
+// get their reasoning restored. gpt-oss is trained to see only the reasoning
+// since the last user message, so older reasoning mostly costs prompt tokens.
+const (
+	// ReasoningInjectionAll restores reasoning to every assistant message.
+	ReasoningInjectionAll = "all"
+	// ReasoningInjectionSinceLastUser restores reasoning to the assistant
+	// messages after the last user message.
+	ReasoningInjectionSinceLastUser = "since-last-user"
+	// ReasoningInjectionLatestOnly restores reasoning to the last assistant
+	// message only.
+	ReasoningInjectionLatestOnly = "latest-only"
+)
+
+// reasoningAliases are the fields clients send the reasoning of earlier
+// assistant turns back under, depending on the API they were written for.
+var reasoningAliases = []string{"reasoning", "reasoning_content", "thinking"}
+
+// normalizeReasoningFields moves the reasoning that clients such as Cline and
+// Roo Code echo back on assistant messages under any of reasoningAliases to
+// the provider's reasoning field, and returns how many messages it moved.
+// Reasoning already in the provider's field is left as it is.
+func (a *Adapter) normalizeReasoningFields(requestData map[string]any) int {
+	messages, _ := requestData["messages"].([]any)
+	normalized := 0
+	for _, msg := range messages {
+		message, ok := msg.(map[string]any)
+		if !ok || message["role"] != "assistant" {
+			continue
+		}
+
+		reasoning, _ := message[a.Provider.Reasoning].(string)
+		moved := false
+		for _, field := range reasoningAliases {
+			text, ok := message[field].(string)
+			if !ok || field == a.Provider.Reasoning {
+				continue
+			}
+			delete(message, field)
+			if reasoning == "" && text != "" {
+				reasoning = text
+				moved = true
+			}
+		}
+		if moved {
+			message[a.Provider.Reasoning] = reasoning
+			normalized++
+		}
+	}
+
+	if normalized > 0 {
+		a.logger.Debug("moved reasoning sent by the client to the provider's field", "count", normalized, "field", a.Provider.Reasoning)
+	}
+	return normalized
+}
+
+// injectionStart returns the index of the first message whose reasoning is
+// restored under the ReasoningInjection policy.
+func (a *Adapter) injectionStart(messages []any) int {
+	var role string
+	switch a.ReasoningInjection {
+	case ReasoningInjectionSinceLastUser:
+		role = "user"
+	case ReasoningInjectionLatestOnly:
+		role = "assistant"
+	default:
+		return 0
+	}
+
+	for i := len(messages) - 1; i >= 0; i-- {
+		message, ok := messages[i].(map[string]any)
+		if !ok || message["role"] != role {
+			continue
+		}
+		if role == "user" {
+			return i + 1
+		}
+		return i
+	}
+
+	if role == "user" {
+		return 0
+	}
+	return len(messages)
+}
+
+// snapshotAssistantMessages returns shallow copies of a request's assistant
+// messages, indexed like the messages, so that reasoning injected afterwards
+// can be told apart from reasoning sent by the client.
+func snapshotAssistantMessages(requestData map[string]any) []map[string]any {
+	messages, _ := requestData["messages"].([]any)
+	snapshot := make([]map[string]any, len(messages))
+	for i, msg := range messages {
+		if message, ok := msg.(map[string]any); ok && message["role"] == "assistant" {
+			snapshot[i] = maps.Clone(message)
+		}
+	}
+	return snapshot
+}
+
+// enforceReasoningBudget drops the reasoning injected into a request since
+// snapshot was taken, oldest first, until the estimated tokens of what
+// remains fit in ReasoningTokenBudget. It returns how many messages it
+// dropped reasoning from.
+func (a *Adapter) enforceReasoningBudget(requestData map[string]any, snapshot []map[string]any) int {
+	messages, _ := requestData["messages"].([]any)
+
+	type injection struct {
+		index  int
+		tokens int
+	}
+	var injected []injection
+	total := 0
+	for i, before := range snapshot {
+		if before == nil || i >= len(messages) {
+			continue
+		}
+		message, ok := messages[i].(map[string]any)
+		if !ok {
+			continue
+		}
+		if reasoning, _ := a.extractReasoning(before); reasoning != "" {
+			continue
+		}
+		reasoning, _ := a.extractReasoning(message)
+		if reasoning == "" {
+			continue
+		}
+		tokens := estimateTokens(reasoning)
+		injected = append(injected, injection{index: i, tokens: tokens})
+		total += tokens
+	}
+
+	dropped := 0
+	for _, inj := range injected {
+		if total <= a.ReasoningTokenBudget {
+			break
+		}
+		message := messages[inj.index].(map[string]any)
+		before := snapshot[inj.index]
+		for key := range message {
+			if _, ok := before[key]; !ok {
+				delete(message, key)
+			}
+		}
+		if value, ok := before[a.Provider.Reasoning]; ok {
+			message[a.Provider.Reasoning] = value
+		}
+
+		total -= inj.tokens
+		dropped++
+		a.logger.Info("dropped injected reasoning over token budget", "message_index", inj.index, "tokens", inj.tokens)
+	}
+
+	if dropped > 0 {
+		a.logger.Warn("injected reasoning exceeded token budget", "dropped", dropped, "kept_tokens", total, "budget", a.ReasoningTokenBudget)
+	}
+	return dropped
+}
@@ -0,0 +1,176 @@
+package adapter
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInjectReasoningFromCache_Policy(t *testing.T) {
+	conversation := func() map[string]any {
+		return map[string]any{
+			"messages": []any{
+				map[string]any{"role": "user", "content": "Weather in Paris?"},
+				map[string]any{"role": "assistant", "tool_calls": []any{map[string]any{"id": "call_1"}}},
+				map[string]any{"role": "tool", "tool_call_id": "call_1", "content": "Sunny"},
+				map[string]any{"role": "assistant", "content": "It is sunny.", plainTurnIDField: "rsn_1"},
+				map[string]any{"role": "user", "content": "And in Rome?"},
+				map[string]any{"role": "assistant", "tool_calls": []any{map[string]any{"id": "call_2"}}},
+				map[string]any{"role": "tool", "tool_call_id": "call_2", "content": "Rainy"},
+				map[string]any{"role": "assistant", "tool_calls": []any{map[string]any{"id": "call_3"}}},
+				map[string]any{"role": "tool", "tool_call_id": "call_3", "content": "Still rainy"},
+			},
+		}
+	}
+
+	tests := []struct {
+		policy   string
+		restored []int
+	}{
+		{"", []int{1, 3, 5, 7}},
+		{ReasoningInjectionAll, []int{1, 3, 5, 7}},
+		{ReasoningInjectionSinceLastUser, []int{5, 7}},
+		{ReasoningInjectionLatestOnly, []int{7}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			adapter := newTestAdapter()
+			adapter.PlainTurns = PlainTurnsField
+			adapter.ReasoningInjection = tt.policy
+			for _, id := range []string{"call_1", "rsn_1", "call_2", "call_3"} {
+				adapter.cache.Put("", id, ReasoningItem{ID: id, Content: "Reasoning for " + id})
+			}
+
+			request := conversation()
+			assert.Equal(t, len(tt.restored), adapter.injectReasoningFromCache("", request))
+
+			messages := request["messages"].([]any)
+			for i, msg := range messages {
+				message := msg.(map[string]any)
+				assert.NotContains(t, message, plainTurnIDField, i)
+				if message["role"] != "assistant" {
+					continue
+				}
+				if slices.Contains(tt.restored, i) {
+					assert.Contains(t, message, "reasoning_content", i)
+				} else {
+					assert.NotContains(t, message, "reasoning_content", i)
+				}
+			}
+		})
+	}
+}
+
+func TestInjectionStart_NoMatchingMessage(t *testing.T) {
+	adapter := newTestAdapter()
+	messages := []any{map[string]any{"role": "system", "content": "Be brief."}}
+
+	adapter.ReasoningInjection = ReasoningInjectionSinceLastUser
+	assert.Equal(t, 0, adapter.injectionStart(messages))
+
+	adapter.ReasoningInjection = ReasoningInjectionLatestOnly
+	assert.Equal(t, 1, adapter.injectionStart(messages))
+}
+
+func TestEnforceReasoningBudget(t *testing.T) {
+	adapter := newTestAdapter()
+	adapter.ReasoningTokenBudget = 8
+	adapter.cache.Put("", "call_1", ReasoningItem{ID: "call_1", Content: strings.Repeat("a", 24)})
+	adapter.cache.Put("", "call_2", ReasoningItem{ID: "call_2", Content: strings.Repeat("b", 24)})
+	adapter.cache.Put("", "call_3", ReasoningItem{ID: "call_3", Content: strings.Repeat("c", 16)})
+
+	request := map[string]any{
+		"messages": []any{
+			map[string]any{"role": "user", "content": "Go"},
+			map[string]any{"role": "assistant", "reasoning_content": "Sent by the client.", "content": "Sure."},
+			map[string]any{"role": "assistant", "tool_calls": []any{map[string]any{"id": "call_1"}}},
+			map[string]any{"role": "assistant", "tool_calls": []any{map[string]any{"id": "call_2"}}},
+			map[string]any{"role": "assistant", "tool_calls": []any{map[string]any{"id": "call_3"}}},
+		},
+	}
+
+	snapshot := snapshotAssistantMessages(request)
+	assert.Equal(t, 3, adapter.injectReasoningFromCache("", request))
+	assert.Equal(t, 2, adapter.enforceReasoningBudget(request, snapshot))
+
+	messages := request["messages"].([]any)
+	assert.Equal(t, "Sent by the client.", messages[1].(map[string]any)["reasoning_content"])
+	assert.NotContains(t, messages[2], "reasoning_content")
+	assert.NotContains(t, messages[3], "reasoning_content")
+	assert.Equal(t, strings.Repeat("c", 16), messages[4].(map[string]any)["reasoning_content"])
+}
+
+func TestEnforceReasoningBudget_WithinBudget(t *testing.T) {
+	adapter := newTestAdapter()
+	adapter.ReasoningTokenBudget = 100
+	adapter.cache.Put("", "call_1", ReasoningItem{ID: "call_1", Content: "Look it up."})
+
+	request := map[string]any{
+		"messages": []any{
+			map[string]any{"role": "assistant", "tool_calls": []any{map[string]any{"id": "call_1"}}},
+		},
+	}
+
+	snapshot := snapshotAssistantMessages(request)
+	adapter.injectReasoningFromCache("", request)
+	assert.Equal(t, 0, adapter.enforceReasoningBudget(request, snapshot))
+	assert.Equal(t, "Look it up.", request["messages"].([]any)[0].(map[string]any)["reasoning_content"])
+}
+
+func TestNormalizeReasoningFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		message map[string]any
+		want    map[string]any
+	}{
+		{
+			name:    "reasoning",
+			message: map[string]any{"role": "assistant", "reasoning": "Look it up."},
+			want:    map[string]any{"role": "assistant", "reasoning_content": "Look it up."},
+		},
+		{
+			name:    "thinking",
+			message: map[string]any{"role": "assistant", "thinking": "Look it up."},
+			want:    map[string]any{"role": "assistant", "reasoning_content": "Look it up."},
+		},
+		{
+			name:    "provider field wins",
+			message: map[string]any{"role": "assistant", "reasoning_content": "Mine.", "reasoning": "Other."},
+			want:    map[string]any{"role": "assistant", "reasoning_content": "Mine."},
+		},
+		{
+			name:    "empty alias",
+			message: map[string]any{"role": "assistant", "reasoning": "", "thinking": "Look it up."},
+			want:    map[string]any{"role": "assistant", "reasoning_content": "Look it up."},
+		},
+		{
+			name:    "not an assistant",
+			message: map[string]any{"role": "user", "reasoning": "Look it up."},
+			want:    map[string]any{"role": "user", "reasoning": "Look it up."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adapter := newTestAdapter()
+			adapter.normalizeReasoningFields(map[string]any{"messages": []any{tt.message}})
+			assert.Equal(t, tt.want, tt.message)
+		})
+	}
+}
+
+func TestInjectReasoningFromCache_ClientReasoning(t *testing.T) {
+	adapter := newTestAdapter()
+	adapter.cache.Put("", "call_1", ReasoningItem{ID: "call_1", Content: "Cached."})
+
+	message := map[string]any{"role": "assistant", "reasoning": "Echoed.", "tool_calls": []any{map[string]any{"id": "call_1"}}}
+	request := map[string]any{"messages": []any{message}}
+	adapter.normalizeReasoningFields(request)
+	assert.Zero(t, adapter.injectReasoningFromCache("", request))
+
+	assert.Equal(t, "Echoed.", message["reasoning_content"])
+	assert.NotContains(t, message, "reasoning")
+}
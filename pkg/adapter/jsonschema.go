@@ -0,0 +1,308 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// validateJSONSchema checks a decoded JSON value against a JSON Schema. It
+// covers the keywords used by structured outputs and tool parameters: type,
+// enum, const, the object, array, string and number constraints, the
+// anyOf, oneOf, allOf and not combinators, and local $refs. Keywords it does
+// not know, such as format, are not checked.
+func validateJSONSchema(schema map[string]any, value any) error {
+	v := schemaValidator{root: schema}
+	return v.validate(schema, value, "$", 0)
+}
+
+// schemaMaxDepth bounds the $refs followed, so that a recursive schema with
+// a value that never ends it cannot recurse forever.
+const schemaMaxDepth = 64
+
+type schemaValidator struct {
+	root map[string]any
+}
+
+func (v schemaValidator) validate(schema map[string]any, value any, path string, depth int) error {
+	if depth > schemaMaxDepth {
+		return fmt.Errorf("%s: schema nested too deeply", path)
+	}
+
+	if ref, ok := schema["$ref"].(string); ok {
+		target, err := v.resolve(ref)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if err := v.validate(target, value, path, depth+1); err != nil {
+			return err
+		}
+	}
+
+	if types, ok := schemaTypes(schema["type"]); ok && !slices.ContainsFunc(types, func(t string) bool { return hasJSONType(value, t) }) {
+		return fmt.Errorf("%s: expected %s, got %s", path, strings.Join(types, " or "), jsonType(value))
+	}
+	if enum, ok := schema["enum"].([]any); ok && !slices.ContainsFunc(enum, func(e any) bool { return reflect.DeepEqual(e, value) }) {
+		return fmt.Errorf("%s: %s is not one of the allowed values", path, compactJSON(value))
+	}
+	if constant, ok := schema["const"]; ok && !reflect.DeepEqual(constant, value) {
+		return fmt.Errorf("%s: expected %s, got %s", path, compactJSON(constant), compactJSON(value))
+	}
+
+	switch value := value.(type) {
+	case map[string]any:
+		if err := v.validateObject(schema, value, path, depth); err != nil {
+			return err
+		}
+	case []any:
+		if err := v.validateArray(schema, value, path, depth); err != nil {
+			return err
+		}
+	case string:
+		if err := validateString(schema, value, path); err != nil {
+			return err
+		}
+	case float64:
+		if err := validateNumber(schema, value, path); err != nil {
+			return err
+		}
+	}
+
+	if all, ok := schema["allOf"].([]any); ok {
+		for _, s := range all {
+			if sub, ok := s.(map[string]any); ok {
+				if err := v.validate(sub, value, path, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if anyOf, ok := schema["anyOf"].([]any); ok && v.matches(anyOf, value, path, depth) == 0 {
+		return fmt.Errorf("%s: matches none of the schemas in anyOf", path)
+	}
+	if oneOf, ok := schema["oneOf"].([]any); ok {
+		if n := v.matches(oneOf, value, path, depth); n != 1 {
+			return fmt.Errorf("%s: matches %d of the schemas in oneOf, expected one", path, n)
+		}
+	}
+	if not, ok := schema["not"].(map[string]any); ok && v.validate(not, value, path, depth+1) == nil {
+		return fmt.Errorf("%s: matches the schema in not", path)
+	}
+	return nil
+}
+
+// matches returns how many of the schemas a value matches.
+func (v schemaValidator) matches(schemas []any, value any, path string, depth int) int {
+	n := 0
+	for _, s := range schemas {
+		if sub, ok := s.(map[string]any); ok && v.validate(sub, value, path, depth+1) == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// resolve returns the schema a local reference such as "#/$defs/item"
+// points to.
+func (v schemaValidator) resolve(ref string) (map[string]any, error) {
+	if ref == "#" {
+		return v.root, nil
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("unsupported $ref %q", ref)
+	}
+	var current any = v.root
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		object, ok := current.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unresolvable $ref %q", ref)
+		}
+		current = object[part]
+	}
+	schema, ok := current.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("unresolvable $ref %q", ref)
+	}
+	return schema, nil
+}
+
+func (v schemaValidator) validateObject(schema map[string]any, object map[string]any, path string, depth int) error {
+	if required, ok := schema["required"].([]any); ok {
+		for _, r := range required {
+			if name, ok := r.(string); ok {
+				if _, ok := object[name]; !ok {
+					return fmt.Errorf("%s: missing required property %q", path, name)
+				}
+			}
+		}
+	}
+	if n, ok := schema["minProperties"].(float64); ok && float64(len(object)) < n {
+		return fmt.Errorf("%s: has %d properties, expected at least %v", path, len(object), n)
+	}
+	if n, ok := schema["maxProperties"].(float64); ok && float64(len(object)) > n {
+		return fmt.Errorf("%s: has %d properties, expected at most %v", path, len(object), n)
+	}
+
+	properties, _ := schema["properties"].(map[string]any)
+	for _, name := range slices.Sorted(maps.Keys(object)) {
+		propertyPath := path + "." + name
+		if property, ok := properties[name].(map[string]any); ok {
+			if err := v.validate(property, object[name], propertyPath, depth+1); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, ok := properties[name]; ok {
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				return fmt.Errorf("%s: unexpected property", propertyPath)
+			}
+		case map[string]any:
+			if err := v.validate(additional, object[name], propertyPath, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v schemaValidator) validateArray(schema map[string]any, array []any, path string, depth int) error {
+	if n, ok := schema["minItems"].(float64); ok && float64(len(array)) < n {
+		return fmt.Errorf("%s: has %d items, expected at least %v", path, len(array), n)
+	}
+	if n, ok := schema["maxItems"].(float64); ok && float64(len(array)) > n {
+		return fmt.Errorf("%s: has %d items, expected at most %v", path, len(array), n)
+	}
+	if unique, _ := schema["uniqueItems"].(bool); unique {
+		for i := range array {
+			for j := range i {
+				if reflect.DeepEqual(array[i], array[j]) {
+					return fmt.Errorf("%s: items %d and %d are equal", path, j, i)
+				}
+			}
+		}
+	}
+
+	prefix, _ := schema["prefixItems"].([]any)
+	for i, item := range array {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+		var itemSchema map[string]any
+		if i < len(prefix) {
+			itemSchema, _ = prefix[i].(map[string]any)
+		} else {
+			itemSchema, _ = schema["items"].(map[string]any)
+		}
+		if itemSchema == nil {
+			continue
+		}
+		if err := v.validate(itemSchema, item, itemPath, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateString(schema map[string]any, s string, path string) error {
+	length := utf8.RuneCountInString(s)
+	if n, ok := schema["minLength"].(float64); ok && float64(length) < n {
+		return fmt.Errorf("%s: is %d characters long, expected at least %v", path, length, n)
+	}
+	if n, ok := schema["maxLength"].(float64); ok && float64(length) > n {
+		return fmt.Errorf("%s: is %d characters long, expected at most %v", path, length, n)
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err == nil && !re.MatchString(s) {
+			return fmt.Errorf("%s: does not match pattern %q", path, pattern)
+		}
+	}
+	return nil
+}
+
+func validateNumber(schema map[string]any, n float64, path string) error {
+	if limit, ok := schema["minimum"].(float64); ok && n < limit {
+		return fmt.Errorf("%s: %v is less than the minimum of %v", path, n, limit)
+	}
+	if limit, ok := schema["maximum"].(float64); ok && n > limit {
+		return fmt.Errorf("%s: %v is greater than the maximum of %v", path, n, limit)
+	}
+	if limit, ok := schema["exclusiveMinimum"].(float64); ok && n <= limit {
+		return fmt.Errorf("%s: %v is not greater than %v", path, n, limit)
+	}
+	if limit, ok := schema["exclusiveMaximum"].(float64); ok && n >= limit {
+		return fmt.Errorf("%s: %v is not less than %v", path, n, limit)
+	}
+	if m, ok := schema["multipleOf"].(float64); ok && m > 0 {
+		if q := n / m; math.Abs(q-math.Round(q)) > 1e-9 {
+			return fmt.Errorf("%s: %v is not a multiple of %v", path, n, m)
+		}
+	}
+	return nil
+}
+
+// schemaTypes returns the types a schema allows, given as a string or an
+// array of strings.
+func schemaTypes(t any) ([]string, bool) {
+	switch t := t.(type) {
+	case string:
+		return []string{t}, true
+	case []any:
+		types := make([]string, 0, len(t))
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types, len(types) > 0
+	}
+	return nil, false
+}
+
+func hasJSONType(value any, t string) bool {
+	switch t {
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	default:
+		return jsonType(value) == t
+	}
+}
+
+func jsonType(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func compactJSON(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
@@ -0,0 +1,57 @@
+package adapter
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateJSONSchema(t *testing.T) {
+	var schema map[string]any
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"type": "object",
+		"properties": {
+			"name": {"type": "string", "minLength": 1, "pattern": "^[A-Z]"},
+			"age": {"type": "integer", "minimum": 0, "exclusiveMaximum": 150},
+			"role": {"enum": ["admin", "user"]},
+			"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2, "uniqueItems": true},
+			"address": {"$ref": "#/$defs/address"},
+			"contact": {"anyOf": [{"type": "string"}, {"type": "null"}]}
+		},
+		"required": ["name", "age"],
+		"additionalProperties": false,
+		"$defs": {
+			"address": {"type": "object", "properties": {"city": {"type": "string"}}, "required": ["city"]}
+		}
+	}`), &schema))
+
+	tests := []struct {
+		value string
+		err   string
+	}{
+		{`{"name":"Ada","age":36,"role":"admin","tags":["a","b"],"address":{"city":"London"},"contact":null}`, ""},
+		{`[]`, "$: expected object, got array"},
+		{`{"name":"Ada"}`, `$: missing required property "age"`},
+		{`{"name":"Ada","age":36.5}`, "$.age: expected integer, got number"},
+		{`{"name":"Ada","age":150}`, "$.age: 150 is not less than 150"},
+		{`{"name":"ada","age":1}`, `$.name: does not match pattern "^[A-Z]"`},
+		{`{"name":"Ada","age":1,"role":"root"}`, `$.role: "root" is not one of the allowed values`},
+		{`{"name":"Ada","age":1,"tags":["a","a"]}`, "$.tags: items 0 and 1 are equal"},
+		{`{"name":"Ada","age":1,"tags":["a",2]}`, "$.tags[1]: expected string, got number"},
+		{`{"name":"Ada","age":1,"address":{}}`, `$.address: missing required property "city"`},
+		{`{"name":"Ada","age":1,"contact":1}`, "$.contact: matches none of the schemas in anyOf"},
+		{`{"name":"Ada","age":1,"email":"ada@example.com"}`, "$.email: unexpected property"},
+	}
+	for _, tt := range tests {
+		var value any
+		require.NoError(t, json.Unmarshal([]byte(tt.value), &value))
+		err := validateJSONSchema(schema, value)
+		if tt.err == "" {
+			assert.NoError(t, err, tt.value)
+		} else {
+			assert.EqualError(t, err, tt.err, tt.value)
+		}
+	}
+}
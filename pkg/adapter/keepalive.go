@@ -0,0 +1,186 @@
+package adapter
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// keepAlivePing is the SSE comment sent while waiting for the target.
+const keepAlivePing = ": ping\n\n"
+
+// startKeepAlive wraps w to send SSE comments every StreamKeepAlive until the
+// response to a streaming request starts, so that clients and proxies with
+// idle timeouts do not drop the connection during a long prompt prefill. The
+// returned stop function must be called before the handler returns.
+func (a *Adapter) startKeepAlive(w http.ResponseWriter, chat *chatRequest) (http.ResponseWriter, func()) {
+	stream, _ := chat.data["stream"].(bool)
+	if a.StreamKeepAlive <= 0 || !stream || chat.ndjson {
+		return w, func() {}
+	}
+
+	kw := &keepAliveWriter{
+		ResponseWriter: w,
+		header:         w.Header().Clone(),
+		done:           make(chan struct{}),
+		pinging:        true,
+	}
+	go kw.run(a.StreamKeepAlive)
+	return kw, kw.stop
+}
+
+// keepAliveWriter pings the client until the handler writes its response
+// body. A ping commits the response as a 200 event stream, so a response the
+// handler writes afterwards in another form, such as an error, is sent as a
+// single data event when the writer is stopped.
+type keepAliveWriter struct {
+	http.ResponseWriter
+	// header collects the handler's headers, since the underlying ones are
+	// written by the ping goroutine.
+	header   http.Header
+	done     chan struct{}
+	stopOnce sync.Once
+
+	mu          sync.Mutex
+	pinging     bool
+	committed   bool
+	wroteHeader bool
+	// event holds a response body written after a ping committed the
+	// response, to be sent as a data event.
+	event *bytes.Buffer
+}
+
+func (kw *keepAliveWriter) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-kw.done:
+			return
+		case <-ticker.C:
+			if !kw.ping() {
+				return
+			}
+		}
+	}
+}
+
+// ping writes a comment to the client, committing the response as an event
+// stream if needed. It returns false once pings have stopped.
+func (kw *keepAliveWriter) ping() bool {
+	kw.mu.Lock()
+	defer kw.mu.Unlock()
+
+	if !kw.pinging {
+		return false
+	}
+	if !kw.committed {
+		header := kw.ResponseWriter.Header()
+		header.Set("Content-Type", "text/event-stream")
+		header.Set("Cache-Control", "no-cache")
+		header.Del("Content-Length")
+		kw.ResponseWriter.WriteHeader(http.StatusOK)
+		kw.committed = true
+	}
+	kw.ResponseWriter.Write([]byte(keepAlivePing))
+	if flusher, ok := kw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return true
+}
+
+func (kw *keepAliveWriter) Header() http.Header {
+	return kw.header
+}
+
+func (kw *keepAliveWriter) WriteHeader(code int) {
+	kw.mu.Lock()
+	defer kw.mu.Unlock()
+	kw.writeHeader(code)
+}
+
+func (kw *keepAliveWriter) writeHeader(code int) {
+	if kw.wroteHeader {
+		return
+	}
+	kw.wroteHeader = true
+
+	mediaType, _, _ := mime.ParseMediaType(kw.header.Get("Content-Type"))
+	isStream := code == http.StatusOK && mediaType == "text/event-stream"
+
+	if kw.committed {
+		if !isStream {
+			kw.pinging = false
+			kw.event = &bytes.Buffer{}
+		}
+		return
+	}
+
+	header := kw.ResponseWriter.Header()
+	for name := range header {
+		header.Del(name)
+	}
+	for name, values := range kw.header {
+		header[name] = values
+	}
+	kw.ResponseWriter.WriteHeader(code)
+	kw.committed = true
+	kw.pinging = isStream
+}
+
+func (kw *keepAliveWriter) Write(b []byte) (int, error) {
+	kw.mu.Lock()
+	defer kw.mu.Unlock()
+
+	kw.writeHeader(http.StatusOK)
+	kw.pinging = false
+	if kw.event != nil {
+		return kw.event.Write(b)
+	}
+	return kw.ResponseWriter.Write(b)
+}
+
+func (kw *keepAliveWriter) Flush() {
+	kw.mu.Lock()
+	defer kw.mu.Unlock()
+
+	if kw.event != nil {
+		return
+	}
+	if flusher, ok := kw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (kw *keepAliveWriter) Unwrap() http.ResponseWriter {
+	return kw.ResponseWriter
+}
+
+// stop ends the pings and sends a response held in event.
+func (kw *keepAliveWriter) stop() {
+	kw.stopOnce.Do(func() {
+		close(kw.done)
+
+		kw.mu.Lock()
+		defer kw.mu.Unlock()
+
+		kw.pinging = false
+		if kw.event == nil || kw.event.Len() == 0 {
+			return
+		}
+
+		var event strings.Builder
+		for _, line := range strings.Split(strings.TrimRight(kw.event.String(), "\n"), "\n") {
+			event.WriteString("data: " + line + "\n")
+		}
+		event.WriteString("\n")
+		kw.ResponseWriter.Write([]byte(event.String()))
+		if flusher, ok := kw.ResponseWriter.(http.Flusher); ok {
+			flusher.Flush()
+		}
+	})
+}
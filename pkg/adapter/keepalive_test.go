@@ -0,0 +1,81 @@
+package adapter
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+)
+
+func newKeepAliveTestServer(t *testing.T, upstream http.HandlerFunc) *httptest.Server {
+	backend := httptest.NewServer(upstream)
+	t.Cleanup(backend.Close)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter(backend.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+	adapter.StreamKeepAlive = 10 * time.Millisecond
+
+	server := httptest.NewServer(adapter)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func postChat(t *testing.T, server *httptest.Server, body string) (*http.Response, string) {
+	resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(data)
+}
+
+func TestStreamKeepAlive(t *testing.T) {
+	server := newKeepAliveTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(60 * time.Millisecond)
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hi\"}}]}\n\ndata: [DONE]\n\n")
+	})
+
+	resp, body := postChat(t, server, `{"messages":[],"stream":true}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	assert.True(t, strings.HasPrefix(body, keepAlivePing), body)
+	assert.Contains(t, body, `"content":"hi"`)
+	assert.True(t, strings.HasSuffix(body, "data: [DONE]\n\n"), body)
+}
+
+func TestStreamKeepAlive_UpstreamError(t *testing.T) {
+	server := newKeepAliveTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(60 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		io.WriteString(w, `{"error":{"message":"loading model","code":503}}`)
+	})
+
+	resp, body := postChat(t, server, `{"messages":[],"stream":true}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, strings.HasPrefix(body, keepAlivePing), body)
+	assert.True(t, strings.HasSuffix(body, "data: {\"error\":{\"code\":503,\"message\":\"loading model\"}}\n\n"), body)
+}
+
+func TestStreamKeepAlive_NotStreaming(t *testing.T) {
+	server := newKeepAliveTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(60 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[]}`)
+	})
+
+	resp, body := postChat(t, server, `{"messages":[]}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	assert.NotContains(t, body, "ping")
+}
@@ -0,0 +1,58 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+// listenFDsStart is the first file descriptor systemd passes to a socket
+// activated process.
+const listenFDsStart = 3
+
+// ActivationListener returns the first socket passed by systemd socket
+// activation, or nil if the process was not started that way. The
+// activation variables are removed from the environment, so that they are
+// not passed on to child processes.
+func ActivationListener() (net.Listener, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	fds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || fds < 1 {
+		return nil, nil
+	}
+	return fileListener(listenFDsStart)
+}
+
+// fileListener returns a listener for the socket with file descriptor fd.
+func fileListener(fd uintptr) (net.Listener, error) {
+	file := os.NewFile(fd, "LISTEN_FD_"+strconv.Itoa(int(fd)))
+	defer file.Close()
+
+	listener, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("socket activation: %w", err)
+	}
+	return listener, nil
+}
+
+// Listen opens a TCP listener on addr. With reusePort the socket is bound
+// with SO_REUSEPORT, so that a new instance can start listening on the same
+// address while the one it replaces finishes the requests in flight.
+func Listen(addr string, reusePort bool) (net.Listener, error) {
+	var config net.ListenConfig
+	if reusePort {
+		config.Control = setReusePort
+	}
+	return config.Listen(context.Background(), "tcp", addr)
+}
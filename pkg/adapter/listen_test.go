@@ -0,0 +1,45 @@
+//go:build unix
+
+package adapter
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActivationListener(t *testing.T) {
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	t.Setenv("LISTEN_FDS", "1")
+	listener, err := ActivationListener()
+	require.NoError(t, err)
+	assert.Nil(t, listener, "variables meant for another process")
+	_, ok := os.LookupEnv("LISTEN_FDS")
+	assert.False(t, ok)
+
+	listener, err = ActivationListener()
+	require.NoError(t, err)
+	assert.Nil(t, listener)
+}
+
+func TestFileListener(t *testing.T) {
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer tcp.Close()
+	file, err := tcp.(*net.TCPListener).File()
+	require.NoError(t, err)
+	// fileListener takes ownership of the descriptor it is given.
+	fd, err := syscall.Dup(int(file.Fd()))
+	file.Close()
+	require.NoError(t, err)
+
+	listener, err := fileListener(uintptr(fd))
+	require.NoError(t, err)
+	defer listener.Close()
+	assert.Equal(t, tcp.Addr().String(), listener.Addr().String())
+}
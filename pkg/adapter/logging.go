@@ -0,0 +1,165 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Log output formats.
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// RouteLevel sets the minimum log level for requests whose path matches
+// Pattern. A pattern ending in "/" matches every path below it, as with
+// http.ServeMux.
+type RouteLevel struct {
+	Pattern string
+	Level   slog.Level
+}
+
+func (rl RouteLevel) matches(path string) bool {
+	if strings.HasSuffix(rl.Pattern, "/") {
+		return strings.HasPrefix(path, rl.Pattern)
+	}
+	return path == rl.Pattern
+}
+
+// ParseRouteLevels parses --log-route-level values, mapping a path pattern
+// to a level name such as "debug" or "warn".
+func ParseRouteLevels(values map[string]string) ([]RouteLevel, error) {
+	levels := make([]RouteLevel, 0, len(values))
+	for pattern, name := range values {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(name)); err != nil {
+			return nil, fmt.Errorf("route %s: invalid log level %q", pattern, name)
+		}
+		levels = append(levels, RouteLevel{Pattern: pattern, Level: level})
+	}
+	return levels, nil
+}
+
+// LogOptions configures NewLogHandler.
+type LogOptions struct {
+	Format string
+	Level  slog.Level
+
+	// RouteLevels override Level for requests to matching paths. The most
+	// specific, i.e. longest, matching pattern wins.
+	RouteLevels []RouteLevel
+
+	// SampleRate thins out repeated debug records, which are mostly logged
+	// per stream event. The first record with a given message each second
+	// is logged, then every SampleRate-th. Values below 2 log every record.
+	SampleRate int
+}
+
+// NewLogHandler creates the adapter's log handler. Records logged with a
+// request's context are filtered by the level of the request's route.
+func NewLogHandler(w io.Writer, opts LogOptions) (slog.Handler, error) {
+	minLevel := opts.Level
+	for _, rl := range opts.RouteLevels {
+		minLevel = min(minLevel, rl.Level)
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: minLevel}
+	var handler slog.Handler
+	switch opts.Format {
+	case LogFormatText, "":
+		handler = slog.NewTextHandler(w, handlerOpts)
+	case LogFormatJSON:
+		handler = slog.NewJSONHandler(w, handlerOpts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q", opts.Format)
+	}
+
+	filter := &logFilter{level: opts.Level, routes: opts.RouteLevels}
+	if opts.SampleRate > 1 {
+		filter.sampler = &logSampler{rate: opts.SampleRate, counts: make(map[string]int)}
+	}
+	return &filteringHandler{handler: handler, filter: filter}, nil
+}
+
+type logRouteKey struct{}
+
+// withLogRoute records the request path in ctx so that the log level of its
+// route applies to records logged with ctx.
+func withLogRoute(ctx context.Context, path string) context.Context {
+	return context.WithValue(ctx, logRouteKey{}, path)
+}
+
+// logFilter holds the state shared by a handler and those derived from it
+// with WithAttrs and WithGroup.
+type logFilter struct {
+	level   slog.Level
+	routes  []RouteLevel
+	sampler *logSampler
+}
+
+func (f *logFilter) levelFor(ctx context.Context) slog.Level {
+	path, ok := ctx.Value(logRouteKey{}).(string)
+	if !ok {
+		return f.level
+	}
+
+	level, matched := f.level, -1
+	for _, rl := range f.routes {
+		if len(rl.Pattern) > matched && rl.matches(path) {
+			level, matched = rl.Level, len(rl.Pattern)
+		}
+	}
+	return level
+}
+
+type filteringHandler struct {
+	handler slog.Handler
+	filter  *logFilter
+}
+
+func (h *filteringHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.filter.levelFor(ctx) && h.handler.Enabled(ctx, level)
+}
+
+func (h *filteringHandler) Handle(ctx context.Context, record slog.Record) error {
+	if record.Level <= slog.LevelDebug && h.filter.sampler != nil && !h.filter.sampler.allow(record.Message, record.Time) {
+		return nil
+	}
+	return h.handler.Handle(ctx, record)
+}
+
+func (h *filteringHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &filteringHandler{handler: h.handler.WithAttrs(attrs), filter: h.filter}
+}
+
+func (h *filteringHandler) WithGroup(name string) slog.Handler {
+	return &filteringHandler{handler: h.handler.WithGroup(name), filter: h.filter}
+}
+
+// logSampler counts records by message within one-second windows.
+type logSampler struct {
+	rate int
+
+	mu     sync.Mutex
+	window time.Time
+	counts map[string]int
+}
+
+func (s *logSampler) allow(message string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if window := now.Truncate(time.Second); !window.Equal(s.window) {
+		s.window = window
+		clear(s.counts)
+	}
+
+	n := s.counts[message]
+	s.counts[message] = n + 1
+	return n%s.rate == 0
+}
@@ -0,0 +1,96 @@
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLogHandler_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	handler, err := NewLogHandler(&buf, LogOptions{Format: LogFormatJSON, Level: slog.LevelInfo})
+	require.NoError(t, err)
+
+	slog.New(handler).Info("HTTP request", "status", 200)
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "HTTP request", record["msg"])
+	assert.Equal(t, "INFO", record["level"])
+	assert.Equal(t, float64(200), record["status"])
+
+	_, err = NewLogHandler(&buf, LogOptions{Format: "xml"})
+	assert.Error(t, err)
+}
+
+func TestNewLogHandler_RouteLevels(t *testing.T) {
+	levels, err := ParseRouteLevels(map[string]string{"/healthz": "warn", "/v1/": "debug", "/v1/models": "error"})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	handler, err := NewLogHandler(&buf, LogOptions{Level: slog.LevelInfo, RouteLevels: levels})
+	require.NoError(t, err)
+	logger := slog.New(handler)
+
+	tests := []struct {
+		path  string
+		level slog.Level
+		want  bool
+	}{
+		{"", slog.LevelInfo, true},
+		{"", slog.LevelDebug, false},
+		{"/healthz", slog.LevelInfo, false},
+		{"/healthz", slog.LevelWarn, true},
+		{"/v1/chat/completions", slog.LevelDebug, true},
+		{"/v1/models", slog.LevelWarn, false},
+		{"/v1/models", slog.LevelError, true},
+		{"/v1", slog.LevelDebug, false},
+	}
+
+	for _, tt := range tests {
+		buf.Reset()
+		ctx := context.Background()
+		if tt.path != "" {
+			ctx = withLogRoute(ctx, tt.path)
+		}
+		logger.Log(ctx, tt.level, "message")
+		assert.Equal(t, tt.want, buf.Len() > 0, "%s at %s", tt.path, tt.level)
+	}
+
+	_, err = ParseRouteLevels(map[string]string{"/healthz": "quiet"})
+	assert.Error(t, err)
+}
+
+func TestNewLogHandler_Sampling(t *testing.T) {
+	var buf bytes.Buffer
+	handler, err := NewLogHandler(&buf, LogOptions{Level: slog.LevelDebug, SampleRate: 3})
+	require.NoError(t, err)
+
+	now := time.Now().Truncate(time.Second)
+	for i := range 7 {
+		record := slog.NewRecord(now.Add(time.Duration(i)*time.Millisecond), slog.LevelDebug, "relaying stream event", 0)
+		record.AddAttrs(slog.Int("i", i))
+		require.NoError(t, handler.Handle(context.Background(), record))
+	}
+	require.NoError(t, handler.Handle(context.Background(), slog.NewRecord(now, slog.LevelDebug, "other message", 0)))
+	require.NoError(t, handler.Handle(context.Background(), slog.NewRecord(now, slog.LevelInfo, "relaying stream event", 0)))
+
+	// The first record is logged, then every third, and the count restarts
+	// each second.
+	require.NoError(t, handler.Handle(context.Background(), slog.NewRecord(now.Add(time.Second), slog.LevelDebug, "relaying stream event", 0)))
+
+	out := buf.String()
+	assert.Contains(t, out, "i=0")
+	assert.Contains(t, out, "i=3")
+	assert.Contains(t, out, "i=6")
+	assert.NotContains(t, out, "i=1")
+	assert.Contains(t, out, "other message")
+	assert.Equal(t, 6, strings.Count(out, "\n"))
+}
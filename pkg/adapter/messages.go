@@ -0,0 +1,898 @@
+package adapter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// handleMessages serves the Anthropic Messages API on top of a backend that
+// only speaks chat completions, so that Anthropic SDK clients can drive
+// gpt-oss models. Reasoning is returned as thinking blocks when the client
+// enables extended thinking.
+func (a *Adapter) handleMessages(w http.ResponseWriter, r *http.Request) {
+	a.logger.InfoContext(r.Context(), "handling messages request", "method", r.Method, "path", r.URL.Path)
+
+	a.inflight.Add(1)
+	defer a.inflight.Add(-1)
+
+	_, parseSpan := tracer().Start(r.Context(), "parse request")
+	requestBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		endSpan(parseSpan, err)
+		a.logger.ErrorContext(r.Context(), "failed to read request body", "error", err)
+		writeRequestBodyError(w, r, err)
+		return
+	}
+
+	var requestData map[string]any
+	err = json.Unmarshal(requestBody, &requestData)
+	endSpan(parseSpan, err)
+	if err != nil {
+		a.logger.ErrorContext(r.Context(), "failed to unmarshal request", "error", err)
+		writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error", "Request body is not valid JSON")
+		return
+	}
+	if requestData == nil {
+		writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error", "Request body must be a JSON object")
+		return
+	}
+
+	scope, err := a.requestCacheScope(r)
+	if err != nil {
+		writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	chatData, err := a.messagesToChat(scope, requestData)
+	if err != nil {
+		a.logger.WarnContext(r.Context(), "failed to translate messages request", "error", err)
+		writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	release, ok := a.acquireModelSlot(w, r, chatData)
+	if !ok {
+		return
+	}
+	defer release()
+
+	releaseStream, ok := a.acquireStreamSlot(w, r, chatData)
+	if !ok {
+		return
+	}
+	defer releaseStream()
+
+	releaseQueue, ok := a.acquireQueueSlot(w, r)
+	if !ok {
+		return
+	}
+	defer releaseQueue()
+
+	// Anthropic clients authenticate with x-api-key; pass it on in the form
+	// OpenAI-compatible backends expect.
+	if key := r.Header.Get("X-Api-Key"); key != "" && r.Header.Get("Authorization") == "" {
+		r.Header.Set("Authorization", "Bearer "+key)
+	}
+
+	chat := &chatRequest{ctx: r.Context(), data: chatData, cacheScope: scope}
+	path := strings.TrimSuffix(r.URL.Path, "/messages") + "/chat/completions"
+
+	w, stopKeepAlive := a.startKeepAlive(w, chat)
+	defer stopKeepAlive()
+
+	resp, upstream, ok := a.forwardWithToolRetries(w, r, chat, path)
+	if !ok {
+		return
+	}
+	defer resp.Body.Close()
+
+	contentType := resp.Header.Get("Content-Type")
+	a.logger.DebugContext(r.Context(), "received response", "status", resp.StatusCode, "content-type", contentType)
+
+	if resp.StatusCode >= 400 {
+		a.logger.WarnContext(r.Context(), "target returned error for messages request", "status", resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		writeAnthropicError(w, resp.StatusCode, anthropicErrorType(resp.StatusCode), upstreamErrorMessage(body))
+		return
+	}
+
+	thinking := messagesThinkingEnabled(requestData)
+	if strings.Contains(contentType, "text/event-stream") {
+		_, span := tracer().Start(r.Context(), "stream relay")
+		upstream.handleMessagesStreaming(w, resp, chat, requestData, thinking)
+		span.End()
+	} else {
+		upstream.handleMessagesBlocking(w, resp, chat, requestData, thinking)
+	}
+}
+
+// handleCountTokens serves /v1/messages/count_tokens with the same estimate
+// used for synthetic usage.
+func (a *Adapter) handleCountTokens(w http.ResponseWriter, r *http.Request) {
+	var requestData map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeRequestTooLarge(w, r, tooLarge.Limit)
+			return
+		}
+		writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error", "Request body is not valid JSON")
+		return
+	}
+
+	scope, err := a.requestCacheScope(r)
+	if err != nil {
+		writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	chatData, err := a.messagesToChat(scope, requestData)
+	if err != nil {
+		writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	var tokens int
+	if a.Usage != nil {
+		tokens = a.Usage.Count(r.Context(), promptText(chatData))
+	} else {
+		tokens = estimateTokens(promptText(chatData))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"input_tokens": tokens})
+}
+
+func (a *Adapter) handleMessagesBlocking(w http.ResponseWriter, resp *http.Response, chat *chatRequest, requestData map[string]any, thinking bool) {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		a.logger.Error("failed to read response body", "error", err)
+		writeAnthropicError(w, http.StatusBadGateway, "api_error", "Failed to read response body")
+		return
+	}
+
+	var responseData map[string]any
+	if err := json.Unmarshal(body, &responseData); err != nil {
+		a.logger.Error("failed to unmarshal response", "error", err)
+		writeAnthropicError(w, http.StatusBadGateway, "api_error", "Failed to unmarshal response")
+		return
+	}
+
+	if !a.processChatResponse(w, resp, chat, responseData) {
+		return
+	}
+
+	message := a.chatToMessage(chat.cacheScope, responseData, requestData, thinking)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.StatusCode)
+	json.NewEncoder(w).Encode(message)
+}
+
+func (a *Adapter) handleMessagesStreaming(w http.ResponseWriter, resp *http.Response, chat *chatRequest, requestData map[string]any, thinking bool) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(resp.StatusCode)
+
+	w, stopWriter := a.startStreamWriter(w, resp, chat)
+	defer stopWriter()
+
+	flush := func() {}
+	if flusher, ok := w.(http.Flusher); ok {
+		flush = flusher.Flush
+	}
+
+	stream := &messagesStream{
+		adapter:  a,
+		scope:    chat.cacheScope,
+		w:        w,
+		flush:    flush,
+		thinking: thinking,
+		message:  newMessageObject(requestData),
+		tools:    make(map[int]int),
+	}
+
+	stream.start()
+	a.relayChatStream(resp, chat, stream.handleLine)
+	stream.finish()
+}
+
+// messagesToChat translates an Anthropic Messages request into a chat
+// completions request.
+func (a *Adapter) messagesToChat(scope cacheScope, req map[string]any) (map[string]any, error) {
+	chat := make(map[string]any)
+
+	for _, key := range []string{"model", "max_tokens", "stream", "temperature", "top_p"} {
+		if value, ok := req[key]; ok {
+			chat[key] = value
+		}
+	}
+
+	if stop, ok := req["stop_sequences"]; ok {
+		chat["stop"] = stop
+	}
+
+	if metadata, ok := req["metadata"].(map[string]any); ok {
+		if user, ok := metadata["user_id"]; ok {
+			chat["user"] = user
+		}
+	}
+
+	if stream, _ := req["stream"].(bool); stream {
+		chat["stream_options"] = map[string]any{"include_usage": true}
+	}
+
+	if effort := messagesThinkingEffort(req["thinking"]); effort != "" {
+		chat["reasoning"] = map[string]any{"effort": effort}
+	}
+
+	if tools, ok := req["tools"].([]any); ok {
+		var chatTools []any
+		for _, t := range tools {
+			tool, ok := t.(map[string]any)
+			if !ok {
+				continue
+			}
+			schema, ok := tool["input_schema"]
+			if !ok {
+				a.logger.Debug("dropping unsupported messages tool", "type", tool["type"])
+				continue
+			}
+			function := map[string]any{
+				"name":       tool["name"],
+				"parameters": schema,
+			}
+			if description, ok := tool["description"]; ok {
+				function["description"] = description
+			}
+			chatTools = append(chatTools, map[string]any{"type": "function", "function": function})
+		}
+		if len(chatTools) > 0 {
+			chat["tools"] = chatTools
+		}
+	}
+
+	if choice, ok := req["tool_choice"].(map[string]any); ok {
+		switch choice["type"] {
+		case "auto":
+			chat["tool_choice"] = "auto"
+		case "any":
+			chat["tool_choice"] = "required"
+		case "none":
+			chat["tool_choice"] = "none"
+		case "tool":
+			chat["tool_choice"] = map[string]any{"type": "function", "function": map[string]any{"name": choice["name"]}}
+		}
+		if disable, _ := choice["disable_parallel_tool_use"].(bool); disable {
+			chat["parallel_tool_calls"] = false
+		}
+	}
+
+	var messages []any
+	switch system := req["system"].(type) {
+	case string:
+		if system != "" {
+			messages = append(messages, map[string]any{"role": "system", "content": system})
+		}
+	case []any:
+		if text := messagesBlocksText(system); text != "" {
+			messages = append(messages, map[string]any{"role": "system", "content": text})
+		}
+	}
+
+	input, ok := req["messages"].([]any)
+	if !ok || len(input) == 0 {
+		return nil, fmt.Errorf("messages is required")
+	}
+
+	for i, m := range input {
+		message, ok := m.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("messages[%d] must be an object", i)
+		}
+
+		role, _ := message["role"].(string)
+		switch role {
+		case "user":
+			messages = append(messages, messagesUserToChat(message["content"])...)
+		case "assistant":
+			messages = append(messages, a.messagesAssistantToChat(scope, message["content"]))
+		default:
+			return nil, fmt.Errorf("messages[%d]: unsupported role %q", i, role)
+		}
+	}
+	chat["messages"] = messages
+
+	return chat, nil
+}
+
+// messagesUserToChat converts a user turn into chat messages. Tool results
+// become separate tool messages, which must directly follow the assistant
+// message that called them, so they are placed before any remaining content.
+func messagesUserToChat(content any) []any {
+	blocks, ok := content.([]any)
+	if !ok {
+		return []any{map[string]any{"role": "user", "content": content}}
+	}
+
+	var messages []any
+	var parts []any
+	textOnly := true
+
+	for _, b := range blocks {
+		block, ok := b.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		switch block["type"] {
+		case "text":
+			parts = append(parts, map[string]any{"type": "text", "text": block["text"]})
+		case "image":
+			if url := messagesImageURL(block); url != "" {
+				textOnly = false
+				parts = append(parts, map[string]any{"type": "image_url", "image_url": map[string]any{"url": url}})
+			}
+		case "tool_result":
+			var output string
+			switch c := block["content"].(type) {
+			case string:
+				output = c
+			case []any:
+				output = messagesBlocksText(c)
+			}
+			if isError, _ := block["is_error"].(bool); isError {
+				output = "Error: " + output
+			}
+			messages = append(messages, map[string]any{
+				"role":         "tool",
+				"tool_call_id": block["tool_use_id"],
+				"content":      output,
+			})
+		}
+	}
+
+	if len(parts) == 0 {
+		return messages
+	}
+
+	if textOnly {
+		var texts []string
+		for _, p := range parts {
+			text, _ := p.(map[string]any)["text"].(string)
+			texts = append(texts, text)
+		}
+		return append(messages, map[string]any{"role": "user", "content": strings.Join(texts, "\n")})
+	}
+	return append(messages, map[string]any{"role": "user", "content": parts})
+}
+
+// messagesAssistantToChat converts an assistant turn into a chat message.
+// Thinking blocks become the provider's reasoning field; when a client
+// echoes a thinking block without its text, or a redacted_thinking block, the
+// reasoning is restored from the cache by signature.
+func (a *Adapter) messagesAssistantToChat(scope cacheScope, content any) map[string]any {
+	message := map[string]any{"role": "assistant"}
+
+	blocks, ok := content.([]any)
+	if !ok {
+		message["content"] = content
+		return message
+	}
+
+	var texts, reasoning []string
+	var toolCalls []any
+
+	for _, b := range blocks {
+		block, ok := b.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		switch block["type"] {
+		case "text":
+			if text, ok := block["text"].(string); ok {
+				texts = append(texts, text)
+			}
+		case "thinking":
+			text, _ := block["thinking"].(string)
+			if text == "" {
+				if signature, ok := block["signature"].(string); ok && !scope.skipLookup {
+					if cached, found := a.cache.Get(scope.namespace, signature); found {
+						text = cached.Content
+					}
+				}
+			}
+			if text != "" {
+				reasoning = append(reasoning, text)
+			}
+		case "redacted_thinking":
+			if data, ok := block["data"].(string); ok && !scope.skipLookup {
+				if cached, found := a.cache.Get(scope.namespace, data); found {
+					reasoning = append(reasoning, cached.Content)
+				}
+			}
+		case "tool_use":
+			arguments, _ := json.Marshal(block["input"])
+			toolCalls = append(toolCalls, withSealedReasoning(map[string]any{
+				"id":   block["id"],
+				"type": "function",
+				"function": map[string]any{
+					"name":      block["name"],
+					"arguments": string(arguments),
+				},
+			}, block))
+		}
+	}
+
+	message["content"] = strings.Join(texts, "")
+	if len(reasoning) > 0 {
+		message[a.Provider.Reasoning] = strings.Join(reasoning, "\n")
+	}
+	if len(toolCalls) > 0 {
+		message["tool_calls"] = toolCalls
+	}
+	return message
+}
+
+func messagesImageURL(block map[string]any) string {
+	source, _ := block["source"].(map[string]any)
+	switch source["type"] {
+	case "base64":
+		return fmt.Sprintf("data:%v;base64,%v", source["media_type"], source["data"])
+	case "url":
+		url, _ := source["url"].(string)
+		return url
+	}
+	return ""
+}
+
+func messagesBlocksText(blocks []any) string {
+	var texts []string
+	for _, b := range blocks {
+		if block, ok := b.(map[string]any); ok && block["type"] == "text" {
+			if text, ok := block["text"].(string); ok {
+				texts = append(texts, text)
+			}
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// messagesThinkingEffort maps an Anthropic thinking budget onto a gpt-oss
+// reasoning effort. gpt-oss always reasons, so disabled thinking maps to low.
+func messagesThinkingEffort(value any) string {
+	thinking, ok := value.(map[string]any)
+	if !ok {
+		return ""
+	}
+
+	switch thinking["type"] {
+	case "disabled":
+		return "low"
+	case "enabled":
+		budget, _ := thinking["budget_tokens"].(float64)
+		switch {
+		case budget < 4096:
+			return "low"
+		case budget < 16384:
+			return "medium"
+		default:
+			return "high"
+		}
+	}
+	return ""
+}
+
+func messagesThinkingEnabled(req map[string]any) bool {
+	thinking, ok := req["thinking"].(map[string]any)
+	return ok && thinking["type"] == "enabled"
+}
+
+func newMessageObject(req map[string]any) map[string]any {
+	return map[string]any{
+		"id":            newResponsesID("msg"),
+		"type":          "message",
+		"role":          "assistant",
+		"model":         req["model"],
+		"content":       []any{},
+		"stop_reason":   nil,
+		"stop_sequence": nil,
+		"usage":         map[string]any{"input_tokens": 0, "output_tokens": 0},
+	}
+}
+
+// chatToMessage translates a (transformed) chat completion into an Anthropic
+// message.
+func (a *Adapter) chatToMessage(scope cacheScope, chatResponse map[string]any, req map[string]any, thinking bool) map[string]any {
+	message := newMessageObject(req)
+	if model, ok := chatResponse["model"]; ok {
+		message["model"] = model
+	}
+
+	content := []any{}
+	finishReason := ""
+
+	if choices, ok := chatResponse["choices"].([]any); ok && len(choices) > 0 {
+		if choice, ok := choices[0].(map[string]any); ok {
+			finishReason, _ = choice["finish_reason"].(string)
+
+			if chatMessage, ok := choice["message"].(map[string]any); ok {
+				if reasoning, ok := chatMessage["reasoning"].(string); ok && reasoning != "" && thinking {
+					if a.StripReasoning {
+						content = append(content, map[string]any{
+							"type": "redacted_thinking",
+							"data": a.thinkingSignature(scope, reasoning),
+						})
+					} else {
+						content = append(content, map[string]any{
+							"type":      "thinking",
+							"thinking":  reasoning,
+							"signature": a.thinkingSignature(scope, reasoning),
+						})
+					}
+				}
+
+				if text, ok := chatMessage["content"].(string); ok && text != "" {
+					content = append(content, map[string]any{"type": "text", "text": text})
+				}
+
+				if toolCalls, ok := chatMessage["tool_calls"].([]any); ok {
+					for _, tc := range toolCalls {
+						toolCall, ok := tc.(map[string]any)
+						if !ok {
+							continue
+						}
+						function, _ := toolCall["function"].(map[string]any)
+						arguments, _ := function["arguments"].(string)
+						content = append(content, withSealedReasoning(map[string]any{
+							"type":  "tool_use",
+							"id":    toolCall["id"],
+							"name":  function["name"],
+							"input": messagesToolInput(arguments),
+						}, toolCall))
+					}
+				}
+			}
+		}
+	}
+
+	message["content"] = content
+	message["stop_reason"] = messagesStopReason(finishReason)
+	message["usage"] = chatUsageToMessages(chatResponse["usage"])
+
+	return message
+}
+
+// thinkingSignature caches reasoning under a new opaque signature, so it can
+// be restored if a client echoes the thinking block without its text. The
+// signature is still made up when the client asked for no storage.
+func (a *Adapter) thinkingSignature(scope cacheScope, reasoning string) string {
+	signature := newResponsesID("sig")
+	if !scope.noStore {
+		a.storeReasoning(scope.namespace, signature, ReasoningItem{ID: signature, Content: reasoning})
+	}
+	return signature
+}
+
+func messagesToolInput(arguments string) any {
+	var input any
+	if err := json.Unmarshal([]byte(arguments), &input); err != nil || input == nil {
+		return map[string]any{}
+	}
+	return input
+}
+
+func messagesStopReason(finishReason string) string {
+	switch finishReason {
+	case "length":
+		return "max_tokens"
+	case "tool_calls", "function_call":
+		return "tool_use"
+	case "content_filter":
+		return "refusal"
+	default:
+		return "end_turn"
+	}
+}
+
+func chatUsageToMessages(u any) map[string]any {
+	usage, _ := u.(map[string]any)
+	result := map[string]any{
+		"input_tokens":  0,
+		"output_tokens": 0,
+	}
+	if prompt, ok := usage["prompt_tokens"]; ok {
+		result["input_tokens"] = prompt
+	}
+	if completion, ok := usage["completion_tokens"]; ok {
+		result["output_tokens"] = completion
+	}
+	if details, ok := usage["prompt_tokens_details"].(map[string]any); ok {
+		if cached, ok := details["cached_tokens"]; ok {
+			result["cache_read_input_tokens"] = cached
+		}
+	}
+	return result
+}
+
+type anthropicError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// writeAnthropicError writes an error in the format returned by the
+// Anthropic API.
+func writeAnthropicError(w http.ResponseWriter, status int, errType, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"type":  "error",
+		"error": anthropicError{Type: errType, Message: message},
+	})
+}
+
+func anthropicErrorType(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "invalid_request_error"
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusForbidden:
+		return "permission_error"
+	case http.StatusNotFound:
+		return "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		return "request_too_large"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case http.StatusServiceUnavailable:
+		return "overloaded_error"
+	default:
+		return "api_error"
+	}
+}
+
+// upstreamErrorMessage extracts the message from an OpenAI-style error body,
+// falling back to the raw body.
+func upstreamErrorMessage(body []byte) string {
+	var parsed struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &parsed); err == nil && parsed.Error.Message != "" {
+		return parsed.Error.Message
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// messagesStream converts transformed chat completion chunks into Anthropic
+// streaming events.
+type messagesStream struct {
+	adapter      *Adapter
+	scope        cacheScope
+	w            io.Writer
+	flush        func()
+	thinking     bool
+	message      map[string]any
+	blocks       int
+	open         string
+	openIndex    int
+	reasoning    strings.Builder
+	tools        map[int]int
+	usage        any
+	finishReason string
+	finished     bool
+}
+
+func (s *messagesStream) emit(eventType string, data map[string]any) {
+	data["type"] = eventType
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		s.adapter.logger.Error("failed to marshal messages event", "type", eventType, "error", err)
+		return
+	}
+
+	fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", eventType, encoded)
+	s.flush()
+}
+
+func (s *messagesStream) start() {
+	s.emit("message_start", map[string]any{"message": s.message})
+}
+
+func (s *messagesStream) handleLine(line string) {
+	if !strings.HasPrefix(line, "data: ") {
+		return
+	}
+
+	data := strings.TrimPrefix(line, "data: ")
+	if data == "[DONE]" {
+		s.finish()
+		return
+	}
+
+	var chunk map[string]any
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return
+	}
+
+	if chatError, ok := chunk["error"].(map[string]any); ok {
+		s.fail(chatError)
+		return
+	}
+
+	if usage, ok := chunk["usage"]; ok && usage != nil {
+		s.usage = usage
+	}
+
+	choices, ok := chunk["choices"].([]any)
+	if !ok || len(choices) == 0 {
+		return
+	}
+
+	choice, ok := choices[0].(map[string]any)
+	if !ok {
+		return
+	}
+
+	if finishReason, ok := choice["finish_reason"].(string); ok {
+		s.finishReason = finishReason
+	}
+
+	delta, ok := choice["delta"].(map[string]any)
+	if !ok {
+		return
+	}
+
+	if reasoning, ok := delta["reasoning"].(string); ok && reasoning != "" && s.thinking {
+		if s.adapter.StripReasoning {
+			s.reasoning.WriteString(reasoning)
+		} else {
+			s.openBlock("thinking", map[string]any{"type": "thinking", "thinking": "", "signature": ""})
+			s.reasoning.WriteString(reasoning)
+			s.blockDelta(map[string]any{"type": "thinking_delta", "thinking": reasoning})
+		}
+	}
+	if content, ok := delta["content"].(string); ok && content != "" {
+		s.openBlock("text", map[string]any{"type": "text", "text": ""})
+		s.blockDelta(map[string]any{"type": "text_delta", "text": content})
+	}
+	if toolCalls, ok := delta["tool_calls"].([]any); ok {
+		for _, tc := range toolCalls {
+			if toolCall, ok := tc.(map[string]any); ok {
+				s.toolCallDelta(toolCall)
+			}
+		}
+	}
+}
+
+// openBlock starts a content block of the given kind unless one is already
+// open, closing any other open block first.
+func (s *messagesStream) openBlock(kind string, block map[string]any) {
+	s.flushRedactedThinking()
+	if s.open == kind {
+		return
+	}
+	s.closeBlock()
+
+	s.open = kind
+	s.openIndex = s.blocks
+	s.blocks++
+	s.emit("content_block_start", map[string]any{
+		"index":         s.openIndex,
+		"content_block": block,
+	})
+}
+
+func (s *messagesStream) blockDelta(delta map[string]any) {
+	s.emit("content_block_delta", map[string]any{
+		"index": s.openIndex,
+		"delta": delta,
+	})
+}
+
+func (s *messagesStream) closeBlock() {
+	if s.open == "" {
+		return
+	}
+
+	if s.open == "thinking" {
+		s.blockDelta(map[string]any{
+			"type":      "signature_delta",
+			"signature": s.adapter.thinkingSignature(s.scope, s.reasoning.String()),
+		})
+		s.reasoning.Reset()
+	}
+
+	s.emit("content_block_stop", map[string]any{"index": s.openIndex})
+	s.open = ""
+}
+
+// flushRedactedThinking emits reasoning withheld from the client under
+// StripReasoning as a complete redacted_thinking block, whose data restores
+// the reasoning when the client sends it back.
+func (s *messagesStream) flushRedactedThinking() {
+	if !s.adapter.StripReasoning || s.reasoning.Len() == 0 {
+		return
+	}
+	s.closeBlock()
+
+	index := s.blocks
+	s.blocks++
+	s.emit("content_block_start", map[string]any{
+		"index": index,
+		"content_block": map[string]any{
+			"type": "redacted_thinking",
+			"data": s.adapter.thinkingSignature(s.scope, s.reasoning.String()),
+		},
+	})
+	s.emit("content_block_stop", map[string]any{"index": index})
+	s.reasoning.Reset()
+}
+
+func (s *messagesStream) toolCallDelta(toolCall map[string]any) {
+	index := 0
+	if i, ok := toolCall["index"].(float64); ok {
+		index = int(i)
+	}
+
+	function, _ := toolCall["function"].(map[string]any)
+
+	if _, exists := s.tools[index]; !exists {
+		name, _ := function["name"].(string)
+		s.openBlock(fmt.Sprintf("tool_use:%d", index), withSealedReasoning(map[string]any{
+			"type":  "tool_use",
+			"id":    toolCall["id"],
+			"name":  name,
+			"input": map[string]any{},
+		}, toolCall))
+		s.tools[index] = s.openIndex
+	}
+
+	if arguments, ok := function["arguments"].(string); ok && arguments != "" {
+		s.emit("content_block_delta", map[string]any{
+			"index": s.tools[index],
+			"delta": map[string]any{"type": "input_json_delta", "partial_json": arguments},
+		})
+	}
+}
+
+// fail reports a chat stream error to the client as an Anthropic error
+// event, which ends the stream.
+func (s *messagesStream) fail(chatError map[string]any) {
+	if s.finished {
+		return
+	}
+	s.finished = true
+
+	s.emit("error", map[string]any{
+		"error": anthropicError{Type: "api_error", Message: fmt.Sprint(chatError["message"])},
+	})
+}
+
+func (s *messagesStream) finish() {
+	if s.finished {
+		return
+	}
+	s.finished = true
+
+	s.flushRedactedThinking()
+	s.closeBlock()
+
+	usage := chatUsageToMessages(s.usage)
+	s.emit("message_delta", map[string]any{
+		"delta": map[string]any{
+			"stop_reason":   messagesStopReason(s.finishReason),
+			"stop_sequence": nil,
+		},
+		"usage": usage,
+	})
+	s.emit("message_stop", map[string]any{})
+}
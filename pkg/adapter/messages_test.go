@@ -0,0 +1,253 @@
+package adapter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessagesToChat(t *testing.T) {
+	adapter := newTestAdapter()
+	adapter.cache.Put("", "sig_cached", ReasoningItem{ID: "sig_cached", Content: "cached thoughts"})
+
+	chat, err := adapter.messagesToChat(cacheScope{}, map[string]any{
+		"model":          "gpt-oss-20b",
+		"max_tokens":     float64(1024),
+		"system":         []any{map[string]any{"type": "text", "text": "Be brief."}},
+		"stop_sequences": []any{"END"},
+		"thinking":       map[string]any{"type": "enabled", "budget_tokens": float64(20000)},
+		"tools": []any{
+			map[string]any{"name": "get_weather", "description": "Weather", "input_schema": map[string]any{"type": "object"}},
+			map[string]any{"type": "web_search_20250305", "name": "web_search"},
+		},
+		"tool_choice": map[string]any{"type": "any", "disable_parallel_tool_use": true},
+		"messages": []any{
+			map[string]any{"role": "user", "content": "Weather in Paris?"},
+			map[string]any{"role": "assistant", "content": []any{
+				map[string]any{"type": "thinking", "thinking": "", "signature": "sig_cached"},
+				map[string]any{"type": "tool_use", "id": "toolu_1", "name": "get_weather", "input": map[string]any{"city": "Paris"}},
+			}},
+			map[string]any{"role": "user", "content": []any{
+				map[string]any{"type": "tool_result", "tool_use_id": "toolu_1", "content": []any{map[string]any{"type": "text", "text": "sunny"}}},
+				map[string]any{"type": "image", "source": map[string]any{"type": "base64", "media_type": "image/png", "data": "AAAA"}},
+			}},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, float64(1024), chat["max_tokens"])
+	assert.Equal(t, []any{"END"}, chat["stop"])
+	assert.Equal(t, map[string]any{"effort": "high"}, chat["reasoning"])
+	assert.Equal(t, "required", chat["tool_choice"])
+	assert.Equal(t, false, chat["parallel_tool_calls"])
+	assert.Equal(t, []any{
+		map[string]any{"type": "function", "function": map[string]any{"name": "get_weather", "description": "Weather", "parameters": map[string]any{"type": "object"}}},
+	}, chat["tools"])
+
+	assert.Equal(t, []any{
+		map[string]any{"role": "system", "content": "Be brief."},
+		map[string]any{"role": "user", "content": "Weather in Paris?"},
+		map[string]any{
+			"role":              "assistant",
+			"content":           "",
+			"reasoning_content": "cached thoughts",
+			"tool_calls": []any{map[string]any{
+				"id":       "toolu_1",
+				"type":     "function",
+				"function": map[string]any{"name": "get_weather", "arguments": `{"city":"Paris"}`},
+			}},
+		},
+		map[string]any{"role": "tool", "tool_call_id": "toolu_1", "content": "sunny"},
+		map[string]any{"role": "user", "content": []any{
+			map[string]any{"type": "image_url", "image_url": map[string]any{"url": "data:image/png;base64,AAAA"}},
+		}},
+	}, chat["messages"])
+}
+
+func TestMessagesToChat_MissingMessages(t *testing.T) {
+	_, err := newTestAdapter().messagesToChat(cacheScope{}, map[string]any{"model": "gpt-oss-20b"})
+	assert.Error(t, err)
+}
+
+func TestMessagesThinkingEffort(t *testing.T) {
+	tests := []struct {
+		thinking any
+		effort   string
+	}{
+		{nil, ""},
+		{map[string]any{"type": "disabled"}, "low"},
+		{map[string]any{"type": "enabled", "budget_tokens": float64(1024)}, "low"},
+		{map[string]any{"type": "enabled", "budget_tokens": float64(8192)}, "medium"},
+		{map[string]any{"type": "enabled", "budget_tokens": float64(32000)}, "high"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.effort, messagesThinkingEffort(tt.thinking), "%v", tt.thinking)
+	}
+}
+
+func TestChatToMessage(t *testing.T) {
+	adapter := newTestAdapter()
+
+	chatResponse := map[string]any{
+		"model": "gpt-oss-20b",
+		"choices": []any{map[string]any{
+			"finish_reason": "tool_calls",
+			"message": map[string]any{
+				"role":      "assistant",
+				"reasoning": "Need the weather.",
+				"content":   "Checking.",
+				"tool_calls": []any{map[string]any{
+					"id":       "call_1",
+					"type":     "function",
+					"function": map[string]any{"name": "get_weather", "arguments": `{"city":"Paris"}`},
+				}},
+			},
+		}},
+		"usage": map[string]any{"prompt_tokens": float64(12), "completion_tokens": float64(30)},
+	}
+
+	message := adapter.chatToMessage(cacheScope{}, chatResponse, map[string]any{}, true)
+
+	assert.Equal(t, "tool_use", message["stop_reason"])
+	assert.Equal(t, map[string]any{"input_tokens": float64(12), "output_tokens": float64(30)}, message["usage"])
+
+	content := message["content"].([]any)
+	require.Len(t, content, 3)
+
+	thinking := content[0].(map[string]any)
+	assert.Equal(t, "thinking", thinking["type"])
+	assert.Equal(t, "Need the weather.", thinking["thinking"])
+	cached, found := adapter.cache.Get("", thinking["signature"].(string))
+	assert.True(t, found)
+	assert.Equal(t, "Need the weather.", cached.Content)
+
+	assert.Equal(t, map[string]any{"type": "text", "text": "Checking."}, content[1])
+	assert.Equal(t, map[string]any{
+		"type":  "tool_use",
+		"id":    "call_1",
+		"name":  "get_weather",
+		"input": map[string]any{"city": "Paris"},
+	}, content[2])
+
+	withoutThinking := adapter.chatToMessage(cacheScope{}, chatResponse, map[string]any{}, false)
+	assert.Len(t, withoutThinking["content"], 2)
+}
+
+func TestMessagesStream(t *testing.T) {
+	var buf bytes.Buffer
+	stream := &messagesStream{
+		adapter:  newTestAdapter(),
+		w:        &buf,
+		flush:    func() {},
+		thinking: true,
+		message:  newMessageObject(map[string]any{"model": "gpt-oss-20b"}),
+		tools:    make(map[int]int),
+	}
+
+	stream.start()
+	for _, line := range []string{
+		`data: {"choices":[{"index":0,"delta":{"reasoning":"Hmm."}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"content":"Hi"}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"f","arguments":"{}"}}]},"finish_reason":"tool_calls"}]}`,
+		`data: [DONE]`,
+	} {
+		stream.handleLine(line)
+	}
+	stream.finish()
+
+	output := buf.String()
+	for _, expected := range []string{
+		"event: message_start\n",
+		`"content_block":{"signature":"","thinking":"","type":"thinking"},"index":0`,
+		`"delta":{"thinking":"Hmm.","type":"thinking_delta"},"index":0`,
+		`"type":"signature_delta"`,
+		`"content_block":{"text":"","type":"text"},"index":1`,
+		`"content_block":{"id":"call_1","input":{},"name":"f","type":"tool_use"},"index":2`,
+		`"delta":{"partial_json":"{}","type":"input_json_delta"},"index":2`,
+		`"stop_reason":"tool_use"`,
+		"event: message_stop\n",
+	} {
+		assert.Contains(t, output, expected)
+	}
+	assert.Equal(t, 1, bytes.Count(buf.Bytes(), []byte("event: message_stop")))
+	assert.Equal(t, 3, bytes.Count(buf.Bytes(), []byte("event: content_block_stop")))
+}
+
+func TestMessagesStream_Error(t *testing.T) {
+	var buf bytes.Buffer
+	stream := &messagesStream{
+		adapter: newTestAdapter(),
+		w:       &buf,
+		flush:   func() {},
+		message: newMessageObject(map[string]any{}),
+		tools:   make(map[int]int),
+	}
+
+	stream.start()
+	stream.handleLine(`data: {"error":{"message":"Upstream stream interrupted: unexpected EOF","type":"server_error"}}`)
+	stream.handleLine(`data: [DONE]`)
+	stream.finish()
+
+	output := buf.String()
+	assert.Contains(t, output, "event: error\n")
+	assert.Contains(t, output, `"message":"Upstream stream interrupted: unexpected EOF"`)
+	assert.NotContains(t, output, "message_stop")
+}
+
+func TestChatToMessage_StripReasoning(t *testing.T) {
+	adapter := newTestAdapter()
+	adapter.StripReasoning = true
+
+	message := adapter.chatToMessage(cacheScope{}, map[string]any{
+		"choices": []any{map[string]any{
+			"finish_reason": "stop",
+			"message":       map[string]any{"role": "assistant", "reasoning": "Secret.", "content": "Hi"},
+		}},
+	}, map[string]any{}, true)
+
+	content := message["content"].([]any)
+	require.Len(t, content, 2)
+	redacted := content[0].(map[string]any)
+	assert.Equal(t, "redacted_thinking", redacted["type"])
+	assert.NotContains(t, redacted, "thinking")
+
+	// Echoing the redacted block back restores the reasoning.
+	chat := adapter.messagesAssistantToChat(cacheScope{}, content)
+	assert.Equal(t, "Secret.", chat["reasoning_content"])
+}
+
+func TestMessagesStream_StripReasoning(t *testing.T) {
+	adapter := newTestAdapter()
+	adapter.StripReasoning = true
+
+	var buf bytes.Buffer
+	stream := &messagesStream{
+		adapter:  adapter,
+		w:        &buf,
+		flush:    func() {},
+		thinking: true,
+		message:  newMessageObject(map[string]any{"model": "gpt-oss-20b"}),
+		tools:    make(map[int]int),
+	}
+
+	stream.start()
+	for _, line := range []string{
+		`data: {"choices":[{"index":0,"delta":{"reasoning":"Sec"}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"reasoning":"ret."}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"content":"Hi"},"finish_reason":"stop"}]}`,
+		`data: [DONE]`,
+	} {
+		stream.handleLine(line)
+	}
+	stream.finish()
+
+	output := buf.String()
+	assert.NotContains(t, output, "Sec")
+	assert.NotContains(t, output, "thinking_delta")
+	assert.Contains(t, output, `"type":"redacted_thinking"},"index":0`)
+	assert.Contains(t, output, `"content_block":{"text":"","type":"text"},"index":1`)
+	assert.Equal(t, 2, bytes.Count(buf.Bytes(), []byte("event: content_block_stop")))
+}
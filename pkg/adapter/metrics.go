@@ -0,0 +1,236 @@
+package adapter
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+)
+
+// handleMetrics serves adapter metrics in the Prometheus text exposition
+// format.
+func (a *Adapter) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	writeMetric(w, "gpt_oss_adapter_inflight_requests", "gauge", "Chat, responses and messages requests being processed.",
+		sample{value: a.inflight.Load()})
+
+	if a.Coalesce {
+		coalesced := a.coalescer.coalesced.Load()
+		for _, route := range a.routeAdapters() {
+			coalesced += route.coalescer.coalesced.Load()
+		}
+		writeMetric(w, "gpt_oss_adapter_coalesced_requests_total", "counter", "Chat requests served from an identical request in flight.",
+			sample{value: coalesced})
+	}
+
+	stats := a.cache.Stats()
+	writeMetric(w, "gpt_oss_adapter_cache_hits_total", "counter", "Reasoning cache lookups that found an entry.", sample{value: stats.Hits})
+	writeMetric(w, "gpt_oss_adapter_cache_misses_total", "counter", "Reasoning cache lookups that found no entry.", sample{value: stats.Misses})
+	writeMetric(w, "gpt_oss_adapter_cache_inserts_total", "counter", "Entries written to the reasoning cache.", sample{value: stats.Inserts})
+	writeMetric(w, "gpt_oss_adapter_cache_evictions_total", "counter", "Reasoning cache entries evicted to make room or because they expired.", sample{value: stats.Evictions})
+
+	if lru, ok := unwrapCache(a.cache).(*LRUCache); ok {
+		writeMetric(w, "gpt_oss_adapter_cache_entries", "gauge", "Entries in the in-memory reasoning cache.",
+			sample{value: int64(lru.Size())})
+		writeMetric(w, "gpt_oss_adapter_cache_bytes", "gauge", "Size in bytes of the keys and reasoning in the in-memory cache.",
+			sample{value: lru.Bytes()})
+	}
+
+	if c := a.ResponseCache; c != nil {
+		writeMetric(w, "gpt_oss_adapter_response_cache_hits_total", "counter", "Chat requests answered from the response cache.", sample{value: c.hits.Load()})
+		writeMetric(w, "gpt_oss_adapter_response_cache_misses_total", "counter", "Deterministic chat requests not found in the response cache.", sample{value: c.misses.Load()})
+		writeMetric(w, "gpt_oss_adapter_response_cache_entries", "gauge", "Responses in the response cache.", sample{value: int64(c.Len())})
+		writeMetric(w, "gpt_oss_adapter_response_cache_bytes", "gauge", "Size in bytes of the response bodies in the response cache.", sample{value: c.Bytes()})
+	}
+
+	if d := a.EffortDowngrade; d != nil {
+		writeMetric(w, "gpt_oss_adapter_effort_downgrades_total", "counter", "Requests whose reasoning effort was lowered under load.", sample{value: d.downgraded.Load()})
+	}
+
+	if a.ToolChoiceRetries > 0 {
+		writeMetric(w, "gpt_oss_adapter_tool_choice_retries_total", "counter", "Chat requests sent again because the model did not call the required tool.",
+			sample{value: a.toolRetries.missing.Load()})
+	}
+	if a.ToolCallRetries > 0 {
+		invalid := a.toolRetries.invalidCounts()
+		samples := make([]sample, 0, len(invalid))
+		for _, reason := range slices.Sorted(maps.Keys(invalid)) {
+			samples = append(samples, sample{labels: map[string]string{"reason": reason}, value: invalid[reason]})
+		}
+		writeMetric(w, "gpt_oss_adapter_tool_call_retries_total", "counter", "Chat requests sent again because the model made an invalid tool call.", samples...)
+	}
+
+	if a.Ledger != nil {
+		a.writeUsageMetrics(w)
+	}
+
+	a.writeQueueMetrics(w)
+	a.writeCircuitMetrics(w)
+	a.rates.writeMetrics(w)
+}
+
+// writeQueueMetrics exposes the request queues labelled by target.
+func (a *Adapter) writeQueueMetrics(w io.Writer) {
+	queues := a.queues()
+	if len(queues) == 0 {
+		return
+	}
+
+	targets := make([]string, 0, len(queues))
+	for target := range queues {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+
+	metrics := []struct {
+		name, kind, help string
+		value            func(q *RequestQueue) int64
+	}{
+		{"gpt_oss_adapter_queue_active", "gauge", "Requests holding a concurrency slot.", func(q *RequestQueue) int64 { return int64(q.Active()) }},
+		{"gpt_oss_adapter_queue_waiting", "gauge", "Requests waiting for a concurrency slot.", func(q *RequestQueue) int64 { return int64(q.Waiting()) }},
+		{"gpt_oss_adapter_queue_max_concurrent", "gauge", "Configured concurrency limit.", func(q *RequestQueue) int64 { return int64(q.MaxConcurrent) }},
+		{"gpt_oss_adapter_queue_rejected_total", "counter", "Requests rejected because the queue was full.", func(q *RequestQueue) int64 { return q.rejected.Load() }},
+		{"gpt_oss_adapter_queue_timeouts_total", "counter", "Requests rejected after waiting for the queue timeout.", func(q *RequestQueue) int64 { return q.timedOut.Load() }},
+		{"gpt_oss_adapter_queue_preempted_total", "counter", "Waiting requests rejected to make room for a higher priority request.", func(q *RequestQueue) int64 { return q.preempted.Load() }},
+	}
+
+	for _, metric := range metrics {
+		samples := make([]sample, 0, len(targets))
+		for _, target := range targets {
+			samples = append(samples, sample{labels: map[string]string{"target": target}, value: metric.value(queues[target])})
+		}
+		writeMetric(w, metric.name, metric.kind, metric.help, samples...)
+	}
+}
+
+// circuitStates are the values of the circuit state gauge.
+var circuitStates = map[string]int64{CircuitClosed: 0, CircuitHalfOpen: 1, CircuitOpen: 2}
+
+// writeCircuitMetrics exposes the circuit breakers labelled by target.
+func (a *Adapter) writeCircuitMetrics(w io.Writer) {
+	breakers := a.breakers()
+	if len(breakers) == 0 {
+		return
+	}
+
+	targets := make([]string, 0, len(breakers))
+	for target := range breakers {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+
+	metrics := []struct {
+		name, kind, help string
+		value            func(b *CircuitBreaker) int64
+	}{
+		{"gpt_oss_adapter_circuit_state", "gauge", "Circuit breaker state: 0 closed, 1 half-open, 2 open.", func(b *CircuitBreaker) int64 { return circuitStates[b.State()] }},
+		{"gpt_oss_adapter_circuit_opened_total", "counter", "Times the circuit breaker opened.", func(b *CircuitBreaker) int64 { return b.opened.Load() }},
+		{"gpt_oss_adapter_circuit_rejected_total", "counter", "Requests rejected because the circuit was open.", func(b *CircuitBreaker) int64 { return b.rejected.Load() }},
+	}
+
+	for _, metric := range metrics {
+		samples := make([]sample, 0, len(targets))
+		for _, target := range targets {
+			samples = append(samples, sample{labels: map[string]string{"target": target}, value: metric.value(breakers[target])})
+		}
+		writeMetric(w, metric.name, metric.kind, metric.help, samples...)
+	}
+}
+
+// writeUsageMetrics exposes the usage ledger as counters labelled by client
+// and model.
+func (a *Adapter) writeUsageMetrics(w io.Writer) {
+	totals := a.Ledger.Totals("")
+
+	metrics := []struct {
+		name, help string
+		value      func(t UsageTotals) int64
+	}{
+		{"gpt_oss_adapter_usage_requests_total", "Requests answered by the backend.", func(t UsageTotals) int64 { return t.Requests }},
+		{"gpt_oss_adapter_usage_prompt_tokens_total", "Prompt tokens reported by the backend.", func(t UsageTotals) int64 { return t.PromptTokens }},
+		{"gpt_oss_adapter_usage_completion_tokens_total", "Completion tokens reported by the backend.", func(t UsageTotals) int64 { return t.CompletionTokens }},
+		{"gpt_oss_adapter_usage_reasoning_tokens_total", "Reasoning tokens reported by the backend.", func(t UsageTotals) int64 { return t.ReasoningTokens }},
+		{"gpt_oss_adapter_usage_tokens_total", "Total tokens reported by the backend.", func(t UsageTotals) int64 { return t.TotalTokens }},
+	}
+
+	for _, metric := range metrics {
+		samples := make([]sample, 0, len(totals))
+		for _, t := range totals {
+			samples = append(samples, sample{labels: map[string]string{"client": t.Client, "model": t.Model}, value: metric.value(t)})
+		}
+		writeMetric(w, metric.name, "counter", metric.help, samples...)
+	}
+}
+
+// queues returns the request queue of the adapter, its routes and its
+// fallback, keyed by target.
+func (a *Adapter) queues() map[string]*RequestQueue {
+	queues := make(map[string]*RequestQueue)
+	if a.Queue != nil {
+		queues[a.Target] = a.Queue
+	}
+	for _, route := range a.routeAdapters() {
+		if route.Queue != nil {
+			queues[route.Target] = route.Queue
+		}
+	}
+	if a.fallback != nil && a.fallback.Queue != nil {
+		queues[a.fallback.Target] = a.fallback.Queue
+	}
+	return queues
+}
+
+// breakers returns the circuit breaker of the adapter, its routes and its
+// fallback, keyed by target.
+func (a *Adapter) breakers() map[string]*CircuitBreaker {
+	breakers := make(map[string]*CircuitBreaker)
+	if a.Breaker != nil {
+		breakers[a.Target] = a.Breaker
+	}
+	for _, route := range a.routeAdapters() {
+		if route.Breaker != nil {
+			breakers[route.Target] = route.Breaker
+		}
+	}
+	if a.fallback != nil && a.fallback.Breaker != nil {
+		breakers[a.fallback.Target] = a.fallback.Breaker
+	}
+	return breakers
+}
+
+type sample struct {
+	labels map[string]string
+	value  int64
+}
+
+func writeMetric(w io.Writer, name, kind, help string, samples ...sample) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	for _, s := range samples {
+		fmt.Fprintf(w, "%s%s %d\n", name, formatLabels(s.labels), s.value)
+	}
+}
+
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := "{"
+	for i, name := range names {
+		if i > 0 {
+			out += ","
+		}
+		out += name + "=" + strconv.Quote(labels[name])
+	}
+	return out + "}"
+}
@@ -0,0 +1,148 @@
+package adapter
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// LoggingMiddleware wraps an http.Handler and logs HTTP requests in Apache/nginx format
+type LoggingMiddleware struct {
+	handler http.Handler
+	logger  *slog.Logger
+
+	// File, if set, also receives every request in the combined log
+	// format.
+	File *AccessLogFile
+}
+
+// NewLoggingMiddleware creates a new HTTP logging middleware. A nil logger
+// only tags requests for per-route log levels and writes to File.
+func NewLoggingMiddleware(handler http.Handler, logger *slog.Logger) *LoggingMiddleware {
+	return &LoggingMiddleware{
+		handler: handler,
+		logger:  logger,
+	}
+}
+
+// responseWriter wraps http.ResponseWriter to capture status code and response size
+type responseWriter struct {
+	http.ResponseWriter
+	statusCode int
+	size       int
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+	rw.statusCode = code
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	size, err := rw.ResponseWriter.Write(b)
+	rw.size += size
+	return size, err
+}
+
+func (rw *responseWriter) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	rw.statusCode = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
+func (m *LoggingMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	// Wrap the response writer to capture status and size
+	rw := &responseWriter{
+		ResponseWriter: w,
+		statusCode:     200, // default status code
+		size:           0,
+	}
+
+	// Tag the request with its path so that per-route log levels apply
+	r = r.WithContext(withLogRoute(r.Context(), r.URL.Path))
+
+	// Call the wrapped handler
+	m.handler.ServeHTTP(rw, r)
+
+	// Calculate request duration
+	duration := time.Since(start)
+
+	// Get client IP, preferring X-Forwarded-For or X-Real-IP headers
+	clientIP := getClientIP(r)
+
+	// Get user agent
+	userAgent := r.Header.Get("User-Agent")
+	if userAgent == "" {
+		userAgent = "-"
+	}
+
+	// Get referer
+	referer := r.Header.Get("Referer")
+	if referer == "" {
+		referer = "-"
+	}
+
+	if m.File != nil {
+		if _, err := m.File.Write(combinedLogLine(r, clientIP, start, rw.statusCode, rw.size)); err != nil && m.logger != nil {
+			m.logger.ErrorContext(r.Context(), "failed to write access log", "error", err)
+		}
+	}
+	if m.logger == nil {
+		return
+	}
+
+	// Log using structured logging fields
+	m.logger.InfoContext(r.Context(), "HTTP request",
+		"client_ip", clientIP,
+		"method", r.Method,
+		"path", r.RequestURI,
+		"protocol", r.Proto,
+		"status", rw.statusCode,
+		"size", rw.size,
+		"referer", referer,
+		"user_agent", userAgent,
+		"duration_ms", duration.Milliseconds(),
+	)
+}
+
+// getClientIP extracts the client IP from the request, checking proxy headers first
+func getClientIP(r *http.Request) string {
+	// Check X-Forwarded-For header
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		// X-Forwarded-For can contain multiple IPs, take the first one
+		if idx := strings.Index(xff, ","); idx != -1 {
+			return strings.TrimSpace(xff[:idx])
+		}
+		return strings.TrimSpace(xff)
+	}
+
+	// Check X-Real-IP header
+	if xri := r.Header.Get("X-Real-IP"); xri != "" {
+		return strings.TrimSpace(xri)
+	}
+
+	// Fall back to RemoteAddr
+	if idx := strings.LastIndex(r.RemoteAddr, ":"); idx != -1 {
+		return r.RemoteAddr[:idx]
+	}
+
+	return r.RemoteAddr
+}
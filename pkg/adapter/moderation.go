@@ -0,0 +1,140 @@
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	ModerationModeBlock    = "block"
+	ModerationModeAnnotate = "annotate"
+)
+
+// Moderator posts content to an external moderation endpoint speaking the
+// OpenAI moderations format, i.e. {"input": "..."} in and
+// {"results": [{"flagged": true, ...}]} out.
+type Moderator struct {
+	URL           string
+	Mode          string
+	FailOpen      bool
+	CheckResponse bool
+	client        *http.Client
+}
+
+func NewModerator(url string, mode string, failOpen bool, checkResponse bool, timeout time.Duration) *Moderator {
+	return &Moderator{
+		URL:           url,
+		Mode:          mode,
+		FailOpen:      failOpen,
+		CheckResponse: checkResponse,
+		client:        &http.Client{Timeout: timeout},
+	}
+}
+
+type moderationResponse struct {
+	Results []struct {
+		Flagged bool `json:"flagged"`
+	} `json:"results"`
+}
+
+// Check reports whether the moderation endpoint flagged the given input. When
+// the endpoint cannot be reached or returns an unexpected response, the
+// verdict falls back to the configured fail-open/fail-closed behavior and the
+// error is returned alongside it.
+func (m *Moderator) Check(ctx context.Context, input string) (bool, error) {
+	flagged, err := m.check(ctx, input)
+	if err != nil {
+		return !m.FailOpen, err
+	}
+	return flagged, nil
+}
+
+func (m *Moderator) check(ctx context.Context, input string) (bool, error) {
+	body, err := json.Marshal(map[string]any{"input": input})
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("moderation endpoint returned status %d", resp.StatusCode)
+	}
+
+	var result moderationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, err
+	}
+
+	for _, r := range result.Results {
+		if r.Flagged {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Blocks reports whether a flagged verdict should reject the request rather
+// than annotate it.
+func (m *Moderator) Blocks() bool {
+	return m.Mode != ModerationModeAnnotate
+}
+
+// lastUserMessage returns the text content of the last user message in a
+// chat completions request.
+func lastUserMessage(requestData map[string]any) string {
+	messages, ok := requestData["messages"].([]any)
+	if !ok {
+		return ""
+	}
+
+	for i := len(messages) - 1; i >= 0; i-- {
+		message, ok := messages[i].(map[string]any)
+		if !ok {
+			continue
+		}
+
+		if role, _ := message["role"].(string); role == "user" {
+			return messageText(message)
+		}
+	}
+
+	return ""
+}
+
+// messageText flattens a message's content, which may be either a string or
+// an array of content parts, into plain text.
+func messageText(message map[string]any) string {
+	switch content := message["content"].(type) {
+	case string:
+		return content
+	case []any:
+		var parts []string
+		for _, p := range content {
+			part, ok := p.(map[string]any)
+			if !ok {
+				continue
+			}
+			if text, ok := part["text"].(string); ok {
+				parts = append(parts, text)
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+	return ""
+}
@@ -0,0 +1,99 @@
+package adapter
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+)
+
+func TestModeration(t *testing.T) {
+	moderator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Input string `json:"input"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		switch request.Input {
+		case "unreachable":
+			http.Error(w, "down", http.StatusInternalServerError)
+		default:
+			json.NewEncoder(w).Encode(map[string]any{"results": []any{map[string]any{"flagged": strings.Contains(request.Input, "bad")}}})
+		}
+	}))
+	defer moderator.Close()
+
+	var forwarded atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"Hi."},"finish_reason":"stop"}]}`)
+	}))
+	defer upstream.Close()
+
+	tests := []struct {
+		name      string
+		mode      string
+		failOpen  bool
+		input     string
+		status    int
+		code      string
+		flagged   string
+		forwarded int32
+	}{
+		{"clean", ModerationModeBlock, false, "hello", http.StatusOK, "", "", 1},
+		{"flagged", ModerationModeBlock, false, "something bad", http.StatusBadRequest, "content_filter", "", 0},
+		{"flagged annotate", ModerationModeAnnotate, false, "something bad", http.StatusOK, "", "true", 1},
+		{"fail open", ModerationModeBlock, true, "unreachable", http.StatusOK, "", "", 1},
+		{"fail closed", ModerationModeBlock, false, "unreachable", http.StatusServiceUnavailable, "moderation_unavailable", "", 0},
+		{"fail closed annotate", ModerationModeAnnotate, false, "unreachable", http.StatusServiceUnavailable, "moderation_unavailable", "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forwarded.Store(0)
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			adapter := NewAdapter(upstream.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+			adapter.Moderator = NewModerator(moderator.URL, tt.mode, tt.failOpen, false, time.Second)
+
+			body := `{"messages":[{"role":"user","content":"` + tt.input + `"}]}`
+			r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+			w := httptest.NewRecorder()
+			adapter.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, tt.flagged, w.Header().Get("X-Moderation-Flagged"))
+			assert.Equal(t, tt.forwarded, forwarded.Load())
+			if tt.code != "" {
+				var response struct {
+					Error openAIError `json:"error"`
+				}
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Equal(t, tt.code, response.Error.Code)
+			}
+		})
+	}
+}
+
+func TestLastUserMessage(t *testing.T) {
+	requestData := map[string]any{"messages": []any{
+		map[string]any{"role": "user", "content": "first"},
+		map[string]any{"role": "user", "content": []any{
+			map[string]any{"type": "text", "text": "second"},
+			map[string]any{"type": "image_url", "image_url": map[string]any{"url": "data:"}},
+			map[string]any{"type": "text", "text": "part"},
+		}},
+		map[string]any{"role": "assistant", "content": "reply"},
+	}}
+	assert.Equal(t, "second\npart", lastUserMessage(requestData))
+	assert.Empty(t, lastUserMessage(map[string]any{}))
+}
@@ -0,0 +1,63 @@
+package adapter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+)
+
+const (
+	// CacheNamespaceNone shares one namespace between all clients.
+	CacheNamespaceNone = "none"
+	// CacheNamespaceAuth gives each API key its own namespace.
+	CacheNamespaceAuth = "auth"
+	// CacheNamespaceHeader gives each value of CacheNamespaceHeader its own
+	// namespace, e.g. a session or user ID set by a gateway.
+	CacheNamespaceHeader = "header"
+)
+
+// DefaultCacheNamespaceHeader is read in CacheNamespaceHeader mode when no
+// other header is configured.
+const DefaultCacheNamespaceHeader = "X-Session-ID"
+
+// cacheNamespace derives the cache namespace for a request. The credential or
+// header value is hashed so that it never ends up in cache keys or on disk.
+// Requests without one share the empty namespace.
+func (a *Adapter) cacheNamespace(r *http.Request) string {
+	var source string
+	switch a.CacheNamespace {
+	case CacheNamespaceAuth:
+		source = requestCredential(r)
+	case CacheNamespaceHeader:
+		header := a.CacheNamespaceHeader
+		if header == "" {
+			header = DefaultCacheNamespaceHeader
+		}
+		source = r.Header.Get(header)
+	}
+
+	if source == "" {
+		return ""
+	}
+	return hashIdentity(source)
+}
+
+// requestCredential returns the API key a request was made with, as sent in
+// the Authorization or X-Api-Key header. Keys checked by the adapter have
+// already been removed from the headers and are taken from the context.
+func requestCredential(r *http.Request) string {
+	if key, ok := authenticatedKey(r.Context()); ok {
+		return "Bearer " + key.Key
+	}
+	if credential := r.Header.Get("Authorization"); credential != "" {
+		return credential
+	}
+	return r.Header.Get("X-Api-Key")
+}
+
+// hashIdentity shortens a credential or other client identifier to a hash
+// that is safe to store and display.
+func hashIdentity(source string) string {
+	sum := sha256.Sum256([]byte(source))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,69 @@
+package adapter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheNamespace(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		headers map[string]string
+		empty   bool
+	}{
+		{"disabled", "", map[string]string{"Authorization": "Bearer a"}, true},
+		{"authorization", CacheNamespaceAuth, map[string]string{"Authorization": "Bearer a"}, false},
+		{"api key", CacheNamespaceAuth, map[string]string{"X-Api-Key": "a"}, false},
+		{"no credentials", CacheNamespaceAuth, nil, true},
+		{"session header", CacheNamespaceHeader, map[string]string{"X-Session-ID": "s1"}, false},
+		{"no session header", CacheNamespaceHeader, map[string]string{"Authorization": "Bearer a"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adapter := newTestAdapter()
+			adapter.CacheNamespace = tt.mode
+
+			r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+
+			namespace := adapter.cacheNamespace(r)
+			if tt.empty {
+				assert.Empty(t, namespace)
+				return
+			}
+			assert.Regexp(t, `^[0-9a-f]{16}$`, namespace)
+		})
+	}
+}
+
+func TestCacheNamespace_Isolation(t *testing.T) {
+	adapter := newTestAdapter()
+	adapter.CacheNamespace = CacheNamespaceAuth
+
+	alice := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	alice.Header.Set("Authorization", "Bearer alice")
+	bob := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	bob.Header.Set("Authorization", "Bearer bob")
+
+	toolCalls := []any{map[string]any{"id": "call_1", "function": map[string]any{"name": "f", "arguments": "{}"}}}
+	adapter.cacheReasoning(adapter.cacheNamespace(alice), toolCalls, "Alice's reasoning")
+
+	for _, tt := range []struct {
+		r    *http.Request
+		want any
+	}{
+		{alice, "Alice's reasoning"},
+		{bob, nil},
+	} {
+		message := map[string]any{"role": "assistant", "tool_calls": toolCalls}
+		adapter.injectReasoningFromCache(adapter.cacheNamespace(tt.r), map[string]any{"messages": []any{message}})
+		assert.Equal(t, tt.want, message["reasoning_content"])
+	}
+}
@@ -0,0 +1,46 @@
+package adapter
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const (
+	StreamFormatSSE    = "sse"
+	StreamFormatNDJSON = "ndjson"
+)
+
+// acceptsNDJSON reports whether the client asked for newline-delimited JSON
+// streaming via the Accept header.
+func acceptsNDJSON(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil {
+				continue
+			}
+			if mediaType == "application/x-ndjson" || mediaType == "application/jsonl" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeNDJSONLine converts a single SSE line into NDJSON. Only data lines
+// carrying JSON payloads are emitted; blank lines, comments, other SSE fields
+// and the [DONE] sentinel are dropped.
+func writeNDJSONLine(w io.Writer, line string) {
+	if !strings.HasPrefix(line, "data: ") {
+		return
+	}
+
+	data := strings.TrimPrefix(line, "data: ")
+	if data == "" || data == "[DONE]" {
+		return
+	}
+
+	w.Write([]byte(data + "\n"))
+}
@@ -0,0 +1,71 @@
+package adapter
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+)
+
+func TestStreamNDJSON(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, ": keep-alive\n\n")
+		io.WriteString(w, `data: {"object":"chat.completion.chunk","choices":[{"index":0,"delta":{"role":"assistant","content":"Hel"}}]}`+"\n\n")
+		io.WriteString(w, `data: {"object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"lo"},"finish_reason":"stop"}]}`+"\n\n")
+		io.WriteString(w, `data: {"object":"chat.completion.chunk","choices":[],"usage":{"prompt_tokens":3,"completion_tokens":2,"total_tokens":5}}`+"\n\n")
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	defer upstream.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter(upstream.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+	adapter.StreamFormat = StreamFormatNDJSON
+
+	body := `{"stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"Hi"}]}`
+	r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, r)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	require.True(t, strings.HasSuffix(w.Body.String(), "\n"))
+
+	lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+	require.Len(t, lines, 3)
+
+	var content strings.Builder
+	for _, line := range lines {
+		var chunk map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &chunk), line)
+		forEachChoice(chunk, "delta", func(_ int, delta map[string]any) {
+			text, _ := delta["content"].(string)
+			content.WriteString(text)
+		})
+	}
+	assert.Equal(t, "Hello", content.String())
+
+	var last map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[2]), &last))
+	assert.Empty(t, last["choices"])
+	assert.Equal(t, map[string]any{"prompt_tokens": float64(3), "completion_tokens": float64(2), "total_tokens": float64(5)}, last["usage"])
+}
+
+func TestAcceptsNDJSON(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	assert.False(t, acceptsNDJSON(r))
+
+	r.Header.Set("Accept", "text/event-stream, application/x-ndjson; q=0.9")
+	assert.True(t, acceptsNDJSON(r))
+
+	r.Header.Set("Accept", "application/jsonl")
+	assert.True(t, acceptsNDJSON(r))
+}
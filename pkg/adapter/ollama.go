@@ -0,0 +1,402 @@
+package adapter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const ollamaChatPath = "/api/chat"
+
+// chatToOllamaRequest translates an OpenAI chat completions request, after
+// reasoning injection, into a request for Ollama's native /api/chat endpoint.
+func chatToOllamaRequest(chat map[string]any) map[string]any {
+	req := map[string]any{
+		"model":  chat["model"],
+		"stream": false,
+	}
+
+	if stream, ok := chat["stream"].(bool); ok {
+		req["stream"] = stream
+	}
+	if think, ok := chat["think"]; ok {
+		req["think"] = think
+	}
+	if tools, ok := chat["tools"]; ok {
+		req["tools"] = tools
+	}
+
+	options := make(map[string]any)
+	for from, to := range map[string]string{
+		"temperature":           "temperature",
+		"top_p":                 "top_p",
+		"seed":                  "seed",
+		"stop":                  "stop",
+		"max_tokens":            "num_predict",
+		"max_completion_tokens": "num_predict",
+		"presence_penalty":      "presence_penalty",
+		"frequency_penalty":     "frequency_penalty",
+	} {
+		if value, ok := chat[from]; ok {
+			options[to] = value
+		}
+	}
+	if len(options) > 0 {
+		req["options"] = options
+	}
+
+	if format, ok := chat["response_format"].(map[string]any); ok {
+		switch format["type"] {
+		case "json_object":
+			req["format"] = "json"
+		case "json_schema":
+			if schema, ok := format["json_schema"].(map[string]any); ok {
+				req["format"] = schema["schema"]
+			}
+		}
+	}
+
+	toolNames := make(map[string]any)
+	var messages []any
+
+	if chatMessages, ok := chat["messages"].([]any); ok {
+		for _, msg := range chatMessages {
+			message, ok := msg.(map[string]any)
+			if !ok {
+				continue
+			}
+
+			converted := make(map[string]any)
+			for key, value := range message {
+				switch key {
+				case "content", "tool_calls", "tool_call_id", "name":
+				default:
+					converted[key] = value
+				}
+			}
+
+			text, images := ollamaContent(message["content"])
+			converted["content"] = text
+			if len(images) > 0 {
+				converted["images"] = images
+			}
+
+			if toolCalls, ok := message["tool_calls"].([]any); ok {
+				var ollamaCalls []any
+				for _, tc := range toolCalls {
+					toolCall, ok := tc.(map[string]any)
+					if !ok {
+						continue
+					}
+					function, _ := toolCall["function"].(map[string]any)
+					toolNames[fmt.Sprint(toolCall["id"])] = function["name"]
+
+					var arguments any = map[string]any{}
+					if encoded, ok := function["arguments"].(string); ok && encoded != "" {
+						if err := json.Unmarshal([]byte(encoded), &arguments); err != nil {
+							arguments = map[string]any{}
+						}
+					}
+
+					ollamaCalls = append(ollamaCalls, map[string]any{
+						"function": map[string]any{
+							"name":      function["name"],
+							"arguments": arguments,
+						},
+					})
+				}
+				converted["tool_calls"] = ollamaCalls
+			}
+
+			if role, _ := message["role"].(string); role == "tool" {
+				if name, ok := toolNames[fmt.Sprint(message["tool_call_id"])]; ok {
+					converted["tool_name"] = name
+				}
+			}
+
+			messages = append(messages, converted)
+		}
+	}
+	req["messages"] = messages
+
+	return req
+}
+
+// ollamaContent splits chat message content into Ollama's text content and
+// base64 image list.
+func ollamaContent(content any) (string, []any) {
+	switch c := content.(type) {
+	case string:
+		return c, nil
+	case []any:
+		var texts []string
+		var images []any
+		for _, p := range c {
+			part, ok := p.(map[string]any)
+			if !ok {
+				continue
+			}
+			switch part["type"] {
+			case "text":
+				if text, ok := part["text"].(string); ok {
+					texts = append(texts, text)
+				}
+			case "image_url":
+				imageURL, _ := part["image_url"].(map[string]any)
+				if url, ok := imageURL["url"].(string); ok {
+					if _, data, found := strings.Cut(url, ";base64,"); found {
+						images = append(images, data)
+					}
+				}
+			}
+		}
+		return strings.Join(texts, "\n"), images
+	}
+	return "", nil
+}
+
+// ollamaResponseToChat replaces the body of a native Ollama response with
+// its OpenAI chat completions equivalent, so the rest of the pipeline can
+// treat it like any other backend.
+func (a *Adapter) ollamaResponseToChat(resp *http.Response) *http.Response {
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		var native struct {
+			Error string `json:"error"`
+		}
+		message := strings.TrimSpace(string(body))
+		if err := json.Unmarshal(body, &native); err == nil && native.Error != "" {
+			message = native.Error
+		}
+
+		encoded, _ := json.Marshal(map[string]any{
+			"error": openAIError{Message: message, Type: "invalid_request_error"},
+		})
+		return replaceResponseBody(resp, "application/json", io.NopCloser(bytes.NewReader(encoded)))
+	}
+
+	if strings.Contains(resp.Header.Get("Content-Type"), "application/x-ndjson") {
+		reader, writer := io.Pipe()
+		go a.ollamaStreamToSSE(resp.Body, writer)
+		return replaceResponseBody(resp, "text/event-stream", reader)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		a.logger.Error("failed to read ollama response", "error", err)
+		return replaceResponseBody(resp, "application/json", io.NopCloser(bytes.NewReader(body)))
+	}
+
+	var native map[string]any
+	if err := json.Unmarshal(body, &native); err != nil {
+		a.logger.Error("failed to unmarshal ollama response", "error", err)
+		return replaceResponseBody(resp, "application/json", io.NopCloser(bytes.NewReader(body)))
+	}
+
+	encoded, _ := json.Marshal(ollamaToChatCompletion(native))
+	return replaceResponseBody(resp, "application/json", io.NopCloser(bytes.NewReader(encoded)))
+}
+
+func replaceResponseBody(resp *http.Response, contentType string, body io.ReadCloser) *http.Response {
+	resp.Header.Set("Content-Type", contentType)
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Body = body
+	return resp
+}
+
+func (a *Adapter) ollamaStreamToSSE(body io.ReadCloser, writer *io.PipeWriter) {
+	defer body.Close()
+
+	id := "chatcmpl-" + randomHex(16)
+	toolIndex := 0
+	first := true
+
+	write := func(chunk map[string]any) error {
+		encoded, err := json.Marshal(chunk)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(writer, "data: %s\n\n", encoded)
+		return err
+	}
+
+	maxLineSize := a.StreamMaxLineSize
+	if maxLineSize <= 0 {
+		maxLineSize = DefaultStreamMaxLineSize
+	}
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var native map[string]any
+		if err := json.Unmarshal(line, &native); err != nil {
+			a.logger.Debug("skipping malformed ollama stream line", "error", err)
+			continue
+		}
+
+		if message, ok := native["error"].(string); ok {
+			writer.CloseWithError(fmt.Errorf("ollama: %s", message))
+			return
+		}
+
+		delta := make(map[string]any)
+		if first {
+			delta["role"] = "assistant"
+			first = false
+		}
+
+		var finishReason any
+		if message, ok := native["message"].(map[string]any); ok {
+			if content, ok := message["content"].(string); ok && content != "" {
+				delta["content"] = content
+			}
+			if thinking, ok := message["thinking"].(string); ok && thinking != "" {
+				delta["thinking"] = thinking
+			}
+			if toolCalls := ollamaToolCalls(message["tool_calls"], &toolIndex); len(toolCalls) > 0 {
+				delta["tool_calls"] = toolCalls
+			}
+		}
+
+		chunk := map[string]any{
+			"id":      id,
+			"object":  "chat.completion.chunk",
+			"created": ollamaCreated(native),
+			"model":   native["model"],
+		}
+
+		if done, _ := native["done"].(bool); done {
+			finishReason = ollamaFinishReason(native, toolIndex > 0)
+			chunk["usage"] = ollamaUsage(native)
+		}
+
+		chunk["choices"] = []any{map[string]any{
+			"index":         0,
+			"delta":         delta,
+			"finish_reason": finishReason,
+		}}
+
+		if err := write(chunk); err != nil {
+			return
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		writer.CloseWithError(err)
+		return
+	}
+
+	fmt.Fprint(writer, "data: [DONE]\n\n")
+	writer.Close()
+}
+
+// ollamaToChatCompletion translates a blocking /api/chat response into a chat
+// completion.
+func ollamaToChatCompletion(native map[string]any) map[string]any {
+	message := map[string]any{"role": "assistant", "content": ""}
+	toolIndex := 0
+
+	if nativeMessage, ok := native["message"].(map[string]any); ok {
+		if content, ok := nativeMessage["content"].(string); ok {
+			message["content"] = content
+		}
+		if thinking, ok := nativeMessage["thinking"].(string); ok && thinking != "" {
+			message["thinking"] = thinking
+		}
+		if toolCalls := ollamaToolCalls(nativeMessage["tool_calls"], &toolIndex); len(toolCalls) > 0 {
+			for _, tc := range toolCalls {
+				delete(tc.(map[string]any), "index")
+			}
+			message["tool_calls"] = toolCalls
+		}
+	}
+
+	return map[string]any{
+		"id":      "chatcmpl-" + randomHex(16),
+		"object":  "chat.completion",
+		"created": ollamaCreated(native),
+		"model":   native["model"],
+		"choices": []any{map[string]any{
+			"index":         0,
+			"message":       message,
+			"finish_reason": ollamaFinishReason(native, toolIndex > 0),
+		}},
+		"usage": ollamaUsage(native),
+	}
+}
+
+// ollamaToolCalls converts native tool calls, which carry no IDs and use
+// object arguments, into chat tool calls. index is advanced for each call so
+// stream deltas receive distinct indices.
+func ollamaToolCalls(value any, index *int) []any {
+	nativeCalls, ok := value.([]any)
+	if !ok {
+		return nil
+	}
+
+	var toolCalls []any
+	for _, tc := range nativeCalls {
+		nativeCall, ok := tc.(map[string]any)
+		if !ok {
+			continue
+		}
+		function, _ := nativeCall["function"].(map[string]any)
+
+		arguments, _ := json.Marshal(function["arguments"])
+		toolCalls = append(toolCalls, map[string]any{
+			"index": *index,
+			"id":    "call_" + randomHex(12),
+			"type":  "function",
+			"function": map[string]any{
+				"name":      function["name"],
+				"arguments": string(arguments),
+			},
+		})
+		*index++
+	}
+	return toolCalls
+}
+
+func ollamaFinishReason(native map[string]any, hasToolCalls bool) string {
+	if hasToolCalls {
+		return "tool_calls"
+	}
+	if reason, _ := native["done_reason"].(string); reason == "length" {
+		return "length"
+	}
+	return "stop"
+}
+
+func ollamaUsage(native map[string]any) map[string]any {
+	prompt, _ := native["prompt_eval_count"].(float64)
+	completion, _ := native["eval_count"].(float64)
+	return map[string]any{
+		"prompt_tokens":     int(prompt),
+		"completion_tokens": int(completion),
+		"total_tokens":      int(prompt + completion),
+	}
+}
+
+func ollamaCreated(native map[string]any) int64 {
+	if createdAt, ok := native["created_at"].(string); ok {
+		if t, err := time.Parse(time.RFC3339Nano, createdAt); err == nil {
+			return t.Unix()
+		}
+	}
+	return time.Now().Unix()
+}
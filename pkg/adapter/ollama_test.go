@@ -0,0 +1,99 @@
+package adapter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatToOllamaRequest(t *testing.T) {
+	chat := map[string]any{
+		"model":      "gpt-oss:20b",
+		"stream":     true,
+		"think":      "high",
+		"max_tokens": float64(128),
+		"messages": []any{
+			map[string]any{"role": "user", "content": []any{
+				map[string]any{"type": "text", "text": "What is this?"},
+				map[string]any{"type": "image_url", "image_url": map[string]any{"url": "data:image/png;base64,AAAA"}},
+			}},
+			map[string]any{"role": "assistant", "content": "", "thinking": "Look it up.", "tool_calls": []any{
+				map[string]any{"id": "call_1", "type": "function", "function": map[string]any{
+					"name":      "lookup",
+					"arguments": `{"q":"cat"}`,
+				}},
+			}},
+			map[string]any{"role": "tool", "tool_call_id": "call_1", "content": "a cat"},
+		},
+	}
+
+	req := chatToOllamaRequest(chat)
+
+	assert.Equal(t, "gpt-oss:20b", req["model"])
+	assert.Equal(t, true, req["stream"])
+	assert.Equal(t, "high", req["think"])
+	assert.Equal(t, map[string]any{"num_predict": float64(128)}, req["options"])
+
+	messages := req["messages"].([]any)
+	require.Len(t, messages, 3)
+
+	assert.Equal(t, map[string]any{
+		"role":    "user",
+		"content": "What is this?",
+		"images":  []any{"AAAA"},
+	}, messages[0])
+
+	assert.Equal(t, map[string]any{
+		"role":     "assistant",
+		"content":  "",
+		"thinking": "Look it up.",
+		"tool_calls": []any{map[string]any{"function": map[string]any{
+			"name":      "lookup",
+			"arguments": map[string]any{"q": "cat"},
+		}}},
+	}, messages[1])
+
+	assert.Equal(t, map[string]any{
+		"role":      "tool",
+		"content":   "a cat",
+		"tool_name": "lookup",
+	}, messages[2])
+}
+
+func TestOllamaToChatCompletion(t *testing.T) {
+	native := map[string]any{
+		"model":      "gpt-oss:20b",
+		"created_at": "2025-08-05T12:00:00Z",
+		"message": map[string]any{
+			"role":     "assistant",
+			"content":  "",
+			"thinking": "Need the weather.",
+			"tool_calls": []any{map[string]any{"function": map[string]any{
+				"name":      "get_weather",
+				"arguments": map[string]any{"city": "Paris"},
+			}}},
+		},
+		"done":              true,
+		"done_reason":       "stop",
+		"prompt_eval_count": float64(12),
+		"eval_count":        float64(30),
+	}
+
+	chat := ollamaToChatCompletion(native)
+
+	assert.Equal(t, "chat.completion", chat["object"])
+	assert.Equal(t, int64(1754395200), chat["created"])
+	assert.Equal(t, map[string]any{"prompt_tokens": 12, "completion_tokens": 30, "total_tokens": 42}, chat["usage"])
+
+	choice := chat["choices"].([]any)[0].(map[string]any)
+	assert.Equal(t, "tool_calls", choice["finish_reason"])
+
+	message := choice["message"].(map[string]any)
+	assert.Equal(t, "Need the weather.", message["thinking"])
+
+	toolCall := message["tool_calls"].([]any)[0].(map[string]any)
+	assert.Regexp(t, `^call_[0-9a-f]{24}$`, toolCall["id"])
+	assert.NotContains(t, toolCall, "index")
+	assert.Equal(t, map[string]any{"name": "get_weather", "arguments": `{"city":"Paris"}`}, toolCall["function"])
+}
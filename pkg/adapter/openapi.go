@@ -0,0 +1,552 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+)
+
+// openAPIHeader describes an extension header accepted on chat requests.
+type openAPIHeader struct {
+	Name        string
+	Description string
+	Enum        []string
+}
+
+// chatHeaders lists the extension headers the adapter understands on chat
+// completion requests.
+var chatHeaders = []openAPIHeader{
+	{
+		Name:        conversationIDHeader,
+		Description: "Identifies the conversation for token budget accounting and reasoning chains. Defaults to a fingerprint of the system prompt and first user message.",
+	},
+	{
+		Name:        cacheModeHeader,
+		Description: "Whether the adapter may look up and store reasoning for this request: store, no-store to look it up without storing anything, or bypass to do neither. Defaults to --cache-mode.",
+		Enum:        []string{CacheModeStore, CacheModeNoStore, CacheModeBypass},
+	},
+	{
+		Name:        DefaultCacheNamespaceHeader,
+		Description: "Keeps cached reasoning apart per value, such as a session or user ID, when the adapter runs with --cache-namespace=header. The header's name is set with --cache-namespace-header.",
+	},
+	{
+		Name:        providerHeader,
+		Description: "Selects the provider, and its target, for this request when the adapter runs with --provider-routing.",
+	},
+	{
+		Name:        reasoningEffortHeader,
+		Description: "Reasoning effort to use when the request body does not set one. It is sent to the backend in the provider's format.",
+		Enum:        reasoningEfforts,
+	},
+	{
+		Name:        priorityHeader,
+		Description: "Priority of the request in the queue when the adapter runs with --max-concurrent: high (or interactive), normal or low (or batch). Capped at the priority of the API key. Defaults to the API key's priority, or normal.",
+		Enum:        []string{"high", "interactive", "normal", "low", "batch"},
+	},
+	{
+		Name:        "Accept",
+		Description: "Send application/x-ndjson to receive streamed responses as newline-delimited JSON instead of Server-Sent Events.",
+		Enum:        []string{"text/event-stream", "application/x-ndjson"},
+	},
+}
+
+func (a *Adapter) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "Method not allowed", "invalid_request_error", "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(a.openAPIDocument())
+}
+
+func (a *Adapter) openAPIDocument() map[string]any {
+	chatOperation := a.chatCompletionsOperation()
+	// Only chat completions are answered from the response cache.
+	chatOperation["responses"].(map[string]any)["200"].(map[string]any)["headers"] = map[string]any{
+		responseCacheHeader: map[string]any{
+			"description": "With --response-cache-ttl, hit when a deterministic non-streaming request was answered from the response cache and miss when it was sent to the backend. Absent for requests that cannot be cached.",
+			"schema":      map[string]any{"type": "string", "enum": []string{"hit", "miss"}},
+		},
+	}
+	responsesOperation := a.responsesOperation()
+	completionsOperation := a.completionsOperation()
+	embeddingsOperation := a.embeddingsOperation()
+
+	document := map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":       "gpt-oss-adapter",
+			"description": "Proxy that manages chain-of-thought reasoning for gpt-oss models. Requests to paths not listed here are passed through to the backend unchanged.",
+			"version":     Version,
+		},
+		"paths": map[string]any{
+			"/v1/chat/completions": map[string]any{"post": chatOperation},
+			"/chat/completions":    map[string]any{"post": unversioned(chatOperation)},
+			"/v1/responses":        map[string]any{"post": responsesOperation},
+			"/responses":           map[string]any{"post": unversioned(responsesOperation)},
+			"/v1/completions":      map[string]any{"post": completionsOperation},
+			"/completions":         map[string]any{"post": unversioned(completionsOperation)},
+			"/v1/messages":         map[string]any{"post": a.messagesOperation()},
+			"/v1/embeddings":       map[string]any{"post": embeddingsOperation},
+			"/embeddings":          map[string]any{"post": unversioned(embeddingsOperation)},
+			"/v1/messages/count_tokens": map[string]any{"post": map[string]any{
+				"summary":     "Count the input tokens of a message request",
+				"operationId": "countMessageTokens",
+				"requestBody": anyObjectRequestBody(),
+				"responses": map[string]any{
+					"200": map[string]any{
+						"description": "Estimated input token count",
+						"content": map[string]any{
+							"application/json": map[string]any{
+								"schema": map[string]any{
+									"type":       "object",
+									"properties": map[string]any{"input_tokens": map[string]any{"type": "integer"}},
+								},
+							},
+						},
+					},
+				},
+			}},
+			"/healthz": map[string]any{"get": healthOperation("getHealth", "Liveness probe", map[string]any{
+				"200": map[string]any{"description": "The adapter is running"},
+			})},
+			"/readyz": map[string]any{"get": healthOperation("getReadiness", "Readiness probe", map[string]any{
+				"200": map[string]any{"description": "The backend passed its most recent health check"},
+				"503": map[string]any{"description": "The backend is unavailable or has not been checked yet"},
+			})},
+			"/metrics": map[string]any{
+				"get": map[string]any{
+					"summary":     "Prometheus metrics",
+					"operationId": "getMetrics",
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "Metrics in the Prometheus text exposition format",
+							"content":     map[string]any{"text/plain": map[string]any{}},
+						},
+					},
+				},
+			},
+			"/openapi.json": map[string]any{
+				"get": map[string]any{
+					"summary":     "This document",
+					"operationId": "getOpenAPI",
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "OpenAPI description of the adapter",
+							"content":     map[string]any{"application/json": map[string]any{}},
+						},
+					},
+				},
+			},
+		},
+		"components": map[string]any{
+			"schemas": map[string]any{
+				"ChatCompletionRequest": chatCompletionRequestSchema(),
+				"CacheExportEntry":      cacheExportEntrySchema(),
+				"Error":                 openAIErrorSchema(),
+			},
+		},
+	}
+
+	if a.Ledger != nil {
+		document["paths"].(map[string]any)["/v1/usage"] = map[string]any{
+			"get": map[string]any{
+				"summary":     "Token usage of the calling API key, or of every key for the admin token",
+				"operationId": "getUsage",
+				"parameters":  []any{queryParameter("model", "Only report usage of this model", "string")},
+				"responses": map[string]any{
+					"200": map[string]any{
+						"description": "Usage counters per client and model",
+						"content":     map[string]any{"application/json": map[string]any{}},
+					},
+				},
+			},
+		}
+	}
+
+	if a.AdminToken != "" {
+		paths := document["paths"].(map[string]any)
+		security := []any{map[string]any{"adminToken": []any{}}}
+		paths["/admin/cache"] = map[string]any{
+			"get": adminOperation("listCacheEntries", "List cached reasoning entries", security, []any{
+				queryParameter("prefix", "Only list keys starting with this prefix", "string"),
+				queryParameter("limit", "Maximum number of entries to return", "integer"),
+			}),
+			"delete": adminOperation("invalidateCache", "Invalidate one key, all keys with a prefix, or the whole cache", security, []any{
+				queryParameter("key", "Key to remove", "string"),
+				queryParameter("prefix", "Remove all keys starting with this prefix", "string"),
+			}),
+		}
+		paths["/admin/cache/stats"] = map[string]any{
+			"get": adminOperation("getCacheStats", "Cache entry count, size, hits, misses, inserts and evictions", security, nil),
+		}
+		paths["/admin/cache/import"] = map[string]any{"post": cacheImportOperation(security)}
+		export := adminOperation("exportCache", "Stream cached reasoning entries as JSON lines, in listing order", security, []any{
+			queryParameter("prefix", "Only export keys starting with this prefix", "string"),
+		})
+		export["responses"].(map[string]any)["200"] = map[string]any{
+			"description": "One cache entry per line, which /admin/cache/import accepts as is",
+			"content": map[string]any{
+				exportContentType: map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/CacheExportEntry"}},
+			},
+		}
+		paths["/admin/cache/export"] = map[string]any{"get": export}
+		tap := adminOperation("tapStreams", "Mirror in-flight streaming responses as server-sent events", security, nil)
+		tap["responses"] = map[string]any{
+			"200": map[string]any{
+				"description": "Start, upstream, client and end events of each stream",
+				"content":     map[string]any{"text/event-stream": map[string]any{}},
+			},
+			"401": map[string]any{"description": "Missing or invalid admin token"},
+		}
+		paths["/admin/tap"] = map[string]any{"get": tap}
+		if a.DebugRecent > 0 {
+			paths["/debug/recent"] = map[string]any{
+				"get": adminOperation("listRecentExchanges", "Recent chat completions exchanges before and after transformation, newest first", security, []any{
+					queryParameter("limit", "Maximum number of exchanges to return", "integer"),
+				}),
+			}
+		}
+		if a.DebugTransform {
+			transform := adminOperation("previewChatTransform", "Return a chat completions request as it would be sent to the target, without sending it", security, nil)
+			transform["requestBody"] = map[string]any{
+				"required": true,
+				"content": map[string]any{
+					"application/json": map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/ChatCompletionRequest"}},
+				},
+			}
+			responses := transform["responses"].(map[string]any)
+			responses["200"] = map[string]any{
+				"description": "The target URL and the transformed request body, with restored reasoning",
+				"content":     map[string]any{"application/json": map[string]any{}},
+			}
+			responses["400"] = map[string]any{"description": "The request body is not a JSON object"}
+			delete(responses, "501")
+			paths["/debug/transform"] = map[string]any{"post": transform}
+		}
+		securitySchemes(document)["adminToken"] = map[string]any{"type": "http", "scheme": "bearer"}
+	}
+
+	if a.APIKeys != nil {
+		securitySchemes(document)["apiKey"] = map[string]any{"type": "http", "scheme": "bearer"}
+		document["security"] = []any{map[string]any{"apiKey": []any{}}}
+
+		// Health checks and metrics are served without a key.
+		paths := document["paths"].(map[string]any)
+		for _, path := range []string{"/healthz", "/readyz", "/metrics"} {
+			paths[path].(map[string]any)["get"].(map[string]any)["security"] = []any{}
+		}
+	}
+
+	return document
+}
+
+// unversioned returns a copy of the operation of a /v1 path for the same
+// path without the prefix. Operation IDs must be unique in the document.
+func unversioned(operation map[string]any) map[string]any {
+	alias := maps.Clone(operation)
+	alias["operationId"] = operation["operationId"].(string) + "Unversioned"
+	return alias
+}
+
+// securitySchemes returns the document's security schemes, adding the
+// section if needed.
+func securitySchemes(document map[string]any) map[string]any {
+	components := document["components"].(map[string]any)
+	schemes, ok := components["securitySchemes"].(map[string]any)
+	if !ok {
+		schemes = make(map[string]any)
+		components["securitySchemes"] = schemes
+	}
+	return schemes
+}
+
+func adminOperation(operationID, summary string, security []any, parameters []any) map[string]any {
+	operation := map[string]any{
+		"summary":     summary,
+		"operationId": operationID,
+		"security":    security,
+		"responses": map[string]any{
+			"200": map[string]any{
+				"description": "Success",
+				"content":     map[string]any{"application/json": map[string]any{}},
+			},
+			"401": map[string]any{"description": "Missing or invalid admin token"},
+			"403": map[string]any{"description": "A client API key was sent instead of the admin token"},
+			"501": map[string]any{"description": "The cache backend does not support inspection"},
+		},
+	}
+	if len(parameters) > 0 {
+		operation["parameters"] = parameters
+	}
+	return operation
+}
+
+func cacheImportOperation(security []any) map[string]any {
+	operation := adminOperation("importCache", "Cache the reasoning of a conversation's assistant messages, or store the entries of a cache export", security, nil)
+	operation["requestBody"] = map[string]any{
+		"required": true,
+		"content": map[string]any{
+			"application/json": map[string]any{
+				"schema": map[string]any{
+					"type":     "object",
+					"required": []string{"messages"},
+					"properties": map[string]any{
+						"namespace": map[string]any{"type": "string", "description": "Namespace to import into, as shown in cache keys"},
+						"messages": map[string]any{
+							"type":        "array",
+							"description": "Chat messages; the reasoning of assistant messages is cached under their tool call IDs or plain turn tags",
+							"items":       map[string]any{"type": "object"},
+						},
+					},
+				},
+			},
+			exportContentType: map[string]any{
+				"schema": map[string]any{"$ref": "#/components/schemas/CacheExportEntry"},
+			},
+		},
+	}
+	responses := operation["responses"].(map[string]any)
+	responses["200"] = map[string]any{
+		"description": "Number of messages or entries imported, and of messages skipped",
+		"content": map[string]any{
+			"application/json": map[string]any{
+				"schema": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"imported": map[string]any{"type": "integer"},
+						"skipped":  map[string]any{"type": "integer"},
+					},
+				},
+			},
+		},
+	}
+	responses["400"] = map[string]any{
+		"description": "Invalid conversation or export entry. The entries of an export before the invalid one are already stored, and the error message says how many.",
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/Error"}},
+		},
+	}
+	// Imports do not need a cache that can be inspected.
+	delete(responses, "501")
+	return operation
+}
+
+func cacheExportEntrySchema() map[string]any {
+	return map[string]any{
+		"type":     "object",
+		"required": []string{"key"},
+		"properties": map[string]any{
+			"key":       map[string]any{"type": "string"},
+			"id":        map[string]any{"type": "string"},
+			"content":   map[string]any{"type": "string"},
+			"created":   map[string]any{"type": "string", "format": "date-time"},
+			"last_used": map[string]any{"type": "string", "format": "date-time"},
+		},
+	}
+}
+
+func queryParameter(name, description, schemaType string) map[string]any {
+	return map[string]any{
+		"name":        name,
+		"in":          "query",
+		"required":    false,
+		"description": description,
+		"schema":      map[string]any{"type": schemaType},
+	}
+}
+
+func (a *Adapter) chatCompletionsOperation() map[string]any {
+	var parameters []any
+	for _, header := range chatHeaders {
+		if header.Name == DefaultCacheNamespaceHeader && a.CacheNamespaceHeader != "" {
+			header.Name = a.CacheNamespaceHeader
+		}
+		schema := map[string]any{"type": "string"}
+		if len(header.Enum) > 0 {
+			schema["enum"] = header.Enum
+		}
+		parameters = append(parameters, map[string]any{
+			"name":        header.Name,
+			"in":          "header",
+			"required":    false,
+			"description": header.Description,
+			"schema":      schema,
+		})
+	}
+
+	errorResponse := func(description string) map[string]any {
+		return map[string]any{
+			"description": description,
+			"content": map[string]any{
+				"application/json": map[string]any{
+					"schema": map[string]any{"$ref": "#/components/schemas/Error"},
+				},
+			},
+		}
+	}
+
+	return map[string]any{
+		"summary":     "Create a chat completion",
+		"description": "OpenAI-compatible chat completions. Cached reasoning is injected into prior assistant tool-call turns and reasoning.effort is mapped to the " + a.Provider.Name + " provider format.",
+		"operationId": "createChatCompletion",
+		"parameters":  parameters,
+		"requestBody": map[string]any{
+			"required": true,
+			"content": map[string]any{
+				"application/json": map[string]any{
+					"schema": map[string]any{"$ref": "#/components/schemas/ChatCompletionRequest"},
+				},
+			},
+		},
+		"responses": map[string]any{
+			"200": map[string]any{
+				"description": "Chat completion, streamed when stream is true",
+				"content": map[string]any{
+					"application/json":     map[string]any{},
+					"text/event-stream":    map[string]any{},
+					"application/x-ndjson": map[string]any{},
+				},
+			},
+			"400": errorResponse("Request rejected, e.g. by moderation"),
+			"429": errorResponse("Model limit or conversation token budget exceeded"),
+			"502": errorResponse("Backend unreachable"),
+		},
+	}
+}
+
+func (a *Adapter) responsesOperation() map[string]any {
+	operation := a.chatCompletionsOperation()
+	operation["summary"] = "Create a model response"
+	operation["description"] = "OpenAI Responses API, translated to chat completions for the backend. Reasoning items are cached by ID and restored when echoed back."
+	operation["operationId"] = "createResponse"
+	operation["requestBody"] = anyObjectRequestBody()
+	return operation
+}
+
+func (a *Adapter) completionsOperation() map[string]any {
+	operation := a.chatCompletionsOperation()
+	operation["summary"] = "Create a text completion"
+	operation["description"] = "Legacy text completions, passed through to the backend. The reasoning effort is set in the system message of Harmony-formatted prompts, or mapped to the " + a.Provider.Name + " provider format otherwise."
+	operation["operationId"] = "createCompletion"
+	operation["requestBody"] = anyObjectRequestBody()
+	return operation
+}
+
+func (a *Adapter) messagesOperation() map[string]any {
+	operation := a.chatCompletionsOperation()
+	operation["summary"] = "Create a message"
+	operation["description"] = "Anthropic Messages API, translated to chat completions for the backend. Reasoning is returned as thinking blocks when thinking is enabled."
+	operation["operationId"] = "createMessage"
+	operation["requestBody"] = anyObjectRequestBody()
+	return operation
+}
+
+func (a *Adapter) embeddingsOperation() map[string]any {
+	description := "OpenAI-compatible embeddings, passed through to the backend."
+	if a.EmbeddingsBatchSize > 0 {
+		description += fmt.Sprintf(" Requests with more than %d inputs are split into batches of that size, and the embeddings merged in the order of the inputs.", a.EmbeddingsBatchSize)
+	}
+	return map[string]any{
+		"summary":     "Create embeddings",
+		"description": description,
+		"operationId": "createEmbedding",
+		"requestBody": anyObjectRequestBody(),
+		"responses": map[string]any{
+			"200": map[string]any{
+				"description": "Embeddings of the inputs",
+				"content":     map[string]any{"application/json": map[string]any{}},
+			},
+			"502": map[string]any{
+				"description": "Backend unreachable",
+				"content": map[string]any{
+					"application/json": map[string]any{
+						"schema": map[string]any{"$ref": "#/components/schemas/Error"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func anyObjectRequestBody() map[string]any {
+	return map[string]any{
+		"required": true,
+		"content": map[string]any{
+			"application/json": map[string]any{
+				"schema": map[string]any{"type": "object", "additionalProperties": true},
+			},
+		},
+	}
+}
+
+func healthOperation(operationID, summary string, responses map[string]any) map[string]any {
+	for _, response := range responses {
+		response.(map[string]any)["content"] = map[string]any{
+			"application/json": map[string]any{
+				"schema": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"status": map[string]any{"type": "string"},
+						"error":  map[string]any{"type": "string"},
+					},
+				},
+			},
+		}
+	}
+	return map[string]any{
+		"summary":     summary,
+		"operationId": operationID,
+		"responses":   responses,
+	}
+}
+
+func chatCompletionRequestSchema() map[string]any {
+	return map[string]any{
+		"type":     "object",
+		"required": []string{"messages"},
+		"properties": map[string]any{
+			"model": map[string]any{"type": "string"},
+			"messages": map[string]any{
+				"type":  "array",
+				"items": map[string]any{"type": "object"},
+			},
+			"stream": map[string]any{"type": "boolean"},
+			"tools": map[string]any{
+				"type":  "array",
+				"items": map[string]any{"type": "object"},
+			},
+			"reasoning": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"effort": map[string]any{
+						"type": "string",
+						"enum": []string{"low", "medium", "high"},
+					},
+				},
+			},
+		},
+		"additionalProperties": true,
+	}
+}
+
+func openAIErrorSchema() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"error": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"message": map[string]any{"type": "string"},
+					"type":    map[string]any{"type": "string"},
+					"code":    map[string]any{"type": "string"},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,173 @@
+package adapter
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+)
+
+// muxRoutes returns the patterns newMux registers on the adapter's mux,
+// read from the source so that a new route cannot be missed.
+func muxRoutes(t *testing.T) []string {
+	t.Helper()
+	file, err := parser.ParseFile(token.NewFileSet(), "adapter.go", nil, 0)
+	require.NoError(t, err)
+
+	var routes []string
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Name.Name != "newMux" {
+			continue
+		}
+		ast.Inspect(fn.Body, func(node ast.Node) bool {
+			call, ok := node.(*ast.CallExpr)
+			if !ok || len(call.Args) == 0 {
+				return true
+			}
+			selector, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok || selector.Sel.Name != "HandleFunc" {
+				return true
+			}
+			if receiver, ok := selector.X.(*ast.Ident); !ok || receiver.Name != "mux" {
+				return true
+			}
+			if literal, ok := call.Args[0].(*ast.BasicLit); ok {
+				pattern, err := strconv.Unquote(literal.Value)
+				require.NoError(t, err)
+				routes = append(routes, pattern)
+			}
+			return true
+		})
+	}
+	require.NotEmpty(t, routes)
+	return routes
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter("http://localhost:8080", NewLRUCache(10), logger, llamacpp.NewProvider())
+	adapter.AdminToken = "secret"
+	adapter.DebugTransform = true
+	adapter.DebugRecent = 10
+	adapter.Ledger = NewUsageLedger()
+
+	r := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var document map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &document))
+	assert.Equal(t, "3.1.0", document["openapi"])
+	info := document["info"].(map[string]any)
+	assert.NotEmpty(t, info["title"])
+	assert.NotEmpty(t, info["version"])
+
+	paths := document["paths"].(map[string]any)
+	for _, route := range muxRoutes(t) {
+		if route == "/" {
+			// Everything else is passed through.
+			continue
+		}
+		assert.Contains(t, paths, route, "served path is not documented")
+	}
+
+	schemas := document["components"].(map[string]any)["schemas"].(map[string]any)
+	operationIDs := make(map[string]string)
+	for path, item := range paths {
+		assert.True(t, strings.HasPrefix(path, "/"), path)
+		for method, op := range item.(map[string]any) {
+			assert.Contains(t, []string{"get", "head", "post", "put", "delete"}, method, path)
+			operation := op.(map[string]any)
+
+			id, _ := operation["operationId"].(string)
+			require.NotEmpty(t, id, "%s %s has no operationId", method, path)
+			if other, ok := operationIDs[id]; ok {
+				t.Errorf("operationId %q of %s %s is also used by %s", id, method, path, other)
+			}
+			operationIDs[id] = method + " " + path
+
+			assert.NotEmpty(t, operation["summary"], "%s %s", method, path)
+			assert.NotEmpty(t, operation["responses"], "%s %s", method, path)
+			for status, response := range operation["responses"].(map[string]any) {
+				_, err := strconv.Atoi(status)
+				assert.NoError(t, err, "%s %s", method, path)
+				assert.NotEmpty(t, response.(map[string]any)["description"], "%s %s %s", method, path, status)
+			}
+			parameters, _ := operation["parameters"].([]any)
+			for _, p := range parameters {
+				parameter := p.(map[string]any)
+				assert.Contains(t, []string{"header", "query"}, parameter["in"], "%s %s", method, path)
+				assert.NotEmpty(t, parameter["name"], "%s %s", method, path)
+			}
+		}
+	}
+
+	// Every schema reference resolves.
+	var refs []string
+	var collect func(value any)
+	collect = func(value any) {
+		switch v := value.(type) {
+		case map[string]any:
+			if ref, ok := v["$ref"].(string); ok {
+				refs = append(refs, ref)
+			}
+			for _, child := range v {
+				collect(child)
+			}
+		case []any:
+			for _, child := range v {
+				collect(child)
+			}
+		}
+	}
+	collect(document)
+	require.NotEmpty(t, refs)
+	for _, ref := range refs {
+		name, ok := strings.CutPrefix(ref, "#/components/schemas/")
+		if assert.True(t, ok, ref) {
+			assert.Contains(t, schemas, name, ref)
+		}
+	}
+
+	// The extension headers of chat requests and responses are documented.
+	chat := paths["/v1/chat/completions"].(map[string]any)["post"].(map[string]any)
+	var headers []string
+	for _, p := range chat["parameters"].([]any) {
+		headers = append(headers, p.(map[string]any)["name"].(string))
+	}
+	for _, header := range []string{conversationIDHeader, cacheModeHeader, DefaultCacheNamespaceHeader, providerHeader, reasoningEffortHeader, priorityHeader} {
+		assert.Contains(t, headers, header)
+	}
+	responseHeaders := chat["responses"].(map[string]any)["200"].(map[string]any)["headers"].(map[string]any)
+	assert.Contains(t, responseHeaders, responseCacheHeader)
+}
+
+func TestOpenAPIDocument_NamespaceHeader(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter("http://localhost:8080", NewLRUCache(10), logger, llamacpp.NewProvider())
+	adapter.CacheNamespaceHeader = "X-User-ID"
+
+	document := adapter.openAPIDocument()
+	chat := document["paths"].(map[string]any)["/v1/chat/completions"].(map[string]any)["post"].(map[string]any)
+	var headers []string
+	for _, p := range chat["parameters"].([]any) {
+		headers = append(headers, p.(map[string]any)["name"].(string))
+	}
+	assert.Contains(t, headers, "X-User-ID")
+	assert.NotContains(t, headers, DefaultCacheNamespaceHeader)
+}
@@ -0,0 +1,119 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type cacheFileEntry struct {
+	Key     string    `json:"key"`
+	ID      string    `json:"id"`
+	Content string    `json:"content"`
+	Expires time.Time `json:"expires,omitzero"`
+}
+
+// SaveFile writes the cache contents to path, least recently used first, so
+// that LoadFile restores the same eviction order. The file is replaced
+// atomically to avoid leaving a truncated snapshot behind on a crash.
+func (c *LRUCache) SaveFile(path string) error {
+	now := time.Now()
+
+	c.mutex.RLock()
+	entries := make([]cacheFileEntry, 0, c.list.Len())
+	for elem := c.list.Back(); elem != nil; elem = elem.Prev() {
+		entry := elem.Value.(*cacheEntry)
+		if c.expired(entry, now) {
+			continue
+		}
+		entries = append(entries, cacheFileEntry{
+			Key:     entry.key,
+			ID:      entry.item.ID,
+			Content: entry.item.Content,
+			Expires: entry.expires,
+		})
+	}
+	c.mutex.RUnlock()
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadFile adds the unexpired entries of a snapshot written by SaveFile to
+// the cache and returns how many were loaded. A missing file is not an error.
+func (c *LRUCache) LoadFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var entries []cacheFileEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return 0, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	now := time.Now()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	loaded := 0
+	for _, entry := range entries {
+		expires := entry.Expires
+		if c.ttl <= 0 {
+			expires = time.Time{}
+		} else if expires.IsZero() || expires.After(c.expiry(now)) {
+			expires = c.expiry(now)
+		}
+
+		if !expires.IsZero() && !now.Before(expires) {
+			continue
+		}
+		c.put(entry.Key, ReasoningItem{ID: entry.ID, Content: entry.Content}, expires)
+		loaded++
+	}
+	return loaded, nil
+}
+
+// PersistCache snapshots the cache to path every interval until ctx is done.
+func PersistCache(ctx context.Context, cache *LRUCache, path string, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := cache.SaveFile(path); err != nil {
+				logger.Error("failed to save cache", "path", path, "error", err)
+			}
+		}
+	}
+}
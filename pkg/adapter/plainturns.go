@@ -0,0 +1,161 @@
+package adapter
+
+import "regexp"
+
+// Plain turns are assistant messages without tool calls. Their reasoning has
+// no tool call ID to be cached under, so the adapter attaches a synthetic ID
+// to the message it returns and restores the reasoning when the client sends
+// that message back.
+const (
+	// PlainTurnsOff caches reasoning for tool call turns only.
+	PlainTurnsOff = "off"
+	// PlainTurnsField attaches the ID as a reasoning_id field on the message.
+	// This only works with clients that echo unknown message fields back.
+	PlainTurnsField = "field"
+	// PlainTurnsMarker appends the ID to the message content as an HTML
+	// comment, which survives clients that keep only the content.
+	PlainTurnsMarker = "marker"
+)
+
+const plainTurnIDField = "reasoning_id"
+
+var plainTurnMarker = regexp.MustCompile(`\s*<!-- reasoning:(rsn_[0-9a-f]+) -->\s*$`)
+
+// plainTurnsEnabled reports whether reasoning is cached for plain turns.
+func (a *Adapter) plainTurnsEnabled() bool {
+	return a.PlainTurns != "" && a.PlainTurns != PlainTurnsOff
+}
+
+// cachePlainTurns caches the reasoning of every choice without tool calls in
+// a chat completion and tags its message with the ID it was cached under.
+func (a *Adapter) cachePlainTurns(namespace string, responseData map[string]any) {
+	forEachChoice(responseData, "message", func(index int, message map[string]any) {
+		if toolCalls, ok := message["tool_calls"].([]any); ok && len(toolCalls) > 0 {
+			return
+		}
+
+		reasoningContent, ok := a.extractReasoning(message)
+		if !ok || reasoningContent == "" {
+			return
+		}
+
+		a.tagPlainTurn(message, a.cachePlainTurn(namespace, reasoningContent))
+	})
+}
+
+// tagStreamedPlainTurns tags the final delta of every choice that finishes
+// in a stream chunk without having produced tool calls, caching its
+// reasoning. It reports whether the chunk was modified.
+func (a *Adapter) tagStreamedPlainTurns(namespace string, eventData map[string]any, reasoning map[int]*choiceReasoning) bool {
+	modified := false
+
+	choices, _ := eventData["choices"].([]any)
+	for i, c := range choices {
+		choice, ok := c.(map[string]any)
+		if !ok {
+			continue
+		}
+		if reason, _ := choice["finish_reason"].(string); reason == "" {
+			continue
+		}
+
+		index := i
+		if n, ok := choice["index"].(float64); ok {
+			index = int(n)
+		}
+
+		accumulated, ok := reasoning[index]
+		if !ok || len(accumulated.toolCalls) > 0 {
+			continue
+		}
+
+		reasoningContent, ok := a.streamedReasoning(accumulated)
+		if !ok || reasoningContent == "" {
+			continue
+		}
+		delete(reasoning, index)
+
+		delta, ok := choice["delta"].(map[string]any)
+		if !ok {
+			delta = make(map[string]any)
+			choice["delta"] = delta
+		}
+		a.tagPlainTurn(delta, a.cachePlainTurn(namespace, reasoningContent))
+		modified = true
+	}
+
+	return modified
+}
+
+func (a *Adapter) cachePlainTurn(namespace, reasoningContent string) string {
+	id := newResponsesID("rsn")
+	a.storeReasoning(namespace, id, ReasoningItem{ID: id, Content: reasoningContent})
+	a.logger.Info("cached reasoning content", "reasoning_id", id, "content_length", len(reasoningContent))
+	return id
+}
+
+// tagPlainTurn attaches id to a message or the final delta of a streamed
+// message. In marker mode, the marker is appended to whatever content the
+// delta carries.
+func (a *Adapter) tagPlainTurn(message map[string]any, id string) {
+	if a.PlainTurns == PlainTurnsMarker {
+		content, _ := message["content"].(string)
+		message["content"] = content + "\n\n<!-- reasoning:" + id + " -->"
+		return
+	}
+	message[plainTurnIDField] = id
+}
+
+// restorePlainTurn removes the tag from an assistant message sent back by a
+// client and injects the reasoning cached under it. It reports whether
+// reasoning was injected.
+func (a *Adapter) restorePlainTurn(namespace string, message map[string]any) bool {
+	id := takePlainTurnID(message)
+	if id == "" {
+		return false
+	}
+
+	item, found := a.cache.Get(namespace, id)
+	if !found {
+		a.logger.Debug("reasoning for plain turn not found in cache", "reasoning_id", id)
+		return false
+	}
+
+	a.restoreReasoning(message, item.Content)
+	a.logger.Debug("injected reasoning content from cache", "reasoning_id", id, "field", a.Provider.Reasoning)
+	return true
+}
+
+// takePlainTurnID removes the tag from an assistant message and returns its
+// ID, or an empty string if the message has none. Tags are removed in both
+// modes, so that switching modes does not leak them to the backend.
+func takePlainTurnID(message map[string]any) string {
+	var id string
+
+	if value, ok := message[plainTurnIDField]; ok {
+		id, _ = value.(string)
+		delete(message, plainTurnIDField)
+	}
+
+	if content, ok := message["content"].(string); ok {
+		if match := plainTurnMarker.FindStringSubmatchIndex(content); match != nil {
+			if id == "" {
+				id = content[match[2]:match[3]]
+			}
+			message["content"] = content[:match[0]]
+		}
+	}
+
+	return id
+}
+
+// stripPlainTurnTags removes the tags from every assistant message of a
+// request whose reasoning is not looked up.
+func stripPlainTurnTags(requestData map[string]any) {
+	messages, _ := requestData["messages"].([]any)
+	for _, msg := range messages {
+		if message, ok := msg.(map[string]any); ok && message["role"] == "assistant" {
+			takePlainTurnID(message)
+		}
+	}
+}
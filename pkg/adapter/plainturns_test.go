@@ -0,0 +1,124 @@
+package adapter
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlainTurns_Field(t *testing.T) {
+	adapter := newTestAdapter()
+	adapter.PlainTurns = PlainTurnsField
+
+	response := map[string]any{
+		"choices": []any{map[string]any{
+			"message": map[string]any{"role": "assistant", "content": "Hello!", "reasoning_content": "Greet the user."},
+		}},
+	}
+	adapter.cachePlainTurns("", response)
+
+	message := response["choices"].([]any)[0].(map[string]any)["message"].(map[string]any)
+	id, ok := message["reasoning_id"].(string)
+	require.True(t, ok)
+	assert.True(t, strings.HasPrefix(id, "rsn_"))
+	assert.Equal(t, "Hello!", message["content"])
+
+	echoed := map[string]any{"role": "assistant", "content": "Hello!", "reasoning_id": id}
+	adapter.injectReasoningFromCache("", map[string]any{"messages": []any{echoed}})
+
+	assert.Equal(t, "Greet the user.", echoed["reasoning_content"])
+	assert.NotContains(t, echoed, "reasoning_id")
+}
+
+func TestPlainTurns_Marker(t *testing.T) {
+	adapter := newTestAdapter()
+	adapter.PlainTurns = PlainTurnsMarker
+
+	response := map[string]any{
+		"choices": []any{map[string]any{
+			"message": map[string]any{"role": "assistant", "content": "Hello!", "reasoning_content": "Greet the user."},
+		}},
+	}
+	adapter.cachePlainTurns("", response)
+
+	content := response["choices"].([]any)[0].(map[string]any)["message"].(map[string]any)["content"].(string)
+	assert.Regexp(t, `^Hello!\n\n<!-- reasoning:rsn_[0-9a-f]+ -->$`, content)
+
+	echoed := map[string]any{"role": "assistant", "content": content}
+	adapter.injectReasoningFromCache("", map[string]any{"messages": []any{echoed}})
+
+	assert.Equal(t, "Greet the user.", echoed["reasoning_content"])
+	assert.Equal(t, "Hello!", echoed["content"])
+}
+
+func TestPlainTurns_SkipsToolCalls(t *testing.T) {
+	adapter := newTestAdapter()
+	adapter.PlainTurns = PlainTurnsField
+
+	response := map[string]any{
+		"choices": []any{map[string]any{
+			"message": map[string]any{
+				"role":              "assistant",
+				"reasoning_content": "Call the tool.",
+				"tool_calls":        []any{map[string]any{"id": "call_1", "function": map[string]any{"name": "f", "arguments": "{}"}}},
+			},
+		}},
+	}
+	adapter.cachePlainTurns("", response)
+
+	assert.NotContains(t, response["choices"].([]any)[0].(map[string]any)["message"], "reasoning_id")
+}
+
+func TestPlainTurns_Stream(t *testing.T) {
+	adapter := newTestAdapter()
+	adapter.PlainTurns = PlainTurnsMarker
+
+	stream := strings.Join([]string{
+		`data: {"choices":[{"index":0,"delta":{"reasoning_content":"Greet the user."}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"content":"Hello!"}}]}`,
+		`data: {"choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+		`data: [DONE]`,
+		``,
+	}, "\n\n")
+
+	req, _ := http.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	resp := &http.Response{Body: io.NopCloser(strings.NewReader(stream)), Request: req}
+
+	var lines []string
+	adapter.relayChatStream(resp, &chatRequest{data: map[string]any{}, plainTurns: true}, func(line string) {
+		lines = append(lines, line)
+	})
+
+	var content string
+	for _, line := range lines {
+		var chunk map[string]any
+		if json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &chunk) != nil {
+			continue
+		}
+		forEachChoice(chunk, "delta", func(_ int, delta map[string]any) {
+			text, _ := delta["content"].(string)
+			content += text
+		})
+	}
+	match := plainTurnMarker.FindStringSubmatch(content)
+	require.NotNil(t, match, content)
+	assert.True(t, strings.HasPrefix(content, "Hello!\n\n<!-- reasoning:"))
+
+	item, found := adapter.cache.Get("", match[1])
+	require.True(t, found)
+	assert.Equal(t, "Greet the user.", item.Content)
+}
+
+func TestPlainTurns_Disabled(t *testing.T) {
+	adapter := newTestAdapter()
+
+	echoed := map[string]any{"role": "assistant", "content": "Hello!", "reasoning_id": "rsn_0"}
+	adapter.injectReasoningFromCache("", map[string]any{"messages": []any{echoed}})
+
+	assert.Equal(t, "rsn_0", echoed["reasoning_id"])
+}
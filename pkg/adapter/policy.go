@@ -0,0 +1,182 @@
+package adapter
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EffortSignals are the request attributes an effort policy can match on.
+type EffortSignals struct {
+	Model        string
+	HasTools     bool
+	PromptTokens int
+	APIKey       string
+	Time         time.Time
+	InFlight     int
+}
+
+type effortCondition func(s EffortSignals) bool
+
+// EffortRule selects a reasoning effort when all of its conditions match.
+type EffortRule struct {
+	Source     string
+	Effort     string
+	conditions []effortCondition
+}
+
+// EffortPolicy picks a reasoning effort for each request from an ordered
+// list of rules. The first matching rule wins.
+type EffortPolicy struct {
+	Rules    []EffortRule
+	Override bool
+}
+
+func NewEffortPolicy(rules []string, override bool) (*EffortPolicy, error) {
+	policy := &EffortPolicy{Override: override}
+	for _, source := range rules {
+		rule, err := ParseEffortRule(source)
+		if err != nil {
+			return nil, err
+		}
+		policy.Rules = append(policy.Rules, rule)
+	}
+	return policy, nil
+}
+
+// Choose returns the effort of the first rule matching the signals, or an
+// empty string if no rule matches.
+func (p *EffortPolicy) Choose(s EffortSignals) (string, *EffortRule) {
+	for i := range p.Rules {
+		if p.Rules[i].Matches(s) {
+			return p.Rules[i].Effort, &p.Rules[i]
+		}
+	}
+	return "", nil
+}
+
+func (r *EffortRule) Matches(s EffortSignals) bool {
+	for _, cond := range r.conditions {
+		if !cond(s) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseEffortRule parses a rule of the form "cond,cond,...:effort", where
+// effort is low, medium or high. Supported conditions are:
+//
+//	model=<glob>      model name matches the glob
+//	tools / !tools    request does or does not define tools
+//	prompt>N, prompt<N  estimated prompt tokens above or below N
+//	key=<token>       client bearer token equals token
+//	hours=H-H         local hour of day within [start, end)
+//	inflight>N, inflight<N  concurrent chat requests above or below N
+//
+// A rule with no conditions ("*:low") always matches.
+func ParseEffortRule(source string) (EffortRule, error) {
+	idx := strings.LastIndex(source, ":")
+	if idx == -1 {
+		return EffortRule{}, fmt.Errorf("effort rule %q: missing \":effort\"", source)
+	}
+
+	rule := EffortRule{
+		Source: source,
+		Effort: strings.TrimSpace(source[idx+1:]),
+	}
+	if rule.Effort == "" {
+		return EffortRule{}, fmt.Errorf("effort rule %q: empty effort", source)
+	}
+	if !ValidReasoningEffort(rule.Effort) {
+		return EffortRule{}, fmt.Errorf("effort rule %q: invalid effort %q (want %s)", source, rule.Effort, strings.Join(reasoningEfforts, ", "))
+	}
+
+	for _, part := range strings.Split(source[:idx], ",") {
+		part = strings.TrimSpace(part)
+		if part == "" || part == "*" {
+			continue
+		}
+
+		cond, err := parseEffortCondition(part)
+		if err != nil {
+			return EffortRule{}, fmt.Errorf("effort rule %q: %w", source, err)
+		}
+		rule.conditions = append(rule.conditions, cond)
+	}
+
+	return rule, nil
+}
+
+func parseEffortCondition(part string) (effortCondition, error) {
+	switch {
+	case part == "tools":
+		return func(s EffortSignals) bool { return s.HasTools }, nil
+	case part == "!tools":
+		return func(s EffortSignals) bool { return !s.HasTools }, nil
+	case strings.HasPrefix(part, "model="):
+		pattern := strings.TrimPrefix(part, "model=")
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid model pattern %q", pattern)
+		}
+		return func(s EffortSignals) bool {
+			matched, _ := path.Match(pattern, s.Model)
+			return matched
+		}, nil
+	case strings.HasPrefix(part, "key="):
+		key := strings.TrimPrefix(part, "key=")
+		return func(s EffortSignals) bool { return s.APIKey == key }, nil
+	case strings.HasPrefix(part, "hours="):
+		start, end, ok := strings.Cut(strings.TrimPrefix(part, "hours="), "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid hours range %q", part)
+		}
+		from, err := strconv.Atoi(start)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hours range %q", part)
+		}
+		to, err := strconv.Atoi(end)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hours range %q", part)
+		}
+		return func(s EffortSignals) bool {
+			hour := s.Time.Hour()
+			if from <= to {
+				return hour >= from && hour < to
+			}
+			return hour >= from || hour < to
+		}, nil
+	case strings.HasPrefix(part, "prompt"):
+		return parseThreshold(strings.TrimPrefix(part, "prompt"), func(s EffortSignals) int { return s.PromptTokens })
+	case strings.HasPrefix(part, "inflight"):
+		return parseThreshold(strings.TrimPrefix(part, "inflight"), func(s EffortSignals) int { return s.InFlight })
+	}
+
+	return nil, fmt.Errorf("unknown condition %q", part)
+}
+
+func parseThreshold(expr string, value func(EffortSignals) int) (effortCondition, error) {
+	if len(expr) < 2 || (expr[0] != '>' && expr[0] != '<') {
+		return nil, fmt.Errorf("invalid threshold %q", expr)
+	}
+
+	n, err := strconv.Atoi(expr[1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid threshold %q", expr)
+	}
+
+	if expr[0] == '>' {
+		return func(s EffortSignals) bool { return value(s) > n }, nil
+	}
+	return func(s EffortSignals) bool { return value(s) < n }, nil
+}
+
+// bearerToken extracts the token from an Authorization: Bearer header.
+func bearerToken(header string) string {
+	if len(header) > 7 && strings.EqualFold(header[:7], "bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
@@ -0,0 +1,73 @@
+package adapter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEffortRule_Invalid(t *testing.T) {
+	tests := []string{
+		"tools",
+		"tools:",
+		"bogus:high",
+		"prompt=5:low",
+		"hours=9:low",
+		"model=[:low",
+		"model=*:hgih",
+		"*:HIGH",
+	}
+
+	for _, source := range tests {
+		t.Run(source, func(t *testing.T) {
+			_, err := ParseEffortRule(source)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestEffortPolicy_Choose(t *testing.T) {
+	policy, err := NewEffortPolicy([]string{
+		"inflight>4:low",
+		"tools,model=gpt-oss-120b:high",
+		"prompt>8000:low",
+		"hours=22-6:high",
+		"key=secret:high",
+	}, false)
+	require.NoError(t, err)
+
+	noon := time.Date(2025, 1, 1, 12, 0, 0, 0, time.Local)
+	midnight := time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name     string
+		signals  EffortSignals
+		expected string
+	}{
+		{"under load", EffortSignals{Model: "gpt-oss-120b", HasTools: true, InFlight: 5, Time: noon}, "low"},
+		{"tools on large model", EffortSignals{Model: "gpt-oss-120b", HasTools: true, Time: noon}, "high"},
+		{"tools on small model", EffortSignals{Model: "gpt-oss-20b", HasTools: true, Time: noon}, ""},
+		{"long prompt", EffortSignals{PromptTokens: 9000, Time: noon}, "low"},
+		{"overnight window", EffortSignals{Time: midnight}, "high"},
+		{"client key", EffortSignals{APIKey: "secret", Time: noon}, "high"},
+		{"no match", EffortSignals{Time: noon}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			effort, _ := policy.Choose(tt.signals)
+			assert.Equal(t, tt.expected, effort)
+		})
+	}
+}
+
+func TestEffortPolicy_Wildcard(t *testing.T) {
+	policy, err := NewEffortPolicy([]string{"*:medium"}, false)
+	require.NoError(t, err)
+
+	effort, rule := policy.Choose(EffortSignals{})
+	assert.Equal(t, "medium", effort)
+	assert.Equal(t, "*:medium", rule.Source)
+}
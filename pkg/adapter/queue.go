@@ -0,0 +1,269 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	errQueueFull      = errors.New("request queue is full")
+	errQueueTimeout   = errors.New("timed out waiting in request queue")
+	errQueuePreempted = errors.New("request queue is full, preempted by a higher priority request")
+)
+
+// priorityHeader sets the priority of a request in the queue.
+const priorityHeader = "X-Priority"
+
+// Priority orders requests waiting in the queue: a request is given a slot
+// before any of lower priority, and in arrival order among its own.
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+)
+
+var priorityNames = []string{"low", "normal", "high"}
+
+// ParsePriority parses a priority name. "batch" and "interactive" are
+// accepted for low and high.
+func ParsePriority(name string) (Priority, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "low", "batch":
+		return PriorityLow, nil
+	case "normal", "":
+		return PriorityNormal, nil
+	case "high", "interactive":
+		return PriorityHigh, nil
+	}
+	return PriorityNormal, fmt.Errorf("unknown priority %q, expected one of %s", name, strings.Join(priorityNames, ", "))
+}
+
+func (p Priority) String() string {
+	return priorityNames[p]
+}
+
+// RequestQueue limits the number of requests in flight to the target. When
+// all slots are taken, up to MaxQueued further requests wait for one to free
+// up, by priority and then in arrival order, for at most Timeout; beyond that
+// they are rejected. A request arriving at a full queue takes the place of
+// the latest waiting request of a lower priority, which is rejected instead.
+type RequestQueue struct {
+	MaxConcurrent int
+	MaxQueued     int
+	Timeout       time.Duration
+
+	mutex     sync.Mutex
+	active    int
+	waiters   [PriorityHigh + 1][]*queueWaiter
+	waiting   atomic.Int64
+	rejected  atomic.Int64
+	timedOut  atomic.Int64
+	preempted atomic.Int64
+}
+
+// queueWaiter is a request waiting for a slot. ready receives nil when the
+// slot of a finished request is handed to it, or errQueuePreempted.
+type queueWaiter struct {
+	ready chan error
+}
+
+func NewRequestQueue(maxConcurrent, maxQueued int, timeout time.Duration) *RequestQueue {
+	return &RequestQueue{
+		MaxConcurrent: maxConcurrent,
+		MaxQueued:     maxQueued,
+		Timeout:       timeout,
+	}
+}
+
+// Acquire takes a slot at normal priority, waiting in the queue if
+// necessary. On success, the returned release function must be called once
+// the request completes. Waiting stops early when ctx is done.
+func (q *RequestQueue) Acquire(ctx context.Context) (release func(), waited time.Duration, err error) {
+	return q.AcquirePriority(ctx, PriorityNormal)
+}
+
+// AcquirePriority takes a slot like Acquire, at the given priority.
+func (q *RequestQueue) AcquirePriority(ctx context.Context, priority Priority) (release func(), waited time.Duration, err error) {
+	q.mutex.Lock()
+	if q.active < q.MaxConcurrent {
+		q.active++
+		q.mutex.Unlock()
+		return q.releaser(), 0, nil
+	}
+
+	if int(q.waiting.Load()) >= q.MaxQueued && !q.preempt(priority) {
+		q.mutex.Unlock()
+		q.rejected.Add(1)
+		return nil, 0, errQueueFull
+	}
+	waiter := &queueWaiter{ready: make(chan error, 1)}
+	q.waiters[priority] = append(q.waiters[priority], waiter)
+	q.waiting.Add(1)
+	q.mutex.Unlock()
+
+	var timeout <-chan time.Time
+	if q.Timeout > 0 {
+		timer := time.NewTimer(q.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	start := time.Now()
+	select {
+	case err := <-waiter.ready:
+		if err != nil {
+			return nil, time.Since(start), err
+		}
+		return q.releaser(), time.Since(start), nil
+	case <-timeout:
+		err = errQueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	q.mutex.Lock()
+	removed := q.remove(priority, waiter)
+	q.mutex.Unlock()
+	if !removed {
+		// The waiter was handed a slot or preempted as it gave up.
+		if <-waiter.ready == nil {
+			q.release()
+		}
+	}
+	if err == errQueueTimeout {
+		q.timedOut.Add(1)
+	}
+	return nil, time.Since(start), err
+}
+
+// preempt rejects the latest waiting request of a lower priority than
+// priority to make room in the queue. It reports false if there is none.
+// q.mutex must be held.
+func (q *RequestQueue) preempt(priority Priority) bool {
+	for p := PriorityLow; p < priority; p++ {
+		waiters := q.waiters[p]
+		if len(waiters) == 0 {
+			continue
+		}
+		victim := waiters[len(waiters)-1]
+		q.waiters[p] = waiters[:len(waiters)-1]
+		q.waiting.Add(-1)
+		q.preempted.Add(1)
+		victim.ready <- errQueuePreempted
+		return true
+	}
+	return false
+}
+
+// remove takes a waiter out of the queue, reporting false if it was no
+// longer in it. q.mutex must be held.
+func (q *RequestQueue) remove(priority Priority, waiter *queueWaiter) bool {
+	i := slices.Index(q.waiters[priority], waiter)
+	if i < 0 {
+		return false
+	}
+	q.waiters[priority] = slices.Delete(q.waiters[priority], i, i+1)
+	q.waiting.Add(-1)
+	return true
+}
+
+// release hands a finished request's slot to the first waiting request of
+// the highest priority, or frees it.
+func (q *RequestQueue) release() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for p := PriorityHigh; p >= PriorityLow; p-- {
+		if len(q.waiters[p]) == 0 {
+			continue
+		}
+		next := q.waiters[p][0]
+		q.waiters[p] = slices.Delete(q.waiters[p], 0, 1)
+		q.waiting.Add(-1)
+		next.ready <- nil
+		return
+	}
+	q.active--
+}
+
+func (q *RequestQueue) releaser() func() {
+	var once sync.Once
+	return func() {
+		once.Do(q.release)
+	}
+}
+
+// Active returns the number of requests holding a slot.
+func (q *RequestQueue) Active() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.active
+}
+
+// Waiting returns the number of requests waiting for a slot.
+func (q *RequestQueue) Waiting() int {
+	return int(q.waiting.Load())
+}
+
+// acquireQueueSlot applies the request queue. It returns false when the
+// request was rejected; otherwise release must be called once the request
+// completes.
+func (a *Adapter) acquireQueueSlot(w http.ResponseWriter, r *http.Request) (func(), bool) {
+	if a.Queue == nil {
+		return func() {}, true
+	}
+
+	priority := requestPriority(r)
+	if a.Queue.Active() >= a.Queue.MaxConcurrent {
+		a.logger.Info("request queued", "target", a.Target, "priority", priority, "active", a.Queue.Active(), "waiting", a.Queue.Waiting()+1)
+	}
+
+	release, waited, err := a.Queue.AcquirePriority(r.Context(), priority)
+	switch {
+	case errors.Is(err, errQueueFull), errors.Is(err, errQueuePreempted):
+		a.logger.Warn("rejecting request, queue is full", "target", a.Target, "priority", priority, "waiting", a.Queue.Waiting(), "waited", waited)
+		w.Header().Set("Retry-After", "1")
+		writeOpenAIError(w, http.StatusServiceUnavailable, "Server busy: "+err.Error(), "server_error", "queue_full")
+		return nil, false
+	case errors.Is(err, errQueueTimeout):
+		a.logger.Warn("rejecting request, timed out in queue", "target", a.Target, "priority", priority, "waited", waited)
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(a.Queue.Timeout)))
+		writeOpenAIError(w, http.StatusServiceUnavailable, "Server busy: "+err.Error(), "server_error", "queue_timeout")
+		return nil, false
+	case err != nil:
+		a.logger.Info("client went away while queued", "waited", waited)
+		return nil, false
+	}
+
+	if waited > 0 {
+		a.logger.Debug("request dequeued", "priority", priority, "waited", waited)
+	}
+	return release, true
+}
+
+// requestPriority returns the priority of a request: that of the X-Priority
+// header, but no higher than the priority of the client's API key. Unknown
+// header values are ignored.
+func requestPriority(r *http.Request) Priority {
+	limit := PriorityHigh
+	priority := PriorityNormal
+	if key, ok := authenticatedKey(r.Context()); ok {
+		limit, _ = ParsePriority(key.Priority)
+		priority = limit
+	}
+	if header := r.Header.Get(priorityHeader); header != "" {
+		if requested, err := ParsePriority(header); err == nil {
+			priority = requested
+		}
+	}
+	return min(priority, limit)
+}
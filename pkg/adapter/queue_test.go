@@ -0,0 +1,189 @@
+package adapter
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+)
+
+func TestRequestQueue_Acquire(t *testing.T) {
+	queue := NewRequestQueue(1, 1, 50*time.Millisecond)
+
+	release, _, err := queue.Acquire(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, queue.Active())
+
+	// The second request waits until the first releases its slot.
+	acquired := make(chan error)
+	go func() {
+		release, _, err := queue.Acquire(context.Background())
+		if err == nil {
+			release()
+		}
+		acquired <- err
+	}()
+	require.Eventually(t, func() bool { return queue.Waiting() == 1 }, time.Second, time.Millisecond)
+
+	// The queue holds one waiter, so a third request is rejected.
+	_, _, err = queue.Acquire(context.Background())
+	assert.ErrorIs(t, err, errQueueFull)
+
+	// Releasing twice must not free a second slot.
+	release()
+	release()
+	assert.NoError(t, <-acquired)
+	assert.Equal(t, 0, queue.Active())
+	assert.Equal(t, int64(1), queue.rejected.Load())
+}
+
+func TestRequestQueue_Timeout(t *testing.T) {
+	queue := NewRequestQueue(1, 1, 10*time.Millisecond)
+
+	release, _, err := queue.Acquire(context.Background())
+	require.NoError(t, err)
+	defer release()
+
+	_, waited, err := queue.Acquire(context.Background())
+	assert.ErrorIs(t, err, errQueueTimeout)
+	assert.GreaterOrEqual(t, waited, 10*time.Millisecond)
+	assert.Equal(t, 0, queue.Waiting())
+}
+
+func TestRequestQueue_Canceled(t *testing.T) {
+	queue := NewRequestQueue(1, 1, 0)
+
+	release, _, err := queue.Acquire(context.Background())
+	require.NoError(t, err)
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err = queue.Acquire(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestHandleChatCompletions_QueueFull(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter("http://localhost:1", NewLRUCache(10), logger, llamacpp.NewProvider())
+	adapter.Queue = NewRequestQueue(1, 0, time.Second)
+
+	release, _, err := adapter.Queue.Acquire(context.Background())
+	require.NoError(t, err)
+	defer release()
+
+	rec := httptest.NewRecorder()
+	adapter.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"messages":[]}`)))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	assert.Contains(t, rec.Body.String(), `"code":"queue_full"`)
+
+	rec = httptest.NewRecorder()
+	adapter.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, rec.Body.String(), `gpt_oss_adapter_queue_active{target="http://localhost:1"} 1`)
+	assert.Contains(t, rec.Body.String(), `gpt_oss_adapter_queue_rejected_total{target="http://localhost:1"} 1`)
+}
+
+func TestRequestQueue_Priority(t *testing.T) {
+	queue := NewRequestQueue(1, 10, time.Second)
+
+	release, _, err := queue.Acquire(context.Background())
+	require.NoError(t, err)
+
+	// Requests are given the slot by priority, then in arrival order.
+	order := make(chan string, 4)
+	enqueue := func(name string, priority Priority) {
+		waiting := queue.Waiting()
+		go func() {
+			release, _, err := queue.AcquirePriority(context.Background(), priority)
+			if assert.NoError(t, err) {
+				order <- name
+				release()
+			}
+		}()
+		require.Eventually(t, func() bool { return queue.Waiting() == waiting+1 }, time.Second, time.Millisecond)
+	}
+	enqueue("batch 1", PriorityLow)
+	enqueue("normal", PriorityNormal)
+	enqueue("batch 2", PriorityLow)
+	enqueue("interactive", PriorityHigh)
+
+	release()
+	var got []string
+	for range 4 {
+		got = append(got, <-order)
+	}
+	assert.Equal(t, []string{"interactive", "normal", "batch 1", "batch 2"}, got)
+	assert.Equal(t, 0, queue.Active())
+}
+
+func TestRequestQueue_Preempt(t *testing.T) {
+	queue := NewRequestQueue(1, 1, time.Second)
+
+	release, _, err := queue.Acquire(context.Background())
+	require.NoError(t, err)
+
+	batch := make(chan error)
+	go func() {
+		_, _, err := queue.AcquirePriority(context.Background(), PriorityLow)
+		batch <- err
+	}()
+	require.Eventually(t, func() bool { return queue.Waiting() == 1 }, time.Second, time.Millisecond)
+
+	// A request of the same priority finds the queue full.
+	_, _, err = queue.AcquirePriority(context.Background(), PriorityLow)
+	assert.ErrorIs(t, err, errQueueFull)
+
+	// A higher priority request takes the batch request's place.
+	interactive := make(chan error)
+	go func() {
+		release, _, err := queue.AcquirePriority(context.Background(), PriorityHigh)
+		if err == nil {
+			release()
+		}
+		interactive <- err
+	}()
+	assert.ErrorIs(t, <-batch, errQueuePreempted)
+
+	release()
+	assert.NoError(t, <-interactive)
+	assert.Equal(t, int64(1), queue.preempted.Load())
+	assert.Equal(t, 0, queue.Active())
+}
+
+func TestRequestPriority(t *testing.T) {
+	request := func(header string, key *APIKey) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		if header != "" {
+			r.Header.Set(priorityHeader, header)
+		}
+		if key != nil {
+			r = r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, *key))
+		}
+		return r
+	}
+
+	assert.Equal(t, PriorityNormal, requestPriority(request("", nil)))
+	assert.Equal(t, PriorityHigh, requestPriority(request("interactive", nil)))
+	assert.Equal(t, PriorityLow, requestPriority(request("batch", nil)))
+	assert.Equal(t, PriorityNormal, requestPriority(request("urgent", nil)))
+
+	// A key's priority is the default and the limit for its requests.
+	eval := &APIKey{Name: "eval", Priority: "low"}
+	assert.Equal(t, PriorityLow, requestPriority(request("", eval)))
+	assert.Equal(t, PriorityLow, requestPriority(request("high", eval)))
+	alice := &APIKey{Name: "alice", Priority: "high"}
+	assert.Equal(t, PriorityHigh, requestPriority(request("", alice)))
+	assert.Equal(t, PriorityLow, requestPriority(request("low", alice)))
+	assert.Equal(t, PriorityNormal, requestPriority(request("", &APIKey{Name: "bob"})))
+}
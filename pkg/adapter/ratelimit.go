@@ -0,0 +1,186 @@
+package adapter
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// RateLimitKeyAuto limits by API key when --api-keys-file is set, and by
+	// client IP otherwise.
+	RateLimitKeyAuto = "auto"
+	// RateLimitKeyIP limits by client IP.
+	RateLimitKeyIP = "ip"
+	// RateLimitKeyAPIKey limits by the API key a client sends, whether or
+	// not it was checked, and by client IP for requests without one.
+	RateLimitKeyAPIKey = "key"
+)
+
+// rateLimitIdleTimeout is how long the state of a client without requests in
+// flight is kept.
+const rateLimitIdleTimeout = 10 * time.Minute
+
+// RateLimiter limits the requests per minute and concurrent streams of each
+// client with a token bucket per client.
+type RateLimiter struct {
+	RatePerMinute int
+	Burst         int
+	MaxStreams    int
+	KeyMode       string
+
+	mutex     sync.Mutex
+	clients   map[string]*clientLimit
+	lastPrune time.Time
+}
+
+type clientLimit struct {
+	bucket  *tokenBucket
+	streams int
+	seen    time.Time
+}
+
+// NewRateLimiter creates a limiter. A burst of zero allows a full minute's
+// worth of requests at once; zero rates or streams are unlimited.
+func NewRateLimiter(ratePerMinute, burst, maxStreams int, keyMode string) *RateLimiter {
+	if burst <= 0 {
+		burst = ratePerMinute
+	}
+	return &RateLimiter{
+		RatePerMinute: ratePerMinute,
+		Burst:         burst,
+		MaxStreams:    maxStreams,
+		KeyMode:       keyMode,
+		clients:       make(map[string]*clientLimit),
+	}
+}
+
+func (l *RateLimiter) client(key string, now time.Time) *clientLimit {
+	if now.Sub(l.lastPrune) > time.Minute {
+		l.lastPrune = now
+		for k, c := range l.clients {
+			if c.streams == 0 && now.Sub(c.seen) > rateLimitIdleTimeout {
+				delete(l.clients, k)
+			}
+		}
+	}
+
+	c, ok := l.clients[key]
+	if !ok {
+		c = &clientLimit{}
+		if l.RatePerMinute > 0 {
+			c.bucket = newTokenBucket(float64(l.Burst), float64(l.RatePerMinute)/60)
+		}
+		l.clients[key] = c
+	}
+	c.seen = now
+	return c
+}
+
+// Allow takes a request from the client's bucket. When the client is over
+// its rate, retryAfter is the time until the next request is allowed.
+func (l *RateLimiter) Allow(key string) (retryAfter time.Duration, ok bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	c := l.client(key, now)
+	if c.bucket == nil {
+		return 0, true
+	}
+	if wait := c.bucket.take(now); wait > 0 {
+		return wait, false
+	}
+	return 0, true
+}
+
+// AcquireStream reserves one of the client's concurrent streams. When the
+// stream is allowed, release must be called once it ends.
+func (l *RateLimiter) AcquireStream(key string) (release func(), ok bool) {
+	if l.MaxStreams <= 0 {
+		return func() {}, true
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	c := l.client(key, time.Now())
+	if c.streams >= l.MaxStreams {
+		return nil, false
+	}
+	c.streams++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mutex.Lock()
+			defer l.mutex.Unlock()
+			c.streams--
+			c.seen = time.Now()
+		})
+	}, true
+}
+
+// rateLimitKey identifies the client a request is limited as.
+func (a *Adapter) rateLimitKey(r *http.Request) string {
+	mode := a.RateLimit.KeyMode
+	if mode == RateLimitKeyAuto || mode == "" {
+		if key, ok := authenticatedKey(r.Context()); ok {
+			return "key:" + key.Name
+		}
+		return "ip:" + getClientIP(r)
+	}
+
+	if mode == RateLimitKeyAPIKey {
+		if credential := requestCredential(r); credential != "" {
+			return "key:" + hashIdentity(credential)
+		}
+	}
+	return "ip:" + getClientIP(r)
+}
+
+// checkRateLimit applies the client's request rate. It returns false when
+// the request was rejected.
+func (a *Adapter) checkRateLimit(w http.ResponseWriter, r *http.Request) bool {
+	key := a.rateLimitKey(r)
+	retryAfter, ok := a.RateLimit.Allow(key)
+	if ok {
+		return true
+	}
+
+	a.logger.Warn("client rate limit exceeded", "client", key, "retry_after", retryAfter)
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(retryAfter)))
+	message := fmt.Sprintf("Rate limit of %d requests per minute exceeded", a.RateLimit.RatePerMinute)
+	writeRateLimitError(w, r, message, "rate_limit_exceeded")
+	return false
+}
+
+// acquireStreamSlot limits the concurrent streams of a client. It returns
+// false when the request was rejected; otherwise release must be called once
+// the request completes.
+func (a *Adapter) acquireStreamSlot(w http.ResponseWriter, r *http.Request, requestData map[string]any) (func(), bool) {
+	if a.RateLimit == nil {
+		return func() {}, true
+	}
+	if stream, _ := requestData["stream"].(bool); !stream {
+		return func() {}, true
+	}
+
+	key := a.rateLimitKey(r)
+	release, ok := a.RateLimit.AcquireStream(key)
+	if !ok {
+		a.logger.Warn("client stream limit exceeded", "client", key, "max_streams", a.RateLimit.MaxStreams)
+		w.Header().Set("Retry-After", "1")
+		message := fmt.Sprintf("Limit of %d concurrent streams exceeded", a.RateLimit.MaxStreams)
+		writeRateLimitError(w, r, message, "concurrent_streams_exceeded")
+		return nil, false
+	}
+	return release, true
+}
+
+// writeRateLimitError writes a 429 in the error format of the endpoint.
+func writeRateLimitError(w http.ResponseWriter, r *http.Request, message, code string) {
+	writeError(w, r, http.StatusTooManyRequests, message, "requests", code)
+}
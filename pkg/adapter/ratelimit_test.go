@@ -0,0 +1,143 @@
+package adapter
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+)
+
+func TestRateLimiter_Allow(t *testing.T) {
+	limiter := NewRateLimiter(60, 2, 0, RateLimitKeyAuto)
+
+	_, ok := limiter.Allow("alice")
+	assert.True(t, ok)
+	_, ok = limiter.Allow("alice")
+	assert.True(t, ok)
+
+	retryAfter, ok := limiter.Allow("alice")
+	assert.False(t, ok)
+	assert.Greater(t, retryAfter, time.Duration(0))
+	assert.LessOrEqual(t, retryAfter, time.Second)
+
+	// Clients have their own buckets.
+	_, ok = limiter.Allow("bob")
+	assert.True(t, ok)
+}
+
+func TestRateLimiter_Streams(t *testing.T) {
+	limiter := NewRateLimiter(0, 0, 1, RateLimitKeyAuto)
+
+	_, ok := limiter.Allow("alice")
+	assert.True(t, ok, "no request rate configured")
+
+	release, ok := limiter.AcquireStream("alice")
+	require.True(t, ok)
+	_, ok = limiter.AcquireStream("alice")
+	assert.False(t, ok)
+	_, ok = limiter.AcquireStream("bob")
+	assert.True(t, ok)
+
+	release()
+	release()
+	release, ok = limiter.AcquireStream("alice")
+	assert.True(t, ok)
+	_, ok = limiter.AcquireStream("alice")
+	assert.False(t, ok)
+	release()
+}
+
+func TestRateLimit_Handler(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[]}`)
+	}))
+	defer upstream.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter(upstream.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+	adapter.RateLimit = NewRateLimiter(60, 1, 0, RateLimitKeyIP)
+
+	send := func(path, remoteAddr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"messages":[]}`))
+		r.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		adapter.ServeHTTP(w, r)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, send("/v1/chat/completions", "10.0.0.1:1234").Code)
+
+	w := send("/v1/chat/completions", "10.0.0.1:1234")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), `"code":"rate_limit_exceeded"`)
+
+	w = send("/v1/messages", "10.0.0.1:1234")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Contains(t, w.Body.String(), `"type":"rate_limit_error"`)
+
+	assert.Equal(t, http.StatusOK, send("/v1/chat/completions", "10.0.0.2:1234").Code)
+
+	// Health checks are not limited.
+	w = httptest.NewRecorder()
+	adapter.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRateLimit_Streams(t *testing.T) {
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.(http.Flusher).Flush()
+		started <- struct{}{}
+		<-finish
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	defer upstream.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter(upstream.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+	adapter.RateLimit = NewRateLimiter(0, 0, 1, RateLimitKeyAPIKey)
+
+	send := func(key string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"messages":[],"stream":true}`))
+		r.Header.Set("Authorization", "Bearer "+key)
+		w := httptest.NewRecorder()
+		adapter.ServeHTTP(w, r)
+		return w
+	}
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- send("alice") }()
+	<-started
+
+	w := send("alice")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"concurrent_streams_exceeded"`)
+
+	go func() { done <- send("bob") }()
+	<-started
+
+	close(finish)
+	assert.Equal(t, http.StatusOK, (<-done).Code)
+	assert.Equal(t, http.StatusOK, (<-done).Code)
+
+	// Non-streamed requests do not count.
+	r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"messages":[]}`))
+	r.Header.Set("Authorization", "Bearer alice")
+	release, ok := adapter.RateLimit.AcquireStream("key:" + hashIdentity("Bearer alice"))
+	require.True(t, ok)
+	defer release()
+	_, ok = adapter.acquireStreamSlot(httptest.NewRecorder(), r, map[string]any{})
+	assert.True(t, ok)
+}
@@ -0,0 +1,73 @@
+package adapter
+
+import (
+	"github.com/aldehir/gpt-oss-adapter/providers/thinktags"
+	"github.com/aldehir/gpt-oss-adapter/providers/types"
+)
+
+// Reasoning formats select where the backend puts reasoning.
+const (
+	// ReasoningFormatField reads and writes reasoning in the provider's
+	// reasoning field.
+	ReasoningFormatField = "field"
+	// ReasoningFormatThinkTags reads reasoning between <think> tags in the
+	// content, as templates without a reasoning parser emit it, and sends
+	// restored reasoning back to the backend in the same tags.
+	ReasoningFormatThinkTags = "think-tags"
+)
+
+func (a *Adapter) thinkTagsEnabled() bool {
+	return a.ReasoningFormat == ReasoningFormatThinkTags
+}
+
+// newStreamParser returns a parser for the deltas of one streamed choice, or
+// nil if deltas are relayed as they are.
+func (a *Adapter) newStreamParser() types.StreamParser {
+	if a.Provider.NewStreamParser != nil {
+		return a.Provider.NewStreamParser()
+	}
+	if a.thinkTagsEnabled() {
+		return thinktags.NewStreamParser(a.Provider.Reasoning)
+	}
+	return nil
+}
+
+// splitThinkTags moves the tagged reasoning in the content of every choice of
+// a chat completion to the provider's reasoning field.
+func (a *Adapter) splitThinkTags(responseData map[string]any) {
+	forEachChoice(responseData, "message", func(_ int, message map[string]any) {
+		thinktags.Split(message, a.Provider.Reasoning)
+	})
+}
+
+// wrapThinkTags moves the reasoning of assistant messages in a request, most
+// of it restored from the cache, into their content between tags.
+func (a *Adapter) wrapThinkTags(requestData map[string]any) {
+	messages, _ := requestData["messages"].([]any)
+	for _, m := range messages {
+		message, ok := m.(map[string]any)
+		if !ok || message["role"] != "assistant" {
+			continue
+		}
+
+		reasoning, ok := message[a.Provider.Reasoning].(string)
+		if !ok {
+			continue
+		}
+
+		var content string
+		switch c := message["content"].(type) {
+		case string:
+			content = c
+		case nil:
+		default:
+			// Content parts are left alone rather than flattened.
+			continue
+		}
+
+		delete(message, a.Provider.Reasoning)
+		if reasoning != "" {
+			message["content"] = thinktags.Wrap(reasoning, content)
+		}
+	}
+}
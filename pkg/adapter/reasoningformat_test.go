@@ -0,0 +1,85 @@
+package adapter
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThinkTags_Blocking(t *testing.T) {
+	adapter := newTestAdapter()
+	adapter.ReasoningFormat = ReasoningFormatThinkTags
+
+	response := map[string]any{
+		"choices": []any{map[string]any{
+			"message": map[string]any{
+				"role":       "assistant",
+				"content":    "<think>Check the weather.</think>\n\n",
+				"tool_calls": []any{map[string]any{"id": "call_1", "function": map[string]any{"name": "get_weather", "arguments": "{}"}}},
+			},
+		}},
+	}
+	require.True(t, adapter.processChatResponse(nil, &http.Response{}, &chatRequest{}, response))
+
+	message := response["choices"].([]any)[0].(map[string]any)["message"].(map[string]any)
+	assert.Equal(t, "Check the weather.", message["reasoning"])
+	assert.NotContains(t, message, "content")
+
+	request := map[string]any{"messages": []any{
+		map[string]any{"role": "user", "content": "Weather?"},
+		map[string]any{
+			"role":       "assistant",
+			"content":    nil,
+			"tool_calls": []any{map[string]any{"id": "call_1", "function": map[string]any{"name": "get_weather", "arguments": "{}"}}},
+		},
+	}}
+	adapter.injectReasoningFromCache("", request)
+	adapter.wrapThinkTags(request)
+
+	message = request["messages"].([]any)[1].(map[string]any)
+	assert.Equal(t, "<think>Check the weather.</think>\n\n", message["content"])
+	assert.NotContains(t, message, "reasoning_content")
+}
+
+func TestThinkTags_Stream(t *testing.T) {
+	adapter := newTestAdapter()
+	adapter.ReasoningFormat = ReasoningFormatThinkTags
+
+	stream := strings.Join([]string{
+		`data: {"choices":[{"index":0,"delta":{"content":"<think>Say "}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"content":"hi.</think>Hi"}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"content":"!"},"finish_reason":"stop"}]}`,
+		`data: [DONE]`,
+		``,
+	}, "\n\n")
+
+	req, _ := http.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	resp := &http.Response{Body: io.NopCloser(strings.NewReader(stream)), Request: req}
+
+	var lines []string
+	adapter.relayChatStream(resp, &chatRequest{data: map[string]any{}}, func(line string) {
+		lines = append(lines, line)
+	})
+
+	assert.Contains(t, lines[0], `"delta":{"reasoning":"Say "}`)
+	assert.Contains(t, lines[2], `"delta":{"content":"Hi","reasoning":"hi."}`)
+	assert.Contains(t, lines[4], `"delta":{"content":"!"}`)
+}
+
+func TestThinkTags_Disabled(t *testing.T) {
+	adapter := newTestAdapter()
+
+	response := map[string]any{
+		"choices": []any{map[string]any{
+			"message": map[string]any{"role": "assistant", "content": "<think>x</think>y"},
+		}},
+	}
+	require.True(t, adapter.processChatResponse(nil, &http.Response{}, &chatRequest{}, response))
+
+	message := response["choices"].([]any)[0].(map[string]any)["message"].(map[string]any)
+	assert.Equal(t, "<think>x</think>y", message["content"])
+}
@@ -0,0 +1,280 @@
+package adapter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// recentBodyLimit is how many bytes of each body a recent exchange keeps.
+const recentBodyLimit = 64 << 10
+
+// recentExchange is a chat completions exchange kept for /debug/recent, with
+// the request and response both as the client and as the target saw them.
+// Streamed responses are merged into a single chat completion.
+type recentExchange struct {
+	ID               uint64    `json:"id"`
+	Time             time.Time `json:"time"`
+	DurationMS       int64     `json:"duration_ms"`
+	Path             string    `json:"path"`
+	Client           string    `json:"client,omitempty"`
+	Model            string    `json:"model,omitempty"`
+	Status           int       `json:"status"`
+	Request          any       `json:"request,omitempty"`
+	UpstreamURL      string    `json:"upstream_url,omitempty"`
+	UpstreamRequest  any       `json:"upstream_request,omitempty"`
+	UpstreamResponse any       `json:"upstream_response,omitempty"`
+	Response         any       `json:"response,omitempty"`
+
+	// Truncated is set when a body was cut off at recentBodyLimit bytes.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// recentExchanges is a ring of the most recent chat completions exchanges,
+// shared by an adapter, its routes and its reloads.
+type recentExchanges struct {
+	mu        sync.Mutex
+	exchanges []*recentExchange
+	next      uint64
+}
+
+func newRecentExchanges() *recentExchanges {
+	return &recentExchanges{}
+}
+
+// add keeps exchange, dropping the oldest exchanges beyond capacity.
+func (re *recentExchanges) add(exchange *recentExchange, capacity int) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	re.next++
+	exchange.ID = re.next
+	re.exchanges = append(re.exchanges, exchange)
+	if excess := len(re.exchanges) - capacity; excess > 0 {
+		re.exchanges = append(re.exchanges[:0:0], re.exchanges[excess:]...)
+	}
+}
+
+// list returns up to limit exchanges, newest first.
+func (re *recentExchanges) list(limit int) []*recentExchange {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	n := len(re.exchanges)
+	if limit > 0 && limit < n {
+		n = limit
+	}
+	list := make([]*recentExchange, 0, n)
+	for i := len(re.exchanges) - 1; i >= 0 && len(list) < n; i-- {
+		list = append(list, re.exchanges[i])
+	}
+	return list
+}
+
+// limitedBuffer keeps the first limit bytes written to it.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); len(p) > room {
+		b.truncated = true
+		if room > 0 {
+			b.Buffer.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// recentCapture collects the bodies of one exchange while it is served.
+type recentCapture struct {
+	exchange         recentExchange
+	request          []byte
+	upstreamRequest  []byte
+	upstreamResponse limitedBuffer
+	upstreamStream   bool
+}
+
+// observeUpstream records the upstream request of the exchange, as sent to
+// the target.
+func (c *recentCapture) observeUpstream(url string, body []byte) {
+	if c == nil {
+		return
+	}
+	c.exchange.UpstreamURL = url
+	c.upstreamRequest = body
+}
+
+// observeUpstreamResponse records a blocking response from the target.
+func (c *recentCapture) observeUpstreamResponse(body []byte) {
+	if c == nil {
+		return
+	}
+	c.upstreamResponse.Reset()
+	c.upstreamResponse.Write(body)
+}
+
+// observeUpstreamEvent records an event data line streamed from the target.
+func (c *recentCapture) observeUpstreamEvent(data string) {
+	if c == nil {
+		return
+	}
+	c.upstreamStream = true
+	fmt.Fprintf(&c.upstreamResponse, "data: %s\n\n", data)
+}
+
+// recentRequestBody returns a request body for an exchange, setting
+// truncated if it is cut off.
+func recentRequestBody(body []byte, truncated *bool) any {
+	if len(body) > recentBodyLimit {
+		*truncated = true
+		return recentBody("", body[:recentBodyLimit], true)
+	}
+	return recentBody("", body, false)
+}
+
+// recentBody returns a captured body for an exchange. Truncated bodies that
+// are not streams are kept as text, since they are no longer valid JSON.
+func recentBody(contentType string, body []byte, truncated bool) any {
+	if truncated && !isStreamContentType(contentType) {
+		return string(body)
+	}
+	return recordResponseBody(contentType, body)
+}
+
+func isStreamContentType(contentType string) bool {
+	return strings.Contains(contentType, "text/event-stream") || strings.Contains(contentType, "application/x-ndjson")
+}
+
+// captureRecent starts capturing a chat completions exchange for
+// /debug/recent, unless it is disabled or the client keeps the request out of
+// the cache. The returned function must be called once the response has been
+// written to the returned writer.
+func (a *Adapter) captureRecent(w http.ResponseWriter, r *http.Request, chat *chatRequest, requestBody []byte) (http.ResponseWriter, func()) {
+	if a.DebugRecent <= 0 || chat.noStore {
+		return w, func() {}
+	}
+
+	start := time.Now()
+	capture := &recentCapture{
+		exchange: recentExchange{Time: start, Path: r.URL.RequestURI()},
+		request:  requestBody,
+	}
+	capture.upstreamResponse.limit = recentBodyLimit
+	chat.recent = capture
+
+	rw := &recentWriter{ResponseWriter: w, statusCode: http.StatusOK}
+	rw.body.limit = recentBodyLimit
+
+	return rw, func() {
+		exchange := &capture.exchange
+		exchange.DurationMS = time.Since(start).Milliseconds()
+		exchange.Client = chat.client
+		exchange.Model, _ = chat.data["model"].(string)
+		exchange.Status = rw.statusCode
+
+		exchange.Request = recentRequestBody(capture.request, &exchange.Truncated)
+		exchange.UpstreamRequest = recentRequestBody(capture.upstreamRequest, &exchange.Truncated)
+
+		upstreamType := ""
+		if capture.upstreamStream {
+			upstreamType = "text/event-stream"
+		}
+		exchange.UpstreamResponse = recentBody(upstreamType, capture.upstreamResponse.Bytes(), capture.upstreamResponse.truncated)
+		exchange.Response = recentBody(w.Header().Get("Content-Type"), rw.body.Bytes(), rw.body.truncated)
+		if capture.upstreamResponse.truncated || rw.body.truncated {
+			exchange.Truncated = true
+		}
+
+		a.recent.add(exchange, a.DebugRecent)
+	}
+}
+
+// recentWriter captures the start of the response body while passing it
+// through.
+type recentWriter struct {
+	http.ResponseWriter
+	statusCode int
+	body       limitedBuffer
+}
+
+func (rw *recentWriter) WriteHeader(code int) {
+	rw.statusCode = code
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *recentWriter) Write(b []byte) (int, error) {
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
+
+func (rw *recentWriter) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (rw *recentWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+func (rw *recentWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	rw.statusCode = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
+// handleDebugRecent lists the most recent chat completions exchanges, newest
+// first, to diagnose how requests and responses are transformed without
+// logging every one of them. A limit query parameter caps the number
+// returned.
+func (a *Adapter) handleDebugRecent(w http.ResponseWriter, r *http.Request) {
+	if a.DebugRecent <= 0 {
+		http.NotFound(w, r)
+		return
+	}
+	if !a.authorizeAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "Method not allowed", "invalid_request_error", "")
+		return
+	}
+
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			writeOpenAIError(w, http.StatusBadRequest, "Invalid limit: "+value, "invalid_request_error", "invalid_limit")
+			return
+		}
+		limit = n
+	}
+
+	exchanges := make([]json.RawMessage, 0)
+	for _, exchange := range a.recent.list(limit) {
+		data, err := json.Marshal(exchange)
+		if err != nil {
+			continue
+		}
+		if a.Scrubber != nil {
+			data = a.Scrubber.ScrubJSON(data)
+		}
+		exchanges = append(exchanges, data)
+	}
+	writeAdminJSON(w, map[string]any{"exchanges": exchanges})
+}
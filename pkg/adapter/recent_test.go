@@ -0,0 +1,125 @@
+package adapter
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+)
+
+func TestDebugRecent(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]any
+		json.NewDecoder(r.Body).Decode(&request)
+		if stream, _ := request["stream"].(bool); stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, `data: {"object":"chat.completion.chunk","choices":[{"index":0,"delta":{"reasoning_content":"Greet."}}]}`+"\n\n")
+			io.WriteString(w, `data: {"object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"Hi"},"finish_reason":"stop"}]}`+"\n\n")
+			io.WriteString(w, "data: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[{"index":0,"message":{"role":"assistant","reasoning_content":"Greet.","content":"Hi"},"finish_reason":"stop"}]}`)
+	}))
+	defer backend.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter(backend.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+	adapter.AdminToken = "secret"
+	adapter.DebugRecent = 2
+	adapter.StripReasoning = true
+	adapter.DefaultReasoningEffort = "high"
+
+	chat := func(body string, header http.Header) {
+		r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+		for name, values := range header {
+			r.Header[name] = values
+		}
+		w := httptest.NewRecorder()
+		adapter.ServeHTTP(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+	recent := func(query string) []map[string]any {
+		r := httptest.NewRequest(http.MethodGet, "/debug/recent"+query, nil)
+		r.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		adapter.ServeHTTP(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var body struct {
+			Exchanges []map[string]any `json:"exchanges"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body.Exchanges
+	}
+
+	chat(`{"model":"gpt-oss-20b","messages":[{"role":"user","content":"Hello"}]}`, nil)
+	chat(`{"model":"gpt-oss-20b","stream":true,"messages":[{"role":"user","content":"Hello"}]}`, nil)
+	chat(`{"model":"gpt-oss-20b","messages":[{"role":"user","content":"Private"}]}`, http.Header{cacheModeHeader: {CacheModeNoStore}})
+
+	exchanges := recent("")
+	require.Len(t, exchanges, 2)
+
+	streamed := exchanges[0]
+	assert.Equal(t, "gpt-oss-20b", streamed["model"])
+	assert.Equal(t, float64(http.StatusOK), streamed["status"])
+	assert.NotContains(t, streamed["request"], "chat_template_kwargs")
+	kwargs := streamed["upstream_request"].(map[string]any)["chat_template_kwargs"].(map[string]any)
+	assert.Equal(t, "high", kwargs["reasoning_effort"])
+	upstream := streamed["upstream_response"].(map[string]any)["choices"].([]any)[0].(map[string]any)["message"].(map[string]any)
+	assert.Equal(t, "Greet.", upstream["reasoning_content"])
+	assert.Equal(t, "Hi", upstream["content"])
+	relayed := streamed["response"].(map[string]any)["choices"].([]any)[0].(map[string]any)["message"].(map[string]any)
+	assert.NotContains(t, relayed, "reasoning_content")
+	assert.Equal(t, "Hi", relayed["content"])
+
+	blocking := exchanges[1]
+	upstream = blocking["upstream_response"].(map[string]any)["choices"].([]any)[0].(map[string]any)["message"].(map[string]any)
+	assert.Equal(t, "Greet.", upstream["reasoning_content"])
+	relayed = blocking["response"].(map[string]any)["choices"].([]any)[0].(map[string]any)["message"].(map[string]any)
+	assert.NotContains(t, relayed, "reasoning_content")
+
+	// Only the newest exchanges are kept.
+	chat(`{"model":"gpt-oss-120b","messages":[{"role":"user","content":"Again"}]}`, nil)
+	exchanges = recent("?limit=1")
+	require.Len(t, exchanges, 1)
+	assert.Equal(t, "gpt-oss-120b", exchanges[0]["model"])
+	assert.Len(t, recent(""), 2)
+
+	r := httptest.NewRequest(http.MethodGet, "/debug/recent", nil)
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	adapter.DebugRecent = 0
+	w = httptest.NewRecorder()
+	adapter.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRecentExchanges_Truncated(t *testing.T) {
+	adapter := newTestAdapter()
+	adapter.DebugRecent = 1
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	chat := &chatRequest{data: map[string]any{}}
+	body := []byte(`{"messages":[{"role":"user","content":"` + strings.Repeat("a", recentBodyLimit) + `"}]}`)
+
+	w, finish := adapter.captureRecent(httptest.NewRecorder(), r, chat, body)
+	w.Write([]byte(`{}`))
+	finish()
+
+	exchanges := adapter.recent.list(0)
+	require.Len(t, exchanges, 1)
+	assert.True(t, exchanges[0].Truncated)
+	assert.Len(t, exchanges[0].Request, recentBodyLimit)
+	assert.Equal(t, json.RawMessage(`{}`), exchanges[0].Response)
+}
@@ -0,0 +1,398 @@
+package adapter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redactedHeaders are replaced in recordings so that credentials do not end
+// up on disk.
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "X-Api-Key", "Api-Key", "Cookie"}
+
+// exchangeRecord is one line of a recording file.
+type exchangeRecord struct {
+	Time            time.Time   `json:"time"`
+	DurationMS      int64       `json:"duration_ms"`
+	Method          string      `json:"method"`
+	Path            string      `json:"path"`
+	RequestHeaders  http.Header `json:"request_headers"`
+	Request         any         `json:"request,omitempty"`
+	UpstreamURL     string      `json:"upstream_url,omitempty"`
+	UpstreamRequest any         `json:"upstream_request,omitempty"`
+	Status          int         `json:"status"`
+	ResponseHeaders http.Header `json:"response_headers"`
+	Response        any         `json:"response,omitempty"`
+}
+
+// Recorder appends request/response pairs to one JSONL file per day in Dir.
+type Recorder struct {
+	Dir string
+
+	// Scrubber, if set, redacts the string values of every record.
+	Scrubber *Scrubber
+
+	mutex sync.Mutex
+	file  *os.File
+	date  string
+}
+
+func NewRecorder(dir string) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Recorder{Dir: dir}, nil
+}
+
+// Write appends a record to the file for the record's date.
+func (rec *Recorder) Write(record *exchangeRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if rec.Scrubber != nil {
+		data = rec.Scrubber.ScrubJSON(data)
+	}
+
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+
+	date := record.Time.UTC().Format("2006-01-02")
+	if rec.file == nil || rec.date != date {
+		if rec.file != nil {
+			rec.file.Close()
+		}
+		path := filepath.Join(rec.Dir, "requests-"+date+".jsonl")
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			rec.file = nil
+			return err
+		}
+		rec.file = file
+		rec.date = date
+	}
+
+	_, err = rec.file.Write(append(data, '\n'))
+	return err
+}
+
+func (rec *Recorder) Close() error {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+
+	if rec.file == nil {
+		return nil
+	}
+	err := rec.file.Close()
+	rec.file = nil
+	return err
+}
+
+type recordingContextKey struct{}
+
+// recordUpstreamRequest attaches the request actually sent to the backend,
+// after reasoning injection, to the recording for r, if any.
+func recordUpstreamRequest(r *http.Request, url string, body []byte) {
+	if record, ok := r.Context().Value(recordingContextKey{}).(*exchangeRecord); ok {
+		record.UpstreamURL = url
+		record.UpstreamRequest = recordBody(body)
+	}
+}
+
+// RecordingMiddleware records every request it serves, with its response,
+// to a Recorder.
+type RecordingMiddleware struct {
+	handler  http.Handler
+	recorder *Recorder
+	logger   *slog.Logger
+}
+
+func NewRecordingMiddleware(handler http.Handler, recorder *Recorder, logger *slog.Logger) *RecordingMiddleware {
+	return &RecordingMiddleware{
+		handler:  handler,
+		recorder: recorder,
+		logger:   logger,
+	}
+}
+
+func (m *RecordingMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/healthz" || r.URL.Path == "/readyz" {
+		m.handler.ServeHTTP(w, r)
+		return
+	}
+
+	// Clients that keep their reasoning out of the cache keep the whole
+	// exchange off disk too.
+	switch strings.ToLower(strings.TrimSpace(r.Header.Get(cacheModeHeader))) {
+	case CacheModeNoStore, CacheModeBypass:
+		m.handler.ServeHTTP(w, r)
+		return
+	}
+
+	start := time.Now()
+
+	requestBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		m.logger.Error("failed to read request body for recording", "error", err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(requestBody))
+
+	record := &exchangeRecord{
+		Time:           start,
+		Method:         r.Method,
+		Path:           r.URL.RequestURI(),
+		RequestHeaders: redactHeaders(r.Header),
+		Request:        recordBody(requestBody),
+	}
+	r = r.WithContext(context.WithValue(r.Context(), recordingContextKey{}, record))
+
+	rw := &recordingWriter{ResponseWriter: w, statusCode: http.StatusOK}
+	m.handler.ServeHTTP(rw, r)
+
+	record.DurationMS = time.Since(start).Milliseconds()
+	record.Status = rw.statusCode
+	record.ResponseHeaders = redactHeaders(w.Header())
+	record.Response = recordResponseBody(w.Header().Get("Content-Type"), rw.body.Bytes())
+
+	if err := m.recorder.Write(record); err != nil {
+		m.logger.Error("failed to write recording", "error", err)
+	}
+}
+
+// recordingWriter captures the response body while passing it through.
+type recordingWriter struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(code int) {
+	rw.statusCode = code
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
+
+func (rw *recordingWriter) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (rw *recordingWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+func (rw *recordingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	rw.statusCode = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
+func redactHeaders(header http.Header) http.Header {
+	redacted := header.Clone()
+	for _, name := range redactedHeaders {
+		if redacted.Get(name) != "" {
+			redacted.Set(name, "[REDACTED]")
+		}
+	}
+	return redacted
+}
+
+// recordBody embeds JSON bodies as-is and anything else as a string.
+func recordBody(body []byte) any {
+	if len(body) == 0 {
+		return nil
+	}
+	if json.Valid(body) {
+		return json.RawMessage(body)
+	}
+	return string(body)
+}
+
+// recordResponseBody reconstructs streamed responses. Chat completion
+// chunks are merged into a single chat completion; other event streams, such
+// as Responses or Messages events, are recorded as a list of events.
+func recordResponseBody(contentType string, body []byte) any {
+	var events []any
+	switch {
+	case strings.Contains(contentType, "text/event-stream"):
+		reader := newSSEReader(bytes.NewReader(body), 0)
+		for {
+			event, err := reader.Next()
+			if err != nil {
+				break
+			}
+			if event.HasData && event.Data != "[DONE]" {
+				events = append(events, recordBody([]byte(event.Data)))
+			}
+		}
+	case strings.Contains(contentType, "application/x-ndjson"):
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		scanner.Buffer(make([]byte, 64*1024), DefaultStreamMaxLineSize)
+		for scanner.Scan() {
+			if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+				events = append(events, recordBody(bytes.Clone(line)))
+			}
+		}
+	default:
+		return recordBody(body)
+	}
+
+	var chunks []map[string]any
+	for _, event := range events {
+		raw, ok := event.(json.RawMessage)
+		if !ok {
+			return events
+		}
+		var chunk map[string]any
+		if err := json.Unmarshal(raw, &chunk); err != nil || chunk["object"] != "chat.completion.chunk" {
+			return events
+		}
+		chunks = append(chunks, chunk)
+	}
+	if len(chunks) == 0 {
+		return events
+	}
+	return mergeChatChunks(chunks)
+}
+
+// mergeChatChunks folds streamed chat completion chunks into the equivalent
+// chat completion. String delta fields, whatever the provider calls them,
+// are concatenated, and tool calls are merged by index.
+func mergeChatChunks(chunks []map[string]any) map[string]any {
+	completion := map[string]any{"object": "chat.completion"}
+	messages := make(map[int]map[string]any)
+	finishReasons := make(map[int]any)
+	toolCalls := make(map[int]map[int]map[string]any)
+
+	for _, chunk := range chunks {
+		for _, key := range []string{"id", "model", "created", "system_fingerprint"} {
+			if _, ok := completion[key]; !ok && chunk[key] != nil {
+				completion[key] = chunk[key]
+			}
+		}
+		if usage, ok := chunk["usage"]; ok && usage != nil {
+			completion["usage"] = usage
+		}
+
+		forEachChoice(chunk, "delta", func(index int, delta map[string]any) {
+			message, ok := messages[index]
+			if !ok {
+				message = make(map[string]any)
+				messages[index] = message
+			}
+
+			for key, value := range delta {
+				switch v := value.(type) {
+				case string:
+					existing, _ := message[key].(string)
+					if key == "role" {
+						existing = ""
+					}
+					message[key] = existing + v
+				case []any:
+					if key == "tool_calls" {
+						mergeToolCallDeltas(toolCalls, index, v)
+					}
+				}
+			}
+		})
+
+		choices, _ := chunk["choices"].([]any)
+		for i, c := range choices {
+			choice, _ := c.(map[string]any)
+			index := i
+			if n, ok := choice["index"].(float64); ok {
+				index = int(n)
+			}
+			if reason, ok := choice["finish_reason"]; ok && reason != nil {
+				finishReasons[index] = reason
+			}
+		}
+	}
+
+	indices := make([]int, 0, len(messages))
+	for index := range messages {
+		indices = append(indices, index)
+	}
+	sort.Ints(indices)
+
+	choices := make([]any, 0, len(indices))
+	for _, index := range indices {
+		message := messages[index]
+		if calls := toolCalls[index]; len(calls) > 0 {
+			callIndices := make([]int, 0, len(calls))
+			for i := range calls {
+				callIndices = append(callIndices, i)
+			}
+			sort.Ints(callIndices)
+
+			list := make([]any, 0, len(calls))
+			for _, i := range callIndices {
+				list = append(list, calls[i])
+			}
+			message["tool_calls"] = list
+		}
+		choices = append(choices, map[string]any{
+			"index":         index,
+			"message":       message,
+			"finish_reason": finishReasons[index],
+		})
+	}
+	completion["choices"] = choices
+
+	return completion
+}
+
+func mergeToolCallDeltas(toolCalls map[int]map[int]map[string]any, choice int, deltas []any) {
+	if toolCalls[choice] == nil {
+		toolCalls[choice] = make(map[int]map[string]any)
+	}
+
+	for i, d := range deltas {
+		delta, ok := d.(map[string]any)
+		if !ok {
+			continue
+		}
+		index := i
+		if n, ok := delta["index"].(float64); ok {
+			index = int(n)
+		}
+
+		call, ok := toolCalls[choice][index]
+		if !ok {
+			call = map[string]any{"type": "function", "function": map[string]any{"name": "", "arguments": ""}}
+			toolCalls[choice][index] = call
+		}
+		if id, ok := delta["id"].(string); ok && id != "" {
+			call["id"] = id
+		}
+
+		function, _ := delta["function"].(map[string]any)
+		merged := call["function"].(map[string]any)
+		for _, key := range []string{"name", "arguments"} {
+			if value, ok := function[key].(string); ok {
+				merged[key] = fmt.Sprint(merged[key]) + value
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordingMiddleware(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir)
+	require.NoError(t, err)
+	defer recorder.Close()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.JSONEq(t, `{"stream":true}`, string(body), "handler still sees the body")
+		recordUpstreamRequest(r, "http://backend/v1/chat/completions", []byte(`{"stream":true,"injected":1}`))
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range []string{
+			`{"id":"c1","object":"chat.completion.chunk","model":"m","choices":[{"index":0,"delta":{"role":"assistant","reasoning":"Think"}}]}`,
+			`{"id":"c1","object":"chat.completion.chunk","model":"m","choices":[{"index":0,"delta":{"reasoning":"ing."}}]}`,
+			`{"id":"c1","object":"chat.completion.chunk","model":"m","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"f","arguments":"{\"a\""}}]}}]}`,
+			`{"id":"c1","object":"chat.completion.chunk","model":"m","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":":1}"}}]},"finish_reason":"tool_calls"}]}`,
+		} {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	})
+
+	middleware := NewRecordingMiddleware(handler, recorder, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"stream":true}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	middleware.ServeHTTP(rec, req)
+	assert.Contains(t, rec.Body.String(), "data: [DONE]")
+
+	files, err := filepath.Glob(filepath.Join(dir, "requests-*.jsonl"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "secret")
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(data, &record))
+
+	assert.Equal(t, "/v1/chat/completions", record["path"])
+	assert.Equal(t, []any{"[REDACTED]"}, record["request_headers"].(map[string]any)["Authorization"])
+	assert.Equal(t, map[string]any{"stream": true}, record["request"])
+	assert.Equal(t, map[string]any{"stream": true, "injected": float64(1)}, record["upstream_request"])
+	assert.Equal(t, float64(200), record["status"])
+
+	response := record["response"].(map[string]any)
+	assert.Equal(t, "chat.completion", response["object"])
+	assert.Equal(t, []any{map[string]any{
+		"index":         float64(0),
+		"finish_reason": "tool_calls",
+		"message": map[string]any{
+			"role":      "assistant",
+			"reasoning": "Thinking.",
+			"tool_calls": []any{map[string]any{
+				"id":       "call_1",
+				"type":     "function",
+				"function": map[string]any{"name": "f", "arguments": `{"a":1}`},
+			}},
+		},
+	}}, response["choices"])
+}
+
+func TestRecordResponseBody_Events(t *testing.T) {
+	body := "event: message_start\ndata: {\"type\":\"message_start\"}\n\nevent: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"
+
+	events := recordResponseBody("text/event-stream", []byte(body))
+	assert.Equal(t, []any{
+		json.RawMessage(`{"type":"message_start"}`),
+		json.RawMessage(`{"type":"message_stop"}`),
+	}, events)
+}
+
+func TestRecordingMiddleware_Scrub(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir)
+	require.NoError(t, err)
+	defer recorder.Close()
+	recorder.Scrubber, err = NewScrubber([]string{ScrubEmails}, nil, nil)
+	require.NoError(t, err)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[{"message":{"reasoning":"Mail bob@example.com."}}]}`)
+	})
+	middleware := NewRecordingMiddleware(handler, recorder, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	middleware.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"user":"bob@example.com"}`)))
+
+	skipped := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{}`))
+	skipped.Header.Set(cacheModeHeader, CacheModeNoStore)
+	middleware.ServeHTTP(httptest.NewRecorder(), skipped)
+
+	files, err := filepath.Glob(filepath.Join(dir, "requests-*.jsonl"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, strings.Count(string(data), "\n"))
+	assert.NotContains(t, string(data), "bob@example.com")
+	assert.Equal(t, 2, strings.Count(string(data), "[REDACTED:email]"))
+}
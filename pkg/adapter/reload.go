@@ -0,0 +1,164 @@
+package adapter
+
+import (
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+	"sync/atomic"
+)
+
+// Reloadable serves requests with an adapter that can be replaced while the
+// server is running, e.g. after the configuration changed. Requests in flight
+// finish on the adapter they started on, so open streams are not dropped.
+type Reloadable struct {
+	current atomic.Pointer[Adapter]
+}
+
+func NewReloadable(a *Adapter) *Reloadable {
+	r := &Reloadable{}
+	r.current.Store(a)
+	return r
+}
+
+// Adapter returns the adapter serving new requests.
+func (r *Reloadable) Adapter() *Adapter {
+	return r.current.Load()
+}
+
+// Replace makes next serve new requests and returns the adapter it replaced.
+// State that outlives a single request, such as usage totals, rate limiter
+// buckets and the in-flight count, is carried over to next wherever next is
+// configured the same way, so that a reload does not reset it.
+func (r *Reloadable) Replace(next *Adapter) *Adapter {
+	prev := r.current.Load()
+	next.inherit(prev)
+	r.current.Store(next)
+	return prev
+}
+
+func (r *Reloadable) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.current.Load().ServeHTTP(w, req)
+}
+
+// inherit takes over the stateful components of prev that a is configured
+// to use unchanged. Routes and the fallback share the components of the
+// adapter they were added to, so they are updated along with it.
+func (a *Adapter) inherit(prev *Adapter) {
+	ledger := a.Ledger
+	if ledger != nil && prev.Ledger != nil {
+		ledger = prev.Ledger
+	}
+
+	budget := a.Budget
+	if budget != nil && prev.Budget != nil && budget.Limit == prev.Budget.Limit && budget.Mode == prev.Budget.Mode {
+		budget = prev.Budget
+	}
+
+	rateLimit := a.RateLimit
+	if rateLimit != nil && prev.RateLimit != nil &&
+		rateLimit.RatePerMinute == prev.RateLimit.RatePerMinute &&
+		rateLimit.Burst == prev.RateLimit.Burst &&
+		rateLimit.MaxStreams == prev.RateLimit.MaxStreams &&
+		rateLimit.KeyMode == prev.RateLimit.KeyMode {
+		rateLimit = prev.RateLimit
+	}
+
+	throttle := a.Throttle
+	if throttle != nil && prev.Throttle != nil && maps.Equal(throttle.limits, prev.Throttle.limits) {
+		throttle = prev.Throttle
+	}
+
+	queue := a.Queue
+	if queue != nil && prev.Queue != nil &&
+		queue.MaxConcurrent == prev.Queue.MaxConcurrent &&
+		queue.MaxQueued == prev.Queue.MaxQueued &&
+		queue.Timeout == prev.Queue.Timeout {
+		queue = prev.Queue
+	}
+
+	breaker := a.Breaker
+	if breaker != nil && prev.Breaker != nil &&
+		breaker.Threshold == prev.Breaker.Threshold &&
+		breaker.MinRequests == prev.Breaker.MinRequests &&
+		breaker.Window == prev.Breaker.Window &&
+		breaker.Cooldown == prev.Breaker.Cooldown {
+		breaker = prev.Breaker
+	}
+
+	responseCache := a.ResponseCache
+	if responseCache != nil && prev.ResponseCache != nil &&
+		responseCache.TTL == prev.ResponseCache.TTL &&
+		responseCache.MaxEntries == prev.ResponseCache.MaxEntries &&
+		responseCache.MaxBytes == prev.ResponseCache.MaxBytes {
+		responseCache = prev.ResponseCache
+	}
+
+	downgrade := a.EffortDowngrade
+	if downgrade != nil && prev.EffortDowngrade != nil &&
+		slices.Equal(downgrade.QueueThresholds, prev.EffortDowngrade.QueueThresholds) &&
+		slices.Equal(downgrade.LatencyThresholds, prev.EffortDowngrade.LatencyThresholds) {
+		downgrade = prev.EffortDowngrade
+	}
+
+	health := a.Health
+	if health != nil && prev.Health != nil &&
+		health.Target == prev.Health.Target &&
+		health.Path == prev.Health.Path &&
+		health.Interval == prev.Health.Interval &&
+		health.Timeout == prev.Health.Timeout &&
+		reflect.DeepEqual(health.Headers, prev.Health.Headers) {
+		health = prev.Health
+	}
+
+	routes := a.routeAdapters()
+	if a.fallback != nil {
+		routes = append(routes, a.fallback)
+	}
+	for _, route := range routes {
+		if route.Ledger == a.Ledger {
+			route.Ledger = ledger
+		}
+		if route.Budget == a.Budget {
+			route.Budget = budget
+		}
+		if route.RateLimit == a.RateLimit {
+			route.RateLimit = rateLimit
+		}
+		if route.Throttle == a.Throttle {
+			route.Throttle = throttle
+		}
+		if route.Queue == a.Queue {
+			route.Queue = queue
+		}
+		if route.Breaker == a.Breaker {
+			route.Breaker = breaker
+		}
+		if route.ResponseCache == a.ResponseCache {
+			route.ResponseCache = responseCache
+		}
+		if route.EffortDowngrade == a.EffortDowngrade {
+			route.EffortDowngrade = downgrade
+		}
+		route.inflight = prev.inflight
+		route.tap = prev.tap
+		route.recent = prev.recent
+		route.rates = prev.rates
+		route.toolRetries = prev.toolRetries
+	}
+
+	a.Ledger = ledger
+	a.Budget = budget
+	a.RateLimit = rateLimit
+	a.Throttle = throttle
+	a.Queue = queue
+	a.Breaker = breaker
+	a.ResponseCache = responseCache
+	a.EffortDowngrade = downgrade
+	a.Health = health
+	a.inflight = prev.inflight
+	a.tap = prev.tap
+	a.recent = prev.recent
+	a.rates = prev.rates
+	a.toolRetries = prev.toolRetries
+}
@@ -0,0 +1,99 @@
+package adapter
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+)
+
+func TestReloadable_Replace(t *testing.T) {
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	first := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"first\"}}]}\n\n")
+		w.(http.Flusher).Flush()
+		close(started)
+		<-finish
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	defer first.Close()
+	second := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"second"}}]}`)
+	}))
+	defer second.Close()
+
+	old := newTestAdapter()
+	old.Target = first.URL
+	reloadable := NewReloadable(old)
+
+	stream := make(chan *httptest.ResponseRecorder)
+	go func() {
+		w := httptest.NewRecorder()
+		reloadable.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"messages":[],"stream":true}`)))
+		stream <- w
+	}()
+	<-started
+
+	next := NewAdapter(second.URL, old.cache, old.logger, llamacpp.NewProvider())
+	assert.Same(t, old, reloadable.Replace(next))
+	assert.Same(t, next, reloadable.Adapter())
+
+	w := httptest.NewRecorder()
+	reloadable.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"messages":[]}`)))
+	assert.Contains(t, w.Body.String(), "second")
+
+	// The stream that started before the reload runs to completion.
+	close(finish)
+	w = <-stream
+	assert.Contains(t, w.Body.String(), "first")
+	assert.Contains(t, w.Body.String(), "data: [DONE]")
+}
+
+func TestReloadable_Inherit(t *testing.T) {
+	old := newTestAdapter()
+	old.Ledger = NewUsageLedger()
+	old.RateLimit = NewRateLimiter(60, 0, 1, RateLimitKeyIP)
+	old.Queue = NewRequestQueue(2, 10, 0)
+	old.Breaker = NewCircuitBreaker(0.5, 5, time.Minute, 30*time.Second)
+	old.ResponseCache = NewResponseCache(time.Hour, 100, 0)
+	old.Ledger.AddRequest("alice", "m")
+	old.inflight.Add(3)
+	reloadable := NewReloadable(old)
+
+	next := newTestAdapter()
+	next.Ledger = NewUsageLedger()
+	next.RateLimit = NewRateLimiter(60, 0, 1, RateLimitKeyIP)
+	next.Queue = NewRequestQueue(4, 10, 0)
+	next.Breaker = NewCircuitBreaker(0.5, 5, time.Minute, 30*time.Second)
+	next.ResponseCache = NewResponseCache(time.Hour, 100, 0)
+	next.AddRoute(llamacpp.NewProvider())
+	reloadable.Replace(next)
+
+	assert.Same(t, old.Ledger, next.Ledger)
+	assert.Same(t, old.RateLimit, next.RateLimit)
+	assert.NotSame(t, old.Queue, next.Queue, "queue size changed")
+	assert.Same(t, old.Breaker, next.Breaker)
+	assert.Same(t, old.ResponseCache, next.ResponseCache)
+	assert.Equal(t, int64(3), next.inflight.Load())
+	assert.Same(t, old.recent, next.recent)
+
+	route := next.routes["llama-cpp"]
+	require.NotNil(t, route)
+	assert.Same(t, old.Ledger, route.Ledger)
+	assert.Same(t, old.RateLimit, route.RateLimit)
+	assert.Same(t, next.Queue, route.Queue)
+	assert.Same(t, old.Breaker, route.Breaker)
+	assert.Same(t, old.ResponseCache, route.ResponseCache)
+	assert.Equal(t, int64(3), route.inflight.Load())
+	assert.Same(t, old.recent, route.recent)
+}
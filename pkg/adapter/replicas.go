@@ -0,0 +1,84 @@
+package adapter
+
+import (
+	"hash/fnv"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+// AddReplica spreads chat requests over target and the adapter's own
+// target, which serve the same models with the adapter's provider. Requests
+// of one conversation, identified by the X-Conversation-ID header or its
+// first user message, stick to one backend so that its prompt cache stays
+// warm. A conversation fails over to the other backends, in an order that is
+// also fixed per conversation, and then to the fallback target. Replicas
+// share the adapter's cache and settings, so AddReplica must be called after
+// the adapter is configured and its fallback set.
+func (a *Adapter) AddReplica(target string) {
+	if len(a.replicas) == 0 {
+		a.replicas = append(a.replicas, a.newReplica(a.Target))
+	}
+	a.replicas = append(a.replicas, a.newReplica(target))
+}
+
+func (a *Adapter) newReplica(target string) *Adapter {
+	replica := a.newRoute(target, a.Provider)
+	replica.fallback = a.fallback
+	replica.FallbackModels = a.FallbackModels
+	return replica
+}
+
+// forwardToReplica sends a chat request to the replica chosen for its
+// conversation, leaving the others in chat for failover.
+func (a *Adapter) forwardToReplica(w http.ResponseWriter, r *http.Request, chat *chatRequest, path string) (*http.Response, *Adapter, bool) {
+	order := a.replicaOrder(conversationID(r, chat.data))
+	a.logger.DebugContext(r.Context(), "selected replica", "replica", order[0].Target)
+	chat.replicas = order[1:]
+	return order[0].forwardChatRequest(w, r, chat, path)
+}
+
+// failOverReplica sends a chat request to the next replica, starting over
+// from the request data as it was before it was prepared for a's target.
+func (a *Adapter) failOverReplica(w http.ResponseWriter, r *http.Request, chat *chatRequest, path string, data map[string]any) (*http.Response, *Adapter, bool) {
+	next := chat.replicas[0]
+	chat.replicas = chat.replicas[1:]
+	chat.data = data
+	return next.forwardChatRequest(w, r, chat, path)
+}
+
+// replicaOrder returns the replicas in the order a conversation tries them.
+// Each is chosen by jump consistent hashing over those not yet chosen, so
+// adding a replica moves only the conversations that land on it, and the
+// conversations of a failed replica spread evenly over the rest. Requests
+// without a conversation key start at a random replica.
+func (a *Adapter) replicaOrder(key string) []*Adapter {
+	if key == "" {
+		key = strconv.FormatUint(rand.Uint64(), 16)
+	}
+	hash := fnv.New64a()
+	hash.Write([]byte(key))
+
+	candidates := slices.Clone(a.replicas)
+	order := make([]*Adapter, 0, len(candidates))
+	for len(candidates) > 0 {
+		i := jumpHash(hash.Sum64(), len(candidates))
+		order = append(order, candidates[i])
+		candidates = slices.Delete(candidates, i, i+1)
+		hash.Write([]byte{0})
+	}
+	return order
+}
+
+// jumpHash maps key to one of n buckets with the jump consistent hash of
+// Lamping and Veach.
+func jumpHash(key uint64, n int) int {
+	var b, j int64 = -1, 0
+	for j < int64(n) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
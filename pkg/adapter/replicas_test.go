@@ -0,0 +1,125 @@
+package adapter
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+)
+
+// newReplicaServer returns a backend that counts its requests, failing them
+// with status if it is not 200.
+func newReplicaServer(t *testing.T, calls *atomic.Int32, status int) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if status != http.StatusOK {
+			http.Error(w, "unavailable", status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"ok"}}]}`)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func sendConversation(t *testing.T, adapter *Adapter, first string, turn int) int {
+	messages := `{"role":"user","content":"` + first + `"}`
+	for i := range turn {
+		messages += fmt.Sprintf(`,{"role":"assistant","content":"reply %d"},{"role":"user","content":"next %d"}`, i, i)
+	}
+	body := `{"model":"gpt-oss-20b","messages":[` + messages + `]}`
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+	return w.Code
+}
+
+func TestReplicas_Affinity(t *testing.T) {
+	calls := make([]atomic.Int32, 3)
+	servers := make([]*httptest.Server, 3)
+	for i := range servers {
+		servers[i] = newReplicaServer(t, &calls[i], http.StatusOK)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter(servers[0].URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+	adapter.AddReplica(servers[1].URL)
+	adapter.AddReplica(servers[2].URL)
+
+	counts := func() [3]int32 {
+		return [3]int32{calls[0].Load(), calls[1].Load(), calls[2].Load()}
+	}
+
+	// Every turn of a conversation goes to the same server.
+	for c := range 20 {
+		before := counts()
+		for turn := range 3 {
+			require.Equal(t, http.StatusOK, sendConversation(t, adapter, fmt.Sprintf("conversation %d", c), turn))
+		}
+		after := counts()
+		served := 0
+		for i := range after {
+			if after[i] != before[i] {
+				assert.Equal(t, int32(3), after[i]-before[i])
+				served++
+			}
+		}
+		assert.Equal(t, 1, served)
+	}
+
+	// Conversations spread over all servers.
+	for i := range calls {
+		assert.NotZero(t, calls[i].Load(), "server %d", i)
+	}
+}
+
+func TestReplicas_FailOver(t *testing.T) {
+	var healthyCalls, failingCalls atomic.Int32
+	failing := newReplicaServer(t, &failingCalls, http.StatusServiceUnavailable)
+	healthy := newReplicaServer(t, &healthyCalls, http.StatusOK)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter(failing.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+	adapter.AddReplica(healthy.URL)
+
+	for c := range 10 {
+		require.Equal(t, http.StatusOK, sendConversation(t, adapter, fmt.Sprintf("conversation %d", c), 0))
+	}
+	assert.Equal(t, int32(10), healthyCalls.Load())
+	assert.NotZero(t, failingCalls.Load())
+}
+
+func TestReplicaOrder(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter("http://replica-0", NewLRUCache(10), logger, llamacpp.NewProvider())
+	for i := 1; i < 4; i++ {
+		adapter.AddReplica(fmt.Sprintf("http://replica-%d", i))
+	}
+
+	order := adapter.replicaOrder("conversation")
+	assert.Len(t, order, 4)
+	assert.ElementsMatch(t, adapter.replicas, order)
+	assert.Equal(t, order, adapter.replicaOrder("conversation"))
+
+	// Adding a replica only moves conversations onto it.
+	first := make(map[string]string)
+	for i := range 200 {
+		key := fmt.Sprint(i)
+		first[key] = adapter.replicaOrder(key)[0].Target
+	}
+	adapter.AddReplica("http://replica-4")
+	for key, target := range first {
+		if moved := adapter.replicaOrder(key)[0].Target; moved != target {
+			assert.Equal(t, "http://replica-4", moved)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package adapter
+
+import (
+	"container/list"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// responseCacheHeader tells clients whether a response was served from the
+// response cache.
+const responseCacheHeader = "X-GPT-OSS-Response-Cache"
+
+// ResponseCache keeps the responses to deterministic chat requests, those
+// sent with a temperature of 0, so that identical requests are answered
+// without calling the target again. It is separate from the reasoning cache.
+// Entries expire TTL after they were stored; the least recently used are
+// evicted once there are more than MaxEntries or their bodies take more than
+// MaxBytes.
+type ResponseCache struct {
+	TTL        time.Duration
+	MaxEntries int
+	MaxBytes   int64
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	list    *list.List
+	bytes   int64
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+type cachedResponse struct {
+	key     string
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+func NewResponseCache(ttl time.Duration, maxEntries int, maxBytes int64) *ResponseCache {
+	return &ResponseCache{
+		TTL:        ttl,
+		MaxEntries: maxEntries,
+		MaxBytes:   maxBytes,
+		entries:    make(map[string]*list.Element),
+		list:       list.New(),
+	}
+}
+
+func (c *ResponseCache) get(key string) (*cachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if ok && time.Now().After(elem.Value.(*cachedResponse).expires) {
+		c.remove(elem)
+		ok = false
+	}
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.hits.Add(1)
+	c.list.MoveToFront(elem)
+	return elem.Value.(*cachedResponse), true
+}
+
+func (c *ResponseCache) put(key string, header http.Header, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+	size := int64(len(body))
+	if c.MaxBytes > 0 && size > c.MaxBytes {
+		return
+	}
+	for c.list.Len() > 0 && ((c.MaxEntries > 0 && c.list.Len() >= c.MaxEntries) || (c.MaxBytes > 0 && c.bytes+size > c.MaxBytes)) {
+		c.remove(c.list.Back())
+	}
+	c.entries[key] = c.list.PushFront(&cachedResponse{
+		key:     key,
+		header:  header,
+		body:    body,
+		expires: time.Now().Add(c.TTL),
+	})
+	c.bytes += size
+}
+
+func (c *ResponseCache) remove(elem *list.Element) {
+	entry := elem.Value.(*cachedResponse)
+	c.list.Remove(elem)
+	delete(c.entries, entry.key)
+	c.bytes -= int64(len(entry.body))
+}
+
+// Len returns the number of cached responses.
+func (c *ResponseCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.list.Len()
+}
+
+// Bytes returns the total size of the cached response bodies.
+func (c *ResponseCache) Bytes() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.bytes
+}
+
+// deterministic reports whether a chat request asks for a temperature of 0,
+// which makes its response worth caching.
+func deterministic(requestData map[string]any) bool {
+	temperature, ok := requestData["temperature"].(float64)
+	return ok && temperature == 0
+}
+
+// cacheResponse serves a request from the response cache if it holds an
+// entry for key, and otherwise calls serve and stores a successful
+// response. Like HTTP caches, it honors a Cache-Control request header:
+// no-cache skips the lookup, and no-store skips the cache altogether.
+func (a *Adapter) cacheResponse(w http.ResponseWriter, r *http.Request, key string, serve func(w http.ResponseWriter)) {
+	control := strings.ToLower(r.Header.Get("Cache-Control"))
+	if strings.Contains(control, "no-store") {
+		serve(w)
+		return
+	}
+
+	if !strings.Contains(control, "no-cache") {
+		if cached, ok := a.ResponseCache.get(key); ok {
+			a.logger.InfoContext(r.Context(), "served request from the response cache")
+			for name, values := range cached.header {
+				if _, ok := w.Header()[name]; !ok {
+					w.Header()[name] = values
+				}
+			}
+			w.Header().Set(responseCacheHeader, "hit")
+			w.WriteHeader(http.StatusOK)
+			w.Write(cached.body)
+			return
+		}
+	}
+
+	w.Header().Set(responseCacheHeader, "miss")
+	tw := &teeWriter{ResponseWriter: w}
+	serve(tw)
+	if tw.status == http.StatusOK && r.Context().Err() == nil {
+		a.ResponseCache.put(key, tw.header, tw.body.Bytes())
+	}
+}
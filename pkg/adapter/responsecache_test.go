@@ -0,0 +1,125 @@
+package adapter
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+)
+
+func TestResponseCache(t *testing.T) {
+	var calls atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}]}`)
+	}))
+	defer backend.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter(backend.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+	adapter.ResponseCache = NewResponseCache(time.Hour, 10, 0)
+
+	send := func(body string, header http.Header) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+		for name, values := range header {
+			r.Header[name] = values
+		}
+		w := httptest.NewRecorder()
+		adapter.ServeHTTP(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
+		return w
+	}
+
+	body := `{"model":"gpt-oss-20b","temperature":0,"messages":[{"role":"user","content":"Hello"}]}`
+	first := send(body, nil)
+	assert.Equal(t, "miss", first.Header().Get(responseCacheHeader))
+
+	w := send(body, nil)
+	assert.Equal(t, "hit", w.Header().Get(responseCacheHeader))
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, first.Body.String(), w.Body.String())
+	assert.Equal(t, int32(1), calls.Load())
+
+	// Sampled requests are not cached.
+	sampled := `{"model":"gpt-oss-20b","temperature":0.7,"messages":[{"role":"user","content":"Hello"}]}`
+	send(sampled, nil)
+	w = send(sampled, nil)
+	assert.Empty(t, w.Header().Get(responseCacheHeader))
+	assert.Equal(t, int32(3), calls.Load())
+
+	w = send(body, http.Header{"Cache-Control": {"no-cache"}})
+	assert.Equal(t, "miss", w.Header().Get(responseCacheHeader))
+	assert.Equal(t, int32(4), calls.Load())
+
+	w = send(body, http.Header{"Cache-Control": {"no-store"}})
+	assert.Empty(t, w.Header().Get(responseCacheHeader))
+	assert.Equal(t, int32(5), calls.Load())
+
+	w = httptest.NewRecorder()
+	adapter.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, w.Body.String(), "gpt_oss_adapter_response_cache_hits_total 1\n")
+	assert.Contains(t, w.Body.String(), "gpt_oss_adapter_response_cache_misses_total 1\n")
+	assert.Contains(t, w.Body.String(), "gpt_oss_adapter_response_cache_entries 1\n")
+}
+
+func TestResponseCache_NotCached(t *testing.T) {
+	var calls atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, `{"error":{"message":"bad request"}}`, http.StatusBadRequest)
+	}))
+	defer backend.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter(backend.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+	adapter.ResponseCache = NewResponseCache(time.Hour, 10, 0)
+
+	body := `{"model":"gpt-oss-20b","temperature":0,"messages":[{"role":"user","content":"Hello"}]}`
+	for range 2 {
+		w := httptest.NewRecorder()
+		adapter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+	assert.Equal(t, int32(2), calls.Load())
+	assert.Zero(t, adapter.ResponseCache.Len())
+}
+
+func TestResponseCache_Limits(t *testing.T) {
+	c := NewResponseCache(time.Hour, 2, 10)
+
+	c.put("a", nil, []byte("aaaa"))
+	c.put("b", nil, []byte("bbbb"))
+	c.put("c", nil, []byte("cccc"))
+	assert.Equal(t, 2, c.Len())
+	assert.Equal(t, int64(8), c.Bytes())
+	_, ok := c.get("a")
+	assert.False(t, ok, "evicted by count")
+
+	c.get("b")
+	c.put("d", nil, []byte("dddddd"))
+	_, ok = c.get("b")
+	assert.True(t, ok)
+	_, ok = c.get("c")
+	assert.False(t, ok, "evicted by size")
+
+	c.put("e", nil, []byte("too large to cache"))
+	_, ok = c.get("e")
+	assert.False(t, ok)
+
+	c = NewResponseCache(10*time.Millisecond, 2, 0)
+	c.put("a", nil, []byte("aaaa"))
+	time.Sleep(15 * time.Millisecond)
+	_, ok = c.get("a")
+	assert.False(t, ok, "expired")
+	assert.Zero(t, c.Len())
+}
@@ -0,0 +1,947 @@
+package adapter
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// handleResponses serves the OpenAI Responses API on top of a backend that
+// only speaks chat completions. Requests are translated into chat
+// completions, sent through the regular chat pipeline, and the results are
+// translated back into Responses objects or streaming events.
+func (a *Adapter) handleResponses(w http.ResponseWriter, r *http.Request) {
+	a.logger.InfoContext(r.Context(), "handling responses request", "method", r.Method, "path", r.URL.Path)
+
+	a.inflight.Add(1)
+	defer a.inflight.Add(-1)
+
+	_, parseSpan := tracer().Start(r.Context(), "parse request")
+	requestBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		endSpan(parseSpan, err)
+		a.logger.ErrorContext(r.Context(), "failed to read request body", "error", err)
+		writeRequestBodyError(w, r, err)
+		return
+	}
+
+	var requestData map[string]any
+	err = json.Unmarshal(requestBody, &requestData)
+	endSpan(parseSpan, err)
+	if err != nil {
+		a.logger.ErrorContext(r.Context(), "failed to unmarshal request", "error", err)
+		writeOpenAIError(w, http.StatusBadRequest, "Request body is not valid JSON", "invalid_request_error", "")
+		return
+	}
+	if requestData == nil {
+		writeOpenAIError(w, http.StatusBadRequest, "Request body must be a JSON object", "invalid_request_error", "")
+		return
+	}
+
+	scope, err := a.requestCacheScope(r)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, err.Error(), "invalid_request_error", "invalid_cache_mode")
+		return
+	}
+
+	chatData, err := a.responsesToChat(scope, requestData)
+	if err != nil {
+		a.logger.WarnContext(r.Context(), "failed to translate responses request", "error", err)
+		writeOpenAIError(w, http.StatusBadRequest, err.Error(), "invalid_request_error", "")
+		return
+	}
+
+	release, ok := a.acquireModelSlot(w, r, chatData)
+	if !ok {
+		return
+	}
+	defer release()
+
+	releaseStream, ok := a.acquireStreamSlot(w, r, chatData)
+	if !ok {
+		return
+	}
+	defer releaseStream()
+
+	releaseQueue, ok := a.acquireQueueSlot(w, r)
+	if !ok {
+		return
+	}
+	defer releaseQueue()
+
+	chat := &chatRequest{ctx: r.Context(), data: chatData, cacheScope: scope, summarize: a.Summarizer != nil}
+	path := strings.TrimSuffix(r.URL.Path, "/responses") + "/chat/completions"
+
+	w, stopKeepAlive := a.startKeepAlive(w, chat)
+	defer stopKeepAlive()
+
+	resp, upstream, ok := a.forwardWithToolRetries(w, r, chat, path)
+	if !ok {
+		return
+	}
+	defer resp.Body.Close()
+
+	contentType := resp.Header.Get("Content-Type")
+	a.logger.DebugContext(r.Context(), "received response", "status", resp.StatusCode, "content-type", contentType)
+
+	if resp.StatusCode >= 400 {
+		a.logger.WarnContext(r.Context(), "target returned error for responses request", "status", resp.StatusCode)
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+		return
+	}
+
+	if strings.Contains(contentType, "text/event-stream") {
+		_, span := tracer().Start(r.Context(), "stream relay")
+		upstream.handleResponsesStreaming(w, resp, chat, requestData)
+		span.End()
+	} else {
+		upstream.handleResponsesBlocking(w, resp, chat, requestData)
+	}
+}
+
+func (a *Adapter) handleResponsesBlocking(w http.ResponseWriter, resp *http.Response, chat *chatRequest, requestData map[string]any) {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		a.logger.Error("failed to read response body", "error", err)
+		writeOpenAIError(w, http.StatusBadGateway, "Failed to read response body", "server_error", "upstream_error")
+		return
+	}
+
+	var responseData map[string]any
+	if err := json.Unmarshal(body, &responseData); err != nil {
+		a.logger.Error("failed to unmarshal response", "error", err)
+		writeOpenAIError(w, http.StatusBadGateway, "Upstream response is not valid JSON", "server_error", "upstream_error")
+		return
+	}
+
+	if !a.processChatResponse(w, resp, chat, responseData) {
+		return
+	}
+
+	response := a.chatToResponse(chat.cacheScope, responseData, requestData)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.StatusCode)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (a *Adapter) handleResponsesStreaming(w http.ResponseWriter, resp *http.Response, chat *chatRequest, requestData map[string]any) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(resp.StatusCode)
+
+	w, stopWriter := a.startStreamWriter(w, resp, chat)
+	defer stopWriter()
+
+	flush := func() {}
+	if flusher, ok := w.(http.Flusher); ok {
+		flush = flusher.Flush
+	}
+
+	// The stream summarizes each reasoning item as it closes.
+	chat.summarize = false
+
+	stream := &responsesStream{
+		ctx:      chat.ctx,
+		adapter:  a,
+		scope:    chat.cacheScope,
+		w:        w,
+		flush:    flush,
+		response: newResponseObject(requestData),
+		tools:    make(map[int]*responsesStreamItem),
+	}
+
+	stream.start()
+	a.relayChatStream(resp, chat, stream.handleLine)
+	stream.finish()
+}
+
+// responsesToChat translates a Responses API request into a chat completions
+// request.
+func (a *Adapter) responsesToChat(scope cacheScope, req map[string]any) (map[string]any, error) {
+	chat := make(map[string]any)
+
+	for _, key := range []string{"model", "stream", "temperature", "top_p", "parallel_tool_calls", "user", "metadata"} {
+		if value, ok := req[key]; ok {
+			chat[key] = value
+		}
+	}
+
+	if maxTokens, ok := req["max_output_tokens"]; ok {
+		chat["max_tokens"] = maxTokens
+	}
+
+	if stream, _ := req["stream"].(bool); stream {
+		chat["stream_options"] = map[string]any{"include_usage": true}
+	}
+
+	if reasoning, ok := req["reasoning"].(map[string]any); ok {
+		if effort, ok := reasoning["effort"]; ok && effort != nil {
+			chat["reasoning"] = map[string]any{"effort": effort}
+		}
+	}
+
+	if text, ok := req["text"].(map[string]any); ok {
+		if format, ok := text["format"].(map[string]any); ok {
+			chat["response_format"] = responsesFormatToChat(format)
+		}
+	}
+
+	if tools, ok := req["tools"].([]any); ok {
+		var chatTools []any
+		for _, t := range tools {
+			tool, ok := t.(map[string]any)
+			if !ok {
+				continue
+			}
+			if toolType, _ := tool["type"].(string); toolType != "function" {
+				a.logger.Debug("dropping unsupported responses tool", "type", tool["type"])
+				continue
+			}
+			function := make(map[string]any)
+			for _, key := range []string{"name", "description", "parameters", "strict"} {
+				if value, ok := tool[key]; ok {
+					function[key] = value
+				}
+			}
+			chatTools = append(chatTools, map[string]any{"type": "function", "function": function})
+		}
+		if len(chatTools) > 0 {
+			chat["tools"] = chatTools
+		}
+	}
+
+	if toolChoice, ok := req["tool_choice"]; ok {
+		if choice, ok := toolChoice.(map[string]any); ok {
+			if name, ok := choice["name"].(string); ok {
+				chat["tool_choice"] = map[string]any{"type": "function", "function": map[string]any{"name": name}}
+			}
+		} else {
+			chat["tool_choice"] = toolChoice
+		}
+	}
+
+	var messages []any
+	if instructions, ok := req["instructions"].(string); ok && instructions != "" {
+		messages = append(messages, map[string]any{"role": "system", "content": instructions})
+	}
+
+	switch input := req["input"].(type) {
+	case string:
+		messages = append(messages, map[string]any{"role": "user", "content": input})
+	case []any:
+		converted, err := a.responsesInputToMessages(scope, input)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, converted...)
+	case nil:
+	default:
+		return nil, fmt.Errorf("input must be a string or an array of items")
+	}
+
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("input is required")
+	}
+	chat["messages"] = messages
+
+	return chat, nil
+}
+
+func (a *Adapter) responsesInputToMessages(scope cacheScope, input []any) ([]any, error) {
+	var messages []any
+	var assistant map[string]any
+	var pendingReasoning string
+
+	takeReasoning := func(message map[string]any) {
+		if pendingReasoning != "" {
+			message[a.Provider.Reasoning] = pendingReasoning
+			pendingReasoning = ""
+		}
+	}
+
+	for i, it := range input {
+		item, ok := it.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("input[%d] must be an object", i)
+		}
+
+		itemType, _ := item["type"].(string)
+		if itemType == "" {
+			if _, ok := item["role"]; ok {
+				itemType = "message"
+			}
+		}
+
+		switch itemType {
+		case "message":
+			role, _ := item["role"].(string)
+			message := map[string]any{
+				"role":    role,
+				"content": responsesContentToChat(item["content"]),
+			}
+			if role == "assistant" {
+				takeReasoning(message)
+				assistant = message
+			} else {
+				assistant = nil
+			}
+			messages = append(messages, message)
+
+		case "reasoning":
+			pendingReasoning = a.responsesReasoningText(scope, item)
+			assistant = nil
+
+		case "function_call":
+			if assistant == nil {
+				assistant = map[string]any{"role": "assistant", "content": nil}
+				takeReasoning(assistant)
+				messages = append(messages, assistant)
+			}
+			toolCalls, _ := assistant["tool_calls"].([]any)
+			assistant["tool_calls"] = append(toolCalls, withSealedReasoning(map[string]any{
+				"id":   item["call_id"],
+				"type": "function",
+				"function": map[string]any{
+					"name":      item["name"],
+					"arguments": item["arguments"],
+				},
+			}, item))
+
+		case "function_call_output":
+			output, ok := item["output"].(string)
+			if !ok {
+				encoded, _ := json.Marshal(item["output"])
+				output = string(encoded)
+			}
+			messages = append(messages, map[string]any{
+				"role":         "tool",
+				"tool_call_id": item["call_id"],
+				"content":      output,
+			})
+			assistant = nil
+
+		default:
+			a.logger.Debug("dropping unsupported responses input item", "type", itemType)
+		}
+	}
+
+	return messages, nil
+}
+
+// responsesReasoningText recovers the text of a reasoning input item, either
+// from its content or, for clients that only echo the item ID, from the
+// cache.
+func (a *Adapter) responsesReasoningText(scope cacheScope, item map[string]any) string {
+	var parts []string
+	if content, ok := item["content"].([]any); ok {
+		for _, c := range content {
+			if part, ok := c.(map[string]any); ok {
+				if text, ok := part["text"].(string); ok {
+					parts = append(parts, text)
+				}
+			}
+		}
+	}
+	if len(parts) > 0 {
+		return strings.Join(parts, "")
+	}
+
+	if id, ok := item["id"].(string); ok && !scope.skipLookup {
+		if cached, found := a.cache.Get(scope.namespace, id); found {
+			a.logger.Debug("restored reasoning item from cache", "id", id)
+			return cached.Content
+		}
+	}
+
+	return ""
+}
+
+// responsesContentToChat converts Responses content, either a string or an
+// array of input/output parts, into chat message content.
+func responsesContentToChat(content any) any {
+	parts, ok := content.([]any)
+	if !ok {
+		return content
+	}
+
+	var chatParts []any
+	var texts []string
+	textOnly := true
+
+	for _, p := range parts {
+		part, ok := p.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		switch part["type"] {
+		case "input_text", "output_text", "text":
+			text, _ := part["text"].(string)
+			texts = append(texts, text)
+			chatParts = append(chatParts, map[string]any{"type": "text", "text": text})
+		case "input_image":
+			textOnly = false
+			imageURL := map[string]any{"url": part["image_url"]}
+			if detail, ok := part["detail"]; ok {
+				imageURL["detail"] = detail
+			}
+			chatParts = append(chatParts, map[string]any{"type": "image_url", "image_url": imageURL})
+		}
+	}
+
+	if textOnly {
+		return strings.Join(texts, "")
+	}
+	return chatParts
+}
+
+func responsesFormatToChat(format map[string]any) map[string]any {
+	if format["type"] != "json_schema" {
+		return map[string]any{"type": format["type"]}
+	}
+
+	schema := make(map[string]any)
+	for _, key := range []string{"name", "description", "schema", "strict"} {
+		if value, ok := format[key]; ok {
+			schema[key] = value
+		}
+	}
+	return map[string]any{"type": "json_schema", "json_schema": schema}
+}
+
+// newResponseObject builds the skeleton of a Responses API response object,
+// echoing the relevant request parameters.
+func newResponseObject(req map[string]any) map[string]any {
+	response := map[string]any{
+		"id":         newResponsesID("resp"),
+		"object":     "response",
+		"created_at": time.Now().Unix(),
+		"status":     "in_progress",
+		"model":      req["model"],
+		"output":     []any{},
+		"usage":      nil,
+	}
+
+	for _, key := range []string{"instructions", "max_output_tokens", "metadata", "parallel_tool_calls", "reasoning", "temperature", "text", "tool_choice", "tools", "top_p"} {
+		if value, ok := req[key]; ok {
+			response[key] = value
+		}
+	}
+
+	return response
+}
+
+// chatToResponse translates a (transformed) chat completion into a
+// Responses API response object.
+func (a *Adapter) chatToResponse(scope cacheScope, chatResponse map[string]any, req map[string]any) map[string]any {
+	response := newResponseObject(req)
+	if model, ok := chatResponse["model"]; ok {
+		response["model"] = model
+	}
+
+	var output []any
+	finishReason := ""
+
+	if choices, ok := chatResponse["choices"].([]any); ok && len(choices) > 0 {
+		if choice, ok := choices[0].(map[string]any); ok {
+			finishReason, _ = choice["finish_reason"].(string)
+
+			if message, ok := choice["message"].(map[string]any); ok {
+				if reasoning, ok := message["reasoning"].(string); ok && reasoning != "" {
+					summary, _ := message["reasoning_summary"].(string)
+					output = append(output, a.reasoningOutputItem(scope, reasoning, summary))
+				}
+
+				if content, ok := message["content"].(string); ok && content != "" {
+					output = append(output, messageOutputItem(content))
+				}
+
+				if toolCalls, ok := message["tool_calls"].([]any); ok {
+					for _, tc := range toolCalls {
+						toolCall, ok := tc.(map[string]any)
+						if !ok {
+							continue
+						}
+						function, _ := toolCall["function"].(map[string]any)
+						output = append(output, withSealedReasoning(map[string]any{
+							"id":        newResponsesID("fc"),
+							"type":      "function_call",
+							"status":    "completed",
+							"call_id":   toolCall["id"],
+							"name":      function["name"],
+							"arguments": function["arguments"],
+						}, toolCall))
+					}
+				}
+			}
+		}
+	}
+
+	if output == nil {
+		output = []any{}
+	}
+	response["output"] = output
+	response["usage"] = chatUsageToResponses(chatResponse["usage"])
+	setResponseStatus(response, finishReason)
+
+	return response
+}
+
+func (a *Adapter) reasoningOutputItem(scope cacheScope, text, summary string) map[string]any {
+	id := newResponsesID("rs")
+	if !scope.noStore {
+		a.storeReasoning(scope.namespace, id, ReasoningItem{ID: id, Content: text})
+		a.logger.Debug("cached reasoning item", "id", id, "content_length", len(text))
+	}
+
+	return map[string]any{
+		"id":      id,
+		"type":    "reasoning",
+		"summary": summaryOutput(summary),
+		"content": a.reasoningItemContent(text),
+	}
+}
+
+// reasoningItemContent returns the content of a reasoning output item. With
+// StripReasoning the item is left empty; clients echo it back by ID.
+func (a *Adapter) reasoningItemContent(text string) []any {
+	if a.StripReasoning {
+		return []any{}
+	}
+	return []any{map[string]any{"type": "reasoning_text", "text": text}}
+}
+
+func messageOutputItem(text string) map[string]any {
+	return map[string]any{
+		"id":     newResponsesID("msg"),
+		"type":   "message",
+		"status": "completed",
+		"role":   "assistant",
+		"content": []any{
+			map[string]any{"type": "output_text", "text": text, "annotations": []any{}},
+		},
+	}
+}
+
+func setResponseStatus(response map[string]any, finishReason string) {
+	switch finishReason {
+	case "length":
+		response["status"] = "incomplete"
+		response["incomplete_details"] = map[string]any{"reason": "max_output_tokens"}
+	case "content_filter":
+		response["status"] = "incomplete"
+		response["incomplete_details"] = map[string]any{"reason": "content_filter"}
+	default:
+		response["status"] = "completed"
+	}
+}
+
+func chatUsageToResponses(u any) any {
+	usage, ok := u.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	result := map[string]any{
+		"input_tokens":  usage["prompt_tokens"],
+		"output_tokens": usage["completion_tokens"],
+		"total_tokens":  usage["total_tokens"],
+	}
+	if details, ok := usage["completion_tokens_details"].(map[string]any); ok {
+		result["output_tokens_details"] = map[string]any{"reasoning_tokens": details["reasoning_tokens"]}
+	}
+	if details, ok := usage["prompt_tokens_details"].(map[string]any); ok {
+		result["input_tokens_details"] = map[string]any{"cached_tokens": details["cached_tokens"]}
+	}
+	return result
+}
+
+func newResponsesID(prefix string) string {
+	return prefix + "_" + randomHex(16)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+type responsesStreamItem struct {
+	outputIndex int
+	item        map[string]any
+	text        strings.Builder
+}
+
+// responsesStream converts transformed chat completion chunks into Responses
+// API streaming events.
+type responsesStream struct {
+	ctx          context.Context
+	adapter      *Adapter
+	scope        cacheScope
+	w            io.Writer
+	flush        func()
+	sequence     int
+	response     map[string]any
+	output       []any
+	reasoning    *responsesStreamItem
+	message      *responsesStreamItem
+	tools        map[int]*responsesStreamItem
+	toolOrder    []int
+	usage        any
+	finishReason string
+	err          map[string]any
+	finished     bool
+}
+
+func (s *responsesStream) emit(eventType string, data map[string]any) {
+	data["type"] = eventType
+	data["sequence_number"] = s.sequence
+	s.sequence++
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		s.adapter.logger.Error("failed to marshal responses event", "type", eventType, "error", err)
+		return
+	}
+
+	fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", eventType, encoded)
+	s.flush()
+}
+
+func (s *responsesStream) start() {
+	s.emit("response.created", map[string]any{"response": s.response})
+	s.emit("response.in_progress", map[string]any{"response": s.response})
+}
+
+func (s *responsesStream) handleLine(line string) {
+	if !strings.HasPrefix(line, "data: ") {
+		return
+	}
+
+	data := strings.TrimPrefix(line, "data: ")
+	if data == "[DONE]" {
+		s.finish()
+		return
+	}
+
+	var chunk map[string]any
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return
+	}
+
+	if chatError, ok := chunk["error"].(map[string]any); ok {
+		s.fail(chatError)
+		return
+	}
+
+	if model, ok := chunk["model"]; ok {
+		s.response["model"] = model
+	}
+	if usage, ok := chunk["usage"]; ok && usage != nil {
+		s.usage = usage
+	}
+
+	choices, ok := chunk["choices"].([]any)
+	if !ok || len(choices) == 0 {
+		return
+	}
+
+	choice, ok := choices[0].(map[string]any)
+	if !ok {
+		return
+	}
+
+	if finishReason, ok := choice["finish_reason"].(string); ok {
+		s.finishReason = finishReason
+	}
+
+	delta, ok := choice["delta"].(map[string]any)
+	if !ok {
+		return
+	}
+
+	if reasoning, ok := delta["reasoning"].(string); ok && reasoning != "" {
+		s.reasoningDelta(reasoning)
+	}
+	if content, ok := delta["content"].(string); ok && content != "" {
+		s.contentDelta(content)
+	}
+	if toolCalls, ok := delta["tool_calls"].([]any); ok {
+		for _, tc := range toolCalls {
+			if toolCall, ok := tc.(map[string]any); ok {
+				s.toolCallDelta(toolCall)
+			}
+		}
+	}
+}
+
+func (s *responsesStream) addItem(item map[string]any) *responsesStreamItem {
+	streamItem := &responsesStreamItem{outputIndex: len(s.output), item: item}
+	s.output = append(s.output, item)
+	s.emit("response.output_item.added", map[string]any{
+		"output_index": streamItem.outputIndex,
+		"item":         item,
+	})
+	return streamItem
+}
+
+func (s *responsesStream) reasoningDelta(delta string) {
+	s.closeMessage()
+
+	if s.reasoning == nil {
+		s.reasoning = s.addItem(map[string]any{
+			"id":      newResponsesID("rs"),
+			"type":    "reasoning",
+			"summary": []any{},
+			"content": []any{},
+		})
+	}
+
+	s.reasoning.text.WriteString(delta)
+	if s.adapter.StripReasoning {
+		return
+	}
+	s.emit("response.reasoning_text.delta", map[string]any{
+		"item_id":       s.reasoning.item["id"],
+		"output_index":  s.reasoning.outputIndex,
+		"content_index": 0,
+		"delta":         delta,
+	})
+}
+
+func (s *responsesStream) contentDelta(delta string) {
+	s.closeReasoning()
+
+	if s.message == nil {
+		s.message = s.addItem(map[string]any{
+			"id":      newResponsesID("msg"),
+			"type":    "message",
+			"status":  "in_progress",
+			"role":    "assistant",
+			"content": []any{},
+		})
+		s.emit("response.content_part.added", map[string]any{
+			"item_id":       s.message.item["id"],
+			"output_index":  s.message.outputIndex,
+			"content_index": 0,
+			"part":          map[string]any{"type": "output_text", "text": "", "annotations": []any{}},
+		})
+	}
+
+	s.message.text.WriteString(delta)
+	s.emit("response.output_text.delta", map[string]any{
+		"item_id":       s.message.item["id"],
+		"output_index":  s.message.outputIndex,
+		"content_index": 0,
+		"delta":         delta,
+	})
+}
+
+func (s *responsesStream) toolCallDelta(toolCall map[string]any) {
+	index := 0
+	if i, ok := toolCall["index"].(float64); ok {
+		index = int(i)
+	}
+
+	function, _ := toolCall["function"].(map[string]any)
+
+	item, exists := s.tools[index]
+	if !exists {
+		s.closeReasoning()
+		s.closeMessage()
+
+		name, _ := function["name"].(string)
+		item = s.addItem(withSealedReasoning(map[string]any{
+			"id":        newResponsesID("fc"),
+			"type":      "function_call",
+			"status":    "in_progress",
+			"call_id":   toolCall["id"],
+			"name":      name,
+			"arguments": "",
+		}, toolCall))
+		s.tools[index] = item
+		s.toolOrder = append(s.toolOrder, index)
+	}
+
+	if arguments, ok := function["arguments"].(string); ok && arguments != "" {
+		item.text.WriteString(arguments)
+		s.emit("response.function_call_arguments.delta", map[string]any{
+			"item_id":      item.item["id"],
+			"output_index": item.outputIndex,
+			"delta":        arguments,
+		})
+	}
+}
+
+func (s *responsesStream) closeReasoning() {
+	if s.reasoning == nil {
+		return
+	}
+
+	text := s.reasoning.text.String()
+	item := s.reasoning.item
+	id, _ := item["id"].(string)
+	if !s.scope.noStore {
+		s.adapter.storeReasoning(s.scope.namespace, id, ReasoningItem{ID: id, Content: text})
+	}
+
+	if !s.adapter.StripReasoning {
+		s.emit("response.reasoning_text.done", map[string]any{
+			"item_id":       id,
+			"output_index":  s.reasoning.outputIndex,
+			"content_index": 0,
+			"text":          text,
+		})
+	}
+	item["content"] = s.adapter.reasoningItemContent(text)
+	if s.adapter.Summarizer != nil {
+		s.emitSummary(text)
+	}
+	s.emit("response.output_item.done", map[string]any{
+		"output_index": s.reasoning.outputIndex,
+		"item":         item,
+	})
+	s.reasoning = nil
+}
+
+// emitSummary summarizes the text of the open reasoning item and streams
+// the summary as a single part.
+func (s *responsesStream) emitSummary(text string) {
+	model, _ := s.response["model"].(string)
+	summary := s.adapter.summarizeReasoning(s.ctx, model, text)
+	if summary == "" {
+		return
+	}
+
+	item := s.reasoning.item
+	part := map[string]any{"type": "summary_text", "text": summary}
+	s.emit("response.reasoning_summary_part.added", map[string]any{
+		"item_id":       item["id"],
+		"output_index":  s.reasoning.outputIndex,
+		"summary_index": 0,
+		"part":          map[string]any{"type": "summary_text", "text": ""},
+	})
+	s.emit("response.reasoning_summary_text.delta", map[string]any{
+		"item_id":       item["id"],
+		"output_index":  s.reasoning.outputIndex,
+		"summary_index": 0,
+		"delta":         summary,
+	})
+	s.emit("response.reasoning_summary_text.done", map[string]any{
+		"item_id":       item["id"],
+		"output_index":  s.reasoning.outputIndex,
+		"summary_index": 0,
+		"text":          summary,
+	})
+	s.emit("response.reasoning_summary_part.done", map[string]any{
+		"item_id":       item["id"],
+		"output_index":  s.reasoning.outputIndex,
+		"summary_index": 0,
+		"part":          part,
+	})
+	item["summary"] = []any{part}
+}
+
+func (s *responsesStream) closeMessage() {
+	if s.message == nil {
+		return
+	}
+
+	text := s.message.text.String()
+	item := s.message.item
+	part := map[string]any{"type": "output_text", "text": text, "annotations": []any{}}
+
+	s.emit("response.output_text.done", map[string]any{
+		"item_id":       item["id"],
+		"output_index":  s.message.outputIndex,
+		"content_index": 0,
+		"text":          text,
+	})
+	s.emit("response.content_part.done", map[string]any{
+		"item_id":       item["id"],
+		"output_index":  s.message.outputIndex,
+		"content_index": 0,
+		"part":          part,
+	})
+	item["status"] = "completed"
+	item["content"] = []any{part}
+	s.emit("response.output_item.done", map[string]any{
+		"output_index": s.message.outputIndex,
+		"item":         item,
+	})
+	s.message = nil
+}
+
+func (s *responsesStream) closeTools() {
+	for _, index := range s.toolOrder {
+		tool := s.tools[index]
+		arguments := tool.text.String()
+
+		s.emit("response.function_call_arguments.done", map[string]any{
+			"item_id":      tool.item["id"],
+			"output_index": tool.outputIndex,
+			"arguments":    arguments,
+		})
+		tool.item["status"] = "completed"
+		tool.item["arguments"] = arguments
+		s.emit("response.output_item.done", map[string]any{
+			"output_index": tool.outputIndex,
+			"item":         tool.item,
+		})
+	}
+	s.tools = make(map[int]*responsesStreamItem)
+	s.toolOrder = nil
+}
+
+func (s *responsesStream) finish() {
+	if s.finished {
+		return
+	}
+	s.finished = true
+
+	s.closeReasoning()
+	s.closeMessage()
+	s.closeTools()
+
+	if s.output == nil {
+		s.output = []any{}
+	}
+	s.response["output"] = s.output
+	s.response["usage"] = chatUsageToResponses(s.usage)
+	setResponseStatus(s.response, s.finishReason)
+
+	eventType := "response.completed"
+	if s.err != nil {
+		s.response["status"] = "failed"
+		s.response["error"] = s.err
+		eventType = "response.failed"
+	} else if s.response["status"] == "incomplete" {
+		eventType = "response.incomplete"
+	}
+	s.emit(eventType, map[string]any{"response": s.response})
+}
+
+// fail reports a chat stream error to the client. The response itself is
+// marked as failed once the stream finishes.
+func (s *responsesStream) fail(chatError map[string]any) {
+	code, _ := chatError["code"].(string)
+	if code == "" {
+		code = "server_error"
+	}
+	s.err = map[string]any{"code": code, "message": chatError["message"]}
+
+	s.emit("error", map[string]any{
+		"code":    code,
+		"message": chatError["message"],
+		"param":   nil,
+	})
+}
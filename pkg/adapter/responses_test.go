@@ -0,0 +1,188 @@
+package adapter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponsesToChat_StringInput(t *testing.T) {
+	adapter := newTestAdapter()
+
+	chat, err := adapter.responsesToChat(cacheScope{}, map[string]any{
+		"model":             "gpt-oss-20b",
+		"instructions":      "Be brief.",
+		"input":             "Hello",
+		"max_output_tokens": float64(128),
+		"reasoning":         map[string]any{"effort": "high", "summary": "auto"},
+		"tools": []any{
+			map[string]any{"type": "function", "name": "get_weather", "parameters": map[string]any{"type": "object"}},
+			map[string]any{"type": "web_search"},
+		},
+		"tool_choice": map[string]any{"type": "function", "name": "get_weather"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "gpt-oss-20b", chat["model"])
+	assert.Equal(t, float64(128), chat["max_tokens"])
+	assert.Equal(t, map[string]any{"effort": "high"}, chat["reasoning"])
+	assert.Equal(t, []any{
+		map[string]any{"role": "system", "content": "Be brief."},
+		map[string]any{"role": "user", "content": "Hello"},
+	}, chat["messages"])
+	assert.Equal(t, []any{
+		map[string]any{"type": "function", "function": map[string]any{"name": "get_weather", "parameters": map[string]any{"type": "object"}}},
+	}, chat["tools"])
+	assert.Equal(t, map[string]any{"type": "function", "function": map[string]any{"name": "get_weather"}}, chat["tool_choice"])
+}
+
+func TestResponsesToChat_ItemInput(t *testing.T) {
+	adapter := newTestAdapter()
+	adapter.cache.Put("", "rs_cached", ReasoningItem{ID: "rs_cached", Content: "cached thoughts"})
+
+	chat, err := adapter.responsesToChat(cacheScope{}, map[string]any{
+		"input": []any{
+			map[string]any{"role": "user", "content": []any{map[string]any{"type": "input_text", "text": "What's the weather?"}}},
+			map[string]any{"type": "reasoning", "id": "rs_cached"},
+			map[string]any{"type": "function_call", "call_id": "call_1", "name": "get_weather", "arguments": "{}"},
+			map[string]any{"type": "function_call", "call_id": "call_2", "name": "get_time", "arguments": "{}"},
+			map[string]any{"type": "function_call_output", "call_id": "call_1", "output": "sunny"},
+			map[string]any{"type": "function_call_output", "call_id": "call_2", "output": "noon"},
+		},
+	})
+	require.NoError(t, err)
+
+	messages := chat["messages"].([]any)
+	require.Len(t, messages, 4)
+
+	assert.Equal(t, map[string]any{"role": "user", "content": "What's the weather?"}, messages[0])
+
+	assistant := messages[1].(map[string]any)
+	assert.Equal(t, "assistant", assistant["role"])
+	assert.Equal(t, "cached thoughts", assistant["reasoning_content"])
+	assert.Len(t, assistant["tool_calls"], 2)
+
+	assert.Equal(t, map[string]any{"role": "tool", "tool_call_id": "call_1", "content": "sunny"}, messages[2])
+	assert.Equal(t, map[string]any{"role": "tool", "tool_call_id": "call_2", "content": "noon"}, messages[3])
+}
+
+func TestResponsesToChat_Invalid(t *testing.T) {
+	adapter := newTestAdapter()
+
+	_, err := adapter.responsesToChat(cacheScope{}, map[string]any{"model": "gpt-oss-20b"})
+	assert.Error(t, err)
+
+	_, err = adapter.responsesToChat(cacheScope{}, map[string]any{"input": float64(1)})
+	assert.Error(t, err)
+}
+
+func TestChatToResponse(t *testing.T) {
+	adapter := newTestAdapter()
+
+	response := adapter.chatToResponse(cacheScope{}, map[string]any{
+		"model": "gpt-oss-20b",
+		"choices": []any{
+			map[string]any{
+				"finish_reason": "tool_calls",
+				"message": map[string]any{
+					"role":      "assistant",
+					"reasoning": "Need the weather.",
+					"tool_calls": []any{
+						map[string]any{"id": "call_1", "type": "function", "function": map[string]any{"name": "get_weather", "arguments": "{}"}},
+					},
+				},
+			},
+		},
+		"usage": map[string]any{"prompt_tokens": float64(10), "completion_tokens": float64(5), "total_tokens": float64(15)},
+	}, map[string]any{"model": "gpt-oss-20b"})
+
+	assert.Equal(t, "response", response["object"])
+	assert.Equal(t, "completed", response["status"])
+
+	output := response["output"].([]any)
+	require.Len(t, output, 2)
+
+	reasoning := output[0].(map[string]any)
+	assert.Equal(t, "reasoning", reasoning["type"])
+	cached, found := adapter.cache.Get("", reasoning["id"].(string))
+	assert.True(t, found)
+	assert.Equal(t, "Need the weather.", cached.Content)
+
+	call := output[1].(map[string]any)
+	assert.Equal(t, "function_call", call["type"])
+	assert.Equal(t, "call_1", call["call_id"])
+
+	assert.Equal(t, map[string]any{
+		"input_tokens":  float64(10),
+		"output_tokens": float64(5),
+		"total_tokens":  float64(15),
+	}, response["usage"])
+}
+
+func TestResponsesStream_Error(t *testing.T) {
+	var buf bytes.Buffer
+	stream := &responsesStream{
+		adapter:  newTestAdapter(),
+		w:        &buf,
+		flush:    func() {},
+		response: newResponseObject(map[string]any{}),
+		tools:    make(map[int]*responsesStreamItem),
+	}
+
+	stream.start()
+	stream.handleLine(`data: {"choices":[{"index":0,"delta":{"content":"Hel"}}]}`)
+	stream.handleLine(`data: {"error":{"message":"Upstream stream interrupted: unexpected EOF","type":"server_error","code":"stream_interrupted"}}`)
+	stream.handleLine(`data: [DONE]`)
+
+	output := buf.String()
+	assert.Contains(t, output, "event: error\n")
+	assert.Contains(t, output, "event: response.failed\n")
+	assert.Contains(t, output, `"code":"stream_interrupted"`)
+	assert.NotContains(t, output, "response.completed")
+}
+
+func TestChatToResponse_StripReasoning(t *testing.T) {
+	adapter := newTestAdapter()
+	adapter.StripReasoning = true
+
+	response := adapter.chatToResponse(cacheScope{}, map[string]any{
+		"choices": []any{map[string]any{
+			"finish_reason": "stop",
+			"message":       map[string]any{"role": "assistant", "reasoning": "Secret.", "content": "Hi"},
+		}},
+	}, map[string]any{})
+
+	reasoning := response["output"].([]any)[0].(map[string]any)
+	assert.Equal(t, "reasoning", reasoning["type"])
+	assert.Equal(t, []any{}, reasoning["content"])
+
+	// Echoing the empty item back restores the reasoning.
+	assert.Equal(t, "Secret.", adapter.responsesReasoningText(cacheScope{}, reasoning))
+}
+
+func TestResponsesStream_StripReasoning(t *testing.T) {
+	adapter := newTestAdapter()
+	adapter.StripReasoning = true
+
+	var buf bytes.Buffer
+	stream := &responsesStream{
+		adapter:  adapter,
+		w:        &buf,
+		flush:    func() {},
+		response: newResponseObject(map[string]any{}),
+		tools:    make(map[int]*responsesStreamItem),
+	}
+
+	stream.start()
+	stream.handleLine(`data: {"choices":[{"index":0,"delta":{"reasoning":"Secret."}}]}`)
+	stream.handleLine(`data: {"choices":[{"index":0,"delta":{"content":"Hi"},"finish_reason":"stop"}]}`)
+	stream.handleLine(`data: [DONE]`)
+	stream.finish()
+
+	output := buf.String()
+	assert.NotContains(t, output, "Secret.")
+	assert.NotContains(t, output, "response.reasoning_text")
+	assert.Contains(t, output, `"type":"reasoning"`)
+}
@@ -0,0 +1,561 @@
+package adapter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const responsesBackendPath = "/v1/responses"
+
+// responsesItemsField holds the reasoning items a chat message was
+// translated from, so that they can be sent back to the backend unchanged.
+const responsesItemsField = "reasoning_items"
+
+// chatToResponsesRequest translates an OpenAI chat completions request, after
+// reasoning injection, into a request for a Responses API backend. The
+// adapter keeps the conversation state itself, so nothing is stored
+// upstream.
+func chatToResponsesRequest(chat map[string]any, reasoningField string) map[string]any {
+	req := map[string]any{
+		"model": chat["model"],
+		"store": false,
+	}
+
+	for _, key := range []string{"stream", "temperature", "top_p", "parallel_tool_calls", "reasoning", "user", "metadata", "service_tier"} {
+		if value, ok := chat[key]; ok {
+			req[key] = value
+		}
+	}
+
+	// The chat API's top-level effort, which the Responses API nests.
+	if effort, ok := chat["reasoning_effort"]; ok {
+		reasoning, _ := req["reasoning"].(map[string]any)
+		if reasoning == nil {
+			reasoning = make(map[string]any)
+			req["reasoning"] = reasoning
+		}
+		if _, ok := reasoning["effort"]; !ok {
+			reasoning["effort"] = effort
+		}
+	}
+
+	for _, key := range []string{"max_tokens", "max_completion_tokens"} {
+		if value, ok := chat[key]; ok {
+			req["max_output_tokens"] = value
+		}
+	}
+
+	if tools, ok := chat["tools"].([]any); ok {
+		var responsesTools []any
+		for _, t := range tools {
+			tool, ok := t.(map[string]any)
+			if !ok {
+				continue
+			}
+			function, ok := tool["function"].(map[string]any)
+			if !ok {
+				responsesTools = append(responsesTools, tool)
+				continue
+			}
+			converted := map[string]any{"type": "function"}
+			for _, key := range []string{"name", "description", "parameters", "strict"} {
+				if value, ok := function[key]; ok {
+					converted[key] = value
+				}
+			}
+			responsesTools = append(responsesTools, converted)
+		}
+		req["tools"] = responsesTools
+	}
+
+	switch choice := chat["tool_choice"].(type) {
+	case string:
+		req["tool_choice"] = choice
+	case map[string]any:
+		function, _ := choice["function"].(map[string]any)
+		req["tool_choice"] = map[string]any{"type": "function", "name": function["name"]}
+	}
+
+	if format, ok := chat["response_format"].(map[string]any); ok {
+		textFormat := map[string]any{"type": format["type"]}
+		if schema, ok := format["json_schema"].(map[string]any); ok {
+			for key, value := range schema {
+				textFormat[key] = value
+			}
+		}
+		req["text"] = map[string]any{"format": textFormat}
+	}
+
+	var input []any
+	messages, _ := chat["messages"].([]any)
+	for _, msg := range messages {
+		message, ok := msg.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		role, _ := message["role"].(string)
+		switch role {
+		case "assistant":
+			input = append(input, assistantToResponsesItems(message, reasoningField)...)
+		case "tool":
+			output, _ := message["content"].(string)
+			if parts, ok := message["content"].([]any); ok {
+				output = joinTextParts(parts)
+			}
+			input = append(input, map[string]any{
+				"type":    "function_call_output",
+				"call_id": message["tool_call_id"],
+				"output":  output,
+			})
+		default:
+			input = append(input, map[string]any{
+				"type":    "message",
+				"role":    role,
+				"content": chatContentToResponses(message["content"], role),
+			})
+		}
+	}
+	if input == nil {
+		input = []any{}
+	}
+	req["input"] = input
+
+	return req
+}
+
+// assistantToResponsesItems translates an assistant message into its
+// reasoning, message and function call items. Reasoning items the message
+// was translated from are reused, IDs included; otherwise one is built from
+// the reasoning text.
+func assistantToResponsesItems(message map[string]any, reasoningField string) []any {
+	var items []any
+
+	if reasoningItems, ok := message[responsesItemsField].([]any); ok && len(reasoningItems) > 0 {
+		items = append(items, reasoningItems...)
+	} else if reasoning, ok := message[reasoningField].(string); ok && reasoning != "" {
+		items = append(items, map[string]any{
+			"type":    "reasoning",
+			"id":      newResponsesID("rs"),
+			"summary": []any{},
+			"content": []any{map[string]any{"type": "reasoning_text", "text": reasoning}},
+		})
+	}
+
+	switch content := chatContentToResponses(message["content"], "assistant").(type) {
+	case string:
+		if content != "" {
+			items = append(items, map[string]any{
+				"type":    "message",
+				"role":    "assistant",
+				"content": []any{map[string]any{"type": "output_text", "text": content}},
+			})
+		}
+	case []any:
+		if len(content) > 0 {
+			items = append(items, map[string]any{"type": "message", "role": "assistant", "content": content})
+		}
+	}
+
+	toolCalls, _ := message["tool_calls"].([]any)
+	for _, tc := range toolCalls {
+		toolCall, ok := tc.(map[string]any)
+		if !ok {
+			continue
+		}
+		function, _ := toolCall["function"].(map[string]any)
+		items = append(items, map[string]any{
+			"type":      "function_call",
+			"call_id":   toolCall["id"],
+			"name":      function["name"],
+			"arguments": function["arguments"],
+		})
+	}
+
+	return items
+}
+
+// chatContentToResponses converts chat message content into Responses
+// content: strings are kept, and parts are converted to input or output
+// parts depending on the role.
+func chatContentToResponses(content any, role string) any {
+	parts, ok := content.([]any)
+	if !ok {
+		if content == nil {
+			return ""
+		}
+		return content
+	}
+
+	textType := "input_text"
+	if role == "assistant" {
+		textType = "output_text"
+	}
+
+	var converted []any
+	for _, p := range parts {
+		part, ok := p.(map[string]any)
+		if !ok {
+			continue
+		}
+		switch part["type"] {
+		case "text":
+			converted = append(converted, map[string]any{"type": textType, "text": part["text"]})
+		case "image_url":
+			imageURL, _ := part["image_url"].(map[string]any)
+			image := map[string]any{"type": "input_image", "image_url": imageURL["url"]}
+			if detail, ok := imageURL["detail"]; ok {
+				image["detail"] = detail
+			}
+			converted = append(converted, image)
+		}
+	}
+	if converted == nil {
+		converted = []any{}
+	}
+	return converted
+}
+
+// joinTextParts concatenates the text of chat content parts.
+func joinTextParts(parts []any) string {
+	var text strings.Builder
+	for _, p := range parts {
+		if part, ok := p.(map[string]any); ok {
+			if t, ok := part["text"].(string); ok {
+				text.WriteString(t)
+			}
+		}
+	}
+	return text.String()
+}
+
+// responsesBackendToChat replaces the body of a Responses API response with
+// its OpenAI chat completions equivalent, so the rest of the pipeline can
+// treat it like any other backend.
+func (a *Adapter) responsesBackendToChat(resp *http.Response) *http.Response {
+	if resp.StatusCode >= 400 {
+		return resp
+	}
+
+	if strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream") {
+		reader, writer := io.Pipe()
+		go a.responsesStreamToChat(resp.Body, writer)
+		return replaceResponseBody(resp, "text/event-stream", reader)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		a.logger.Error("failed to read responses response", "error", err)
+		return replaceResponseBody(resp, "application/json", io.NopCloser(bytes.NewReader(body)))
+	}
+
+	var response map[string]any
+	if err := json.Unmarshal(body, &response); err != nil {
+		a.logger.Error("failed to unmarshal responses response", "error", err)
+		return replaceResponseBody(resp, "application/json", io.NopCloser(bytes.NewReader(body)))
+	}
+
+	encoded, _ := json.Marshal(a.responsesToChatCompletion(response))
+	return replaceResponseBody(resp, "application/json", io.NopCloser(bytes.NewReader(encoded)))
+}
+
+// responsesToChatCompletion translates a Responses API response object into
+// a chat completion.
+func (a *Adapter) responsesToChatCompletion(response map[string]any) map[string]any {
+	message := map[string]any{"role": "assistant", "content": ""}
+
+	var content, reasoning strings.Builder
+	var reasoningItems, toolCalls []any
+	output, _ := response["output"].([]any)
+	for _, o := range output {
+		item, ok := o.(map[string]any)
+		if !ok {
+			continue
+		}
+		switch item["type"] {
+		case "reasoning":
+			reasoningItems = append(reasoningItems, item)
+			reasoning.WriteString(responsesItemReasoning(item))
+		case "message":
+			parts, _ := item["content"].([]any)
+			for _, p := range parts {
+				part, ok := p.(map[string]any)
+				if !ok {
+					continue
+				}
+				switch part["type"] {
+				case "output_text":
+					text, _ := part["text"].(string)
+					content.WriteString(text)
+				case "refusal":
+					message["refusal"] = part["refusal"]
+				}
+			}
+		case "function_call":
+			toolCalls = append(toolCalls, responsesChatToolCall(item))
+		}
+	}
+
+	message["content"] = content.String()
+	if reasoning.Len() > 0 {
+		message[a.Provider.Reasoning] = reasoning.String()
+	}
+	if len(reasoningItems) > 0 {
+		message[responsesItemsField] = reasoningItems
+	}
+	if len(toolCalls) > 0 {
+		message["tool_calls"] = toolCalls
+	}
+
+	completion := map[string]any{
+		"id":      response["id"],
+		"object":  "chat.completion",
+		"created": responsesCreated(response),
+		"model":   response["model"],
+		"choices": []any{map[string]any{
+			"index":         0,
+			"message":       message,
+			"finish_reason": responsesFinishReason(response, len(toolCalls) > 0),
+		}},
+	}
+	if usage := responsesUsageToChat(response["usage"]); usage != nil {
+		completion["usage"] = usage
+	}
+	return completion
+}
+
+// responsesItemReasoning returns the text of a reasoning item, falling back
+// to its summary for backends that only return one.
+func responsesItemReasoning(item map[string]any) string {
+	for _, key := range []string{"content", "summary"} {
+		parts, _ := item[key].([]any)
+		if text := joinTextParts(parts); text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+func responsesChatToolCall(item map[string]any) map[string]any {
+	return map[string]any{
+		"id":   item["call_id"],
+		"type": "function",
+		"function": map[string]any{
+			"name":      item["name"],
+			"arguments": item["arguments"],
+		},
+	}
+}
+
+func responsesFinishReason(response map[string]any, hasToolCalls bool) string {
+	if hasToolCalls {
+		return "tool_calls"
+	}
+	if response["status"] == "incomplete" {
+		details, _ := response["incomplete_details"].(map[string]any)
+		if details["reason"] == "content_filter" {
+			return "content_filter"
+		}
+		return "length"
+	}
+	return "stop"
+}
+
+func responsesUsageToChat(u any) map[string]any {
+	usage, ok := u.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	result := map[string]any{
+		"prompt_tokens":     usage["input_tokens"],
+		"completion_tokens": usage["output_tokens"],
+		"total_tokens":      usage["total_tokens"],
+	}
+	if details, ok := usage["output_tokens_details"].(map[string]any); ok {
+		result["completion_tokens_details"] = map[string]any{"reasoning_tokens": details["reasoning_tokens"]}
+	}
+	if details, ok := usage["input_tokens_details"].(map[string]any); ok {
+		result["prompt_tokens_details"] = map[string]any{"cached_tokens": details["cached_tokens"]}
+	}
+	return result
+}
+
+func responsesCreated(response map[string]any) int64 {
+	if created, ok := response["created_at"].(float64); ok {
+		return int64(created)
+	}
+	return time.Now().Unix()
+}
+
+// responsesStreamState tracks a Responses API event stream being converted
+// into chat completion chunks.
+type responsesStreamState struct {
+	id      any
+	model   any
+	created int64
+	started bool
+	// toolCalls maps the output index of a function call item to its index
+	// among the tool calls.
+	toolCalls map[int]int
+	// streamedReasoning records the output indices of reasoning items whose
+	// text was streamed as deltas.
+	streamedReasoning map[int]bool
+}
+
+// responsesStreamToChat converts a Responses API event stream into chat
+// completion chunks.
+func (a *Adapter) responsesStreamToChat(body io.ReadCloser, writer *io.PipeWriter) {
+	defer body.Close()
+
+	state := &responsesStreamState{
+		id:                "chatcmpl-" + randomHex(16),
+		created:           time.Now().Unix(),
+		toolCalls:         make(map[int]int),
+		streamedReasoning: make(map[int]bool),
+	}
+
+	write := func(delta map[string]any, finishReason any, usage map[string]any) error {
+		if !state.started {
+			delta["role"] = "assistant"
+			state.started = true
+		}
+		chunk := map[string]any{
+			"id":      state.id,
+			"object":  "chat.completion.chunk",
+			"created": state.created,
+			"model":   state.model,
+			"choices": []any{map[string]any{
+				"index":         0,
+				"delta":         delta,
+				"finish_reason": finishReason,
+			}},
+		}
+		if usage != nil {
+			chunk["usage"] = usage
+		}
+		encoded, err := json.Marshal(chunk)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(writer, "data: %s\n\n", encoded)
+		return err
+	}
+
+	reader := newSSEReader(body, a.StreamMaxLineSize)
+	for {
+		event, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			writer.CloseWithError(err)
+			return
+		}
+		if !event.HasData || event.Data == "[DONE]" {
+			continue
+		}
+
+		var data map[string]any
+		if err := json.Unmarshal([]byte(event.Data), &data); err != nil {
+			a.logger.Debug("skipping malformed responses stream event", "error", err)
+			continue
+		}
+
+		eventType, _ := data["type"].(string)
+		if eventType == "" {
+			eventType = event.Event
+		}
+		outputIndex := 0
+		if n, ok := data["output_index"].(float64); ok {
+			outputIndex = int(n)
+		}
+
+		var delta map[string]any
+		switch eventType {
+		case "response.created", "response.in_progress":
+			if response, ok := data["response"].(map[string]any); ok {
+				if id, ok := response["id"]; ok {
+					state.id = id
+				}
+				state.model = response["model"]
+				state.created = responsesCreated(response)
+			}
+
+		case "response.output_text.delta":
+			delta = map[string]any{"content": data["delta"]}
+
+		case "response.reasoning_text.delta":
+			state.streamedReasoning[outputIndex] = true
+			delta = map[string]any{a.Provider.Reasoning: data["delta"]}
+
+		case "response.output_item.added":
+			item, _ := data["item"].(map[string]any)
+			if item["type"] != "function_call" {
+				continue
+			}
+			index := len(state.toolCalls)
+			state.toolCalls[outputIndex] = index
+			toolCall := responsesChatToolCall(item)
+			toolCall["index"] = index
+			delta = map[string]any{"tool_calls": []any{toolCall}}
+
+		case "response.function_call_arguments.delta":
+			index, ok := state.toolCalls[outputIndex]
+			if !ok {
+				continue
+			}
+			delta = map[string]any{"tool_calls": []any{map[string]any{
+				"index":    index,
+				"function": map[string]any{"arguments": data["delta"]},
+			}}}
+
+		case "response.output_item.done":
+			item, _ := data["item"].(map[string]any)
+			if item["type"] != "reasoning" {
+				continue
+			}
+			delta = map[string]any{responsesItemsField: []any{item}}
+			if !state.streamedReasoning[outputIndex] {
+				if text := responsesItemReasoning(item); text != "" {
+					delta[a.Provider.Reasoning] = text
+				}
+			}
+
+		case "response.completed", "response.incomplete":
+			response, _ := data["response"].(map[string]any)
+			finishReason := responsesFinishReason(response, len(state.toolCalls) > 0)
+			if err := write(map[string]any{}, finishReason, responsesUsageToChat(response["usage"])); err != nil {
+				return
+			}
+			fmt.Fprint(writer, "data: [DONE]\n\n")
+			writer.Close()
+			return
+
+		case "response.failed":
+			response, _ := data["response"].(map[string]any)
+			writer.CloseWithError(fmt.Errorf("responses stream failed: %v", response["error"]))
+			return
+
+		case "error":
+			writer.CloseWithError(fmt.Errorf("responses stream error: %v", data["message"]))
+			return
+		}
+
+		if delta == nil {
+			continue
+		}
+		if err := write(delta, nil, nil); err != nil {
+			return
+		}
+	}
+
+	writer.CloseWithError(errors.New("responses stream ended before the response completed"))
+}
@@ -0,0 +1,264 @@
+package adapter
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/responses"
+)
+
+func newResponsesBackendTestServer(t *testing.T, handler http.HandlerFunc) *Adapter {
+	upstream := httptest.NewServer(handler)
+	t.Cleanup(upstream.Close)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewAdapter(upstream.URL, NewLRUCache(10), logger, responses.NewProvider())
+}
+
+func TestChatToResponsesRequest(t *testing.T) {
+	reasoningItem := map[string]any{
+		"type":    "reasoning",
+		"id":      "rs_1",
+		"summary": []any{},
+		"content": []any{map[string]any{"type": "reasoning_text", "text": "Look it up."}},
+	}
+	chat := map[string]any{
+		"model":            "gpt-oss-120b",
+		"stream":           true,
+		"reasoning_effort": "high",
+		"max_tokens":       float64(128),
+		"tool_choice":      map[string]any{"type": "function", "function": map[string]any{"name": "lookup"}},
+		"tools": []any{map[string]any{"type": "function", "function": map[string]any{
+			"name":       "lookup",
+			"parameters": map[string]any{"type": "object"},
+		}}},
+		"messages": []any{
+			map[string]any{"role": "system", "content": "Be brief."},
+			map[string]any{"role": "user", "content": []any{
+				map[string]any{"type": "text", "text": "What is this?"},
+				map[string]any{"type": "image_url", "image_url": map[string]any{"url": "data:image/png;base64,AAAA"}},
+			}},
+			map[string]any{
+				"role":              "assistant",
+				"content":           nil,
+				"reasoning_content": "Look it up.",
+				"reasoning_items":   []any{reasoningItem},
+				"tool_calls": []any{map[string]any{"id": "call_1", "type": "function", "function": map[string]any{
+					"name":      "lookup",
+					"arguments": `{"q":"cat"}`,
+				}}},
+			},
+			map[string]any{"role": "tool", "tool_call_id": "call_1", "content": "a cat"},
+			map[string]any{"role": "assistant", "content": "A cat.", "reasoning_content": "Answer."},
+		},
+	}
+
+	req := chatToResponsesRequest(chat, "reasoning_content")
+
+	assert.Equal(t, "gpt-oss-120b", req["model"])
+	assert.Equal(t, false, req["store"])
+	assert.Equal(t, true, req["stream"])
+	assert.Equal(t, float64(128), req["max_output_tokens"])
+	assert.Equal(t, map[string]any{"effort": "high"}, req["reasoning"])
+	assert.Equal(t, map[string]any{"type": "function", "name": "lookup"}, req["tool_choice"])
+	assert.Equal(t, []any{map[string]any{
+		"type":       "function",
+		"name":       "lookup",
+		"parameters": map[string]any{"type": "object"},
+	}}, req["tools"])
+
+	input := req["input"].([]any)
+	require.Len(t, input, 7)
+	assert.Equal(t, map[string]any{"type": "message", "role": "system", "content": "Be brief."}, input[0])
+	assert.Equal(t, map[string]any{"type": "message", "role": "user", "content": []any{
+		map[string]any{"type": "input_text", "text": "What is this?"},
+		map[string]any{"type": "input_image", "image_url": "data:image/png;base64,AAAA"},
+	}}, input[1])
+	assert.Equal(t, reasoningItem, input[2])
+	assert.Equal(t, map[string]any{
+		"type":      "function_call",
+		"call_id":   "call_1",
+		"name":      "lookup",
+		"arguments": `{"q":"cat"}`,
+	}, input[3])
+	assert.Equal(t, map[string]any{"type": "function_call_output", "call_id": "call_1", "output": "a cat"}, input[4])
+
+	// Reasoning without items is sent as a new item.
+	rebuilt := input[5].(map[string]any)
+	assert.Equal(t, "reasoning", rebuilt["type"])
+	assert.Equal(t, []any{map[string]any{"type": "reasoning_text", "text": "Answer."}}, rebuilt["content"])
+	assert.Equal(t, map[string]any{
+		"type":    "message",
+		"role":    "assistant",
+		"content": []any{map[string]any{"type": "output_text", "text": "A cat."}},
+	}, input[6])
+}
+
+func TestResponsesBackend_Blocking(t *testing.T) {
+	reasoningItem := map[string]any{
+		"type":    "reasoning",
+		"id":      "rs_upstream",
+		"summary": []any{},
+		"content": []any{map[string]any{"type": "reasoning_text", "text": "Need weather."}},
+	}
+
+	var requests []map[string]any
+	adapter := newResponsesBackendTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/responses", r.URL.Path)
+
+		var req map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		requests = append(requests, req)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":         "resp_1",
+			"object":     "response",
+			"created_at": 1754395200,
+			"model":      "gpt-oss-120b",
+			"status":     "completed",
+			"output": []any{
+				reasoningItem,
+				map[string]any{"type": "function_call", "id": "fc_1", "call_id": "call_1", "name": "get_weather", "arguments": `{"city":"Paris"}`},
+			},
+			"usage": map[string]any{"input_tokens": 10, "output_tokens": 5, "total_tokens": 15},
+		})
+	})
+
+	body := `{"model":"gpt-oss-120b","reasoning_effort":"low","messages":[{"role":"user","content":"Weather in Paris?"}]}`
+	r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, requests, 1)
+	assert.Equal(t, map[string]any{"effort": "low"}, requests[0]["reasoning"])
+
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "chat.completion", resp["object"])
+	assert.Equal(t, map[string]any{"prompt_tokens": float64(10), "completion_tokens": float64(5), "total_tokens": float64(15)}, resp["usage"])
+
+	choice := resp["choices"].([]any)[0].(map[string]any)
+	assert.Equal(t, "tool_calls", choice["finish_reason"])
+	message := choice["message"].(map[string]any)
+	assert.Equal(t, "Need weather.", message["reasoning"])
+	assert.Equal(t, []any{map[string]any{
+		"id":       "call_1",
+		"type":     "function",
+		"function": map[string]any{"name": "get_weather", "arguments": `{"city":"Paris"}`},
+	}}, message["tool_calls"])
+
+	// The client drops the reasoning; the next turn gets the backend's item
+	// back from the cache.
+	body = `{"model":"gpt-oss-120b","messages":[
+		{"role":"user","content":"Weather in Paris?"},
+		{"role":"assistant","content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]},
+		{"role":"tool","tool_call_id":"call_1","content":"Sunny"}
+	]}`
+	r = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	w = httptest.NewRecorder()
+	adapter.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, requests, 2)
+
+	input := requests[1]["input"].([]any)
+	require.Len(t, input, 4)
+	assert.Equal(t, reasoningItem, input[1])
+	assert.Equal(t, "function_call", input[2].(map[string]any)["type"])
+	assert.Equal(t, "function_call_output", input[3].(map[string]any)["type"])
+}
+
+func TestResponsesBackend_Streaming(t *testing.T) {
+	events := []map[string]any{
+		{"type": "response.created", "response": map[string]any{"id": "resp_1", "model": "gpt-oss-120b", "created_at": 1754395200}},
+		{"type": "response.output_item.added", "output_index": 0, "item": map[string]any{"type": "reasoning", "id": "rs_upstream"}},
+		{"type": "response.reasoning_text.delta", "output_index": 0, "delta": "Need "},
+		{"type": "response.reasoning_text.delta", "output_index": 0, "delta": "weather."},
+		{"type": "response.output_item.done", "output_index": 0, "item": map[string]any{
+			"type":    "reasoning",
+			"id":      "rs_upstream",
+			"summary": []any{},
+			"content": []any{map[string]any{"type": "reasoning_text", "text": "Need weather."}},
+		}},
+		{"type": "response.output_item.added", "output_index": 1, "item": map[string]any{"type": "function_call", "call_id": "call_1", "name": "get_weather", "arguments": ""}},
+		{"type": "response.function_call_arguments.delta", "output_index": 1, "delta": `{"city":`},
+		{"type": "response.function_call_arguments.delta", "output_index": 1, "delta": `"Paris"}`},
+		{"type": "response.completed", "response": map[string]any{
+			"status": "completed",
+			"usage":  map[string]any{"input_tokens": 10, "output_tokens": 5, "total_tokens": 15},
+		}},
+	}
+
+	adapter := newResponsesBackendTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			data, _ := json.Marshal(event)
+			w.Write([]byte("event: " + event["type"].(string) + "\ndata: " + string(data) + "\n\n"))
+		}
+	})
+
+	body := `{"model":"gpt-oss-120b","stream":true,"messages":[{"role":"user","content":"Weather in Paris?"}]}`
+	r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var reasoning, arguments strings.Builder
+	var toolCallID, finishReason any
+	done := false
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		if data == "[DONE]" {
+			done = true
+			continue
+		}
+
+		var chunk map[string]any
+		require.NoError(t, json.Unmarshal([]byte(data), &chunk))
+		assert.Equal(t, "resp_1", chunk["id"])
+		for _, c := range chunk["choices"].([]any) {
+			choice := c.(map[string]any)
+			delta := choice["delta"].(map[string]any)
+			if text, ok := delta["reasoning"].(string); ok {
+				reasoning.WriteString(text)
+			}
+			toolCalls, _ := delta["tool_calls"].([]any)
+			for _, tc := range toolCalls {
+				toolCall := tc.(map[string]any)
+				if id, ok := toolCall["id"]; ok {
+					toolCallID = id
+				}
+				function := toolCall["function"].(map[string]any)
+				if text, ok := function["arguments"].(string); ok {
+					arguments.WriteString(text)
+				}
+			}
+			if choice["finish_reason"] != nil {
+				finishReason = choice["finish_reason"]
+			}
+		}
+	}
+
+	assert.True(t, done)
+	assert.Equal(t, "Need weather.", reasoning.String())
+	assert.Equal(t, "call_1", toolCallID)
+	assert.Equal(t, `{"city":"Paris"}`, arguments.String())
+	assert.Equal(t, "tool_calls", finishReason)
+
+	item, found := adapter.cache.Get("", "call_1")
+	require.True(t, found)
+	assert.Contains(t, item.Content, `"id":"rs_upstream"`)
+}
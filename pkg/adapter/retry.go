@@ -0,0 +1,113 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// DefaultRetryStatuses are the upstream statuses retried by default: those
+// llama.cpp and vLLM return while a model is loading or a server restarts.
+var DefaultRetryStatuses = []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+// RetryPolicy retries upstream chat requests that fail to connect or return
+// one of Statuses, waiting with exponential backoff and jitter between
+// attempts.
+type RetryPolicy struct {
+	MaxRetries int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	Statuses   []int
+}
+
+func NewRetryPolicy(maxRetries int, backoff, maxBackoff time.Duration, statuses []int) *RetryPolicy {
+	return &RetryPolicy{
+		MaxRetries: maxRetries,
+		Backoff:    backoff,
+		MaxBackoff: maxBackoff,
+		Statuses:   statuses,
+	}
+}
+
+// retryable reports whether an attempt that returned resp and err should be
+// retried.
+func (p *RetryPolicy) retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled)
+	}
+	return slices.Contains(p.Statuses, resp.StatusCode)
+}
+
+// delay returns how long to wait before the given retry, counting from 1. A
+// Retry-After header on resp takes precedence over the backoff schedule, up
+// to MaxBackoff.
+func (p *RetryPolicy) delay(retry int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			return p.capped(time.Duration(seconds) * time.Second)
+		}
+	}
+
+	backoff := p.Backoff
+	for i := 1; i < retry && (p.MaxBackoff <= 0 || backoff < p.MaxBackoff); i++ {
+		backoff *= 2
+	}
+	backoff = p.capped(backoff)
+
+	// Jitter over the upper half of the backoff, so that clients retrying
+	// together do not hit a recovering backend at the same instant.
+	if backoff > 1 {
+		backoff = backoff/2 + rand.N(backoff/2)
+	}
+	return backoff
+}
+
+func (p *RetryPolicy) capped(d time.Duration) time.Duration {
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return d
+}
+
+// doWithRetry sends req, replaying its body according to the retry policy.
+// req must have been created with a buffered body so that GetBody is set.
+// Waiting stops early when ctx, the client's request context, is done.
+func (a *Adapter) doWithRetry(ctx context.Context, req *http.Request) (*http.Response, error) {
+	resp, err := a.client.Do(req)
+	if a.Retry == nil || req.GetBody == nil {
+		return resp, err
+	}
+
+	for retry := 1; retry <= a.Retry.MaxRetries && a.Retry.retryable(resp, err); retry++ {
+		delay := a.Retry.delay(retry, resp)
+		if err != nil {
+			a.logger.Warn("upstream request failed, retrying", "error", err, "retry", retry, "delay", delay)
+		} else {
+			a.logger.Warn("upstream returned retryable status, retrying", "status", resp.StatusCode, "retry", retry, "delay", delay)
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		body, bodyErr := req.GetBody()
+		if bodyErr != nil {
+			return nil, bodyErr
+		}
+		req.Body = body
+		resp, err = a.client.Do(req)
+	}
+
+	return resp, err
+}
@@ -0,0 +1,112 @@
+package adapter
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+)
+
+func TestRetryPolicy_Retryable(t *testing.T) {
+	policy := NewRetryPolicy(3, time.Millisecond, time.Second, DefaultRetryStatuses)
+
+	tests := []struct {
+		name string
+		resp *http.Response
+		err  error
+		want bool
+	}{
+		{"connection error", nil, errors.New("connection refused"), true},
+		{"service unavailable", &http.Response{StatusCode: http.StatusServiceUnavailable}, nil, true},
+		{"bad request", &http.Response{StatusCode: http.StatusBadRequest}, nil, false},
+		{"ok", &http.Response{StatusCode: http.StatusOK}, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, policy.retryable(tt.resp, tt.err))
+		})
+	}
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	policy := NewRetryPolicy(5, 100*time.Millisecond, 300*time.Millisecond, nil)
+
+	for retry, max := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 5: 300 * time.Millisecond} {
+		delay := policy.delay(retry, nil)
+		assert.GreaterOrEqual(t, delay, max/2)
+		assert.Less(t, delay, max)
+	}
+
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{"60"}}}
+	assert.Equal(t, 300*time.Millisecond, policy.delay(1, resp))
+}
+
+func TestForwardChatRequest_Retry(t *testing.T) {
+	var attempts atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Contains(t, string(body), `"model":"gpt-oss"`)
+
+		if attempts.Add(1) < 3 {
+			writeOpenAIError(w, http.StatusServiceUnavailable, "Loading model", "server_error", "")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"index":0,"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer upstream.Close()
+
+	tests := []struct {
+		name       string
+		maxRetries int
+		status     int
+		attempts   int32
+	}{
+		{"recovers", 3, http.StatusOK, 3},
+		{"exhausted", 1, http.StatusServiceUnavailable, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts.Store(0)
+
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			adapter := NewAdapter(upstream.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+			adapter.Retry = NewRetryPolicy(tt.maxRetries, time.Millisecond, 10*time.Millisecond, DefaultRetryStatuses)
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"gpt-oss","messages":[]}`))
+			rec := httptest.NewRecorder()
+			adapter.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Equal(t, tt.attempts, attempts.Load())
+		})
+	}
+}
+
+func TestForwardChatRequest_RetryConnectionRefused(t *testing.T) {
+	upstream := httptest.NewServer(http.NotFoundHandler())
+	target := upstream.URL
+	upstream.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter(target, NewLRUCache(10), logger, llamacpp.NewProvider())
+	adapter.Retry = NewRetryPolicy(2, time.Millisecond, 10*time.Millisecond, DefaultRetryStatuses)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"messages":[]}`))
+	rec := httptest.NewRecorder()
+	adapter.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusBadGateway, rec.Code)
+}
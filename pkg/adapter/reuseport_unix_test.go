@@ -0,0 +1,23 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package adapter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListen_ReusePort(t *testing.T) {
+	first, err := Listen("127.0.0.1:0", true)
+	require.NoError(t, err)
+	defer first.Close()
+
+	second, err := Listen(first.Addr().String(), true)
+	require.NoError(t, err, "a second instance binds the same address")
+	defer second.Close()
+
+	_, err = Listen(first.Addr().String(), false)
+	assert.Error(t, err)
+}
@@ -0,0 +1,245 @@
+package adapter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/types"
+)
+
+// providerHeader selects the provider for a single request when provider
+// routing is enabled.
+const providerHeader = "X-GPT-OSS-Provider"
+
+// AddRoute makes provider selectable per request, by the X-GPT-OSS-Provider
+// header or by prefixing the model name with the provider name, e.g.
+// "openrouter/openai/gpt-oss-120b". Requests are sent to the provider's
+// Target, or to the adapter's own target if it has none. Routes share the
+// adapter's cache and settings, so AddRoute must be called after the
+// adapter is configured.
+func (a *Adapter) AddRoute(provider types.Provider) {
+	target := provider.Target
+	if target == "" {
+		target = a.Target
+	}
+
+	if a.routes == nil {
+		a.routes = make(map[string]*Adapter)
+	}
+	a.routes[provider.Name] = a.newRoute(target, provider)
+}
+
+// ModelRoute sends requests for the models matching Pattern, a glob such as
+// "gpt-oss-120b" or "qwen*", to a provider. Target defaults to the
+// provider's Target, then to the adapter's own target. If Model is set, it
+// replaces the model name in the request.
+type ModelRoute struct {
+	Pattern  string
+	Provider string
+	Target   string
+	Model    string
+}
+
+// ParseModelRoute parses a route of the form
+// "pattern=provider[,target=URL][,model=name]", e.g.
+// "gpt-oss-120b=openrouter,model=openai/gpt-oss-120b".
+func ParseModelRoute(source string) (ModelRoute, error) {
+	pattern, rest, ok := strings.Cut(source, "=")
+	if !ok || pattern == "" {
+		return ModelRoute{}, fmt.Errorf("invalid model route %q, expected pattern=provider", source)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return ModelRoute{}, fmt.Errorf("invalid model route %q: bad pattern", source)
+	}
+
+	options := strings.Split(rest, ",")
+	route := ModelRoute{Pattern: pattern, Provider: options[0]}
+	if route.Provider == "" {
+		return ModelRoute{}, fmt.Errorf("invalid model route %q: missing provider", source)
+	}
+	for _, option := range options[1:] {
+		key, value, _ := strings.Cut(option, "=")
+		switch key {
+		case "target":
+			route.Target = value
+		case "model":
+			route.Model = value
+		default:
+			return ModelRoute{}, fmt.Errorf("invalid model route %q: unknown option %q", source, key)
+		}
+	}
+	return route, nil
+}
+
+// modelRoute is a ModelRoute bound to the adapter serving it.
+type modelRoute struct {
+	pattern string
+	model   string
+	adapter *Adapter
+}
+
+// AddModelRoute sends requests for the models matching route.Pattern to
+// provider, which must be the provider route.Provider names. Routes are
+// tried in the order they were added, after the provider header and model
+// prefix of AddRoute. Like AddRoute, it must be called after the adapter is
+// configured.
+func (a *Adapter) AddModelRoute(route ModelRoute, provider types.Provider) error {
+	if _, err := path.Match(route.Pattern, ""); err != nil {
+		return fmt.Errorf("invalid model route pattern %q", route.Pattern)
+	}
+
+	target := route.Target
+	if target == "" {
+		target = provider.Target
+	}
+	if target == "" {
+		target = a.Target
+	}
+
+	// Routes to the same backend share an adapter, and so its queue and
+	// circuit breaker.
+	var adapter *Adapter
+	for _, existing := range a.routeAdapters() {
+		if existing.Target == target && existing.Provider.Name == provider.Name {
+			adapter = existing
+			break
+		}
+	}
+	if adapter == nil {
+		adapter = a.newRoute(target, provider)
+	}
+
+	a.modelRoutes = append(a.modelRoutes, modelRoute{pattern: route.Pattern, model: route.Model, adapter: adapter})
+	return nil
+}
+
+// routeAdapters returns the distinct adapters of the provider and model
+// routes and the replicas.
+func (a *Adapter) routeAdapters() []*Adapter {
+	adapters := make([]*Adapter, 0, len(a.routes)+len(a.modelRoutes))
+	for _, route := range a.routes {
+		adapters = append(adapters, route)
+	}
+	for _, route := range a.modelRoutes {
+		if !slices.Contains(adapters, route.adapter) {
+			adapters = append(adapters, route.adapter)
+		}
+	}
+	for _, replica := range a.replicas {
+		if !slices.Contains(adapters, replica) {
+			adapters = append(adapters, replica)
+		}
+	}
+	return adapters
+}
+
+// newRoute returns an adapter that sends requests to target with provider,
+// sharing a's cache, settings and counters. It copies a, so that every
+// setting carries over, and only replaces the state that belongs to a
+// single target: the queue and circuit breaker of another target, the
+// coalescer, the health check and, for another target, the client. Routes
+// have no routes, replicas or fallback of their own.
+func (a *Adapter) newRoute(target string, provider types.Provider) *Adapter {
+	route := new(Adapter)
+	*route = *a
+	route.Target = target
+	route.Provider = provider
+	if a.Queue != nil && target != a.Target {
+		route.Queue = NewRequestQueue(a.Queue.MaxConcurrent, a.Queue.MaxQueued, a.Queue.Timeout)
+	}
+	if a.Breaker != nil && target != a.Target {
+		route.Breaker = NewCircuitBreaker(a.Breaker.Threshold, a.Breaker.MinRequests, a.Breaker.Window, a.Breaker.Cooldown)
+	}
+	route.Health = nil
+	route.FallbackModels = nil
+	route.coalescer = newCoalescer()
+	route.routes = nil
+	route.modelRoutes = nil
+	route.fallback = nil
+	route.replicas = nil
+	if target != a.Target {
+		route.ownUpstream()
+	}
+	route.mux = route.newMux()
+	return route
+}
+
+// route picks the adapter that should serve r. The provider header takes
+// precedence over a model prefix, which takes precedence over the model
+// routes; a matched model prefix is stripped from the request body, and a
+// model route's model replaces the model name, before it is passed on.
+func (a *Adapter) route(w http.ResponseWriter, r *http.Request) (*Adapter, bool) {
+	if name := r.Header.Get(providerHeader); name != "" {
+		r.Header.Del(providerHeader)
+		route, ok := a.routes[name]
+		if !ok {
+			a.logger.Warn("unknown provider requested", "provider", name)
+			writeOpenAIError(w, http.StatusBadRequest, "Unknown provider: "+name, "invalid_request_error", "unknown_provider")
+			return nil, false
+		}
+		return route, true
+	}
+
+	if r.Method != http.MethodPost || r.Body == nil {
+		return a, true
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		a.logger.Error("failed to read request body", "error", err)
+		writeRequestBodyError(w, r, err)
+		return nil, false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	var requestData map[string]any
+	if err := json.Unmarshal(body, &requestData); err != nil {
+		return a, true
+	}
+
+	model, _ := requestData["model"].(string)
+	route, rewritten := a.routeModel(model)
+	if route == a {
+		return a, true
+	}
+
+	if rewritten != model {
+		requestData["model"] = rewritten
+		if body, err = json.Marshal(requestData); err != nil {
+			return a, true
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		r.ContentLength = int64(len(body))
+	}
+	return route, true
+}
+
+// routeModel returns the adapter that serves model, by its provider prefix
+// or the first matching model route, and the model name to send it.
+func (a *Adapter) routeModel(model string) (*Adapter, string) {
+	if name, rest, found := strings.Cut(model, "/"); found {
+		if route, ok := a.routes[name]; ok {
+			a.logger.Debug("routed request by model prefix", "provider", name, "model", rest)
+			return route, rest
+		}
+	}
+
+	for _, route := range a.modelRoutes {
+		if matched, _ := path.Match(route.pattern, model); !matched {
+			continue
+		}
+		rewritten := model
+		if route.model != "" {
+			rewritten = route.model
+		}
+		a.logger.Debug("routed request by model route", "pattern", route.pattern, "provider", route.adapter.Provider.Name, "target", route.adapter.Target, "model", rewritten)
+		return route.adapter, rewritten
+	}
+	return a, model
+}
@@ -0,0 +1,209 @@
+package adapter
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+	"github.com/aldehir/gpt-oss-adapter/providers/openrouter"
+)
+
+// newModelServer returns a backend that records the model and provider header
+// of the requests it receives.
+func newModelServer(t *testing.T, models *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		assert.Empty(t, r.Header.Get(providerHeader))
+		*models = append(*models, request["model"].(string))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"index":0,"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+}
+
+func TestProviderRouting(t *testing.T) {
+	var defaultModels, openrouterModels []string
+	defaultServer := newModelServer(t, &defaultModels)
+	defer defaultServer.Close()
+	openrouterServer := newModelServer(t, &openrouterModels)
+	defer openrouterServer.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter(defaultServer.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+	adapter.AddRoute(llamacpp.NewProvider())
+	route := openrouter.NewProvider()
+	route.Target = openrouterServer.URL
+	adapter.AddRoute(route)
+
+	tests := []struct {
+		name     string
+		header   string
+		model    string
+		status   int
+		upstream *[]string
+		want     string
+	}{
+		{"default", "", "gpt-oss-120b", http.StatusOK, &defaultModels, "gpt-oss-120b"},
+		{"model prefix", "", "openrouter/openai/gpt-oss-120b", http.StatusOK, &openrouterModels, "openai/gpt-oss-120b"},
+		{"unknown prefix", "", "openai/gpt-oss-120b", http.StatusOK, &defaultModels, "openai/gpt-oss-120b"},
+		{"header", "openrouter", "openai/gpt-oss-20b", http.StatusOK, &openrouterModels, "openai/gpt-oss-20b"},
+		{"header wins over prefix", "llama-cpp", "openrouter/gpt-oss-20b", http.StatusOK, &defaultModels, "openrouter/gpt-oss-20b"},
+		{"unknown header", "vllm", "gpt-oss-120b", http.StatusBadRequest, nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"`+tt.model+`","messages":[]}`))
+			if tt.header != "" {
+				req.Header.Set(providerHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			adapter.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.status, rec.Code, rec.Body.String())
+			if tt.upstream != nil {
+				models := *tt.upstream
+				require.NotEmpty(t, models)
+				assert.Equal(t, tt.want, models[len(models)-1])
+			}
+		})
+	}
+}
+
+func TestModelRouting(t *testing.T) {
+	var defaultModels, localModels, openrouterModels []string
+	defaultServer := newModelServer(t, &defaultModels)
+	defer defaultServer.Close()
+	localServer := newModelServer(t, &localModels)
+	defer localServer.Close()
+	openrouterServer := newModelServer(t, &openrouterModels)
+	defer openrouterServer.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter(defaultServer.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+	adapter.AddRoute(llamacpp.NewProvider())
+
+	routes := []string{
+		"gpt-oss-20b=llama-cpp,target=" + localServer.URL,
+		"gpt-oss-120b=openrouter,target=" + openrouterServer.URL + ",model=openai/gpt-oss-120b",
+		"qwen*=llama-cpp,target=" + localServer.URL,
+	}
+	for _, source := range routes {
+		route, err := ParseModelRoute(source)
+		require.NoError(t, err)
+		provider := llamacpp.NewProvider()
+		if route.Provider == "openrouter" {
+			provider = openrouter.NewProvider()
+		}
+		require.NoError(t, adapter.AddModelRoute(route, provider))
+	}
+	// Both routes to the local server share one adapter.
+	assert.Len(t, adapter.routeAdapters(), 3)
+
+	tests := []struct {
+		name     string
+		model    string
+		upstream *[]string
+		want     string
+	}{
+		{"exact", "gpt-oss-20b", &localModels, "gpt-oss-20b"},
+		{"renamed", "gpt-oss-120b", &openrouterModels, "openai/gpt-oss-120b"},
+		{"glob", "qwen3-8b", &localModels, "qwen3-8b"},
+		{"no match", "llama-3", &defaultModels, "llama-3"},
+		{"prefix wins over route", "llama-cpp/gpt-oss-120b", &defaultModels, "gpt-oss-120b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"`+tt.model+`","messages":[]}`))
+			rec := httptest.NewRecorder()
+			adapter.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			models := *tt.upstream
+			require.NotEmpty(t, models)
+			assert.Equal(t, tt.want, models[len(models)-1])
+		})
+	}
+}
+
+func TestParseModelRoute(t *testing.T) {
+	route, err := ParseModelRoute("gpt-oss-*=openrouter,target=https://openrouter.ai/api,model=openai/gpt-oss-120b")
+	require.NoError(t, err)
+	assert.Equal(t, ModelRoute{Pattern: "gpt-oss-*", Provider: "openrouter", Target: "https://openrouter.ai/api", Model: "openai/gpt-oss-120b"}, route)
+
+	for _, source := range []string{"gpt-oss-20b", "=llama-cpp", "gpt-oss-20b=", "[=llama-cpp", "gpt-oss-20b=llama-cpp,weight=2"} {
+		_, err := ParseModelRoute(source)
+		assert.Error(t, err, source)
+	}
+}
+
+// TestNewRoute checks that a route carries over every setting of the
+// adapter except the per-target ones, so that a new setting cannot be
+// silently dropped for routed targets.
+func TestNewRoute(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter("http://primary:8080", NewLRUCache(10), logger, llamacpp.NewProvider())
+
+	// Give every exported setting a non-zero value.
+	settings := reflect.ValueOf(adapter).Elem()
+	for i := range settings.NumField() {
+		field := settings.Field(i)
+		if !settings.Type().Field(i).IsExported() || !field.IsZero() {
+			continue
+		}
+		switch field.Kind() {
+		case reflect.Pointer:
+			field.Set(reflect.New(field.Type().Elem()))
+		case reflect.Slice:
+			field.Set(reflect.MakeSlice(field.Type(), 1, 1))
+		case reflect.Map:
+			field.Set(reflect.MakeMap(field.Type()))
+		case reflect.String:
+			field.SetString("set")
+		case reflect.Bool:
+			field.SetBool(true)
+		case reflect.Int, reflect.Int64:
+			field.SetInt(1)
+		default:
+			t.Fatalf("cannot set %s of kind %s", settings.Type().Field(i).Name, field.Kind())
+		}
+	}
+
+	perTarget := map[string]bool{"Target": true, "Provider": true, "Queue": true, "Breaker": true, "Health": true, "FallbackModels": true}
+	route := adapter.newRoute("http://other:8080", openrouter.NewProvider())
+	routed := reflect.ValueOf(route).Elem()
+	for i := range settings.NumField() {
+		name := settings.Type().Field(i).Name
+		if !settings.Type().Field(i).IsExported() || perTarget[name] {
+			continue
+		}
+		want, got := settings.Field(i), routed.Field(i)
+		if want.Kind() == reflect.Pointer || want.Kind() == reflect.Map {
+			assert.Equal(t, want.Pointer(), got.Pointer(), "%s is not shared with the route", name)
+			continue
+		}
+		assert.Equal(t, want.Interface(), got.Interface(), "%s is not copied to the route", name)
+	}
+
+	assert.Equal(t, "http://other:8080", route.Target)
+	assert.Equal(t, "openrouter", route.Provider.Name)
+	assert.NotSame(t, adapter.Queue, route.Queue)
+	assert.NotSame(t, adapter.Breaker, route.Breaker)
+	assert.Nil(t, route.Health)
+	assert.Same(t, adapter.inflight, route.inflight)
+	assert.NotSame(t, adapter.client, route.client)
+	assert.Same(t, adapter.client, adapter.newRoute(adapter.Target, openrouter.NewProvider()).client)
+	assert.NotSame(t, adapter.coalescer, route.coalescer)
+	assert.Empty(t, route.routes)
+}
@@ -0,0 +1,195 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// RequestRule changes the chat requests that match it, for backend quirks
+// that are easier to describe than to script, such as a field a backend
+// rejects. Fields are addressed by dotted paths like
+// "chat_template_kwargs.reasoning_effort". A rule renames, deletes and sets
+// fields, then clamps numbers, in that order.
+type RequestRule struct {
+	Name  string    `yaml:"name"`
+	Match RuleMatch `yaml:"match"`
+
+	// Rename moves fields to new paths.
+	Rename map[string]string `yaml:"rename"`
+	// Delete removes fields.
+	Delete []string `yaml:"delete"`
+	// Set sets fields. String values are Go templates, which can read the
+	// request with {{field "model"}} and its headers with
+	// {{header "X-User"}}.
+	Set map[string]any `yaml:"set"`
+	// Max and Min clamp numeric fields that are present.
+	Max map[string]float64 `yaml:"max"`
+	Min map[string]float64 `yaml:"min"`
+
+	templates map[string]*template.Template
+}
+
+// RuleMatch selects the requests a rule applies to. Path, Model and Provider
+// are globs, and Header maps the names of headers the request must have to
+// globs of their values. Empty fields match every request.
+type RuleMatch struct {
+	Path     string            `yaml:"path"`
+	Model    string            `yaml:"model"`
+	Provider string            `yaml:"provider"`
+	Header   map[string]string `yaml:"header"`
+}
+
+// templateFuncs declares the functions of set templates. They are bound to
+// the request when a rule is applied.
+var templateFuncs = template.FuncMap{
+	"field":  func(string) any { return nil },
+	"header": func(string) string { return "" },
+}
+
+// CompileRequestRules checks rules and prepares their templates.
+func CompileRequestRules(rules []RequestRule) ([]RequestRule, error) {
+	compiled := make([]RequestRule, len(rules))
+	for i, rule := range rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+
+		globs := []string{rule.Match.Path, rule.Match.Model, rule.Match.Provider}
+		for glob := range maps.Values(rule.Match.Header) {
+			globs = append(globs, glob)
+		}
+		for _, glob := range globs {
+			if _, err := path.Match(glob, ""); err != nil {
+				return nil, fmt.Errorf("rule %s: invalid pattern %q", name, glob)
+			}
+		}
+
+		rule.templates = make(map[string]*template.Template)
+		set := make(map[string]any, len(rule.Set))
+		for field, value := range rule.Set {
+			if text, ok := value.(string); ok && strings.Contains(text, "{{") {
+				tmpl, err := template.New(field).Funcs(templateFuncs).Parse(text)
+				if err != nil {
+					return nil, fmt.Errorf("rule %s: field %s: %w", name, field, err)
+				}
+				rule.templates[field] = tmpl
+			}
+			// Values decoded from YAML are normalized to what decoding
+			// JSON produces, e.g. float64 for numbers.
+			normalized, err := normalizeJSON(value)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: field %s: %w", name, field, err)
+			}
+			set[field] = normalized
+		}
+		rule.Set = set
+		rule.Name = name
+		compiled[i] = rule
+	}
+	return compiled, nil
+}
+
+func normalizeJSON(value any) (any, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var normalized any
+	err = json.Unmarshal(data, &normalized)
+	return normalized, err
+}
+
+// matches reports whether the rule applies to a request sent with provider.
+func (rule *RequestRule) matches(r *http.Request, requestData map[string]any, provider string) bool {
+	model, _ := requestData["model"].(string)
+	if !globMatches(rule.Match.Path, r.URL.Path) || !globMatches(rule.Match.Model, model) || !globMatches(rule.Match.Provider, provider) {
+		return false
+	}
+	for name, glob := range rule.Match.Header {
+		if len(r.Header.Values(name)) == 0 || !globMatches(glob, r.Header.Get(name)) {
+			return false
+		}
+	}
+	return true
+}
+
+func globMatches(glob, value string) bool {
+	if glob == "" {
+		return true
+	}
+	matched, _ := path.Match(glob, value)
+	return matched
+}
+
+// applyRequestRules applies the matching RequestRules to a chat request.
+func (a *Adapter) applyRequestRules(r *http.Request, requestData map[string]any) {
+	for i := range a.RequestRules {
+		rule := &a.RequestRules[i]
+		if !rule.matches(r, requestData, a.Provider.Name) {
+			continue
+		}
+
+		for _, from := range slices.Sorted(maps.Keys(rule.Rename)) {
+			value := a.getNestedField(requestData, from)
+			if value == nil {
+				continue
+			}
+			a.deleteNestedField(requestData, from)
+			a.setNestedField(requestData, rule.Rename[from], value)
+		}
+
+		for _, field := range rule.Delete {
+			a.deleteNestedField(requestData, field)
+		}
+
+		for _, field := range slices.Sorted(maps.Keys(rule.Set)) {
+			value := rule.Set[field]
+			if tmpl, ok := rule.templates[field]; ok {
+				expanded, err := a.expandRuleTemplate(tmpl, r, requestData)
+				if err != nil {
+					a.logger.ErrorContext(r.Context(), "failed to expand request rule template", "rule", rule.Name, "field", field, "error", err)
+					continue
+				}
+				value = expanded
+			}
+			a.setNestedField(requestData, field, deepCopyJSON(value))
+		}
+
+		for field, limit := range rule.Max {
+			if n, ok := a.getNestedField(requestData, field).(float64); ok && n > limit {
+				a.setNestedField(requestData, field, limit)
+			}
+		}
+		for field, limit := range rule.Min {
+			if n, ok := a.getNestedField(requestData, field).(float64); ok && n < limit {
+				a.setNestedField(requestData, field, limit)
+			}
+		}
+
+		a.logger.DebugContext(r.Context(), "applied request rule", "rule", rule.Name)
+	}
+}
+
+func (a *Adapter) expandRuleTemplate(tmpl *template.Template, r *http.Request, requestData map[string]any) (string, error) {
+	bound, err := tmpl.Clone()
+	if err != nil {
+		return "", err
+	}
+	bound.Funcs(template.FuncMap{
+		"field":  func(path string) any { return a.getNestedField(requestData, path) },
+		"header": r.Header.Get,
+	})
+
+	var b strings.Builder
+	if err := bound.Execute(&b, nil); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,92 @@
+package adapter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func compileRules(t *testing.T, source string) []RequestRule {
+	var rules []RequestRule
+	require.NoError(t, yaml.Unmarshal([]byte(source), &rules))
+	compiled, err := CompileRequestRules(rules)
+	require.NoError(t, err)
+	return compiled
+}
+
+func TestRequestRules(t *testing.T) {
+	var forwarded map[string]any
+	adapter := newEffortTestAdapter(t, &forwarded)
+	adapter.RequestRules = compileRules(t, `
+- name: strip unsupported
+  match:
+    model: gpt-oss-*
+  delete: [parallel_tool_calls]
+  rename:
+    max_completion_tokens: max_tokens
+  max:
+    max_tokens: 4096
+  min:
+    temperature: 0.1
+- name: tag user
+  match:
+    path: /v1/chat/*
+    provider: llama-cpp
+    header:
+      X-User: "*"
+  set:
+    user: '{{header "X-User"}} via {{field "model"}}'
+    chat_template_kwargs.builtin_tools: [browser]
+    top_k: 40
+- match:
+    model: qwen*
+  set:
+    top_k: 20
+`)
+
+	send := func(body, user string) {
+		r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+		if user != "" {
+			r.Header.Set("X-User", user)
+		}
+		w := httptest.NewRecorder()
+		adapter.ServeHTTP(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	send(`{"model":"gpt-oss-20b","messages":[],"parallel_tool_calls":true,"max_completion_tokens":100000,"temperature":0}`, "alice")
+	assert.NotContains(t, forwarded, "parallel_tool_calls")
+	assert.NotContains(t, forwarded, "max_completion_tokens")
+	assert.Equal(t, float64(4096), forwarded["max_tokens"])
+	assert.Equal(t, 0.1, forwarded["temperature"])
+	assert.Equal(t, "alice via gpt-oss-20b", forwarded["user"])
+	assert.Equal(t, []any{"browser"}, forwarded["chat_template_kwargs"].(map[string]any)["builtin_tools"])
+	assert.Equal(t, float64(40), forwarded["top_k"])
+
+	// Rules that do not match leave the request alone.
+	send(`{"model":"qwen3","messages":[],"parallel_tool_calls":true,"max_tokens":100000}`, "")
+	assert.Equal(t, true, forwarded["parallel_tool_calls"])
+	assert.Equal(t, float64(100000), forwarded["max_tokens"])
+	assert.NotContains(t, forwarded, "user")
+	assert.Equal(t, float64(20), forwarded["top_k"])
+}
+
+func TestCompileRequestRules_Invalid(t *testing.T) {
+	invalid := map[string]string{
+		"bad glob":     `[{match: {model: "["}}]`,
+		"bad header":   `[{match: {header: {X-User: "["}}}]`,
+		"bad template": `[{set: {user: "{{header"}}]`,
+		"unknown func": `[{set: {user: "{{env \"HOME\"}}"}}]`,
+	}
+	for name, source := range invalid {
+		var rules []RequestRule
+		require.NoError(t, yaml.Unmarshal([]byte(source), &rules), name)
+		_, err := CompileRequestRules(rules)
+		assert.Error(t, err, name)
+	}
+}
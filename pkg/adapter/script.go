@@ -0,0 +1,165 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	starlarkjson "go.starlark.net/lib/json"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// scriptMaxSteps bounds the work of a single script call, so that a script
+// stuck in a loop fails instead of holding up the request.
+const scriptMaxSteps = 10_000_000
+
+// scriptFunctions are the functions a transform script may define.
+var scriptFunctions = []string{"transform_request", "transform_response", "transform_chunk"}
+
+// ScriptTransformer is a Transformer written in Starlark, for backend quirks
+// that none of the built-in transformers handle. The script defines any of
+// transform_request, transform_response and transform_chunk, each called
+// with the JSON body as a dict. A function either changes its argument in
+// place and returns None, or returns the dict to use instead. A request
+// script that fails, for example by calling fail(), rejects the request;
+// a response script that fails leaves the response unchanged.
+type ScriptTransformer struct {
+	path      string
+	functions map[string]starlark.Callable
+	logger    *slog.Logger
+}
+
+// LoadScriptTransformer loads a transform script.
+func LoadScriptTransformer(path string, logger *slog.Logger) (*ScriptTransformer, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &ScriptTransformer{
+		path:      path,
+		functions: make(map[string]starlark.Callable),
+		logger:    logger,
+	}
+	options := &syntax.FileOptions{Set: true, While: true, TopLevelControl: true, GlobalReassign: true}
+	predeclared := starlark.StringDict{"json": starlarkjson.Module}
+	globals, err := starlark.ExecFileOptions(options, s.thread("load"), path, src, predeclared)
+	if err != nil {
+		return nil, fmt.Errorf("loading %s: %w", path, scriptError(err))
+	}
+
+	for _, name := range scriptFunctions {
+		value, ok := globals[name]
+		if !ok {
+			continue
+		}
+		fn, ok := value.(starlark.Callable)
+		if !ok {
+			return nil, fmt.Errorf("loading %s: %s is a %s, not a function", path, name, value.Type())
+		}
+		s.functions[name] = fn
+	}
+	if len(s.functions) == 0 {
+		return nil, fmt.Errorf("loading %s: script defines none of %s", path, strings.Join(scriptFunctions, ", "))
+	}
+	return s, nil
+}
+
+func (s *ScriptTransformer) thread(name string) *starlark.Thread {
+	thread := &starlark.Thread{
+		Name: name,
+		Print: func(_ *starlark.Thread, msg string) {
+			s.logger.Info("transform script", "script", s.path, "message", msg)
+		},
+	}
+	thread.SetMaxExecutionSteps(scriptMaxSteps)
+	return thread
+}
+
+// call runs a script function over data, replacing its contents with the
+// result. It reports false if the script does not define the function.
+func (s *ScriptTransformer) call(name string, data map[string]any) (bool, error) {
+	fn, ok := s.functions[name]
+	if !ok {
+		return false, nil
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return false, err
+	}
+	thread := s.thread(name)
+	arg, err := starlark.Call(thread, starlarkjson.Module.Members["decode"], starlark.Tuple{starlark.String(encoded)}, nil)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := starlark.Call(thread, fn, starlark.Tuple{arg}, nil)
+	if err != nil {
+		return false, scriptError(err)
+	}
+	switch result.(type) {
+	case starlark.NoneType:
+		result = arg
+	case *starlark.Dict:
+	default:
+		return false, fmt.Errorf("%s returned a %s, not a dict or None", name, result.Type())
+	}
+
+	encodedResult, err := starlark.Call(thread, starlarkjson.Module.Members["encode"], starlark.Tuple{result}, nil)
+	if err != nil {
+		return false, scriptError(err)
+	}
+	var transformed map[string]any
+	if err := json.Unmarshal([]byte(encodedResult.(starlark.String)), &transformed); err != nil {
+		return false, err
+	}
+
+	clear(data)
+	for key, value := range transformed {
+		data[key] = value
+	}
+	return true, nil
+}
+
+func (s *ScriptTransformer) TransformRequest(r *http.Request, request map[string]any) error {
+	if _, err := s.call("transform_request", request); err != nil {
+		return &TransformError{
+			Status:  http.StatusBadRequest,
+			Message: "Request rejected by transform script: " + err.Error(),
+			Type:    "invalid_request_error",
+			Code:    "rejected_by_script",
+		}
+	}
+	return nil
+}
+
+func (s *ScriptTransformer) TransformResponse(ctx context.Context, response map[string]any) {
+	if _, err := s.call("transform_response", response); err != nil {
+		s.logger.ErrorContext(ctx, "transform script failed", "script", s.path, "function", "transform_response", "error", err)
+	}
+}
+
+func (s *ScriptTransformer) TransformChunk(ctx context.Context, chunk map[string]any) bool {
+	called, err := s.call("transform_chunk", chunk)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "transform script failed", "script", s.path, "function", "transform_chunk", "error", err)
+	}
+	return called && err == nil
+}
+
+// scriptError returns the message of a Starlark error without its
+// backtrace.
+func scriptError(err error) error {
+	var evalErr *starlark.EvalError
+	if errors.As(err, &evalErr) {
+		return errors.New(evalErr.Msg)
+	}
+	return err
+}
@@ -0,0 +1,91 @@
+package adapter
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadTestScript(t *testing.T, src string) (*ScriptTransformer, error) {
+	path := filepath.Join(t.TempDir(), "transform.star")
+	require.NoError(t, os.WriteFile(path, []byte(src), 0o600))
+	return LoadScriptTransformer(path, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestScriptTransformer(t *testing.T) {
+	script, err := loadTestScript(t, `
+def transform_request(request):
+    if request.get("model") == "blocked":
+        fail("model is blocked")
+    request["max_tokens"] = min(request.get("max_tokens", 4096), 4096)
+    request.pop("logit_bias", None)
+
+def transform_response(response):
+    return {"wrapped": response}
+
+def transform_chunk(chunk):
+    for choice in chunk.get("choices", []):
+        delta = choice.get("delta", {})
+        if "thoughts" in delta:
+            delta["reasoning"] = delta.pop("thoughts")
+`)
+	require.NoError(t, err)
+
+	request := map[string]any{"model": "gpt-oss-20b", "max_tokens": float64(10000), "logit_bias": map[string]any{}}
+	require.NoError(t, script.TransformRequest(nil, request))
+	assert.Equal(t, map[string]any{"model": "gpt-oss-20b", "max_tokens": float64(4096)}, request)
+
+	err = script.TransformRequest(nil, map[string]any{"model": "blocked"})
+	var transformErr *TransformError
+	require.ErrorAs(t, err, &transformErr)
+	assert.Equal(t, http.StatusBadRequest, transformErr.Status)
+	assert.Contains(t, transformErr.Message, "model is blocked")
+
+	response := map[string]any{"id": "1"}
+	script.TransformResponse(context.Background(), response)
+	assert.Equal(t, map[string]any{"wrapped": map[string]any{"id": "1"}}, response)
+
+	chunk := map[string]any{"choices": []any{map[string]any{"delta": map[string]any{"thoughts": "Hmm."}}}}
+	assert.True(t, script.TransformChunk(context.Background(), chunk))
+	assert.Equal(t, map[string]any{"choices": []any{map[string]any{"delta": map[string]any{"reasoning": "Hmm."}}}}, chunk)
+}
+
+func TestScriptTransformer_Errors(t *testing.T) {
+	_, err := loadTestScript(t, `x = 1`)
+	assert.ErrorContains(t, err, "script defines none of")
+
+	_, err = loadTestScript(t, `transform_request = 1`)
+	assert.ErrorContains(t, err, "transform_request is a int, not a function")
+
+	_, err = loadTestScript(t, `def transform_request(`)
+	assert.Error(t, err)
+
+	script, err := loadTestScript(t, `
+def transform_response(response):
+    while True:
+        pass
+
+def transform_chunk(chunk):
+    return 1
+`)
+	require.NoError(t, err)
+
+	// A failing response script leaves the response alone.
+	response := map[string]any{"id": "1"}
+	script.TransformResponse(context.Background(), response)
+	assert.Equal(t, map[string]any{"id": "1"}, response)
+
+	chunk := map[string]any{"id": "1"}
+	assert.False(t, script.TransformChunk(context.Background(), chunk))
+	assert.Equal(t, map[string]any{"id": "1"}, chunk)
+
+	// Functions the script does not define pass requests through.
+	assert.NoError(t, script.TransformRequest(nil, map[string]any{"id": "1"}))
+}
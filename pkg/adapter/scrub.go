@@ -0,0 +1,190 @@
+package adapter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Built-in scrub rules, selected by name.
+const (
+	ScrubAPIKeys = "api-keys"
+	ScrubEmails  = "emails"
+	ScrubCards   = "cards"
+)
+
+// scrubRedacted replaces text matched by a custom pattern or keyword.
+const scrubRedacted = "[REDACTED]"
+
+// scrubRule replaces the matches of pattern that pass valid, if set, with
+// replacement.
+type scrubRule struct {
+	pattern     *regexp.Regexp
+	replacement string
+	valid       func(match string) bool
+}
+
+var builtinScrubRules = map[string]scrubRule{
+	ScrubAPIKeys: {
+		// OpenAI, Anthropic, AWS, GitHub, Slack and Google keys, and bearer
+		// tokens.
+		pattern:     regexp.MustCompile(`\b(?:sk-[A-Za-z0-9_\-]{20,}|AKIA[0-9A-Z]{16}|gh[pousr]_[A-Za-z0-9]{36,}|xox[abprs]-[A-Za-z0-9\-]{10,}|AIza[0-9A-Za-z_\-]{35})|(?i:\bbearer\s+)[A-Za-z0-9._~+/\-]{15,}[A-Za-z0-9_~+/\-]=*`),
+		replacement: "[REDACTED:api-key]",
+	},
+	ScrubEmails: {
+		pattern:     regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`),
+		replacement: "[REDACTED:email]",
+	},
+	ScrubCards: {
+		pattern:     regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`),
+		replacement: "[REDACTED:card]",
+		valid:       luhnValid,
+	},
+}
+
+// ScrubRules lists the names of the built-in scrub rules.
+func ScrubRules() []string {
+	return []string{ScrubAPIKeys, ScrubEmails, ScrubCards}
+}
+
+// Scrubber redacts secrets and personal data from reasoning before it is
+// cached or recorded, using built-in rules, custom regular expressions and
+// case-insensitive keywords.
+type Scrubber struct {
+	rules []scrubRule
+}
+
+// NewScrubber compiles a scrubber from the names of built-in rules, regular
+// expressions and keywords.
+func NewScrubber(builtins, patterns, keywords []string) (*Scrubber, error) {
+	s := &Scrubber{}
+	for _, name := range builtins {
+		rule, ok := builtinScrubRules[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown scrub rule %q", name)
+		}
+		s.rules = append(s.rules, rule)
+	}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scrub pattern %q: %w", pattern, err)
+		}
+		s.rules = append(s.rules, scrubRule{pattern: re, replacement: scrubRedacted})
+	}
+	for _, keyword := range keywords {
+		if keyword == "" {
+			continue
+		}
+		s.rules = append(s.rules, scrubRule{
+			pattern:     regexp.MustCompile(`(?i)` + regexp.QuoteMeta(keyword)),
+			replacement: scrubRedacted,
+		})
+	}
+	return s, nil
+}
+
+// Scrub returns text with every match of the scrubber's rules redacted.
+func (s *Scrubber) Scrub(text string) string {
+	for _, rule := range s.rules {
+		if rule.valid == nil {
+			text = rule.pattern.ReplaceAllLiteralString(text, rule.replacement)
+			continue
+		}
+		text = rule.pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if rule.valid(match) {
+				return rule.replacement
+			}
+			return match
+		})
+	}
+	return text
+}
+
+// ScrubJSON scrubs the string values of a JSON document, leaving its
+// structure intact, or the whole text if it is not JSON.
+func (s *Scrubber) ScrubJSON(data []byte) []byte {
+	var document any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return []byte(s.Scrub(string(data)))
+	}
+	scrubbed, err := json.Marshal(s.scrubValue(document))
+	if err != nil {
+		return data
+	}
+	return scrubbed
+}
+
+func (s *Scrubber) scrubValue(value any) any {
+	switch v := value.(type) {
+	case string:
+		return s.Scrub(v)
+	case []any:
+		for i := range v {
+			v[i] = s.scrubValue(v[i])
+		}
+	case map[string]any:
+		for key := range v {
+			v[key] = s.scrubValue(v[key])
+		}
+	}
+	return value
+}
+
+// luhnValid reports whether the digits of a card number candidate pass the
+// Luhn checksum, which rules out most other long numbers.
+func luhnValid(number string) bool {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, number)
+
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// scrubItem scrubs the reasoning of a cache entry. Reasoning chains are
+// scrubbed link by link so that they stay valid JSON.
+func (a *Adapter) scrubItem(item ReasoningItem) ReasoningItem {
+	if a.Scrubber == nil {
+		return item
+	}
+	if strings.HasPrefix(item.ID, chainKeyPrefix) {
+		var chain []chainLink
+		if err := json.Unmarshal([]byte(item.Content), &chain); err == nil {
+			for i := range chain {
+				chain[i].Reasoning = a.Scrubber.Scrub(chain[i].Reasoning)
+			}
+			if data, err := json.Marshal(chain); err == nil {
+				item.Content = string(data)
+			}
+			return item
+		}
+	}
+	item.Content = a.Scrubber.Scrub(item.Content)
+	return item
+}
+
+// storeReasoning caches an entry after scrubbing it. Every write of
+// reasoning to the cache goes through it.
+func (a *Adapter) storeReasoning(namespace, key string, item ReasoningItem) {
+	a.cache.Put(namespace, key, a.scrubItem(item))
+}
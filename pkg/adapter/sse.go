@@ -0,0 +1,228 @@
+package adapter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+)
+
+// DefaultStreamMaxLineSize bounds a single line of an upstream event stream.
+// Large tool call arguments or reasoning deltas can easily exceed the 64KB
+// that bufio.Scanner allows by default.
+const DefaultStreamMaxLineSize = 16 * 1024 * 1024
+
+var errSSELineTooLong = errors.New("event stream line exceeds maximum size")
+
+// sseEvent is a single Server-Sent Event. Multi-line data fields are joined
+// with newlines, as EventSource does. Comments are kept verbatim so they can
+// be relayed unchanged.
+type sseEvent struct {
+	Event    string
+	ID       string
+	Retry    string
+	Data     string
+	HasData  bool
+	Comments []string
+
+	// fragments holds lines that are not fields, which is what is left of
+	// a data line some backends break in two.
+	fragments []string
+}
+
+// joined returns the data lines and fragments of the event concatenated
+// without separators, as they were before a JSON payload was broken into
+// several lines.
+func (e *sseEvent) joined() string {
+	return strings.ReplaceAll(e.Data, "\n", "") + strings.Join(e.fragments, "")
+}
+
+// lines encodes the event back into SSE lines, ending with the blank line
+// that dispatches it.
+func (e *sseEvent) lines() []string {
+	var lines []string
+	for _, comment := range e.Comments {
+		lines = append(lines, ":"+comment)
+	}
+	if e.Event != "" {
+		lines = append(lines, "event: "+e.Event)
+	}
+	if e.ID != "" {
+		lines = append(lines, "id: "+e.ID)
+	}
+	if e.Retry != "" {
+		lines = append(lines, "retry: "+e.Retry)
+	}
+	if e.HasData {
+		for _, data := range strings.Split(e.Data, "\n") {
+			lines = append(lines, "data: "+data)
+		}
+	}
+	return append(lines, "")
+}
+
+// maxRepairEvents bounds how many events are read ahead to complete a JSON
+// payload that was cut off.
+const maxRepairEvents = 16
+
+// sseReader parses an event stream into events without the fixed token
+// limit of bufio.Scanner.
+type sseReader struct {
+	reader      *bufio.Reader
+	maxLineSize int
+
+	// queued holds the events read ahead while trying to repair one, and
+	// err the error that ended reading ahead.
+	queued   []*sseEvent
+	err      error
+	repaired int
+}
+
+func newSSEReader(r io.Reader, maxLineSize int) *sseReader {
+	if maxLineSize <= 0 {
+		maxLineSize = DefaultStreamMaxLineSize
+	}
+	return &sseReader{
+		reader:      bufio.NewReaderSize(r, 64*1024),
+		maxLineSize: maxLineSize,
+	}
+}
+
+// Next returns the next event in the stream, or io.EOF once the stream ends.
+//
+// Some backends, llama.cpp among them, occasionally break the JSON payload
+// of an event into several lines or events. When the data of an event is
+// the start of a JSON object that does not parse, Next joins it with the
+// lines that follow and, failing that, with the data of the events that
+// follow, until it parses. If it never does, the events are returned as
+// they were received, so none are lost.
+func (s *sseReader) Next() (*sseEvent, error) {
+	if len(s.queued) > 0 {
+		event := s.queued[0]
+		s.queued = s.queued[1:]
+		return event, nil
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	event, err := s.next()
+	if err != nil {
+		return nil, err
+	}
+	if !event.HasData || json.Valid([]byte(event.Data)) || !strings.HasPrefix(strings.TrimSpace(event.Data), "{") {
+		return event, nil
+	}
+
+	data := event.joined()
+	events := []*sseEvent{event}
+	for !json.Valid([]byte(data)) {
+		if len(events) == maxRepairEvents || len(data) > s.maxLineSize {
+			s.queued = events[1:]
+			return event, nil
+		}
+		next, err := s.next()
+		if err != nil {
+			s.err = err
+			s.queued = events[1:]
+			return event, nil
+		}
+		events = append(events, next)
+		if next.HasData && (next.Data == "[DONE]" || json.Valid([]byte(next.Data))) {
+			s.queued = events[1:]
+			return event, nil
+		}
+		data += next.joined()
+	}
+
+	for _, next := range events[1:] {
+		event.Comments = append(event.Comments, next.Comments...)
+	}
+	event.Data = data
+	event.fragments = nil
+	s.repaired++
+	return event, nil
+}
+
+// next reads the next event as it is in the stream. An event that is not
+// terminated by a blank line before the end of the stream is still returned.
+func (s *sseReader) next() (*sseEvent, error) {
+	var event sseEvent
+	var data []string
+	pending := false
+
+	for {
+		line, err := s.readLine()
+		if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+			if errors.Is(err, io.EOF) && pending {
+				event.Data = strings.Join(data, "\n")
+				return &event, nil
+			}
+			return nil, err
+		}
+
+		if line == "" {
+			if !pending {
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
+			event.Data = strings.Join(data, "\n")
+			return &event, nil
+		}
+		pending = true
+
+		field, value, found := strings.Cut(line, ":")
+		if found && field != "" {
+			value = strings.TrimPrefix(value, " ")
+		}
+
+		switch field {
+		case "":
+			event.Comments = append(event.Comments, value)
+		case "event":
+			event.Event = value
+		case "id":
+			event.ID = value
+		case "retry":
+			event.Retry = value
+		case "data":
+			data = append(data, value)
+			event.HasData = true
+		default:
+			event.fragments = append(event.fragments, line)
+		}
+
+		if err != nil {
+			event.Data = strings.Join(data, "\n")
+			return &event, nil
+		}
+	}
+}
+
+// readLine reads a single line without its line ending. A final line without
+// a line ending is returned together with io.EOF.
+func (s *sseReader) readLine() (string, error) {
+	var buf []byte
+	for {
+		chunk, err := s.reader.ReadSlice('\n')
+		if len(buf)+len(chunk) > s.maxLineSize+2 {
+			return "", errSSELineTooLong
+		}
+		buf = append(buf, chunk...)
+
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+
+		line := bytes.TrimSuffix(buf, []byte("\n"))
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		if err != nil {
+			return string(line), err
+		}
+		return string(line), nil
+	}
+}
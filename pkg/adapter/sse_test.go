@@ -0,0 +1,175 @@
+package adapter
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readSSEEvents(t *testing.T, stream string, maxLineSize int) ([]*sseEvent, error) {
+	t.Helper()
+	reader := newSSEReader(strings.NewReader(stream), maxLineSize)
+
+	var events []*sseEvent
+	for {
+		event, err := reader.Next()
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return events, err
+		}
+		events = append(events, event)
+	}
+}
+
+func TestSSEReader(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		events []*sseEvent
+	}{
+		{
+			name:   "data events",
+			stream: "data: {\"a\":1}\n\ndata: [DONE]\n\n",
+			events: []*sseEvent{
+				{Data: `{"a":1}`, HasData: true},
+				{Data: "[DONE]", HasData: true},
+			},
+		},
+		{
+			name:   "all fields",
+			stream: ": keep-alive\nevent: message\nid: 7\nretry: 1000\ndata: first\ndata: second\n\n",
+			events: []*sseEvent{
+				{Comments: []string{" keep-alive"}, Event: "message", ID: "7", Retry: "1000", Data: "first\nsecond", HasData: true},
+			},
+		},
+		{
+			name:   "crlf and no space after colon",
+			stream: "data:{\"a\":1}\r\n\r\n",
+			events: []*sseEvent{
+				{Data: `{"a":1}`, HasData: true},
+			},
+		},
+		{
+			name:   "unterminated final event",
+			stream: "data: one\n\ndata: two",
+			events: []*sseEvent{
+				{Data: "one", HasData: true},
+				{Data: "two", HasData: true},
+			},
+		},
+		{
+			name:   "extra blank lines",
+			stream: "\n\ndata: one\n\n\n",
+			events: []*sseEvent{
+				{Data: "one", HasData: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := readSSEEvents(t, tt.stream, 0)
+			require.NoError(t, err)
+			assert.Equal(t, tt.events, events)
+		})
+	}
+}
+
+func TestSSEReader_LargeLine(t *testing.T) {
+	payload := strings.Repeat("x", 1024*1024)
+
+	events, err := readSSEEvents(t, "data: "+payload+"\n\ndata: [DONE]\n\n", 0)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, payload, events[0].Data)
+}
+
+func TestSSEReader_LineTooLong(t *testing.T) {
+	_, err := readSSEEvents(t, "data: "+strings.Repeat("x", 200*1024)+"\n\n", 100*1024)
+	assert.ErrorIs(t, err, errSSELineTooLong)
+}
+
+func TestSSEEvent_Lines(t *testing.T) {
+	event := &sseEvent{Comments: []string{" ping"}, Event: "message", Data: "first\nsecond", HasData: true}
+	assert.Equal(t, []string{": ping", "event: message", "data: first", "data: second", ""}, event.lines())
+}
+
+func TestSSEReader_Repair(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		events []*sseEvent
+	}{
+		{
+			name:   "line broken in two",
+			stream: "data: {\"content\":\"Hel\nlo\"}\n\ndata: [DONE]\n\n",
+			events: []*sseEvent{
+				{Data: `{"content":"Hello"}`, HasData: true},
+				{Data: "[DONE]", HasData: true},
+			},
+		},
+		{
+			name:   "data lines",
+			stream: "data: {\"content\":\"Hel\ndata: lo\"}\n\n",
+			events: []*sseEvent{
+				{Data: `{"content":"Hello"}`, HasData: true},
+			},
+		},
+		{
+			name:   "event broken in two",
+			stream: "data: {\"content\":\"Hel\n\n: ping\n\ndata: lo\"}\n\ndata: {\"a\":1}\n\n",
+			events: []*sseEvent{
+				{Data: `{"content":"Hello"}`, HasData: true, Comments: []string{" ping"}},
+				{Data: `{"a":1}`, HasData: true},
+			},
+		},
+		{
+			name:   "unrepairable",
+			stream: "data: {\"content\":\n\ndata: {\"a\":1}\n\ndata: [DONE]\n\n",
+			events: []*sseEvent{
+				{Data: `{"content":`, HasData: true},
+				{Data: `{"a":1}`, HasData: true},
+				{Data: "[DONE]", HasData: true},
+			},
+		},
+		{
+			name:   "cut off at the end",
+			stream: "data: {\"a\":1}\n\ndata: {\"content\":",
+			events: []*sseEvent{
+				{Data: `{"a":1}`, HasData: true},
+				{Data: `{"content":`, HasData: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := readSSEEvents(t, tt.stream, 0)
+			require.NoError(t, err)
+			assert.Equal(t, tt.events, events)
+		})
+	}
+}
+
+func TestRelayChatStream_RepairsSplitEvents(t *testing.T) {
+	adapter := newTestAdapter()
+	stream := "data: {\"choices\":[{\"index\":0,\"delta\":{\"reasoning_content\":\"Th\n\ndata: ink.\"}}]}\n\ndata: [DONE]\n\n"
+
+	req, _ := http.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	resp := &http.Response{Body: io.NopCloser(strings.NewReader(stream)), Request: req}
+	var lines []string
+	adapter.relayChatStream(resp, &chatRequest{data: map[string]any{}}, func(line string) {
+		lines = append(lines, line)
+	})
+
+	assert.Equal(t, []string{
+		`data: {"choices":[{"delta":{"reasoning":"Think."},"index":0}]}`, "",
+		"data: [DONE]", "",
+	}, lines)
+}
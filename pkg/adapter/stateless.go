@@ -0,0 +1,248 @@
+package adapter
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// sealedReasoningField is the field of a tool call that carries the
+// reasoning sealed for it.
+const sealedReasoningField = "sealed_reasoning"
+
+// sealedIDMarker separates a tool call ID from the reasoning sealed into it
+// by earlier versions, which are still opened so that conversations carry on
+// across an upgrade.
+const sealedIDMarker = ".rs1."
+
+// ReasoningCipher seals reasoning into the tool calls sent to clients, so
+// that it comes back with the tool calls on the next turn instead of being
+// looked up in the cache. Any adapter replica with the same key can restore it,
+// which makes the adapter stateless.
+type ReasoningCipher struct {
+	aead cipher.AEAD
+}
+
+// NewReasoningCipher returns a cipher using AES-256-GCM with a 32 byte key.
+func NewReasoningCipher(key []byte) (*ReasoningCipher, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &ReasoningCipher{aead: aead}, nil
+}
+
+// LoadReasoningKey reads a 32 byte key, hex or base64 encoded, from a file,
+// such as one created with "openssl rand -hex 32".
+func LoadReasoningKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ParseReasoningKey(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// ParseReasoningKey decodes a hex or base64 encoded key.
+func ParseReasoningKey(encoded string) ([]byte, error) {
+	encoded = strings.TrimSpace(encoded)
+	if key, err := hex.DecodeString(encoded); err == nil {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(encoded); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("key is neither hex nor base64 encoded")
+}
+
+// seal encrypts plaintext and authenticates it along with additional data,
+// returning the nonce followed by the ciphertext.
+func (c *ReasoningCipher) seal(plaintext, additional []byte) []byte {
+	nonce := make([]byte, c.aead.NonceSize())
+	rand.Read(nonce)
+	return c.aead.Seal(nonce, nonce, plaintext, additional)
+}
+
+// open decrypts the output of seal for the same additional data.
+func (c *ReasoningCipher) open(sealed, additional []byte) ([]byte, error) {
+	if len(sealed) < c.aead.NonceSize() {
+		return nil, errors.New("sealed data is too short")
+	}
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	return c.aead.Open(nil, nonce, ciphertext, additional)
+}
+
+// Seal returns reasoning sealed for the tool call with the given ID. The ID
+// is authenticated along with the reasoning, so the reasoning cannot be
+// moved to another call.
+func (c *ReasoningCipher) Seal(id, reasoning string) string {
+	return base64.RawURLEncoding.EncodeToString(c.seal([]byte(reasoning), []byte(id)))
+}
+
+// Open returns the reasoning that Seal sealed for the tool call with the
+// given ID.
+func (c *ReasoningCipher) Open(id, sealed string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", fmt.Errorf("decoding sealed reasoning: %w", err)
+	}
+	plaintext, err := c.open(data, []byte(id))
+	if err != nil {
+		return "", fmt.Errorf("opening sealed reasoning: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// OpenID splits a tool call ID that earlier versions sealed reasoning into,
+// as <id>.rs1.<token>, into the original ID and the reasoning. IDs without
+// sealed reasoning are returned unchanged, with ok false.
+func (c *ReasoningCipher) OpenID(sealedID string) (id, reasoning string, ok bool, err error) {
+	id, token, found := strings.Cut(sealedID, sealedIDMarker)
+	if !found {
+		return sealedID, "", false, nil
+	}
+	reasoning, err = c.Open(id, token)
+	if err != nil {
+		return id, "", false, err
+	}
+	return id, reasoning, true, nil
+}
+
+// openSealedReasoning restores the reasoning sealed into the tool calls of
+// a request's assistant messages, and strips the sealed reasoning from the
+// tool calls, and from the IDs and tool results that earlier versions sealed
+// it into, so that the target sees its own tool calls. Reasoning is only
+// restored to the messages selected by the ReasoningInjection policy. It
+// returns how many messages it restored.
+func (a *Adapter) openSealedReasoning(requestData map[string]any) int {
+	messages, ok := requestData["messages"].([]any)
+	if !ok {
+		return 0
+	}
+
+	start := a.injectionStart(messages)
+	restored := 0
+	for i, msg := range messages {
+		message, ok := msg.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		if id, ok := message["tool_call_id"].(string); ok {
+			message["tool_call_id"], _, _, _ = a.Cipher.OpenID(id)
+		}
+
+		toolCalls, _ := message["tool_calls"].([]any)
+		sent, _ := a.extractReasoning(message)
+		found := sent != ""
+		for _, tc := range toolCalls {
+			toolCall, ok := tc.(map[string]any)
+			if !ok {
+				continue
+			}
+			sealed, hasSealed := toolCall[sealedReasoningField].(string)
+			delete(toolCall, sealedReasoningField)
+			sealedID, ok := toolCall["id"].(string)
+			if !ok {
+				continue
+			}
+
+			id, reasoning, ok, err := a.Cipher.OpenID(sealedID)
+			toolCall["id"] = id
+			if err == nil && !ok && hasSealed {
+				reasoning, err = a.Cipher.Open(id, sealed)
+				ok = err == nil
+			}
+			if err != nil {
+				a.logger.Warn("failed to open sealed reasoning", "tool_call_id", id, "error", err)
+				continue
+			}
+			if ok && !found && i >= start {
+				a.restoreReasoning(message, reasoning)
+				found = true
+				restored++
+				a.logger.Debug("restored sealed reasoning", "tool_call_id", id, "field", a.Provider.Reasoning)
+			}
+		}
+	}
+	return restored
+}
+
+// withSealedReasoning copies the reasoning sealed for a tool call from src
+// to dst, so that it survives the translation between chat completions and
+// the Responses and Messages APIs. It returns dst.
+func withSealedReasoning(dst, src map[string]any) map[string]any {
+	if sealed, ok := src[sealedReasoningField]; ok {
+		dst[sealedReasoningField] = sealed
+	}
+	return dst
+}
+
+// sealReasoning seals the reasoning of every message of a chat completion
+// into its first tool call.
+func (a *Adapter) sealReasoning(responseData map[string]any) {
+	forEachChoice(responseData, "message", func(_ int, message map[string]any) {
+		reasoning, ok := a.extractReasoning(message)
+		if !ok || reasoning == "" {
+			return
+		}
+		toolCalls, _ := message["tool_calls"].([]any)
+		if len(toolCalls) == 0 {
+			return
+		}
+		if toolCall, ok := toolCalls[0].(map[string]any); ok {
+			if id, ok := toolCall["id"].(string); ok && id != "" {
+				toolCall[sealedReasoningField] = a.Cipher.Seal(id, reasoning)
+			}
+		}
+	})
+}
+
+// sealStreamedReasoning seals the reasoning streamed so far for a choice
+// into its first tool call, which gpt-oss only starts once it has finished
+// reasoning. It reports whether eventData was modified.
+func (a *Adapter) sealStreamedReasoning(eventData map[string]any, reasoning map[int]*choiceReasoning) bool {
+	modified := false
+	forEachChoice(eventData, "delta", func(index int, delta map[string]any) {
+		toolCalls, _ := delta["tool_calls"].([]any)
+		for _, tc := range toolCalls {
+			toolCall, ok := tc.(map[string]any)
+			if !ok {
+				continue
+			}
+			if i, _ := toolCall["index"].(float64); i != 0 {
+				continue
+			}
+			id, ok := toolCall["id"].(string)
+			if !ok || id == "" {
+				continue
+			}
+
+			choice, ok := reasoning[index]
+			if !ok {
+				continue
+			}
+			if text, ok := a.streamedReasoning(choice); ok && text != "" {
+				toolCall[sealedReasoningField] = a.Cipher.Seal(id, text)
+				modified = true
+			}
+		}
+	})
+	return modified
+}
@@ -0,0 +1,288 @@
+package adapter
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+)
+
+var testReasoningKey = bytes.Repeat([]byte{7}, 32)
+
+func TestReasoningCipher(t *testing.T) {
+	c, err := NewReasoningCipher(testReasoningKey)
+	require.NoError(t, err)
+
+	sealed := c.Seal("call_1", "Look up Paris.")
+	assert.NotContains(t, sealed, "Paris")
+	reasoning, err := c.Open("call_1", sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "Look up Paris.", reasoning)
+
+	// The reasoning is bound to its ID and key.
+	_, err = c.Open("call_2", sealed)
+	assert.Error(t, err)
+	other, err := NewReasoningCipher(bytes.Repeat([]byte{8}, 32))
+	require.NoError(t, err)
+	_, err = other.Open("call_1", sealed)
+	assert.Error(t, err)
+	_, err = c.Open("call_1", "not base64!")
+	assert.Error(t, err)
+
+	_, err = NewReasoningCipher([]byte("short"))
+	assert.Error(t, err)
+
+	// IDs that earlier versions sealed reasoning into are still opened.
+	id, reasoning, ok, err := c.OpenID("call_1" + sealedIDMarker + sealed)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "call_1", id)
+	assert.Equal(t, "Look up Paris.", reasoning)
+
+	id, _, ok, err = c.OpenID("call_2")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, "call_2", id)
+
+	id, _, ok, err = c.OpenID("call_2" + sealedIDMarker + sealed)
+	assert.Error(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, "call_2", id)
+}
+
+func TestLoadReasoningKey(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "hex")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Repeat("07", 32)+"\n"), 0o600))
+	key, err := LoadReasoningKey(path)
+	require.NoError(t, err)
+	assert.Equal(t, testReasoningKey, key)
+
+	path = filepath.Join(dir, "invalid")
+	require.NoError(t, os.WriteFile(path, []byte("not a key!"), 0o600))
+	_, err = LoadReasoningKey(path)
+	assert.Error(t, err)
+}
+
+// newStatelessAdapter returns an adapter with its own cache whose target
+// answers with a tool call, and stores the last request it received in
+// forwarded.
+func newStatelessAdapter(t *testing.T, forwarded *map[string]any) *Adapter {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(forwarded)
+		if stream, _ := (*forwarded)["stream"].(bool); stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, `data: {"choices":[{"index":0,"delta":{"reasoning_content":"Look up "}}]}`+"\n\n")
+			io.WriteString(w, `data: {"choices":[{"index":0,"delta":{"reasoning_content":"Paris."}}]}`+"\n\n")
+			io.WriteString(w, `data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}`+"\n\n")
+			io.WriteString(w, `data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{}"}}]},"finish_reason":"tool_calls"}]}`+"\n\n")
+			io.WriteString(w, "data: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[{"index":0,"message":{"role":"assistant","reasoning_content":"Look up Paris.","tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{}"}}]},"finish_reason":"tool_calls"}]}`)
+	}))
+	t.Cleanup(backend.Close)
+
+	c, err := NewReasoningCipher(testReasoningKey)
+	require.NoError(t, err)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter(backend.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+	adapter.Cipher = c
+	return adapter
+}
+
+// followUp returns the next turn of a conversation whose assistant turn
+// called a tool with the given ID and sealed reasoning.
+func followUp(id, sealed string) string {
+	field := ""
+	if sealed != "" {
+		field = `,"` + sealedReasoningField + `":"` + sealed + `"`
+	}
+	return `{"messages":[` +
+		`{"role":"user","content":"Weather in Paris?"},` +
+		`{"role":"assistant","tool_calls":[{"id":"` + id + `","type":"function","function":{"name":"get_weather","arguments":"{}"}` + field + `}]},` +
+		`{"role":"tool","tool_call_id":"` + id + `","content":"Sunny"}]}`
+}
+
+func assertRestored(t *testing.T, forwarded map[string]any, reasoning string) {
+	messages := forwarded["messages"].([]any)
+	assistant := messages[1].(map[string]any)
+	assert.Equal(t, reasoning, assistant["reasoning_content"])
+	toolCall := assistant["tool_calls"].([]any)[0].(map[string]any)
+	assert.Equal(t, "call_1", toolCall["id"])
+	assert.NotContains(t, toolCall, sealedReasoningField)
+	assert.Equal(t, "call_1", messages[2].(map[string]any)["tool_call_id"])
+}
+
+func TestStateless_Blocking(t *testing.T) {
+	var forwarded map[string]any
+	first := newStatelessAdapter(t, &forwarded)
+	second := newStatelessAdapter(t, &forwarded)
+
+	w := httptest.NewRecorder()
+	first.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"messages":[]}`)))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	message := response["choices"].([]any)[0].(map[string]any)["message"].(map[string]any)
+	toolCall := message["tool_calls"].([]any)[0].(map[string]any)
+	assert.Equal(t, "call_1", toolCall["id"])
+	sealed, _ := toolCall[sealedReasoningField].(string)
+	require.NotEmpty(t, sealed)
+
+	// A replica with an empty cache restores the reasoning from the tool
+	// call.
+	w = httptest.NewRecorder()
+	second.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(followUp("call_1", sealed))))
+	require.Equal(t, http.StatusOK, w.Code)
+	assertRestored(t, forwarded, "Look up Paris.")
+}
+
+func TestStateless_Stream(t *testing.T) {
+	var forwarded map[string]any
+	first := newStatelessAdapter(t, &forwarded)
+	second := newStatelessAdapter(t, &forwarded)
+
+	w := httptest.NewRecorder()
+	first.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"messages":[],"stream":true}`)))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var sealed string
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		if strings.Contains(line, `"id":"call_1"`) {
+			var chunk map[string]any
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &chunk))
+			delta := chunk["choices"].([]any)[0].(map[string]any)["delta"].(map[string]any)
+			sealed, _ = delta["tool_calls"].([]any)[0].(map[string]any)[sealedReasoningField].(string)
+		}
+	}
+	require.NotEmpty(t, sealed)
+
+	w = httptest.NewRecorder()
+	second.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(followUp("call_1", sealed))))
+	require.Equal(t, http.StatusOK, w.Code)
+	assertRestored(t, forwarded, "Look up Paris.")
+}
+
+func TestStateless_SealedID(t *testing.T) {
+	var forwarded map[string]any
+	adapter := newStatelessAdapter(t, &forwarded)
+
+	// Tool call IDs sealed by earlier versions are still restored, and the
+	// target sees the original IDs.
+	id := "call_1" + sealedIDMarker + adapter.Cipher.Seal("call_1", "Look up Paris.")
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(followUp(id, ""))))
+	require.Equal(t, http.StatusOK, w.Code)
+	assertRestored(t, forwarded, "Look up Paris.")
+}
+
+func TestStateless_LongReasoning(t *testing.T) {
+	reasoning := strings.Repeat("Think it through. ", 2000)
+	var forwarded map[string]any
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&forwarded)
+		if stream, _ := forwarded["stream"].(bool); stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, `data: {"choices":[{"index":0,"delta":{"reasoning_content":"`+reasoning+`"}}]}`+"\n\n")
+			io.WriteString(w, `data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{}"}}]},"finish_reason":"tool_calls"}]}`+"\n\n")
+			io.WriteString(w, "data: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[{"index":0,"message":{"role":"assistant","reasoning_content":"`+reasoning+`","tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{}"}}]},"finish_reason":"tool_calls"}]}`)
+	}))
+	defer backend.Close()
+
+	c, err := NewReasoningCipher(testReasoningKey)
+	require.NoError(t, err)
+
+	// The tool call ID keeps its length however long the reasoning is.
+	for _, body := range []string{`{"messages":[]}`, `{"messages":[],"stream":true}`} {
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+		adapter := NewAdapter(backend.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+		adapter.Cipher = c
+
+		w := httptest.NewRecorder()
+		adapter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"id":"call_1"`, body)
+		assert.NotContains(t, w.Body.String(), sealedIDMarker, body)
+
+		var sealed string
+		for _, line := range strings.Split(w.Body.String(), "\n") {
+			line = strings.TrimPrefix(line, "data: ")
+			if !strings.Contains(line, `"id":"call_1"`) {
+				continue
+			}
+			var data map[string]any
+			require.NoError(t, json.Unmarshal([]byte(line), &data), body)
+			choice := data["choices"].([]any)[0].(map[string]any)
+			message, ok := choice["message"].(map[string]any)
+			if !ok {
+				message = choice["delta"].(map[string]any)
+			}
+			sealed, _ = message["tool_calls"].([]any)[0].(map[string]any)[sealedReasoningField].(string)
+		}
+		require.NotEmpty(t, sealed, body)
+
+		// A replica with an empty cache restores all of it.
+		replica := NewAdapter(backend.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+		replica.Cipher = c
+		w = httptest.NewRecorder()
+		replica.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(followUp("call_1", sealed))))
+		require.Equal(t, http.StatusOK, w.Code)
+		assertRestored(t, forwarded, reasoning)
+	}
+}
+
+func TestStateless_Responses(t *testing.T) {
+	var forwarded map[string]any
+	first := newStatelessAdapter(t, &forwarded)
+	second := newStatelessAdapter(t, &forwarded)
+
+	w := httptest.NewRecorder()
+	first.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/responses", strings.NewReader(`{"input":"Weather in Paris?"}`)))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		Output []map[string]any `json:"output"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	var call map[string]any
+	for _, item := range response.Output {
+		if item["type"] == "function_call" {
+			call = item
+		}
+	}
+	require.NotNil(t, call)
+	assert.Equal(t, "call_1", call["call_id"])
+	assert.NotEmpty(t, call[sealedReasoningField])
+
+	// The sealed reasoning comes back with the function call item.
+	input, err := json.Marshal([]any{
+		map[string]any{"role": "user", "content": "Weather in Paris?"},
+		call,
+		map[string]any{"type": "function_call_output", "call_id": "call_1", "output": "Sunny"},
+	})
+	require.NoError(t, err)
+	w = httptest.NewRecorder()
+	second.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/responses", strings.NewReader(`{"input":`+string(input)+`}`)))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assertRestored(t, forwarded, "Look up Paris.")
+}
@@ -0,0 +1,180 @@
+package adapter
+
+import (
+	"encoding/json"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// streamTiming measures how fast a stream produces tokens: the time from
+// sending the request to the first token, and the rate of the tokens after
+// it.
+type streamTiming struct {
+	sent       time.Time
+	firstToken time.Time
+	lastToken  time.Time
+	chunks     int
+	usage      int
+	attached   bool
+}
+
+// observe records the tokens of a stream chunk.
+func (t *streamTiming) observe(eventData map[string]any, reasoningField string) {
+	if usage, ok := eventData["usage"].(map[string]any); ok {
+		if tokens, ok := usage["completion_tokens"].(float64); ok {
+			t.usage = int(tokens)
+		}
+	}
+
+	produced := false
+	forEachChoice(eventData, "delta", func(_ int, delta map[string]any) {
+		if completionText(delta, reasoningField) != "" {
+			produced = true
+		}
+	})
+	if !produced {
+		return
+	}
+
+	now := time.Now()
+	if t.firstToken.IsZero() {
+		t.firstToken = now
+	}
+	t.lastToken = now
+	t.chunks++
+}
+
+// tokens returns the number of tokens generated, as reported by the target
+// or else counted as one per chunk.
+func (t *streamTiming) tokens() int {
+	if t.usage > 0 {
+		return t.usage
+	}
+	return t.chunks
+}
+
+// timeToFirstToken returns the time from sending the request to the first
+// token.
+func (t *streamTiming) timeToFirstToken() time.Duration {
+	return t.firstToken.Sub(t.sent)
+}
+
+// tokensPerSecond returns the rate of the tokens after the first, or zero if
+// there were not enough to measure it.
+func (t *streamTiming) tokensPerSecond() float64 {
+	elapsed := t.lastToken.Sub(t.firstToken)
+	if t.tokens() < 2 || elapsed <= 0 {
+		return 0
+	}
+	return float64(t.tokens()-1) / elapsed.Seconds()
+}
+
+// stats returns the measurements as the stream_stats field of a chunk.
+func (t *streamTiming) stats() map[string]any {
+	return map[string]any{
+		"time_to_first_token_ms": t.timeToFirstToken().Milliseconds(),
+		"tokens_per_second":      math.Round(t.tokensPerSecond()*10) / 10,
+		"completion_tokens":      t.tokens(),
+	}
+}
+
+// attach adds the measurements to the usage chunk of a stream, once. It
+// reports whether it changed the chunk.
+func (t *streamTiming) attach(eventData map[string]any) bool {
+	if t.attached || t.firstToken.IsZero() {
+		return false
+	}
+	if _, ok := eventData["usage"].(map[string]any); !ok {
+		return false
+	}
+	eventData["stream_stats"] = t.stats()
+	t.attached = true
+	return true
+}
+
+// emitStreamStats sends the measurements in a chunk of their own, for
+// streams whose usage chunk did not carry them.
+func (a *Adapter) emitStreamStats(timing *streamTiming, usage *streamUsage, emit func(line string)) {
+	if timing.attached || timing.firstToken.IsZero() {
+		return
+	}
+	chunk := usage.chunk(nil)
+	delete(chunk, "usage")
+	chunk["stream_stats"] = timing.stats()
+
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		a.logger.Error("failed to marshal stream stats chunk", "error", err)
+		return
+	}
+	emit("data: " + string(data))
+	emit("")
+	timing.attached = true
+}
+
+// streamRates totals the timing of the streams served by each target.
+type streamRates struct {
+	mutex   sync.Mutex
+	targets map[string]*streamRateTotals
+}
+
+type streamRateTotals struct {
+	streams          int64
+	firstTokenMillis int64
+	tokens           int64
+	generationMillis int64
+}
+
+func newStreamRates() *streamRates {
+	return &streamRates{targets: make(map[string]*streamRateTotals)}
+}
+
+// record adds a finished stream to the totals of target.
+func (s *streamRates) record(target string, timing *streamTiming) {
+	if timing.firstToken.IsZero() {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	totals, ok := s.targets[target]
+	if !ok {
+		totals = &streamRateTotals{}
+		s.targets[target] = totals
+	}
+	totals.streams++
+	totals.firstTokenMillis += timing.timeToFirstToken().Milliseconds()
+	totals.tokens += int64(timing.tokens() - 1)
+	totals.generationMillis += timing.lastToken.Sub(timing.firstToken).Milliseconds()
+}
+
+// writeMetrics exposes the totals labelled by target.
+func (s *streamRates) writeMetrics(w io.Writer) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.targets) == 0 {
+		return
+	}
+
+	targets := slices.Sorted(maps.Keys(s.targets))
+	metrics := []struct {
+		name, help string
+		value      func(t *streamRateTotals) int64
+	}{
+		{"gpt_oss_adapter_streams_total", "Streamed chat responses that produced a token.", func(t *streamRateTotals) int64 { return t.streams }},
+		{"gpt_oss_adapter_stream_first_token_milliseconds_total", "Time from sending streamed requests to their first token.", func(t *streamRateTotals) int64 { return t.firstTokenMillis }},
+		{"gpt_oss_adapter_stream_tokens_total", "Tokens streamed after the first token of each response.", func(t *streamRateTotals) int64 { return t.tokens }},
+		{"gpt_oss_adapter_stream_generation_milliseconds_total", "Time from the first to the last token of streamed responses.", func(t *streamRateTotals) int64 { return t.generationMillis }},
+	}
+	for _, metric := range metrics {
+		samples := make([]sample, 0, len(targets))
+		for _, target := range targets {
+			samples = append(samples, sample{labels: map[string]string{"target": target}, value: metric.value(s.targets[target])})
+		}
+		writeMetric(w, metric.name, "counter", metric.help, samples...)
+	}
+}
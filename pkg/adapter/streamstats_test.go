@@ -0,0 +1,106 @@
+package adapter
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+)
+
+func newStreamStatsTestAdapter(t *testing.T, usage bool) *Adapter {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		time.Sleep(20 * time.Millisecond)
+		for _, token := range []string{"One", " two", " three"} {
+			io.WriteString(w, `data: {"id":"chatcmpl-1","model":"gpt-oss-20b","choices":[{"index":0,"delta":{"content":"`+token+`"}}]}`+"\n\n")
+			flusher.Flush()
+			time.Sleep(10 * time.Millisecond)
+		}
+		if usage {
+			io.WriteString(w, `data: {"id":"chatcmpl-1","model":"gpt-oss-20b","choices":[],"usage":{"prompt_tokens":5,"completion_tokens":4,"total_tokens":9}}`+"\n\n")
+		}
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(backend.Close)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter(backend.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+	adapter.StreamStats = true
+	return adapter
+}
+
+// streamStats returns the stream_stats of the chunk carrying them.
+func streamStats(t *testing.T, body string) map[string]any {
+	for _, line := range strings.Split(body, "\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok || data == "[DONE]" {
+			continue
+		}
+		var chunk map[string]any
+		require.NoError(t, json.Unmarshal([]byte(data), &chunk))
+		if stats, ok := chunk["stream_stats"].(map[string]any); ok {
+			return stats
+		}
+	}
+	return nil
+}
+
+func TestStreamStats_UsageChunk(t *testing.T) {
+	adapter := newStreamStatsTestAdapter(t, true)
+
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"gpt-oss-20b","stream":true,"messages":[]}`)))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	stats := streamStats(t, w.Body.String())
+	require.NotNil(t, stats)
+	assert.GreaterOrEqual(t, stats["time_to_first_token_ms"], float64(20))
+	assert.Equal(t, float64(4), stats["completion_tokens"])
+	assert.Greater(t, stats["tokens_per_second"], float64(0))
+	assert.Contains(t, w.Body.String(), `"usage":{"completion_tokens":4`)
+
+	w = httptest.NewRecorder()
+	adapter.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, w.Body.String(), `gpt_oss_adapter_streams_total{target="`+adapter.Target+`"} 1`)
+	assert.Contains(t, w.Body.String(), `gpt_oss_adapter_stream_tokens_total{target="`+adapter.Target+`"} 3`)
+}
+
+func TestStreamStats_NoUsageChunk(t *testing.T) {
+	adapter := newStreamStatsTestAdapter(t, false)
+
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"gpt-oss-20b","stream":true,"messages":[]}`)))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// Without a usage chunk, the stats arrive in a chunk of their own with
+	// the tokens counted per chunk.
+	stats := streamStats(t, w.Body.String())
+	require.NotNil(t, stats)
+	assert.Equal(t, float64(3), stats["completion_tokens"])
+	assert.Less(t, strings.Index(w.Body.String(), "stream_stats"), strings.Index(w.Body.String(), "[DONE]"))
+}
+
+func TestStreamStats_Disabled(t *testing.T) {
+	adapter := newStreamStatsTestAdapter(t, true)
+	adapter.StreamStats = false
+
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"gpt-oss-20b","stream":true,"messages":[]}`)))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "stream_stats")
+
+	// The metrics are kept regardless.
+	w = httptest.NewRecorder()
+	adapter.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, w.Body.String(), "gpt_oss_adapter_stream_first_token_milliseconds_total")
+}
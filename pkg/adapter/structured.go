@@ -0,0 +1,156 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/types"
+)
+
+const (
+	// StructuredOutputAnnotate marks chat completions whose content does not
+	// match the requested schema with a header and logs them.
+	StructuredOutputAnnotate = "annotate"
+	// StructuredOutputReject fails chat completions whose content does not
+	// match the requested schema.
+	StructuredOutputReject = "reject"
+)
+
+// structuredOutputHeader marks a blocking response that failed validation
+// in annotate mode.
+const structuredOutputHeader = "X-GPT-OSS-Structured-Output"
+
+// outputSchema returns the schema the content of a response to a chat
+// request must match: the schema of a json_schema response format, or any
+// object for json_object.
+func outputSchema(requestData map[string]any) map[string]any {
+	format, _ := requestData["response_format"].(map[string]any)
+	switch format["type"] {
+	case "json_schema":
+		jsonSchema, _ := format["json_schema"].(map[string]any)
+		if schema, ok := jsonSchema["schema"].(map[string]any); ok {
+			return schema
+		}
+		return map[string]any{}
+	case "json_object":
+		return map[string]any{"type": "object"}
+	}
+	return nil
+}
+
+// translateResponseFormat rewrites the response_format of a chat request in
+// the form the provider understands.
+func (a *Adapter) translateResponseFormat(requestData map[string]any) {
+	format, ok := requestData["response_format"].(map[string]any)
+	if !ok || format["type"] == "text" {
+		return
+	}
+	schema := outputSchema(requestData)
+	if schema == nil {
+		return
+	}
+
+	switch a.Provider.ResponseFormat {
+	case types.ResponseFormatJSONSchemaField:
+		delete(requestData, "response_format")
+		requestData["json_schema"] = schema
+	case types.ResponseFormatSchemaOnly:
+		if format["type"] == "json_object" {
+			requestData["response_format"] = map[string]any{
+				"type":        "json_schema",
+				"json_schema": map[string]any{"name": "json_object", "schema": schema},
+			}
+		}
+	}
+}
+
+// checkStructuredOutput validates the content of each choice of a chat
+// completion against the requested schema. It returns false if the
+// response was rejected and an error written to w.
+func (a *Adapter) checkStructuredOutput(w http.ResponseWriter, chat *chatRequest, responseData map[string]any) bool {
+	var invalid error
+	forEachChoice(responseData, "message", func(index int, message map[string]any) {
+		if _, ok := message["tool_calls"]; ok || invalid != nil {
+			return
+		}
+		content, _ := message["content"].(string)
+		if err := validateStructuredOutput(chat.outputSchema, content); err != nil {
+			invalid = fmt.Errorf("choice %d: %w", index, err)
+		}
+	})
+	if invalid == nil {
+		return true
+	}
+
+	a.logger.WarnContext(chat.ctx, "model output does not match the response format", "error", invalid)
+	if a.StructuredOutputs == StructuredOutputReject {
+		writeOpenAIError(w, http.StatusBadGateway, "Model output does not match the response format: "+invalid.Error(), "server_error", "invalid_structured_output")
+		return false
+	}
+	w.Header().Set(structuredOutputHeader, "invalid")
+	return true
+}
+
+// validateStructuredOutput checks that content is JSON matching schema.
+func validateStructuredOutput(schema map[string]any, content string) error {
+	var value any
+	if err := json.Unmarshal([]byte(content), &value); err != nil {
+		return fmt.Errorf("content is not valid JSON: %w", err)
+	}
+	return validateJSONSchema(schema, value)
+}
+
+// streamedOutput accumulates the content streamed for each choice, to be
+// validated once the stream ends.
+type streamedOutput map[int]*strings.Builder
+
+func (o streamedOutput) observe(chunk map[string]any) {
+	forEachChoice(chunk, "delta", func(index int, delta map[string]any) {
+		if _, ok := delta["tool_calls"]; ok {
+			// Tool calls are not subject to the response format.
+			o[index] = nil
+		}
+		content, ok := delta["content"].(string)
+		if !ok {
+			return
+		}
+		builder, seen := o[index]
+		if builder == nil && seen {
+			return
+		}
+		if builder == nil {
+			builder = &strings.Builder{}
+			o[index] = builder
+		}
+		builder.WriteString(content)
+	})
+}
+
+// checkStreamedOutput validates the content of a finished stream and, when
+// invalid output is rejected, ends the stream with an error event.
+func (a *Adapter) checkStreamedOutput(chat *chatRequest, output streamedOutput, emit func(line string)) {
+	for index, builder := range output {
+		if builder == nil {
+			continue
+		}
+		err := validateStructuredOutput(chat.outputSchema, builder.String())
+		if err == nil {
+			continue
+		}
+		a.logger.WarnContext(chat.ctx, "model output does not match the response format", "choice", index, "error", err)
+		if a.StructuredOutputs == StructuredOutputReject {
+			data, _ := json.Marshal(map[string]any{
+				"error": openAIError{
+					Message: fmt.Sprintf("Model output does not match the response format: choice %d: %s", index, err),
+					Type:    "server_error",
+					Code:    "invalid_structured_output",
+				},
+			})
+			emit("data: " + string(data))
+			emit("")
+			return
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package adapter
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+	"github.com/aldehir/gpt-oss-adapter/providers/lmstudio"
+)
+
+func TestTranslateResponseFormat(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	schema := map[string]any{"type": "object", "properties": map[string]any{"answer": map[string]any{"type": "string"}}}
+
+	llama := NewAdapter("http://localhost:8080", NewLRUCache(10), logger, llamacpp.NewProvider())
+	request := map[string]any{"response_format": map[string]any{"type": "json_schema", "json_schema": map[string]any{"name": "answer", "schema": schema}}}
+	llama.translateResponseFormat(request)
+	assert.Equal(t, map[string]any{"json_schema": schema}, request)
+
+	request = map[string]any{"response_format": map[string]any{"type": "json_object"}}
+	llama.translateResponseFormat(request)
+	assert.Equal(t, map[string]any{"json_schema": map[string]any{"type": "object"}}, request)
+
+	request = map[string]any{"response_format": map[string]any{"type": "text"}}
+	llama.translateResponseFormat(request)
+	assert.Equal(t, map[string]any{"response_format": map[string]any{"type": "text"}}, request)
+
+	lmStudio := NewAdapter("http://localhost:1234", NewLRUCache(10), logger, lmstudio.NewProvider())
+	request = map[string]any{"response_format": map[string]any{"type": "json_object"}}
+	lmStudio.translateResponseFormat(request)
+	assert.Equal(t, map[string]any{"response_format": map[string]any{
+		"type":        "json_schema",
+		"json_schema": map[string]any{"name": "json_object", "schema": map[string]any{"type": "object"}},
+	}}, request)
+}
+
+func TestStructuredOutputs(t *testing.T) {
+	content := `{"answer":42}`
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var sent map[string]any
+		json.NewDecoder(r.Body).Decode(&sent)
+		if stream, _ := sent["stream"].(bool); stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			for _, part := range []string{content[:5], content[5:]} {
+				data, _ := json.Marshal(map[string]any{"choices": []any{map[string]any{"index": 0, "delta": map[string]any{"content": part}}}})
+				io.WriteString(w, "data: "+string(data)+"\n\n")
+			}
+			io.WriteString(w, "data: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"choices": []any{map[string]any{"index": 0, "message": map[string]any{"role": "assistant", "content": content}}}})
+	}))
+	defer backend.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter(backend.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+	adapter.StructuredOutputs = StructuredOutputAnnotate
+
+	request := `{"model":"gpt-oss-20b","messages":[],"response_format":{"type":"json_schema","json_schema":{"name":"answer","schema":{"type":"object","properties":{"answer":{"type":"string"}},"required":["answer"]}}}}`
+	send := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		adapter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+		return w
+	}
+	streamRequest := strings.Replace(request, `"model"`, `"stream":true,"model"`, 1)
+
+	w := send(request)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "invalid", w.Header().Get(structuredOutputHeader))
+
+	adapter.StructuredOutputs = StructuredOutputReject
+	w = send(request)
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"invalid_structured_output"`)
+	assert.Contains(t, w.Body.String(), "choice 0: $.answer: expected string, got number")
+
+	w = send(streamRequest)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"invalid_structured_output"`)
+	assert.True(t, strings.HasSuffix(w.Body.String(), "data: [DONE]\n\n"))
+
+	content = `{"answer":"42"}`
+	w = send(request)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(structuredOutputHeader))
+	w = send(streamRequest)
+	assert.NotContains(t, w.Body.String(), "error")
+
+	// Content that is not JSON fails a json_object response format.
+	content = "Sure! Here is the JSON."
+	w = send(`{"model":"gpt-oss-20b","messages":[],"response_format":{"type":"json_object"}}`)
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Contains(t, w.Body.String(), "content is not valid JSON")
+}
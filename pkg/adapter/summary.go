@@ -0,0 +1,177 @@
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// summaryPrompt instructs the summarizing model.
+const summaryPrompt = "Summarize the following reasoning of an AI assistant in a few sentences, " +
+	"written in the first person as the assistant's own thinking. Reply with the summary only."
+
+// Summarizer condenses reasoning into a short summary that clients can show
+// instead of the full chain of thought. It sends a chat completions request
+// to URL, which may be the target itself or a smaller model.
+type Summarizer struct {
+	URL string
+	// Model is the model asked for summaries. Empty uses the model of the
+	// response being summarized.
+	Model   string
+	Headers map[string]string
+	client  *http.Client
+}
+
+func NewSummarizer(url, model string, headers map[string]string, timeout time.Duration) *Summarizer {
+	return &Summarizer{
+		URL:     url,
+		Model:   model,
+		Headers: headers,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+type summaryResponse struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	} `json:"choices"`
+}
+
+// Summarize returns a summary of reasoning produced by model.
+func (s *Summarizer) Summarize(ctx context.Context, model, reasoning string) (string, error) {
+	if s.Model != "" {
+		model = s.Model
+	}
+
+	request := map[string]any{
+		"messages": []any{
+			map[string]any{"role": "system", "content": summaryPrompt},
+			map[string]any{"role": "user", "content": reasoning},
+		},
+	}
+	if model != "" {
+		request["model"] = model
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range s.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("summary endpoint returned status %d", resp.StatusCode)
+	}
+
+	var result summaryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	if len(result.Choices) == 0 {
+		return "", errors.New("summary endpoint returned no choices")
+	}
+	return strings.TrimSpace(result.Choices[0].Message.Content), nil
+}
+
+// summarizeReasoning returns a summary of reasoning, or an empty string if
+// there is none or it could not be produced. Failures only cost the client
+// the summary, so they are logged rather than returned.
+func (a *Adapter) summarizeReasoning(ctx context.Context, model, reasoning string) string {
+	if strings.TrimSpace(reasoning) == "" {
+		return ""
+	}
+
+	summary, err := a.Summarizer.Summarize(ctx, model, reasoning)
+	if err != nil {
+		a.logger.WarnContext(ctx, "failed to summarize reasoning", "error", err)
+		return ""
+	}
+	a.logger.DebugContext(ctx, "summarized reasoning", "reasoning_length", len(reasoning), "summary_length", len(summary))
+	return summary
+}
+
+// addReasoningSummaries sets reasoning_summary on every message of a chat
+// completion that has reasoning.
+func (a *Adapter) addReasoningSummaries(chat *chatRequest, responseData map[string]any) {
+	model, _ := responseData["model"].(string)
+	forEachChoice(responseData, "message", func(_ int, message map[string]any) {
+		reasoning, _ := a.extractReasoning(message)
+		if summary := a.summarizeReasoning(chat.ctx, model, reasoning); summary != "" {
+			message["reasoning_summary"] = summary
+		}
+	})
+}
+
+// emitReasoningSummaries sends a chunk with a reasoning_summary delta for
+// every choice of a stream that had reasoning. header holds the id, model
+// and creation time of the stream's chunks.
+func (a *Adapter) emitReasoningSummaries(chat *chatRequest, header map[string]any, reasoning map[int]*choiceReasoning, emit func(line string)) {
+	model, _ := header["model"].(string)
+	for _, index := range slices.Sorted(maps.Keys(reasoning)) {
+		text, _ := a.streamedReasoning(reasoning[index])
+		summary := a.summarizeReasoning(chat.ctx, model, text)
+		if summary == "" {
+			continue
+		}
+
+		chunk := map[string]any{"object": "chat.completion.chunk"}
+		for key, value := range header {
+			chunk[key] = value
+		}
+		chunk["choices"] = []any{map[string]any{
+			"index": index,
+			"delta": map[string]any{"reasoning_summary": summary},
+		}}
+
+		data, err := json.Marshal(chunk)
+		if err != nil {
+			a.logger.Error("failed to marshal reasoning summary chunk", "error", err)
+			continue
+		}
+		emit("data: " + string(data))
+		emit("")
+	}
+}
+
+// streamHeader returns the fields of a stream chunk that identify its
+// stream.
+func streamHeader(eventData map[string]any) map[string]any {
+	header := make(map[string]any)
+	for _, key := range []string{"id", "created", "model"} {
+		if value, ok := eventData[key]; ok {
+			header[key] = value
+		}
+	}
+	return header
+}
+
+// summaryOutput returns the summary of a Responses API reasoning item.
+func summaryOutput(summary string) []any {
+	if summary == "" {
+		return []any{}
+	}
+	return []any{map[string]any{"type": "summary_text", "text": summary}}
+}
@@ -0,0 +1,104 @@
+package adapter
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+)
+
+// newSummaryTestAdapter returns an adapter whose target answers chat requests
+// with reasoning, and summary requests with a summary of it.
+func newSummaryTestAdapter(t *testing.T) *Adapter {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]any
+		json.NewDecoder(r.Body).Decode(&request)
+
+		messages, _ := request["messages"].([]any)
+		if len(messages) == 2 && messages[0].(map[string]any)["content"] == summaryPrompt {
+			assert.Equal(t, "summarizer", request["model"])
+			user := messages[1].(map[string]any)
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"choices":[{"message":{"role":"assistant","content":" Summary of: `+user["content"].(string)+` "}}]}`)
+			return
+		}
+
+		if stream, _ := request["stream"].(bool); stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, `data: {"id":"chatcmpl-1","model":"gpt-oss","choices":[{"index":0,"delta":{"reasoning_content":"Think hard."}}]}`+"\n\n")
+			io.WriteString(w, `data: {"id":"chatcmpl-1","model":"gpt-oss","choices":[{"index":0,"delta":{"content":"Done."},"finish_reason":"stop"}]}`+"\n\n")
+			io.WriteString(w, "data: [DONE]\n\n")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"model":"gpt-oss","choices":[{"index":0,"message":{"role":"assistant","reasoning_content":"Think hard.","content":"Done."},"finish_reason":"stop"}]}`)
+	}))
+	t.Cleanup(backend.Close)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter(backend.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+	adapter.Summarizer = NewSummarizer(backend.URL+"/v1/chat/completions", "summarizer", nil, time.Second)
+	return adapter
+}
+
+func serveSummaryRequest(adapter *Adapter, path, body string) string {
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	return w.Body.String()
+}
+
+func TestReasoningSummary_Blocking(t *testing.T) {
+	adapter := newSummaryTestAdapter(t)
+	adapter.StripReasoning = true
+
+	var response map[string]any
+	require.NoError(t, json.Unmarshal([]byte(serveSummaryRequest(adapter, "/v1/chat/completions", `{"messages":[]}`)), &response))
+
+	message := response["choices"].([]any)[0].(map[string]any)["message"].(map[string]any)
+	assert.Equal(t, "Summary of: Think hard.", message["reasoning_summary"])
+	assert.NotContains(t, message, "reasoning")
+}
+
+func TestReasoningSummary_Stream(t *testing.T) {
+	adapter := newSummaryTestAdapter(t)
+
+	body := serveSummaryRequest(adapter, "/v1/chat/completions", `{"messages":[],"stream":true}`)
+	assert.Contains(t, body, `data: {"choices":[{"delta":{"reasoning_summary":"Summary of: Think hard."},"index":0}],"id":"chatcmpl-1","model":"gpt-oss","object":"chat.completion.chunk"}`+"\n\ndata: [DONE]")
+}
+
+func TestReasoningSummary_Responses(t *testing.T) {
+	adapter := newSummaryTestAdapter(t)
+
+	var response map[string]any
+	require.NoError(t, json.Unmarshal([]byte(serveSummaryRequest(adapter, "/v1/responses", `{"input":"Hi"}`)), &response))
+
+	item := response["output"].([]any)[0].(map[string]any)
+	assert.Equal(t, "reasoning", item["type"])
+	assert.Equal(t, []any{map[string]any{"type": "summary_text", "text": "Summary of: Think hard."}}, item["summary"])
+
+	body := serveSummaryRequest(adapter, "/v1/responses", `{"input":"Hi","stream":true}`)
+	assert.Contains(t, body, "event: response.reasoning_summary_text.done\n")
+	assert.Contains(t, body, `"summary":[{"text":"Summary of: Think hard.","type":"summary_text"}]`)
+	assert.Equal(t, 1, strings.Count(body, "event: response.reasoning_summary_text.done"))
+}
+
+func TestReasoningSummary_Failure(t *testing.T) {
+	adapter := newSummaryTestAdapter(t)
+	unavailable := httptest.NewServer(http.NotFoundHandler())
+	unavailable.Close()
+	adapter.Summarizer.URL = unavailable.URL
+
+	body := serveSummaryRequest(adapter, "/v1/chat/completions", `{"messages":[]}`)
+	assert.Contains(t, body, `"content":"Done."`)
+	assert.NotContains(t, body, "reasoning_summary")
+}
@@ -0,0 +1,152 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// tapBuffer is how many events an observer may fall behind before events
+// are dropped for it.
+const tapBuffer = 256
+
+// tapEvent is an event mirrored to /admin/tap observers. Kind is "start" and
+// "end" for the lifetime of a stream, "upstream" for an event data line
+// received from the target and "client" for the transformed line relayed to
+// the client.
+type tapEvent struct {
+	Stream uint64    `json:"stream"`
+	Kind   string    `json:"kind"`
+	Time   time.Time `json:"time"`
+	Model  string    `json:"model,omitempty"`
+	Data   string    `json:"data,omitempty"`
+}
+
+// tap mirrors in-flight streaming responses to observers. Publishing never
+// blocks a stream: an observer that falls behind misses events.
+type tap struct {
+	mu        sync.Mutex
+	observers map[chan tapEvent]struct{}
+	count     atomic.Int32
+	streams   atomic.Uint64
+}
+
+func newTap() *tap {
+	return &tap{observers: make(map[chan tapEvent]struct{})}
+}
+
+func (t *tap) subscribe() chan tapEvent {
+	ch := make(chan tapEvent, tapBuffer)
+	t.mu.Lock()
+	t.observers[ch] = struct{}{}
+	t.mu.Unlock()
+	t.count.Add(1)
+	return ch
+}
+
+func (t *tap) unsubscribe(ch chan tapEvent) {
+	t.mu.Lock()
+	delete(t.observers, ch)
+	t.mu.Unlock()
+	t.count.Add(-1)
+}
+
+func (t *tap) publish(event tapEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for ch := range t.observers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// tapStream publishes the events of one stream.
+type tapStream struct {
+	tap *tap
+	id  uint64
+}
+
+// open starts mirroring a stream, or returns nil if nobody is observing.
+func (t *tap) open(chat *chatRequest) *tapStream {
+	if t == nil || t.count.Load() == 0 {
+		return nil
+	}
+
+	s := &tapStream{tap: t, id: t.streams.Add(1)}
+	model, _ := chat.data["model"].(string)
+	s.publish(tapEvent{Kind: "start", Model: model})
+	return s
+}
+
+func (s *tapStream) publish(event tapEvent) {
+	event.Stream = s.id
+	event.Time = time.Now()
+	s.tap.publish(event)
+}
+
+// wrap returns an emit function that also mirrors the data lines relayed to
+// the client.
+func (s *tapStream) wrap(emit func(line string)) func(line string) {
+	return func(line string) {
+		if data, ok := strings.CutPrefix(line, "data:"); ok {
+			s.publish(tapEvent{Kind: "client", Data: strings.TrimPrefix(data, " ")})
+		}
+		emit(line)
+	}
+}
+
+func (s *tapStream) close() {
+	s.publish(tapEvent{Kind: "end"})
+}
+
+// handleAdminTap streams the events of in-flight streaming responses to an
+// operator as server-sent events, until the operator disconnects.
+func (a *Adapter) handleAdminTap(w http.ResponseWriter, r *http.Request) {
+	if !a.authorizeAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "Method not allowed", "invalid_request_error", "")
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeOpenAIError(w, http.StatusInternalServerError, "Streaming not supported", "server_error", "")
+		return
+	}
+
+	events := a.tap.subscribe()
+	defer a.tap.unsubscribe(events)
+	a.logger.Info("tap observer connected", "client_ip", getClientIP(r))
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			a.logger.Info("tap observer disconnected", "client_ip", getClientIP(r))
+			return
+		case event := <-events:
+			if a.Scrubber != nil && event.Data != "" {
+				event.Data = string(a.Scrubber.ScrubJSON([]byte(event.Data)))
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Kind, data)
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package adapter
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+)
+
+func TestAdminTap(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, `data: {"choices":[{"index":0,"delta":{"reasoning_content":"Greet."}}]}`+"\n\n")
+		io.WriteString(w, `data: {"choices":[{"index":0,"delta":{"content":"Hi"},"finish_reason":"stop"}]}`+"\n\n")
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	defer backend.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter(backend.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+	adapter.AdminToken = "secret"
+	adapter.StripReasoning = true
+	server := httptest.NewServer(adapter)
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/admin/tap", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	tapResp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer tapResp.Body.Close()
+	require.Equal(t, http.StatusOK, tapResp.StatusCode)
+	assert.Equal(t, "text/event-stream", tapResp.Header.Get("Content-Type"))
+	require.Eventually(t, func() bool { return adapter.tap.count.Load() == 1 }, time.Second, 10*time.Millisecond)
+
+	events := make(chan tapEvent)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(tapResp.Body)
+		for scanner.Scan() {
+			if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+				var event tapEvent
+				if json.Unmarshal([]byte(data), &event) == nil {
+					events <- event
+				}
+			}
+		}
+	}()
+
+	resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json",
+		strings.NewReader(`{"model":"gpt-oss-20b","stream":true,"messages":[{"role":"user","content":"Hello"}]}`))
+	require.NoError(t, err)
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	var received []tapEvent
+	timeout := time.After(5 * time.Second)
+	for len(received) == 0 || received[len(received)-1].Kind != "end" {
+		select {
+		case event, ok := <-events:
+			require.True(t, ok, "tap closed early")
+			received = append(received, event)
+		case <-timeout:
+			t.Fatalf("timed out waiting for tap events, got %v", received)
+		}
+	}
+
+	assert.Equal(t, "start", received[0].Kind)
+	assert.Equal(t, "gpt-oss-20b", received[0].Model)
+
+	var upstream, client []string
+	for _, event := range received {
+		assert.Equal(t, received[0].Stream, event.Stream)
+		switch event.Kind {
+		case "upstream":
+			upstream = append(upstream, event.Data)
+		case "client":
+			client = append(client, event.Data)
+		}
+	}
+	require.Len(t, upstream, 3)
+	assert.Contains(t, upstream[0], "Greet.")
+	assert.Equal(t, "[DONE]", upstream[2])
+	require.Len(t, client, 3)
+	assert.NotContains(t, client[0], "Greet.")
+	assert.Contains(t, client[1], `"content":"Hi"`)
+}
+
+func TestAdminTap_RequiresToken(t *testing.T) {
+	adapter := newAdminTestAdapter(NewLRUCache(10))
+
+	w, _ := adminRequest(t, adapter, http.MethodGet, "/admin/tap", "wrong")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	adapter.AdminToken = ""
+	w, _ = adminRequest(t, adapter, http.MethodGet, "/admin/tap", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestTap_NoObservers(t *testing.T) {
+	assert.Nil(t, newTap().open(&chatRequest{data: map[string]any{}}))
+}
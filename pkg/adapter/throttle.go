@@ -0,0 +1,108 @@
+package adapter
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+type ModelLimit struct {
+	Concurrency   int
+	RatePerMinute int
+}
+
+type ModelThrottle struct {
+	limits  map[string]ModelLimit
+	mutex   sync.Mutex
+	active  map[string]int
+	buckets map[string]*tokenBucket
+}
+
+func NewModelThrottle(limits map[string]ModelLimit) *ModelThrottle {
+	t := &ModelThrottle{
+		limits:  limits,
+		active:  make(map[string]int),
+		buckets: make(map[string]*tokenBucket),
+	}
+
+	for model, limit := range limits {
+		if limit.RatePerMinute > 0 {
+			t.buckets[model] = newTokenBucket(float64(limit.RatePerMinute), float64(limit.RatePerMinute)/60)
+		}
+	}
+
+	return t
+}
+
+// Acquire reserves a slot for a request against the given model. When the
+// request is allowed, the returned release function must be called once the
+// request completes. When it is rejected, retryAfter hints how long the
+// client should wait before trying again.
+func (t *ModelThrottle) Acquire(model string) (release func(), retryAfter time.Duration, ok bool) {
+	limit, exists := t.limits[model]
+	if !exists {
+		return func() {}, 0, true
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if limit.Concurrency > 0 && t.active[model] >= limit.Concurrency {
+		return nil, time.Second, false
+	}
+
+	if bucket, exists := t.buckets[model]; exists {
+		if wait := bucket.take(time.Now()); wait > 0 {
+			return nil, wait, false
+		}
+	}
+
+	t.active[model]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.mutex.Lock()
+			defer t.mutex.Unlock()
+			t.active[model]--
+		})
+	}, 0, true
+}
+
+// tokenBucket is a simple token bucket. It is not safe for concurrent use;
+// callers are expected to hold their own lock.
+type tokenBucket struct {
+	capacity float64
+	rate     float64
+	tokens   float64
+	last     time.Time
+}
+
+func newTokenBucket(capacity, ratePerSecond float64) *tokenBucket {
+	return &tokenBucket{
+		capacity: capacity,
+		rate:     ratePerSecond,
+		tokens:   capacity,
+	}
+}
+
+// take consumes a token if one is available and returns zero. Otherwise it
+// returns the time until the next token becomes available.
+func (b *tokenBucket) take(now time.Time) time.Duration {
+	if !b.last.IsZero() {
+		elapsed := now.Sub(b.last).Seconds()
+		b.tokens = math.Min(b.capacity, b.tokens+elapsed*b.rate)
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+func retryAfterSeconds(d time.Duration) int {
+	return int(math.Max(1, math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,72 @@
+package adapter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModelThrottle_Concurrency(t *testing.T) {
+	throttle := NewModelThrottle(map[string]ModelLimit{
+		"gpt-oss-120b": {Concurrency: 2},
+	})
+
+	release1, _, ok := throttle.Acquire("gpt-oss-120b")
+	require.True(t, ok)
+	release2, _, ok := throttle.Acquire("gpt-oss-120b")
+	require.True(t, ok)
+
+	_, retryAfter, ok := throttle.Acquire("gpt-oss-120b")
+	assert.False(t, ok)
+	assert.Greater(t, retryAfter, time.Duration(0))
+
+	release1()
+	release1()
+
+	release3, _, ok := throttle.Acquire("gpt-oss-120b")
+	assert.True(t, ok)
+
+	_, _, ok = throttle.Acquire("gpt-oss-120b")
+	assert.False(t, ok)
+
+	release2()
+	release3()
+}
+
+func TestModelThrottle_UnlimitedModel(t *testing.T) {
+	throttle := NewModelThrottle(map[string]ModelLimit{
+		"gpt-oss-120b": {Concurrency: 1},
+	})
+
+	for i := 0; i < 10; i++ {
+		_, _, ok := throttle.Acquire("gpt-oss-20b")
+		assert.True(t, ok)
+	}
+}
+
+func TestModelThrottle_Rate(t *testing.T) {
+	throttle := NewModelThrottle(map[string]ModelLimit{
+		"gpt-oss-120b": {RatePerMinute: 2},
+	})
+
+	for i := 0; i < 2; i++ {
+		release, _, ok := throttle.Acquire("gpt-oss-120b")
+		require.True(t, ok)
+		release()
+	}
+
+	_, retryAfter, ok := throttle.Acquire("gpt-oss-120b")
+	assert.False(t, ok)
+	assert.InDelta(t, 30*time.Second, retryAfter, float64(time.Second))
+}
+
+func TestTokenBucket_Refill(t *testing.T) {
+	bucket := newTokenBucket(1, 1)
+	now := time.Now()
+
+	assert.Equal(t, time.Duration(0), bucket.take(now))
+	assert.Greater(t, bucket.take(now), time.Duration(0))
+	assert.Equal(t, time.Duration(0), bucket.take(now.Add(1500*time.Millisecond)))
+}
@@ -0,0 +1,55 @@
+package adapter
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// LoadUpstreamTLSConfig builds the TLS configuration for connections to the
+// target from a CA bundle, an optional client certificate and key for mutual
+// TLS, and whether to skip certificate verification. It returns nil when no
+// option is set, so that the default configuration is used.
+func LoadUpstreamTLSConfig(caFile, certFile, keyFile string, insecureSkipVerify bool) (*tls.Config, error) {
+	if caFile == "" && certFile == "" && keyFile == "" && !insecureSkipVerify {
+		return nil, nil
+	}
+
+	config := &tls.Config{
+		InsecureSkipVerify: insecureSkipVerify,
+	}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no certificates found", caFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, fmt.Errorf("client certificate and key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// SetUpstreamTLS makes requests to the target, including those of the health
+// checker and of the routes and replicas to the same target, use config.
+// Routes, replicas and a fallback with another target keep the default
+// configuration.
+func (a *Adapter) SetUpstreamTLS(config *tls.Config) {
+	a.upstreamTransport().TLSClientConfig = config
+}
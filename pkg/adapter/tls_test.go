@@ -0,0 +1,77 @@
+package adapter
+
+import (
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+)
+
+func TestLoadUpstreamTLSConfig_Unset(t *testing.T) {
+	config, err := LoadUpstreamTLSConfig("", "", "", false)
+	require.NoError(t, err)
+	assert.Nil(t, config)
+}
+
+func TestLoadUpstreamTLSConfig_Errors(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty.pem")
+	require.NoError(t, os.WriteFile(empty, nil, 0o600))
+
+	_, err := LoadUpstreamTLSConfig(empty, "", "", false)
+	assert.ErrorContains(t, err, "no certificates found")
+
+	_, err = LoadUpstreamTLSConfig("", "client.pem", "", false)
+	assert.ErrorContains(t, err, "must be set together")
+
+	_, err = LoadUpstreamTLSConfig(filepath.Join(dir, "missing.pem"), "", "", false)
+	assert.Error(t, err)
+}
+
+func TestSetUpstreamTLS(t *testing.T) {
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: upstream.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caFile, certPEM, 0o600))
+
+	tests := []struct {
+		name     string
+		caFile   string
+		insecure bool
+		status   int
+	}{
+		{"untrusted", "", false, http.StatusBadGateway},
+		{"ca bundle", caFile, false, http.StatusOK},
+		{"insecure", "", true, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			adapter := NewAdapter(upstream.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+
+			config, err := LoadUpstreamTLSConfig(tt.caFile, "", "", tt.insecure)
+			require.NoError(t, err)
+			if config != nil {
+				adapter.SetUpstreamTLS(config)
+			}
+
+			rec := httptest.NewRecorder()
+			adapter.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+			assert.Equal(t, tt.status, rec.Code)
+		})
+	}
+}
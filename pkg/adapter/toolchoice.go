@@ -0,0 +1,163 @@
+package adapter
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// requiredToolCall reports whether the tool_choice of a chat request
+// requires a tool call, and the name of the function it forces, if any.
+func requiredToolCall(requestData map[string]any) (string, bool) {
+	switch choice := requestData["tool_choice"].(type) {
+	case string:
+		return "", choice == "required"
+	case map[string]any:
+		function, _ := choice["function"].(map[string]any)
+		name, _ := function["name"].(string)
+		return name, name != ""
+	}
+	return "", false
+}
+
+// forceToolCallGrammar replaces the tools of a request whose tool_choice
+// requires a tool call with a json_schema response format that only admits
+// a call of one of them, as an object with the function's name and
+// arguments. It reports whether the request was changed.
+func (a *Adapter) forceToolCallGrammar(requestData map[string]any) bool {
+	name, required := requiredToolCall(requestData)
+	tools, _ := requestData["tools"].([]any)
+	if !required || len(tools) == 0 {
+		return false
+	}
+
+	var calls []any
+	for _, t := range tools {
+		tool, _ := t.(map[string]any)
+		function, _ := tool["function"].(map[string]any)
+		if function == nil || name != "" && function["name"] != name {
+			continue
+		}
+		call := map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"name":      map[string]any{"const": function["name"]},
+				"arguments": function["parameters"],
+			},
+			"required": []any{"name", "arguments"},
+		}
+		if description, ok := function["description"]; ok {
+			call["description"] = description
+		}
+		calls = append(calls, call)
+	}
+	if len(calls) == 0 {
+		return false
+	}
+	schema := calls[0].(map[string]any)
+	if len(calls) > 1 {
+		schema = map[string]any{"anyOf": calls}
+	}
+
+	if _, ok := requestData["response_format"]; ok {
+		a.logger.Debug("replacing response format with forced tool call")
+	}
+	requestData["response_format"] = map[string]any{
+		"type":        "json_schema",
+		"json_schema": map[string]any{"name": "tool_call", "strict": true, "schema": schema},
+	}
+	delete(requestData, "tools")
+	delete(requestData, "tool_choice")
+	delete(requestData, "parallel_tool_calls")
+	return true
+}
+
+// parseGrammarToolCall returns the tool call for the content a model
+// produced under forceToolCallGrammar.
+func parseGrammarToolCall(content string) (map[string]any, bool) {
+	var call struct {
+		Name      string `json:"name"`
+		Arguments any    `json:"arguments"`
+	}
+	if err := json.Unmarshal([]byte(content), &call); err != nil || call.Name == "" {
+		return nil, false
+	}
+	arguments, err := json.Marshal(call.Arguments)
+	if err != nil {
+		return nil, false
+	}
+	return map[string]any{
+		"id":   newResponsesID("call"),
+		"type": "function",
+		"function": map[string]any{
+			"name":      call.Name,
+			"arguments": string(arguments),
+		},
+	}, true
+}
+
+// grammarToolCalls turns the content of a chat completion generated under
+// forceToolCallGrammar into tool calls. It is idempotent.
+func (a *Adapter) grammarToolCalls(responseData map[string]any) {
+	choices, _ := responseData["choices"].([]any)
+	for _, c := range choices {
+		choice, _ := c.(map[string]any)
+		message, _ := choice["message"].(map[string]any)
+		content, _ := message["content"].(string)
+		if content == "" {
+			continue
+		}
+		call, ok := parseGrammarToolCall(content)
+		if !ok {
+			a.logger.Warn("forced tool call is not valid JSON, returning it as content")
+			continue
+		}
+		message["content"] = nil
+		message["tool_calls"] = []any{call}
+		choice["finish_reason"] = "tool_calls"
+	}
+}
+
+// grammarToolCallChunk holds back the content of a stream generated under
+// forceToolCallGrammar, and sends it as a tool call with the finish
+// reason. It reports whether the chunk was modified.
+func grammarToolCallChunk(chunk map[string]any, content map[int]*strings.Builder) bool {
+	modified := false
+	choices, _ := chunk["choices"].([]any)
+	for i, c := range choices {
+		choice, _ := c.(map[string]any)
+		index := i
+		if n, ok := choice["index"].(float64); ok {
+			index = int(n)
+		}
+		delta, _ := choice["delta"].(map[string]any)
+		if delta == nil {
+			if content[index] == nil {
+				continue
+			}
+			delta = make(map[string]any)
+			choice["delta"] = delta
+		}
+		if text, ok := delta["content"].(string); ok {
+			if content[index] == nil {
+				content[index] = &strings.Builder{}
+			}
+			content[index].WriteString(text)
+			delete(delta, "content")
+			modified = true
+		}
+
+		if _, ok := choice["finish_reason"].(string); !ok || content[index] == nil {
+			continue
+		}
+		if call, ok := parseGrammarToolCall(content[index].String()); ok {
+			call["index"] = 0
+			delta["tool_calls"] = []any{call}
+			choice["finish_reason"] = "tool_calls"
+		} else {
+			delta["content"] = content[index].String()
+		}
+		delete(content, index)
+		modified = true
+	}
+	return modified
+}
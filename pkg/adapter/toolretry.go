@@ -0,0 +1,229 @@
+package adapter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/types"
+)
+
+// Reasons a tool call is invalid, as reported in logs and metrics.
+const (
+	toolCallTruncated       = "truncated"
+	toolCallInvalidJSON     = "invalid_json"
+	toolCallUnknownFunction = "unknown_function"
+	toolCallSchemaMismatch  = "schema_mismatch"
+)
+
+// toolRetryCounts counts the chat requests sent again because of the tool
+// calls of the response.
+type toolRetryCounts struct {
+	// missing counts responses that did not call the required tool.
+	missing atomic.Int64
+
+	mutex   sync.Mutex
+	invalid map[string]int64
+}
+
+func (c *toolRetryCounts) addInvalid(reason string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.invalid == nil {
+		c.invalid = make(map[string]int64)
+	}
+	c.invalid[reason]++
+}
+
+// invalidCounts returns the retries for invalid tool calls by reason.
+func (c *toolRetryCounts) invalidCounts() map[string]int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return maps.Clone(c.invalid)
+}
+
+// invalidToolCallError describes the first invalid tool call of a response.
+type invalidToolCallError struct {
+	reason   string
+	function string
+	err      error
+}
+
+func (e *invalidToolCallError) Error() string {
+	return fmt.Sprintf("tool call of %s: %v", e.function, e.err)
+}
+
+// forwardWithToolRetries forwards a chat request like forwardChatRequest,
+// and sends a blocking request with tools again when the model's answer
+// does not use them as it should. A request whose tool_choice requires a
+// tool call is sent up to ToolChoiceRetries more times while the model does
+// not call it, after which its last answer is returned. One whose answer
+// calls a tool with arguments that are truncated, are not JSON or do not
+// match the tool's parameters is sent up to ToolCallRetries more times,
+// with a system message telling the model what was wrong, after which the
+// request fails. The request is moderated and counted in usage once, however
+// many times it is sent.
+func (a *Adapter) forwardWithToolRetries(w http.ResponseWriter, r *http.Request, chat *chatRequest, path string) (*http.Response, *Adapter, bool) {
+	name, required := requiredToolCall(chat.data)
+	choiceRetries := 0
+	if required {
+		choiceRetries = a.ToolChoiceRetries
+	}
+	callRetries := 0
+	if len(toolParameters(chat.data)) > 0 {
+		callRetries = a.ToolCallRetries
+	}
+	stream, _ := chat.data["stream"].(bool)
+	if stream || choiceRetries <= 0 && callRetries <= 0 {
+		return a.forwardChatRequest(w, r, chat, path)
+	}
+
+	original := deepCopyJSON(chat.data).(map[string]any)
+	parameters := toolParameters(original)
+	var correction string
+	for choiceRetry, callRetry := 0, 0; ; {
+		if correction != "" {
+			messages, _ := chat.data["messages"].([]any)
+			chat.data["messages"] = append(messages, map[string]any{"role": "system", "content": correction})
+		}
+
+		resp, upstream, ok := a.forwardChatRequest(w, r, chat, path)
+		if !ok || resp.StatusCode != http.StatusOK {
+			return resp, upstream, ok
+		}
+		chat.retried = true
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		var responseData map[string]any
+		if err != nil || json.Unmarshal(body, &responseData) != nil {
+			return resp, upstream, ok
+		}
+		if upstream.Provider.ToolChoice == types.ToolChoiceGrammar {
+			upstream.grammarToolCalls(responseData)
+		}
+
+		if required && !calledTool(responseData, name) {
+			if choiceRetry == choiceRetries {
+				return resp, upstream, ok
+			}
+			choiceRetry++
+			a.logger.WarnContext(r.Context(), "model did not call the required tool, retrying", "function", name, "retry", choiceRetry)
+			a.toolRetries.missing.Add(1)
+			chat.data = deepCopyJSON(original).(map[string]any)
+			correction = ""
+			continue
+		}
+
+		var invalid *invalidToolCallError
+		if err := checkToolCalls(responseData, parameters); callRetries > 0 && errors.As(err, &invalid) {
+			if callRetry == callRetries {
+				resp.Body.Close()
+				a.logger.ErrorContext(r.Context(), "model made an invalid tool call, giving up", "reason", invalid.reason, "error", invalid, "retries", callRetry)
+				writeError(w, r, http.StatusBadGateway, "Model made an invalid "+invalid.Error(), "server_error", "invalid_tool_call")
+				return nil, nil, false
+			}
+			callRetry++
+			a.logger.WarnContext(r.Context(), "model made an invalid tool call, retrying", "reason", invalid.reason, "error", invalid, "retry", callRetry)
+			a.toolRetries.addInvalid(invalid.reason)
+			chat.data = deepCopyJSON(original).(map[string]any)
+			correction = fmt.Sprintf("Your previous attempt made an invalid %s. Call the tool again with complete arguments: a single JSON object matching the tool's parameters schema.", invalid)
+			continue
+		}
+		return resp, upstream, ok
+	}
+}
+
+// toolParameters returns the parameters schema of each function of a
+// chat request, from its tools or its legacy functions.
+func toolParameters(requestData map[string]any) map[string]any {
+	parameters := make(map[string]any)
+	tools, _ := requestData["tools"].([]any)
+	for _, t := range tools {
+		tool, _ := t.(map[string]any)
+		function, _ := tool["function"].(map[string]any)
+		if name, ok := function["name"].(string); ok {
+			parameters[name] = function["parameters"]
+		}
+	}
+	functions, _ := requestData["functions"].([]any)
+	for _, f := range functions {
+		function, _ := f.(map[string]any)
+		if name, ok := function["name"].(string); ok {
+			parameters[name] = function["parameters"]
+		}
+	}
+	return parameters
+}
+
+// calledTool reports whether every choice of a chat completion calls a
+// tool, and the forced function if name is set.
+func calledTool(responseData map[string]any, name string) bool {
+	choices, _ := responseData["choices"].([]any)
+	if len(choices) == 0 {
+		return false
+	}
+	for _, c := range choices {
+		choice, _ := c.(map[string]any)
+		message, _ := choice["message"].(map[string]any)
+		toolCalls, _ := message["tool_calls"].([]any)
+		if len(toolCalls) == 0 {
+			return false
+		}
+		for _, tc := range toolCalls {
+			call, _ := tc.(map[string]any)
+			function, _ := call["function"].(map[string]any)
+			if name != "" && function["name"] != name {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// checkToolCalls returns an *invalidToolCallError for the first tool call
+// of a chat completion that was cut off, calls a function that is not in
+// parameters, or has arguments that are not a JSON object matching the
+// function's parameters.
+func checkToolCalls(responseData map[string]any, parameters map[string]any) error {
+	choices, _ := responseData["choices"].([]any)
+	for _, c := range choices {
+		choice, _ := c.(map[string]any)
+		message, _ := choice["message"].(map[string]any)
+		toolCalls, _ := message["tool_calls"].([]any)
+		for _, tc := range toolCalls {
+			call, _ := tc.(map[string]any)
+			function, _ := call["function"].(map[string]any)
+			name, _ := function["name"].(string)
+			if choice["finish_reason"] == "length" {
+				return &invalidToolCallError{toolCallTruncated, name, errors.New("the response was cut off at the token limit")}
+			}
+			schema, ok := parameters[name]
+			if !ok {
+				return &invalidToolCallError{toolCallUnknownFunction, name, fmt.Errorf("the function is not one of %v", slices.Sorted(maps.Keys(parameters)))}
+			}
+
+			arguments, _ := function["arguments"].(string)
+			var value any
+			if err := json.Unmarshal([]byte(arguments), &value); err != nil {
+				return &invalidToolCallError{toolCallInvalidJSON, name, fmt.Errorf("the arguments are not valid JSON: %w", err)}
+			}
+			if schema, ok := schema.(map[string]any); ok {
+				if err := validateJSONSchema(schema, value); err != nil {
+					return &invalidToolCallError{toolCallSchemaMismatch, name, fmt.Errorf("the arguments do not match the parameters: %w", err)}
+				}
+			} else if _, ok := value.(map[string]any); !ok {
+				return &invalidToolCallError{toolCallSchemaMismatch, name, errors.New("the arguments are not a JSON object")}
+			}
+		}
+	}
+	return nil
+}
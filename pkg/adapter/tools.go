@@ -0,0 +1,253 @@
+package adapter
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+)
+
+// toolNamePattern is what OpenAI accepts as a function name. Backends that
+// compile tools into a grammar fail in less helpful ways on other names.
+var toolNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// schemaMaps are the JSON Schema keywords whose values map names to
+// schemas, rather than being schemas themselves.
+var schemaMaps = []string{"properties", "patternProperties", "$defs", "definitions", "dependentSchemas"}
+
+// normalizeTools prepares the tools of a chat request for the backend. The
+// legacy functions and function_call fields are translated to tools and
+// tool_choice, with chat.legacyFunctions set so that the response is
+// translated back. Tools are then checked, so that malformed ones are
+// rejected with a 400 instead of failing in the backend, and their schemas
+// are stripped of what the provider cannot compile.
+func (a *Adapter) normalizeTools(chat *chatRequest, requestData map[string]any) error {
+	if legacyFunctionsToTools(requestData) {
+		chat.legacyFunctions = true
+	}
+
+	tools, ok := requestData["tools"]
+	if !ok {
+		return validateToolChoice(requestData["tool_choice"], nil)
+	}
+	list, ok := tools.([]any)
+	if !ok {
+		return toolsError("tools must be an array")
+	}
+
+	names := make([]string, 0, len(list))
+	for i, t := range list {
+		tool, ok := t.(map[string]any)
+		if !ok {
+			return toolsError(fmt.Sprintf("tools[%d] must be an object", i))
+		}
+		if _, ok := tool["type"]; !ok {
+			tool["type"] = "function"
+		}
+		if tool["type"] != "function" {
+			return toolsError(fmt.Sprintf("tools[%d] has unsupported type %v", i, tool["type"]))
+		}
+		function, ok := tool["function"].(map[string]any)
+		if !ok {
+			return toolsError(fmt.Sprintf("tools[%d].function must be an object", i))
+		}
+		name, _ := function["name"].(string)
+		if !toolNamePattern.MatchString(name) {
+			return toolsError(fmt.Sprintf("tools[%d].function.name %q must be 1 to 64 letters, digits, underscores or dashes", i, name))
+		}
+		if slices.Contains(names, name) {
+			return toolsError(fmt.Sprintf("tools[%d].function.name %q is defined more than once", i, name))
+		}
+		names = append(names, name)
+
+		switch parameters := function["parameters"].(type) {
+		case nil:
+			function["parameters"] = map[string]any{"type": "object", "properties": map[string]any{}}
+		case map[string]any:
+			a.normalizeSchema(parameters)
+		default:
+			return toolsError(fmt.Sprintf("tools[%d].function.parameters must be a JSON Schema object", i))
+		}
+	}
+	return validateToolChoice(requestData["tool_choice"], names)
+}
+
+// validateToolChoice checks that tool_choice is one of the OpenAI forms and
+// that a forced function is one of the tools.
+func validateToolChoice(choice any, names []string) error {
+	switch choice := choice.(type) {
+	case nil:
+		return nil
+	case string:
+		if choice == "none" || choice == "auto" || choice == "required" {
+			return nil
+		}
+		return toolsError(fmt.Sprintf("tool_choice %q must be none, auto or required", choice))
+	case map[string]any:
+		function, _ := choice["function"].(map[string]any)
+		name, _ := function["name"].(string)
+		if choice["type"] != "function" || name == "" {
+			return toolsError(`tool_choice must name a function as {"type": "function", "function": {"name": ...}}`)
+		}
+		if !slices.Contains(names, name) {
+			return toolsError(fmt.Sprintf("tool_choice names function %q, which is not in tools", name))
+		}
+		return nil
+	default:
+		return toolsError("tool_choice must be a string or an object")
+	}
+}
+
+func toolsError(message string) error {
+	return &TransformError{
+		Status:  http.StatusBadRequest,
+		Message: message,
+		Type:    "invalid_request_error",
+		Code:    "invalid_tools",
+	}
+}
+
+// normalizeSchema removes the keywords and formats the provider cannot
+// compile from a JSON Schema and the schemas nested in it.
+func (a *Adapter) normalizeSchema(schema map[string]any) {
+	for _, keyword := range a.Provider.SchemaUnsupported {
+		delete(schema, keyword)
+	}
+	if format, ok := schema["format"].(string); ok && len(a.Provider.SchemaFormats) > 0 && !slices.Contains(a.Provider.SchemaFormats, format) {
+		delete(schema, "format")
+	}
+
+	for key, value := range schema {
+		switch value := value.(type) {
+		case map[string]any:
+			if slices.Contains(schemaMaps, key) {
+				for _, nested := range value {
+					if nested, ok := nested.(map[string]any); ok {
+						a.normalizeSchema(nested)
+					}
+				}
+			} else if key != "const" && key != "default" && key != "examples" {
+				a.normalizeSchema(value)
+			}
+		case []any:
+			if key == "enum" || key == "examples" || key == "required" {
+				continue
+			}
+			for _, item := range value {
+				if nested, ok := item.(map[string]any); ok {
+					a.normalizeSchema(nested)
+				}
+			}
+		}
+	}
+}
+
+// legacyFunctionsToTools translates the functions and function_call fields
+// of a request, and the function calls and results in its messages, to
+// their tools equivalents. It reports whether the request used functions.
+func legacyFunctionsToTools(requestData map[string]any) bool {
+	functions, hasFunctions := requestData["functions"].([]any)
+	functionCall, hasFunctionCall := requestData["function_call"]
+	if !hasFunctions && !hasFunctionCall {
+		return false
+	}
+	delete(requestData, "functions")
+	delete(requestData, "function_call")
+
+	if _, ok := requestData["tools"]; !ok && len(functions) > 0 {
+		tools := make([]any, 0, len(functions))
+		for _, function := range functions {
+			tools = append(tools, map[string]any{"type": "function", "function": function})
+		}
+		requestData["tools"] = tools
+	}
+	if _, ok := requestData["tool_choice"]; !ok && hasFunctionCall {
+		switch choice := functionCall.(type) {
+		case map[string]any:
+			requestData["tool_choice"] = map[string]any{"type": "function", "function": map[string]any{"name": choice["name"]}}
+		default:
+			requestData["tool_choice"] = choice
+		}
+	}
+	// Legacy clients expect at most one function call per turn.
+	requestData["parallel_tool_calls"] = false
+
+	// Function results name the function they answer rather than a call
+	// ID, so each one is paired with the latest call of that function.
+	messages, _ := requestData["messages"].([]any)
+	callIDs := make(map[string]string)
+	for i, m := range messages {
+		message, ok := m.(map[string]any)
+		if !ok {
+			continue
+		}
+		switch message["role"] {
+		case "assistant":
+			call, ok := message["function_call"].(map[string]any)
+			if !ok {
+				continue
+			}
+			delete(message, "function_call")
+			if _, ok := message["tool_calls"]; ok {
+				continue
+			}
+			id := fmt.Sprintf("call_%d", i)
+			name, _ := call["name"].(string)
+			callIDs[name] = id
+			message["tool_calls"] = []any{map[string]any{"id": id, "type": "function", "function": call}}
+		case "function":
+			name, _ := message["name"].(string)
+			message["role"] = "tool"
+			message["tool_call_id"] = callIDs[name]
+		}
+	}
+	return true
+}
+
+// toolCallsToLegacyFunction translates the tool calls of a response message
+// or stream delta to a function_call, for clients that sent functions. It
+// reports whether the message was modified.
+func toolCallsToLegacyFunction(message map[string]any) bool {
+	toolCalls, ok := message["tool_calls"].([]any)
+	if !ok {
+		return false
+	}
+	delete(message, "tool_calls")
+	for _, c := range toolCalls {
+		call, ok := c.(map[string]any)
+		if !ok {
+			continue
+		}
+		// Stream deltas carry the index of the call they continue.
+		if index, ok := call["index"].(float64); ok && index != 0 {
+			continue
+		}
+		if function, ok := call["function"].(map[string]any); ok {
+			message["function_call"] = function
+		}
+		break
+	}
+	return true
+}
+
+// toLegacyFunctionCalls translates the tool calls and finish reasons of a
+// response or stream chunk for clients that sent functions. It reports
+// whether anything was modified.
+func toLegacyFunctionCalls(data map[string]any, field string) bool {
+	modified := false
+	choices, _ := data["choices"].([]any)
+	for _, c := range choices {
+		choice, ok := c.(map[string]any)
+		if !ok {
+			continue
+		}
+		if choice["finish_reason"] == "tool_calls" {
+			choice["finish_reason"] = "function_call"
+			modified = true
+		}
+		if message, ok := choice[field].(map[string]any); ok && toolCallsToLegacyFunction(message) {
+			modified = true
+		}
+	}
+	return modified
+}
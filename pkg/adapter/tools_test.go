@@ -0,0 +1,368 @@
+package adapter
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+	"github.com/aldehir/gpt-oss-adapter/providers/types"
+)
+
+func TestNormalizeTools_Invalid(t *testing.T) {
+	adapter := newTestAdapter()
+
+	tests := []struct {
+		name    string
+		request string
+		message string
+	}{
+		{"not an array", `{"tools":{}}`, "tools must be an array"},
+		{"wrong type", `{"tools":[{"type":"retrieval"}]}`, "tools[0] has unsupported type retrieval"},
+		{"no function", `{"tools":[{"type":"function"}]}`, "tools[0].function must be an object"},
+		{"bad name", `{"tools":[{"type":"function","function":{"name":"get weather"}}]}`, `tools[0].function.name "get weather"`},
+		{"duplicate", `{"tools":[{"function":{"name":"a"}},{"function":{"name":"a"}}]}`, `tools[1].function.name "a" is defined more than once`},
+		{"bad parameters", `{"tools":[{"function":{"name":"a","parameters":"object"}}]}`, "tools[0].function.parameters must be a JSON Schema object"},
+		{"bad choice", `{"tools":[{"function":{"name":"a"}}],"tool_choice":"any"}`, `tool_choice "any" must be none, auto or required`},
+		{"unknown choice", `{"tools":[{"function":{"name":"a"}}],"tool_choice":{"type":"function","function":{"name":"b"}}}`, `tool_choice names function "b"`},
+		{"choice without tools", `{"tool_choice":{"type":"function","function":{"name":"a"}}}`, `tool_choice names function "a"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var request map[string]any
+			require.NoError(t, json.Unmarshal([]byte(tt.request), &request))
+			err := adapter.normalizeTools(&chatRequest{}, request)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.message)
+			var transformErr *TransformError
+			require.ErrorAs(t, err, &transformErr)
+			assert.Equal(t, "invalid_tools", transformErr.Code)
+		})
+	}
+}
+
+func TestNormalizeTools_Schema(t *testing.T) {
+	adapter := newTestAdapter()
+	adapter.Provider.SchemaUnsupported = []string{"$schema"}
+
+	var request map[string]any
+	require.NoError(t, json.Unmarshal([]byte(`{"tools":[
+		{"function":{"name":"noop"}},
+		{"type":"function","function":{"name":"book","parameters":{
+			"$schema":"https://json-schema.org/draft/2020-12/schema",
+			"type":"object",
+			"properties":{
+				"email":{"type":"string","format":"email"},
+				"when":{"type":"string","format":"date-time"},
+				"format":{"type":"string","enum":["pdf","html"]},
+				"guests":{"type":"array","items":{"type":"string","format":"idn-email"}}
+			}
+		}}}
+	],"tool_choice":{"type":"function","function":{"name":"book"}}}`), &request))
+	require.NoError(t, adapter.normalizeTools(&chatRequest{}, request))
+
+	tools := request["tools"].([]any)
+	assert.Equal(t, map[string]any{
+		"type":     "function",
+		"function": map[string]any{"name": "noop", "parameters": map[string]any{"type": "object", "properties": map[string]any{}}},
+	}, tools[0])
+
+	parameters := tools[1].(map[string]any)["function"].(map[string]any)["parameters"].(map[string]any)
+	assert.NotContains(t, parameters, "$schema")
+	properties := parameters["properties"].(map[string]any)
+	assert.Equal(t, map[string]any{"type": "string"}, properties["email"])
+	assert.Equal(t, map[string]any{"type": "string", "format": "date-time"}, properties["when"])
+	assert.Equal(t, map[string]any{"type": "string", "enum": []any{"pdf", "html"}}, properties["format"])
+	assert.Equal(t, map[string]any{"type": "string"}, properties["guests"].(map[string]any)["items"])
+}
+
+func TestLegacyFunctions(t *testing.T) {
+	var sent map[string]any
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = nil
+		json.NewDecoder(r.Body).Decode(&sent)
+		if stream, _ := sent["stream"].(bool); stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, `data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_x","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}`+"\n\n")
+			io.WriteString(w, `data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{}"}}]}}]}`+"\n\n")
+			io.WriteString(w, `data: {"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`+"\n\ndata: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[{"index":0,"finish_reason":"tool_calls","message":{"role":"assistant","tool_calls":[{"id":"call_x","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]}}]}`)
+	}))
+	defer backend.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter(backend.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+
+	request := `{"model":"gpt-oss-20b",
+		"functions":[{"name":"get_weather","parameters":{"type":"object","properties":{"city":{"type":"string"}}}}],
+		"function_call":{"name":"get_weather"},
+		"messages":[
+			{"role":"user","content":"Weather in Paris and Rome?"},
+			{"role":"assistant","content":null,"function_call":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}},
+			{"role":"function","name":"get_weather","content":"Sunny"}
+		]}`
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(request)))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	assert.NotContains(t, sent, "functions")
+	assert.NotContains(t, sent, "function_call")
+	assert.Equal(t, []any{map[string]any{"type": "function", "function": map[string]any{
+		"name":       "get_weather",
+		"parameters": map[string]any{"type": "object", "properties": map[string]any{"city": map[string]any{"type": "string"}}},
+	}}}, sent["tools"])
+	assert.Equal(t, map[string]any{"type": "function", "function": map[string]any{"name": "get_weather"}}, sent["tool_choice"])
+	assert.Equal(t, false, sent["parallel_tool_calls"])
+	messages := sent["messages"].([]any)
+	assert.Equal(t, []any{map[string]any{"id": "call_1", "type": "function", "function": map[string]any{"name": "get_weather", "arguments": `{"city":"Paris"}`}}}, messages[1].(map[string]any)["tool_calls"])
+	assert.Equal(t, map[string]any{"role": "tool", "tool_call_id": "call_1", "name": "get_weather", "content": "Sunny"}, messages[2])
+
+	var response map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	choice := response["choices"].([]any)[0].(map[string]any)
+	assert.Equal(t, "function_call", choice["finish_reason"])
+	assert.Equal(t, map[string]any{"role": "assistant", "function_call": map[string]any{"name": "get_weather", "arguments": `{"city":"Paris"}`}}, choice["message"])
+
+	w = httptest.NewRecorder()
+	streamRequest := strings.Replace(request, `"model"`, `"stream":true,"model"`, 1)
+	adapter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(streamRequest)))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"delta":{"function_call":{"arguments":"","name":"get_weather"}}`)
+	assert.Contains(t, w.Body.String(), `"delta":{"function_call":{"arguments":"{}"}}`)
+	assert.Contains(t, w.Body.String(), `"finish_reason":"function_call"`)
+	assert.NotContains(t, w.Body.String(), "tool_calls")
+}
+
+func TestToolChoiceGrammar(t *testing.T) {
+	var sent map[string]any
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = nil
+		json.NewDecoder(r.Body).Decode(&sent)
+		if stream, _ := sent["stream"].(bool); stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, `data: {"choices":[{"index":0,"delta":{"reasoning_content":"Look it up."}}]}`+"\n\n")
+			io.WriteString(w, `data: {"choices":[{"index":0,"delta":{"content":"{\"name\":\"get_weather\","}}]}`+"\n\n")
+			io.WriteString(w, `data: {"choices":[{"index":0,"delta":{"content":"\"arguments\":{\"city\":\"Paris\"}}"}}]}`+"\n\n")
+			io.WriteString(w, `data: {"choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`+"\n\ndata: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","reasoning_content":"Look it up.","content":"{\"name\":\"get_weather\",\"arguments\":{\"city\":\"Paris\"}}"}}]}`)
+	}))
+	defer backend.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	provider := llamacpp.NewProvider()
+	provider.ToolChoice = types.ToolChoiceGrammar
+	adapter := NewAdapter(backend.URL, NewLRUCache(10), logger, provider)
+
+	request := `{"model":"gpt-oss-20b","tool_choice":"required","messages":[{"role":"user","content":"Weather in Paris?"}],"tools":[
+		{"type":"function","function":{"name":"get_weather","description":"Current weather","parameters":{"type":"object","properties":{"city":{"type":"string"}}}}},
+		{"type":"function","function":{"name":"get_time"}}
+	]}`
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(request)))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	assert.NotContains(t, sent, "tools")
+	assert.NotContains(t, sent, "tool_choice")
+	// llama.cpp takes the schema in its own field.
+	assert.NotContains(t, sent, "response_format")
+	schema := sent["json_schema"].(map[string]any)
+	require.Len(t, schema["anyOf"], 2)
+	assert.Equal(t, map[string]any{
+		"type":        "object",
+		"description": "Current weather",
+		"properties": map[string]any{
+			"name":      map[string]any{"const": "get_weather"},
+			"arguments": map[string]any{"type": "object", "properties": map[string]any{"city": map[string]any{"type": "string"}}},
+		},
+		"required": []any{"name", "arguments"},
+	}, schema["anyOf"].([]any)[0])
+
+	var response map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	choice := response["choices"].([]any)[0].(map[string]any)
+	assert.Equal(t, "tool_calls", choice["finish_reason"])
+	message := choice["message"].(map[string]any)
+	assert.Nil(t, message["content"])
+	call := message["tool_calls"].([]any)[0].(map[string]any)
+	assert.Equal(t, map[string]any{"name": "get_weather", "arguments": `{"city":"Paris"}`}, call["function"])
+
+	// The reasoning is cached under the ID of the tool call.
+	item, ok := adapter.cache.Get("", call["id"].(string))
+	require.True(t, ok)
+	assert.Equal(t, "Look it up.", item.Content)
+
+	w = httptest.NewRecorder()
+	streamRequest := strings.Replace(request, `"model"`, `"stream":true,"model"`, 1)
+	adapter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(streamRequest)))
+	require.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.NotContains(t, body, `"content"`)
+	assert.Contains(t, body, `"reasoning":"Look it up."`)
+	assert.Contains(t, body, `"finish_reason":"tool_calls"`)
+	assert.Contains(t, body, `"function":{"arguments":"{\"city\":\"Paris\"}","name":"get_weather"}`)
+
+	// Requests that do not require a tool call keep their tools.
+	request = strings.Replace(request, `"required"`, `"auto"`, 1)
+	adapter.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(request)))
+	assert.Contains(t, sent, "tools")
+	assert.NotContains(t, sent, "json_schema")
+}
+
+func TestToolChoiceRetries(t *testing.T) {
+	var calls atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var sent map[string]any
+		json.NewDecoder(r.Body).Decode(&sent)
+		assert.Equal(t, "required", sent["tool_choice"])
+		w.Header().Set("Content-Type", "application/json")
+		if calls.Add(1) < 3 {
+			io.WriteString(w, `{"choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"It is sunny."}}]}`)
+			return
+		}
+		io.WriteString(w, `{"choices":[{"index":0,"finish_reason":"tool_calls","message":{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{}"}}]}}]}`)
+	}))
+	defer backend.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter(backend.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+	adapter.ToolChoiceRetries = 2
+
+	request := `{"model":"gpt-oss-20b","tool_choice":"required","messages":[{"role":"user","content":"Weather?"}],"tools":[{"type":"function","function":{"name":"get_weather"}}]}`
+	send := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		adapter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(request)))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		return w
+	}
+
+	w := send()
+	assert.Equal(t, int32(3), calls.Load())
+	assert.Contains(t, w.Body.String(), `"tool_calls"`)
+
+	// Once the retries run out, the last answer is returned.
+	calls.Store(-10)
+	w = send()
+	assert.Equal(t, int32(-7), calls.Load())
+	assert.Contains(t, w.Body.String(), "It is sunny.")
+
+	metrics := httptest.NewRecorder()
+	adapter.ServeHTTP(metrics, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, metrics.Body.String(), "gpt_oss_adapter_tool_choice_retries_total 4")
+}
+
+func TestToolCallRetries(t *testing.T) {
+	responses := []string{
+		`{"choices":[{"index":0,"finish_reason":"length","message":{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":"}}]}}]}`,
+		`{"choices":[{"index":0,"finish_reason":"tool_calls","message":{"role":"assistant","tool_calls":[{"id":"call_2","type":"function","function":{"name":"get_weather","arguments":"{city: Paris}"}}]}}]}`,
+		`{"choices":[{"index":0,"finish_reason":"tool_calls","message":{"role":"assistant","tool_calls":[{"id":"call_3","type":"function","function":{"name":"get_weather","arguments":"{\"town\":\"Paris\"}"}}]}}]}`,
+		`{"choices":[{"index":0,"finish_reason":"tool_calls","message":{"role":"assistant","tool_calls":[{"id":"call_4","type":"function","function":{"name":"get_forecast","arguments":"{}"}}]}}]}`,
+		`{"choices":[{"index":0,"finish_reason":"tool_calls","message":{"role":"assistant","tool_calls":[{"id":"call_5","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]}}]}`,
+	}
+	var sent []map[string]any
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]any
+		json.NewDecoder(r.Body).Decode(&request)
+		sent = append(sent, request)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, responses[(len(sent)-1)%len(responses)])
+	}))
+	defer backend.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter(backend.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+	adapter.ToolCallRetries = 4
+
+	request := `{"model":"gpt-oss-20b","messages":[{"role":"user","content":"Weather?"}],"tools":[{"type":"function","function":{"name":"get_weather","parameters":{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}}}]}`
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(request)))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"id":"call_5"`)
+	require.Len(t, sent, 5)
+
+	// Each retry starts from the original messages and explains what was
+	// wrong with the last attempt.
+	assert.Len(t, sent[0]["messages"], 1)
+	corrections := []string{
+		"the response was cut off at the token limit",
+		"the arguments are not valid JSON",
+		`the arguments do not match the parameters: $: missing required property "city"`,
+		"the function is not one of [get_weather]",
+	}
+	for i, correction := range corrections {
+		messages := sent[i+1]["messages"].([]any)
+		require.Len(t, messages, 2)
+		message := messages[1].(map[string]any)
+		assert.Equal(t, "system", message["role"])
+		assert.Contains(t, message["content"], correction)
+	}
+
+	metrics := httptest.NewRecorder()
+	adapter.ServeHTTP(metrics, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, reason := range []string{"invalid_json", "schema_mismatch", "truncated", "unknown_function"} {
+		assert.Contains(t, metrics.Body.String(), `gpt_oss_adapter_tool_call_retries_total{reason="`+reason+`"} 1`)
+	}
+
+	// Once the retries run out, the request fails.
+	adapter.ToolCallRetries = 1
+	sent = nil
+	w = httptest.NewRecorder()
+	adapter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(request)))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"invalid_tool_call"`)
+	assert.Contains(t, w.Body.String(), "Model made an invalid tool call of get_weather: the arguments are not valid JSON")
+	assert.Len(t, sent, 2)
+}
+
+func TestToolChoiceRetries_Accounting(t *testing.T) {
+	var moderated atomic.Int32
+	moderator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		moderated.Add(1)
+		io.WriteString(w, `{"results":[{"flagged":false}]}`)
+	}))
+	defer moderator.Close()
+
+	var calls atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if calls.Add(1) < 3 {
+			io.WriteString(w, `{"choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"It is sunny."}}]}`)
+			return
+		}
+		io.WriteString(w, `{"choices":[{"index":0,"finish_reason":"tool_calls","message":{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{}"}}]}}]}`)
+	}))
+	defer backend.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter(backend.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+	adapter.ToolChoiceRetries = 2
+	adapter.Ledger = NewUsageLedger()
+	adapter.Moderator = NewModerator(moderator.URL, ModerationModeBlock, false, false, time.Second)
+
+	request := `{"model":"gpt-oss-20b","tool_choice":"required","messages":[{"role":"user","content":"Weather?"}],"tools":[{"type":"function","function":{"name":"get_weather"}}]}`
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(request)))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, int32(3), calls.Load())
+
+	// The retries are part of one request, so it is moderated and counted
+	// once.
+	assert.Equal(t, int32(1), moderated.Load())
+	totals := adapter.Ledger.Totals("")
+	require.Len(t, totals, 1)
+	assert.Equal(t, int64(1), totals[0].Requests)
+}
@@ -0,0 +1,151 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName         = "github.com/aldehir/gpt-oss-adapter"
+	defaultServiceName = "gpt-oss-adapter"
+)
+
+// tracingEnabled reports whether the OTEL_* environment asks for traces to be
+// exported. Tracing is off unless an OTLP endpoint or OTEL_TRACES_EXPORTER is
+// set, so that an unconfigured adapter does not try to reach a collector.
+func tracingEnabled() (bool, error) {
+	if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+		return false, nil
+	}
+
+	switch exporter := strings.TrimSpace(os.Getenv("OTEL_TRACES_EXPORTER")); exporter {
+	case "none":
+		return false, nil
+	case "otlp":
+	case "":
+		if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+			return false, nil
+		}
+	default:
+		return false, fmt.Errorf("unsupported OTEL_TRACES_EXPORTER %q (supported: otlp, none)", exporter)
+	}
+
+	protocol := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL")
+	if protocol == "" {
+		protocol = os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+	}
+	if protocol != "" && protocol != "http/protobuf" {
+		return false, fmt.Errorf("unsupported OTLP protocol %q (supported: http/protobuf)", protocol)
+	}
+	return true, nil
+}
+
+// SetupTracing installs an OTLP/HTTP trace exporter configured from the
+// standard OTEL_* environment variables, and the W3C trace context and
+// baggage propagators. The returned function flushes and stops the exporter.
+// It returns a nil function when tracing is not enabled.
+func SetupTracing(ctx context.Context) (func(context.Context) error, error) {
+	enabled, err := tracingEnabled()
+	if err != nil || !enabled {
+		return nil, err
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("creating OTLP trace exporter: %w", err)
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the default
+	// service name.
+	res, err := resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(semconv.ServiceName(defaultServiceName)),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider.Shutdown, nil
+}
+
+// tracer returns the adapter's tracer from the global provider, which is a
+// no-op unless SetupTracing installed one.
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// endSpan records err on span, if any, and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// injectTraceContext adds the traceparent of the span in ctx to an upstream
+// request, replacing the one copied from the client.
+func injectTraceContext(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// TracingMiddleware starts a server span for every request, continuing the
+// trace of an incoming traceparent header.
+type TracingMiddleware struct {
+	handler http.Handler
+}
+
+func NewTracingMiddleware(handler http.Handler) *TracingMiddleware {
+	return &TracingMiddleware{handler: handler}
+}
+
+func (m *TracingMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := tracer().Start(ctx, r.Method+" "+r.URL.Path,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(r.Method),
+			semconv.URLPath(r.URL.Path),
+			semconv.ClientAddress(getClientIP(r)),
+		),
+	)
+	defer span.End()
+
+	rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+	m.handler.ServeHTTP(rw, r.WithContext(ctx))
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(rw.statusCode))
+	if rw.statusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(rw.statusCode))
+	}
+}
+
+// upstreamSpanAttributes describes an upstream request on its client span.
+func upstreamSpanAttributes(req *http.Request) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		semconv.HTTPRequestMethodKey.String(req.Method),
+		semconv.URLFull(req.URL.Redacted()),
+		semconv.ServerAddress(req.URL.Hostname()),
+	}
+}
@@ -0,0 +1,206 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// Transformer changes chat completions on their way through the adapter:
+// requests before they are sent to the target, and responses and stream
+// chunks before they are sent to the client. Requests are in the chat
+// completions format, before reasoning is restored from the cache and
+// before they are translated for the provider's API.
+type Transformer interface {
+	// TransformRequest changes a request. An error rejects it, with the
+	// status of a *TransformError or 400.
+	TransformRequest(r *http.Request, request map[string]any) error
+
+	// TransformResponse changes a blocking response.
+	TransformResponse(ctx context.Context, response map[string]any)
+
+	// TransformChunk changes a chunk of a streamed response and reports
+	// whether it did.
+	TransformChunk(ctx context.Context, chunk map[string]any) bool
+}
+
+// TransformError rejects a request with an OpenAI-style error.
+type TransformError struct {
+	Status  int
+	Message string
+	Type    string
+	Code    string
+}
+
+func (e *TransformError) Error() string {
+	return e.Message
+}
+
+// transformers returns the transformers a request goes through: the built-in
+// ones for reasoning effort and reasoning fields, then Transformers.
+func (a *Adapter) transformers() []Transformer {
+	return append([]Transformer{effortTransformer{a}, reasoningTransformer{a}}, a.Transformers...)
+}
+
+// transformRequest runs the transformers over a request. It returns false if
+// one rejected the request and the error was written to w.
+func (a *Adapter) transformRequest(w http.ResponseWriter, r *http.Request, requestData map[string]any) bool {
+	for _, t := range a.transformers() {
+		if err := t.TransformRequest(r, requestData); err != nil {
+			a.writeTransformError(w, r, err)
+			return false
+		}
+	}
+	return true
+}
+
+func (a *Adapter) writeTransformError(w http.ResponseWriter, r *http.Request, err error) {
+	var transformErr *TransformError
+	if !errors.As(err, &transformErr) {
+		transformErr = &TransformError{Status: http.StatusBadRequest, Message: err.Error(), Type: "invalid_request_error"}
+	}
+	a.logger.InfoContext(r.Context(), "rejected request", "error", err)
+	writeError(w, r, transformErr.Status, transformErr.Message, transformErr.Type, transformErr.Code)
+}
+
+func (a *Adapter) transformResponse(ctx context.Context, responseData map[string]any) {
+	for _, t := range a.transformers() {
+		t.TransformResponse(ctx, responseData)
+	}
+}
+
+func (a *Adapter) transformChunk(ctx context.Context, eventData map[string]any) bool {
+	modified := false
+	for _, t := range a.transformers() {
+		if t.TransformChunk(ctx, eventData) {
+			modified = true
+		}
+	}
+	return modified
+}
+
+// effortTransformer chooses the reasoning effort of a request and moves it
+// to the provider's field.
+type effortTransformer struct {
+	a *Adapter
+}
+
+func (t effortTransformer) TransformRequest(r *http.Request, request map[string]any) error {
+	if err := t.a.chooseEffort(r, request); err != nil {
+		return err
+	}
+	t.a.injectReasoningEffort(request)
+	return nil
+}
+
+func (effortTransformer) TransformResponse(context.Context, map[string]any) {}
+
+func (effortTransformer) TransformChunk(context.Context, map[string]any) bool {
+	return false
+}
+
+// reasoningTransformer moves the reasoning clients send to the provider's
+// field, and the reasoning in responses to the reasoning field.
+type reasoningTransformer struct {
+	a *Adapter
+}
+
+func (t reasoningTransformer) TransformRequest(_ *http.Request, request map[string]any) error {
+	t.a.normalizeReasoningFields(request)
+	return nil
+}
+
+func (t reasoningTransformer) TransformResponse(_ context.Context, response map[string]any) {
+	t.a.transformReasoningContentToReasoning(response)
+}
+
+func (t reasoningTransformer) TransformChunk(_ context.Context, chunk map[string]any) bool {
+	return t.a.transformStreamingEvent(chunk)
+}
+
+// builtinTransformers are the optional transformers that can be selected by
+// name.
+var builtinTransformers = map[string]func() Transformer{
+	// reasoning-content also sends reasoning as reasoning_content, for
+	// clients that only read that field.
+	"reasoning-content": func() Transformer { return reasoningContentTransformer{} },
+	// max-tokens renames max_completion_tokens to max_tokens, for backends
+	// that only understand the older field.
+	"max-tokens": func() Transformer { return maxTokensTransformer{} },
+}
+
+// NewTransformer returns the optional built-in transformer with the given
+// name.
+func NewTransformer(name string) (Transformer, error) {
+	newTransformer, ok := builtinTransformers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown transformer %q, expected one of %v", name, TransformerNames())
+	}
+	return newTransformer(), nil
+}
+
+// TransformerNames returns the names of the optional built-in transformers.
+func TransformerNames() []string {
+	names := make([]string, 0, len(builtinTransformers))
+	for name := range builtinTransformers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type reasoningContentTransformer struct{}
+
+func (reasoningContentTransformer) TransformRequest(*http.Request, map[string]any) error {
+	return nil
+}
+
+func (reasoningContentTransformer) TransformResponse(_ context.Context, response map[string]any) {
+	forEachChoice(response, "message", func(_ int, message map[string]any) {
+		copyReasoningContent(message)
+	})
+}
+
+func (reasoningContentTransformer) TransformChunk(_ context.Context, chunk map[string]any) bool {
+	modified := false
+	forEachChoice(chunk, "delta", func(_ int, delta map[string]any) {
+		if copyReasoningContent(delta) {
+			modified = true
+		}
+	})
+	return modified
+}
+
+func copyReasoningContent(message map[string]any) bool {
+	reasoning, ok := message["reasoning"].(string)
+	if !ok {
+		return false
+	}
+	if _, ok := message["reasoning_content"]; ok {
+		return false
+	}
+	message["reasoning_content"] = reasoning
+	return true
+}
+
+type maxTokensTransformer struct{}
+
+func (maxTokensTransformer) TransformRequest(_ *http.Request, request map[string]any) error {
+	maxTokens, ok := request["max_completion_tokens"]
+	if !ok {
+		return nil
+	}
+	delete(request, "max_completion_tokens")
+	if _, ok := request["max_tokens"]; !ok {
+		request["max_tokens"] = maxTokens
+	}
+	return nil
+}
+
+func (maxTokensTransformer) TransformResponse(context.Context, map[string]any) {}
+
+func (maxTokensTransformer) TransformChunk(context.Context, map[string]any) bool {
+	return false
+}
@@ -0,0 +1,119 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+)
+
+// renameTransformer renames a request field and tags responses and chunks.
+type renameTransformer struct{}
+
+func (renameTransformer) TransformRequest(r *http.Request, request map[string]any) error {
+	if _, ok := request["forbidden"]; ok {
+		return errors.New("forbidden field")
+	}
+	if seed, ok := request["random_seed"]; ok {
+		delete(request, "random_seed")
+		request["seed"] = seed
+	}
+	return nil
+}
+
+func (renameTransformer) TransformResponse(_ context.Context, response map[string]any) {
+	response["transformed"] = true
+}
+
+func (renameTransformer) TransformChunk(_ context.Context, chunk map[string]any) bool {
+	chunk["transformed"] = true
+	return true
+}
+
+func TestTransformers(t *testing.T) {
+	var sent map[string]any
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&sent)
+		if stream, _ := sent["stream"].(bool); stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"reasoning_content\":\"Think.\"}}]}\n\ndata: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[{"index":0,"message":{"role":"assistant","reasoning_content":"Think.","content":"Hi"}}]}`)
+	}))
+	defer backend.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter(backend.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+	reasoningContent, err := NewTransformer("reasoning-content")
+	require.NoError(t, err)
+	adapter.Transformers = []Transformer{renameTransformer{}, reasoningContent}
+
+	send := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		adapter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+		return w
+	}
+
+	w := send(`{"model":"gpt-oss-20b","random_seed":7,"messages":[{"role":"user","content":"Hello"}]}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, float64(7), sent["seed"])
+	assert.NotContains(t, sent, "random_seed")
+
+	var response map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, true, response["transformed"])
+	message := response["choices"].([]any)[0].(map[string]any)["message"].(map[string]any)
+	assert.Equal(t, "Think.", message["reasoning"])
+	assert.Equal(t, "Think.", message["reasoning_content"])
+
+	w = send(`{"model":"gpt-oss-20b","stream":true,"messages":[{"role":"user","content":"Hello"}]}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"transformed":true`)
+	assert.Contains(t, w.Body.String(), `"reasoning":"Think.","reasoning_content":"Think."`)
+
+	w = send(`{"model":"gpt-oss-20b","forbidden":true,"messages":[{"role":"user","content":"Hello"}]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "forbidden field")
+}
+
+func TestTransformers_Error(t *testing.T) {
+	adapter := newTestAdapter()
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"gpt-oss-20b","messages":[]}`))
+	r.Header.Set(reasoningEffortHeader, "extreme")
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"invalid_reasoning_effort"`)
+}
+
+func TestMaxTokensTransformer(t *testing.T) {
+	transformer, err := NewTransformer("max-tokens")
+	require.NoError(t, err)
+
+	request := map[string]any{"max_completion_tokens": float64(100)}
+	require.NoError(t, transformer.TransformRequest(nil, request))
+	assert.Equal(t, map[string]any{"max_tokens": float64(100)}, request)
+
+	request = map[string]any{"max_completion_tokens": float64(100), "max_tokens": float64(50)}
+	require.NoError(t, transformer.TransformRequest(nil, request))
+	assert.Equal(t, map[string]any{"max_tokens": float64(50)}, request)
+}
+
+func TestNewTransformer_Unknown(t *testing.T) {
+	_, err := NewTransformer("nope")
+	assert.ErrorContains(t, err, `unknown transformer "nope"`)
+	assert.Equal(t, []string{"max-tokens", "reasoning-content"}, TransformerNames())
+}
@@ -0,0 +1,197 @@
+package adapter
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// DefaultUpstreamMaxIdleConns is the number of idle connections to the
+// target kept open for reuse. The net/http default of two makes bursts of
+// requests dial the target again and again, exhausting ephemeral ports.
+const DefaultUpstreamMaxIdleConns = 100
+
+// DefaultUpstreamIdleTimeout is how long an idle connection to the target is
+// kept open.
+const DefaultUpstreamIdleTimeout = 90 * time.Second
+
+// HTTP/2 modes for requests to the target.
+const (
+	// UpstreamHTTP2Auto uses HTTP/2 when an HTTPS target offers it.
+	UpstreamHTTP2Auto = "auto"
+	// UpstreamHTTP2Off always uses HTTP/1.1.
+	UpstreamHTTP2Off = "off"
+	// UpstreamHTTP2Always uses HTTP/2 for every target, unencrypted
+	// (h2c with prior knowledge) for plain HTTP ones.
+	UpstreamHTTP2Always = "always"
+)
+
+// newUpstreamTransport returns the default transport tuned for sending many
+// requests to a single target.
+func newUpstreamTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = DefaultUpstreamMaxIdleConns
+	transport.MaxIdleConnsPerHost = DefaultUpstreamMaxIdleConns
+	transport.IdleConnTimeout = DefaultUpstreamIdleTimeout
+	return transport
+}
+
+// upstreamTransport returns the transport of requests to the target, which
+// the health checker shares. If the client's transport was replaced by one
+// of another type, it is replaced again with a tuned one. The SetUpstream
+// methods configure it, and must be called before the adapter serves
+// requests.
+func (a *Adapter) upstreamTransport() *http.Transport {
+	transport, ok := a.client.Transport.(*http.Transport)
+	if !ok {
+		transport = newUpstreamTransport()
+		a.client.Transport = transport
+	}
+	if a.Health != nil {
+		a.Health.client.Transport = transport
+	}
+	return transport
+}
+
+// upstreamDialer opens connections to the target, optionally through a unix
+// socket regardless of the target's host. A zero timeout uses the 30 seconds
+// of the net/http default transport.
+type upstreamDialer struct {
+	timeout time.Duration
+	socket  string
+}
+
+func (d upstreamDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: cmp.Or(d.timeout, 30*time.Second), KeepAlive: 30 * time.Second}
+	if d.socket != "" {
+		return dialer.DialContext(ctx, "unix", d.socket)
+	}
+	return dialer.DialContext(ctx, network, addr)
+}
+
+func (a *Adapter) setUpstreamDialer() {
+	a.upstreamTransport().DialContext = a.dialer.DialContext
+}
+
+// ownUpstream gives a route to another target than the adapter it was made
+// from a client of its own. The client keeps the timeouts, pool and HTTP/2
+// settings, but not the unix socket or TLS configuration, which belong to
+// the adapter's target.
+func (a *Adapter) ownUpstream() {
+	a.dialer.socket = ""
+	transport := a.upstreamTransport().Clone()
+	transport.TLSClientConfig = nil
+	transport.DialContext = a.dialer.DialContext
+	client := *a.client
+	client.Transport = transport
+	a.client = &client
+}
+
+// upstreams returns a and the routes, replicas and fallback with a client
+// of their own, for the settings that apply to every target.
+func (a *Adapter) upstreams() []*Adapter {
+	adapters := []*Adapter{a}
+	for _, route := range append(a.routeAdapters(), a.fallback) {
+		if route == nil {
+			continue
+		}
+		if !slices.ContainsFunc(adapters, func(other *Adapter) bool { return other.client == route.client }) {
+			adapters = append(adapters, route)
+		}
+	}
+	return adapters
+}
+
+// SetUpstreamTimeouts limits how long connecting to the target and waiting
+// for its response headers may take. Zero leaves a limit unset. Neither
+// limit applies once the response starts, so long streams are unaffected.
+// The limits apply to the routes, replicas and fallback too.
+func (a *Adapter) SetUpstreamTimeouts(connect, responseHeader time.Duration) {
+	for _, upstream := range a.upstreams() {
+		upstream.dialer.timeout = connect
+		upstream.setUpstreamDialer()
+		upstream.upstreamTransport().ResponseHeaderTimeout = responseHeader
+	}
+}
+
+// SetUpstreamSocket connects to the target through the unix socket at path,
+// such as that of a local llama.cpp server. The target URL still sets the
+// scheme, host header and path of requests. Routes, replicas and a fallback
+// with another target still connect to their own hosts.
+func (a *Adapter) SetUpstreamSocket(path string) {
+	a.dialer.socket = path
+	a.setUpstreamDialer()
+}
+
+// SetUpstreamPool sets how many idle connections to the target, and to
+// each route, replica and fallback target, are kept open, and for how long.
+// Zero keeps the current setting.
+func (a *Adapter) SetUpstreamPool(maxIdleConns int, idleTimeout time.Duration) {
+	for _, upstream := range a.upstreams() {
+		transport := upstream.upstreamTransport()
+		if maxIdleConns > 0 {
+			transport.MaxIdleConns = maxIdleConns
+			transport.MaxIdleConnsPerHost = maxIdleConns
+		}
+		if idleTimeout > 0 {
+			transport.IdleConnTimeout = idleTimeout
+		}
+	}
+}
+
+// SetUpstreamHTTP2 selects when HTTP/2 is used with the target, and with
+// the route, replica and fallback targets, one of the UpstreamHTTP2 modes.
+func (a *Adapter) SetUpstreamHTTP2(mode string) {
+	for _, upstream := range a.upstreams() {
+		transport := upstream.upstreamTransport()
+		switch mode {
+		case UpstreamHTTP2Off:
+			transport.Protocols = new(http.Protocols)
+			transport.Protocols.SetHTTP1(true)
+		case UpstreamHTTP2Always:
+			transport.Protocols = new(http.Protocols)
+			transport.Protocols.SetHTTP2(true)
+			transport.Protocols.SetUnencryptedHTTP2(true)
+		default:
+			transport.Protocols = nil
+		}
+	}
+}
+
+// upstreamContext returns the context for a chat request to the target,
+// bounded by UpstreamTimeout unless the request is streamed. cancel must be
+// called once the response body has been read.
+func (a *Adapter) upstreamContext(r *http.Request, chat *chatRequest) (ctx context.Context, cancel context.CancelFunc) {
+	if stream, _ := chat.data["stream"].(bool); stream || a.UpstreamTimeout <= 0 {
+		return r.Context(), func() {}
+	}
+	return context.WithTimeout(r.Context(), a.UpstreamTimeout)
+}
+
+// cancelOnClose cancels the context of an upstream request when its response
+// body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// isTimeout reports whether err is an upstream connect, response header or
+// overall timeout.
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,209 @@
+package adapter
+
+import (
+	"crypto/tls"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+	"github.com/aldehir/gpt-oss-adapter/providers/openrouter"
+)
+
+// newSlowBackend returns an adapter for a backend that waits delay before
+// responding, or streams with delay between events for streamed requests.
+func newSlowBackend(t *testing.T, delay time.Duration) *Adapter {
+	wait := func(r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(delay):
+		}
+	}
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), `"stream":true`) {
+			wait(r)
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"choices":[]}`)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for range 3 {
+			io.WriteString(w, `data: {"choices":[{"index":0,"delta":{"content":"token"}}]}`+"\n\n")
+			w.(http.Flusher).Flush()
+			wait(r)
+		}
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(backend.Close)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewAdapter(backend.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+}
+
+func serveChat(adapter *Adapter, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+	return w
+}
+
+func TestUpstreamTimeout(t *testing.T) {
+	adapter := newSlowBackend(t, 100*time.Millisecond)
+	adapter.UpstreamTimeout = 20 * time.Millisecond
+
+	w := serveChat(adapter, `{"messages":[]}`)
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"upstream_timeout"`)
+
+	// Streams may run longer than the timeout.
+	w = serveChat(adapter, `{"messages":[],"stream":true}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 3, strings.Count(w.Body.String(), "token"))
+	assert.Contains(t, w.Body.String(), "data: [DONE]")
+}
+
+func TestUpstreamResponseHeaderTimeout(t *testing.T) {
+	adapter := newSlowBackend(t, 100*time.Millisecond)
+	adapter.SetUpstreamTimeouts(time.Second, 20*time.Millisecond)
+
+	w := serveChat(adapter, `{"messages":[]}`)
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"upstream_timeout"`)
+
+	w = serveChat(adapter, `{"messages":[],"stream":true}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 3, strings.Count(w.Body.String(), "token"))
+}
+
+func TestUpstreamTransport_Shared(t *testing.T) {
+	adapter := newTestAdapter()
+	adapter.Health = NewHealthChecker(adapter.Target, "", nil, time.Second, time.Second)
+
+	adapter.SetUpstreamTimeouts(0, time.Minute)
+	adapter.SetUpstreamTLS(&tls.Config{ServerName: "backend"})
+
+	transport, ok := adapter.client.Transport.(*http.Transport)
+	require.True(t, ok)
+	assert.Equal(t, time.Minute, transport.ResponseHeaderTimeout)
+	assert.Equal(t, "backend", transport.TLSClientConfig.ServerName)
+	assert.Same(t, transport, adapter.Health.client.Transport)
+	assert.NotSame(t, http.DefaultTransport, transport)
+}
+
+func TestUpstreamSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "backend.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+
+	backend := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"path":"`+r.URL.Path+`"}`)
+	})}
+	go backend.Serve(listener)
+	t.Cleanup(func() { backend.Close() })
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter("http://localhost/base", NewLRUCache(10), logger, llamacpp.NewProvider())
+	adapter.SetUpstreamSocket(socket)
+
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"path":"/base/v1/models"}`, w.Body.String())
+}
+
+func TestUpstreamSocket_OtherTargets(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "backend.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	local := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "socket")
+	})}
+	go local.Serve(listener)
+	t.Cleanup(func() { local.Close() })
+
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "remote")
+	}))
+	t.Cleanup(remote.Close)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter("http://localhost", NewLRUCache(10), logger, llamacpp.NewProvider())
+	provider := openrouter.NewProvider()
+	provider.Target = remote.URL
+	adapter.AddRoute(provider)
+	adapter.SetFallback(remote.URL, llamacpp.NewProvider())
+
+	// The settings are applied after the routes, as the command does.
+	adapter.SetUpstreamSocket(socket)
+	adapter.SetUpstreamTLS(&tls.Config{ServerName: "local"})
+	adapter.SetUpstreamTimeouts(0, time.Minute)
+
+	get := func(provider string) string {
+		r := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+		if provider != "" {
+			r.Header.Set(providerHeader, provider)
+		}
+		w := httptest.NewRecorder()
+		adapter.ServeHTTP(w, r)
+		return w.Body.String()
+	}
+	assert.Equal(t, "socket", get(""))
+	assert.Equal(t, "remote", get("openrouter"))
+
+	for _, route := range []*Adapter{adapter.routes["openrouter"], adapter.fallback} {
+		transport := route.client.Transport.(*http.Transport)
+		if transport.TLSClientConfig != nil {
+			assert.Empty(t, transport.TLSClientConfig.ServerName)
+		}
+		assert.Equal(t, time.Minute, transport.ResponseHeaderTimeout)
+	}
+}
+
+func TestUpstreamHTTP2(t *testing.T) {
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	}))
+	backend.Config.Protocols = new(http.Protocols)
+	backend.Config.Protocols.SetHTTP1(true)
+	backend.Config.Protocols.SetUnencryptedHTTP2(true)
+	backend.Start()
+	t.Cleanup(backend.Close)
+
+	proto := func(mode string) string {
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+		adapter := NewAdapter(backend.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+		adapter.SetUpstreamHTTP2(mode)
+
+		w := httptest.NewRecorder()
+		adapter.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+		return w.Body.String()
+	}
+
+	assert.Equal(t, "HTTP/1.1", proto(UpstreamHTTP2Auto))
+	assert.Equal(t, "HTTP/2.0", proto(UpstreamHTTP2Always))
+	assert.Equal(t, "HTTP/1.1", proto(UpstreamHTTP2Off))
+}
+
+func TestUpstreamPool(t *testing.T) {
+	adapter := newTestAdapter()
+
+	transport := adapter.upstreamTransport()
+	assert.Equal(t, DefaultUpstreamMaxIdleConns, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, DefaultUpstreamIdleTimeout, transport.IdleConnTimeout)
+
+	adapter.SetUpstreamPool(8, time.Minute)
+	assert.Equal(t, 8, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, time.Minute, transport.IdleConnTimeout)
+}
@@ -0,0 +1,216 @@
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// UsageEstimator estimates token counts for backends that do not report
+// usage. When TokenizeURL is set it is expected to speak llama.cpp's
+// /tokenize API; otherwise, or if the tokenizer fails, a character-based
+// heuristic is used.
+type UsageEstimator struct {
+	TokenizeURL string
+	client      *http.Client
+}
+
+func NewUsageEstimator(tokenizeURL string) *UsageEstimator {
+	return &UsageEstimator{
+		TokenizeURL: tokenizeURL,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Count returns the number of tokens in text.
+func (e *UsageEstimator) Count(ctx context.Context, text string) int {
+	if text == "" {
+		return 0
+	}
+
+	if e.TokenizeURL != "" {
+		if n, err := e.tokenize(ctx, text); err == nil {
+			return n
+		}
+	}
+
+	return estimateTokens(text)
+}
+
+func (e *UsageEstimator) tokenize(ctx context.Context, text string) (int, error) {
+	body, err := json.Marshal(map[string]any{"content": text})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.TokenizeURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("tokenize endpoint returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Tokens []any `json:"tokens"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, err
+	}
+	return len(result.Tokens), nil
+}
+
+// Usage builds an OpenAI usage object for a request and its completion.
+func (e *UsageEstimator) Usage(ctx context.Context, requestData map[string]any, completion string) map[string]any {
+	prompt := e.Count(ctx, promptText(requestData))
+	output := e.Count(ctx, completion)
+	return map[string]any{
+		"prompt_tokens":     prompt,
+		"completion_tokens": output,
+		"total_tokens":      prompt + output,
+	}
+}
+
+// estimateTokens approximates a token count at roughly four characters per
+// token, which holds reasonably well for English text with BPE tokenizers.
+func estimateTokens(text string) int {
+	n := utf8.RuneCountInString(text)
+	if n == 0 {
+		return 0
+	}
+	return (n + 3) / 4
+}
+
+// promptText flattens the parts of a chat request that count towards prompt
+// tokens.
+func promptText(requestData map[string]any) string {
+	var b strings.Builder
+
+	if messages, ok := requestData["messages"].([]any); ok {
+		for _, msg := range messages {
+			message, ok := msg.(map[string]any)
+			if !ok {
+				continue
+			}
+			role, _ := message["role"].(string)
+			b.WriteString(role)
+			b.WriteString("\n")
+			b.WriteString(messageText(message))
+			b.WriteString("\n")
+			if toolCalls, ok := message["tool_calls"]; ok {
+				if encoded, err := json.Marshal(toolCalls); err == nil {
+					b.Write(encoded)
+				}
+			}
+		}
+	}
+
+	if tools, ok := requestData["tools"]; ok {
+		if encoded, err := json.Marshal(tools); err == nil {
+			b.Write(encoded)
+		}
+	}
+
+	return b.String()
+}
+
+// completionText flattens the parts of an assistant message or delta that
+// count towards completion tokens.
+func completionText(message map[string]any, reasoningField string) string {
+	var b strings.Builder
+
+	if content, ok := message["content"].(string); ok {
+		b.WriteString(content)
+	}
+	if reasoning, ok := message[reasoningField].(string); ok {
+		b.WriteString(reasoning)
+	}
+
+	if toolCalls, ok := message["tool_calls"].([]any); ok {
+		for _, tc := range toolCalls {
+			toolCall, ok := tc.(map[string]any)
+			if !ok {
+				continue
+			}
+			function, ok := toolCall["function"].(map[string]any)
+			if !ok {
+				continue
+			}
+			if name, ok := function["name"].(string); ok {
+				b.WriteString(name)
+			}
+			if arguments, ok := function["arguments"].(string); ok {
+				b.WriteString(arguments)
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// streamUsage accumulates what is needed to synthesize a usage chunk at the
+// end of a stream that did not report one.
+type streamUsage struct {
+	id         string
+	model      string
+	created    any
+	completion strings.Builder
+	seen       bool
+}
+
+func (u *streamUsage) observe(eventData map[string]any, reasoningField string) {
+	if _, ok := eventData["usage"].(map[string]any); ok {
+		u.seen = true
+	}
+	if id, ok := eventData["id"].(string); ok {
+		u.id = id
+	}
+	if model, ok := eventData["model"].(string); ok {
+		u.model = model
+	}
+	if created, ok := eventData["created"]; ok {
+		u.created = created
+	}
+
+	choices, ok := eventData["choices"].([]any)
+	if !ok {
+		return
+	}
+	for _, c := range choices {
+		choice, ok := c.(map[string]any)
+		if !ok {
+			continue
+		}
+		if delta, ok := choice["delta"].(map[string]any); ok {
+			u.completion.WriteString(completionText(delta, reasoningField))
+		}
+	}
+}
+
+func (u *streamUsage) chunk(usage map[string]any) map[string]any {
+	if u.created == nil {
+		u.created = time.Now().Unix()
+	}
+
+	return map[string]any{
+		"id":      u.id,
+		"object":  "chat.completion.chunk",
+		"created": u.created,
+		"model":   u.model,
+		"choices": []any{},
+		"usage":   usage,
+	}
+}
@@ -0,0 +1,114 @@
+package adapter
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+)
+
+// The prompt "user\nHello\n" and the completion "Hi there!" with the
+// reasoning "Greet." estimate to 3 and 4 tokens.
+var syntheticUsage = map[string]any{"prompt_tokens": float64(3), "completion_tokens": float64(4), "total_tokens": float64(7)}
+
+func newSyntheticUsageAdapter(t *testing.T, handler http.HandlerFunc) *Adapter {
+	t.Helper()
+	upstream := httptest.NewServer(handler)
+	t.Cleanup(upstream.Close)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adapter := NewAdapter(upstream.URL, NewLRUCache(10), logger, llamacpp.NewProvider())
+	adapter.Usage = NewUsageEstimator("")
+	return adapter
+}
+
+func TestSyntheticUsage_Blocking(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		upstream string
+		usage    map[string]any
+	}{
+		{"missing", "", syntheticUsage},
+		{"reported", `,"usage":{"prompt_tokens":10,"completion_tokens":20,"total_tokens":30}`,
+			map[string]any{"prompt_tokens": float64(10), "completion_tokens": float64(20), "total_tokens": float64(30)}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			adapter := newSyntheticUsageAdapter(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				io.WriteString(w, `{"object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","reasoning_content":"Greet.","content":"Hi there!"},"finish_reason":"stop"}]`+tt.upstream+`}`)
+			})
+
+			r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"messages":[{"role":"user","content":"Hello"}]}`))
+			w := httptest.NewRecorder()
+			adapter.ServeHTTP(w, r)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var response map[string]any
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.usage, response["usage"])
+		})
+	}
+}
+
+func TestSyntheticUsage_Stream(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		upstream string
+		usage    map[string]any
+	}{
+		{"missing", "", syntheticUsage},
+		{"reported", `data: {"object":"chat.completion.chunk","choices":[],"usage":{"prompt_tokens":10,"completion_tokens":20,"total_tokens":30}}` + "\n\n",
+			map[string]any{"prompt_tokens": float64(10), "completion_tokens": float64(20), "total_tokens": float64(30)}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			adapter := newSyntheticUsageAdapter(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				io.WriteString(w, `data: {"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"role":"assistant","reasoning_content":"Greet."}}]}`+"\n\n")
+				io.WriteString(w, `data: {"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"Hi"}}]}`+"\n\n")
+				io.WriteString(w, `data: {"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":" there!"},"finish_reason":"stop"}]}`+"\n\n")
+				io.WriteString(w, tt.upstream)
+				io.WriteString(w, "data: [DONE]\n\n")
+			})
+
+			body := `{"stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"Hello"}]}`
+			r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+			w := httptest.NewRecorder()
+			adapter.ServeHTTP(w, r)
+			require.Equal(t, http.StatusOK, w.Code)
+			require.True(t, strings.HasSuffix(w.Body.String(), "data: [DONE]\n\n"))
+
+			var usages []any
+			reader := newSSEReader(w.Body, 0)
+			for {
+				event, err := reader.Next()
+				if err != nil {
+					break
+				}
+				if !event.HasData || event.Data == "[DONE]" {
+					continue
+				}
+				var chunk map[string]any
+				require.NoError(t, json.Unmarshal([]byte(event.Data), &chunk))
+				if usage, ok := chunk["usage"]; ok && usage != nil {
+					usages = append(usages, usage)
+					assert.Empty(t, chunk["choices"])
+				}
+			}
+			assert.Equal(t, []any{tt.usage}, usages)
+		})
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	assert.Equal(t, 0, estimateTokens(""))
+	assert.Equal(t, 1, estimateTokens("abc"))
+	assert.Equal(t, 2, estimateTokens("héllo"))
+}
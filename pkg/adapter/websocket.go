@@ -0,0 +1,54 @@
+package adapter
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// isUpgradeRequest reports whether r asks to switch protocols, as WebSocket
+// handshakes do.
+func isUpgradeRequest(r *http.Request) bool {
+	if r.Header.Get("Upgrade") == "" {
+		return false
+	}
+	for _, value := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// upgradeWriter hands ReverseProxy a hijacked connection that first returns
+// what the server already read from the client. A client may send its first
+// frames right after the handshake, and ReverseProxy would otherwise lose
+// them by reading from the connection itself.
+type upgradeWriter struct {
+	http.ResponseWriter
+}
+
+func (u upgradeWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, buffered, err := http.NewResponseController(u.ResponseWriter).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	return bufferedConn{Conn: conn, reader: buffered.Reader}, buffered, nil
+}
+
+func (u upgradeWriter) Unwrap() http.ResponseWriter {
+	return u.ResponseWriter
+}
+
+// bufferedConn reads a hijacked connection through its buffer.
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
@@ -0,0 +1,12 @@
+package harmony
+
+import "github.com/aldehir/gpt-oss-adapter/providers/types"
+
+func NewProvider() types.Provider {
+	return types.Provider{
+		Name:            "harmony",
+		Reasoning:       "reasoning",
+		ReasoningEffort: "reasoning_effort",
+		API:             types.APIHarmony,
+	}
+}
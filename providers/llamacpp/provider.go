@@ -7,5 +7,10 @@ func NewProvider() types.Provider {
 		Name:            "llama-cpp",
 		Reasoning:       "reasoning_content",
 		ReasoningEffort: "chat_template_kwargs.reasoning_effort",
+		// OpenAI platform fields that llama.cpp's server rejects.
+		Unsupported: []string{"store", "metadata", "service_tier"},
+		// The formats llama.cpp's JSON Schema to grammar converter knows.
+		SchemaFormats:  []string{"date", "time", "date-time", "uuid"},
+		ResponseFormat: types.ResponseFormatJSONSchemaField,
 	}
 }
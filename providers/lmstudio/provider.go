@@ -1,11 +1,19 @@
 package lmstudio
 
-import "github.com/aldehir/gpt-oss-adapter/providers/types"
+import (
+	"github.com/aldehir/gpt-oss-adapter/providers/thinktags"
+	"github.com/aldehir/gpt-oss-adapter/providers/types"
+)
 
+// NewProvider returns the LM Studio provider. LM Studio streams reasoning in
+// the reasoning field, except for models whose template it does not know to
+// separate, which stream it inline in <think> tags instead.
 func NewProvider() types.Provider {
 	return types.Provider{
 		Name:            "lmstudio",
 		Reasoning:       "reasoning",
 		ReasoningEffort: "reasoning_effort",
+		NewStreamParser: thinktags.Factory("reasoning"),
+		ResponseFormat:  types.ResponseFormatSchemaOnly,
 	}
 }
@@ -0,0 +1,12 @@
+package ollama
+
+import "github.com/aldehir/gpt-oss-adapter/providers/types"
+
+func NewProvider() types.Provider {
+	return types.Provider{
+		Name:            "ollama",
+		Reasoning:       "thinking",
+		ReasoningEffort: "think",
+		API:             types.APIOllama,
+	}
+}
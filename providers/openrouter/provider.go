@@ -1,12 +1,123 @@
-// TODO: OpenRouter needs a bit more work
 package openrouter
 
-import "github.com/aldehir/gpt-oss-adapter/providers/types"
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/types"
+)
+
+// detailsField holds OpenRouter's structured reasoning: a list of
+// reasoning.text, reasoning.summary and reasoning.encrypted entries that must
+// be sent back unmodified for the model to pick up where it left off.
+const detailsField = "reasoning_details"
+
+// apiKeyEnv names the environment variable the API key is read from.
+const apiKeyEnv = "OPENROUTER_API_KEY"
 
 func NewProvider() types.Provider {
 	return types.Provider{
-		Name:            "openrouter",
-		Reasoning:       "reasoning",
-		ReasoningEffort: "reasoning.effort",
+		Name:                "openrouter",
+		Reasoning:           "reasoning",
+		ReasoningEffort:     "reasoning.effort",
+		APIKey:              os.Getenv(apiKeyEnv),
+		ExtractReasoning:    extractReasoning,
+		InjectReasoning:     injectReasoning,
+		MergeReasoningDelta: mergeReasoningDelta,
+	}
+}
+
+// storedReasoning is the cached form of a message's reasoning when it
+// carries reasoning_details.
+type storedReasoning struct {
+	Reasoning string `json:"reasoning,omitempty"`
+	Details   []any  `json:"reasoning_details"`
+}
+
+// extractReasoning caches the flat reasoning string as-is, or, when the
+// message has reasoning_details, both it and the details encoded as JSON.
+func extractReasoning(message map[string]any) (string, bool) {
+	text, hasText := message["reasoning"].(string)
+
+	details, _ := message[detailsField].([]any)
+	if len(details) == 0 {
+		return text, hasText
+	}
+
+	data, err := json.Marshal(storedReasoning{Reasoning: text, Details: details})
+	if err != nil {
+		return text, hasText
+	}
+	return string(data), true
+}
+
+func injectReasoning(message map[string]any, reasoning string) {
+	var stored storedReasoning
+	if len(reasoning) > 0 && reasoning[0] == '{' && json.Unmarshal([]byte(reasoning), &stored) == nil && len(stored.Details) > 0 {
+		message[detailsField] = stored.Details
+		if stored.Reasoning != "" {
+			message["reasoning"] = stored.Reasoning
+		}
+		return
+	}
+	message["reasoning"] = reasoning
+}
+
+// mergeReasoningDelta concatenates reasoning text and merges streamed
+// reasoning_details entries by index. String fields of an entry, such as
+// text, summary and data, arrive in fragments and are concatenated; other
+// fields are taken from the latest fragment.
+func mergeReasoningDelta(message, delta map[string]any) {
+	if text, ok := delta["reasoning"].(string); ok {
+		existing, _ := message["reasoning"].(string)
+		message["reasoning"] = existing + text
 	}
+
+	fragments, _ := delta[detailsField].([]any)
+	if len(fragments) == 0 {
+		return
+	}
+
+	details, _ := message[detailsField].([]any)
+	for i, f := range fragments {
+		fragment, ok := f.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		index := i
+		if n, ok := fragment["index"].(float64); ok {
+			index = int(n)
+		}
+
+		var entry map[string]any
+		for _, d := range details {
+			if existing, ok := d.(map[string]any); ok && entryIndex(existing) == index {
+				entry = existing
+				break
+			}
+		}
+		if entry == nil {
+			entry = map[string]any{"index": float64(index)}
+			details = append(details, entry)
+		}
+
+		for key, value := range fragment {
+			if key == "index" {
+				continue
+			}
+			if text, ok := value.(string); ok && key != "type" && key != "format" && key != "id" {
+				existing, _ := entry[key].(string)
+				entry[key] = existing + text
+				continue
+			}
+			entry[key] = value
+		}
+	}
+	message[detailsField] = details
+}
+
+func entryIndex(entry map[string]any) int {
+	n, _ := entry["index"].(float64)
+	return int(n)
 }
@@ -0,0 +1,185 @@
+package providers
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/harmony"
+	"github.com/aldehir/gpt-oss-adapter/providers/llamacpp"
+	"github.com/aldehir/gpt-oss-adapter/providers/lmstudio"
+	"github.com/aldehir/gpt-oss-adapter/providers/ollama"
+	"github.com/aldehir/gpt-oss-adapter/providers/openrouter"
+	"github.com/aldehir/gpt-oss-adapter/providers/responses"
+	"github.com/aldehir/gpt-oss-adapter/providers/types"
+	"github.com/aldehir/gpt-oss-adapter/providers/vllm"
+)
+
+// Registry holds the provider definitions known to the adapter, keyed by
+// name.
+type Registry struct {
+	mutex     sync.RWMutex
+	providers map[string]types.Provider
+}
+
+// NewRegistry returns a registry containing the built-in providers.
+func NewRegistry() *Registry {
+	r := &Registry{providers: make(map[string]types.Provider)}
+	r.Register(lmstudio.NewProvider())
+	r.Register(llamacpp.NewProvider())
+	r.Register(openrouter.NewProvider())
+	r.Register(vllm.NewProvider())
+	r.Register(ollama.NewProvider())
+	r.Register(harmony.NewProvider())
+	r.Register(responses.NewProvider())
+	return r
+}
+
+// Register adds or replaces a provider definition.
+func (r *Registry) Register(provider types.Provider) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.providers[provider.Name] = provider
+}
+
+// Get returns the provider registered under name.
+func (r *Registry) Get(name string) (types.Provider, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	provider, ok := r.providers[name]
+	return provider, ok
+}
+
+// Names returns the names of all registered providers in sorted order.
+func (r *Registry) Names() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Merge applies a provider definition to the registry. Non-empty fields
+// override those of an existing provider with the same name; otherwise the
+// definition is registered as a new provider, which must at least name its
+// reasoning field. Header values are expanded against the environment, so
+// secrets can be referenced as ${VAR} rather than written to disk.
+func (r *Registry) Merge(definition types.Provider) error {
+	if definition.Name == "" {
+		return fmt.Errorf("provider definition is missing a name")
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	provider, exists := r.providers[definition.Name]
+	if !exists {
+		if definition.Reasoning == "" {
+			return fmt.Errorf("provider %q: reasoning field is required", definition.Name)
+		}
+		provider.Name = definition.Name
+	}
+
+	if definition.Reasoning != "" {
+		provider.Reasoning = definition.Reasoning
+	}
+	if definition.ReasoningEffort != "" {
+		provider.ReasoningEffort = definition.ReasoningEffort
+	}
+	if definition.Target != "" {
+		provider.Target = os.ExpandEnv(definition.Target)
+	}
+	if definition.API != "" {
+		provider.API = definition.API
+	}
+	if definition.APIKey != "" {
+		provider.APIKey = os.ExpandEnv(definition.APIKey)
+	}
+	if len(definition.Body) > 0 {
+		provider.Body = definition.Body
+	}
+	if len(definition.Unsupported) > 0 {
+		provider.Unsupported = definition.Unsupported
+	}
+	if len(definition.Rename) > 0 {
+		provider.Rename = definition.Rename
+	}
+	if len(definition.SchemaUnsupported) > 0 {
+		provider.SchemaUnsupported = definition.SchemaUnsupported
+	}
+	if len(definition.SchemaFormats) > 0 {
+		provider.SchemaFormats = definition.SchemaFormats
+	}
+	switch definition.ToolChoice {
+	case "":
+	case types.ToolChoiceNative, types.ToolChoiceGrammar:
+		provider.ToolChoice = definition.ToolChoice
+	default:
+		return fmt.Errorf("provider %q: unknown tool_choice %q, expected %s or %s", definition.Name, definition.ToolChoice, types.ToolChoiceNative, types.ToolChoiceGrammar)
+	}
+	switch definition.ResponseFormat {
+	case "":
+	case types.ResponseFormatOpenAI, types.ResponseFormatJSONSchemaField, types.ResponseFormatSchemaOnly:
+		provider.ResponseFormat = definition.ResponseFormat
+	default:
+		return fmt.Errorf("provider %q: unknown response_format %q, expected %s, %s or %s", definition.Name, definition.ResponseFormat,
+			types.ResponseFormatOpenAI, types.ResponseFormatJSONSchemaField, types.ResponseFormatSchemaOnly)
+	}
+	if definition.DropEmptyReasoning {
+		provider.DropEmptyReasoning = true
+	}
+	if len(definition.Headers) > 0 {
+		headers := make(map[string]string, len(provider.Headers)+len(definition.Headers))
+		for name, value := range provider.Headers {
+			headers[name] = value
+		}
+		for name, value := range definition.Headers {
+			headers[name] = os.ExpandEnv(value)
+		}
+		provider.Headers = headers
+	}
+
+	r.providers[definition.Name] = provider
+	return nil
+}
+
+// LoadFile merges provider definitions from a YAML or JSON file mapping
+// provider names to definitions.
+func (r *Registry) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var definitions map[string]types.Provider
+	if err := yaml.Unmarshal(data, &definitions); err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	return r.MergeAll(definitions)
+}
+
+// MergeAll merges a set of definitions keyed by provider name.
+func (r *Registry) MergeAll(definitions map[string]types.Provider) error {
+	names := make([]string, 0, len(definitions))
+	for name := range definitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		definition := definitions[name]
+		definition.Name = name
+		if err := r.Merge(definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package providers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/types"
+)
+
+func TestRegistry_BuiltIns(t *testing.T) {
+	registry := NewRegistry()
+	assert.Equal(t, []string{"harmony", "llama-cpp", "lmstudio", "ollama", "openrouter", "responses", "vllm"}, registry.Names())
+
+	provider, ok := registry.Get("llama-cpp")
+	require.True(t, ok)
+	assert.Equal(t, "reasoning_content", provider.Reasoning)
+}
+
+func TestRegistry_Merge(t *testing.T) {
+	registry := NewRegistry()
+
+	require.NoError(t, registry.Merge(types.Provider{Name: "lmstudio", ReasoningEffort: "reasoning.effort"}))
+	provider, _ := registry.Get("lmstudio")
+	assert.Equal(t, "reasoning", provider.Reasoning)
+	assert.Equal(t, "reasoning.effort", provider.ReasoningEffort)
+
+	assert.Error(t, registry.Merge(types.Provider{Name: "incomplete"}))
+	assert.Error(t, registry.Merge(types.Provider{Reasoning: "reasoning"}))
+
+	require.NoError(t, registry.Merge(types.Provider{Name: "llama-cpp", ToolChoice: types.ToolChoiceGrammar}))
+	provider, _ = registry.Get("llama-cpp")
+	assert.Equal(t, types.ToolChoiceGrammar, provider.ToolChoice)
+	assert.ErrorContains(t, registry.Merge(types.Provider{Name: "llama-cpp", ToolChoice: "prompt"}), `unknown tool_choice "prompt"`)
+}
+
+func TestRegistry_LoadFile(t *testing.T) {
+	t.Setenv("TEST_PROVIDER_KEY", "secret")
+	t.Setenv("TEST_PROVIDER_HOST", "vllm.internal")
+
+	path := filepath.Join(t.TempDir(), "providers.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+vllm:
+  reasoning: reasoning_content
+  reasoning_effort: chat_template_kwargs.reasoning_effort
+  target: http://${TEST_PROVIDER_HOST}:8000
+  headers:
+    Authorization: "Bearer ${TEST_PROVIDER_KEY}"
+  unsupported: [logit_bias, store]
+  schema_unsupported: [$schema]
+  rename:
+    max_completion_tokens: max_tokens
+`), 0o600))
+
+	registry := NewRegistry()
+	require.NoError(t, registry.LoadFile(path))
+
+	provider, ok := registry.Get("vllm")
+	require.True(t, ok)
+	assert.Equal(t, "vllm", provider.Name)
+	assert.Equal(t, "reasoning_content", provider.Reasoning)
+	assert.Equal(t, "http://vllm.internal:8000", provider.Target)
+	assert.Equal(t, map[string]string{"Authorization": "Bearer secret"}, provider.Headers)
+	assert.Equal(t, []string{"logit_bias", "store"}, provider.Unsupported)
+	assert.Equal(t, map[string]string{"max_completion_tokens": "max_tokens"}, provider.Rename)
+	assert.Equal(t, []string{"$schema"}, provider.SchemaUnsupported)
+}
+
+func TestRegistry_OpenRouter(t *testing.T) {
+	t.Setenv("OPENROUTER_API_KEY", "sk-or-env")
+	t.Setenv("TEST_OPENROUTER_KEY", "sk-or-file")
+
+	registry := NewRegistry()
+	provider, _ := registry.Get("openrouter")
+	assert.Equal(t, "sk-or-env", provider.APIKey)
+
+	path := filepath.Join(t.TempDir(), "providers.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+openrouter:
+  api_key: ${TEST_OPENROUTER_KEY}
+  body:
+    provider:
+      order: [groq, cerebras]
+      allow_fallbacks: false
+      require_parameters: true
+`), 0o600))
+	require.NoError(t, registry.LoadFile(path))
+
+	provider, _ = registry.Get("openrouter")
+	assert.Equal(t, "sk-or-file", provider.APIKey)
+	assert.Equal(t, map[string]any{
+		"provider": map[string]any{
+			"order":              []any{"groq", "cerebras"},
+			"allow_fallbacks":    false,
+			"require_parameters": true,
+		},
+	}, provider.Body)
+}
@@ -0,0 +1,74 @@
+package responses
+
+import (
+	"encoding/json"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/types"
+)
+
+// itemsField holds the Responses API reasoning items a message was
+// translated from. They are sent back as they are, IDs included, so that the
+// backend recognizes its own items.
+const itemsField = "reasoning_items"
+
+func NewProvider() types.Provider {
+	return types.Provider{
+		Name:                "responses",
+		Reasoning:           "reasoning_content",
+		ReasoningEffort:     "reasoning.effort",
+		API:                 types.APIResponses,
+		ExtractReasoning:    extractReasoning,
+		InjectReasoning:     injectReasoning,
+		MergeReasoningDelta: mergeReasoningDelta,
+	}
+}
+
+// storedReasoning is the cached form of a message's reasoning when it
+// carries reasoning items.
+type storedReasoning struct {
+	Reasoning string `json:"reasoning,omitempty"`
+	Items     []any  `json:"reasoning_items"`
+}
+
+// extractReasoning caches the reasoning text as-is, or, when the message has
+// reasoning items, both it and the items encoded as JSON.
+func extractReasoning(message map[string]any) (string, bool) {
+	text, hasText := message["reasoning_content"].(string)
+
+	items, _ := message[itemsField].([]any)
+	if len(items) == 0 {
+		return text, hasText
+	}
+
+	data, err := json.Marshal(storedReasoning{Reasoning: text, Items: items})
+	if err != nil {
+		return text, hasText
+	}
+	return string(data), true
+}
+
+func injectReasoning(message map[string]any, reasoning string) {
+	var stored storedReasoning
+	if len(reasoning) > 0 && reasoning[0] == '{' && json.Unmarshal([]byte(reasoning), &stored) == nil && len(stored.Items) > 0 {
+		message[itemsField] = stored.Items
+		if stored.Reasoning != "" {
+			message["reasoning_content"] = stored.Reasoning
+		}
+		return
+	}
+	message["reasoning_content"] = reasoning
+}
+
+// mergeReasoningDelta concatenates reasoning text and collects the reasoning
+// items, which are streamed whole once each is done.
+func mergeReasoningDelta(message, delta map[string]any) {
+	if text, ok := delta["reasoning_content"].(string); ok {
+		existing, _ := message["reasoning_content"].(string)
+		message["reasoning_content"] = existing + text
+	}
+
+	if items, ok := delta[itemsField].([]any); ok && len(items) > 0 {
+		existing, _ := message[itemsField].([]any)
+		message[itemsField] = append(existing, items...)
+	}
+}
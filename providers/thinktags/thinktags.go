@@ -0,0 +1,158 @@
+// Package thinktags handles reasoning that a backend emits inline in the
+// message content between <think> and </think> tags, as some chat templates
+// do, instead of in a separate field.
+package thinktags
+
+import (
+	"strings"
+
+	"github.com/aldehir/gpt-oss-adapter/providers/types"
+)
+
+// Tags delimiting reasoning in the content.
+const (
+	OpenTag  = "<think>"
+	CloseTag = "</think>"
+)
+
+// StreamParser moves tagged reasoning out of the content of streamed deltas
+// and into a reasoning field. Tags may be split across deltas.
+type StreamParser struct {
+	field string
+
+	thinking bool
+	// pending is text held back because it may be the start of a tag.
+	pending string
+	// afterThinking is set between a closing tag and the first content,
+	// so that the whitespace separating the two can be dropped.
+	afterThinking bool
+}
+
+// NewStreamParser returns a parser that writes reasoning to field.
+func NewStreamParser(field string) *StreamParser {
+	return &StreamParser{field: field}
+}
+
+// Factory returns a types.Provider NewStreamParser hook writing to field.
+func Factory(field string) func() types.StreamParser {
+	return func() types.StreamParser {
+		return NewStreamParser(field)
+	}
+}
+
+// Parse moves the tagged parts of the delta's content to the reasoning field.
+func (p *StreamParser) Parse(delta map[string]any) {
+	content, ok := delta["content"].(string)
+	if !ok && p.pending == "" {
+		return
+	}
+
+	text := p.pending + content
+	p.pending = ""
+
+	var reasoning, visible strings.Builder
+	for text != "" {
+		tag := OpenTag
+		if p.thinking {
+			tag = CloseTag
+		}
+
+		if i := strings.Index(text, tag); i >= 0 {
+			p.write(&reasoning, &visible, text[:i])
+			text = text[i+len(tag):]
+			p.thinking = !p.thinking
+			if !p.thinking {
+				p.afterThinking = true
+			}
+			continue
+		}
+
+		held := partialTagLength(text, tag)
+		p.write(&reasoning, &visible, text[:len(text)-held])
+		p.pending = text[len(text)-held:]
+		break
+	}
+
+	p.apply(delta, reasoning.String(), visible.String(), ok)
+}
+
+// Flush adds a partial tag held back at the end of the stream as text.
+func (p *StreamParser) Flush(delta map[string]any) {
+	if p.pending == "" {
+		return
+	}
+
+	var reasoning, visible strings.Builder
+	p.write(&reasoning, &visible, p.pending)
+	p.pending = ""
+
+	p.apply(delta, reasoning.String(), visible.String(), false)
+}
+
+func (p *StreamParser) write(reasoning, visible *strings.Builder, text string) {
+	if p.thinking {
+		reasoning.WriteString(text)
+		return
+	}
+
+	if p.afterThinking {
+		text = strings.TrimLeft(text, " \t\r\n")
+		if text == "" {
+			return
+		}
+		p.afterThinking = false
+	}
+	visible.WriteString(text)
+}
+
+// apply appends the reasoning to the reasoning field and the visible text to
+// the content of delta. consumed means the content of delta was parsed and
+// is replaced rather than appended to.
+func (p *StreamParser) apply(delta map[string]any, reasoning, visible string, consumed bool) {
+	if reasoning != "" {
+		existing, _ := delta[p.field].(string)
+		delta[p.field] = existing + reasoning
+	}
+
+	content, _ := delta["content"].(string)
+	if consumed {
+		content = ""
+	}
+	content += visible
+
+	switch {
+	case content != "":
+		delta["content"] = content
+	case consumed && reasoning != "":
+		delete(delta, "content")
+	case consumed:
+		delta["content"] = ""
+	}
+}
+
+// Split moves the tagged reasoning in a message's content to field.
+func Split(message map[string]any, field string) {
+	if _, ok := message["content"].(string); !ok {
+		return
+	}
+	p := NewStreamParser(field)
+	p.Parse(message)
+	p.Flush(message)
+}
+
+// Wrap returns content preceded by reasoning in tags, the way a model that
+// only speaks tags would have written it.
+func Wrap(reasoning, content string) string {
+	return OpenTag + reasoning + CloseTag + "\n\n" + content
+}
+
+// partialTagLength returns the length of the longest suffix of text that is
+// a proper prefix of tag.
+func partialTagLength(text, tag string) int {
+	for n := min(len(text), len(tag)-1); n > 0; n-- {
+		if strings.HasSuffix(text, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
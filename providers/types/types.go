@@ -1,7 +1,122 @@
 package types
 
+const (
+	// APIOpenAI forwards OpenAI-compatible chat completions as-is.
+	APIOpenAI = "openai"
+	// APIOllama translates chat completions to and from Ollama's native
+	// /api/chat endpoint.
+	APIOllama = "ollama"
+	// APIHarmony renders chat completions as a raw gpt-oss Harmony prompt
+	// for a /v1/completions endpoint and parses the channel markup in the
+	// completion, for backends that do not parse Harmony themselves.
+	APIHarmony = "harmony"
+	// APIResponses translates chat completions to and from the OpenAI
+	// Responses API, for backends with native reasoning items.
+	APIResponses = "responses"
+)
+
+const (
+	// ToolChoiceNative leaves tool_choice to the backend.
+	ToolChoiceNative = "native"
+	// ToolChoiceGrammar enforces a tool_choice that requires a tool call
+	// with a json_schema response format, for backends that ignore
+	// tool_choice, and translates the JSON the model returns into a tool
+	// call.
+	ToolChoiceGrammar = "grammar"
+)
+
+const (
+	// ResponseFormatOpenAI sends response_format as the client did.
+	ResponseFormatOpenAI = "openai"
+	// ResponseFormatJSONSchemaField sends the schema of a json_schema or
+	// json_object response format in a top-level json_schema field, which
+	// llama.cpp compiles into a grammar.
+	ResponseFormatJSONSchemaField = "json_schema"
+	// ResponseFormatSchemaOnly sends json_object response formats as a
+	// json_schema one, for backends that only accept the latter.
+	ResponseFormatSchemaOnly = "schema_only"
+)
+
 type Provider struct {
-	Name            string
-	Reasoning       string
-	ReasoningEffort string
+	Name            string            `yaml:"name"`
+	Reasoning       string            `yaml:"reasoning"`
+	ReasoningEffort string            `yaml:"reasoning_effort"`
+	Headers         map[string]string `yaml:"headers"`
+
+	// Target is the backend URL used when the provider is selected per
+	// request. Empty means the adapter's --target.
+	Target string `yaml:"target"`
+
+	// API selects the wire format spoken by the backend. Empty means
+	// APIOpenAI.
+	API string `yaml:"api"`
+
+	// APIKey is sent to the backend as a bearer token in place of the
+	// client's credentials, so that clients do not need to know it.
+	APIKey string `yaml:"api_key"`
+
+	// Body holds fields merged into every chat request sent to the backend,
+	// such as OpenRouter's provider routing preferences. Fields the client
+	// sets take precedence; objects are merged key by key.
+	Body map[string]any `yaml:"body"`
+
+	// Unsupported lists request fields the backend rejects, as dotted
+	// paths. They are removed from chat requests before they are sent.
+	Unsupported []string `yaml:"unsupported"`
+
+	// Rename maps request fields to the names the backend understands them
+	// by, e.g. max_completion_tokens to max_tokens. A field already set
+	// under the new name is kept.
+	Rename map[string]string `yaml:"rename"`
+
+	// SchemaUnsupported lists JSON Schema keywords the backend cannot
+	// compile into a grammar. They are removed from tool parameters.
+	SchemaUnsupported []string `yaml:"schema_unsupported"`
+
+	// SchemaFormats lists the string formats the backend supports in tool
+	// parameters. Other formats are removed; if empty, all are kept.
+	SchemaFormats []string `yaml:"schema_formats"`
+
+	// ToolChoice selects how a tool_choice that requires a tool call is
+	// enforced. Empty means ToolChoiceNative.
+	ToolChoice string `yaml:"tool_choice"`
+
+	// ResponseFormat selects how structured output requests are sent to
+	// the backend. Empty means ResponseFormatOpenAI.
+	ResponseFormat string `yaml:"response_format"`
+
+	// DropEmptyReasoning removes empty-string reasoning fields from
+	// responses and stream deltas, which some backends emit on every chunk.
+	DropEmptyReasoning bool `yaml:"drop_empty_reasoning"`
+
+	// ExtractReasoning returns the reasoning to cache for an assistant
+	// message, for providers whose reasoning is more than the string in the
+	// Reasoning field. The result is opaque to the adapter and handed back
+	// to InjectReasoning. Nil reads the Reasoning field.
+	ExtractReasoning func(message map[string]any) (string, bool) `yaml:"-"`
+
+	// InjectReasoning restores reasoning returned by ExtractReasoning into
+	// an assistant message. Nil sets the Reasoning field.
+	InjectReasoning func(message map[string]any, reasoning string) `yaml:"-"`
+
+	// MergeReasoningDelta folds a stream delta into the message accumulated
+	// so far, such that ExtractReasoning can read the result. Nil
+	// concatenates the Reasoning field.
+	MergeReasoningDelta func(message, delta map[string]any) `yaml:"-"`
+
+	// NewStreamParser returns a parser for the deltas of one streamed
+	// choice, for backends that stream reasoning somewhere other than the
+	// Reasoning field. Deltas are parsed before anything else reads them.
+	// Nil leaves deltas as they are.
+	NewStreamParser func() StreamParser `yaml:"-"`
+}
+
+// StreamParser rewrites the deltas of one streamed choice.
+type StreamParser interface {
+	// Parse rewrites a delta in place. Text that cannot be placed yet, such
+	// as the start of a tag, may be held back until a later delta.
+	Parse(delta map[string]any)
+
+	// Flush adds anything held back to the last delta of the choice.
+	Flush(delta map[string]any)
 }
@@ -0,0 +1,12 @@
+package vllm
+
+import "github.com/aldehir/gpt-oss-adapter/providers/types"
+
+func NewProvider() types.Provider {
+	return types.Provider{
+		Name:               "vllm",
+		Reasoning:          "reasoning_content",
+		ReasoningEffort:    "chat_template_kwargs.reasoning_effort",
+		DropEmptyReasoning: true,
+	}
+}